@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAccount names the account whose credentials are the exchange's regular ones
+const defaultAccount = "default"
+
+// accountNamePattern restricts account names to what can be used in environment variable and keyring names
+var accountNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// AccountWeight is an account of a multi-account run and its relative share of the run's slices
+type AccountWeight struct {
+	Name   string `json:"name"`
+	Weight int    `json:"weight"`
+}
+
+// parseAccounts parses a comma-separated account list such as "default:2,sub1:1". Accounts without a weight
+// get 1, so "default,sub1,sub2" alternates between them.
+func parseAccounts(spec string) ([]AccountWeight, error) {
+	var accounts []AccountWeight
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(spec, ",") {
+		name, weightSpec, hasWeight := strings.Cut(strings.TrimSpace(entry), ":")
+		name = strings.ToLower(name)
+		if !accountNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid account name %q", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("account %s is listed twice", name)
+		}
+		seen[name] = true
+
+		weight := 1
+		if hasWeight {
+			var err error
+			if weight, err = strconv.Atoi(weightSpec); err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight %q for account %s", weightSpec, name)
+			}
+		}
+		accounts = append(accounts, AccountWeight{Name: name, Weight: weight})
+	}
+	return accounts, nil
+}
+
+// accountCredentialsName returns the name an account's credentials are stored under, e.g. BINANCE_SUB1_API_KEY
+// and the binance-sub1-api-key keyring account for account sub1
+func accountCredentialsName(exchange, market string, testnet bool, account string) string {
+	return credentialsName(exchange, market, testnet) + "-" + account
+}
+
+// tradingAccount is one account of a multiAccountClient
+type tradingAccount struct {
+	AccountWeight
+	client ExchangeClient
+	// current is the account's smooth weighted round-robin counter
+	current int
+}
+
+// multiAccountClient spreads the orders of a run over several accounts of one exchange, in proportion to their
+// weights. Market data and symbol rules come from the first account. Orders are tagged with the account that
+// placed them and queried and cancelled through it, and balances are the totals over all accounts.
+type multiAccountClient struct {
+	ExchangeClient
+	accounts []*tradingAccount
+
+	mu sync.Mutex
+	// owners maps order IDs, and routes maps client order IDs without their reprice suffix, to accounts
+	owners map[string]*tradingAccount
+	routes map[string]*tradingAccount
+}
+
+// newMultiAccountClient combines the clients of accounts, which must be in the same order as clients
+func newMultiAccountClient(accounts []AccountWeight, clients []ExchangeClient) *multiAccountClient {
+	c := &multiAccountClient{ExchangeClient: clients[0], owners: map[string]*tradingAccount{}, routes: map[string]*tradingAccount{}}
+	for i, account := range accounts {
+		c.accounts = append(c.accounts, &tradingAccount{AccountWeight: account, client: clients[i]})
+	}
+	return c
+}
+
+// route returns the account an order is placed on. Repriced orders stay on the account of the order they
+// replace, which holds the released funds. Other orders go to the accounts by smooth weighted round-robin,
+// which interleaves them rather than sending each account's share in a burst.
+func (c *multiAccountClient) route(clientOrderID string) *tradingAccount {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, _ := splitRepriceSuffix(clientOrderID)
+	if account, ok := c.routes[key]; ok {
+		return account
+	}
+
+	var total int
+	var selected *tradingAccount
+	for _, account := range c.accounts {
+		account.current += account.Weight
+		total += account.Weight
+		if selected == nil || account.current > selected.current {
+			selected = account
+		}
+	}
+	selected.current -= total
+	if key != "" {
+		c.routes[key] = selected
+	}
+	return selected
+}
+
+// remember records the account an order belongs to and tags the order with it
+func (c *multiAccountClient) remember(account *tradingAccount, order *Order) {
+	order.Account = account.Name
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.owners[order.OrderID] = account
+	if key, _ := splitRepriceSuffix(order.ClientOrderID); key != "" {
+		c.routes[key] = account
+	}
+}
+
+// owner returns the account that placed an order, if known
+func (c *multiAccountClient) owner(orderID string) (*tradingAccount, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	account, ok := c.owners[orderID]
+	return account, ok
+}
+
+// PlaceOrder places an order on the next account
+func (c *multiAccountClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	account := c.route(req.ClientOrderID)
+	order, err := account.client.PlaceOrder(req)
+	if err != nil {
+		// Wrapped so the exchange error can still be classified
+		return nil, fmt.Errorf("account %s: %w", account.Name, err)
+	}
+	c.remember(account, order)
+	return order, nil
+}
+
+// GetOrder queries an order through the account that placed it. Orders placed before a restart are looked up
+// on every account.
+func (c *multiAccountClient) GetOrder(symbol, orderID string) (*Order, error) {
+	if account, ok := c.owner(orderID); ok {
+		order, err := account.client.GetOrder(symbol, orderID)
+		if err != nil {
+			return nil, err
+		}
+		order.Account = account.Name
+		return order, nil
+	}
+
+	var lastErr error
+	for _, account := range c.accounts {
+		order, err := account.client.GetOrder(symbol, orderID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		c.remember(account, order)
+		return order, nil
+	}
+	return nil, lastErr
+}
+
+// CancelOrder cancels an order through the account that placed it
+func (c *multiAccountClient) CancelOrder(symbol, orderID string) error {
+	account, ok := c.owner(orderID)
+	if !ok {
+		if _, err := c.GetOrder(symbol, orderID); err != nil {
+			return err
+		}
+		account, _ = c.owner(orderID)
+	}
+	return account.client.CancelOrder(symbol, orderID)
+}
+
+// GetBalance returns the total free balance of an asset over all accounts
+func (c *multiAccountClient) GetBalance(asset string) (Decimal, error) {
+	var total Decimal
+	for _, account := range c.accounts {
+		balance, err := account.client.GetBalance(asset)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("account %s: %v", account.Name, err)
+		}
+		total = total.Add(balance)
+	}
+	return total, nil
+}
+
+// GetOrderBook fetches the order book through the first account
+func (c *multiAccountClient) GetOrderBook(symbol string, levels int) (*OrderBook, error) {
+	provider, ok := baseClient(c.ExchangeClient).(OrderBookProvider)
+	if !ok {
+		return nil, fmt.Errorf("order book is not supported for this exchange")
+	}
+	return provider.GetOrderBook(symbol, levels)
+}
+
+// GetOpenOrders lists the open orders of a symbol on every account
+func (c *multiAccountClient) GetOpenOrders(symbol string) ([]*Order, error) {
+	var orders []*Order
+	for _, account := range c.accounts {
+		history, ok := baseClient(account.client).(OrderHistory)
+		if !ok {
+			return nil, fmt.Errorf("order history is not supported for this exchange")
+		}
+		accountOrders, err := history.GetOpenOrders(symbol)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %v", account.Name, err)
+		}
+		for _, order := range accountOrders {
+			c.remember(account, order)
+		}
+		orders = append(orders, accountOrders...)
+	}
+	return orders, nil
+}
+
+// GetTrades lists the trades of a symbol between two times on every account
+func (c *multiAccountClient) GetTrades(symbol string, since, until time.Time) ([]Trade, error) {
+	var trades []Trade
+	for _, account := range c.accounts {
+		history, ok := baseClient(account.client).(OrderHistory)
+		if !ok {
+			return nil, fmt.Errorf("order history is not supported for this exchange")
+		}
+		accountTrades, err := history.GetTrades(symbol, since, until)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %v", account.Name, err)
+		}
+		for i := range accountTrades {
+			accountTrades[i].Account = account.Name
+		}
+		trades = append(trades, accountTrades...)
+	}
+	return trades, nil
+}
+
+// checkAccountBalances verifies that every account holds its weighted share of the run's budget, valuing
+// base asset balances at price for runs budgeted in the quote asset
+func (c *multiAccountClient) checkAccountBalances(cfg TWAPConfig, price float64) error {
+	var totalWeight int
+	for _, account := range c.accounts {
+		totalWeight += account.Weight
+	}
+
+	asset := cfg.QuoteAsset
+	if cfg.Side == "SELL" {
+		asset = cfg.baseAsset()
+	}
+	for _, account := range c.accounts {
+		share := cfg.Amount.Mul(NewDecimalFromInt(int64(account.Weight))).Div(NewDecimalFromInt(int64(totalWeight)))
+		balance, err := account.client.GetBalance(asset)
+		if err != nil {
+			return fmt.Errorf("error getting %s balance of account %s: %v", asset, account.Name, err)
+		}
+		available := balance
+		if cfg.Side == "SELL" && !cfg.BaseAmount {
+			available = balance.Mul(NewDecimalFromFloat(price))
+		}
+		log.Printf("Account %s: weight %d, share %s %s, available %s %s", account.Name, account.Weight, share, cfg.budgetAsset(), available, cfg.budgetAsset())
+		if available.LessThan(share) {
+			return fmt.Errorf("account %s has %s %s available, less than its %s %s share of the run", account.Name, available, cfg.budgetAsset(), share, cfg.budgetAsset())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// arbLeg converts one asset of a triangle into the next by trading symbol: buying its base with its quote,
+// or selling its base for its quote
+type arbLeg struct {
+	Symbol   string
+	From, To string
+	Buy      bool
+}
+
+// newArbLeg creates the leg converting from into to through symbol
+func newArbLeg(symbol, from, to string) arbLeg {
+	return arbLeg{Symbol: symbol, From: from, To: to, Buy: symbol == to+from}
+}
+
+// arbCycle is one direction around a triangle of symbols, starting and ending in the same asset
+type arbCycle struct {
+	Legs [3]arbLeg
+}
+
+// String returns the cycle's path of assets, e.g. USDT>BTC>ETH>USDT
+func (c arbCycle) String() string {
+	return strings.Join([]string{c.Legs[0].From, c.Legs[0].To, c.Legs[1].To, c.Legs[2].To}, ">")
+}
+
+// parseTriangle parses three comma-separated symbols that link start with two other assets, e.g.
+// BTCUSDT,ETHBTC,ETHUSDT for USDT, into the cycles around them in both directions
+func parseTriangle(spec, start string) ([2]arbCycle, error) {
+	var cycles [2]arbCycle
+	var linked, other []string
+	for _, symbol := range strings.Split(spec, ",") {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if strings.HasSuffix(symbol, start) || strings.HasPrefix(symbol, start) {
+			linked = append(linked, symbol)
+		} else {
+			other = append(other, symbol)
+		}
+	}
+	if len(linked) != 2 || len(other) != 1 {
+		return cycles, fmt.Errorf("invalid triangle %q: two symbols must trade %s and a third the two other assets", spec, start)
+	}
+	trim := func(symbol string) string {
+		return strings.TrimPrefix(strings.TrimSuffix(symbol, start), start)
+	}
+	a, b, cross := trim(linked[0]), trim(linked[1]), other[0]
+	if cross != a+b && cross != b+a {
+		return cycles, fmt.Errorf("invalid triangle %q: %s does not trade %s against %s", spec, cross, a, b)
+	}
+	cycles[0] = arbCycle{Legs: [3]arbLeg{newArbLeg(linked[0], start, a), newArbLeg(cross, a, b), newArbLeg(linked[1], b, start)}}
+	cycles[1] = arbCycle{Legs: [3]arbLeg{newArbLeg(linked[1], start, b), newArbLeg(cross, b, a), newArbLeg(linked[0], a, start)}}
+	return cycles, nil
+}
+
+// edgeBps returns what one unit of the start asset grows to around the cycle at the best bid and ask, after
+// paying feeBps on every leg, as a gain in basis points. It reports false when a book has no bid or ask.
+func (c arbCycle) edgeBps(tickers map[string]*BookTicker, feeBps float64) (float64, bool) {
+	amount := 1.0
+	for _, leg := range c.Legs {
+		ticker := tickers[leg.Symbol]
+		if ticker == nil || ticker.BidPrice <= 0 || ticker.AskPrice <= 0 {
+			return 0, false
+		}
+		if leg.Buy {
+			amount /= ticker.AskPrice
+		} else {
+			amount *= ticker.BidPrice
+		}
+		amount *= 1 - feeBps/10000
+	}
+	return (amount - 1) * 10000, true
+}
+
+// execute trades notional of the start asset around the cycle with market orders, each leg spending what the
+// previous one received net of commissions, and returns the amount of the start asset received. A leg that
+// fails leaves the previous leg's asset in the account.
+func (c arbCycle) execute(client ExchangeClient, notional Decimal) (Decimal, error) {
+	amount := notional
+	for i, leg := range c.Legs {
+		filters, err := client.GetSymbolFilters(leg.Symbol)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("leg %d: error getting symbol filters for %s: %v", i+1, leg.Symbol, err)
+		}
+		request := OrderRequest{Symbol: leg.Symbol, Side: "SELL", Type: OrderTypeMarket}
+		if leg.Buy {
+			request.Side = "BUY"
+			request.QuoteQuantity = filters.RoundQuote(amount)
+			err = filters.ValidateNotional(request.QuoteQuantity)
+		} else {
+			request.Quantity = filters.RoundQuantity(amount)
+			var price float64
+			if price, err = client.GetPrice(leg.Symbol); err == nil {
+				err = filters.ValidateOrder(request.Quantity, NewDecimalFromFloat(price))
+			}
+		}
+		if err != nil {
+			return Decimal{}, fmt.Errorf("leg %d: cannot trade %s %s on %s: %v", i+1, amount, leg.From, leg.Symbol, err)
+		}
+		order, err := client.PlaceOrder(request)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("leg %d: error placing %s order on %s, holding %s %s: %v", i+1, request.Side, leg.Symbol, amount, leg.From, err)
+		}
+		received := filledQuote(order)
+		if leg.Buy {
+			received = decimalOrZero(order.ExecutedQty)
+		}
+		if order.CommissionAsset == leg.To {
+			received = received.Sub(order.Commission)
+		}
+		log.Printf("Arbitrage leg %d: %s %s order %s traded %s %s for %s %s", i+1, request.Side, leg.Symbol, order.OrderID, amount, leg.From, received, leg.To)
+		amount = received
+	}
+	return amount, nil
+}
+
+// runArb scans triangles of symbols for arbitrage opportunities at the best bid and ask, logging the edge of
+// both directions around each after fees, and with -execute trades the cycles whose edge exceeds the
+// threshold within strict notional limits
+func runArb(args []string) error {
+	fs, common := newFlagSet("arb")
+	triangles := fs.String("triangles", "", "Semicolon-separated triangles of three comma-separated symbols linking the start asset with two other assets (e.g., BTCUSDT,ETHBTC,ETHUSDT;BTCUSDT,BNBBTC,BNBUSDT)")
+	startAsset := fs.String("start-asset", "USDT", "Asset every cycle starts and ends in")
+	feeBps := fs.Float64("fee-bps", 10, "Trading fee per leg in basis points")
+	interval := fs.String("interval", "5s", "Time between scans (e.g., 5s, 1m)")
+	once := fs.Bool("once", false, "Scan once and exit")
+	execute := fs.Bool("execute", false, "Trade cycles whose edge after fees exceeds -min-edge-bps")
+	minEdgeBps := fs.Float64("min-edge-bps", 20, "Smallest edge after fees, in basis points, that is traded with -execute")
+	maxNotional := fs.Float64("max-notional", 0, "Amount of the start asset traded per cycle, required with -execute")
+	maxTotalNotional := fs.Float64("max-total-notional", 0, "Total amount of the start asset traded before the scanner stops executing, required with -execute")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+
+	start := strings.ToUpper(*startAsset)
+	if *triangles == "" {
+		return fmt.Errorf("at least one triangle is required")
+	}
+	var cycles []arbCycle
+	for _, spec := range strings.Split(*triangles, ";") {
+		pair, err := parseTriangle(spec, start)
+		if err != nil {
+			return err
+		}
+		cycles = append(cycles, pair[:]...)
+	}
+	scanInterval, err := parseDuration(*interval)
+	if err != nil {
+		return fmt.Errorf("error parsing interval: %v", err)
+	}
+	if *execute && (*maxNotional <= 0 || *maxTotalNotional < *maxNotional) {
+		return fmt.Errorf("-execute requires a positive -max-notional and a -max-total-notional of at least as much")
+	}
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	notional := NewDecimalFromFloat(*maxNotional)
+	var traded Decimal
+	for {
+		tickers := map[string]*BookTicker{}
+		for _, cycle := range cycles {
+			for _, leg := range cycle.Legs {
+				if tickers[leg.Symbol] != nil {
+					continue
+				}
+				if tickers[leg.Symbol], err = client.GetBookTicker(leg.Symbol); err != nil {
+					log.Printf("Error getting book ticker for %s: %v", leg.Symbol, err)
+				}
+			}
+		}
+
+		for _, cycle := range cycles {
+			edge, ok := cycle.edgeBps(tickers, *feeBps)
+			if !ok {
+				continue
+			}
+			log.Printf("%s: %+.2f bps after fees", cycle, edge)
+			if !*execute || edge < *minEdgeBps {
+				continue
+			}
+			if traded.Add(notional).GreaterThan(NewDecimalFromFloat(*maxTotalNotional)) {
+				log.Printf("Total notional limit of %g %s reached. Not executing %s.", *maxTotalNotional, start, cycle)
+				continue
+			}
+			log.Printf("Executing %s with %s %s at an edge of %+.2f bps", cycle, notional, start, edge)
+			traded = traded.Add(notional)
+			received, err := cycle.execute(client, notional)
+			if err != nil {
+				return fmt.Errorf("arbitrage cycle %s failed: %v", cycle, err)
+			}
+			log.Printf("Cycle %s returned %s %s for %s %s (%+.2f bps)", cycle, received, start, notional, start,
+				(received.Float64()/notional.Float64()-1)*10000)
+			// The book has moved after trading, so the other cycles wait for the next scan
+			break
+		}
+
+		if *once || !sleepContext(ctx, scanInterval) {
+			return nil
+		}
+	}
+}
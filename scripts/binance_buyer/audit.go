@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Audit log events, one per decision the scheduler takes
+const (
+	AuditPlanned     = "planned"
+	AuditResumed     = "resumed"
+	AuditPlaced      = "placed"
+	AuditFilled      = "filled"
+	AuditUnfilled    = "unfilled"
+	AuditSkipped     = "skipped"
+	AuditReplanned   = "replanned"
+	AuditError       = "error"
+	AuditStopped     = "stopped"
+	AuditKilled      = "killed"
+	AuditInterrupted = "interrupted"
+	AuditCompleted   = "completed"
+	AuditExit        = "exit"
+	AuditWithdrawal  = "withdrawal"
+	AuditTransferred = "transferred"
+	// AuditBudgetChanged is a change of the remaining budget made through the control server
+	AuditBudgetChanged = "budget_changed"
+	// AuditCaughtUp is the catch-up of a resumed run for the slices it missed while not running
+	AuditCaughtUp = "caught_up"
+	// AuditWindowClosed is a run held back until its trading window opens
+	AuditWindowClosed = "window_closed"
+	// AuditBlackout is a run held back until a period of its blackout calendar ends
+	AuditBlackout = "blackout"
+)
+
+// AuditLog appends every decision of a run as a JSON line to a file for post-mortem analysis. Each line
+// carries the time, the event as "msg", the run ID and the event's fields.
+type AuditLog struct {
+	file   *os.File
+	logger *slog.Logger
+}
+
+// OpenAuditLog opens the audit log at path for appending
+func OpenAuditLog(path string) (*AuditLog, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log: %v", err)
+	}
+	return &AuditLog{file: file, logger: slog.New(slog.NewJSONHandler(file, nil))}, nil
+}
+
+// Record appends an event of a run with key-value fields as in slog. A nil audit log records nothing.
+func (a *AuditLog) Record(runID, event string, args ...any) {
+	if a == nil {
+		return
+	}
+	a.logger.Info(event, append([]any{"run_id", runID}, args...)...)
+}
+
+// Close closes the audit log file. A nil audit log is a no-op.
+func (a *AuditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.file.Close()
+}
+
+// audit records an event of the run in its audit log and sends it to the notifier and the control server's
+// event stream, if they are configured
+func (s *RunState) audit(event string, args ...any) {
+	s.auditLog.Record(s.RunID, event, args...)
+	notification := notifyEvent{Time: time.Now(), RunID: s.RunID, Symbol: s.Config.Symbol, Side: s.Config.Side, Event: event, Args: args}
+	s.notifier.Notify(notification)
+	s.control.publish(notification)
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// BacktestConfig holds the period and account of a backtest
+type BacktestConfig struct {
+	// Start and End bound the candles replayed, as dates (YYYY-MM-DD) or RFC 3339 timestamps. End defaults to
+	// now.
+	Start string `json:"start"`
+	End   string `json:"end,omitempty"`
+	// InitialQuote is the starting quote balance, all of which each entry buys with unless the position limits
+	// allow less
+	InitialQuote float64 `json:"initial_quote"`
+	// FeeBps is the trading fee paid on every fill, in basis points, for exchanges without a fill model
+	FeeBps float64 `json:"fee_bps"`
+	// Exchange selects the fill model, the -exchange flag by default
+	Exchange string `json:"exchange,omitempty"`
+	// Fills are the fill models of exchanges, by name
+	Fills map[string]FillModel `json:"fills,omitempty"`
+	// DataDir reads the candles from a directory filled by trade data download instead of the exchange
+	DataDir string `json:"data_dir,omitempty"`
+	// Sweep lists the values -optimize tries for parameters of the strategy's section, e.g. {"lookback": [10,
+	// 20, 30]}, backtesting every combination
+	Sweep map[string][]float64 `json:"sweep,omitempty"`
+	// Objective ranks the combinations: return (default) or return_drawdown
+	Objective string `json:"objective,omitempty"`
+	// WalkForward optimizes on rolling training windows and tests out of sample instead of ranking the
+	// combinations over the whole period
+	WalkForward *WalkForwardConfig `json:"walk_forward,omitempty"`
+}
+
+// BacktestTrade is a position opened and closed during a backtest
+type BacktestTrade struct {
+	EntryTime  time.Time
+	EntryPrice float64
+	ExitTime   time.Time
+	ExitPrice  float64
+	Quantity   float64
+	// PnL is the quote gained net of fees
+	PnL    float64
+	Reason string
+}
+
+// BacktestResult summarizes a backtest
+type BacktestResult struct {
+	Trades         []BacktestTrade
+	InitialQuote   float64
+	FinalEquity    float64
+	ReturnPct      float64
+	BuyHoldPct     float64
+	MaxDrawdownPct float64
+	// OpenQuantity is the base still held at the end, valued at the last close in FinalEquity
+	OpenQuantity float64
+	// Fees and Slippage are the quote paid in fees and lost to slippage from the open
+	Fees     float64
+	Slippage float64
+	// ExpiredOrders counts the limit orders cancelled before they fully filled
+	ExpiredOrders int
+	// Equity is the equity at the close of every candle, after the initial quote before the first
+	Equity []EquityPoint
+}
+
+// metrics computes the performance metrics of the backtest
+func (r BacktestResult) metrics(source string) PerformanceMetrics {
+	pnls := make([]float64, len(r.Trades))
+	for i, trade := range r.Trades {
+		pnls[i] = trade.PnL
+	}
+	return computeMetrics(source, r.Equity, pnls, r.Fees)
+}
+
+// markers marks the entries and exits of the backtest's trades at their average fill prices
+func (r BacktestResult) markers() []chartMarker {
+	var markers []chartMarker
+	for _, trade := range r.Trades {
+		markers = append(markers,
+			chartMarker{Time: trade.EntryTime, Value: trade.EntryPrice, Color: "#2ca02c",
+				Label: fmt.Sprintf("%s entry %.8g at %.8g", trade.EntryTime.UTC().Format(time.DateTime), trade.Quantity, trade.EntryPrice)},
+			chartMarker{Time: trade.ExitTime, Value: trade.ExitPrice, Color: "#d62728",
+				Label: fmt.Sprintf("%s exit at %.8g (%s), PnL %+.2f", trade.ExitTime.UTC().Format(time.DateTime), trade.ExitPrice, trade.Reason, trade.PnL)})
+	}
+	return markers
+}
+
+// WinRate returns the percentage of closed trades with a positive PnL
+func (r BacktestResult) WinRate() float64 {
+	if len(r.Trades) == 0 {
+		return 0
+	}
+	wins := 0
+	for _, trade := range r.Trades {
+		if trade.PnL > 0 {
+			wins++
+		}
+	}
+	return float64(wins) / float64(len(r.Trades)) * 100
+}
+
+// restingOrder is a limit order of a backtest waiting to fill
+type restingOrder struct {
+	Side string
+	// Amount is the quote left to spend when buying and the base left to sell when selling
+	Amount float64
+	Price  float64
+	// Age is the number of candles the order has rested
+	Age int
+}
+
+// backtest replays candles through a strategy. Signals on a candle are acted on from the next candle's open,
+// so the strategy never trades on a price it could not have seen. The fill model of the backtest's exchange
+// decides how: market orders fill at the open with slippage, limit orders rest at a price and may fill in
+// parts, and every fill pays the fee of its tier. Entries are capped by the position limits.
+func backtest(strategy Strategy, candles []Candle, bt BacktestConfig, limit PositionLimit) BacktestResult {
+	initialQuote := bt.InitialQuote
+	result := BacktestResult{InitialQuote: initialQuote}
+	if len(candles) == 0 {
+		result.FinalEquity = initialQuote
+		return result
+	}
+	model, _ := bt.fillModel()
+	fills := newFillSimulator(model)
+	quote, quantity, peak := initialQuote, 0.0, initialQuote
+	var open BacktestTrade
+	var entryNotional, exitNotional, exitBase float64
+	var resting *restingOrder
+	var pending Signal
+
+	buy := func(c Candle, spend, price float64, maker bool) {
+		fee := fills.feeRate(c.OpenTime, maker)
+		base := spend * (1 - fee) / price
+		if open.EntryTime.IsZero() {
+			open.EntryTime = c.OpenTime
+		}
+		quantity += base
+		open.Quantity += base
+		open.PnL -= spend
+		entryNotional += base * price
+		open.EntryPrice = entryNotional / open.Quantity
+		result.Fees += spend * fee
+		if !maker {
+			result.Slippage += base * (price - c.Open)
+		}
+		fills.record(c.OpenTime, spend)
+	}
+	sell := func(c Candle, base, price float64, maker bool) {
+		gross := base * price
+		fee := gross * fills.feeRate(c.OpenTime, maker)
+		quote += gross - fee
+		if quantity -= base; quantity <= open.Quantity*1e-9 {
+			// Rounding must not leave a position open after its last part sold
+			quantity = 0
+		}
+		open.PnL += gross - fee
+		exitNotional += gross
+		exitBase += base
+		result.Fees += fee
+		if !maker {
+			result.Slippage += base * (c.Open - price)
+		}
+		fills.record(c.OpenTime, gross)
+		if quantity == 0 {
+			open.ExitTime, open.ExitPrice = c.OpenTime, exitNotional/exitBase
+			result.Trades = append(result.Trades, open)
+			open, entryNotional, exitNotional, exitBase = BacktestTrade{}, 0, 0, 0
+		}
+	}
+
+	prevClose := candles[0].Open
+	result.Equity = append(result.Equity, EquityPoint{Time: candles[0].OpenTime, Equity: initialQuote, Price: candles[0].Open})
+	for _, c := range candles {
+		switch pending.Action {
+		case ActionEnter:
+			spend := quote
+			if pending.Amount.Sign() > 0 {
+				spend = math.Min(spend, pending.Amount.Float64())
+			}
+			if limit.Enabled() {
+				spend = math.Min(spend, limit.maxBase(NewDecimalFromFloat(c.Open)).Float64()*c.Open)
+			}
+			quote -= spend
+			if fills.limit() {
+				resting = &restingOrder{Side: "BUY", Amount: spend, Price: fills.limitPrice("BUY", prevClose)}
+			} else {
+				buy(c, spend, fills.marketPrice("BUY", c, spend), false)
+			}
+		case ActionExit:
+			if resting != nil {
+				// An exit cancels the rest of the entry
+				quote += resting.Amount
+				resting = nil
+			}
+			open.Reason = pending.Reason
+			if fills.limit() {
+				resting = &restingOrder{Side: "SELL", Amount: quantity, Price: fills.limitPrice("SELL", prevClose)}
+			} else {
+				sell(c, quantity, fills.marketPrice("SELL", c, quantity*c.Open), false)
+			}
+		}
+		if resting != nil {
+			if share := fills.limitFill(resting.Side, resting.Price, c); share > 0 {
+				amount := resting.Amount
+				if share < 1 {
+					amount *= share
+				}
+				resting.Amount -= amount
+				if resting.Side == "BUY" {
+					buy(c, amount, resting.Price, true)
+				} else {
+					sell(c, amount, resting.Price, true)
+				}
+			}
+			resting.Age++
+			if resting.Amount == 0 || resting.Age >= fills.expiry() {
+				if resting.Amount > 0 {
+					result.ExpiredOrders++
+					if resting.Side == "BUY" {
+						quote += resting.Amount
+					}
+				}
+				resting = nil
+			}
+		}
+
+		pending = strategy.OnCandle(c, quantity > 0)
+		if pending.Action == ActionEnter && (quantity > 0 || resting != nil) || pending.Action == ActionExit && (quantity == 0 || resting != nil && resting.Side == "SELL") {
+			pending = Signal{}
+		}
+
+		equity := quote + quantity*c.Close
+		if resting != nil && resting.Side == "BUY" {
+			equity += resting.Amount
+		}
+		result.Equity = append(result.Equity, EquityPoint{Time: c.CloseTime, Equity: equity, InPosition: quantity > 0, Price: c.Close,
+			Position: quantity})
+		peak = math.Max(peak, equity)
+		result.MaxDrawdownPct = math.Max(result.MaxDrawdownPct, (peak-equity)/peak*100)
+		prevClose = c.Close
+	}
+
+	last := candles[len(candles)-1]
+	if resting != nil && resting.Side == "BUY" {
+		quote += resting.Amount
+	}
+	result.OpenQuantity = quantity
+	result.FinalEquity = quote + quantity*last.Close
+	result.ReturnPct = (result.FinalEquity/initialQuote - 1) * 100
+	result.BuyHoldPct = (last.Close/candles[0].Open - 1) * 100
+	return result
+}
+
+// backtestPeriod returns the validated period of the config's backtest
+func backtestPeriod(bt BacktestConfig) (time.Time, time.Time, error) {
+	start, err := parseDate(bt.Start)
+	if err != nil {
+		return start, start, fmt.Errorf("invalid backtest start: %v", err)
+	}
+	end := time.Now()
+	if bt.End != "" {
+		if end, err = parseDate(bt.End); err != nil {
+			return start, end, fmt.Errorf("invalid backtest end: %v", err)
+		}
+	}
+	if !start.Before(end) {
+		return start, end, fmt.Errorf("backtest start must be before its end")
+	}
+	if bt.InitialQuote <= 0 {
+		return start, end, fmt.Errorf("backtest initial_quote must be positive")
+	}
+	if _, err := bt.fillModel(); err != nil {
+		return start, end, fmt.Errorf("invalid backtest fill model: %v", err)
+	}
+	return start, end, nil
+}
+
+// backtestCandles returns the closed candles of the backtest period preceded by warmup candles, from the data
+// directory of the backtest when it has one or else from the exchange
+func backtestCandles(client ExchangeClient, cfg *StrategyConfig, start, end time.Time, warmup int) ([]Candle, error) {
+	warmupStart := start.Add(-time.Duration(warmup) * klineDurations[cfg.Interval])
+	var candles []Candle
+	var err error
+	if cfg.Backtest.DataDir != "" {
+		candles, err = loadStoredCandles(cfg.Backtest.DataDir, cfg.Symbol, cfg.Interval, warmupStart, end)
+	} else {
+		candles, err = client.GetKlines(cfg.Symbol, cfg.Interval, warmupStart, end)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s klines: %v", cfg.Symbol, err)
+	}
+	for len(candles) > 0 && candles[len(candles)-1].CloseTime.After(time.Now()) {
+		candles = candles[:len(candles)-1]
+	}
+	return candles, nil
+}
+
+// backtestWindow warms the strategy up on the candles opened before start and backtests it on the candles
+// opened between start and end, returning the result and the candles replayed
+func backtestWindow(strategy Strategy, candles []Candle, start, end time.Time, bt BacktestConfig, limit PositionLimit) (BacktestResult, []Candle) {
+	var history, replay []Candle
+	for _, c := range candles {
+		if c.OpenTime.Before(start) {
+			history = append(history, c)
+		} else if c.OpenTime.Before(end) {
+			replay = append(replay, c)
+		}
+	}
+	for _, c := range history[max(0, len(history)-strategy.Warmup()):] {
+		strategy.OnCandle(c, false)
+	}
+	return backtest(strategy, replay, bt, limit), replay
+}
+
+// runBacktest fetches the candles of the config's backtest period, replays them through the strategy after
+// warming it up on the candles before the period, and prints the trades and a summary, writing its metrics to
+// metricsOut when set
+func runBacktest(client ExchangeClient, cfg *StrategyConfig, strategy Strategy, metricsOut string) error {
+	bt := cfg.Backtest
+	start, end, err := backtestPeriod(bt)
+	if err != nil {
+		return err
+	}
+	candles, err := backtestCandles(client, cfg, start, end, strategy.Warmup())
+	if err != nil {
+		return err
+	}
+	result, replay := backtestWindow(strategy, candles, start, end, bt, cfg.Position)
+	if len(replay) == 0 {
+		return fmt.Errorf("no %s %s candles between %s and %s", cfg.Symbol, cfg.Interval, start.Format(time.DateOnly), end.Format(time.DateOnly))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ENTRY\tENTRY PRICE\tEXIT\tEXIT PRICE\tQUANTITY\tPNL\tEXIT REASON")
+	for _, t := range result.Trades {
+		fmt.Fprintf(w, "%s\t%.8g\t%s\t%.8g\t%.8g\t%.2f\t%s\n", t.EntryTime.Format(time.DateTime), t.EntryPrice,
+			t.ExitTime.Format(time.DateTime), t.ExitPrice, t.Quantity, t.PnL, t.Reason)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%s on %s %s candles, %s to %s (%d candles)\n", cfg.Strategy, cfg.Symbol, cfg.Interval,
+		replay[0].OpenTime.Format(time.DateOnly), replay[len(replay)-1].CloseTime.Format(time.DateOnly), len(replay))
+	fmt.Printf("Trades: %d (win rate %.1f%%)\n", len(result.Trades), result.WinRate())
+	if result.OpenQuantity > 0 {
+		fmt.Printf("Open position: %.8g, valued at the last close\n", result.OpenQuantity)
+	}
+	fmt.Printf("Equity: %.2f -> %.2f (%+.2f%%, buy and hold %+.2f%%)\n", result.InitialQuote, result.FinalEquity, result.ReturnPct, result.BuyHoldPct)
+	fmt.Printf("Max drawdown: %.2f%%\n", result.MaxDrawdownPct)
+	fmt.Printf("Costs: %.2f fees, %.2f slippage", result.Fees, result.Slippage)
+	if result.ExpiredOrders > 0 {
+		fmt.Printf(", %d limit order(s) expired before filling", result.ExpiredOrders)
+	}
+	fmt.Println()
+	metrics := result.metrics(fmt.Sprintf("Backtest %s %s %s", cfg.Strategy, cfg.Symbol, cfg.Interval))
+	fmt.Printf("Sharpe %.2f, Sortino %.2f, exposure %.1f%%, average trade %+.2f\n", metrics.SharpeRatio, metrics.SortinoRatio,
+		metrics.ExposurePct, metrics.AvgTradePnL)
+	return writeMetrics(metricsOut, metrics, result.Equity, result.markers())
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// priceBandPollInterval is how often the price is rechecked while execution is paused outside the band
+const priceBandPollInterval = 5 * time.Second
+
+// PriceBand restricts execution to market prices within [Min, Max], where a zero bound is disabled
+type PriceBand struct {
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Pause bool    `json:"pause"`
+}
+
+// Enabled reports whether at least one bound is set
+func (b PriceBand) Enabled() bool {
+	return b.Min > 0 || b.Max > 0
+}
+
+// Contains reports whether price lies within the band
+func (b PriceBand) Contains(price float64) bool {
+	return (b.Min <= 0 || price >= b.Min) && (b.Max <= 0 || price <= b.Max)
+}
+
+// checkPriceBand rechecks the ticker and reports whether a slice may be executed. When the band is
+// configured to pause, it blocks until the price returns inside the band or ctx is cancelled.
+func checkPriceBand(ctx context.Context, client ExchangeClient, symbol string, band PriceBand) bool {
+	if !band.Enabled() {
+		return true
+	}
+
+	paused := false
+	for {
+		price, err := client.GetPrice(symbol)
+		if err != nil {
+			log.Printf("Error checking price band: %v", err)
+			return false
+		}
+		if band.Contains(price) {
+			if paused {
+				log.Printf("Price %.8f is back within band [%g, %g]. Resuming.", price, band.Min, band.Max)
+			}
+			return true
+		}
+		if !band.Pause {
+			log.Printf("Price %.8f is outside band [%g, %g]. Skipping slice.", price, band.Min, band.Max)
+			return false
+		}
+		if !paused {
+			log.Printf("Price %.8f is outside band [%g, %g]. Pausing until it returns.", price, band.Min, band.Max)
+			paused = true
+		}
+		if !sleepContext(ctx, priceBandPollInterval) {
+			return false
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+// MarketBenchmark accumulates the market's average prices from the trade stream over the window of a run
+type MarketBenchmark struct {
+	Trades int `json:"trades"`
+	// Volume and Notional are the base and quote traded by the market
+	Volume   float64 `json:"volume"`
+	Notional float64 `json:"notional"`
+	// PriceTime sums each traded price multiplied by the seconds it stayed the last price, over Seconds
+	PriceTime float64 `json:"price_time"`
+	Seconds   float64 `json:"seconds"`
+}
+
+// add returns the sum of two benchmarks, e.g. of a run before and after it was resumed
+func (b MarketBenchmark) add(other MarketBenchmark) MarketBenchmark {
+	return MarketBenchmark{
+		Trades:    b.Trades + other.Trades,
+		Volume:    b.Volume + other.Volume,
+		Notional:  b.Notional + other.Notional,
+		PriceTime: b.PriceTime + other.PriceTime,
+		Seconds:   b.Seconds + other.Seconds,
+	}
+}
+
+// VWAP returns the market's volume-weighted average price, or 0 before any trade
+func (b MarketBenchmark) VWAP() float64 {
+	if b.Volume == 0 {
+		return 0
+	}
+	return b.Notional / b.Volume
+}
+
+// TWAP returns the market's time-weighted average price, or 0 before any trade
+func (b MarketBenchmark) TWAP() float64 {
+	if b.Seconds == 0 {
+		return 0
+	}
+	return b.PriceTime / b.Seconds
+}
+
+// trackBenchmark starts adding the market trades streamed for the run's symbol to its benchmark. Runs whose
+// prices are not streamed keep benchmarking against the prices sampled at each slice.
+func (s *RunState) trackBenchmark(client ExchangeClient) {
+	s.benchmarkFeed = marketFeed(client, s.Config.Symbol)
+	s.benchmarkBase = s.Benchmark
+}
+
+// refreshBenchmark adds the trades streamed so far to the run's benchmark
+func (s *RunState) refreshBenchmark() {
+	if s.benchmarkFeed != nil {
+		s.Benchmark = s.benchmarkBase.add(s.benchmarkFeed.Benchmark())
+	}
+}
+
+// liveBenchmark returns a function computing the run's benchmark up to the moment it is called, safe to call
+// from other goroutines, or nil when the run's trades are not streamed
+func (s *RunState) liveBenchmark() func() MarketBenchmark {
+	feed, base := s.benchmarkFeed, s.benchmarkBase
+	if feed == nil {
+		return nil
+	}
+	return func() MarketBenchmark {
+		return base.add(feed.Benchmark())
+	}
+}
+
+// marketTWAP returns the market's time-weighted average price over the run, from the trade stream when it
+// was streamed or else from the prices sampled at each slice
+func (s *RunState) marketTWAP() float64 {
+	if twap := s.Benchmark.TWAP(); twap > 0 {
+		return twap
+	}
+	if s.PriceSamples > 0 {
+		return s.PriceSum / float64(s.PriceSamples)
+	}
+	return 0
+}
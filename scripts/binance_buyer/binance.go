@@ -0,0 +1,938 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Binance REST and WebSocket endpoints for the live exchange and the spot testnet
+const (
+	binanceBaseURL          = "https://api.binance.com"
+	binanceWSBaseURL        = "wss://stream.binance.com:9443"
+	binanceTestnetBaseURL   = "https://testnet.binance.vision"
+	binanceTestnetWSBaseURL = "wss://stream.testnet.binance.vision"
+	binanceWSAPIURL         = "wss://ws-api.binance.com:443/ws-api/v3"
+	binanceTestnetWSAPIURL  = "wss://ws-api.testnet.binance.vision/ws-api/v3"
+)
+
+// binanceAlternateBaseURLs are the alternate hosts of the live REST API, failed over to in turn when a host
+// cannot be reached
+var binanceAlternateBaseURLs = []string{
+	"https://api1.binance.com",
+	"https://api2.binance.com",
+	"https://api3.binance.com",
+	"https://api4.binance.com",
+}
+
+// binanceKlineLimit is the maximum number of candles returned by a single klines request
+const binanceKlineLimit = 1000
+
+// binanceTradePageSize is the maximum number of trades returned by a single trade list request
+const binanceTradePageSize = 1000
+
+// BinanceClient represents the Binance API client
+type BinanceClient struct {
+	key       atomic.Pointer[binanceKey]
+	baseURLs  []string
+	activeURL atomic.Int64
+	wsBaseURL string
+	// wsAPIURL is the WebSocket API orders can be placed over, empty for markets it does not trade
+	wsAPIURL    string
+	httpClient  *http.Client
+	retryPolicy RetryPolicy
+	limiter     *WeightLimiter
+	timeOffset  atomic.Int64
+	paths       binancePaths
+}
+
+// binancePaths are the endpoints of the requests the spot and futures APIs share the format of
+type binancePaths struct {
+	time         string
+	price        string
+	bookTicker   string
+	klines       string
+	depth        string
+	exchangeInfo string
+	order        string
+	openOrders   string
+}
+
+// binanceSpotPaths are the endpoints of the spot API
+var binanceSpotPaths = binancePaths{
+	time:         "/api/v3/time",
+	price:        "/api/v3/ticker/price",
+	bookTicker:   "/api/v3/ticker/bookTicker",
+	klines:       "/api/v3/klines",
+	depth:        "/api/v3/depth",
+	exchangeInfo: "/api/v3/exchangeInfo",
+	order:        "/api/v3/order",
+	openOrders:   "/api/v3/openOrders",
+}
+
+// OrderResponse represents the response from Binance order API
+type OrderResponse struct {
+	Symbol        string `json:"symbol"`
+	OrderID       int64  `json:"orderId"`
+	ClientOrderID string `json:"clientOrderId"`
+	TransactTime  int64  `json:"transactTime"`
+	Time          int64  `json:"time"`
+	Price         string `json:"price"`
+	StopPrice     string `json:"stopPrice"`
+	OrigQty       string `json:"origQty"`
+	ExecutedQty   string `json:"executedQty"`
+	CumQuoteQty   string `json:"cummulativeQuoteQty"`
+	CumQuote      string `json:"cumQuote"`
+	UpdateTime    int64  `json:"updateTime"`
+	Status        string `json:"status"`
+	Type          string `json:"type"`
+	Side          string `json:"side"`
+	// Fills are the trades a new order executed on placement, listed in full order responses
+	Fills []struct {
+		Commission      string `json:"commission"`
+		CommissionAsset string `json:"commissionAsset"`
+	} `json:"fills"`
+}
+
+// TickerPrice represents the current price of a symbol
+type TickerPrice struct {
+	Symbol string `json:"symbol"`
+	Price  string `json:"price"`
+}
+
+// AccountInfo represents the account information from Binance
+type AccountInfo struct {
+	Balances []Balance `json:"balances"`
+}
+
+// Balance represents a single balance in the account
+type Balance struct {
+	Asset  string `json:"asset"`
+	Free   string `json:"free"`
+	Locked string `json:"locked"`
+}
+
+// NewBinanceClient creates a new Binance API client signing requests with signer
+func NewBinanceClient(apiKey string, signer Signer) *BinanceClient {
+	client := &BinanceClient{
+		baseURLs:    append([]string{binanceBaseURL}, binanceAlternateBaseURLs...),
+		wsBaseURL:   binanceWSBaseURL,
+		wsAPIURL:    binanceWSAPIURL,
+		httpClient:  newHTTPClient(),
+		retryPolicy: defaultRetryPolicy,
+		limiter:     binanceLimiter,
+		paths:       binanceSpotPaths,
+	}
+	client.key.Store(&binanceKey{apiKey: apiKey, signer: signer})
+	return client
+}
+
+// binanceKey is the API key a client authenticates with and the signer of its secret, replaced together when
+// the key is rotated
+type binanceKey struct {
+	apiKey string
+	signer Signer
+}
+
+// NewBinanceTestnetClient creates a Binance API client for the spot testnet at testnet.binance.vision
+func NewBinanceTestnetClient(apiKey string, signer Signer) *BinanceClient {
+	client := NewBinanceClient(apiKey, signer)
+	client.baseURLs = []string{binanceTestnetBaseURL}
+	client.wsBaseURL = binanceTestnetWSBaseURL
+	client.wsAPIURL = binanceTestnetWSAPIURL
+	client.limiter = binanceTestnetLimiter
+	return client
+}
+
+// SetEndpoints replaces the REST hosts, failed over to in the given order, and the WebSocket base URL.
+// Empty values keep the defaults. REST URLs must be http or https URLs and the WebSocket URL a ws or wss URL.
+func (c *BinanceClient) SetEndpoints(restURLs []string, wsURL string) error {
+	for _, restURL := range restURLs {
+		if err := validateEndpoint(restURL, "http", "https"); err != nil {
+			return err
+		}
+	}
+	if wsURL != "" {
+		if err := validateEndpoint(wsURL, "ws", "wss"); err != nil {
+			return err
+		}
+	}
+	if len(restURLs) > 0 {
+		c.baseURLs = restURLs
+		c.activeURL.Store(0)
+	}
+	if wsURL != "" {
+		c.wsBaseURL = wsURL
+	}
+	return nil
+}
+
+// validateEndpoint checks that rawURL is an absolute URL with a host and one of the given schemes
+func validateEndpoint(rawURL string, schemes ...string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint URL %q: %v", rawURL, err)
+	}
+	if !slices.Contains(schemes, u.Scheme) || u.Host == "" {
+		return fmt.Errorf("invalid endpoint URL %q: want a %s URL with a host", rawURL, strings.Join(schemes, " or "))
+	}
+	return nil
+}
+
+// failover switches requests to the REST host after failed, unless another request already switched away from it
+func (c *BinanceClient) failover(failed int64) {
+	if len(c.baseURLs) < 2 {
+		return
+	}
+	next := (failed + 1) % int64(len(c.baseURLs))
+	if c.activeURL.CompareAndSwap(failed, next) {
+		log.Printf("Cannot reach %s, failing over to %s", c.baseURLs[failed], c.baseURLs[next])
+	}
+}
+
+// SyncTime measures the offset between the Binance server clock and the local clock and
+// applies it to the timestamps of all subsequent signed requests
+func (c *BinanceClient) SyncTime() (time.Duration, error) {
+	start := time.Now()
+	body, err := c.sendPublic(c.paths.time, url.Values{})
+	if err != nil {
+		return 0, err
+	}
+	roundTrip := time.Since(start)
+
+	var serverTime struct {
+		ServerTime int64 `json:"serverTime"`
+	}
+	if err := json.Unmarshal(body, &serverTime); err != nil {
+		return 0, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	offset := time.UnixMilli(serverTime.ServerTime).Sub(start.Add(roundTrip / 2))
+	c.timeOffset.Store(int64(offset))
+	return offset, nil
+}
+
+// serverTimestamp returns the current time in milliseconds adjusted by the measured server time offset
+func (c *BinanceClient) serverTimestamp() string {
+	return strconv.FormatInt(time.Now().Add(time.Duration(c.timeOffset.Load())).UnixMilli(), 10)
+}
+
+// binanceAuth is the authentication a Binance endpoint requires
+type binanceAuth int
+
+const (
+	// binanceAuthNone is for public market data endpoints
+	binanceAuthNone binanceAuth = iota
+	// binanceAuthAPIKey sends the API key header without a signature, as the user data stream endpoints require
+	binanceAuthAPIKey
+	// binanceAuthSigned sends the API key header and signs the parameters with a timestamp
+	binanceAuthSigned
+)
+
+// sendSigned signs the given parameters, sends the request and returns the response body
+func (c *BinanceClient) sendSigned(method, endpoint string, params url.Values) ([]byte, error) {
+	return c.send(method, endpoint, params, binanceAuthSigned)
+}
+
+// sendPublic sends an unsigned GET request and returns the response body
+func (c *BinanceClient) sendPublic(endpoint string, params url.Values) ([]byte, error) {
+	return c.send("GET", endpoint, params, binanceAuthNone)
+}
+
+// sendWithAPIKey sends a request authenticated by the API key header only, without a signature
+func (c *BinanceClient) sendWithAPIKey(method, endpoint string, params url.Values) ([]byte, error) {
+	return c.send(method, endpoint, params, binanceAuthAPIKey)
+}
+
+// send sends a request with retries and returns the body of a successful response, or the Binance error
+func (c *BinanceClient) send(method, endpoint string, params url.Values, auth binanceAuth) ([]byte, error) {
+	return c.resendOnTimestampError(func() (*httpResult, error) {
+		return doWithRetry(c.retryPolicy, func() (*httpResult, error) {
+			return c.sendOnce(method, endpoint, params, auth)
+		}, isBinanceRetryable)
+	})
+}
+
+// resendOnTimestampError runs send, running it once more after resyncing the clock when Binance rejected
+// the request's timestamp, and returns the body of a successful response
+func (c *BinanceClient) resendOnTimestampError(send func() (*httpResult, error)) ([]byte, error) {
+	res, err := send()
+	if err == nil && res.StatusCode != http.StatusOK && newBinanceAPIError(res).Code == binanceTimestampErrorCode {
+		if offset, syncErr := c.SyncTime(); syncErr == nil {
+			log.Printf("Timestamp rejected by Binance, resynced server time offset to %s and retrying", offset)
+			res, err = send()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, newBinanceAPIError(res)
+	}
+
+	return res.Body, nil
+}
+
+// sendOnce builds and sends a single attempt of a request. Signed requests get a fresh timestamp and
+// signature, so every attempt must go through here.
+func (c *BinanceClient) sendOnce(method, endpoint string, params url.Values, auth binanceAuth) (*httpResult, error) {
+	if auth == binanceAuthSigned {
+		params.Del("signature")
+		params.Set("timestamp", c.serverTimestamp())
+		params.Set("recvWindow", "5000")
+		signature, err := c.key.Load().signer.Sign(params.Encode())
+		if err != nil {
+			return nil, err
+		}
+		params.Set("signature", signature)
+	}
+
+	active := c.activeURL.Load()
+	req, err := http.NewRequest(method, c.baseURLs[active]+endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	if auth != binanceAuthNone {
+		req.Header.Set("X-MBX-APIKEY", c.key.Load().apiKey)
+	}
+	req.URL.RawQuery = params.Encode()
+
+	res, err := c.do(req, endpointWeight(method, endpoint))
+	if err != nil {
+		c.failover(active)
+	}
+	return res, err
+}
+
+// do waits for the request weight to be available, sends the request and records the used weight
+func (c *BinanceClient) do(req *http.Request, weight int) (*httpResult, error) {
+	c.limiter.Acquire(weight)
+	res, err := readResult(c.httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	c.limiter.Update(res)
+	return res, nil
+}
+
+// isBinanceRetryable reports whether a Binance response is a transient failure worth retrying
+func isBinanceRetryable(res *httpResult) bool {
+	return res.StatusCode != http.StatusOK && newBinanceAPIError(res).Retryable()
+}
+
+// GetAccountInfo gets the account information including balances
+func (c *BinanceClient) GetAccountInfo() (*AccountInfo, error) {
+	body, err := c.sendSigned("GET", "/api/v3/account", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var accountInfo AccountInfo
+	if err := json.Unmarshal(body, &accountInfo); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	return &accountInfo, nil
+}
+
+// GetBalance gets the free balance for a given asset symbol (e.g., USDT, BTC, ETH)
+func (c *BinanceClient) GetBalance(asset string) (Decimal, error) {
+	accountInfo, err := c.GetAccountInfo()
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	for _, balance := range accountInfo.Balances {
+		if balance.Asset == asset {
+			free, err := ParseDecimal(balance.Free)
+			if err != nil {
+				return Decimal{}, fmt.Errorf("error parsing %s balance: %v", asset, err)
+			}
+			return free, nil
+		}
+	}
+
+	return Decimal{}, fmt.Errorf("%s balance not found", asset)
+}
+
+// GetBalances gets every balance of the account that is not empty, free or locked
+func (c *BinanceClient) GetBalances() ([]Balance, error) {
+	accountInfo, err := c.GetAccountInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	var balances []Balance
+	for _, balance := range accountInfo.Balances {
+		if decimalOrZero(balance.Free).Sign() > 0 || decimalOrZero(balance.Locked).Sign() > 0 {
+			balances = append(balances, balance)
+		}
+	}
+	return balances, nil
+}
+
+// CreateListenKey starts a user data stream and returns its listen key
+func (c *BinanceClient) CreateListenKey() (string, error) {
+	body, err := c.sendWithAPIKey("POST", "/api/v3/userDataStream", url.Values{})
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		ListenKey string `json:"listenKey"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("error parsing response: %v", err)
+	}
+
+	return resp.ListenKey, nil
+}
+
+// KeepAliveListenKey extends the validity of a listen key by 60 minutes
+func (c *BinanceClient) KeepAliveListenKey(listenKey string) error {
+	params := url.Values{}
+	params.Set("listenKey", listenKey)
+
+	_, err := c.sendWithAPIKey("PUT", "/api/v3/userDataStream", params)
+	return err
+}
+
+// CloseListenKey closes a user data stream
+func (c *BinanceClient) CloseListenKey(listenKey string) error {
+	params := url.Values{}
+	params.Set("listenKey", listenKey)
+
+	_, err := c.sendWithAPIKey("DELETE", "/api/v3/userDataStream", params)
+	return err
+}
+
+// GetPrice gets the current price of a symbol
+func (c *BinanceClient) GetPrice(symbol string) (float64, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	body, err := c.sendPublic(c.paths.price, params)
+	if err != nil {
+		return 0, err
+	}
+
+	var tickerPrice TickerPrice
+	if err := json.Unmarshal(body, &tickerPrice); err != nil {
+		return 0, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	price, err := strconv.ParseFloat(tickerPrice.Price, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing price: %v", err)
+	}
+
+	return price, nil
+}
+
+// GetBookTicker gets the best bid and ask of a symbol
+func (c *BinanceClient) GetBookTicker(symbol string) (*BookTicker, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	body, err := c.sendPublic(c.paths.bookTicker, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var ticker struct {
+		Symbol   string `json:"symbol"`
+		BidPrice string `json:"bidPrice"`
+		AskPrice string `json:"askPrice"`
+	}
+	if err := json.Unmarshal(body, &ticker); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	bid, err := strconv.ParseFloat(ticker.BidPrice, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing bid price: %v", err)
+	}
+	ask, err := strconv.ParseFloat(ticker.AskPrice, 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ask price: %v", err)
+	}
+
+	return &BookTicker{Symbol: ticker.Symbol, BidPrice: bid, AskPrice: ask}, nil
+}
+
+// GetKlines fetches the candles of a symbol for the given interval (e.g., 1m, 1h, 1d) opened between start
+// and end, paging through the results since Binance returns at most binanceKlineLimit candles per request
+func (c *BinanceClient) GetKlines(symbol, interval string, start, end time.Time) ([]Candle, error) {
+	var candles []Candle
+	for !start.After(end) {
+		page, err := c.getKlinePage(symbol, interval, start, end)
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, page...)
+		if len(page) < binanceKlineLimit {
+			break
+		}
+		start = page[len(page)-1].OpenTime.Add(time.Millisecond)
+	}
+	return candles, nil
+}
+
+// getKlinePage fetches up to binanceKlineLimit candles opened between start and end
+func (c *BinanceClient) getKlinePage(symbol, interval string, start, end time.Time) ([]Candle, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("interval", interval)
+	params.Set("startTime", strconv.FormatInt(start.UnixMilli(), 10))
+	params.Set("endTime", strconv.FormatInt(end.UnixMilli(), 10))
+	params.Set("limit", strconv.Itoa(binanceKlineLimit))
+
+	body, err := c.sendPublic(c.paths.klines, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows [][]any
+	if err := json.Unmarshal(body, &rows); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	candles := make([]Candle, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 9 {
+			return nil, fmt.Errorf("unexpected kline format: %v", row)
+		}
+		openTime, _ := row[0].(float64)
+		closeTime, _ := row[6].(float64)
+		trades, _ := row[8].(float64)
+		candles = append(candles, Candle{
+			OpenTime:    time.UnixMilli(int64(openTime)),
+			CloseTime:   time.UnixMilli(int64(closeTime)),
+			Open:        parseAnyFloat(row[1]),
+			High:        parseAnyFloat(row[2]),
+			Low:         parseAnyFloat(row[3]),
+			Close:       parseAnyFloat(row[4]),
+			Volume:      parseAnyFloat(row[5]),
+			QuoteVolume: parseAnyFloat(row[7]),
+			Trades:      int(trades),
+		})
+	}
+
+	return candles, nil
+}
+
+// binanceDepthLimits are the order book depths the depth endpoint accepts, up to maxDepthLevels
+var binanceDepthLimits = []int{5, 10, 20, 50, 100}
+
+// GetOrderBook fetches the best levels of a symbol's order book
+func (c *BinanceClient) GetOrderBook(symbol string, levels int) (*OrderBook, error) {
+	limit := binanceDepthLimits[len(binanceDepthLimits)-1]
+	for _, l := range binanceDepthLimits {
+		if l >= levels {
+			limit = l
+			break
+		}
+	}
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("limit", strconv.Itoa(limit))
+
+	body, err := c.sendPublic(c.paths.depth, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var depth struct {
+		Bids [][]any `json:"bids"`
+		Asks [][]any `json:"asks"`
+	}
+	if err := json.Unmarshal(body, &depth); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	return &OrderBook{Bids: parseBookLevels(depth.Bids), Asks: parseBookLevels(depth.Asks)}, nil
+}
+
+// parseBookLevels converts [price, quantity, ...] rows of an order book response into levels
+func parseBookLevels(rows [][]any) []BookLevel {
+	levels := make([]BookLevel, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 2 {
+			continue
+		}
+		levels = append(levels, BookLevel{Price: parseAnyFloat(row[0]), Qty: parseAnyFloat(row[1])})
+	}
+	return levels
+}
+
+// parseAnyFloat converts a JSON string or number into a float64, returning 0 for anything else
+func parseAnyFloat(value any) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case string:
+		f, _ := strconv.ParseFloat(v, 64)
+		return f
+	}
+	return 0
+}
+
+// exchangeInfoFilter represents a single entry of a symbol's filter list in exchangeInfo
+type exchangeInfoFilter struct {
+	FilterType  string `json:"filterType"`
+	TickSize    string `json:"tickSize"`
+	StepSize    string `json:"stepSize"`
+	MinQty      string `json:"minQty"`
+	MaxQty      string `json:"maxQty"`
+	MinNotional string `json:"minNotional"`
+	Notional    string `json:"notional"`
+}
+
+// GetSymbolFilters fetches the PRICE_FILTER, LOT_SIZE and (MIN_)NOTIONAL filters of a symbol from exchangeInfo
+func (c *BinanceClient) GetSymbolFilters(symbol string) (*SymbolFilters, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	body, err := c.sendPublic(c.paths.exchangeInfo, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Symbols []struct {
+			Symbol              string               `json:"symbol"`
+			QuoteAssetPrecision int                  `json:"quoteAssetPrecision"`
+			Filters             []exchangeInfoFilter `json:"filters"`
+		} `json:"symbols"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	if len(info.Symbols) == 0 {
+		return nil, fmt.Errorf("symbol %s not found in exchange info", symbol)
+	}
+
+	filters := &SymbolFilters{
+		Symbol:         info.Symbols[0].Symbol,
+		QuotePrecision: info.Symbols[0].QuoteAssetPrecision,
+	}
+	filters.apply(info.Symbols[0].Filters)
+
+	return filters, nil
+}
+
+// apply sets the rules of the PRICE_FILTER, LOT_SIZE and (MIN_)NOTIONAL entries of an exchangeInfo filter list
+func (f *SymbolFilters) apply(list []exchangeInfoFilter) {
+	for _, filter := range list {
+		switch filter.FilterType {
+		case "PRICE_FILTER":
+			f.TickSize = decimalOrZero(filter.TickSize)
+		case "LOT_SIZE":
+			f.StepSize = decimalOrZero(filter.StepSize)
+			f.MinQty = decimalOrZero(filter.MinQty)
+			f.MaxQty = decimalOrZero(filter.MaxQty)
+		case "MIN_NOTIONAL", "NOTIONAL":
+			// The futures API names the minimum notional field "notional"
+			f.MinNotional = decimalOrZero(firstNonEmpty(filter.MinNotional, filter.Notional))
+		}
+	}
+}
+
+// PlaceOrder places an order on Binance: a limit order, or a market order by base or quote quantity.
+// Orders are placed idempotently by their client order ID.
+func (c *BinanceClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	if isStopLimit(req.Type) {
+		if err := req.validateStopLimit(); err != nil {
+			return nil, err
+		}
+	}
+	return c.submitOrder(req.Symbol, req.ClientOrderID, spotOrderParams(req))
+}
+
+// spotOrderParams builds the parameters of a new order on the spot order books, as spot and margin orders are
+func spotOrderParams(req OrderRequest) url.Values {
+	params := url.Values{}
+	params.Set("symbol", req.Symbol)
+	params.Set("side", strings.ToUpper(req.Side))
+	if req.ClientOrderID != "" {
+		params.Set("newClientOrderId", req.ClientOrderID)
+	}
+
+	if req.Type == OrderTypeLimit || isStopLimit(req.Type) {
+		params.Set("type", req.Type)
+		params.Set("timeInForce", cmp.Or(req.TimeInForce, TimeInForceGTC))
+		params.Set("quantity", req.Quantity.String())
+		params.Set("price", req.Price.String())
+		if isStopLimit(req.Type) {
+			params.Set("stopPrice", req.StopPrice.String())
+		}
+	} else if req.Type == OrderTypeLimitMaker {
+		params.Set("type", OrderTypeLimitMaker)
+		params.Set("quantity", req.Quantity.String())
+		params.Set("price", req.Price.String())
+	} else if req.Quantity.Sign() > 0 {
+		params.Set("type", OrderTypeMarket)
+		params.Set("quantity", req.Quantity.String())
+	} else {
+		params.Set("type", OrderTypeMarket)
+		params.Set("quoteOrderQty", req.QuoteQuantity.String())
+	}
+	return params
+}
+
+// submitOrder sends the parameters of a new order idempotently, under a generated client order ID when it has
+// none, so a resend never places it twice
+func (c *BinanceClient) submitOrder(symbol, clientOrderID string, params url.Values) (*Order, error) {
+	if clientOrderID == "" {
+		clientOrderID = newClientOrderID()
+		params.Set("newClientOrderId", clientOrderID)
+	}
+	order := &idempotentOrder{client: c, symbol: symbol, clientOrderID: clientOrderID, params: params}
+	body, err := c.resendOnTimestampError(func() (*httpResult, error) {
+		return doWithRetry(c.retryPolicy, order.attempt, isBinanceRetryable)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseOrderResponse(body)
+}
+
+// newClientOrderID returns a random client order ID for an order placed without one
+func newClientOrderID() string {
+	return "bb-" + newRunID()
+}
+
+// idempotentOrder is a new order sent under a client order ID. A timed out or failed attempt may still have
+// placed the order, and Binance only rejects a reused client order ID while the first order is open, so once
+// the order has been sent it is looked up by its client order ID before every resend.
+type idempotentOrder struct {
+	client        *BinanceClient
+	symbol        string
+	clientOrderID string
+	params        url.Values
+	sent          bool
+}
+
+// attempt sends the order, or returns it when an earlier attempt already placed it
+func (o *idempotentOrder) attempt() (*httpResult, error) {
+	if o.sent {
+		lookup := url.Values{}
+		lookup.Set("symbol", o.symbol)
+		lookup.Set("origClientOrderId", o.clientOrderID)
+		// Isolated margin orders are looked up in the isolated account of their symbol
+		if isolated := o.params.Get("isIsolated"); isolated != "" {
+			lookup.Set("isIsolated", isolated)
+		}
+		res, err := o.client.sendOnce("GET", o.client.paths.order, lookup, binanceAuthSigned)
+		switch {
+		case err != nil:
+			return nil, err
+		case res.StatusCode == http.StatusOK:
+			log.Printf("Order %s was placed by an earlier attempt. Not sending it again.", o.clientOrderID)
+			return res, nil
+		case newBinanceAPIError(res).Code != binanceNoSuchOrderErrorCode:
+			return res, nil
+		}
+	}
+	o.sent = true
+	return o.client.sendOnce("POST", o.client.paths.order, o.params, binanceAuthSigned)
+}
+
+// GetOrder queries the status of an order
+func (c *BinanceClient) GetOrder(symbol, orderID string) (*Order, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", orderID)
+
+	return c.sendOrderRequest("GET", params)
+}
+
+// CancelOrder cancels an open order
+func (c *BinanceClient) CancelOrder(symbol, orderID string) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", orderID)
+
+	_, err := c.sendOrderRequest("DELETE", params)
+	return err
+}
+
+// sendOrderRequest sends a signed request to the order endpoint and converts the response
+func (c *BinanceClient) sendOrderRequest(method string, params url.Values) (*Order, error) {
+	body, err := c.sendSigned(method, c.paths.order, params)
+	if err != nil {
+		return nil, err
+	}
+	return parseOrderResponse(body)
+}
+
+// parseOrderResponse converts the body of an order endpoint response
+func parseOrderResponse(body []byte) (*Order, error) {
+	var orderResp OrderResponse
+	if err := json.Unmarshal(body, &orderResp); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	return orderResp.toOrder(), nil
+}
+
+// toOrder converts a Binance order response into an exchange-agnostic order
+func (r *OrderResponse) toOrder() *Order {
+	order := &Order{
+		Symbol:        r.Symbol,
+		OrderID:       strconv.FormatInt(r.OrderID, 10),
+		ClientOrderID: r.ClientOrderID,
+		Price:         r.Price,
+		StopPrice:     r.StopPrice,
+		OrigQty:       r.OrigQty,
+		ExecutedQty:   r.ExecutedQty,
+		CumQuoteQty:   firstNonEmpty(r.CumQuoteQty, r.CumQuote),
+		Status:        r.Status,
+		Type:          r.Type,
+		Side:          r.Side,
+	}
+	for _, fill := range r.Fills {
+		order.Commission = order.Commission.Add(decimalOrZero(fill.Commission))
+		order.CommissionAsset = fill.CommissionAsset
+	}
+	// Futures order responses have no transactTime, so a new order is dated by its update time
+	if created := cmp.Or(max(r.Time, r.TransactTime), r.UpdateTime); created > 0 {
+		order.CreatedAt = time.UnixMilli(created)
+	}
+	return order
+}
+
+// GetOpenOrders lists the open orders of a symbol
+func (c *BinanceClient) GetOpenOrders(symbol string) ([]*Order, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	return c.listOpenOrders(params)
+}
+
+// listOpenOrders sends a signed request to the open orders endpoint and converts the orders in the response
+func (c *BinanceClient) listOpenOrders(params url.Values) ([]*Order, error) {
+	body, err := c.sendSigned("GET", c.paths.openOrders, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var orderResps []OrderResponse
+	if err := json.Unmarshal(body, &orderResps); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	orders := make([]*Order, 0, len(orderResps))
+	for _, orderResp := range orderResps {
+		orders = append(orders, orderResp.toOrder())
+	}
+	return orders, nil
+}
+
+// GetTrades lists the account's trades of a symbol executed between since and until, where a zero until
+// means up to now
+func (c *BinanceClient) GetTrades(symbol string, since, until time.Time) ([]Trade, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	return c.paginateTrades("/api/v3/myTrades", params, since, until)
+}
+
+// GetOrderTrades lists the account's trades of an order
+func (c *BinanceClient) GetOrderTrades(symbol, orderID string) ([]Trade, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", orderID)
+
+	return c.listTrades("/api/v3/myTrades", params)
+}
+
+// paginateTrades lists trades from a trade list endpoint a page at a time. The first page starts at since and
+// each later page at the trade ID after the previous page's last, since Binance does not combine fromId with
+// startTime. Paging stops at the first trade after until.
+func (c *BinanceClient) paginateTrades(endpoint string, params url.Values, since, until time.Time) ([]Trade, error) {
+	params.Set("startTime", strconv.FormatInt(since.UnixMilli(), 10))
+	params.Set("limit", strconv.Itoa(binanceTradePageSize))
+
+	var trades []Trade
+	for {
+		page, err := c.listTrades(endpoint, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, trade := range page {
+			if !until.IsZero() && trade.Time.After(until) {
+				return trades, nil
+			}
+			trades = append(trades, trade)
+		}
+		if len(page) < binanceTradePageSize {
+			return trades, nil
+		}
+		lastID, err := strconv.ParseInt(page[len(page)-1].ID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error paging trades: invalid trade ID %q", page[len(page)-1].ID)
+		}
+		params.Del("startTime")
+		params.Set("fromId", strconv.FormatInt(lastID+1, 10))
+	}
+}
+
+// listTrades sends a signed request to a trade list endpoint and converts the trades in the response
+func (c *BinanceClient) listTrades(endpoint string, params url.Values) ([]Trade, error) {
+	body, err := c.sendSigned("GET", endpoint, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawTrades []struct {
+		Symbol          string `json:"symbol"`
+		ID              int64  `json:"id"`
+		OrderID         int64  `json:"orderId"`
+		Price           string `json:"price"`
+		Qty             string `json:"qty"`
+		QuoteQty        string `json:"quoteQty"`
+		Commission      string `json:"commission"`
+		CommissionAsset string `json:"commissionAsset"`
+		Time            int64  `json:"time"`
+		IsBuyer         bool   `json:"isBuyer"`
+	}
+	if err := json.Unmarshal(body, &rawTrades); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	trades := make([]Trade, 0, len(rawTrades))
+	for _, raw := range rawTrades {
+		side := "SELL"
+		if raw.IsBuyer {
+			side = "BUY"
+		}
+		// Margin trades have no quote quantity
+		price, qty := decimalOrZero(raw.Price), decimalOrZero(raw.Qty)
+		quoteQty := decimalOrZero(raw.QuoteQty)
+		if raw.QuoteQty == "" {
+			quoteQty = price.Mul(qty)
+		}
+		trades = append(trades, Trade{
+			ID:              strconv.FormatInt(raw.ID, 10),
+			Symbol:          raw.Symbol,
+			OrderID:         strconv.FormatInt(raw.OrderID, 10),
+			Side:            side,
+			Price:           price,
+			Qty:             qty,
+			QuoteQty:        quoteQty,
+			Commission:      decimalOrZero(raw.Commission),
+			CommissionAsset: raw.CommissionAsset,
+			Time:            time.UnixMilli(raw.Time),
+		})
+	}
+	return trades, nil
+}
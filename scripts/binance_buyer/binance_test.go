@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestSetEndpoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		restURLs []string
+		wsURL    string
+		err      bool
+	}{
+		{name: "defaults"},
+		{name: "REST hosts and WebSocket URL", restURLs: []string{"https://api1.binance.com", "http://127.0.0.1:9090"}, wsURL: "wss://stream.binance.com:9443"},
+		{name: "REST URL without a scheme", restURLs: []string{"api.binance.com"}, err: true},
+		{name: "WebSocket URL as a REST URL", restURLs: []string{"wss://stream.binance.com:9443"}, err: true},
+		{name: "REST URL as the WebSocket URL", wsURL: "https://stream.binance.com", err: true},
+		{name: "REST URL without a host", restURLs: []string{"https://"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := NewSigner("hmac", "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := NewBinanceClient("key", signer)
+			err = client.SetEndpoints(tt.restURLs, tt.wsURL)
+			if tt.err {
+				if err == nil {
+					t.Error("endpoints accepted, want an error")
+				}
+				if client.baseURLs[0] != binanceBaseURL || client.wsBaseURL != binanceWSBaseURL {
+					t.Errorf("endpoints changed to %v and %s by invalid URLs", client.baseURLs, client.wsBaseURL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Binance error codes classified by the client
+const (
+	binanceUnknownErrorCode         = -1000
+	binanceDisconnectedErrorCode    = -1001
+	binanceUnauthorizedErrorCode    = -1002
+	binanceTooManyRequestsErrorCode = -1003
+	binanceTimeoutErrorCode         = -1007
+	binanceServerBusyErrorCode      = -1008
+	binanceTimestampErrorCode       = -1021
+	binanceInvalidSignatureCode     = -1022
+	binanceInvalidSymbolErrorCode   = -1121
+	binanceOrderRejectedErrorCode   = -2010
+	binanceNoSuchOrderErrorCode     = -2013
+	binanceAPIKeyFormatErrorCode    = -2014
+	binanceRejectedAPIKeyErrorCode  = -2015
+	// binanceMarginInsufficientErrorCode rejects a futures order the available margin cannot cover
+	binanceMarginInsufficientErrorCode = -2019
+	// binanceMarginTypeUnchangedErrorCode rejects setting a futures symbol to the margin type it already has
+	binanceMarginTypeUnchangedErrorCode = -4046
+)
+
+// BinanceAPIError is an error response returned by the Binance API
+type BinanceAPIError struct {
+	StatusCode int    `json:"-"`
+	Code       int    `json:"code"`
+	Msg        string `json:"msg"`
+}
+
+// newBinanceAPIError parses the error body of a failed response. Bodies that are not Binance errors,
+// such as the HTML served by a proxy, are kept as the message.
+func newBinanceAPIError(res *httpResult) *BinanceAPIError {
+	apiErr := &BinanceAPIError{StatusCode: res.StatusCode}
+	if err := json.Unmarshal(res.Body, apiErr); err != nil || apiErr.Msg == "" {
+		apiErr.Msg = strings.TrimSpace(string(res.Body))
+	}
+	return apiErr
+}
+
+// Error formats the error with its HTTP status and Binance code
+func (e *BinanceAPIError) Error() string {
+	return fmt.Sprintf("Binance API error %d (HTTP %d): %s", e.Code, e.StatusCode, e.Msg)
+}
+
+// Retryable reports whether the request may succeed if sent again later. Timestamp errors are not
+// included since they only succeed once the server time offset is resynced.
+func (e *BinanceAPIError) Retryable() bool {
+	if e.Banned() {
+		return false
+	}
+	switch e.Code {
+	case binanceUnknownErrorCode, binanceDisconnectedErrorCode, binanceTooManyRequestsErrorCode,
+		binanceTimeoutErrorCode, binanceServerBusyErrorCode:
+		return true
+	}
+	return isRetryableStatus(e.StatusCode)
+}
+
+// Fatal reports whether the error will persist for the rest of the run, so placing further orders is pointless
+func (e *BinanceAPIError) Fatal() bool {
+	if e.Banned() || e.InsufficientBalance() {
+		return true
+	}
+	switch e.Code {
+	case binanceUnauthorizedErrorCode, binanceInvalidSignatureCode, binanceInvalidSymbolErrorCode,
+		binanceAPIKeyFormatErrorCode, binanceRejectedAPIKeyErrorCode:
+		return true
+	}
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// Banned reports whether the IP has been banned for ignoring rate limits
+func (e *BinanceAPIError) Banned() bool {
+	return e.StatusCode == http.StatusTeapot
+}
+
+// InsufficientBalance reports whether an order was rejected because the account cannot fund it
+func (e *BinanceAPIError) InsufficientBalance() bool {
+	if e.Code == binanceMarginInsufficientErrorCode {
+		return true
+	}
+	return e.Code == binanceOrderRejectedErrorCode && strings.Contains(strings.ToLower(e.Msg), "insufficient balance")
+}
+
+// isFatalError reports whether err is an exchange error that will persist for the rest of the run, a
+// breached spend cap or a spend ledger that cannot be saved
+func isFatalError(err error) bool {
+	var capErr *SpendCapError
+	if errors.As(err, &capErr) || errors.Is(err, errSpendLedgerNotSaved) {
+		return true
+	}
+	var apiErr *BinanceAPIError
+	return errors.As(err, &apiErr) && apiErr.Fatal()
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// blackoutOrdinals maps the ordinals of recurring blackout rules, e.g. "first fri", to the week of the month
+var blackoutOrdinals = map[string]int{"first": 1, "second": 2, "third": 3, "fourth": 4, "last": -1}
+
+// Blackout is a period of a blackout calendar during which a run places no slices. It is either a one-off
+// period from From to To, a Date, or a rule recurring Every day, every weekday ("wed") or on the nth weekday
+// of the month ("first fri"). Hours narrows a date or recurring rule to a time of day in UTC, e.g.
+// "12:00-13:30", and runs past midnight when it ends before it starts.
+type Blackout struct {
+	Name  string    `json:"name,omitempty"`
+	From  time.Time `json:"from,omitzero"`
+	To    time.Time `json:"to,omitzero"`
+	Date  string    `json:"date,omitempty"`
+	Every string    `json:"every,omitempty"`
+	Hours string    `json:"hours,omitempty"`
+}
+
+// loadBlackouts reads a blackout calendar, a JSON list of blackout periods, e.g.
+// [{"name": "FOMC", "from": "2026-11-04T18:00:00Z", "to": "2026-11-04T20:00:00Z"}, {"name": "Christmas", "date": "2026-12-25"},
+// {"name": "NFP", "every": "first fri", "hours": "12:15-13:00"}, {"name": "Maintenance", "every": "tue", "hours": "06:00-07:00"}]
+func loadBlackouts(path string) ([]Blackout, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading blackout calendar: %v", err)
+	}
+	var blackouts []Blackout
+	if err := json.Unmarshal(data, &blackouts); err != nil {
+		return nil, fmt.Errorf("error parsing blackout calendar: %v", err)
+	}
+	for i, b := range blackouts {
+		if err := b.validate(); err != nil {
+			return nil, fmt.Errorf("blackout %d (%s): %v", i+1, b.label(), err)
+		}
+	}
+	return blackouts, nil
+}
+
+// label returns the name of the blackout, or its period when it has none
+func (b Blackout) label() string {
+	switch {
+	case b.Name != "":
+		return b.Name
+	case b.Date != "":
+		return b.Date
+	case b.Every != "":
+		return strings.TrimSpace("every " + b.Every + " " + b.Hours)
+	}
+	return b.From.Format(time.RFC3339)
+}
+
+// validate checks that the blackout sets exactly one kind of period with valid values
+func (b Blackout) validate() error {
+	kinds := 0
+	for _, set := range []bool{!b.From.IsZero() || !b.To.IsZero(), b.Date != "", b.Every != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return fmt.Errorf("set one of from and to, date or every")
+	}
+	if !b.From.IsZero() || !b.To.IsZero() {
+		if b.From.IsZero() || !b.To.After(b.From) {
+			return fmt.Errorf("to must be after from")
+		}
+		if b.Hours != "" {
+			return fmt.Errorf("hours only apply to date and every")
+		}
+		return nil
+	}
+	if b.Date != "" {
+		if _, err := time.Parse(time.DateOnly, b.Date); err != nil {
+			return fmt.Errorf("invalid date: %s. Use YYYY-MM-DD", b.Date)
+		}
+	} else if _, _, err := b.rule(); err != nil {
+		return err
+	}
+	if _, _, err := b.hours(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// rule parses Every into the weekday and week of the month it recurs on, where a negative weekday recurs
+// every day, a zero week every week and week -1 the last week of the month
+func (b Blackout) rule() (time.Weekday, int, error) {
+	fields := strings.Fields(strings.ToLower(b.Every))
+	if len(fields) == 1 && fields[0] == "day" {
+		return -1, 0, nil
+	}
+	week := 0
+	if len(fields) == 2 {
+		var ok bool
+		if week, ok = blackoutOrdinals[fields[0]]; !ok {
+			return 0, 0, fmt.Errorf("invalid ordinal: %s. Use first, second, third, fourth or last", fields[0])
+		}
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return 0, 0, fmt.Errorf("invalid every: %s. Use day, a weekday (e.g. wed) or an ordinal weekday (e.g. first fri)", b.Every)
+	}
+	day, ok := weekdays[fields[0]]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid weekday: %s. Use sun, mon, tue, wed, thu, fri or sat", fields[0])
+	}
+	return day, week, nil
+}
+
+// hours parses Hours into its start and end in minutes after midnight, the whole day when it is empty
+func (b Blackout) hours() (int, int, error) {
+	if b.Hours == "" {
+		return 0, 24 * 60, nil
+	}
+	start, end, ok := strings.Cut(b.Hours, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid hours: %s. Use HH:MM-HH:MM in UTC, e.g. 12:00-13:30", b.Hours)
+	}
+	startMinute, err := parseMinuteOfDay(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	endMinute, err := parseMinuteOfDay(end)
+	if err != nil {
+		return 0, 0, err
+	}
+	if endMinute <= startMinute {
+		endMinute += 24 * 60
+	}
+	return startMinute, endMinute, nil
+}
+
+// recursOn reports whether the blackout's date or rule falls on the UTC day starting at midnight
+func (b Blackout) recursOn(midnight time.Time) bool {
+	if b.Date != "" {
+		return midnight.Format(time.DateOnly) == b.Date
+	}
+	day, week, err := b.rule()
+	switch {
+	case err != nil:
+		return false
+	case day < 0:
+		return true
+	case midnight.Weekday() != day:
+		return false
+	case week == -1:
+		return midnight.AddDate(0, 0, 7).Month() != midnight.Month()
+	case week > 0:
+		return (midnight.Day()-1)/7+1 == week
+	}
+	return true
+}
+
+// activeAt returns the end of the blackout period t falls in, reporting false when it falls in none
+func (b Blackout) activeAt(t time.Time) (time.Time, bool) {
+	if !b.From.IsZero() {
+		return b.To, !t.Before(b.From) && t.Before(b.To)
+	}
+	start, end, err := b.hours()
+	if err != nil {
+		return time.Time{}, false
+	}
+	// A period running past midnight may have started the day before
+	today := t.UTC().Truncate(24 * time.Hour)
+	for _, midnight := range []time.Time{today, today.AddDate(0, 0, -1)} {
+		from := midnight.Add(time.Duration(start) * time.Minute)
+		to := midnight.Add(time.Duration(end) * time.Minute)
+		if b.recursOn(midnight) && !t.Before(from) && t.Before(to) {
+			return to, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// activeBlackout returns the blackout of the calendar t falls in and the end of its period, the latest one
+// when periods overlap
+func activeBlackout(blackouts []Blackout, t time.Time) (Blackout, time.Time, bool) {
+	var active Blackout
+	var until time.Time
+	for _, b := range blackouts {
+		if end, ok := b.activeAt(t); ok && end.After(until) {
+			active, until = b, end
+		}
+	}
+	return active, until, !until.IsZero()
+}
+
+// waitForBlackout holds the run back while a period of its blackout calendar is in effect, reporting whether it
+// waited and false if ctx was cancelled meanwhile
+func (s *RunState) waitForBlackout(ctx context.Context) (waited, ok bool) {
+	blackout, until, active := activeBlackout(s.Config.Blackouts, time.Now())
+	if !active {
+		return false, true
+	}
+	s.audit(AuditBlackout, "next_slice", s.NextSlice+1, "blackout", blackout.label(), "until", until.Format(time.RFC3339))
+	slog.Info("Blackout period in effect. Pausing until it ends.", "symbol", s.Config.Symbol, "blackout", blackout.label(),
+		"until", until.Format(time.RFC3339), "next_slice", s.NextSlice+1)
+	if !sleepContext(ctx, time.Until(until)) {
+		return true, false
+	}
+	slog.Info("Blackout period over. Re-planning the remaining slices from now.", "symbol", s.Config.Symbol,
+		"blackout", blackout.label(), "next_slice", s.NextSlice+1, "total_slices", s.TotalSlices)
+	return true, true
+}
+
+// waitUntilTradable holds the run back until its trading window is open and no blackout period is in effect,
+// reporting whether it waited and false if ctx was cancelled meanwhile
+func (s *RunState) waitUntilTradable(ctx context.Context) (waited, ok bool) {
+	for {
+		windowWaited, ok := s.waitForWindow(ctx)
+		if !ok {
+			return true, false
+		}
+		blackoutWaited, ok := s.waitForBlackout(ctx)
+		if !ok {
+			return true, false
+		}
+		if !windowWaited && !blackoutWaited {
+			return waited, true
+		}
+		waited = true
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"strings"
+)
+
+// Mean-reversion exit targets
+const (
+	// BollingerExitMid sells once the close is back at the middle band
+	BollingerExitMid = "mid"
+	// BollingerExitUpper sells once the close reaches the upper band
+	BollingerExitUpper = "upper"
+)
+
+// BollingerConfig holds the parameters of the Bollinger Band mean-reversion strategy
+type BollingerConfig struct {
+	// Period is the number of candles the bands are computed over
+	Period int `json:"period"`
+	// StdDev is the distance of the bands from the middle band in standard deviations
+	StdDev float64 `json:"std_dev"`
+	// Exit is the band a position is sold at: mid or upper
+	Exit string `json:"exit"`
+	// StopLossPct sells once the close falls this percentage below the entry signal's close (0 to disable)
+	StopLossPct float64 `json:"stop_loss_pct,omitempty"`
+}
+
+// MeanReversion buys when a candle closes at or below the lower Bollinger Band and sells when the close
+// reverts to the middle or upper band, or falls to the stop-loss
+type MeanReversion struct {
+	cfg   BollingerConfig
+	bands *BollingerBands
+	// entry is the close of the entry signal's candle, zero while flat
+	entry float64
+}
+
+// NewMeanReversion creates the strategy, validating its parameters
+func NewMeanReversion(cfg BollingerConfig) (*MeanReversion, error) {
+	if cfg.Period < 2 || cfg.StdDev <= 0 {
+		return nil, fmt.Errorf("bollinger period must be at least 2 and std_dev positive")
+	}
+	cfg.Exit = strings.ToLower(cmp.Or(cfg.Exit, BollingerExitMid))
+	if cfg.Exit != BollingerExitMid && cfg.Exit != BollingerExitUpper {
+		return nil, fmt.Errorf("invalid bollinger exit: %s. Use mid or upper", cfg.Exit)
+	}
+	if cfg.StopLossPct < 0 || cfg.StopLossPct >= 100 {
+		return nil, fmt.Errorf("bollinger stop_loss_pct must be between 0 and 100")
+	}
+	return &MeanReversion{cfg: cfg, bands: NewBollingerBands(cfg.Period, cfg.StdDev)}, nil
+}
+
+// Name returns the strategy's config name
+func (m *MeanReversion) Name() string {
+	return "bollinger"
+}
+
+// Warmup returns the candles needed to compute the bands
+func (m *MeanReversion) Warmup() int {
+	return m.cfg.Period
+}
+
+// OnCandle signals an entry at the lower band and an exit at the target band or the stop-loss
+func (m *MeanReversion) OnCandle(c Candle, inPosition bool) Signal {
+	m.bands.Update(c)
+	if !m.bands.Ready() {
+		return Signal{}
+	}
+	if !inPosition {
+		m.entry = 0
+		if lower := m.bands.Lower(); c.Close <= lower {
+			m.entry = c.Close
+			return Signal{Action: ActionEnter, Reason: fmt.Sprintf("close %.8f at or below the lower band %.8f", c.Close, lower)}
+		}
+		return Signal{}
+	}
+
+	if m.cfg.StopLossPct > 0 && m.entry > 0 {
+		if stop := m.entry * (1 - m.cfg.StopLossPct/100); c.Close <= stop {
+			return Signal{Action: ActionExit, Reason: fmt.Sprintf("close %.8f hit the stop-loss %.8f", c.Close, stop)}
+		}
+	}
+	target := m.bands.Value()
+	if m.cfg.Exit == BollingerExitUpper {
+		target = m.bands.Upper()
+	}
+	if c.Close >= target {
+		return Signal{Action: ActionExit, Reason: fmt.Sprintf("close %.8f reached the %s band %.8f", c.Close, m.cfg.Exit, target)}
+	}
+	return Signal{}
+}
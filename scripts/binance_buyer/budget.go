@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+)
+
+// maxBudgetRequestBytes bounds the body of a budget change request
+const maxBudgetRequestBytes = 4 << 10
+
+// budgetChange is a change of a run's remaining budget requested through the control server. Remaining, when set,
+// replaces the remaining budget before Delta is added to it.
+type budgetChange struct {
+	Remaining *Decimal `json:"remaining,omitempty"`
+	Delta     Decimal  `json:"delta,omitzero"`
+}
+
+// handleBudget queues a change of the remaining budget of the run named in the path, given as JSON with the new
+// remaining budget or a signed delta. The run applies it before its next slice.
+func (c *RunControl) handleBudget(w http.ResponseWriter, r *http.Request) {
+	var change budgetChange
+	err := json.NewDecoder(io.LimitReader(r.Body, maxBudgetRequestBytes)).Decode(&change)
+	if err != nil || (change.Remaining == nil && change.Delta.IsZero()) {
+		http.Error(w, `budget must be JSON with "remaining" (the new remaining budget) or "delta" (the amount to add or remove)`, http.StatusBadRequest)
+		return
+	}
+	if change.Remaining != nil && change.Remaining.Sign() < 0 {
+		http.Error(w, "remaining budget must not be negative", http.StatusBadRequest)
+		return
+	}
+
+	c.mu.Lock()
+	if id := r.PathValue("id"); id != c.status.RunID {
+		c.mu.Unlock()
+		http.Error(w, fmt.Sprintf("no run %s", id), http.StatusNotFound)
+		return
+	}
+	if !c.budgetOpen {
+		c.mu.Unlock()
+		http.Error(w, "run has no slices left to re-plan", http.StatusConflict)
+		return
+	}
+	if c.budget == nil || change.Remaining != nil {
+		c.budget = &change
+	} else {
+		c.budget.Delta = c.budget.Delta.Add(change.Delta)
+	}
+	c.mu.Unlock()
+	if change.Remaining != nil {
+		log.Printf("Remaining budget change to %s requested via control server", change.Remaining)
+	} else {
+		log.Printf("Remaining budget change by %s requested via control server", change.Delta)
+	}
+	c.handleStatus(w, r)
+}
+
+// takeBudgetChange returns the pending budget change and clears it. A nil control has none.
+func (c *RunControl) takeBudgetChange() (budgetChange, bool) {
+	if c == nil {
+		return budgetChange{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.budget == nil {
+		return budgetChange{}, false
+	}
+	change := *c.budget
+	c.budget = nil
+	return change, true
+}
+
+// acceptBudgetChanges opens or closes the run to budget changes, which are only applied while it schedules slices.
+// A change still pending when it closes is dropped. A nil control is a no-op.
+func (c *RunControl) acceptBudgetChanges(open bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.budgetOpen = open
+	if !open && c.budget != nil {
+		log.Printf("Dropping the requested budget change: the run has no slices left")
+		c.budget = nil
+	}
+}
+
+// changeBudget applies a change of the remaining budget. The change is spread over the remaining planned slices in
+// proportion to their planned amounts, so the run spends its new budget over the time it has left. A decrease
+// larger than the remaining slices' budget takes the rest from the re-planned backlog and the carried amount.
+func (s *RunState) changeBudget(change budgetChange) {
+	remaining := s.Remaining
+	if change.Remaining != nil {
+		remaining = *change.Remaining
+	}
+	remaining = remaining.Add(change.Delta)
+	if remaining.Sign() < 0 {
+		remaining = Decimal{}
+	}
+	delta := remaining.Sub(s.Remaining)
+	s.Remaining = remaining
+	s.BudgetChanged = s.BudgetChanged.Add(delta)
+
+	s.Adjustment = s.Adjustment.Add(delta)
+	if floor := s.remainingPlanned().Neg(); s.Adjustment.LessThan(floor) {
+		excess := floor.Sub(s.Adjustment)
+		s.Adjustment = floor
+		fromBacklog := minDecimal(excess, s.Backlog)
+		s.Backlog = s.Backlog.Sub(fromBacklog)
+		s.Carry = s.Carry.Sub(minDecimal(excess.Sub(fromBacklog), s.Carry))
+	}
+
+	s.audit(AuditBudgetChanged, "next_slice", s.NextSlice+1, "delta", delta, "remaining_budget", s.Remaining,
+		"target_amount", s.targetAmount())
+	slog.Info("Remaining budget changed. Re-planning the remaining slices.", "symbol", s.Config.Symbol, "delta", delta,
+		"remaining_budget", s.Remaining, "next_slice", s.NextSlice+1, "total_slices", s.TotalSlices, "budget_asset", s.Config.budgetAsset())
+}
+
+// remainingPlanned returns the planned amount of the slices from the next one on, before budget changes
+func (s *RunState) remainingPlanned() Decimal {
+	var planned Decimal
+	for i := s.NextSlice; i < s.plannedSlices(); i++ {
+		planned = planned.Add(s.sliceAmount(i))
+	}
+	return planned
+}
+
+// adjustmentShare returns the part of the budget adjustment due with the next slice, in proportion to its planned
+// amount among the remaining slices', or all of it when no planned slice is left
+func (s *RunState) adjustmentShare() Decimal {
+	if s.Adjustment.IsZero() {
+		return Decimal{}
+	}
+	planned := s.remainingPlanned()
+	if planned.Sign() <= 0 {
+		return s.Adjustment
+	}
+	return s.Adjustment.Mul(s.sliceAmount(s.NextSlice)).Div(planned)
+}
+
+// targetAmount returns the run's target amount including the budget changes made while it ran
+func (s *RunState) targetAmount() Decimal {
+	return s.Config.Amount.Add(s.BudgetChanged)
+}
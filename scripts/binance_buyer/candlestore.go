@@ -0,0 +1,370 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// candleDBFile is the name of the SQLite database holding the candles of a data directory
+const candleDBFile = "candles.db"
+
+// candleSchema creates the tables of the candle database. Candles are keyed by symbol, interval and open time in
+// Unix milliseconds. months records which months trade data download has stored and whether they are complete,
+// and unavailable the closed ranges the exchange returned no candles for.
+const candleSchema = `
+CREATE TABLE IF NOT EXISTS candles (
+	symbol       TEXT    NOT NULL,
+	interval     TEXT    NOT NULL,
+	open_time    INTEGER NOT NULL,
+	open         REAL    NOT NULL,
+	high         REAL    NOT NULL,
+	low          REAL    NOT NULL,
+	close        REAL    NOT NULL,
+	volume       REAL    NOT NULL,
+	close_time   INTEGER NOT NULL,
+	quote_volume REAL    NOT NULL,
+	trades       INTEGER NOT NULL,
+	PRIMARY KEY (symbol, interval, open_time)
+) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS months (
+	symbol   TEXT    NOT NULL,
+	interval TEXT    NOT NULL,
+	month    TEXT    NOT NULL,
+	complete INTEGER NOT NULL,
+	source   TEXT    NOT NULL,
+	PRIMARY KEY (symbol, interval, month)
+) WITHOUT ROWID;
+CREATE TABLE IF NOT EXISTS unavailable (
+	symbol     TEXT    NOT NULL,
+	interval   TEXT    NOT NULL,
+	start_time INTEGER NOT NULL,
+	end_time   INTEGER NOT NULL,
+	PRIMARY KEY (symbol, interval, start_time)
+) WITHOUT ROWID;
+`
+
+// CandleRange is a range of candle open times, both ends included
+type CandleRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// openCandleDB opens the candle database of a data directory, creating it when create is set. Writers from other
+// processes are waited for rather than failing.
+func openCandleDB(root string, create bool) (*sql.DB, error) {
+	path := filepath.Join(root, candleDBFile)
+	if create {
+		if err := os.MkdirAll(root, 0o755); err != nil {
+			return nil, fmt.Errorf("error creating %s: %v", root, err)
+		}
+	} else if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("no candles stored in %s. Run trade data download first", root)
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %v", path, err)
+	}
+	if _, err := db.Exec(candleSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error creating the tables of %s: %v", path, err)
+	}
+	return db, nil
+}
+
+// CandleStore caches the closed klines of a symbol and interval in the SQLite database of a data directory, the
+// database trade data download writes, so repeated runs only request the candles they have not seen yet
+type CandleStore struct {
+	db       *sql.DB
+	symbol   string
+	interval string
+	period   time.Duration
+	// unavailable are the closed ranges the exchange returned no candles for, by start, which are not requested again
+	unavailable []CandleRange
+}
+
+// newCandleStore returns the store of symbol and interval in db
+func newCandleStore(db *sql.DB, symbol, interval string) (*CandleStore, error) {
+	period, ok := klineDurations[interval]
+	if !ok {
+		return nil, fmt.Errorf("invalid interval %q. Use a kline interval such as 1m, 15m, 1h or 1d", interval)
+	}
+	s := &CandleStore{db: db, symbol: symbol, interval: interval, period: period}
+	rows, err := db.Query(`SELECT start_time, end_time FROM unavailable WHERE symbol = ? AND interval = ? ORDER BY start_time`,
+		symbol, interval)
+	if err != nil {
+		return nil, fmt.Errorf("error reading unavailable ranges: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var start, end int64
+		if err := rows.Scan(&start, &end); err != nil {
+			return nil, fmt.Errorf("error reading unavailable ranges: %v", err)
+		}
+		s.unavailable = append(s.unavailable, CandleRange{Start: time.UnixMilli(start), End: time.UnixMilli(end)})
+	}
+	return s, rows.Err()
+}
+
+// load returns the stored candles opened between start and end
+func (s *CandleStore) load(start, end time.Time) ([]Candle, error) {
+	rows, err := s.db.Query(`SELECT open_time, open, high, low, close, volume, close_time, quote_volume, trades FROM candles
+		WHERE symbol = ? AND interval = ? AND open_time BETWEEN ? AND ? ORDER BY open_time`,
+		s.symbol, s.interval, start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s %s candles: %v", s.symbol, s.interval, err)
+	}
+	defer rows.Close()
+	var candles []Candle
+	for rows.Next() {
+		var c Candle
+		var openTime, closeTime int64
+		if err := rows.Scan(&openTime, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &closeTime, &c.QuoteVolume, &c.Trades); err != nil {
+			return nil, fmt.Errorf("error reading %s %s candles: %v", s.symbol, s.interval, err)
+		}
+		c.OpenTime, c.CloseTime = time.UnixMilli(openTime), time.UnixMilli(closeTime)
+		candles = append(candles, c)
+	}
+	return candles, rows.Err()
+}
+
+// gaps returns the ranges between start and end, capped at now, in which candles are missing from candles, the
+// stored candles of the range. Ranges known to be unavailable are cut out of the gaps.
+func (s *CandleStore) gaps(candles []Candle, start, end time.Time) []CandleRange {
+	if now := time.Now(); end.After(now) {
+		end = now
+	}
+	var gaps []CandleRange
+	add := func(from, to time.Time) {
+		for _, r := range s.unavailable {
+			if r.End.Before(from) || r.Start.After(to) {
+				continue
+			}
+			if r.Start.After(from) {
+				gaps = append(gaps, CandleRange{Start: from, End: r.Start.Add(-time.Millisecond)})
+			}
+			from = r.End.Add(time.Millisecond)
+		}
+		if !from.After(to) {
+			gaps = append(gaps, CandleRange{Start: from, End: to})
+		}
+	}
+	if len(candles) == 0 {
+		add(start, end)
+		return gaps
+	}
+	if candles[0].OpenTime.Sub(start) >= s.period {
+		add(start, candles[0].OpenTime.Add(-time.Millisecond))
+	}
+	for i := 1; i < len(candles); i++ {
+		if candles[i].OpenTime.Sub(candles[i-1].OpenTime) > s.period {
+			add(candles[i-1].OpenTime.Add(time.Millisecond), candles[i].OpenTime.Add(-time.Millisecond))
+		}
+	}
+	add(candles[len(candles)-1].CloseTime.Add(time.Millisecond), end)
+	return gaps
+}
+
+// insert writes candles in tx, replacing stored candles with the same open time
+func (s *CandleStore) insert(tx *sql.Tx, candles []Candle) error {
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO candles
+		(symbol, interval, open_time, open, high, low, close, volume, close_time, quote_volume, trades)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, c := range candles {
+		if _, err := stmt.Exec(s.symbol, s.interval, c.OpenTime.UnixMilli(), c.Open, c.High, c.Low, c.Close, c.Volume,
+			c.CloseTime.UnixMilli(), c.QuoteVolume, c.Trades); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markMonth records in tx that a month has candles from source, and whether it is complete
+func (s *CandleStore) markMonth(tx *sql.Tx, month time.Time, complete bool, source string) error {
+	_, err := tx.Exec(`INSERT INTO months (symbol, interval, month, complete, source) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (symbol, interval, month) DO UPDATE SET complete = excluded.complete, source = excluded.source`,
+		s.symbol, s.interval, month.Format("2006-01"), complete, source)
+	return err
+}
+
+// monthStatus reports whether a month has been stored and whether it is complete
+func (s *CandleStore) monthStatus(month time.Time) (stored, complete bool, err error) {
+	err = s.db.QueryRow(`SELECT complete FROM months WHERE symbol = ? AND interval = ? AND month = ?`,
+		s.symbol, s.interval, month.Format("2006-01")).Scan(&complete)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("error reading the stored months: %v", err)
+	}
+	return true, complete, nil
+}
+
+// saveMonth stores the candles of a month from source in one transaction, marking whether it is complete
+func (s *CandleStore) saveMonth(month time.Time, candles []Candle, complete bool, source string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.insert(tx, candles); err != nil {
+		return fmt.Errorf("error storing %s %s candles: %v", s.symbol, s.interval, err)
+	}
+	if err := s.markMonth(tx, month, complete, source); err != nil {
+		return fmt.Errorf("error storing %s %s candles: %v", s.symbol, s.interval, err)
+	}
+	return tx.Commit()
+}
+
+// store merges closed candles into the database. A month is marked complete once it has ended without gaps.
+func (s *CandleStore) store(candles []Candle) error {
+	if len(candles) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := s.insert(tx, candles); err != nil {
+		return fmt.Errorf("error storing %s %s candles: %v", s.symbol, s.interval, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	var months []time.Time
+	for _, c := range candles {
+		if month := monthStart(c.OpenTime); !slices.ContainsFunc(months, month.Equal) {
+			months = append(months, month)
+		}
+	}
+	for _, month := range months {
+		monthEnd := month.AddDate(0, 1, 0)
+		monthCandles, err := s.load(month, monthEnd.Add(-time.Millisecond))
+		if err != nil {
+			return err
+		}
+		complete := !monthEnd.After(time.Now()) && len(s.gaps(monthCandles, month, monthEnd.Add(-time.Millisecond))) == 0
+		if _, err := s.db.Exec(`INSERT INTO months (symbol, interval, month, complete, source) VALUES (?, ?, ?, ?, 'api')
+			ON CONFLICT (symbol, interval, month) DO UPDATE SET complete = excluded.complete`,
+			s.symbol, s.interval, month.Format("2006-01"), complete); err != nil {
+			return fmt.Errorf("error storing the %s month of %s %s: %v", month.Format("2006-01"), s.symbol, s.interval, err)
+		}
+	}
+	return nil
+}
+
+// markUnavailable records a closed range the exchange has no candles for, so it is not requested again
+func (s *CandleStore) markUnavailable(r CandleRange) error {
+	if _, err := s.db.Exec(`INSERT OR REPLACE INTO unavailable (symbol, interval, start_time, end_time) VALUES (?, ?, ?, ?)`,
+		s.symbol, s.interval, r.Start.UnixMilli(), r.End.UnixMilli()); err != nil {
+		return fmt.Errorf("error recording unavailable %s %s candles: %v", s.symbol, s.interval, err)
+	}
+	s.unavailable = append(s.unavailable, r)
+	slices.SortFunc(s.unavailable, func(a, b CandleRange) int { return a.Start.Compare(b.Start) })
+	return nil
+}
+
+// fill requests the candles of each gap from the exchange and stores the closed ones, returning the candles
+// still open. Closed gaps the exchange has no candles for are recorded as unavailable.
+func (s *CandleStore) fill(client MarketReader, gaps []CandleRange) ([]Candle, error) {
+	now := time.Now()
+	var closed, open []Candle
+	for _, gap := range gaps {
+		candles, err := client.GetKlines(s.symbol, s.interval, gap.Start, gap.End)
+		if err != nil {
+			return nil, fmt.Errorf("error getting %s klines: %v", s.symbol, err)
+		}
+		if len(candles) == 0 && gap.End.Add(s.period).Before(now) {
+			if err := s.markUnavailable(gap); err != nil {
+				return nil, err
+			}
+		}
+		for _, c := range candles {
+			if c.CloseTime.Before(now) {
+				closed = append(closed, c)
+			} else {
+				open = append(open, c)
+			}
+		}
+	}
+	if err := s.store(closed); err != nil {
+		return nil, err
+	}
+	return open, nil
+}
+
+// Candles returns the candles opened between start and end, requesting only the ones missing from the store
+// and storing those that have closed
+func (s *CandleStore) Candles(client MarketReader, start, end time.Time) ([]Candle, error) {
+	candles, err := s.load(start, end)
+	if err != nil {
+		return nil, err
+	}
+	gaps := s.gaps(candles, start, end)
+	if len(gaps) == 0 {
+		return candles, nil
+	}
+	open, err := s.fill(client, gaps)
+	if err != nil {
+		return nil, err
+	}
+	if candles, err = s.load(start, end); err != nil {
+		return nil, err
+	}
+	return append(candles, open...), nil
+}
+
+// candleStoreClient serves the klines of a client through the candle database of a data directory
+type candleStoreClient struct {
+	ExchangeClient
+	root string
+	mu   sync.Mutex
+	// db is opened with the first kline request
+	db *sql.DB
+}
+
+// withCandleStore wraps client so its klines are cached under root
+func withCandleStore(client ExchangeClient, root string) ExchangeClient {
+	return &candleStoreClient{ExchangeClient: client, root: root}
+}
+
+// Unwrap returns the wrapped client
+func (c *candleStoreClient) Unwrap() ExchangeClient {
+	return c.ExchangeClient
+}
+
+// GetKlines returns the klines from the store, requesting the missing ones from the wrapped client. When the
+// store fails, the klines are requested directly, so a broken cache never stops a run.
+func (c *candleStoreClient) GetKlines(symbol, interval string, start, end time.Time) ([]Candle, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	if c.db == nil {
+		c.db, err = openCandleDB(c.root, true)
+	}
+	if err == nil {
+		var store *CandleStore
+		if store, err = newCandleStore(c.db, symbol, interval); err == nil {
+			var candles []Candle
+			if candles, err = store.Candles(c.ExchangeClient, start, end); err == nil {
+				return candles, nil
+			}
+		}
+	}
+	log.Printf("Candle store unavailable, requesting %s klines directly: %v", symbol, err)
+	return c.ExchangeClient.GetKlines(symbol, interval, start, end)
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// klineSource serves hourly candles from listed on, counting the ranges it is asked for
+type klineSource struct {
+	ExchangeClient
+	listed   time.Time
+	requests []CandleRange
+}
+
+func (c *klineSource) GetKlines(symbol, interval string, start, end time.Time) ([]Candle, error) {
+	c.requests = append(c.requests, CandleRange{Start: start, End: end})
+	var candles []Candle
+	for t := start.Truncate(time.Hour); !t.After(end); t = t.Add(time.Hour) {
+		if !t.Before(start) && !t.Before(c.listed) && t.Before(time.Now()) {
+			candles = append(candles, Candle{OpenTime: t, CloseTime: t.Add(time.Hour - time.Millisecond), Close: 100})
+		}
+	}
+	return candles, nil
+}
+
+// hourlyCandles returns the hourly candles opened at the given hours after start
+func hourlyCandles(start time.Time, hours ...int) []Candle {
+	var candles []Candle
+	for _, h := range hours {
+		open := start.Add(time.Duration(h) * time.Hour)
+		candles = append(candles, Candle{OpenTime: open, CloseTime: open.Add(time.Hour - time.Millisecond)})
+	}
+	return candles
+}
+
+// openTestCandleStore opens the store of hourly BTCUSDT candles in a new database
+func openTestCandleStore(t *testing.T, root string) *CandleStore {
+	t.Helper()
+	db, err := openCandleDB(root, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	store, err := newCandleStore(db, "BTCUSDT", "1h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestCandleStoreGaps(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(10*time.Hour - time.Millisecond)
+	hour := func(h int) time.Time { return start.Add(time.Duration(h) * time.Hour) }
+	tests := []struct {
+		name        string
+		candles     []Candle
+		unavailable []CandleRange
+		want        []CandleRange
+	}{
+		{name: "complete", candles: hourlyCandles(start, 0, 1, 2, 3, 4, 5, 6, 7, 8, 9)},
+		{name: "empty", want: []CandleRange{{Start: start, End: end}}},
+		{
+			name:    "missing start",
+			candles: hourlyCandles(start, 2, 3, 4, 5, 6, 7, 8, 9),
+			want:    []CandleRange{{Start: start, End: hour(2).Add(-time.Millisecond)}},
+		},
+		{
+			name:    "missing end",
+			candles: hourlyCandles(start, 0, 1, 2, 3, 4, 5, 6, 7),
+			want:    []CandleRange{{Start: hour(8), End: end}},
+		},
+		{
+			name:    "holes",
+			candles: hourlyCandles(start, 0, 1, 4, 5, 9),
+			want: []CandleRange{
+				{Start: hour(1).Add(time.Millisecond), End: hour(4).Add(-time.Millisecond)},
+				{Start: hour(5).Add(time.Millisecond), End: hour(9).Add(-time.Millisecond)},
+			},
+		},
+		{
+			name:        "unavailable hole",
+			candles:     hourlyCandles(start, 0, 1, 4, 5, 9),
+			unavailable: []CandleRange{{Start: hour(1), End: hour(4)}},
+			want:        []CandleRange{{Start: hour(5).Add(time.Millisecond), End: hour(9).Add(-time.Millisecond)}},
+		},
+		{
+			name:        "unavailable range covers only part of the gap",
+			candles:     hourlyCandles(start, 0, 1, 4, 5, 9),
+			unavailable: []CandleRange{{Start: hour(2), End: hour(3)}},
+			want: []CandleRange{
+				{Start: hour(1).Add(time.Millisecond), End: hour(2).Add(-time.Millisecond)},
+				{Start: hour(3).Add(time.Millisecond), End: hour(4).Add(-time.Millisecond)},
+				{Start: hour(5).Add(time.Millisecond), End: hour(9).Add(-time.Millisecond)},
+			},
+		},
+		{
+			name:        "nothing stored after an unavailable range",
+			unavailable: []CandleRange{{Start: start.Add(-24 * time.Hour), End: hour(6).Add(-time.Millisecond)}},
+			want:        []CandleRange{{Start: hour(6), End: end}},
+		},
+		{
+			name:        "unavailable before listing",
+			candles:     hourlyCandles(start, 6, 7, 8, 9),
+			unavailable: []CandleRange{{Start: start.Add(-24 * time.Hour), End: hour(6).Add(-time.Millisecond)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &CandleStore{period: time.Hour, unavailable: tt.unavailable}
+			got := store.gaps(tt.candles, start, end)
+			if len(got) != len(tt.want) {
+				t.Fatalf("gaps %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Start.Equal(tt.want[i].Start) || !got[i].End.Equal(tt.want[i].End) {
+					t.Errorf("gap %d: %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCandleStoreGapsEndAtNow(t *testing.T) {
+	start := time.Now().Add(-2 * time.Hour).Truncate(time.Hour)
+	store := &CandleStore{period: time.Hour}
+	gaps := store.gaps(hourlyCandles(start, 0), start, start.Add(48*time.Hour))
+	if len(gaps) != 1 || gaps[0].End.After(time.Now()) {
+		t.Errorf("gaps %v, want one gap ending now", gaps)
+	}
+}
+
+func TestCandleStoreRequestsOnlyMissingCandles(t *testing.T) {
+	root := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(48*time.Hour - time.Millisecond)
+	source := &klineSource{}
+
+	store := openTestCandleStore(t, root)
+	candles, err := store.Candles(source, start, end.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 24 || len(source.requests) != 1 {
+		t.Fatalf("got %d candles in %d requests, want 24 in 1", len(candles), len(source.requests))
+	}
+
+	source.requests = nil
+	if candles, err = store.Candles(source, start, end); err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 48 {
+		t.Errorf("got %d candles, want 48", len(candles))
+	}
+	if len(source.requests) != 1 || !source.requests[0].Start.Equal(start.Add(24*time.Hour)) {
+		t.Errorf("requested %v, want only the second day", source.requests)
+	}
+
+	// A reopened store serves the range from the database alone
+	source.requests = nil
+	reopened := openTestCandleStore(t, root)
+	if candles, err = reopened.Candles(source, start, end); err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 48 || len(source.requests) != 0 {
+		t.Errorf("got %d candles in %d requests from the reopened store, want 48 in none", len(candles), len(source.requests))
+	}
+}
+
+func TestCandleStoreRecordsUnavailableRanges(t *testing.T) {
+	root := t.TempDir()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	listed := start.Add(24 * time.Hour)
+	end := start.Add(48*time.Hour - time.Millisecond)
+	source := &klineSource{listed: listed}
+
+	store := openTestCandleStore(t, root)
+	candles, err := store.Candles(source, start, listed.Add(-time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 0 || len(store.unavailable) != 1 {
+		t.Fatalf("got %d candles and unavailable ranges %v, want none and the day before the listing", len(candles), store.unavailable)
+	}
+
+	source.requests = nil
+	if candles, err = store.Candles(source, start, end); err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 24 || !candles[0].OpenTime.Equal(listed) {
+		t.Errorf("got %d candles, want the 24 since the listing", len(candles))
+	}
+	if len(source.requests) != 1 || !source.requests[0].Start.Equal(listed) {
+		t.Errorf("requested %v, want only the day since the listing", source.requests)
+	}
+
+	// The unavailable range is kept in the database, so a reopened store does not request it either
+	source.requests = nil
+	reopened := openTestCandleStore(t, root)
+	if candles, err = reopened.Candles(source, start, end); err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 24 || len(source.requests) != 0 {
+		t.Errorf("got %d candles in %d requests from the reopened store, want 24 in none", len(candles), len(source.requests))
+	}
+}
+
+func TestCandleStoreMarksCompleteMonths(t *testing.T) {
+	store := openTestCandleStore(t, t.TempDir())
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := &klineSource{}
+
+	if _, err := store.Candles(source, january, january.AddDate(0, 0, 15)); err != nil {
+		t.Fatal(err)
+	}
+	if stored, complete, err := store.monthStatus(january); err != nil || !stored || complete {
+		t.Errorf("half a month stored=%t complete=%t (%v), want stored and incomplete", stored, complete, err)
+	}
+	if _, err := store.Candles(source, january, january.AddDate(0, 1, 0).Add(-time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if stored, complete, err := store.monthStatus(january); err != nil || !stored || !complete {
+		t.Errorf("whole month stored=%t complete=%t (%v), want complete", stored, complete, err)
+	}
+}
+
+func TestLoadStoredCandlesNeedsDownloadedMonths(t *testing.T) {
+	root := t.TempDir()
+	if _, err := loadStoredCandles(root, "BTCUSDT", "1h", time.Now().AddDate(0, -1, 0), time.Now()); err == nil {
+		t.Error("loading from a directory without a database succeeded")
+	}
+
+	store := openTestCandleStore(t, root)
+	january := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := store.saveMonth(january, hourlyCandles(january, 0, 1, 2), true, "dump"); err != nil {
+		t.Fatal(err)
+	}
+	candles, err := loadStoredCandles(root, "BTCUSDT", "1h", january, january.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 2 {
+		t.Errorf("loaded %d candles, want the 2 opened before the end", len(candles))
+	}
+	if _, err := loadStoredCandles(root, "BTCUSDT", "1h", january, january.AddDate(0, 1, 1)); err == nil {
+		t.Error("loading a range reaching into a month not downloaded succeeded")
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Catch-up policies for the slices that fell due while a resumed run was not running
+const (
+	// CatchUpExtend keeps the pace of the remaining slices, pushing the end of the run back by the downtime
+	CatchUpExtend = "extend"
+	// CatchUpSkip drops the missed slices, leaving their budget unspent, so the run ends on time
+	CatchUpSkip = "skip"
+	// CatchUpCompress shortens the interval so every remaining slice is placed before the planned end
+	CatchUpCompress = "compress"
+)
+
+// catchUp applies a catch-up policy to a resumed run for the slices that fell due since its state was last
+// saved. A run resumed within an interval of its last save missed nothing.
+func (s *RunState) catchUp(policy string) {
+	left := s.TotalSlices - s.NextSlice
+	if left <= 0 || s.Interval <= 0 || s.UpdatedAt.IsZero() {
+		return
+	}
+	downtime := time.Since(s.UpdatedAt)
+	if downtime <= s.Interval {
+		return
+	}
+	missed := min(int(downtime/s.Interval), left)
+	s.Downtime += downtime
+
+	args := []any{"symbol", s.Config.Symbol, "catch_up", policy, "downtime", downtime.Round(time.Second), "missed_slices", missed}
+	switch policy {
+	case CatchUpSkip:
+		s.NextSlice += missed
+		s.SkippedSlices += missed
+		slog.Warn("Run was down past its due slices. Skipping them and leaving their budget unspent.", append(args, "next_slice", s.NextSlice+1, "total_slices", s.TotalSlices)...)
+	case CatchUpCompress:
+		// The planned end is left intervals after the last save. Once it has passed, the slices are placed a
+		// second apart, the shortest interval a run is planned with.
+		window := time.Duration(left)*s.Interval - downtime
+		s.Interval = max(window/time.Duration(left), time.Second)
+		slog.Warn("Run was down past its due slices. Compressing the remaining slices into the rest of the run time.", append(args, "interval", s.Interval.Round(time.Millisecond), "remaining_slices", left)...)
+	default:
+		slog.Warn("Run was down past its due slices. Extending the end of the run by the downtime.", append(args, "remaining_slices", left)...)
+	}
+	s.audit(AuditCaughtUp, "next_slice", s.NextSlice+1, "catch_up", policy, "downtime", downtime.Round(time.Second).String(),
+		"missed_slices", missed, "interval", s.Interval.Round(time.Millisecond).String())
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"strings"
+	"time"
+)
+
+// HTML chart layout in pixels
+const (
+	svgWidth       = 960
+	svgPanelHeight = 180
+	svgLeft        = 80
+	svgRight       = 20
+	svgTop         = 28
+	svgBottom      = 22
+	// maxChartPoints bounds the points drawn per series, longer series being thinned evenly
+	maxChartPoints = 2000
+)
+
+// chartPoint is a value of a chart series at a point in time
+type chartPoint struct {
+	Time  time.Time
+	Value float64
+}
+
+// chartSeries is a line of a chart panel
+type chartSeries struct {
+	Name  string
+	Color string
+	// Step draws the line in steps, holding each value until the next, as for positions
+	Step   bool
+	Dashed bool
+	Points []chartPoint
+}
+
+// chartMarker is a point highlighted on a panel, such as a fill, with a label shown on hover
+type chartMarker struct {
+	Time  time.Time
+	Value float64
+	Color string
+	Label string
+}
+
+// chartPanel is one chart of a report, sharing its time axis with the other panels
+type chartPanel struct {
+	Title   string
+	Series  []chartSeries
+	Markers []chartMarker
+}
+
+// renderChart renders panels as inline SVG stacked over a shared time axis, viewable in any browser without
+// scripts or a server. Marker labels show as tooltips.
+func renderChart(panels []chartPanel) template.HTML {
+	var start, end time.Time
+	extend := func(t time.Time) {
+		if start.IsZero() || t.Before(start) {
+			start = t
+		}
+		if end.IsZero() || t.After(end) {
+			end = t
+		}
+	}
+	for _, panel := range panels {
+		for _, series := range panel.Series {
+			for _, p := range series.Points {
+				extend(p.Time)
+			}
+		}
+		for _, m := range panel.Markers {
+			extend(m.Time)
+		}
+	}
+	if !end.After(start) {
+		end = start.Add(time.Second)
+	}
+	plotWidth := float64(svgWidth - svgLeft - svgRight)
+	x := func(t time.Time) float64 {
+		return svgLeft + float64(t.Sub(start))/float64(end.Sub(start))*plotWidth
+	}
+
+	var b strings.Builder
+	for _, panel := range panels {
+		low, high := math.Inf(1), math.Inf(-1)
+		for _, series := range panel.Series {
+			for _, p := range series.Points {
+				low, high = math.Min(low, p.Value), math.Max(high, p.Value)
+			}
+		}
+		for _, m := range panel.Markers {
+			low, high = math.Min(low, m.Value), math.Max(high, m.Value)
+		}
+		if math.IsInf(low, 0) {
+			continue
+		}
+		if high == low {
+			high, low = high+math.Max(math.Abs(high)*0.01, 1), low-math.Max(math.Abs(low)*0.01, 1)
+		}
+		plotHeight := float64(svgPanelHeight - svgTop - svgBottom)
+		y := func(v float64) float64 {
+			return svgTop + (high-v)/(high-low)*plotHeight
+		}
+
+		fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="11">`, svgWidth, svgPanelHeight)
+		fmt.Fprintf(&b, `<text x="%d" y="14" font-size="13" font-weight="bold">%s</text>`, svgLeft, html.EscapeString(panel.Title))
+		legend := float64(svgLeft) + 10 + float64(len(panel.Title))*8
+		for _, series := range panel.Series {
+			fmt.Fprintf(&b, `<text x="%.0f" y="14" fill="%s">%s</text>`, legend, series.Color, html.EscapeString(series.Name))
+			legend += float64(len(series.Name))*6.5 + 16
+		}
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.0f" height="%.0f" fill="none" stroke="#ccc"/>`, svgLeft, svgTop, plotWidth, plotHeight)
+		for i := range 3 {
+			v := low + (high-low)*float64(i)/2
+			fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" fill="#666">%s</text>`, svgLeft-6, y(v)+4, formatChartValue(v))
+			fmt.Fprintf(&b, `<line x1="%d" x2="%.0f" y1="%.1f" y2="%.1f" stroke="#eee"/>`, svgLeft, svgLeft+plotWidth, y(v), y(v))
+		}
+		for i := range 5 {
+			t := start.Add(time.Duration(float64(end.Sub(start)) * float64(i) / 4))
+			anchor := map[int]string{0: "start", 4: "end"}[i]
+			fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="%s" fill="#666">%s</text>`, x(t), svgPanelHeight-6,
+				map[bool]string{true: anchor, false: "middle"}[anchor != ""], t.UTC().Format("2006-01-02 15:04"))
+		}
+
+		for _, series := range panel.Series {
+			points := series.Points
+			if len(points) == 0 {
+				continue
+			}
+			if stride := len(points)/maxChartPoints + 1; stride > 1 {
+				var thinned []chartPoint
+				for i := 0; i < len(points); i += stride {
+					thinned = append(thinned, points[i])
+				}
+				points = append(thinned, points[len(points)-1])
+			}
+			var coords []string
+			for i, p := range points {
+				if series.Step && i > 0 {
+					coords = append(coords, fmt.Sprintf("%.1f,%.1f", x(p.Time), y(points[i-1].Value)))
+				}
+				coords = append(coords, fmt.Sprintf("%.1f,%.1f", x(p.Time), y(p.Value)))
+			}
+			dash := ""
+			if series.Dashed {
+				dash = ` stroke-dasharray="5,4"`
+			}
+			fmt.Fprintf(&b, `<polyline fill="none" stroke="%s" stroke-width="1.5"%s points="%s"/>`, series.Color, dash, strings.Join(coords, " "))
+		}
+		for _, m := range panel.Markers {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="4" fill="%s" stroke="#fff"><title>%s</title></circle>`, x(m.Time), y(m.Value), m.Color,
+				html.EscapeString(m.Label))
+		}
+		b.WriteString("</svg>\n")
+	}
+	return template.HTML(b.String())
+}
+
+// formatChartValue formats an axis value with a precision suited to its size
+func formatChartValue(v float64) string {
+	switch abs := math.Abs(v); {
+	case abs >= 1000:
+		return fmt.Sprintf("%.0f", v)
+	case abs >= 1:
+		return fmt.Sprintf("%.2f", v)
+	default:
+		return fmt.Sprintf("%.6g", v)
+	}
+}
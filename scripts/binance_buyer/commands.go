@@ -0,0 +1,571 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// runBalance prints the free balance of each requested asset
+func runBalance(args []string) error {
+	fs, common := newFlagSet("balance")
+	assets := fs.String("assets", "USDT", "Comma-separated assets to show (e.g., USDT,BTC)")
+	symbol := fs.String("symbol", "", "Symbol whose isolated margin account to show (with -market isolated-margin)")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	client, err := common.readOnlyClient()
+	if err != nil {
+		return err
+	}
+	client.BindIsolatedSymbol(*symbol)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ASSET\tFREE")
+	for _, asset := range strings.Split(*assets, ",") {
+		asset = strings.ToUpper(strings.TrimSpace(asset))
+		balance, err := client.GetBalance(asset)
+		if err != nil {
+			return fmt.Errorf("error getting %s balance: %v", asset, err)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", asset, balance)
+	}
+	return w.Flush()
+}
+
+// runPrice prints the last price and the best bid and ask of a symbol
+func runPrice(args []string) error {
+	fs, common := newFlagSet("price")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	client, err := common.readOnlyClient()
+	if err != nil {
+		return err
+	}
+
+	price, err := client.GetPrice(*symbol)
+	if err != nil {
+		return fmt.Errorf("error getting price for %s: %v", *symbol, err)
+	}
+	ticker, err := client.GetBookTicker(*symbol)
+	if err != nil {
+		return fmt.Errorf("error getting book ticker for %s: %v", *symbol, err)
+	}
+	fmt.Printf("%s last=%.8f bid=%.8f ask=%.8f spread=%.2f bps\n",
+		*symbol, price, ticker.BidPrice, ticker.AskPrice, (ticker.AskPrice-ticker.BidPrice)/ticker.Mid()*10000)
+	return nil
+}
+
+// runPosition prints the open futures position of a symbol
+func runPosition(args []string) error {
+	fs, common := newFlagSet("position")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if common.market != MarketFutures {
+		return fmt.Errorf("positions are only available with -market futures")
+	}
+	client, err := common.readOnlyClient()
+	if err != nil {
+		return err
+	}
+
+	position, err := client.GetPosition(*symbol)
+	if err != nil {
+		return fmt.Errorf("error getting position for %s: %v", *symbol, err)
+	}
+	fmt.Printf("%s amount=%s entry=%.8f mark=%.8f liquidation=%.8f unrealized_pnl=%s leverage=%dx margin=%s\n",
+		position.Symbol, position.Amount, position.EntryPrice, position.MarkPrice, position.LiquidationPrice,
+		position.UnrealizedPnL, position.Leverage, strings.ToLower(position.MarginType))
+	return nil
+}
+
+// runHistory prints the account's trades of a symbol over a period, fetched from the exchange page by page
+func runHistory(args []string) error {
+	fs, common := newFlagSet("history")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	since := fs.String("since", "1D", "Start of the trades to list, as a period back from now (e.g., 12H, 1D, 1W) or a date (e.g., 2024-01-01 or RFC 3339)")
+	until := fs.String("until", "", "End of the trades to list, as a date (e.g., 2024-02-01 or RFC 3339; empty lists up to now)")
+	format := fs.String("format", "table", "Output format: table, csv or json")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	sinceTime, err := parseDate(*since)
+	if err != nil {
+		period, durationErr := parseDuration(*since)
+		if durationErr != nil {
+			return fmt.Errorf("invalid since %q: use a period such as 1D or a date such as 2024-01-01", *since)
+		}
+		sinceTime = time.Now().Add(-period)
+	}
+	var untilTime time.Time
+	if *until != "" {
+		if untilTime, err = parseDate(*until); err != nil {
+			return err
+		}
+	}
+	if *format != "table" && *format != "csv" && *format != "json" {
+		return fmt.Errorf("invalid format: %s. Use table, csv or json", *format)
+	}
+	client, err := common.readOnlyClient()
+	if err != nil {
+		return err
+	}
+
+	trades, err := client.GetTrades(*symbol, sinceTime, untilTime)
+	if err != nil {
+		return fmt.Errorf("error getting trades for %s: %v", *symbol, err)
+	}
+	slices.SortStableFunc(trades, func(a, b Trade) int { return a.Time.Compare(b.Time) })
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(trades)
+	case "csv":
+		return writeTradesCSV(os.Stdout, trades)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tSIDE\tORDER ID\tPRICE\tQTY\tQUOTE QTY\tFEE")
+	for _, trade := range trades {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s %s\n", trade.Time.Format(time.RFC3339), trade.Side, trade.OrderID,
+			trade.Price, trade.Qty, trade.QuoteQty, trade.Commission, trade.CommissionAsset)
+	}
+	return w.Flush()
+}
+
+// writeTradesCSV writes trades as CSV with a header row
+func writeTradesCSV(out io.Writer, trades []Trade) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"time", "id", "order_id", "symbol", "side", "price", "qty", "quote_qty", "commission", "commission_asset", "account"})
+	for _, trade := range trades {
+		w.Write([]string{trade.Time.UTC().Format(time.RFC3339Nano), trade.ID, trade.OrderID, trade.Symbol, trade.Side,
+			trade.Price.String(), trade.Qty.String(), trade.QuoteQty.String(), trade.Commission.String(), trade.CommissionAsset, trade.Account})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// runCancelAll cancels every open order of a symbol
+func runCancelAll(args []string) error {
+	fs, common := newFlagSet("cancel-all")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+	history, err := orderHistory(client, common.exchange)
+	if err != nil {
+		return err
+	}
+
+	return cancelOpenOrders(client, history, *symbol)
+}
+
+// runOrdersList prints the open orders of a symbol
+func runOrdersList(args []string) error {
+	fs, common := newFlagSet("orders list")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	client, err := common.readOnlyClient()
+	if err != nil {
+		return err
+	}
+	client.BindIsolatedSymbol(*symbol)
+
+	orders, err := client.GetOpenOrders(*symbol)
+	if err != nil {
+		return fmt.Errorf("error listing open orders for %s: %v", *symbol, err)
+	}
+	return printOrders(orders)
+}
+
+// runOrderStatus prints the current state of an order
+func runOrderStatus(args []string) error {
+	fs, common := newFlagSet("orders status")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	orderID := fs.String("order-id", "", "Exchange ID of the order")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if *orderID == "" {
+		return fmt.Errorf("an order ID is required: set -order-id")
+	}
+	client, err := common.readOnlyClient()
+	if err != nil {
+		return err
+	}
+	client.BindIsolatedSymbol(*symbol)
+
+	order, err := client.GetOrder(*symbol, *orderID)
+	if err != nil {
+		return fmt.Errorf("error getting order %s: %v", *orderID, err)
+	}
+	return printOrders([]*Order{order})
+}
+
+// runOrderCancel cancels an order and prints its final state
+func runOrderCancel(args []string) error {
+	fs, common := newFlagSet("orders cancel")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	orderID := fs.String("order-id", "", "Exchange ID of the order")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if *orderID == "" {
+		return fmt.Errorf("an order ID is required: set -order-id")
+	}
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+	bindIsolatedSymbol(client, *symbol)
+
+	if err := client.CancelOrder(*symbol, *orderID); err != nil {
+		return fmt.Errorf("error cancelling order %s: %v", *orderID, err)
+	}
+	order, err := client.GetOrder(*symbol, *orderID)
+	if err != nil {
+		log.Printf("Cancelled order %s", *orderID)
+		return nil
+	}
+	return printOrders([]*Order{order})
+}
+
+// printOrders prints orders as a table
+func printOrders(orders []*Order) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CREATED\tORDER ID\tCLIENT ID\tSYMBOL\tSIDE\tTYPE\tPRICE\tQTY\tEXECUTED\tQUOTE QTY\tSTATUS")
+	for _, order := range orders {
+		created := ""
+		if !order.CreatedAt.IsZero() {
+			created = order.CreatedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", created, order.OrderID, order.ClientOrderID, order.Symbol,
+			order.Side, order.Type, order.Price, order.OrigQty, order.ExecutedQty, order.CumQuoteQty, order.Status)
+	}
+	return w.Flush()
+}
+
+// cancelOpenOrders cancels every open order of a symbol, carrying on past orders that cannot be cancelled
+func cancelOpenOrders(client ExchangeClient, history OrderHistory, symbol string) error {
+	orders, err := history.GetOpenOrders(symbol)
+	if err != nil {
+		return fmt.Errorf("error listing open orders for %s: %v", symbol, err)
+	}
+	var failed int
+	for _, order := range orders {
+		if err := client.CancelOrder(symbol, order.OrderID); err != nil {
+			log.Printf("Error cancelling order %s: %v", order.OrderID, err)
+			failed++
+			continue
+		}
+		log.Printf("Cancelled %s order %s: Price=%s, Qty=%s, ExecutedQty=%s", order.Side, order.OrderID, order.Price, order.OrigQty, order.ExecutedQty)
+	}
+	log.Printf("Cancelled %d of %d open order(s) for %s", len(orders)-failed, len(orders), symbol)
+	if failed > 0 {
+		return fmt.Errorf("%d order(s) could not be cancelled", failed)
+	}
+	return nil
+}
+
+// runReport prints the execution report of the run persisted in a state file
+func runReport(args []string) error {
+	fs, common := newFlagSet("report")
+	stateFile := fs.String("state-file", "binance_buyer_state.json", "State file of the run to report on")
+	reportPath := fs.String("report", "", "Also write the report to this file, as JSON or as an HTML page with charts when it ends in .html (e.g., report.html)")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	state, err := loadRunState(*stateFile)
+	if err != nil {
+		return fmt.Errorf("error loading run state: %v", err)
+	}
+	var client MarketReader
+	if strings.EqualFold(filepath.Ext(*reportPath), ".html") {
+		// The market prices charted are optional, so the report is written without them when there are no credentials
+		if reader, err := common.readOnlyClient(); err != nil {
+			log.Printf("Charting the fills without market prices: %v", err)
+		} else {
+			client = reader
+		}
+	}
+	reportRun(state, *reportPath, client)
+	return nil
+}
+
+// runDCACommand runs a recurring buy plan as a long-lived process, continuing the plan in the state file if there is one
+func runDCACommand(args []string) error {
+	fs, common := newFlagSet("dca")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	amount := fs.String("amount", "", "Quote amount to buy at every activation of the schedule (e.g., 100)")
+	schedule := fs.String("schedule", "", "Cron schedule of the buys: minute hour day-of-month month day-of-week (e.g., \"0 9 * * MON\") or @daily/@weekly/@monthly")
+	timezone := fs.String("timezone", "Local", "Time zone the schedule is evaluated in (e.g., UTC, Europe/London)")
+	catchUp := fs.Int("catch-up", 1, "Maximum number of buys missed while the process was not running to make up on start (0 to skip them)")
+	stateFile := fs.String("state-file", "binance_buyer_dca.json", "File the plan's last buy and totals are persisted to (empty to disable)")
+	journalPath := fs.String("journal", "", "Append every executed order to this CSV file (e.g., trades.csv)")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+
+	quoteAmount, err := ParseDecimal(*amount)
+	if err != nil || quoteAmount.Sign() <= 0 {
+		return fmt.Errorf("invalid amount %q: a positive quote amount is required", *amount)
+	}
+	if _, err := ParseCron(*schedule); err != nil {
+		return err
+	}
+	if *catchUp < 0 {
+		return fmt.Errorf("catch-up must not be negative")
+	}
+	quoteAsset := "USDT"
+	if !strings.HasSuffix(*symbol, quoteAsset) {
+		return fmt.Errorf("unsupported quote asset. Only %s quote pairs supported, got: %s", quoteAsset, *symbol)
+	}
+	cfg := DCAConfig{
+		Exchange:   strings.ToLower(common.exchange),
+		Testnet:    common.testnet,
+		Symbol:     *symbol,
+		QuoteAsset: quoteAsset,
+		Amount:     quoteAmount,
+		Schedule:   *schedule,
+		Timezone:   *timezone,
+		MaxCatchUp: *catchUp,
+	}
+
+	state := &DCAState{Config: cfg, StartedAt: time.Now()}
+	if previous, err := loadDCAState(*stateFile); err == nil {
+		if previous.Config.Exchange != cfg.Exchange || previous.Config.Symbol != cfg.Symbol {
+			return fmt.Errorf("state file %s belongs to a %s plan on %s. Use another -state-file", *stateFile, previous.Config.Symbol, previous.Config.Exchange)
+		}
+		log.Printf("Continuing DCA plan from %s: %d buy(s) so far, last scheduled buy at %s", *stateFile, previous.Buys, previous.LastRun.Format(time.RFC3339))
+		state = previous
+		state.Config = cfg
+	}
+
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+	if *journalPath != "" {
+		journal, err := OpenJournal(*journalPath)
+		if err != nil {
+			return err
+		}
+		defer journal.Close()
+		state.journal = journal
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Buying %s %s of %s on schedule %q (%s)", cfg.Amount, cfg.QuoteAsset, cfg.Symbol, cfg.Schedule, cfg.Timezone)
+	return runDCA(ctx, client, state, *stateFile)
+}
+
+// runRebalance trades the assets of a portfolio that drifted outside the tolerance band back to their target
+// allocations, selling before buying so the sales fund the buys
+func runRebalance(args []string) error {
+	fs, common := newFlagSet("rebalance")
+	targetSpec := fs.String("targets", "", "Comma-separated target allocations in percent, adding up to 100 (e.g., BTC:50,ETH:30,USDT:20)")
+	quoteAsset := fs.String("quote", "USDT", "Asset the portfolio is valued in and every trade goes through")
+	tolerance := fs.Float64("tolerance-pct", 2, "Percentage points an allocation may drift from its target before it is rebalanced")
+	twapDuration := fs.String("twap", "", "Execute each trade as a TWAP run over this duration (e.g., 30m) instead of a single market order")
+	dryRun := fs.Bool("dry-run", false, "Show the drift and the planned trades without placing orders")
+	journalPath := fs.String("journal", "", "Append every executed order to this CSV file (e.g., trades.csv)")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+
+	targets, err := parseTargets(*targetSpec)
+	if err != nil {
+		return err
+	}
+	if *tolerance < 0 {
+		return fmt.Errorf("tolerance-pct must not be negative")
+	}
+	var duration time.Duration
+	if *twapDuration != "" {
+		if duration, err = time.ParseDuration(*twapDuration); err != nil || duration <= 0 {
+			return fmt.Errorf("invalid twap duration %q", *twapDuration)
+		}
+	}
+	if common.market != MarketSpot && common.market != MarketMargin {
+		return fmt.Errorf("rebalancing is only supported on the spot and cross margin markets")
+	}
+	quote := strings.ToUpper(*quoteAsset)
+	// A dry run only plans, so it reads through a client that cannot place orders and works in read-only mode
+	var reader MarketReader
+	var client ExchangeClient
+	if *dryRun {
+		reader, err = common.readOnlyClient()
+	} else {
+		client, err = common.client()
+		reader = client
+	}
+	if err != nil {
+		return err
+	}
+
+	holdings, total, err := loadHoldings(reader, targets, quote)
+	if err != nil {
+		return err
+	}
+	trades, err := planRebalance(reader, holdings, total, quote, *tolerance)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "ASSET\tBALANCE\tVALUE (%s)\tCURRENT\tTARGET\tDRIFT\n", quote)
+	for _, h := range holdings {
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%.2f%%\t%.2f%%\t%+.2f\n", h.Asset, h.Balance, h.Value, h.CurrentPct, h.Pct, h.drift())
+	}
+	fmt.Fprintf(w, "TOTAL\t\t%.2f\t\t\t\n", total)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(trades) == 0 {
+		log.Printf("Every allocation is within %.2f percentage points of its target. Nothing to rebalance.", *tolerance)
+		return nil
+	}
+
+	fmt.Println()
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "SYMBOL\tSIDE\tAMOUNT\tVALUE (%s)\n", quote)
+	for _, trade := range trades {
+		amount := trade.Quote.String() + " " + quote
+		if trade.Side == "SELL" {
+			amount = trade.Quantity.String() + " " + strings.TrimSuffix(trade.Symbol, quote)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\n", trade.Symbol, trade.Side, amount, trade.Value)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if *dryRun {
+		log.Printf("Dry run: no orders placed")
+		return nil
+	}
+
+	var journal *Journal
+	if *journalPath != "" {
+		if journal, err = OpenJournal(*journalPath); err != nil {
+			return err
+		}
+		defer journal.Close()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := executeRebalance(ctx, client, trades, quote, duration, journal); err != nil {
+		return err
+	}
+	log.Printf("Rebalance complete")
+	return nil
+}
+
+// runPnL syncs the position ledger with the account's trades and reports the realized and unrealized profit
+// or loss of each symbol
+func runPnL(args []string) error {
+	fs, common := newFlagSet("pnl")
+	symbols := fs.String("symbols", "BTCUSDT", "Comma-separated symbols to report (e.g., BTCUSDT,ETHUSDT)")
+	since := fs.String("since", "", "Report profit or loss realized since this date (YYYY-MM-DD or RFC 3339, default: all)")
+	historyStart := fs.String("history-start", "", "Date the ledger starts collecting trades of a symbol from (YYYY-MM-DD or RFC 3339, default: a year before -since)")
+	method := fs.String("method", CostBasisFIFO, "Cost basis method: fifo (oldest lots sold first) or average")
+	ledgerPath := fs.String("ledger", "binance_buyer_ledger.json", "File the position ledger is kept in")
+	quoteAsset := fs.String("quote", "USDT", "Quote asset of the symbols, which profit and loss is reported in")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+
+	if *method != CostBasisFIFO && *method != CostBasisAverage {
+		return fmt.Errorf("invalid method %q: use fifo or average", *method)
+	}
+	var sinceTime time.Time
+	if *since != "" {
+		var err error
+		if sinceTime, err = parseDate(*since); err != nil {
+			return err
+		}
+	}
+	start := cmp.Or(sinceTime, time.Now()).AddDate(-1, 0, 0)
+	if *historyStart != "" {
+		var err error
+		if start, err = parseDate(*historyStart); err != nil {
+			return err
+		}
+	}
+	quote := strings.ToUpper(*quoteAsset)
+	client, err := common.readOnlyClient()
+	if err != nil {
+		return err
+	}
+	ledger, err := loadLedger(*ledgerPath, *method, quote)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "SYMBOL\tPOSITION\tAVG COST\tPRICE\tCOST BASIS\tREALIZED\tUNREALIZED\n")
+	var totalRealized, totalUnrealized Decimal
+	for _, symbol := range strings.Split(*symbols, ",") {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if !strings.HasSuffix(symbol, quote) {
+			return fmt.Errorf("symbol %s is not quoted in %s", symbol, quote)
+		}
+		applied, err := ledger.sync(client, symbol, start)
+		if saveErr := ledger.save(*ledgerPath); saveErr != nil {
+			return saveErr
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("Applied %d new trade(s) of %s to the ledger", applied, symbol)
+
+		price, err := client.GetPrice(symbol)
+		if err != nil {
+			return fmt.Errorf("error getting price for %s: %v", symbol, err)
+		}
+		position := ledger.position(symbol)
+		qty, cost := position.Holding()
+		unrealized := qty.Mul(NewDecimalFromFloat(price)).Sub(cost)
+		realized := position.RealizedSince(sinceTime)
+		totalRealized = totalRealized.Add(realized)
+		totalUnrealized = totalUnrealized.Add(unrealized)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.8g\t%s\t%s\t%s\n", symbol, qty, cost.Div(qty).StringFixed(8), price,
+			cost.StringFixed(2), realized.StringFixed(2), unrealized.StringFixed(2))
+	}
+	fmt.Fprintf(w, "TOTAL (%s)\t\t\t\t\t%s\t%s\n", quote, totalRealized.StringFixed(2), totalUnrealized.StringFixed(2))
+	return w.Flush()
+}
+
+// parseDate parses a date such as 2024-01-31, taken as midnight UTC, or an RFC 3339 timestamp
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: use YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// controlPollInterval is how often a paused run checks whether it was resumed
+const controlPollInterval = time.Second
+
+// controlEventBuffer bounds the events waiting to be streamed to a subscriber
+const controlEventBuffer = 100
+
+// dashboardHTML is the web dashboard served at the control server's root
+//
+//go:embed dashboard/index.html
+var dashboardHTML []byte
+
+// RunControl lets a running bot be paused, resumed, throttled or stopped early from outside the process
+type RunControl struct {
+	mu         sync.Mutex
+	paused     bool
+	sizeFactor float64
+	status     controlStatus
+	fills      []FillRecord
+	stop       context.CancelFunc
+	// token, when set, must accompany every request as a bearer token or token query parameter
+	token string
+	// subscribers receive the run's events for the event stream, until done is closed on shutdown
+	subscribers map[chan WebhookEvent]struct{}
+	done        chan struct{}
+	// benchmark, when set, computes the market benchmark of the running run live for the status endpoint
+	benchmark func() MarketBenchmark
+	// budget is the budget change waiting for the run's next slice, accepted while budgetOpen is set
+	budget     *budgetChange
+	budgetOpen bool
+}
+
+// controlStatus is the progress snapshot reported by the status endpoint
+type controlStatus struct {
+	RunID            string  `json:"run_id"`
+	Symbol           string  `json:"symbol"`
+	Side             string  `json:"side"`
+	Paused           bool    `json:"paused"`
+	SizeFactor       float64 `json:"size_factor"`
+	NextSlice        int     `json:"next_slice"`
+	TotalSlices      int     `json:"total_slices"`
+	TargetAmount     Decimal `json:"target_amount"`
+	RemainingBudget  Decimal `json:"remaining_budget"`
+	BudgetAsset      string  `json:"budget_asset"`
+	FilledBase       Decimal `json:"filled_base"`
+	FilledQuote      Decimal `json:"filled_quote"`
+	AverageFillPrice float64 `json:"average_fill_price"`
+	MarketTWAP       float64 `json:"market_twap"`
+	MarketVWAP       float64 `json:"market_vwap"`
+	LastPrice        float64 `json:"last_price"`
+	SlippageBps      float64 `json:"slippage_bps"`
+	OpenOrders       int     `json:"open_orders"`
+	// BudgetChangePending is set while a budget change waits for the run's next slice
+	BudgetChangePending bool `json:"budget_change_pending"`
+}
+
+// NewRunControl creates a control that cancels the run through stop and, when token is set, only accepts
+// requests carrying it
+func NewRunControl(stop context.CancelFunc, token string) *RunControl {
+	return &RunControl{sizeFactor: 1, stop: stop, token: token, subscribers: map[chan WebhookEvent]struct{}{}, done: make(chan struct{})}
+}
+
+// Serve starts the control server on a TCP address (e.g., localhost:8080) or a Unix socket (unix:/path/to.sock)
+// and returns a function that shuts it down
+func (c *RunControl) Serve(addr string) (func(), error) {
+	listener, err := controlListener(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/pause", c.handlePause)
+	mux.HandleFunc("/resume", c.handleResume)
+	mux.HandleFunc("/throttle", c.handleThrottle)
+	mux.HandleFunc("/stop", c.handleStop)
+	mux.HandleFunc("/status", c.handleStatus)
+	mux.HandleFunc("/events", c.handleEvents)
+	mux.HandleFunc("/fills", c.handleFills)
+	mux.HandleFunc("PATCH /runs/{id}/budget", c.handleBudget)
+	mux.HandleFunc("/{$}", c.handleDashboard)
+
+	server := &http.Server{Handler: c.authorize(mux), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Control server stopped: %v", err)
+		}
+	}()
+	log.Printf("Control server listening on %s", addr)
+
+	return func() {
+		close(c.done)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
+
+// handlePause pauses slice scheduling
+func (c *RunControl) handlePause(w http.ResponseWriter, r *http.Request) {
+	c.pause()
+	log.Printf("Run paused via control server")
+	c.handleStatus(w, r)
+}
+
+// handleResume resumes slice scheduling
+func (c *RunControl) handleResume(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	c.paused = false
+	c.mu.Unlock()
+	log.Printf("Run resumed via control server")
+	c.handleStatus(w, r)
+}
+
+// handleThrottle scales the size of the following slices by the factor query parameter
+func (c *RunControl) handleThrottle(w http.ResponseWriter, r *http.Request) {
+	factor, err := strconv.ParseFloat(r.URL.Query().Get("factor"), 64)
+	if err != nil || factor <= 0 || factor > 1 {
+		http.Error(w, "factor must be a number in (0, 1]", http.StatusBadRequest)
+		return
+	}
+	c.mu.Lock()
+	c.sizeFactor = factor
+	c.mu.Unlock()
+	log.Printf("Slice size factor set to %.2f via control server", factor)
+	c.handleStatus(w, r)
+}
+
+// handleStop stops the run early, saving its state so it can be resumed
+func (c *RunControl) handleStop(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Stop requested via control server")
+	c.stop()
+	c.handleStatus(w, r)
+}
+
+// handleStatus reports the run's progress
+func (c *RunControl) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	c.mu.Lock()
+	status := c.status
+	status.Paused = c.paused
+	status.SizeFactor = c.sizeFactor
+	status.BudgetChangePending = c.budget != nil
+	benchmark := c.benchmark
+	c.mu.Unlock()
+	if benchmark != nil {
+		if live := benchmark(); live.Trades > 0 {
+			status.MarketTWAP, status.MarketVWAP = live.TWAP(), live.VWAP()
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// controlListener listens on a TCP address (e.g., localhost:8080) or a Unix socket (unix:/path/to.sock),
+// replacing a socket left behind by a previous process
+func controlListener(addr string) (net.Listener, error) {
+	network, address := "tcp", addr
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, address = "unix", path
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("error removing stale control socket: %v", err)
+		}
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("error starting control server: %v", err)
+	}
+	return listener, nil
+}
+
+// controlHTTPClient returns a client for the server listening on a TCP address (e.g., localhost:8080) or a Unix
+// socket (unix:/path/to.sock), along with the base URL of its requests
+func controlHTTPClient(addr string) (*http.Client, string) {
+	client := &http.Client{}
+	path, ok := strings.CutPrefix(addr, "unix:")
+	if !ok {
+		return client, "http://" + addr
+	}
+	client.Transport = &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "unix", path)
+	}}
+	return client, "http://control"
+}
+
+// authorize rejects requests without the control token
+func (c *RunControl) authorize(next http.Handler) http.Handler {
+	return requireToken(c.token, next)
+}
+
+// requireToken rejects requests without token, when set, accepted as an "Authorization: Bearer" header or,
+// for opening a page in a browser, a token query parameter
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			given := r.URL.Query().Get("token")
+			if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+				given = bearer
+			}
+			if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				http.Error(w, "invalid or missing control token", http.StatusUnauthorized)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleDashboard serves the web dashboard
+func (c *RunControl) handleDashboard(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(dashboardHTML)
+}
+
+// handleFills reports the run's fills
+func (c *RunControl) handleFills(w http.ResponseWriter, _ *http.Request) {
+	c.mu.Lock()
+	fills := c.fills
+	c.mu.Unlock()
+	if fills == nil {
+		fills = []FillRecord{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(fills)
+}
+
+// handleEvents streams the run's events as newline-delimited JSON until the client disconnects or the server
+// shuts down
+func (c *RunControl) handleEvents(w http.ResponseWriter, r *http.Request) {
+	events := make(chan WebhookEvent, controlEventBuffer)
+	c.mu.Lock()
+	c.subscribers[events] = struct{}{}
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.subscribers, events)
+		c.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case event := <-events:
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		case <-c.done:
+			// Send the events of the run's last moments before closing the stream
+			for {
+				select {
+				case event := <-events:
+					if encoder.Encode(event) != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// publish sends an event to the event stream's subscribers, dropping it for subscribers that fall behind so a
+// slow client never delays the run. A nil control is a no-op.
+func (c *RunControl) publish(event notifyEvent) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.subscribers) == 0 {
+		return
+	}
+	payload := newWebhookEvent(event)
+	for events := range c.subscribers {
+		select {
+		case events <- payload:
+		default:
+			log.Printf("Event stream subscriber is falling behind. Dropping %s event.", payload.Type)
+		}
+	}
+}
+
+// pause pauses slice scheduling until the run is resumed through the control server. A nil control is a no-op.
+func (c *RunControl) pause() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.paused = true
+	c.mu.Unlock()
+}
+
+// update records the run's progress for the status endpoint. A nil control is a no-op.
+func (c *RunControl) update(state *RunState) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.status = controlStatus{
+		RunID:            state.RunID,
+		Symbol:           state.Config.Symbol,
+		Side:             state.Config.Side,
+		NextSlice:        state.NextSlice,
+		TotalSlices:      state.TotalSlices,
+		TargetAmount:     state.targetAmount(),
+		RemainingBudget:  state.Remaining,
+		BudgetAsset:      state.Config.budgetAsset(),
+		FilledBase:       state.FilledBase,
+		FilledQuote:      state.FilledQuote,
+		AverageFillPrice: state.averageFillPrice(),
+		LastPrice:        state.LastPrice,
+		SlippageBps:      state.cumulativeSlippageBps(),
+		OpenOrders:       len(state.OpenOrders),
+	}
+	c.status.MarketTWAP = state.marketTWAP()
+	c.status.MarketVWAP = state.Benchmark.VWAP()
+	c.benchmark = nil
+	if !state.Completed {
+		c.benchmark = state.liveBenchmark()
+	}
+	c.fills = slices.Clone(state.FillLog)
+}
+
+// throttle scales a slice amount by the current size factor. A nil control leaves it unchanged.
+func (c *RunControl) throttle(amount Decimal) Decimal {
+	if c == nil {
+		return amount
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return amount.MulFloat(c.sizeFactor)
+}
+
+// waitWhilePaused blocks while the run is paused, reporting false if ctx is cancelled meanwhile.
+// A nil control never pauses.
+func (c *RunControl) waitWhilePaused(ctx context.Context) bool {
+	if c == nil {
+		return true
+	}
+	logged := false
+	for {
+		c.mu.Lock()
+		paused := c.paused
+		c.mu.Unlock()
+		if !paused {
+			return true
+		}
+		if !logged {
+			log.Printf("Run is paused. Waiting for resume.")
+			logged = true
+		}
+		if !sleepContext(ctx, controlPollInterval) {
+			return false
+		}
+	}
+}
@@ -0,0 +1,726 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: control.proto
+
+package controlpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type StartRunRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// name of the job
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// args are the trade exec arguments of the run, e.g. ["-symbol", "BTCUSDT", "-total-amount", "100"]
+	Args []string `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+	// schedule is a cron schedule starting a new run at every activation; empty runs the job once
+	Schedule      string `protobuf:"bytes,3,opt,name=schedule,proto3" json:"schedule,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StartRunRequest) Reset() {
+	*x = StartRunRequest{}
+	mi := &file_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StartRunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StartRunRequest) ProtoMessage() {}
+
+func (x *StartRunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StartRunRequest.ProtoReflect.Descriptor instead.
+func (*StartRunRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StartRunRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *StartRunRequest) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *StartRunRequest) GetSchedule() string {
+	if x != nil {
+		return x.Schedule
+	}
+	return ""
+}
+
+type RunRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// id is the daemon's ID of the job
+	Id            string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RunRequest) Reset() {
+	*x = RunRequest{}
+	mi := &file_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunRequest) ProtoMessage() {}
+
+func (x *RunRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunRequest.ProtoReflect.Descriptor instead.
+func (*RunRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *RunRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type Run struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Id       string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name     string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Args     []string               `protobuf:"bytes,3,rep,name=args,proto3" json:"args,omitempty"`
+	Schedule string                 `protobuf:"bytes,4,opt,name=schedule,proto3" json:"schedule,omitempty"`
+	// source is where the job was submitted from: config or api
+	Source string `protobuf:"bytes,5,opt,name=source,proto3" json:"source,omitempty"`
+	// status is queued, scheduled, running, completed, failed or cancelled
+	Status    string                 `protobuf:"bytes,6,opt,name=status,proto3" json:"status,omitempty"`
+	CreatedAt *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	StartedAt *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	EndedAt   *timestamppb.Timestamp `protobuf:"bytes,9,opt,name=ended_at,json=endedAt,proto3" json:"ended_at,omitempty"`
+	NextRun   *timestamppb.Timestamp `protobuf:"bytes,10,opt,name=next_run,json=nextRun,proto3" json:"next_run,omitempty"`
+	Runs      int32                  `protobuf:"varint,11,opt,name=runs,proto3" json:"runs,omitempty"`
+	// last_result is how the job's last run ended: completed, failed or cancelled
+	LastResult string `protobuf:"bytes,12,opt,name=last_result,json=lastResult,proto3" json:"last_result,omitempty"`
+	Error      string `protobuf:"bytes,13,opt,name=error,proto3" json:"error,omitempty"`
+	// progress is the running run's progress, unset while the job does not run
+	Progress      *RunProgress `protobuf:"bytes,14,opt,name=progress,proto3" json:"progress,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Run) Reset() {
+	*x = Run{}
+	mi := &file_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Run) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Run) ProtoMessage() {}
+
+func (x *Run) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Run.ProtoReflect.Descriptor instead.
+func (*Run) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Run) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Run) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Run) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *Run) GetSchedule() string {
+	if x != nil {
+		return x.Schedule
+	}
+	return ""
+}
+
+func (x *Run) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Run) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Run) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Run) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *Run) GetEndedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndedAt
+	}
+	return nil
+}
+
+func (x *Run) GetNextRun() *timestamppb.Timestamp {
+	if x != nil {
+		return x.NextRun
+	}
+	return nil
+}
+
+func (x *Run) GetRuns() int32 {
+	if x != nil {
+		return x.Runs
+	}
+	return 0
+}
+
+func (x *Run) GetLastResult() string {
+	if x != nil {
+		return x.LastResult
+	}
+	return ""
+}
+
+func (x *Run) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Run) GetProgress() *RunProgress {
+	if x != nil {
+		return x.Progress
+	}
+	return nil
+}
+
+// RunProgress is the progress a run's control server reports. Amounts are decimal strings.
+type RunProgress struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	RunId               string                 `protobuf:"bytes,1,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	Symbol              string                 `protobuf:"bytes,2,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Side                string                 `protobuf:"bytes,3,opt,name=side,proto3" json:"side,omitempty"`
+	Paused              bool                   `protobuf:"varint,4,opt,name=paused,proto3" json:"paused,omitempty"`
+	SizeFactor          float64                `protobuf:"fixed64,5,opt,name=size_factor,json=sizeFactor,proto3" json:"size_factor,omitempty"`
+	NextSlice           int32                  `protobuf:"varint,6,opt,name=next_slice,json=nextSlice,proto3" json:"next_slice,omitempty"`
+	TotalSlices         int32                  `protobuf:"varint,7,opt,name=total_slices,json=totalSlices,proto3" json:"total_slices,omitempty"`
+	TargetAmount        string                 `protobuf:"bytes,8,opt,name=target_amount,json=targetAmount,proto3" json:"target_amount,omitempty"`
+	RemainingBudget     string                 `protobuf:"bytes,9,opt,name=remaining_budget,json=remainingBudget,proto3" json:"remaining_budget,omitempty"`
+	BudgetAsset         string                 `protobuf:"bytes,10,opt,name=budget_asset,json=budgetAsset,proto3" json:"budget_asset,omitempty"`
+	FilledBase          string                 `protobuf:"bytes,11,opt,name=filled_base,json=filledBase,proto3" json:"filled_base,omitempty"`
+	FilledQuote         string                 `protobuf:"bytes,12,opt,name=filled_quote,json=filledQuote,proto3" json:"filled_quote,omitempty"`
+	AverageFillPrice    float64                `protobuf:"fixed64,13,opt,name=average_fill_price,json=averageFillPrice,proto3" json:"average_fill_price,omitempty"`
+	MarketTwap          float64                `protobuf:"fixed64,14,opt,name=market_twap,json=marketTwap,proto3" json:"market_twap,omitempty"`
+	MarketVwap          float64                `protobuf:"fixed64,15,opt,name=market_vwap,json=marketVwap,proto3" json:"market_vwap,omitempty"`
+	LastPrice           float64                `protobuf:"fixed64,16,opt,name=last_price,json=lastPrice,proto3" json:"last_price,omitempty"`
+	SlippageBps         float64                `protobuf:"fixed64,17,opt,name=slippage_bps,json=slippageBps,proto3" json:"slippage_bps,omitempty"`
+	OpenOrders          int32                  `protobuf:"varint,18,opt,name=open_orders,json=openOrders,proto3" json:"open_orders,omitempty"`
+	BudgetChangePending bool                   `protobuf:"varint,19,opt,name=budget_change_pending,json=budgetChangePending,proto3" json:"budget_change_pending,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *RunProgress) Reset() {
+	*x = RunProgress{}
+	mi := &file_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RunProgress) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RunProgress) ProtoMessage() {}
+
+func (x *RunProgress) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RunProgress.ProtoReflect.Descriptor instead.
+func (*RunProgress) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RunProgress) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *RunProgress) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *RunProgress) GetSide() string {
+	if x != nil {
+		return x.Side
+	}
+	return ""
+}
+
+func (x *RunProgress) GetPaused() bool {
+	if x != nil {
+		return x.Paused
+	}
+	return false
+}
+
+func (x *RunProgress) GetSizeFactor() float64 {
+	if x != nil {
+		return x.SizeFactor
+	}
+	return 0
+}
+
+func (x *RunProgress) GetNextSlice() int32 {
+	if x != nil {
+		return x.NextSlice
+	}
+	return 0
+}
+
+func (x *RunProgress) GetTotalSlices() int32 {
+	if x != nil {
+		return x.TotalSlices
+	}
+	return 0
+}
+
+func (x *RunProgress) GetTargetAmount() string {
+	if x != nil {
+		return x.TargetAmount
+	}
+	return ""
+}
+
+func (x *RunProgress) GetRemainingBudget() string {
+	if x != nil {
+		return x.RemainingBudget
+	}
+	return ""
+}
+
+func (x *RunProgress) GetBudgetAsset() string {
+	if x != nil {
+		return x.BudgetAsset
+	}
+	return ""
+}
+
+func (x *RunProgress) GetFilledBase() string {
+	if x != nil {
+		return x.FilledBase
+	}
+	return ""
+}
+
+func (x *RunProgress) GetFilledQuote() string {
+	if x != nil {
+		return x.FilledQuote
+	}
+	return ""
+}
+
+func (x *RunProgress) GetAverageFillPrice() float64 {
+	if x != nil {
+		return x.AverageFillPrice
+	}
+	return 0
+}
+
+func (x *RunProgress) GetMarketTwap() float64 {
+	if x != nil {
+		return x.MarketTwap
+	}
+	return 0
+}
+
+func (x *RunProgress) GetMarketVwap() float64 {
+	if x != nil {
+		return x.MarketVwap
+	}
+	return 0
+}
+
+func (x *RunProgress) GetLastPrice() float64 {
+	if x != nil {
+		return x.LastPrice
+	}
+	return 0
+}
+
+func (x *RunProgress) GetSlippageBps() float64 {
+	if x != nil {
+		return x.SlippageBps
+	}
+	return 0
+}
+
+func (x *RunProgress) GetOpenOrders() int32 {
+	if x != nil {
+		return x.OpenOrders
+	}
+	return 0
+}
+
+func (x *RunProgress) GetBudgetChangePending() bool {
+	if x != nil {
+		return x.BudgetChangePending
+	}
+	return false
+}
+
+// Event is a run event, in the payload format of -webhook-url
+type Event struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// type is the event type, e.g. order_placed, order_filled or run_completed
+	Type   string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Time   *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=time,proto3" json:"time,omitempty"`
+	RunId  string                 `protobuf:"bytes,4,opt,name=run_id,json=runId,proto3" json:"run_id,omitempty"`
+	Symbol string                 `protobuf:"bytes,5,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	Side   string                 `protobuf:"bytes,6,opt,name=side,proto3" json:"side,omitempty"`
+	Data   *structpb.Struct       `protobuf:"bytes,7,opt,name=data,proto3" json:"data,omitempty"`
+	// job_id is the daemon's ID of the job the run belongs to
+	JobId         string `protobuf:"bytes,8,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Event) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+func (x *Event) GetRunId() string {
+	if x != nil {
+		return x.RunId
+	}
+	return ""
+}
+
+func (x *Event) GetSymbol() string {
+	if x != nil {
+		return x.Symbol
+	}
+	return ""
+}
+
+func (x *Event) GetSide() string {
+	if x != nil {
+		return x.Side
+	}
+	return ""
+}
+
+func (x *Event) GetData() *structpb.Struct {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Event) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+var File_control_proto protoreflect.FileDescriptor
+
+const file_control_proto_rawDesc = "" +
+	"\n" +
+	"\rcontrol.proto\x12\x17binancebuyer.control.v1\x1a\x1cgoogle/protobuf/struct.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"U\n" +
+	"\x0fStartRunRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04args\x18\x02 \x03(\tR\x04args\x12\x1a\n" +
+	"\bschedule\x18\x03 \x01(\tR\bschedule\"\x1c\n" +
+	"\n" +
+	"RunRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\"\xfa\x03\n" +
+	"\x03Run\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04args\x18\x03 \x03(\tR\x04args\x12\x1a\n" +
+	"\bschedule\x18\x04 \x01(\tR\bschedule\x12\x16\n" +
+	"\x06source\x18\x05 \x01(\tR\x06source\x12\x16\n" +
+	"\x06status\x18\x06 \x01(\tR\x06status\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"started_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x125\n" +
+	"\bended_at\x18\t \x01(\v2\x1a.google.protobuf.TimestampR\aendedAt\x125\n" +
+	"\bnext_run\x18\n" +
+	" \x01(\v2\x1a.google.protobuf.TimestampR\anextRun\x12\x12\n" +
+	"\x04runs\x18\v \x01(\x05R\x04runs\x12\x1f\n" +
+	"\vlast_result\x18\f \x01(\tR\n" +
+	"lastResult\x12\x14\n" +
+	"\x05error\x18\r \x01(\tR\x05error\x12@\n" +
+	"\bprogress\x18\x0e \x01(\v2$.binancebuyer.control.v1.RunProgressR\bprogress\"\x89\x05\n" +
+	"\vRunProgress\x12\x15\n" +
+	"\x06run_id\x18\x01 \x01(\tR\x05runId\x12\x16\n" +
+	"\x06symbol\x18\x02 \x01(\tR\x06symbol\x12\x12\n" +
+	"\x04side\x18\x03 \x01(\tR\x04side\x12\x16\n" +
+	"\x06paused\x18\x04 \x01(\bR\x06paused\x12\x1f\n" +
+	"\vsize_factor\x18\x05 \x01(\x01R\n" +
+	"sizeFactor\x12\x1d\n" +
+	"\n" +
+	"next_slice\x18\x06 \x01(\x05R\tnextSlice\x12!\n" +
+	"\ftotal_slices\x18\a \x01(\x05R\vtotalSlices\x12#\n" +
+	"\rtarget_amount\x18\b \x01(\tR\ftargetAmount\x12)\n" +
+	"\x10remaining_budget\x18\t \x01(\tR\x0fremainingBudget\x12!\n" +
+	"\fbudget_asset\x18\n" +
+	" \x01(\tR\vbudgetAsset\x12\x1f\n" +
+	"\vfilled_base\x18\v \x01(\tR\n" +
+	"filledBase\x12!\n" +
+	"\ffilled_quote\x18\f \x01(\tR\vfilledQuote\x12,\n" +
+	"\x12average_fill_price\x18\r \x01(\x01R\x10averageFillPrice\x12\x1f\n" +
+	"\vmarket_twap\x18\x0e \x01(\x01R\n" +
+	"marketTwap\x12\x1f\n" +
+	"\vmarket_vwap\x18\x0f \x01(\x01R\n" +
+	"marketVwap\x12\x1d\n" +
+	"\n" +
+	"last_price\x18\x10 \x01(\x01R\tlastPrice\x12!\n" +
+	"\fslippage_bps\x18\x11 \x01(\x01R\vslippageBps\x12\x1f\n" +
+	"\vopen_orders\x18\x12 \x01(\x05R\n" +
+	"openOrders\x122\n" +
+	"\x15budget_change_pending\x18\x13 \x01(\bR\x13budgetChangePending\"\xe2\x01\n" +
+	"\x05Event\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12.\n" +
+	"\x04time\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\x12\x15\n" +
+	"\x06run_id\x18\x04 \x01(\tR\x05runId\x12\x16\n" +
+	"\x06symbol\x18\x05 \x01(\tR\x06symbol\x12\x12\n" +
+	"\x04side\x18\x06 \x01(\tR\x04side\x12+\n" +
+	"\x04data\x18\a \x01(\v2\x17.google.protobuf.StructR\x04data\x12\x15\n" +
+	"\x06job_id\x18\b \x01(\tR\x05jobId2\xf6\x03\n" +
+	"\n" +
+	"RunService\x12R\n" +
+	"\bStartRun\x12(.binancebuyer.control.v1.StartRunRequest\x1a\x1c.binancebuyer.control.v1.Run\x12M\n" +
+	"\bPauseRun\x12#.binancebuyer.control.v1.RunRequest\x1a\x1c.binancebuyer.control.v1.Run\x12N\n" +
+	"\tResumeRun\x12#.binancebuyer.control.v1.RunRequest\x1a\x1c.binancebuyer.control.v1.Run\x12N\n" +
+	"\tCancelRun\x12#.binancebuyer.control.v1.RunRequest\x1a\x1c.binancebuyer.control.v1.Run\x12N\n" +
+	"\tGetStatus\x12#.binancebuyer.control.v1.RunRequest\x1a\x1c.binancebuyer.control.v1.Run\x12U\n" +
+	"\fStreamEvents\x12#.binancebuyer.control.v1.RunRequest\x1a\x1e.binancebuyer.control.v1.Event0\x01BCZAgithub.com/agamkapur/algo-trading/scripts/binance_buyer/controlpbb\x06proto3"
+
+var (
+	file_control_proto_rawDescOnce sync.Once
+	file_control_proto_rawDescData []byte
+)
+
+func file_control_proto_rawDescGZIP() []byte {
+	file_control_proto_rawDescOnce.Do(func() {
+		file_control_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)))
+	})
+	return file_control_proto_rawDescData
+}
+
+var file_control_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_control_proto_goTypes = []any{
+	(*StartRunRequest)(nil),       // 0: binancebuyer.control.v1.StartRunRequest
+	(*RunRequest)(nil),            // 1: binancebuyer.control.v1.RunRequest
+	(*Run)(nil),                   // 2: binancebuyer.control.v1.Run
+	(*RunProgress)(nil),           // 3: binancebuyer.control.v1.RunProgress
+	(*Event)(nil),                 // 4: binancebuyer.control.v1.Event
+	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+	(*structpb.Struct)(nil),       // 6: google.protobuf.Struct
+}
+var file_control_proto_depIdxs = []int32{
+	5,  // 0: binancebuyer.control.v1.Run.created_at:type_name -> google.protobuf.Timestamp
+	5,  // 1: binancebuyer.control.v1.Run.started_at:type_name -> google.protobuf.Timestamp
+	5,  // 2: binancebuyer.control.v1.Run.ended_at:type_name -> google.protobuf.Timestamp
+	5,  // 3: binancebuyer.control.v1.Run.next_run:type_name -> google.protobuf.Timestamp
+	3,  // 4: binancebuyer.control.v1.Run.progress:type_name -> binancebuyer.control.v1.RunProgress
+	5,  // 5: binancebuyer.control.v1.Event.time:type_name -> google.protobuf.Timestamp
+	6,  // 6: binancebuyer.control.v1.Event.data:type_name -> google.protobuf.Struct
+	0,  // 7: binancebuyer.control.v1.RunService.StartRun:input_type -> binancebuyer.control.v1.StartRunRequest
+	1,  // 8: binancebuyer.control.v1.RunService.PauseRun:input_type -> binancebuyer.control.v1.RunRequest
+	1,  // 9: binancebuyer.control.v1.RunService.ResumeRun:input_type -> binancebuyer.control.v1.RunRequest
+	1,  // 10: binancebuyer.control.v1.RunService.CancelRun:input_type -> binancebuyer.control.v1.RunRequest
+	1,  // 11: binancebuyer.control.v1.RunService.GetStatus:input_type -> binancebuyer.control.v1.RunRequest
+	1,  // 12: binancebuyer.control.v1.RunService.StreamEvents:input_type -> binancebuyer.control.v1.RunRequest
+	2,  // 13: binancebuyer.control.v1.RunService.StartRun:output_type -> binancebuyer.control.v1.Run
+	2,  // 14: binancebuyer.control.v1.RunService.PauseRun:output_type -> binancebuyer.control.v1.Run
+	2,  // 15: binancebuyer.control.v1.RunService.ResumeRun:output_type -> binancebuyer.control.v1.Run
+	2,  // 16: binancebuyer.control.v1.RunService.CancelRun:output_type -> binancebuyer.control.v1.Run
+	2,  // 17: binancebuyer.control.v1.RunService.GetStatus:output_type -> binancebuyer.control.v1.Run
+	4,  // 18: binancebuyer.control.v1.RunService.StreamEvents:output_type -> binancebuyer.control.v1.Event
+	13, // [13:19] is the sub-list for method output_type
+	7,  // [7:13] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_control_proto_init() }
+func file_control_proto_init() {
+	if File_control_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_control_proto_goTypes,
+		DependencyIndexes: file_control_proto_depIdxs,
+		MessageInfos:      file_control_proto_msgTypes,
+	}.Build()
+	File_control_proto = out.File
+	file_control_proto_goTypes = nil
+	file_control_proto_depIdxs = nil
+}
@@ -0,0 +1,335 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: control.proto
+
+package controlpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	RunService_StartRun_FullMethodName     = "/binancebuyer.control.v1.RunService/StartRun"
+	RunService_PauseRun_FullMethodName     = "/binancebuyer.control.v1.RunService/PauseRun"
+	RunService_ResumeRun_FullMethodName    = "/binancebuyer.control.v1.RunService/ResumeRun"
+	RunService_CancelRun_FullMethodName    = "/binancebuyer.control.v1.RunService/CancelRun"
+	RunService_GetStatus_FullMethodName    = "/binancebuyer.control.v1.RunService/GetStatus"
+	RunService_StreamEvents_FullMethodName = "/binancebuyer.control.v1.RunService/StreamEvents"
+)
+
+// RunServiceClient is the client API for RunService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// RunService orchestrates the execution runs of a trade daemon. Each run is a daemon job; its progress, pausing
+// and events come from the control server of the job's trade exec process while it runs.
+type RunServiceClient interface {
+	// StartRun submits a job, queued to run as soon as the daemon has a free slot or, with a schedule, at its next
+	// activation
+	StartRun(ctx context.Context, in *StartRunRequest, opts ...grpc.CallOption) (*Run, error)
+	// PauseRun pauses slice scheduling of a running job
+	PauseRun(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error)
+	// ResumeRun resumes slice scheduling of a paused job
+	ResumeRun(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error)
+	// CancelRun cancels a job, stopping its run if it is running
+	CancelRun(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error)
+	// GetStatus reports a job and, while it runs, its progress
+	GetStatus(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error)
+	// StreamEvents streams the events of a job's runs until the job ends or the client disconnects
+	StreamEvents(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+}
+
+type runServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRunServiceClient(cc grpc.ClientConnInterface) RunServiceClient {
+	return &runServiceClient{cc}
+}
+
+func (c *runServiceClient) StartRun(ctx context.Context, in *StartRunRequest, opts ...grpc.CallOption) (*Run, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Run)
+	err := c.cc.Invoke(ctx, RunService_StartRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runServiceClient) PauseRun(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Run)
+	err := c.cc.Invoke(ctx, RunService_PauseRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runServiceClient) ResumeRun(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Run)
+	err := c.cc.Invoke(ctx, RunService_ResumeRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runServiceClient) CancelRun(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Run)
+	err := c.cc.Invoke(ctx, RunService_CancelRun_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runServiceClient) GetStatus(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*Run, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Run)
+	err := c.cc.Invoke(ctx, RunService_GetStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *runServiceClient) StreamEvents(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &RunService_ServiceDesc.Streams[0], RunService_StreamEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[RunRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RunService_StreamEventsClient = grpc.ServerStreamingClient[Event]
+
+// RunServiceServer is the server API for RunService service.
+// All implementations must embed UnimplementedRunServiceServer
+// for forward compatibility.
+//
+// RunService orchestrates the execution runs of a trade daemon. Each run is a daemon job; its progress, pausing
+// and events come from the control server of the job's trade exec process while it runs.
+type RunServiceServer interface {
+	// StartRun submits a job, queued to run as soon as the daemon has a free slot or, with a schedule, at its next
+	// activation
+	StartRun(context.Context, *StartRunRequest) (*Run, error)
+	// PauseRun pauses slice scheduling of a running job
+	PauseRun(context.Context, *RunRequest) (*Run, error)
+	// ResumeRun resumes slice scheduling of a paused job
+	ResumeRun(context.Context, *RunRequest) (*Run, error)
+	// CancelRun cancels a job, stopping its run if it is running
+	CancelRun(context.Context, *RunRequest) (*Run, error)
+	// GetStatus reports a job and, while it runs, its progress
+	GetStatus(context.Context, *RunRequest) (*Run, error)
+	// StreamEvents streams the events of a job's runs until the job ends or the client disconnects
+	StreamEvents(*RunRequest, grpc.ServerStreamingServer[Event]) error
+	mustEmbedUnimplementedRunServiceServer()
+}
+
+// UnimplementedRunServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedRunServiceServer struct{}
+
+func (UnimplementedRunServiceServer) StartRun(context.Context, *StartRunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartRun not implemented")
+}
+func (UnimplementedRunServiceServer) PauseRun(context.Context, *RunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PauseRun not implemented")
+}
+func (UnimplementedRunServiceServer) ResumeRun(context.Context, *RunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeRun not implemented")
+}
+func (UnimplementedRunServiceServer) CancelRun(context.Context, *RunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelRun not implemented")
+}
+func (UnimplementedRunServiceServer) GetStatus(context.Context, *RunRequest) (*Run, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedRunServiceServer) StreamEvents(*RunRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEvents not implemented")
+}
+func (UnimplementedRunServiceServer) mustEmbedUnimplementedRunServiceServer() {}
+func (UnimplementedRunServiceServer) testEmbeddedByValue()                    {}
+
+// UnsafeRunServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RunServiceServer will
+// result in compilation errors.
+type UnsafeRunServiceServer interface {
+	mustEmbedUnimplementedRunServiceServer()
+}
+
+func RegisterRunServiceServer(s grpc.ServiceRegistrar, srv RunServiceServer) {
+	// If the following call pancis, it indicates UnimplementedRunServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&RunService_ServiceDesc, srv)
+}
+
+func _RunService_StartRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunServiceServer).StartRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RunService_StartRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunServiceServer).StartRun(ctx, req.(*StartRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RunService_PauseRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunServiceServer).PauseRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RunService_PauseRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunServiceServer).PauseRun(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RunService_ResumeRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunServiceServer).ResumeRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RunService_ResumeRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunServiceServer).ResumeRun(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RunService_CancelRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunServiceServer).CancelRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RunService_CancelRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunServiceServer).CancelRun(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RunService_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RunServiceServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RunService_GetStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RunServiceServer).GetStatus(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RunService_StreamEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(RunRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(RunServiceServer).StreamEvents(m, &grpc.GenericServerStream[RunRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type RunService_StreamEventsServer = grpc.ServerStreamingServer[Event]
+
+// RunService_ServiceDesc is the grpc.ServiceDesc for RunService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RunService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "binancebuyer.control.v1.RunService",
+	HandlerType: (*RunServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "StartRun",
+			Handler:    _RunService_StartRun_Handler,
+		},
+		{
+			MethodName: "PauseRun",
+			Handler:    _RunService_PauseRun_Handler,
+		},
+		{
+			MethodName: "ResumeRun",
+			Handler:    _RunService_ResumeRun_Handler,
+		},
+		{
+			MethodName: "CancelRun",
+			Handler:    _RunService_CancelRun_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _RunService_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEvents",
+			Handler:       _RunService_StreamEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "control.proto",
+}
@@ -0,0 +1,4 @@
+// Package controlpb is the gRPC API a trade daemon serves for orchestrating its runs, generated from control.proto
+package controlpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative control.proto
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringService is the service name credentials are stored under in the OS keyring
+const keyringService = "algo-trading"
+
+// Credentials holds an exchange API key pair
+type Credentials struct {
+	APIKey    string `json:"api_key"`
+	SecretKey string `json:"secret_key"`
+	// KeyType is how requests are signed: hmac with the secret key, or ed25519 or rsa with the private key as
+	// the secret key
+	KeyType string `json:"key_type"`
+}
+
+// credentialsName returns the name credentials are stored under for an exchange. Testnet keys are
+// kept apart from live keys, e.g. BINANCE_TESTNET_API_KEY and the binance-testnet-api-key keyring account.
+// The futures testnet issues its own keys, e.g. BINANCE_FUTURES_TESTNET_API_KEY, while live futures trade
+// with the exchange's regular keys.
+func credentialsName(exchange, market string, testnet bool) string {
+	if testnet && market == MarketFutures {
+		return exchange + "-futures-testnet"
+	}
+	if testnet {
+		return exchange + "-testnet"
+	}
+	return exchange
+}
+
+// credentialSource is a store of named key pairs that credentials missing from the flags and environment are
+// looked up in
+type credentialSource interface {
+	lookup(name string) (Credentials, bool, error)
+}
+
+// loadCredentials resolves the API key pair for an exchange from, in order of precedence, the
+// command line flags, the <EXCHANGE>_API_KEY/<EXCHANGE>_SECRET_KEY/<EXCHANGE>_KEY_TYPE environment
+// variables, the given sources, such as a secrets manager or the encrypted credentials file, and,
+// when enabled, the OS keyring
+func loadCredentials(exchange, flagAPIKey, flagSecretKey, flagKeyType string, sources []credentialSource, useKeyring bool) (Credentials, error) {
+	prefix := strings.ToUpper(strings.ReplaceAll(exchange, "-", "_"))
+	creds := Credentials{
+		APIKey:    firstNonEmpty(flagAPIKey, os.Getenv(prefix+"_API_KEY")),
+		SecretKey: firstNonEmpty(flagSecretKey, os.Getenv(prefix+"_SECRET_KEY")),
+		KeyType:   firstNonEmpty(flagKeyType, os.Getenv(prefix+"_KEY_TYPE")),
+	}
+
+	for _, source := range sources {
+		if creds.APIKey != "" && creds.SecretKey != "" {
+			break
+		}
+		stored, ok, err := source.lookup(strings.ToLower(exchange))
+		if err != nil {
+			return creds, err
+		}
+		if ok {
+			creds.APIKey = firstNonEmpty(creds.APIKey, stored.APIKey)
+			creds.SecretKey = firstNonEmpty(creds.SecretKey, stored.SecretKey)
+			creds.KeyType = firstNonEmpty(creds.KeyType, stored.KeyType)
+		}
+	}
+	creds.KeyType = strings.ToLower(firstNonEmpty(creds.KeyType, KeyTypeHMAC))
+
+	if useKeyring && creds.APIKey == "" {
+		apiKey, err := keyringLookup(strings.ToLower(exchange) + "-api-key")
+		if err != nil {
+			return creds, err
+		}
+		creds.APIKey = apiKey
+	}
+	if useKeyring && creds.SecretKey == "" {
+		secretKey, err := keyringLookup(strings.ToLower(exchange) + "-secret-key")
+		if err != nil {
+			return creds, err
+		}
+		creds.SecretKey = secretKey
+	}
+
+	if creds.APIKey == "" || creds.SecretKey == "" {
+		return creds, fmt.Errorf("API key and secret key are required. Set %s_API_KEY and %s_SECRET_KEY, store them in -secrets-provider or -credentials-file, or use -keyring", prefix, prefix)
+	}
+	return creds, nil
+}
+
+// keyringLookup reads a secret from the OS keyring: the macOS keychain via security, or the
+// Secret Service via secret-tool elsewhere
+func keyringLookup(account string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "darwin" {
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", account, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", account)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error reading %s from keyring: %v", account, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// firstNonEmpty returns the first of the given values that is not empty
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	// credentialStoreVersion is the format version of credentials files
+	credentialStoreVersion = 1
+	// credentialStoreIterations is the PBKDF2-SHA256 work factor deriving a credentials file's key from its passphrase
+	credentialStoreIterations = 600000
+)
+
+// credentialFile is the on-disk form of a credentials store: its named key pairs encrypted with AES-256-GCM under
+// a key derived from the passphrase. A fresh salt and nonce are drawn every time it is written.
+type credentialFile struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// CredentialStore is a passphrase-encrypted file of key pairs named like the environment variables they replace,
+// e.g. binance, binance-testnet or binance-sub1
+type CredentialStore struct {
+	path       string
+	passphrase []byte
+	entries    map[string]Credentials
+}
+
+// credentialPassphrase reads the passphrase unlocking a credentials file from a key file, or from the
+// CREDENTIALS_PASSPHRASE environment variable
+func credentialPassphrase(keyFile string) ([]byte, error) {
+	if keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading credentials key file: %v", err)
+		}
+		if passphrase := strings.TrimSpace(string(data)); passphrase != "" {
+			return []byte(passphrase), nil
+		}
+		return nil, fmt.Errorf("credentials key file %s is empty", keyFile)
+	}
+	if passphrase := os.Getenv("CREDENTIALS_PASSPHRASE"); passphrase != "" {
+		return []byte(passphrase), nil
+	}
+	return nil, fmt.Errorf("a passphrase is required to unlock the credentials file. Set CREDENTIALS_PASSPHRASE or use -credentials-key-file")
+}
+
+// openCredentialStore decrypts the credentials file at path. A missing file opens as an empty store when create
+// is set, so key pairs can be added to it.
+func openCredentialStore(path string, passphrase []byte, create bool) (*CredentialStore, error) {
+	store := &CredentialStore{path: path, passphrase: passphrase, entries: map[string]Credentials{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && create {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading credentials file: %v", err)
+	}
+
+	var file credentialFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing credentials file: %v", err)
+	}
+	if file.Version != credentialStoreVersion || file.KDF != "pbkdf2-sha256" {
+		return nil, fmt.Errorf("unsupported credentials file version %d (%s)", file.Version, file.KDF)
+	}
+	aead, err := credentialCipher(passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt credentials file %s: wrong passphrase or corrupted file", path)
+	}
+	if err := json.Unmarshal(plaintext, &store.entries); err != nil {
+		return nil, fmt.Errorf("error parsing credentials: %v", err)
+	}
+	return store, nil
+}
+
+// credentialCipher derives the AES-256-GCM cipher of a credentials file from its passphrase
+func credentialCipher(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, iterations, 32)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving credentials key: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// save encrypts the store and atomically replaces its file, readable by the owner only
+func (s *CredentialStore) save() error {
+	plaintext, err := json.Marshal(s.entries)
+	if err != nil {
+		return fmt.Errorf("error encoding credentials: %v", err)
+	}
+	file := credentialFile{
+		Version:    credentialStoreVersion,
+		KDF:        "pbkdf2-sha256",
+		Iterations: credentialStoreIterations,
+		Salt:       make([]byte, 16),
+	}
+	rand.Read(file.Salt)
+	aead, err := credentialCipher(s.passphrase, file.Salt, file.Iterations)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	rand.Read(file.Nonce)
+	file.Ciphertext = aead.Seal(nil, file.Nonce, plaintext, nil)
+	return writeJSONFile(s.path, file)
+}
+
+// lookup returns the key pair stored under a name
+func (s *CredentialStore) lookup(name string) (Credentials, bool, error) {
+	creds, ok := s.entries[name]
+	return creds, ok, nil
+}
+
+// names returns the names of the stored key pairs in order
+func (s *CredentialStore) names() []string {
+	return slices.Sorted(func(yield func(string) bool) {
+		for name := range s.entries {
+			if !yield(name) {
+				return
+			}
+		}
+	})
+}
+
+// credentialStore unlocks the credentials file given by the flags, once
+func (c *commonFlags) credentialStore() (*CredentialStore, error) {
+	if c.store != nil {
+		return c.store, nil
+	}
+	passphrase, err := credentialPassphrase(c.credentialsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	store, err := openCredentialStore(c.credentialsFile, passphrase, false)
+	if err != nil {
+		return nil, err
+	}
+	c.store = store
+	return store, nil
+}
+
+// runCredentialsAdd stores a key pair in the credentials file, creating the file when it does not exist. The pair is
+// read like a run's credentials, from the flags, the environment or the keyring, so plaintext keys can be moved in.
+func runCredentialsAdd(args []string) error {
+	fs, common := newFlagSet("credentials add")
+	name := fs.String("name", "", "Name to store the key pair under, as in the environment variables it replaces (default the exchange's: binance, binance-testnet, binance-futures-testnet; binance-<account> for -accounts)")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if common.credentialsFile == "" {
+		return fmt.Errorf("-credentials-file or CREDENTIALS_FILE is required")
+	}
+	if *name == "" {
+		*name = credentialsName(strings.ToLower(common.exchange), common.market, common.testnet)
+	}
+	*name = strings.ToLower(*name)
+	creds, err := loadCredentials(*name, common.apiKey, common.secretKey, common.keyType, nil, common.keyring)
+	if err != nil {
+		return err
+	}
+	if _, err := NewSigner(creds.KeyType, creds.SecretKey); err != nil {
+		return err
+	}
+	// Keys given as a file path are stored by content, so the file can be deleted
+	if creds.KeyType != KeyTypeHMAC && !strings.Contains(creds.SecretKey, "-----BEGIN") {
+		pemData, err := os.ReadFile(creds.SecretKey)
+		if err != nil {
+			return fmt.Errorf("error reading private key: %v", err)
+		}
+		creds.SecretKey = string(pemData)
+	}
+
+	passphrase, err := credentialPassphrase(common.credentialsKeyFile)
+	if err != nil {
+		return err
+	}
+	store, err := openCredentialStore(common.credentialsFile, passphrase, true)
+	if err != nil {
+		return err
+	}
+	_, replaced := store.entries[*name]
+	store.entries[*name] = creds
+	if err := store.save(); err != nil {
+		return err
+	}
+	if replaced {
+		fmt.Printf("Replaced the %s key pair in %s\n", *name, common.credentialsFile)
+	} else {
+		fmt.Printf("Added the %s key pair to %s\n", *name, common.credentialsFile)
+	}
+	return nil
+}
+
+// runCredentialsList lists the key pairs of the credentials file with their API keys masked
+func runCredentialsList(args []string) error {
+	fs, common := newFlagSet("credentials list")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if common.credentialsFile == "" {
+		return fmt.Errorf("-credentials-file or CREDENTIALS_FILE is required")
+	}
+	store, err := common.credentialStore()
+	if err != nil {
+		return err
+	}
+	if len(store.entries) == 0 {
+		fmt.Println("No key pairs stored")
+		return nil
+	}
+	fmt.Printf("%-32s %-8s %s\n", "NAME", "TYPE", "API KEY")
+	for _, name := range store.names() {
+		creds := store.entries[name]
+		fmt.Printf("%-32s %-8s %s\n", name, creds.KeyType, maskAPIKey(creds.APIKey))
+	}
+	return nil
+}
+
+// runCredentialsRemove deletes a key pair from the credentials file
+func runCredentialsRemove(args []string) error {
+	fs, common := newFlagSet("credentials remove")
+	name := fs.String("name", "", "Name of the key pair to remove")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if common.credentialsFile == "" {
+		return fmt.Errorf("-credentials-file or CREDENTIALS_FILE is required")
+	}
+	if *name == "" {
+		return fmt.Errorf("-name is required")
+	}
+	store, err := common.credentialStore()
+	if err != nil {
+		return err
+	}
+	if _, ok := store.entries[strings.ToLower(*name)]; !ok {
+		return fmt.Errorf("no key pair named %s in %s", *name, common.credentialsFile)
+	}
+	delete(store.entries, strings.ToLower(*name))
+	if err := store.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Removed the %s key pair from %s\n", strings.ToLower(*name), common.credentialsFile)
+	return nil
+}
+
+// maskAPIKey shows the first and last four characters of an API key
+func maskAPIKey(apiKey string) string {
+	if len(apiKey) <= 8 {
+		return strings.Repeat("*", len(apiKey))
+	}
+	return apiKey[:4] + strings.Repeat("*", 8) + apiKey[len(apiKey)-4:]
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredentialStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	passphrase := []byte("correct horse battery staple")
+	store, err := openCredentialStore(path, passphrase, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := Credentials{APIKey: "api-key", SecretKey: "secret-key", KeyType: KeyTypeHMAC}
+	store.entries["binance"] = stored
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-key") {
+		t.Error("credentials file contains the secret key in plaintext")
+	}
+
+	reopened, err := openCredentialStore(path, passphrase, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, ok, err := reopened.lookup("binance")
+	if err != nil || !ok || creds != stored {
+		t.Errorf("got %+v (found %t, error %v), want %+v", creds, ok, err, stored)
+	}
+}
+
+func TestCredentialStoreRejectsFile(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	tests := []struct {
+		name       string
+		passphrase []byte
+		tamper     func(file *credentialFile)
+		err        string
+	}{
+		{name: "wrong passphrase", passphrase: []byte("wrong passphrase"), err: "cannot decrypt"},
+		{name: "tampered ciphertext", tamper: func(file *credentialFile) { file.Ciphertext[0] ^= 1 }, err: "cannot decrypt"},
+		{name: "tampered salt", tamper: func(file *credentialFile) { file.Salt[0] ^= 1 }, err: "cannot decrypt"},
+		{name: "unknown version", tamper: func(file *credentialFile) { file.Version = 2 }, err: "unsupported"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials.json")
+			store, err := openCredentialStore(path, passphrase, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.entries["binance"] = Credentials{APIKey: "api-key", SecretKey: "secret-key"}
+			if err := store.save(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper != nil {
+				var file credentialFile
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := json.Unmarshal(data, &file); err != nil {
+					t.Fatal(err)
+				}
+				tt.tamper(&file)
+				if err := writeJSONFile(path, file); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if tt.passphrase == nil {
+				tt.passphrase = passphrase
+			}
+			_, err = openCredentialStore(path, tt.passphrase, false)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSearchLimit bounds the search for the next activation of a schedule that can never match, such as 30 February
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+// cronMacros are the shorthand schedules accepted in place of the five fields
+var cronMacros = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * SUN",
+	"@monthly": "0 0 1 * *",
+	"@yearly":  "0 0 1 1 *",
+}
+
+// cronField describes the range and value names of one field of a cron expression
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+// The five fields of a cron expression, in order
+var (
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDay    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+	cronDow    = cronField{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+)
+
+// CronSchedule is a parsed five-field cron expression (minute hour day-of-month month day-of-week).
+// Fields accept *, values, names (JAN-DEC, SUN-SAT), ranges, lists and steps, e.g. "0 9 * * MON-FRI"
+// or "*/15 * * * *". As in Vixie cron, when both day fields are restricted a day matching either one matches.
+type CronSchedule struct {
+	expr                       string
+	minutes, hours, days, dows uint64
+	months                     uint64
+	daysAny, dowsAny           bool
+}
+
+// ParseCron parses a cron expression or one of the @hourly, @daily, @weekly, @monthly and @yearly macros
+func ParseCron(expr string) (*CronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	schedule := &CronSchedule{expr: expr, daysAny: fields[2] == "*", dowsAny: fields[4] == "*"}
+	targets := []struct {
+		field cronField
+		bits  *uint64
+	}{
+		{cronMinute, &schedule.minutes},
+		{cronHour, &schedule.hours},
+		{cronDay, &schedule.days},
+		{cronMonth, &schedule.months},
+		{cronDow, &schedule.dows},
+	}
+	for i, target := range targets {
+		bits, err := target.field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+		}
+		*target.bits = bits
+	}
+	// 7 is an alias for Sunday
+	if schedule.dows&(1<<7) != 0 {
+		schedule.dows |= 1
+	}
+	return schedule, nil
+}
+
+// parse converts a field into a bit set of the values it matches
+func (f cronField) parse(spec string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(spec, ",") {
+		rangeSpec, stepSpec, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepSpec); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid %s step %q", f.name, stepSpec)
+			}
+		}
+
+		low, high := f.min, f.max
+		if rangeSpec != "*" {
+			lowSpec, highSpec, isRange := strings.Cut(rangeSpec, "-")
+			var err error
+			if low, err = f.value(lowSpec); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highSpec); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				high = f.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("invalid %s range %q", f.name, rangeSpec)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single number or name of the field
+func (f cronField) value(spec string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(spec, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(spec)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q", f.name, spec)
+	}
+	return v, nil
+}
+
+// Next returns the first activation strictly after t, in t's location, or the zero time if there is none
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+	for next.Before(limit) {
+		switch {
+		case s.months&(1<<uint(next.Month())) == 0:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !s.matchesDay(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case s.hours&(1<<uint(next.Hour())) == 0:
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case s.minutes&(1<<uint(next.Minute())) == 0:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay reports whether the day of t matches the day-of-month and day-of-week fields
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	day := s.days&(1<<uint(t.Day())) != 0
+	dow := s.dows&(1<<uint(t.Weekday())) != 0
+	if s.daysAny || s.dowsAny {
+		return day && dow
+	}
+	return day || dow
+}
+
+// String returns the expression the schedule was parsed from
+func (s *CronSchedule) String() string {
+	return s.expr
+}
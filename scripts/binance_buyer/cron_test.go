@@ -0,0 +1,100 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCronNext(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse("2006-01-02 15:04:05", value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		expr string
+		from string
+		want string
+	}{
+		{expr: "*/15 * * * *", from: "2024-01-01 00:07:00", want: "2024-01-01 00:15:00"},
+		{expr: "*/15 * * * *", from: "2024-01-01 00:14:59", want: "2024-01-01 00:15:00"},
+		{expr: "*/15 * * * *", from: "2024-01-01 00:15:00", want: "2024-01-01 00:30:00"},
+		{expr: "5/20 * * * *", from: "2024-01-01 00:06:00", want: "2024-01-01 00:25:00"},
+		{expr: "0 9 * * MON-FRI", from: "2024-01-05 10:00:00", want: "2024-01-08 09:00:00"},
+		{expr: "0 0 1,15 * *", from: "2024-01-02 00:00:00", want: "2024-01-15 00:00:00"},
+		{expr: "0 12 * feb *", from: "2024-03-01 00:00:00", want: "2025-02-01 12:00:00"},
+		{expr: "0 0 29 2 *", from: "2024-03-01 00:00:00", want: "2028-02-29 00:00:00"},
+		{expr: "0 0 13 * FRI", from: "2024-01-01 00:00:00", want: "2024-01-05 00:00:00"},
+		{expr: "0 0 13 * FRI", from: "2024-01-12 00:00:00", want: "2024-01-13 00:00:00"},
+		{expr: "0 0 * * 7", from: "2024-01-01 00:00:00", want: "2024-01-07 00:00:00"},
+		{expr: "@daily", from: "2024-01-01 00:00:00", want: "2024-01-02 00:00:00"},
+		{expr: "@weekly", from: "2024-01-01 00:00:00", want: "2024-01-07 00:00:00"},
+		{expr: "@MONTHLY", from: "2024-01-31 23:59:00", want: "2024-02-01 00:00:00"},
+		{expr: "@yearly", from: "2024-06-01 00:00:00", want: "2025-01-01 00:00:00"},
+		{expr: "0 0 30 2 *", from: "2024-01-01 00:00:00"},
+	}
+	for _, tt := range tests {
+		schedule, err := ParseCron(tt.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		var want time.Time
+		if tt.want != "" {
+			want = at(tt.want)
+		}
+		if got := schedule.Next(at(tt.from)); !got.Equal(want) {
+			t.Errorf("%q after %s: got %s, want %s", tt.expr, tt.from, got, want)
+		}
+	}
+}
+
+func TestParseCronNextKeepsLocation(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	schedule, err := ParseCron("30 8 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := schedule.Next(time.Date(2024, 1, 1, 9, 0, 0, 0, location))
+	if want := time.Date(2024, 1, 2, 8, 30, 0, 0, location); !got.Equal(want) || got.Location() != location {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{expr: "* * * *", want: "expected 5 fields, got 4"},
+		{expr: "", want: "expected 5 fields, got 0"},
+		{expr: "60 * * * *", want: `invalid minute "60"`},
+		{expr: "* 24 * * *", want: `invalid hour "24"`},
+		{expr: "* * 0 * *", want: `invalid day of month "0"`},
+		{expr: "* * * 13 *", want: `invalid month "13"`},
+		{expr: "* * * * FOO", want: `invalid day of week "FOO"`},
+		{expr: "*/0 * * * *", want: `invalid minute step "0"`},
+		{expr: "*/x * * * *", want: `invalid minute step "x"`},
+		{expr: "30-10 * * * *", want: `invalid minute range "30-10"`},
+		{expr: "@often", want: "expected 5 fields, got 1"},
+	}
+	for _, tt := range tests {
+		_, err := ParseCron(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseCron(%q) error = %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
+func TestParseCronString(t *testing.T) {
+	schedule, err := ParseCron("@hourly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schedule.String() != "@hourly" {
+		t.Errorf("String() = %q, want the expression as written", schedule.String())
+	}
+}
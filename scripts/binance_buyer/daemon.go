@@ -0,0 +1,767 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+// Lifecycle states of a daemon job
+const (
+	JobQueued    = "queued"
+	JobScheduled = "scheduled"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// errNoSuchJob is returned for a job ID the daemon does not have
+var errNoSuchJob = errors.New("no such job")
+
+// Where a daemon job was submitted from
+const (
+	JobSourceConfig = "config"
+	JobSourceAPI    = "api"
+)
+
+const (
+	// defaultDaemonAddr is the address the daemon API is served on and the daemon CLI connects to by default
+	defaultDaemonAddr = "localhost:8090"
+	// maxJobRequestBytes bounds the body of a job submission
+	maxJobRequestBytes = 64 << 10
+)
+
+// JobSpec describes an execution job: the trade exec arguments of its run and, for a recurring job, the cron
+// schedule starting a new run at every activation
+type JobSpec struct {
+	Name     string   `json:"name,omitempty"`
+	Args     []string `json:"args"`
+	Schedule string   `json:"schedule,omitempty"`
+}
+
+// DaemonJob is a job managed by the daemon and where it is in its lifecycle. One-off jobs go from queued to
+// running to completed, failed or cancelled. Recurring jobs return to scheduled after each run until cancelled.
+type DaemonJob struct {
+	JobSpec
+	ID        string    `json:"id"`
+	Source    string    `json:"source"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	StartedAt time.Time `json:"started_at,omitzero"`
+	EndedAt   time.Time `json:"ended_at,omitzero"`
+	NextRun   time.Time `json:"next_run,omitzero"`
+	Runs      int       `json:"runs"`
+	// LastResult is how the job's last run ended: completed, failed or cancelled
+	LastResult string `json:"last_result,omitempty"`
+	Error      string `json:"error,omitempty"`
+	// Resume continues the job's run from its state file when it next starts, as the daemon stopped during it
+	Resume bool `json:"resume,omitempty"`
+
+	schedule  *CronSchedule
+	process   *os.Process
+	cancelled bool
+}
+
+// daemonFile is the on-disk form of the daemon's jobs
+type daemonFile struct {
+	NextID int          `json:"next_id"`
+	Jobs   []*DaemonJob `json:"jobs"`
+}
+
+// Daemon runs a queue of execution jobs, each as a trade exec child process with its own state file and log in
+// the daemon's directory, up to maxRunning at a time. Its jobs are persisted, so runs interrupted by stopping the
+// daemon are resumed when it starts again.
+type Daemon struct {
+	dir        string
+	executable string
+	maxRunning int
+
+	mu       sync.Mutex
+	jobs     []*DaemonJob
+	nextID   int
+	running  int
+	stopping bool
+	wake     chan struct{}
+	exited   sync.WaitGroup
+}
+
+// openDaemon loads the jobs persisted in dir, creating it when it does not exist. Jobs that were running when the
+// daemon stopped are queued to resume.
+func openDaemon(dir, executable string, maxRunning int) (*Daemon, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating daemon directory: %v", err)
+	}
+	d := &Daemon{dir: dir, executable: executable, maxRunning: maxRunning, wake: make(chan struct{}, 1)}
+	data, err := os.ReadFile(d.path())
+	if errors.Is(err, fs.ErrNotExist) {
+		return d, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading daemon jobs: %v", err)
+	}
+	var file daemonFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error parsing daemon jobs: %v", err)
+	}
+	d.jobs, d.nextID = file.Jobs, file.NextID
+	for _, job := range d.jobs {
+		if job.Schedule != "" {
+			if job.schedule, err = ParseCron(job.Schedule); err != nil {
+				return nil, fmt.Errorf("job %s: %v", job.ID, err)
+			}
+		}
+		if job.Status == JobRunning {
+			log.Printf("Job %s was running when the daemon stopped. Queuing it to resume.", job.ID)
+			job.Status, job.Resume = JobQueued, true
+		}
+	}
+	return d, nil
+}
+
+// path returns the file the daemon's jobs are persisted to
+func (d *Daemon) path() string {
+	return filepath.Join(d.dir, "jobs.json")
+}
+
+// logPath returns the file the output of a job's runs is appended to
+func (d *Daemon) logPath(id string) string {
+	return filepath.Join(d.dir, "job-"+id+".log")
+}
+
+// controlPath returns the Unix socket the control server of a job's runs listens on, unless its arguments set
+// -control-addr
+func (d *Daemon) controlPath(id string) string {
+	return filepath.Join(d.dir, "job-"+id+".sock")
+}
+
+// save persists the jobs. The caller must hold d.mu.
+func (d *Daemon) save() {
+	if err := writeJSONFile(d.path(), daemonFile{NextID: d.nextID, Jobs: d.jobs}); err != nil {
+		log.Printf("Error saving daemon jobs: %v", err)
+	}
+}
+
+// notify wakes the scheduling loop
+func (d *Daemon) notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Submit adds a job, queued to run as soon as a slot is free or, with a schedule, at its next activation. Jobs
+// without -state-file get a state file of their own in the daemon's directory, and jobs without -control-addr a
+// control server on a Unix socket there.
+func (d *Daemon) Submit(spec JobSpec, source string) (DaemonJob, error) {
+	if len(spec.Args) == 0 {
+		return DaemonJob{}, fmt.Errorf("a job needs the trade exec arguments of its run")
+	}
+	if hasFlag(spec.Args, "resume") {
+		return DaemonJob{}, fmt.Errorf("jobs cannot use -resume: the daemon resumes the runs it interrupted itself")
+	}
+	var schedule *CronSchedule
+	var nextRun time.Time
+	if spec.Schedule != "" {
+		var err error
+		if schedule, err = ParseCron(spec.Schedule); err != nil {
+			return DaemonJob{}, err
+		}
+		if nextRun = schedule.Next(time.Now()); nextRun.IsZero() {
+			return DaemonJob{}, fmt.Errorf("schedule %q has no upcoming activations", spec.Schedule)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.stopping {
+		return DaemonJob{}, fmt.Errorf("the daemon is shutting down")
+	}
+	d.nextID++
+	job := &DaemonJob{
+		JobSpec:   spec,
+		ID:        strconv.Itoa(d.nextID),
+		Source:    source,
+		Status:    JobQueued,
+		CreatedAt: time.Now(),
+		schedule:  schedule,
+	}
+	job.Args = slices.Clone(spec.Args)
+	if !hasFlag(job.Args, "state-file") {
+		job.Args = append(job.Args, "-state-file", filepath.Join(d.dir, "job-"+job.ID+".state.json"))
+	}
+	if !hasFlag(job.Args, "control-addr") {
+		job.Args = append(job.Args, "-control-addr", "unix:"+d.controlPath(job.ID))
+	}
+	if schedule != nil {
+		job.Status, job.NextRun = JobScheduled, nextRun
+	}
+	d.jobs = append(d.jobs, job)
+	d.save()
+	log.Printf("Job %s %s submitted from %s: trade exec %s", job.ID, job.Name, source, strings.Join(job.Args, " "))
+	d.notify()
+	return *job, nil
+}
+
+// Cancel cancels a job. A queued or scheduled job will not run, and a running job's run is stopped with SIGTERM
+// so it saves its state first. A recurring job is not scheduled again.
+func (d *Daemon) Cancel(id string) (DaemonJob, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	job := d.find(id)
+	if job == nil {
+		return DaemonJob{}, errNoSuchJob
+	}
+	switch job.Status {
+	case JobQueued, JobScheduled:
+		job.Status, job.EndedAt, job.NextRun = JobCancelled, time.Now(), time.Time{}
+		d.save()
+	case JobRunning:
+		job.cancelled = true
+		if err := job.process.Signal(syscall.SIGTERM); err != nil {
+			return *job, fmt.Errorf("error stopping job %s: %v", id, err)
+		}
+	default:
+		return *job, fmt.Errorf("job %s already %s", id, job.Status)
+	}
+	log.Printf("Job %s cancelled", id)
+	return *job, nil
+}
+
+// find returns the job with an ID, or nil. The caller must hold d.mu.
+func (d *Daemon) find(id string) *DaemonJob {
+	for _, job := range d.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// Jobs returns a snapshot of the jobs in submission order
+func (d *Daemon) Jobs() []DaemonJob {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	jobs := make([]DaemonJob, len(d.jobs))
+	for i, job := range d.jobs {
+		jobs[i] = *job
+	}
+	return jobs
+}
+
+// Job returns a snapshot of the job with an ID
+func (d *Daemon) Job(id string) (DaemonJob, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if job := d.find(id); job != nil {
+		return *job, true
+	}
+	return DaemonJob{}, false
+}
+
+// Run queues recurring jobs at their activations and starts queued jobs while fewer than maxRunning run, until
+// ctx is cancelled
+func (d *Daemon) Run(ctx context.Context) {
+	for {
+		d.mu.Lock()
+		now := time.Now()
+		var next time.Time
+		for _, job := range d.jobs {
+			if job.Status != JobScheduled {
+				continue
+			}
+			if !job.NextRun.After(now) {
+				job.Status = JobQueued
+			} else if next.IsZero() || job.NextRun.Before(next) {
+				next = job.NextRun
+			}
+		}
+		for _, job := range d.jobs {
+			if d.running >= d.maxRunning {
+				break
+			}
+			if job.Status == JobQueued {
+				d.start(job)
+			}
+		}
+		d.save()
+		d.mu.Unlock()
+
+		var activation <-chan time.Time
+		var timer *time.Timer
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			activation = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-d.wake:
+		case <-activation:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// start starts a job's run as a child process writing to the job's log. The caller must hold d.mu.
+func (d *Daemon) start(job *DaemonJob) {
+	args := append([]string{"trade", "exec"}, job.Args...)
+	if job.Resume {
+		args = append(args, "-resume")
+	}
+	logFile, err := os.OpenFile(d.logPath(job.ID), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err == nil {
+		fmt.Fprintf(logFile, "--- %s: run %d of job %s ---\n", time.Now().Format(time.RFC3339), job.Runs+1, job.ID)
+		cmd := exec.Command(d.executable, args...)
+		cmd.Stdout, cmd.Stderr = logFile, logFile
+		if err = cmd.Start(); err == nil {
+			job.Status, job.StartedAt, job.Error = JobRunning, time.Now(), ""
+			job.process = cmd.Process
+			job.Runs++
+			d.running++
+			d.exited.Add(1)
+			go d.wait(job, cmd, logFile)
+			log.Printf("Job %s %s started (pid %d)", job.ID, job.Name, cmd.Process.Pid)
+			return
+		}
+		logFile.Close()
+	}
+	log.Printf("Error starting job %s: %v", job.ID, err)
+	job.Status, job.LastResult, job.Error, job.EndedAt = JobFailed, JobFailed, err.Error(), time.Now()
+}
+
+// wait records how a job's run ended once its process exits. A recurring job is scheduled again, and a run
+// interrupted by the daemon stopping is queued to resume.
+func (d *Daemon) wait(job *DaemonJob, cmd *exec.Cmd, logFile *os.File) {
+	defer d.exited.Done()
+	err := cmd.Wait()
+	logFile.Close()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	defer d.notify()
+	d.running--
+	job.process, job.EndedAt, job.Resume = nil, time.Now(), false
+	elapsed := job.EndedAt.Sub(job.StartedAt).Round(time.Second)
+	if d.stopping && !job.cancelled {
+		job.Status, job.Resume = JobQueued, true
+		log.Printf("Job %s stopped after %s. It resumes when the daemon starts again.", job.ID, elapsed)
+		d.save()
+		return
+	}
+
+	switch {
+	case job.cancelled:
+		job.LastResult = JobCancelled
+	case err != nil:
+		job.LastResult, job.Error = JobFailed, err.Error()
+	default:
+		job.LastResult = JobCompleted
+	}
+	job.Status = job.LastResult
+	if job.schedule != nil && !job.cancelled {
+		job.Status, job.NextRun = JobScheduled, job.schedule.Next(time.Now())
+		if job.NextRun.IsZero() {
+			job.Status = job.LastResult
+		}
+	}
+	log.Printf("Job %s %s after %s (%s)", job.ID, job.LastResult, elapsed, cmp.Or(job.Error, "see "+d.logPath(job.ID)))
+	d.save()
+}
+
+// Shutdown stops starting jobs and waits for the running ones to exit. With forward set, their runs are sent
+// SIGTERM; without it they are expected to have received the signal stopping the daemon, like Ctrl-C reaching
+// every process of the terminal.
+func (d *Daemon) Shutdown(forward bool) {
+	d.mu.Lock()
+	d.stopping = true
+	if d.running > 0 {
+		log.Printf("Waiting for %d running job(s) to save their state", d.running)
+	}
+	for _, job := range d.jobs {
+		if forward && job.process != nil {
+			job.process.Signal(syscall.SIGTERM)
+		}
+	}
+	d.mu.Unlock()
+	d.exited.Wait()
+}
+
+// handler returns the daemon's API, which only accepts requests carrying token when it is set
+func (d *Daemon) handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", d.handleList)
+	mux.HandleFunc("POST /jobs", d.handleSubmit)
+	mux.HandleFunc("GET /jobs/{id}", d.handleJob)
+	mux.HandleFunc("POST /jobs/{id}/cancel", d.handleCancel)
+	mux.HandleFunc("GET /jobs/{id}/log", d.handleLog)
+	return requireToken(token, mux)
+}
+
+// writeJSON writes a JSON response with a status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleList lists the jobs
+func (d *Daemon) handleList(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, d.Jobs())
+}
+
+// handleSubmit submits the job in the request body
+func (d *Daemon) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var spec JobSpec
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxJobRequestBytes)).Decode(&spec); err != nil {
+		http.Error(w, "job must be JSON with args and optionally name and schedule", http.StatusBadRequest)
+		return
+	}
+	job, err := d.Submit(spec, JobSourceAPI)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, job)
+}
+
+// handleJob shows a job
+func (d *Daemon) handleJob(w http.ResponseWriter, r *http.Request) {
+	job, ok := d.Job(r.PathValue("id"))
+	if !ok {
+		http.Error(w, errNoSuchJob.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleCancel cancels a job
+func (d *Daemon) handleCancel(w http.ResponseWriter, r *http.Request) {
+	job, err := d.Cancel(r.PathValue("id"))
+	if errors.Is(err, errNoSuchJob) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleLog serves the output of a job's runs
+func (d *Daemon) handleLog(w http.ResponseWriter, r *http.Request) {
+	job, ok := d.Job(r.PathValue("id"))
+	if !ok {
+		http.Error(w, errNoSuchJob.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, d.logPath(job.ID))
+}
+
+// loadJobSpecs reads the jobs of a daemon config file, a JSON array of jobs with unique names, e.g.
+// [{"name": "weekly_btc", "schedule": "0 9 * * MON", "args": ["-symbol", "BTCUSDT", "-total-amount", "100"]}]
+func loadJobSpecs(path string) ([]JobSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading jobs: %v", err)
+	}
+	var specs []JobSpec
+	if err := json.Unmarshal(data, &specs); err != nil {
+		return nil, fmt.Errorf("error parsing jobs: %v", err)
+	}
+	names := map[string]bool{}
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("every job of %s needs a name", path)
+		}
+		if names[spec.Name] {
+			return nil, fmt.Errorf("job name %s is used twice in %s", spec.Name, path)
+		}
+		names[spec.Name] = true
+	}
+	return specs, nil
+}
+
+// runDaemonServe runs the daemon until interrupted, serving its API and starting the jobs of its config file that
+// it has not run before
+func runDaemonServe(args []string) error {
+	fs := flag.NewFlagSet("daemon serve", flag.ExitOnError)
+	dir := fs.String("dir", "binance_buyer_daemon", "Directory the daemon keeps its jobs and their state files and logs in")
+	jobsPath := fs.String("jobs", "", "JSON file of jobs to submit at startup, each with a name, trade exec args and optionally a cron schedule. Jobs already submitted by name are not submitted again")
+	addr := fs.String("addr", defaultDaemonAddr, "Serve the job API on this address (e.g., localhost:8090 or unix:/tmp/binance_buyer_daemon.sock; empty to disable)")
+	grpcAddr := fs.String("grpc-addr", "", "Serve the gRPC run service on this address (e.g., localhost:8091 or unix:/tmp/binance_buyer_grpc.sock; empty to disable)")
+	token := fs.String("token", os.Getenv("DAEMON_TOKEN"), "Token every API request and gRPC call must carry as a bearer token, required unless the address is a Unix socket (default from DAEMON_TOKEN)")
+	maxRunning := fs.Int("max-running", 4, "Jobs run at the same time, the rest waiting in the queue")
+	logFormat := fs.String("log-format", "text", "Log output format: text or json")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if err := setupLogging(*logFormat); err != nil {
+		return err
+	}
+	if *maxRunning < 1 {
+		return fmt.Errorf("max running must be at least 1")
+	}
+	if *addr != "" && *token == "" && !strings.HasPrefix(*addr, "unix:") {
+		return fmt.Errorf("a token is required to serve the job API over TCP: set -token or DAEMON_TOKEN")
+	}
+	if *grpcAddr != "" && *token == "" && !strings.HasPrefix(*grpcAddr, "unix:") {
+		return fmt.Errorf("a token is required to serve the gRPC run service over TCP: set -token or DAEMON_TOKEN")
+	}
+	var specs []JobSpec
+	if *jobsPath != "" {
+		var err error
+		if specs, err = loadJobSpecs(*jobsPath); err != nil {
+			return err
+		}
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error locating executable: %v", err)
+	}
+	daemon, err := openDaemon(*dir, executable, *maxRunning)
+	if err != nil {
+		return err
+	}
+	for _, spec := range specs {
+		submitted := slices.ContainsFunc(daemon.Jobs(), func(job DaemonJob) bool {
+			return job.Source == JobSourceConfig && job.Name == spec.Name
+		})
+		if submitted {
+			continue
+		}
+		if _, err := daemon.Submit(spec, JobSourceConfig); err != nil {
+			return fmt.Errorf("job %s: %v", spec.Name, err)
+		}
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var received os.Signal
+	go func() {
+		received = <-signals
+		cancel()
+	}()
+
+	if *addr != "" {
+		listener, err := controlListener(*addr)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Handler: daemon.handler(*token), ReadHeaderTimeout: 5 * time.Second}
+		go func() {
+			if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("Daemon API stopped: %v", err)
+			}
+		}()
+		defer func() {
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+		log.Printf("Daemon API listening on %s", *addr)
+	}
+	if *grpcAddr != "" {
+		listener, err := controlListener(*grpcAddr)
+		if err != nil {
+			return err
+		}
+		server := newRunServer(daemon, *token)
+		go func() {
+			if err := server.Serve(listener); err != nil {
+				log.Printf("gRPC run service stopped: %v", err)
+			}
+		}()
+		defer server.Stop()
+		log.Printf("gRPC run service listening on %s", *grpcAddr)
+	}
+
+	log.Printf("Daemon started with %d job(s) in %s, running up to %d at a time", len(daemon.Jobs()), *dir, *maxRunning)
+	daemon.Run(ctx)
+	log.Printf("Shutting down daemon")
+	daemon.Shutdown(received == syscall.SIGTERM)
+	return nil
+}
+
+// daemonClient sends requests to a daemon's API
+type daemonClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// newDaemonClientFlags registers the flags locating the daemon on a daemon CLI subcommand
+func newDaemonClientFlags(name string) (*flag.FlagSet, *daemonClient, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	client := &daemonClient{}
+	addr := fs.String("addr", cmp.Or(os.Getenv("DAEMON_ADDR"), defaultDaemonAddr), "Address of the daemon API (e.g., localhost:8090 or unix:/tmp/binance_buyer_daemon.sock; default from DAEMON_ADDR)")
+	fs.StringVar(&client.token, "token", os.Getenv("DAEMON_TOKEN"), "Token of the daemon API (default from DAEMON_TOKEN)")
+	return fs, client, addr
+}
+
+// connect points the client at the daemon listening on addr
+func (c *daemonClient) connect(addr string) {
+	c.http, c.baseURL = controlHTTPClient(addr)
+	c.http.Timeout = 30 * time.Second
+}
+
+// do sends a request to the daemon and returns the response body, failing on an error status
+func (c *daemonClient) do(method, path string, body any) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("error encoding request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error reaching the daemon: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading daemon response: %v", err)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("daemon returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// printJobs prints jobs as a table
+func printJobs(jobs []DaemonJob) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tSOURCE\tSCHEDULE\tNEXT RUN\tRUNS\tLAST RESULT")
+	for _, job := range jobs {
+		next := ""
+		if !job.NextRun.IsZero() {
+			next = job.NextRun.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", job.ID, job.Name, job.Status, job.Source, job.Schedule, next, job.Runs, job.LastResult)
+	}
+	return w.Flush()
+}
+
+// runDaemonSubmit submits a job to a running daemon. The trade exec arguments of its run follow the flags after --.
+func runDaemonSubmit(args []string) error {
+	fs, client, addr := newDaemonClientFlags("daemon submit")
+	name := fs.String("name", "", "Name of the job")
+	schedule := fs.String("schedule", "", "Cron schedule starting a new run of the job at every activation (e.g., \"0 9 * * MON\"; empty runs it once)")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		return fmt.Errorf("the trade exec arguments of the job's run are required after --, e.g. -- -symbol BTCUSDT -total-amount 100")
+	}
+	client.connect(*addr)
+	data, err := client.do(http.MethodPost, "/jobs", JobSpec{Name: *name, Args: fs.Args(), Schedule: *schedule})
+	if err != nil {
+		return err
+	}
+	var job DaemonJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return fmt.Errorf("error parsing daemon response: %v", err)
+	}
+	return printJobs([]DaemonJob{job})
+}
+
+// runDaemonList lists the jobs of a running daemon
+func runDaemonList(args []string) error {
+	fs, client, addr := newDaemonClientFlags("daemon list")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	client.connect(*addr)
+	data, err := client.do(http.MethodGet, "/jobs", nil)
+	if err != nil {
+		return err
+	}
+	var jobs []DaemonJob
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("error parsing daemon response: %v", err)
+	}
+	return printJobs(jobs)
+}
+
+// runDaemonCancel cancels a job of a running daemon
+func runDaemonCancel(args []string) error {
+	fs, client, addr := newDaemonClientFlags("daemon cancel")
+	id := fs.String("id", "", "ID of the job to cancel")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *id == "" {
+		return fmt.Errorf("-id is required")
+	}
+	client.connect(*addr)
+	data, err := client.do(http.MethodPost, "/jobs/"+*id+"/cancel", nil)
+	if err != nil {
+		return err
+	}
+	var job DaemonJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return fmt.Errorf("error parsing daemon response: %v", err)
+	}
+	return printJobs([]DaemonJob{job})
+}
+
+// runDaemonLogs prints the output of a job's runs
+func runDaemonLogs(args []string) error {
+	fs, client, addr := newDaemonClientFlags("daemon logs")
+	id := fs.String("id", "", "ID of the job whose output to print")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if *id == "" {
+		return fmt.Errorf("-id is required")
+	}
+	client.connect(*addr)
+	data, err := client.do(http.MethodGet, "/jobs/"+*id+"/log", nil)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/agamkapur/algo-trading/scripts/binance_buyer/controlpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// runControlTimeout bounds a request to the control server of a job's run
+	runControlTimeout = 10 * time.Second
+	// eventStreamRetryInterval is how often an event stream checks whether its job started a run it can follow
+	eventStreamRetryInterval = time.Second
+)
+
+// runService serves the daemon's jobs over gRPC as runs to start, pause, resume, cancel, inspect and follow.
+// Pausing, progress and events are relayed from the control server of each job's running trade exec process.
+type runService struct {
+	controlpb.UnimplementedRunServiceServer
+	daemon *Daemon
+}
+
+// newRunServer returns a gRPC server of the daemon's run service, which only accepts calls carrying token as
+// "authorization: Bearer" metadata when it is set
+func newRunServer(daemon *Daemon, token string) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			if err := checkBearerToken(ctx, token); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := checkBearerToken(stream.Context(), token); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	controlpb.RegisterRunServiceServer(server, &runService{daemon: daemon})
+	return server
+}
+
+// checkBearerToken rejects a call without token, when set, in its authorization metadata
+func checkBearerToken(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if bearer, ok := strings.CutPrefix(value, "Bearer "); ok && subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing token")
+}
+
+// StartRun submits a job
+func (s *runService) StartRun(_ context.Context, req *controlpb.StartRunRequest) (*controlpb.Run, error) {
+	job, err := s.daemon.Submit(JobSpec{Name: req.Name, Args: req.Args, Schedule: req.Schedule}, JobSourceAPI)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return runMessage(job, nil), nil
+}
+
+// PauseRun pauses slice scheduling of a running job
+func (s *runService) PauseRun(ctx context.Context, req *controlpb.RunRequest) (*controlpb.Run, error) {
+	return s.controlRun(ctx, req.Id, "/pause")
+}
+
+// ResumeRun resumes slice scheduling of a paused job
+func (s *runService) ResumeRun(ctx context.Context, req *controlpb.RunRequest) (*controlpb.Run, error) {
+	return s.controlRun(ctx, req.Id, "/resume")
+}
+
+// CancelRun cancels a job
+func (s *runService) CancelRun(_ context.Context, req *controlpb.RunRequest) (*controlpb.Run, error) {
+	job, err := s.daemon.Cancel(req.Id)
+	if errors.Is(err, errNoSuchJob) {
+		return nil, status.Errorf(codes.NotFound, "no job %s", req.Id)
+	}
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return runMessage(job, nil), nil
+}
+
+// GetStatus reports a job and, when its run's control server answers, the run's progress
+func (s *runService) GetStatus(ctx context.Context, req *controlpb.RunRequest) (*controlpb.Run, error) {
+	job, ok := s.daemon.Job(req.Id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no job %s", req.Id)
+	}
+	if job.Status != JobRunning {
+		return runMessage(job, nil), nil
+	}
+	progress, _ := runControlStatus(ctx, job, "/status")
+	return runMessage(job, progress), nil
+}
+
+// controlRun sends a request to the control server of a job's run and reports the job with the progress the run
+// answers with
+func (s *runService) controlRun(ctx context.Context, id, path string) (*controlpb.Run, error) {
+	job, ok := s.daemon.Job(id)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no job %s", id)
+	}
+	if job.Status != JobRunning {
+		return nil, status.Errorf(codes.FailedPrecondition, "job %s is %s, not running", id, job.Status)
+	}
+	progress, err := runControlStatus(ctx, job, path)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "error reaching the run of job %s: %v", id, err)
+	}
+	return runMessage(job, progress), nil
+}
+
+// StreamEvents streams the events of a job's runs as they happen, following each run of a recurring job, until
+// the job ends or the client disconnects. A job that is queued or scheduled is waited for.
+func (s *runService) StreamEvents(req *controlpb.RunRequest, stream grpc.ServerStreamingServer[controlpb.Event]) error {
+	ctx := stream.Context()
+	for {
+		job, ok := s.daemon.Job(req.Id)
+		if !ok {
+			return status.Errorf(codes.NotFound, "no job %s", req.Id)
+		}
+		switch job.Status {
+		case JobCompleted, JobFailed, JobCancelled:
+			return nil
+		case JobRunning:
+			if _, _, ok := jobControl(job); !ok {
+				return status.Errorf(codes.FailedPrecondition, "the runs of job %s have no control server", req.Id)
+			}
+			if err := relayRunEvents(ctx, job, stream.Send); err != nil {
+				return err
+			}
+		}
+		if !sleepContext(ctx, eventStreamRetryInterval) {
+			return nil
+		}
+	}
+}
+
+// jobControl returns the address and token of the control server of a job's runs, false when its arguments
+// turned it off. The token defaults to CONTROL_TOKEN, which the runs inherit from the daemon.
+func jobControl(job DaemonJob) (string, string, bool) {
+	addr := flagValue(job.Args, "control-addr")
+	if addr == "" {
+		return "", "", false
+	}
+	token := os.Getenv("CONTROL_TOKEN")
+	if hasFlag(job.Args, "control-token") {
+		token = flagValue(job.Args, "control-token")
+	}
+	return addr, token, true
+}
+
+// runControlRequest builds a request to the control server of a job's run
+func runControlRequest(ctx context.Context, job DaemonJob, path string) (*http.Client, *http.Request, error) {
+	addr, token, ok := jobControl(job)
+	if !ok {
+		return nil, nil, fmt.Errorf("the runs of job %s have no control server", job.ID)
+	}
+	client, baseURL := controlHTTPClient(addr)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+path, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return client, req, nil
+}
+
+// runControlStatus sends a request to the control server of a job's run and returns the status it answers with
+func runControlStatus(ctx context.Context, job DaemonJob, path string) (*controlStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, runControlTimeout)
+	defer cancel()
+	client, req, err := runControlRequest(ctx, job, path)
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseIdleConnections()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("control server returned %s", resp.Status)
+	}
+	var progress controlStatus
+	if err := json.NewDecoder(resp.Body).Decode(&progress); err != nil {
+		return nil, fmt.Errorf("error parsing run status: %v", err)
+	}
+	return &progress, nil
+}
+
+// relayRunEvents sends the events a job's run streams from its control server until the stream ends. A run
+// whose control server cannot be reached, e.g. while it starts up or exits, relays nothing; only a failure to
+// send to the client is returned.
+func relayRunEvents(ctx context.Context, job DaemonJob, send func(*controlpb.Event) error) error {
+	client, req, err := runControlRequest(ctx, job, "/events")
+	if err != nil {
+		return nil
+	}
+	defer client.CloseIdleConnections()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event WebhookEvent
+		if decoder.Decode(&event) != nil {
+			return nil
+		}
+		if err := send(eventMessage(event, job.ID)); err != nil {
+			return err
+		}
+	}
+}
+
+// runMessage converts a job, and its run's progress when known, to its gRPC form
+func runMessage(job DaemonJob, progress *controlStatus) *controlpb.Run {
+	run := &controlpb.Run{
+		Id:         job.ID,
+		Name:       job.Name,
+		Args:       job.Args,
+		Schedule:   job.Schedule,
+		Source:     job.Source,
+		Status:     job.Status,
+		CreatedAt:  timestampMessage(job.CreatedAt),
+		StartedAt:  timestampMessage(job.StartedAt),
+		EndedAt:    timestampMessage(job.EndedAt),
+		NextRun:    timestampMessage(job.NextRun),
+		Runs:       int32(job.Runs),
+		LastResult: job.LastResult,
+		Error:      job.Error,
+	}
+	if progress != nil {
+		run.Progress = &controlpb.RunProgress{
+			RunId:               progress.RunID,
+			Symbol:              progress.Symbol,
+			Side:                progress.Side,
+			Paused:              progress.Paused,
+			SizeFactor:          progress.SizeFactor,
+			NextSlice:           int32(progress.NextSlice),
+			TotalSlices:         int32(progress.TotalSlices),
+			TargetAmount:        progress.TargetAmount.String(),
+			RemainingBudget:     progress.RemainingBudget.String(),
+			BudgetAsset:         progress.BudgetAsset,
+			FilledBase:          progress.FilledBase.String(),
+			FilledQuote:         progress.FilledQuote.String(),
+			AverageFillPrice:    progress.AverageFillPrice,
+			MarketTwap:          progress.MarketTWAP,
+			MarketVwap:          progress.MarketVWAP,
+			LastPrice:           progress.LastPrice,
+			SlippageBps:         progress.SlippageBps,
+			OpenOrders:          int32(progress.OpenOrders),
+			BudgetChangePending: progress.BudgetChangePending,
+		}
+	}
+	return run
+}
+
+// eventMessage converts a run event of a job to its gRPC form
+func eventMessage(event WebhookEvent, jobID string) *controlpb.Event {
+	data, _ := structpb.NewStruct(event.Data)
+	return &controlpb.Event{
+		Id:     event.ID,
+		Type:   event.Type,
+		Time:   timestampMessage(event.Time),
+		RunId:  event.RunID,
+		Symbol: event.Symbol,
+		Side:   event.Side,
+		Data:   data,
+		JobId:  jobID,
+	}
+}
+
+// timestampMessage converts a time to its gRPC form, leaving the zero time unset
+func timestampMessage(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agamkapur/algo-trading/scripts/binance_buyer/controlpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialRunService serves the daemon's run service in memory and returns a client of it
+func dialRunService(t *testing.T, daemon *Daemon, token string) controlpb.RunServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := newRunServer(daemon, token)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return controlpb.NewRunServiceClient(conn)
+}
+
+// setJobStatus moves a job to a lifecycle state as if the daemon had started or finished its run
+func setJobStatus(daemon *Daemon, id, jobStatus string) {
+	daemon.mu.Lock()
+	defer daemon.mu.Unlock()
+	daemon.find(id).Status = jobStatus
+}
+
+func TestRunServiceStartRunQueuesJobWithControlServer(t *testing.T) {
+	daemon, err := openDaemon(t.TempDir(), "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dialRunService(t, daemon, "")
+
+	run, err := client.StartRun(context.Background(), &controlpb.StartRunRequest{Name: "btc", Args: []string{"-symbol", "BTCUSDT", "-total-amount", "100"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != JobQueued || run.Name != "btc" {
+		t.Errorf("started run %s with status %s, want btc queued", run.Name, run.Status)
+	}
+	if addr := flagValue(run.Args, "control-addr"); addr != "unix:"+daemon.controlPath(run.Id) {
+		t.Errorf("control address %q, want the job's socket", addr)
+	}
+
+	if _, err := client.StartRun(context.Background(), &controlpb.StartRunRequest{Args: []string{"-resume"}}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("starting a resumed run returned %v, want InvalidArgument", err)
+	}
+	if _, err := client.PauseRun(context.Background(), &controlpb.RunRequest{Id: run.Id}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("pausing a queued run returned %v, want FailedPrecondition", err)
+	}
+	if _, err := client.GetStatus(context.Background(), &controlpb.RunRequest{Id: "42"}); status.Code(err) != codes.NotFound {
+		t.Errorf("status of an unknown run returned %v, want NotFound", err)
+	}
+}
+
+func TestRunServiceRelaysRunControl(t *testing.T) {
+	daemon, err := openDaemon(t.TempDir(), "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dialRunService(t, daemon, "")
+	job, err := daemon.Submit(JobSpec{Args: []string{"-symbol", "BTCUSDT"}}, JobSourceAPI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	control := NewRunControl(func() {}, "")
+	control.update(&RunState{RunID: "run1", Config: TWAPConfig{Symbol: "BTCUSDT", Side: "BUY"}, TotalSlices: 4, NextSlice: 1})
+	shutdown, err := control.Serve("unix:" + daemon.controlPath(job.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	setJobStatus(daemon, job.ID, JobRunning)
+
+	run, err := client.PauseRun(context.Background(), &controlpb.RunRequest{Id: job.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !run.Progress.GetPaused() || run.Progress.GetRunId() != "run1" || run.Progress.GetTotalSlices() != 4 {
+		t.Errorf("paused run reported progress %v, want run1 paused at 4 slices", run.Progress)
+	}
+	if run, err = client.ResumeRun(context.Background(), &controlpb.RunRequest{Id: job.ID}); err != nil || run.Progress.GetPaused() {
+		t.Errorf("resumed run reported %v, %v, want it no longer paused", run.GetProgress(), err)
+	}
+	if run, err = client.GetStatus(context.Background(), &controlpb.RunRequest{Id: job.ID}); err != nil || run.Progress.GetSymbol() != "BTCUSDT" {
+		t.Errorf("status reported %v, %v, want the run's progress", run.GetProgress(), err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := client.StreamEvents(ctx, &controlpb.RunRequest{Id: job.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan *controlpb.Event, 1)
+	go func() {
+		if event, err := stream.Recv(); err == nil {
+			received <- event
+		}
+	}()
+	var event *controlpb.Event
+	for event == nil {
+		control.publish(notifyEvent{Time: time.Now(), RunID: "run1", Symbol: "BTCUSDT", Side: "BUY", Event: AuditPlaced, Args: []any{"order_id", "7"}})
+		select {
+		case event = <-received:
+		case <-time.After(50 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("no event streamed")
+		}
+	}
+	if event.Type != "order_placed" || event.JobId != job.ID || event.Data.GetFields()["order_id"].GetStringValue() != "7" {
+		t.Errorf("streamed event %v, want the placed order of job %s", event, job.ID)
+	}
+
+	shutdown()
+	setJobStatus(daemon, job.ID, JobCompleted)
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("stream ended with %v, want the end of the stream once the job completed", err)
+			}
+			break
+		}
+	}
+}
+
+func TestRunServiceRequiresToken(t *testing.T) {
+	daemon, err := openDaemon(t.TempDir(), "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dialRunService(t, daemon, "secret")
+	request := &controlpb.RunRequest{Id: "1"}
+
+	for _, token := range []string{"", "wrong"} {
+		ctx := context.Background()
+		if token != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+		}
+		if _, err := client.GetStatus(ctx, request); status.Code(err) != codes.Unauthenticated {
+			t.Errorf("call with token %q returned %v, want Unauthenticated", token, err)
+		}
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	if _, err := client.GetStatus(ctx, request); status.Code(err) != codes.NotFound || !strings.Contains(err.Error(), "no job 1") {
+		t.Errorf("authorized call returned %v, want NotFound", err)
+	}
+}
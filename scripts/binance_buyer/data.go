@@ -0,0 +1,362 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// binanceDataURL serves Binance's public market data dumps
+	binanceDataURL = "https://data.binance.vision"
+	// dumpTimeout bounds the download of one monthly dump
+	dumpTimeout = 5 * time.Minute
+	// maxDumpBytes bounds the size of one monthly dump
+	maxDumpBytes = 512 << 20
+)
+
+// candleHeader names the columns of kline CSV files, as in Binance's dumps
+var candleHeader = []string{"open_time", "open", "high", "low", "close", "volume", "close_time", "quote_volume", "trades"}
+
+// parseCandleRow parses a kline CSV row as found in Binance's dumps, with timestamps in
+// milliseconds or, in newer dumps, microseconds
+func parseCandleRow(row []string) (Candle, error) {
+	if len(row) < 9 {
+		return Candle{}, fmt.Errorf("expected at least 9 columns, got %d", len(row))
+	}
+	var values [9]float64
+	for i := range values {
+		value, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64)
+		if err != nil {
+			return Candle{}, fmt.Errorf("invalid %s %q", candleHeader[i], row[i])
+		}
+		values[i] = value
+	}
+	timestamp := func(value float64) time.Time {
+		if value > 1e14 {
+			return time.UnixMicro(int64(value))
+		}
+		return time.UnixMilli(int64(value))
+	}
+	return Candle{
+		OpenTime:    timestamp(values[0]),
+		Open:        values[1],
+		High:        values[2],
+		Low:         values[3],
+		Close:       values[4],
+		Volume:      values[5],
+		CloseTime:   timestamp(values[6]),
+		QuoteVolume: values[7],
+		Trades:      int(values[8]),
+	}, nil
+}
+
+// readCandleCSV parses kline CSV data, skipping a header row
+func readCandleCSV(r io.Reader) ([]Candle, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	var candles []Candle
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return candles, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && len(row) > 0 && row[0] == candleHeader[0] {
+			continue
+		}
+		candle, err := parseCandleRow(row)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		candles = append(candles, candle)
+	}
+}
+
+// downloadDump fetches the monthly kline dump of symbol from Binance's public data, reporting false when it
+// has not been published
+func downloadDump(baseURL, symbol, interval, month string) ([]Candle, bool, error) {
+	name := fmt.Sprintf("%s-%s-%s", symbol, interval, month)
+	url := fmt.Sprintf("%s/data/spot/monthly/klines/%s/%s/%s.zip", baseURL, symbol, interval, name)
+	ctx, cancel := context.WithTimeout(context.Background(), dumpTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := newHTTPClient().Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("error downloading %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("error downloading %s: status %d", url, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDumpBytes))
+	if err != nil {
+		return nil, false, fmt.Errorf("error downloading %s: %v", url, err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, false, fmt.Errorf("error opening %s: %v", url, err)
+	}
+	for _, file := range archive.File {
+		if !strings.HasSuffix(file.Name, ".csv") {
+			continue
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, false, err
+		}
+		defer f.Close()
+		candles, err := readCandleCSV(f)
+		if err != nil {
+			return nil, false, fmt.Errorf("error parsing %s: %v", file.Name, err)
+		}
+		return candles, true, nil
+	}
+	return nil, false, fmt.Errorf("no CSV file in %s", url)
+}
+
+// downloadMonth stores the candles of one month, from its dump when published and requested, or else from
+// the exchange's kline API, adding to the candles already stored for an incomplete month
+func downloadMonth(store *CandleStore, dataURL, source string, apiClient func() (MarketReader, error), month time.Time, end time.Time) error {
+	key := month.Format("2006-01")
+	_, finished, err := store.monthStatus(month)
+	if err != nil || finished {
+		return err
+	}
+	monthEnd := month.AddDate(0, 1, 0)
+	complete := !monthEnd.After(end)
+
+	var candles []Candle
+	from := "api"
+	if source != "api" && complete {
+		dump, ok, err := downloadDump(dataURL, store.symbol, store.interval, key)
+		if err != nil {
+			return err
+		}
+		if ok {
+			candles, from = dump, "dump"
+		} else if source == "dumps" {
+			return fmt.Errorf("no dump of %s %s for %s", store.symbol, store.interval, key)
+		}
+	}
+	if from == "api" {
+		client, err := apiClient()
+		if err != nil {
+			return err
+		}
+		to := monthEnd.Add(-time.Millisecond)
+		stored, err := store.load(month, to)
+		if err != nil {
+			return err
+		}
+		start := month
+		if len(stored) > 0 {
+			start = stored[len(stored)-1].OpenTime.Add(time.Millisecond)
+		}
+		if end.Before(to) {
+			to = end
+		}
+		page, err := client.GetKlines(store.symbol, store.interval, start, to)
+		if err != nil {
+			return fmt.Errorf("error getting %s klines for %s: %v", store.symbol, key, err)
+		}
+		for _, c := range page {
+			// The candle still open at the end of the download is left for the next update
+			if c.CloseTime.Before(end) {
+				candles = append(candles, c)
+			}
+		}
+	}
+
+	if err := store.saveMonth(month, candles, complete, from); err != nil {
+		return err
+	}
+	log.Printf("Stored %d %s %s candle(s) for %s from %s%s", len(candles), store.symbol, store.interval, key, from,
+		map[bool]string{true: "", false: " (month in progress)"}[complete])
+	return nil
+}
+
+// loadStoredCandles reads the stored candles of symbol and interval opened between start and end, failing
+// when a month of the range has not been downloaded
+func loadStoredCandles(root, symbol, interval string, start, end time.Time) ([]Candle, error) {
+	db, err := openCandleDB(root, false)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	store, err := newCandleStore(db, symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+	for month := monthStart(start); month.Before(end); month = month.AddDate(0, 1, 0) {
+		stored, _, err := store.monthStatus(month)
+		if err != nil {
+			return nil, err
+		}
+		if !stored {
+			return nil, fmt.Errorf("%s %s candles for %s are not in %s. Run trade data download first", symbol, interval, month.Format("2006-01"), root)
+		}
+	}
+	return store.load(start, end.Add(-time.Millisecond))
+}
+
+// monthStart returns the first instant of t's month in UTC
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// runDataDownload stores a symbol's klines in the candle database of a data directory, month by month, skipping
+// the months already complete so an interrupted download resumes where it stopped
+func runDataDownload(args []string) error {
+	fs, common := newFlagSet("data download")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	interval := fs.String("interval", "1m", "Kline interval (e.g., 1m, 1h, 1d)")
+	from := fs.String("from", "", "First day to download (YYYY-MM-DD)")
+	to := fs.String("to", "", "Day to download up to, excluded (YYYY-MM-DD, default now)")
+	dir := fs.String("dir", "data", "Directory of the candle database the candles are stored in")
+	source := fs.String("source", "auto", "Where candles come from: auto (Binance's monthly dumps, the kline API for months without one), dumps or api")
+	dataURL := fs.String("data-url", binanceDataURL, "Base URL of Binance's public data dumps")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+
+	pair := strings.ToUpper(*symbol)
+	if _, ok := klineDurations[*interval]; !ok {
+		return fmt.Errorf("invalid interval %q. Use a kline interval such as 1m, 15m, 1h or 1d", *interval)
+	}
+	start, err := parseDate(*from)
+	if err != nil {
+		return fmt.Errorf("invalid from: %v", err)
+	}
+	end := time.Now()
+	if *to != "" {
+		if end, err = parseDate(*to); err != nil {
+			return fmt.Errorf("invalid to: %v", err)
+		}
+	}
+	if !start.Before(end) {
+		return fmt.Errorf("from must be before to")
+	}
+	if *source != "auto" && *source != "dumps" && *source != "api" {
+		return fmt.Errorf("invalid source: %s. Use auto, dumps or api", *source)
+	}
+
+	db, err := openCandleDB(*dir, true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	store, err := newCandleStore(db, pair, *interval)
+	if err != nil {
+		return err
+	}
+	// The exchange client, and the credentials it needs, are only set up when a month comes from the API
+	var client *ReadOnlyClient
+	apiClient := func() (MarketReader, error) {
+		if client == nil {
+			if client, err = common.readOnlyClient(); err != nil {
+				return nil, err
+			}
+		}
+		return client, nil
+	}
+	for month := monthStart(start); month.Before(end); month = month.AddDate(0, 1, 0) {
+		if err := downloadMonth(store, strings.TrimRight(*dataURL, "/"), *source, apiClient, month, end); err != nil {
+			return err
+		}
+	}
+	log.Printf("%s %s candles from %s stored in %s", pair, *interval, monthStart(start).Format("2006-01"), filepath.Join(*dir, candleDBFile))
+	return nil
+}
+
+// runDataGaps lists the ranges missing from a symbol's stored klines and optionally fills them from the kline
+// API, which updates a store incrementally
+func runDataGaps(args []string) error {
+	fs, common := newFlagSet("data gaps")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	interval := fs.String("interval", "1m", "Kline interval (e.g., 1m, 1h, 1d)")
+	from := fs.String("from", "", "First day to check (YYYY-MM-DD)")
+	to := fs.String("to", "", "Day to check up to, excluded (YYYY-MM-DD, default now)")
+	dir := fs.String("dir", "data", "Directory of the candle database")
+	fill := fs.Bool("fill", false, "Request the missing candles from the exchange and store them")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+
+	pair := strings.ToUpper(*symbol)
+	start, err := parseDate(*from)
+	if err != nil {
+		return fmt.Errorf("invalid from: %v", err)
+	}
+	end := time.Now()
+	if *to != "" {
+		if end, err = parseDate(*to); err != nil {
+			return fmt.Errorf("invalid to: %v", err)
+		}
+	}
+	if !start.Before(end) {
+		return fmt.Errorf("from must be before to")
+	}
+	end = end.Add(-time.Millisecond)
+	db, err := openCandleDB(*dir, false)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	store, err := newCandleStore(db, pair, *interval)
+	if err != nil {
+		return err
+	}
+	candles, err := store.load(start, end)
+	if err != nil {
+		return err
+	}
+	gaps := store.gaps(candles, start, end)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FROM\tTO\tMISSING CANDLES")
+	for _, gap := range gaps {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", gap.Start.UTC().Format(time.DateTime), gap.End.UTC().Format(time.DateTime),
+			int(gap.End.Sub(gap.Start)/store.period)+1)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d %s %s candle(s) stored, %d gap(s)\n", len(candles), pair, *interval, len(gaps))
+	if !*fill || len(gaps) == 0 {
+		return nil
+	}
+
+	client, err := common.readOnlyClient()
+	if err != nil {
+		return err
+	}
+	if _, err := store.fill(client, gaps); err != nil {
+		return err
+	}
+	if candles, err = store.load(start, end); err != nil {
+		return err
+	}
+	fmt.Printf("Filled: %d candle(s) stored, %d gap(s) left\n", len(candles), len(store.gaps(candles, start, end)))
+	return nil
+}
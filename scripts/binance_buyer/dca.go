@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// dcaGracePeriod is how late a scheduled buy can run before it counts as missed
+const dcaGracePeriod = time.Minute
+
+// DCAConfig holds the parameters of a recurring buy plan
+type DCAConfig struct {
+	Exchange   string  `json:"exchange"`
+	Testnet    bool    `json:"testnet,omitempty"`
+	Symbol     string  `json:"symbol"`
+	QuoteAsset string  `json:"quote_asset"`
+	Amount     Decimal `json:"amount"`
+	Schedule   string  `json:"schedule"`
+	Timezone   string  `json:"timezone"`
+	MaxCatchUp int     `json:"max_catch_up"`
+}
+
+// DCAState is the persisted progress of a recurring buy plan
+type DCAState struct {
+	Config     DCAConfig `json:"config"`
+	LastRun    time.Time `json:"last_run"`
+	Buys       int       `json:"buys"`
+	FailedBuys int       `json:"failed_buys"`
+	Fills
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	journal *Journal
+}
+
+// loadDCAState reads a previously persisted recurring buy plan
+func loadDCAState(path string) (*DCAState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file: %v", err)
+	}
+
+	var state DCAState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error parsing state file: %v", err)
+	}
+
+	return &state, nil
+}
+
+// save writes the plan's state to path, logging rather than failing on error
+func (s *DCAState) save(path string) {
+	if path == "" {
+		return
+	}
+
+	s.UpdatedAt = time.Now()
+	if err := writeJSONFile(path, s); err != nil {
+		log.Print(err)
+	}
+}
+
+// runDCA buys the configured amount at every activation of the schedule until ctx is cancelled. Activations
+// missed since the last persisted buy, while the process was stopped or the host asleep, are caught up
+// immediately, up to MaxCatchUp of them.
+func runDCA(ctx context.Context, client ExchangeClient, state *DCAState, statePath string) error {
+	cfg := state.Config
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return fmt.Errorf("error loading timezone: %v", err)
+	}
+	schedule, err := ParseCron(cfg.Schedule)
+	if err != nil {
+		return err
+	}
+	filters, err := client.GetSymbolFilters(cfg.Symbol)
+	if err != nil {
+		return fmt.Errorf("error getting symbol filters for %s: %v", cfg.Symbol, err)
+	}
+
+	if state.LastRun.IsZero() {
+		state.LastRun = time.Now()
+		state.save(statePath)
+	}
+
+	for {
+		var due []time.Time
+		now := time.Now()
+		for next := schedule.Next(state.LastRun.In(location)); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+			due = append(due, next)
+		}
+		missed := 0
+		for missed < len(due) && now.Sub(due[missed]) > dcaGracePeriod {
+			missed++
+		}
+		if skipped := missed - cfg.MaxCatchUp; skipped > 0 {
+			log.Printf("Skipping %d missed buy(s), the last one scheduled at %s", skipped, due[skipped-1].Format(time.RFC3339))
+			state.LastRun = due[skipped-1]
+			state.save(statePath)
+			due = due[skipped:]
+		}
+		for _, at := range due {
+			if ctx.Err() != nil {
+				break
+			}
+			if time.Since(at) > dcaGracePeriod {
+				log.Printf("Catching up buy missed at %s", at.Format(time.RFC3339))
+			}
+			buyDCA(client, state, filters)
+			state.LastRun = at
+			state.save(statePath)
+		}
+
+		next := schedule.Next(state.LastRun.In(location))
+		if next.IsZero() {
+			return fmt.Errorf("schedule %q has no upcoming activations", schedule)
+		}
+		log.Printf("Next buy of %s %s of %s at %s", cfg.Amount, cfg.QuoteAsset, cfg.Symbol, next.Format(time.RFC3339))
+		if !sleepContext(ctx, time.Until(next)) {
+			log.Printf("DCA stopped after %d buy(s): %s %s spent for %s %s", state.Buys, state.FilledQuote, cfg.QuoteAsset, state.FilledBase, cfg.Symbol)
+			return nil
+		}
+	}
+}
+
+// buyDCA places one market buy of the plan's amount and records its fill
+func buyDCA(client ExchangeClient, state *DCAState, filters *SymbolFilters) {
+	cfg := state.Config
+	quote := filters.RoundQuote(cfg.Amount)
+	if err := filters.ValidateNotional(quote); err != nil {
+		slog.Warn("Skipping DCA buy", "symbol", cfg.Symbol, "quote_qty", quote, "error", err)
+		state.FailedBuys++
+		return
+	}
+
+	order, err := client.PlaceOrder(OrderRequest{
+		Symbol:        cfg.Symbol,
+		Side:          "BUY",
+		Type:          OrderTypeMarket,
+		QuoteQuantity: quote,
+	})
+	if err != nil {
+		slog.Error("Error placing DCA buy", "symbol", cfg.Symbol, "quote_qty", quote, "error", err)
+		state.FailedBuys++
+		return
+	}
+	slog.Info("DCA buy placed", "symbol", cfg.Symbol, "order_id", order.OrderID, "status", order.Status,
+		"qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty)
+	state.Buys++
+	state.Fills.add(order)
+	if err := state.journal.Record(order, "", "", 0); err != nil {
+		log.Printf("Error recording order %s in journal: %v", order.OrderID, err)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// decimalDisplayPlaces bounds the decimals printed for values without a finite decimal representation
+const decimalDisplayPlaces = 18
+
+// Decimal is an exact decimal number used for money and quantity math. It is backed by big.Rat, so budgets
+// tracked over thousands of slices accumulate no rounding error. The zero value is 0 and values are immutable.
+type Decimal struct {
+	r *big.Rat
+}
+
+// zeroRat backs the zero value of Decimal and must never be modified
+var zeroRat = new(big.Rat)
+
+// ParseDecimal parses a decimal string such as "0.00100000"
+func ParseDecimal(s string) (Decimal, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", s)
+	}
+	return Decimal{r}, nil
+}
+
+// decimalOrZero parses a decimal string from an exchange response, treating empty or malformed values as zero
+func decimalOrZero(s string) Decimal {
+	d, _ := ParseDecimal(s)
+	return d
+}
+
+// NewDecimalFromFloat converts a float using its shortest decimal representation, so 0.1 becomes exactly 0.1
+func NewDecimalFromFloat(f float64) Decimal {
+	return decimalOrZero(strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// NewDecimalFromInt converts an integer
+func NewDecimalFromInt(i int64) Decimal {
+	return Decimal{new(big.Rat).SetInt64(i)}
+}
+
+// rat returns the underlying value, which callers must not modify
+func (d Decimal) rat() *big.Rat {
+	if d.r == nil {
+		return zeroRat
+	}
+	return d.r
+}
+
+// Add returns d + other
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{new(big.Rat).Add(d.rat(), other.rat())}
+}
+
+// Sub returns d - other
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{new(big.Rat).Sub(d.rat(), other.rat())}
+}
+
+// Mul returns d * other
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{new(big.Rat).Mul(d.rat(), other.rat())}
+}
+
+// MulFloat returns d scaled by a float factor such as a jitter or volume weight
+func (d Decimal) MulFloat(factor float64) Decimal {
+	return d.Mul(NewDecimalFromFloat(factor))
+}
+
+// Div returns d / other, or zero when other is zero
+func (d Decimal) Div(other Decimal) Decimal {
+	if other.IsZero() {
+		return Decimal{}
+	}
+	return Decimal{new(big.Rat).Quo(d.rat(), other.rat())}
+}
+
+// Cmp compares d and other, returning -1, 0 or +1
+func (d Decimal) Cmp(other Decimal) int {
+	return d.rat().Cmp(other.rat())
+}
+
+// LessThan reports whether d < other
+func (d Decimal) LessThan(other Decimal) bool {
+	return d.Cmp(other) < 0
+}
+
+// GreaterThan reports whether d > other
+func (d Decimal) GreaterThan(other Decimal) bool {
+	return d.Cmp(other) > 0
+}
+
+// Sign returns -1, 0 or +1 depending on the sign of d
+func (d Decimal) Sign() int {
+	return d.rat().Sign()
+}
+
+// IsZero reports whether d is zero
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Float64 returns the nearest float, for market math and display
+func (d Decimal) Float64() float64 {
+	f, _ := d.rat().Float64()
+	return f
+}
+
+// FloorToStep rounds d down to a multiple of step. A non-positive step leaves d unchanged.
+func (d Decimal) FloorToStep(step Decimal) Decimal {
+	if step.Sign() <= 0 {
+		return d
+	}
+	q := new(big.Rat).Quo(d.rat(), step.rat())
+	steps := new(big.Int).Div(q.Num(), q.Denom())
+	return Decimal{new(big.Rat).Mul(new(big.Rat).SetInt(steps), step.rat())}
+}
+
+// CeilToStep rounds d up to a multiple of step. A non-positive step leaves d unchanged.
+func (d Decimal) CeilToStep(step Decimal) Decimal {
+	if step.Sign() <= 0 {
+		return d
+	}
+	return d.Neg().FloorToStep(step).Neg()
+}
+
+// Truncate rounds d down to the given number of decimal places
+func (d Decimal) Truncate(places int) Decimal {
+	return d.FloorToStep(Decimal{new(big.Rat).SetFrac(big.NewInt(1), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil))})
+}
+
+// Neg returns -d
+func (d Decimal) Neg() Decimal {
+	return Decimal{new(big.Rat).Neg(d.rat())}
+}
+
+// String formats d without trailing zeros, as expected by exchange APIs
+func (d Decimal) String() string {
+	if d.rat().IsInt() {
+		return d.rat().Num().String()
+	}
+	s := strings.TrimRight(d.rat().FloatString(decimalDisplayPlaces), "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// StringFixed formats d rounded to the given number of decimal places
+func (d Decimal) StringFixed(places int) string {
+	return d.rat().FloatString(places)
+}
+
+// MarshalJSON encodes d as a JSON string so no precision is lost
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	return strconv.AppendQuote(nil, d.String()), nil
+}
+
+// UnmarshalJSON decodes d from a JSON string or number, so state files written with floats still load
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*d = Decimal{}
+		return nil
+	}
+	parsed, err := ParseDecimal(strings.Trim(string(data), `"`))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// minDecimal returns the smaller of a and b
+func minDecimal(a, b Decimal) Decimal {
+	if a.LessThan(b) {
+		return a
+	}
+	return b
+}
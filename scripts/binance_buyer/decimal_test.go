@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecimalArithmetic(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{name: "add", got: decimalOrZero("0.1").Add(decimalOrZero("0.2")), want: "0.3"},
+		{name: "sub", got: decimalOrZero("1").Sub(decimalOrZero("0.00000001")), want: "0.99999999"},
+		{name: "mul", got: decimalOrZero("0.001").Mul(decimalOrZero("50000.5")), want: "50.0005"},
+		{name: "mul float", got: decimalOrZero("100").MulFloat(0.1), want: "10"},
+		{name: "div", got: decimalOrZero("1").Div(decimalOrZero("4")), want: "0.25"},
+		{name: "div by zero", got: decimalOrZero("1").Div(Decimal{}), want: "0"},
+		{name: "neg", got: decimalOrZero("2.5").Neg(), want: "-2.5"},
+		{name: "zero value", got: Decimal{}.Add(Decimal{}), want: "0"},
+		{name: "from float", got: NewDecimalFromFloat(0.1), want: "0.1"},
+		{name: "from int", got: NewDecimalFromInt(-42), want: "-42"},
+		{name: "min", got: minDecimal(decimalOrZero("3"), decimalOrZero("2.9")), want: "2.9"},
+	}
+	for _, tt := range tests {
+		if got := tt.got.String(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalAccumulatesNoRoundingError(t *testing.T) {
+	total := decimalOrZero("100")
+	slice := total.Div(NewDecimalFromInt(3))
+	var spent Decimal
+	for range 3 {
+		spent = spent.Add(slice)
+	}
+	if spent.Cmp(total) != 0 {
+		t.Errorf("three thirds of 100 add up to %s", spent)
+	}
+}
+
+func TestDecimalRounding(t *testing.T) {
+	tests := []struct {
+		name string
+		got  Decimal
+		want string
+	}{
+		{name: "floor to step", got: decimalOrZero("0.123456").FloorToStep(decimalOrZero("0.001")), want: "0.123"},
+		{name: "floor exact multiple", got: decimalOrZero("0.125").FloorToStep(decimalOrZero("0.005")), want: "0.125"},
+		{name: "floor to coarse step", got: decimalOrZero("27").FloorToStep(decimalOrZero("5")), want: "25"},
+		{name: "floor negative", got: decimalOrZero("-0.1234").FloorToStep(decimalOrZero("0.01")), want: "-0.13"},
+		{name: "floor without step", got: decimalOrZero("0.123456").FloorToStep(Decimal{}), want: "0.123456"},
+		{name: "ceil to step", got: decimalOrZero("0.1231").CeilToStep(decimalOrZero("0.001")), want: "0.124"},
+		{name: "ceil exact multiple", got: decimalOrZero("0.124").CeilToStep(decimalOrZero("0.001")), want: "0.124"},
+		{name: "ceil negative", got: decimalOrZero("-0.1234").CeilToStep(decimalOrZero("0.01")), want: "-0.12"},
+		{name: "truncate", got: decimalOrZero("1.99999").Truncate(2), want: "1.99"},
+		{name: "truncate to integer", got: decimalOrZero("1.99999").Truncate(0), want: "1"},
+	}
+	for _, tt := range tests {
+		if got := tt.got.String(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecimalFormatting(t *testing.T) {
+	tests := []struct {
+		value  Decimal
+		string string
+		fixed2 string
+	}{
+		{value: decimalOrZero("0.00100000"), string: "0.001", fixed2: "0.00"},
+		{value: decimalOrZero("12.345"), string: "12.345", fixed2: "12.35"},
+		{value: decimalOrZero("-12.345"), string: "-12.345", fixed2: "-12.35"},
+		{value: decimalOrZero("100"), string: "100", fixed2: "100.00"},
+		{value: decimalOrZero("1").Div(decimalOrZero("3")), string: "0.333333333333333333", fixed2: "0.33"},
+		{value: Decimal{}, string: "0", fixed2: "0.00"},
+	}
+	for _, tt := range tests {
+		if got := tt.value.String(); got != tt.string {
+			t.Errorf("String() = %s, want %s", got, tt.string)
+		}
+		if got := tt.value.StringFixed(2); got != tt.fixed2 {
+			t.Errorf("StringFixed(2) of %s = %s, want %s", tt.string, got, tt.fixed2)
+		}
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "0.00100000", want: "0.001"},
+		{input: " 42 ", want: "42"},
+		{input: "-1.5", want: "-1.5"},
+		{input: "1e-8", want: "0.00000001"},
+		{input: "", wantErr: true},
+		{input: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDecimal(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDecimal(%q) error = %v, want error %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("ParseDecimal(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+	if !decimalOrZero("not a number").IsZero() {
+		t.Error("decimalOrZero of a malformed value is not zero")
+	}
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var v struct {
+		A Decimal `json:"a"`
+		B Decimal `json:"b"`
+		C Decimal `json:"c"`
+	}
+	if err := json.Unmarshal([]byte(`{"a": "0.10000000", "b": 2.5, "c": null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.A.String() != "0.1" || v.B.String() != "2.5" || !v.C.IsZero() {
+		t.Errorf("decoded %s, %s, %s, want 0.1, 2.5, 0", v.A, v.B, v.C)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"a":"0.1","b":"2.5","c":"0"}` {
+		t.Errorf("encoded %s", data)
+	}
+	if err := json.Unmarshal([]byte(`{"a": "x"}`), &v); err == nil {
+		t.Error("decoding a malformed decimal succeeded")
+	}
+}
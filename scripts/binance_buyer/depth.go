@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log"
+	"log/slog"
+)
+
+// maxDepthLevels is the deepest order book snapshot a slice is sized against
+const maxDepthLevels = 100
+
+// DepthLimit caps each market slice to MaxPct percent of the quote liquidity in the top Levels of the
+// opposite side of the order book, where a zero MaxPct is disabled
+type DepthLimit struct {
+	MaxPct float64 `json:"max_pct"`
+	Levels int     `json:"levels"`
+}
+
+// Enabled reports whether the cap is set
+func (d DepthLimit) Enabled() bool {
+	return d.MaxPct > 0
+}
+
+// BookLevel is a price level of an order book
+type BookLevel struct {
+	Price float64
+	Qty   float64
+}
+
+// OrderBook is a snapshot of the best levels of a symbol's order book, best prices first
+type OrderBook struct {
+	Bids []BookLevel
+	Asks []BookLevel
+}
+
+// OrderBookProvider is implemented by clients that can fetch order book snapshots
+type OrderBookProvider interface {
+	GetOrderBook(symbol string, levels int) (*OrderBook, error)
+}
+
+// sideLevels returns the top levels of the book side a market order on side consumes
+func (b *OrderBook) sideLevels(side string, levels int) []BookLevel {
+	book := b.Asks
+	if side == "SELL" {
+		book = b.Bids
+	}
+	return book[:min(levels, len(book))]
+}
+
+// quoteDepth returns the quote value of the top levels of the book side a market order on side consumes
+func (b *OrderBook) quoteDepth(side string, levels int) float64 {
+	var depth float64
+	for _, level := range b.sideLevels(side, levels) {
+		depth += level.Price * level.Qty
+	}
+	return depth
+}
+
+// baseDepth returns the base quantity of the top levels of the book side a market order on side consumes
+func (b *OrderBook) baseDepth(side string, levels int) float64 {
+	var depth float64
+	for _, level := range b.sideLevels(side, levels) {
+		depth += level.Qty
+	}
+	return depth
+}
+
+// capToDepth limits a market slice to the configured share of the visible liquidity, measured in the asset
+// of the run's budget. The amount is returned unchanged when the cap is disabled or the order book cannot be fetched.
+func capToDepth(client ExchangeClient, cfg TWAPConfig, amount Decimal) Decimal {
+	if !cfg.Depth.Enabled() || cfg.OrderType != OrderTypeMarket {
+		return amount
+	}
+	provider, ok := baseClient(client).(OrderBookProvider)
+	if !ok {
+		return amount
+	}
+	book, err := provider.GetOrderBook(cfg.Symbol, cfg.Depth.Levels)
+	if err != nil {
+		log.Printf("Error getting order book, slice is not capped to depth: %v", err)
+		return amount
+	}
+
+	depth := book.quoteDepth(cfg.Side, cfg.Depth.Levels)
+	if cfg.BaseAmount {
+		depth = book.baseDepth(cfg.Side, cfg.Depth.Levels)
+	}
+	limit := NewDecimalFromFloat(depth * cfg.Depth.MaxPct / 100)
+	if !amount.GreaterThan(limit) {
+		return amount
+	}
+	slog.Info("Slice capped to order book depth", "symbol", cfg.Symbol, "amount", amount, "capped", limit, "levels", cfg.Depth.Levels, "max_pct", cfg.Depth.MaxPct)
+	return limit
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Email summary schedules
+const (
+	// EmailEnd sends one summary when the run ends
+	EmailEnd = "end"
+	// EmailDaily also sends a summary every 24 hours while the run is in progress
+	EmailDaily = "daily"
+)
+
+// Email delivery limits
+const (
+	emailDialTimeout  = 10 * time.Second
+	emailSendTimeout  = time.Minute
+	emailFlushTimeout = 2 * time.Minute
+	// maxEmailFills bounds the rows of the fills table, which lists the latest fills
+	maxEmailFills = 500
+)
+
+// SMTPConfig is the mail server and the addresses summary emails are sent from and to
+type SMTPConfig struct {
+	// Addr is the server's host:port. Port 465 uses implicit TLS, other ports STARTTLS when offered.
+	Addr     string
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// EmailReporter emails HTML summaries of a run, with its fills table and a chart of its fill prices
+type EmailReporter struct {
+	smtp     SMTPConfig
+	daily    bool
+	lastSent time.Time
+	pending  sync.WaitGroup
+}
+
+// NewEmailReporter returns a reporter sending summaries on schedule (end or daily)
+func NewEmailReporter(cfg SMTPConfig, schedule string) (*EmailReporter, error) {
+	if cfg.Addr == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("email summaries require -smtp-addr, -email-from and -email-to")
+	}
+	if _, _, err := net.SplitHostPort(cfg.Addr); err != nil {
+		return nil, fmt.Errorf("invalid SMTP address %s: use host:port", cfg.Addr)
+	}
+	switch strings.ToLower(schedule) {
+	case EmailEnd, EmailDaily:
+	default:
+		return nil, fmt.Errorf("invalid email schedule: %s. Use end or daily", schedule)
+	}
+	return &EmailReporter{smtp: cfg, daily: strings.ToLower(schedule) == EmailDaily, lastSent: time.Now()}, nil
+}
+
+// Send renders a summary of the report and sends it in the background. A nil reporter sends nothing.
+func (e *EmailReporter) Send(report *RunReport, runID, status string) {
+	if e == nil {
+		return
+	}
+	e.lastSent = time.Now()
+	subject := fmt.Sprintf("[Binance Buyer] %s %s run %s: %s", report.Side, report.Symbol, runID, status)
+	msg, err := e.render(report, subject, runID, status)
+	if err != nil {
+		log.Printf("Error rendering summary email: %v", err)
+		return
+	}
+
+	e.pending.Add(1)
+	go func() {
+		defer e.pending.Done()
+		if err := sendMail(e.smtp, msg); err != nil {
+			log.Printf("Error sending summary email: %v", err)
+			return
+		}
+		log.Printf("Summary email sent to %s", strings.Join(e.smtp.To, ", "))
+	}()
+}
+
+// Close waits for the summaries being sent, at most emailFlushTimeout. A nil reporter is a no-op.
+func (e *EmailReporter) Close() {
+	if e == nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		e.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(emailFlushTimeout):
+		log.Printf("Timed out sending summary email")
+	}
+}
+
+// emailDaily sends a summary of the run so far once 24 hours have passed since the last one, when daily
+// summaries are enabled
+func (s *RunState) emailDaily() {
+	if s.emailer == nil || !s.emailer.daily || time.Since(s.emailer.lastSent) < 24*time.Hour {
+		return
+	}
+	report := newRunReport(s)
+	report.FinishedAt = time.Now()
+	s.emailer.Send(report, s.RunID, fmt.Sprintf("daily summary, slice %d/%d", s.NextSlice, s.TotalSlices))
+}
+
+// emailTemplate is the HTML body of summary emails
+var emailTemplate = template.Must(template.New("email").Parse(`<!DOCTYPE html>
+<html><body style="font-family: sans-serif; font-size: 14px;">
+<h2>{{.Report.Side}} {{.Report.Symbol}}: {{.Status}}</h2>
+<table cellpadding="4" style="border-collapse: collapse;">
+<tr><td>Run ID</td><td>{{.RunID}}</td></tr>
+<tr><td>Period</td><td>{{.Report.StartedAt.Format "2006-01-02 15:04:05 MST"}} to {{.Report.FinishedAt.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+{{if .Report.TargetBase.IsZero}}<tr><td>Quote filled</td><td>{{.Report.FilledQuote}} / {{.Report.TargetQuote}} {{.Report.QuoteAsset}}</td></tr>
+<tr><td>Base filled</td><td>{{.Report.FilledBase}}</td></tr>
+{{else}}<tr><td>Base filled</td><td>{{.Report.FilledBase}} / {{.Report.TargetBase}}</td></tr>
+<tr><td>Quote filled</td><td>{{.Report.FilledQuote}} {{.Report.QuoteAsset}}</td></tr>
+{{end}}<tr><td>Average fill price</td><td>{{printf "%.8f" .Report.AverageFillPrice}}</td></tr>
+<tr><td>vs market TWAP</td><td>{{printf "%.8f" .Report.MarketTWAP}} ({{printf "%+.2f" .Report.VsTWAPBps}} bps)</td></tr>
+{{if .Report.MarketVWAP}}<tr><td>vs market VWAP</td><td>{{printf "%.8f" .Report.MarketVWAP}} ({{printf "%+.2f" .Report.VsVWAPBps}} bps)</td></tr>
+{{end}}<tr><td>Slippage vs mid</td><td>{{printf "%+.2f" .Report.SlippageBps}} bps</td></tr>
+{{range $asset, $fee := .Report.Fees}}<tr><td>Fees paid</td><td>{{$fee}} {{$asset}}</td></tr>
+{{end}}{{if .Report.PriceWithFees}}<tr><td>Fees in {{.Report.QuoteAsset}}</td><td>{{.Report.FeesQuote}}</td></tr>
+<tr><td>Price with fees</td><td>{{printf "%.8f" .Report.PriceWithFees}}</td></tr>
+{{end}}{{if .Report.Currency}}<tr><td>Filled in {{.Report.Currency}}</td><td>{{.Report.FilledFiat.StringFixed 2}} at {{printf "%.8f" .Report.AveragePriceFiat}} average{{if .Report.FiatMissed}} ({{.Report.FiatMissed}} fills without an FX rate){{end}}</td></tr>
+{{if .Report.FeesFiat.Sign}}<tr><td>Fees in {{.Report.Currency}}</td><td>{{.Report.FeesFiat.StringFixed 2}}</td></tr>
+{{end}}{{end}}<tr><td>Slices</td><td>{{.Report.PlannedSlices}} planned, {{.Report.PlacedSlices}} placed, {{.Report.FailedSlices}} failed</td></tr>
+</table>
+{{if .Chart}}<h3>Fill prices</h3>
+<img src="cid:fill-prices" width="{{.ChartWidth}}" height="{{.ChartHeight}}" alt="Fill prices">
+<p style="color: #666;">In order of execution, from {{printf "%.8f" .MinPrice}} to {{printf "%.8f" .MaxPrice}}. The grey line is the average fill price.</p>
+{{end}}<h3>Fills</h3>
+{{if .Omitted}}<p>The {{.Omitted}} earliest fills are not listed.</p>
+{{end}}<table cellpadding="4" border="1" style="border-collapse: collapse;">
+<tr><th>Time</th><th>Order ID</th><th>Account</th><th>Quantity</th><th>Quote</th><th>Price</th><th>Fee</th></tr>
+{{range .Fills}}<tr><td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.OrderID}}</td><td>{{.Account}}</td><td>{{.Qty}}</td><td>{{.Quote}}</td><td>{{printf "%.8f" .Price}}</td><td>{{if .CommissionAsset}}{{.Commission}} {{.CommissionAsset}}{{end}}</td></tr>
+{{else}}<tr><td colspan="7">No fills</td></tr>
+{{end}}</table>
+</body></html>
+`))
+
+// Fill price chart size in pixels
+const (
+	chartWidth  = 640
+	chartHeight = 240
+	chartMargin = 10
+)
+
+// render builds the MIME message of a summary: the HTML body and, with at least two fills, the fill price
+// chart as an inline image
+func (e *EmailReporter) render(report *RunReport, subject, runID, status string) ([]byte, error) {
+	fills := report.Fills
+	omitted := max(0, len(fills)-maxEmailFills)
+	data := map[string]any{
+		"Report": report, "RunID": runID, "Status": status, "Fills": fills[omitted:], "Omitted": omitted,
+		"ChartWidth": chartWidth, "ChartHeight": chartHeight,
+	}
+	var chart []byte
+	if len(fills) >= 2 {
+		var err error
+		var low, high float64
+		if chart, low, high, err = chartFillPrices(fills, report.AverageFillPrice); err != nil {
+			return nil, err
+		}
+		data["Chart"], data["MinPrice"], data["MaxPrice"] = true, low, high
+	}
+	var body bytes.Buffer
+	if err := emailTemplate.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("error rendering email: %v", err)
+	}
+
+	var msg bytes.Buffer
+	parts := multipart.NewWriter(&msg)
+	header := []string{
+		"From: " + e.smtp.From,
+		"To: " + strings.Join(e.smtp.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		fmt.Sprintf(`Content-Type: multipart/related; boundary="%s"`, parts.Boundary()),
+	}
+	msg.WriteString(strings.Join(header, "\r\n") + "\r\n\r\n")
+
+	part, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error building email: %v", err)
+	}
+	qp := quotedprintable.NewWriter(part)
+	qp.Write(body.Bytes())
+	qp.Close()
+
+	if chart != nil {
+		part, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {"image/png"},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-ID":                {"<fill-prices>"},
+			"Content-Disposition":       {`inline; filename="fill-prices.png"`},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error building email: %v", err)
+		}
+		// Base64 lines are wrapped at 76 characters as MIME requires
+		encoded := base64.StdEncoding.EncodeToString(chart)
+		for len(encoded) > 76 {
+			fmt.Fprintf(part, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(part, "%s\r\n", encoded)
+	}
+	if err := parts.Close(); err != nil {
+		return nil, fmt.Errorf("error building email: %v", err)
+	}
+	return msg.Bytes(), nil
+}
+
+// chartFillPrices draws the fill prices in order of execution as a PNG line chart with the average price as
+// a grey line, returning the image and the lowest and highest price
+func chartFillPrices(fills []FillRecord, average float64) ([]byte, float64, float64, error) {
+	low, high := fills[0].Price, fills[0].Price
+	for _, fill := range fills {
+		low, high = min(low, fill.Price), max(high, fill.Price)
+	}
+	span := high - low
+	if span == 0 {
+		span = 1
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	point := func(i int, price float64) (int, int) {
+		x := chartMargin + i*(chartWidth-2*chartMargin)/(len(fills)-1)
+		y := chartHeight - chartMargin - int((price-low)/span*float64(chartHeight-2*chartMargin))
+		return x, y
+	}
+
+	grey := color.RGBA{0xaa, 0xaa, 0xaa, 0xff}
+	blue := color.RGBA{0x1f, 0x77, 0xb4, 0xff}
+	if average >= low && average <= high {
+		_, y := point(0, average)
+		drawLine(img, chartMargin, y, chartWidth-chartMargin, y, grey)
+	}
+	x0, y0 := point(0, fills[0].Price)
+	for i, fill := range fills[1:] {
+		x1, y1 := point(i+1, fill.Price)
+		drawLine(img, x0, y0, x1, y1, blue)
+		x0, y0 = x1, y1
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, 0, 0, fmt.Errorf("error encoding chart: %v", err)
+	}
+	return buf.Bytes(), low, high, nil
+}
+
+// drawLine draws a two pixel wide line from (x0, y0) to (x1, y1) with Bresenham's algorithm
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	for e := dx + dy; ; {
+		img.SetRGBA(x0, y0, c)
+		img.SetRGBA(x0, y0+1, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// abs returns the absolute value of an integer
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// sendMail delivers a message over SMTP, with implicit TLS on port 465 and STARTTLS elsewhere when the
+// server offers it. Authentication is only attempted when a username is configured.
+func sendMail(cfg SMTPConfig, msg []byte) error {
+	host, port, _ := net.SplitHostPort(cfg.Addr)
+	dialer := &net.Dialer{Timeout: emailDialTimeout}
+	var conn net.Conn
+	var err error
+	if port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.Addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.Addr)
+	}
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %v", cfg.Addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(emailSendTimeout))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error starting SMTP session: %v", err)
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); ok && port != "465" {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("error starting TLS: %v", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, host)); err != nil {
+			return fmt.Errorf("error authenticating: %v", err)
+		}
+	}
+	if err := client.Mail(cfg.From); err != nil {
+		return fmt.Errorf("error setting sender: %v", err)
+	}
+	for _, to := range cfg.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("error adding recipient %s: %v", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("error sending message: %v", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("error sending message: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error sending message: %v", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// MarketReader is the set of exchange operations that read market data and account state without changing them
+type MarketReader interface {
+	GetPrice(symbol string) (float64, error)
+	GetBookTicker(symbol string) (*BookTicker, error)
+	GetSymbolFilters(symbol string) (*SymbolFilters, error)
+	GetKlines(symbol, interval string, start, end time.Time) ([]Candle, error)
+	GetBalance(asset string) (Decimal, error)
+	GetOrder(symbol, orderID string) (*Order, error)
+}
+
+// ExchangeClient is the set of exchange operations the scheduler depends on
+type ExchangeClient interface {
+	MarketReader
+	PlaceOrder(req OrderRequest) (*Order, error)
+	CancelOrder(symbol, orderID string) error
+}
+
+// TimeSyncer is implemented by clients whose signed requests depend on the exchange server clock
+type TimeSyncer interface {
+	SyncTime() (time.Duration, error)
+}
+
+// EndpointOverrider is implemented by clients whose REST and WebSocket base URLs can be replaced, e.g. to
+// reach the exchange through a gateway
+type EndpointOverrider interface {
+	SetEndpoints(restURLs []string, wsURL string) error
+}
+
+// OrderHistory is implemented by clients that can list open orders and past trades, which is used to
+// reconcile a run's state with the exchange
+type OrderHistory interface {
+	GetOpenOrders(symbol string) ([]*Order, error)
+	GetTrades(symbol string, since, until time.Time) ([]Trade, error)
+}
+
+// OrderTrades is implemented by clients that can list the trades of a single order, which supplies the
+// commissions that order queries do not report
+type OrderTrades interface {
+	GetOrderTrades(symbol, orderID string) ([]Trade, error)
+}
+
+// AccountBalances is implemented by clients that can list every balance of the account at once, which account
+// snapshots value
+type AccountBalances interface {
+	GetBalances() ([]Balance, error)
+}
+
+// baseClient returns the exchange client underneath any decorators wrapping it
+func baseClient(client ExchangeClient) ExchangeClient {
+	for {
+		wrapper, ok := client.(interface{ Unwrap() ExchangeClient })
+		if !ok {
+			return client
+		}
+		client = wrapper.Unwrap()
+	}
+}
+
+// OrderRequest describes an order to be submitted to an exchange
+type OrderRequest struct {
+	Symbol        string
+	Side          string
+	Type          string
+	QuoteQuantity Decimal
+	Quantity      Decimal
+	Price         Decimal
+	// StopPrice is the price that triggers a stop-limit order
+	StopPrice   Decimal
+	TimeInForce string
+	// ClientOrderID, when set, identifies the order so a retried request cannot place it twice
+	ClientOrderID string
+	// ReduceOnly restricts a futures order to reducing the open position. Spot clients ignore it.
+	ReduceOnly bool
+	// SideEffect is the borrowing and repaying a margin order does, one of the MarginSideEffect values.
+	// Empty leaves it to the exchange, and non-margin clients ignore it.
+	SideEffect string
+}
+
+// validateStopLimit checks that a stop-limit order has the quantity and prices it needs
+func (r OrderRequest) validateStopLimit() error {
+	if r.Quantity.Sign() <= 0 {
+		return fmt.Errorf("%s orders need a base quantity", r.Type)
+	}
+	if r.Price.Sign() <= 0 || r.StopPrice.Sign() <= 0 {
+		return fmt.Errorf("%s orders need a limit price and a stop price", r.Type)
+	}
+	return nil
+}
+
+// BookTicker holds the best bid and ask of a symbol
+type BookTicker struct {
+	Symbol   string
+	BidPrice float64
+	AskPrice float64
+}
+
+// Mid returns the mid-price between the best bid and ask
+func (t *BookTicker) Mid() float64 {
+	return (t.BidPrice + t.AskPrice) / 2
+}
+
+// Candle is a single OHLCV kline
+type Candle struct {
+	OpenTime    time.Time
+	CloseTime   time.Time
+	Open        float64
+	High        float64
+	Low         float64
+	Close       float64
+	Volume      float64
+	QuoteVolume float64
+	Trades      int
+}
+
+// Order represents an order as reported by an exchange
+type Order struct {
+	Symbol          string    `json:"symbol"`
+	OrderID         string    `json:"order_id"`
+	ClientOrderID   string    `json:"client_order_id"`
+	Price           string    `json:"price"`
+	StopPrice       string    `json:"stop_price,omitempty"`
+	OrigQty         string    `json:"orig_qty"`
+	ExecutedQty     string    `json:"executed_qty"`
+	CumQuoteQty     string    `json:"cum_quote_qty"`
+	Status          string    `json:"status"`
+	Type            string    `json:"type"`
+	Side            string    `json:"side"`
+	Commission      Decimal   `json:"commission,omitzero"`
+	CommissionAsset string    `json:"commission_asset,omitempty"`
+	CreatedAt       time.Time `json:"created_at,omitzero"`
+	// Account is the account of a multi-account run the order was placed on
+	Account string `json:"account,omitempty"`
+}
+
+// Trade is a single execution of an order
+type Trade struct {
+	// ID is the exchange's ID of the trade
+	ID              string    `json:"id"`
+	Symbol          string    `json:"symbol"`
+	OrderID         string    `json:"order_id"`
+	Side            string    `json:"side"`
+	Price           Decimal   `json:"price"`
+	Qty             Decimal   `json:"qty"`
+	QuoteQty        Decimal   `json:"quote_qty"`
+	Commission      Decimal   `json:"commission"`
+	CommissionAsset string    `json:"commission_asset"`
+	Time            time.Time `json:"time"`
+	Account         string    `json:"account,omitempty"`
+}
+
+// Normalized order statuses shared by all exchange clients
+const (
+	OrderStatusNew             = "NEW"
+	OrderStatusPartiallyFilled = "PARTIALLY_FILLED"
+	OrderStatusFilled          = "FILLED"
+	OrderStatusCanceled        = "CANCELED"
+	OrderStatusExpired         = "EXPIRED"
+	OrderStatusRejected        = "REJECTED"
+)
+
+// isTerminalStatus reports whether an order with the given status can no longer fill
+func isTerminalStatus(status string) bool {
+	switch status {
+	case OrderStatusFilled, OrderStatusCanceled, OrderStatusExpired, OrderStatusRejected, "EXPIRED_IN_MATCH":
+		return true
+	}
+	return false
+}
+
+// Markets a run can trade on
+const (
+	MarketSpot           = "spot"
+	MarketMargin         = "margin"
+	MarketIsolatedMargin = "isolated-margin"
+	MarketFutures        = "futures"
+)
+
+// Supported order types. LIMIT_MAKER orders are post-only limit orders, rejected instead of taking
+// liquidity. Stop-limit orders rest untriggered until the price reaches their stop price and
+// then become limit orders at their price: a stop-loss triggers when the price moves against the order's side
+// (falls for a SELL, rises for a BUY) and a take-profit when it moves in its favor.
+const (
+	OrderTypeMarket          = "MARKET"
+	OrderTypeLimit           = "LIMIT"
+	OrderTypeLimitMaker      = "LIMIT_MAKER"
+	OrderTypeStopLossLimit   = "STOP_LOSS_LIMIT"
+	OrderTypeTakeProfitLimit = "TAKE_PROFIT_LIMIT"
+)
+
+// isStopLimit reports whether an order type rests until its stop price triggers it
+func isStopLimit(orderType string) bool {
+	return orderType == OrderTypeStopLossLimit || orderType == OrderTypeTakeProfitLimit
+}
+
+// Supported limit order time-in-force policies
+const (
+	TimeInForceGTC = "GTC"
+	TimeInForceIOC = "IOC"
+	TimeInForceFOK = "FOK"
+)
@@ -0,0 +1,480 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// execOptions are the parsed and validated flags of an exec run. run holds the configuration of a new run,
+// which runExec completes with its amount and the symbol's filters once connected to the exchange.
+type execOptions struct {
+	common *commonFlags
+	run    TWAPConfig
+	// totalAmount is the quote amount a BUY run uses, or the whole balance when not positive
+	totalAmount float64
+	// quantity is the base quantity a base amount run sells, when run.BaseAmount is set
+	quantity         Decimal
+	vwapLookbackDays int
+	// trigger is the -start-when condition the run waits for, when startWhen is set, for up to triggerExpiry
+	startWhen     bool
+	trigger       PriceTrigger
+	triggerExpiry time.Duration
+
+	resume           bool
+	stateFile        string
+	catchUp          string
+	orphanAction     string
+	checkPermissions bool
+	marketData       string
+	orderTransport   string
+	userStream       bool
+
+	controlAddr  string
+	controlToken string
+	reportPath   string
+	journalPath  string
+	auditPath    string
+
+	slackWebhook     string
+	slackVerbosity   string
+	discordWebhook   string
+	discordVerbosity string
+	webhookURL       string
+	webhookSecret    string
+	webhookVerbosity string
+	// email is the SMTP configuration of summary emails, sent on emailSchedule when it has recipients
+	email         SMTPConfig
+	emailSchedule string
+}
+
+// parseExecFlags parses the flags of trade exec, applying a -preset first, and validates them, so a run with
+// invalid settings fails before it connects to the exchange
+func parseExecFlags(args []string) (*execOptions, error) {
+	fs, common := newFlagSet("exec")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	totalRunTime := fs.String("total-run-time", "1H", "Total run time (e.g., 30m, 2H, 1D, 1W, 1M)")
+	totalAmount := fs.Float64("total-amount", -1, "Total USDT amount to use for buying (optional, default: use full balance)")
+	side := fs.String("side", "BUY", "Order side: BUY or SELL")
+	accountsSpec := fs.String("accounts", "", "Spread the run's slices over these accounts by weight (e.g., default:2,sub1:1). Account credentials are read from <EXCHANGE>_<ACCOUNT>_API_KEY/_SECRET_KEY; default uses the regular ones")
+	quantity := fs.String("quantity", "", "Total base asset quantity to sell with base quantity orders, so exactly this much is sold whatever the price (e.g., 0.5; SELL only except with -market futures, replaces -total-amount)")
+	leverage := fs.Int("leverage", 0, "Futures leverage to set on the symbol before the run (0 keeps the current setting)")
+	marginType := fs.String("margin-type", "", "Futures margin type to set on the symbol before the run: isolated or crossed (empty keeps the current setting)")
+	sideEffect := fs.String("side-effect", "", "Borrowing and repaying done by margin orders: none, margin-buy (borrow what the order lacks), auto-repay (repay debt with the proceeds) or auto-borrow-repay (empty uses the exchange default)")
+	autoTransfer := fs.Float64("futures-auto-transfer", 0, "Move up to this much quote from the spot wallet to futures margin over the run, topping up before any order the margin cannot cover (0 to disable)")
+	reduceOnly := fs.Bool("reduce-only", false, "Place reduce-only futures orders, so the run unwinds the open position up to its size and never opens or flips one")
+	orderType := fs.String("order-type", OrderTypeMarket, "Order type: MARKET, LIMIT or LIMIT_MAKER (post-only at or inside the best bid/ask)")
+	limitOffsetBps := fs.Float64("limit-offset-bps", 0, "Limit price offset from mid-price in basis points, away from the spread")
+	timeInForce := fs.String("time-in-force", TimeInForceGTC, "Limit order time in force: GTC, IOC or FOK")
+	limitTimeout := fs.String("limit-timeout", "1m", "Time an unfilled GTC limit order is left open (e.g., 30s, 5m)")
+	limitTimeoutAction := fs.String("limit-timeout-action", "reprice", "Action for timed out limit orders: reprice or cancel")
+	makerPatience := fs.String("maker-patience", "5m", "Time a LIMIT_MAKER slice keeps re-posting before the rest is sent at market (e.g., 30s, 5m)")
+	algo := fs.String("algo", AlgoTWAP, "Execution algorithm: twap (even slices), vwap (slices weighted by historical hourly volume) or pov (orders capped to a share of live traded volume)")
+	participation := fs.String("participation", "5%", "Share of the volume traded since the previous order each pov order may take (e.g., 5%)")
+	vwapLookbackDays := fs.Int("vwap-lookback-days", 7, "Days of hourly klines used to build the VWAP volume profile")
+	sizeJitter := fs.Float64("size-jitter", 0, "Randomize each slice's size by up to this fraction (e.g., 0.2 for ±20%)")
+	timeJitter := fs.Float64("time-jitter", 0, "Randomize each slice's timing by up to this fraction of the interval (e.g., 0.3 for ±30%)")
+	minPrice := fs.Float64("min-price", 0, "Only execute slices while the price is at or above this value (0 to disable)")
+	maxPrice := fs.Float64("max-price", 0, "Only execute slices while the price is at or below this value (0 to disable)")
+	bandAction := fs.String("band-action", "skip", "Action when the price is outside the band: skip (carry the slice forward) or pause")
+	opportunisticWindow := fs.Int("opportunistic-window", 0, "Scale slices by the price's deviation from the average price of this many recent slices, buying more below it and less above (0 to disable)")
+	opportunisticSensitivity := fs.Float64("opportunistic-sensitivity", 100, "Slice multiplier change per unit of relative deviation from the rolling average (100 doubles a slice at a 1% dip)")
+	opportunisticMin := fs.Float64("opportunistic-min", 0.5, "Smallest opportunistic slice multiplier")
+	opportunisticMax := fs.Float64("opportunistic-max", 2, "Largest opportunistic slice multiplier")
+	volMaxFactor := fs.Float64("vol-max-factor", 0, "Scale slices by recent 1m volatility, up to this many times larger when calm or split into this many smaller orders when volatile (0 to disable)")
+	volWindow := fs.String("vol-window", "30m", "Period of 1m returns the recent volatility is measured over (e.g., 15m, 1H)")
+	volLookback := fs.String("vol-lookback", "1D", "Period of 1m returns before the run the reference volatility is measured over (e.g., 12H, 1D)")
+	maxSpreadBps := fs.Float64("max-spread-bps", 0, "Hold back slices while the bid-ask spread is wider than this many basis points of the mid-price (0 to disable)")
+	spreadAction := fs.String("spread-action", "skip", "Action when the spread is too wide: skip (carry the slice forward) or delay (wait up to one slice interval for it to narrow)")
+	tradingHours := fs.String("trading-hours", "", "Only execute slices between these UTC times of day, pausing outside them (e.g., 08:00-22:00, or 22:00-06:00 past midnight)")
+	tradingDays := fs.String("trading-days", "", "Only execute slices on these UTC days, pausing on the others (e.g., mon-fri to skip weekends, or mon,wed,fri)")
+	blackoutCalendar := fs.String("blackout-calendar", os.Getenv("BLACKOUT_CALENDAR"), "Pause the run during the periods of this JSON blackout calendar, e.g. around economic releases or exchange maintenance (default from BLACKOUT_CALENDAR)")
+	replan := fs.String("replan", "off", "Re-planning of slices skipped or failed without an order: off (carry skipped slices into the next one), spread (over the remaining slices) or append (as catch-up slices at the end)")
+	maxSlippageBps := fs.Float64("max-slippage-bps", 0, "Cumulative slippage of market slices against the pre-order mid-price, in basis points, at which the run is stopped or paused (0 to disable)")
+	depthMaxPct := fs.Float64("depth-max-pct", 0, "Cap each market slice to this percentage of the quote liquidity in the top -depth-levels of the order book, deferring the rest (0 to disable)")
+	depthLevels := fs.Int("depth-levels", 10, "Number of order book levels -depth-max-pct is measured against (1-100)")
+	slippageAction := fs.String("slippage-action", "abort", "Action when -max-slippage-bps is exceeded: abort (end the run) or pause (requires -control-addr)")
+	stopLossPct := fs.Float64("stop-loss-pct", 0, "After a BUY run, sell the position if the price falls this percentage below the average fill price (0 to disable)")
+	takeProfitPct := fs.Float64("take-profit-pct", 0, "After a BUY run, sell the position if the price rises this percentage above the average fill price (0 to disable)")
+	trailingStopPct := fs.Float64("trailing-stop-pct", 0, "After a BUY run, trail the stop this percentage below the highest price seen (0 to disable)")
+	trailingTakeProfitPct := fs.Float64("trailing-take-profit-pct", 0, "After a BUY run, once the price reaches -take-profit-pct (or the entry price without it), sell when it falls this percentage below the highest price seen (0 to disable)")
+	exitOCO := fs.Bool("exit-oco", false, "Place the stop-loss and take-profit as one OCO sell order resting on the exchange instead of watching the price (requires -stop-loss-pct and -take-profit-pct, no trailing stop)")
+	withdrawTo := fs.String("withdraw-to", "", "After a BUY run completes, withdraw the base asset it acquired to this address, e.g. cold storage (requires -enable-withdrawals)")
+	withdrawNetwork := fs.String("withdraw-network", "", "Network the -withdraw-to withdrawal is sent over (e.g., BTC, ETH, BSC; empty for the asset's default network)")
+	withdrawTag := fs.String("withdraw-tag", "", "Memo or tag the -withdraw-to address needs on networks that use one")
+	enableWithdrawals := fs.Bool("enable-withdrawals", false, "Allow the run to withdraw with -withdraw-to, which is refused without it")
+	exitPollInterval := fs.String("exit-poll-interval", "5s", "How often the price is checked while managing the exit (e.g., 5s, 1m)")
+	stateFile := fs.String("state-file", "binance_buyer_state.json", "File the run state is persisted to after every order (empty to disable)")
+	marketData := fs.String("market-data", "ws", "Price source for slice checks: ws (WebSocket stream with REST fallback) or rest")
+	userStream := fs.Bool("user-stream", true, "Track fills, partial fills and commissions from the exchange's user data stream")
+	reportCurrency := fs.String("report-currency", "", "Also express the run's fills, fees and average price in this fiat currency in the report, emails and journal (e.g., EUR, INR, GBP)")
+	fxSource := fs.String("fx-source", FXSourceBinance, "Source of the -report-currency rate: binance (the quote asset's pair with the currency, e.g. EURUSDT), fixed:<rate> (e.g., fixed:0.92) or a URL with {from} and {to} answering JSON with a rate or rates field")
+	convertFees := fs.Bool("convert-fees", false, "Value commissions paid in other assets than the quote asset (e.g., BNB or the base asset) in the quote asset at the prices when they are paid, and report the fill price with fees")
+	orderTransport := fs.String("order-transport", OrderTransportREST, "Transport orders are placed and cancelled over: rest, or ws (Binance spot WebSocket API with REST fallback)")
+	catchUp := fs.String("catch-up", CatchUpExtend, "Handling of the slices a resumed run missed while not running: extend (keep the pace and end later), skip (drop them and end on time) or compress (fit the remaining slices into the planned run time)")
+	orphanAction := fs.String("orphan-action", OrphanActionAdopt, "Action for open orders left by a crashed run that the state file does not track: adopt or cancel")
+	controlAddr := fs.String("control-addr", "", "Serve the web dashboard and pause/resume/throttle/stop/status endpoints on this address (e.g., localhost:8080 or unix:/tmp/binance_buyer.sock)")
+	controlToken := fs.String("control-token", os.Getenv("CONTROL_TOKEN"), "Token every control server request must carry as a bearer token or token query parameter (default from CONTROL_TOKEN)")
+	reportPath := fs.String("report", "", "Write the final execution report to this file, as JSON or as an HTML page with charts when it ends in .html (e.g., report.json)")
+	journalPath := fs.String("journal", "", "Append every executed order to this CSV file (e.g., trades.csv)")
+	auditPath := fs.String("audit", "", "Append every decision of the run (planned, placed, filled, skipped, error) as a JSON line to this file (e.g., audit.jsonl)")
+	slackWebhook := fs.String("slack-webhook", os.Getenv("SLACK_WEBHOOK_URL"), "Send run events to this Slack incoming webhook URL (default from SLACK_WEBHOOK_URL)")
+	slackVerbosity := fs.String("slack-verbosity", NotifySummary, "Run events sent to Slack: errors, summary (adds start, re-plans, completion and exit) or all (adds every slice)")
+	discordWebhook := fs.String("discord-webhook", os.Getenv("DISCORD_WEBHOOK_URL"), "Send run events to this Discord webhook URL (default from DISCORD_WEBHOOK_URL)")
+	discordVerbosity := fs.String("discord-verbosity", NotifySummary, "Run events sent to Discord: errors, summary or all")
+	webhookURL := fs.String("webhook-url", "", "Post run events (order_placed, order_filled, run_completed, error, ...) as JSON to this URL")
+	webhookSecret := fs.String("webhook-secret", os.Getenv("WEBHOOK_SECRET"), "Sign webhook events with HMAC-SHA256 under this secret (default from WEBHOOK_SECRET)")
+	webhookVerbosity := fs.String("webhook-verbosity", NotifyAll, "Run events posted to -webhook-url: errors, summary or all")
+	emailTo := fs.String("email-to", "", "Email an HTML summary of the run (fills table, average price, fees, fill price chart) to these comma-separated addresses")
+	emailFrom := fs.String("email-from", "", "Sender address of summary emails")
+	emailSchedule := fs.String("email-schedule", EmailEnd, "When summary emails are sent: end (when the run ends) or daily (also every 24 hours during the run)")
+	smtpAddr := fs.String("smtp-addr", "", "SMTP server for summary emails as host:port (port 465 uses TLS, others STARTTLS when offered)")
+	smtpUser := fs.String("smtp-user", "", "SMTP username (empty to send without authentication)")
+	smtpPassword := fs.String("smtp-password", os.Getenv("SMTP_PASSWORD"), "SMTP password (default from SMTP_PASSWORD)")
+	killMaxErrors := fs.Int("kill-max-errors", 0, "Halt the run and cancel its open orders after this many order placements fail in a row (0 to disable)")
+	killMaxDropPct := fs.Float64("kill-max-drop-pct", 0, "Halt the run and cancel its open orders once the price falls this percentage below its first sampled price (0 to disable)")
+	killFile := fs.String("kill-file", "", "Halt the run and cancel its open orders once this file exists (e.g., /tmp/STOP_TRADING, shared by every run)")
+	maxSpendRun := fs.Float64("max-spend-run", 0, "Hard cap on the quote the run's BUY orders may spend, checked before every order (0 to disable)")
+	maxSpendDay := fs.Float64("max-spend-day", 0, "Hard cap on the quote spent per UTC day by every run sharing -spend-ledger, over symbols of the same quote asset (0 to disable)")
+	maxSpendSymbolDay := fs.Float64("max-spend-symbol-day", 0, "Hard cap on the quote spent per UTC day on the symbol by every run sharing -spend-ledger (0 to disable)")
+	maxPositionBase := fs.Float64("max-position-base", 0, "Stop a BUY run once the account's position in the base asset, plus the run's unfilled orders, would exceed this quantity (0 to disable)")
+	maxPositionQuote := fs.Float64("max-position-quote", 0, "Stop a BUY run once the account's position valued at the current price, plus the run's unfilled orders, would exceed this quote amount (0 to disable)")
+	spendLedger := fs.String("spend-ledger", "binance_buyer_spend.json", "File recording the spending of runs with spend caps, shared by runs so the daily caps span them")
+	killURL := fs.String("kill-url", "", "Halt the run and cancel its open orders once this URL answers \"stop\" or {\"stop\": true}, checked before every slice")
+	checkPermissions := fs.Bool("check-permissions", true, "Verify before starting that the API key has the permissions the run needs, and warn about broader ones")
+	startWhen := fs.String("start-when", "", "Arm the run and start it only once the price meets this condition, watched on the market data stream (e.g., \"price <= 60000\")")
+	startExpiry := fs.String("start-expiry", "", "Give up on -start-when if the condition is not met within this time (e.g., 12H, 3D; empty to wait indefinitely)")
+	resume := fs.Bool("resume", false, "Resume the run persisted in the state file instead of starting a new one")
+	fs.String("preset", "", "Start from the flag values of this preset of -presets, which flags given alongside override (e.g., weekly-btc-dca)")
+	fs.String("presets", cmp.Or(os.Getenv("PRESETS_FILE"), defaultPresetsFile), "File mapping preset names to exec flag values (default from PRESETS_FILE)")
+	args, err := applyPreset(fs, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.parse(fs, args); err != nil {
+		return nil, err
+	}
+
+	opts := &execOptions{
+		common:           common,
+		totalAmount:      *totalAmount,
+		vwapLookbackDays: *vwapLookbackDays,
+		resume:           *resume,
+		stateFile:        *stateFile,
+		orphanAction:     *orphanAction,
+		checkPermissions: *checkPermissions,
+		userStream:       *userStream,
+		controlAddr:      *controlAddr,
+		controlToken:     *controlToken,
+		reportPath:       *reportPath,
+		journalPath:      *journalPath,
+		auditPath:        *auditPath,
+		slackWebhook:     *slackWebhook,
+		slackVerbosity:   *slackVerbosity,
+		discordWebhook:   *discordWebhook,
+		discordVerbosity: *discordVerbosity,
+		webhookURL:       *webhookURL,
+		webhookSecret:    *webhookSecret,
+		webhookVerbosity: *webhookVerbosity,
+		email:            SMTPConfig{Addr: *smtpAddr, Username: *smtpUser, Password: *smtpPassword, From: *emailFrom},
+		emailSchedule:    *emailSchedule,
+	}
+	if *emailTo != "" {
+		for _, to := range strings.Split(*emailTo, ",") {
+			opts.email.To = append(opts.email.To, strings.TrimSpace(to))
+		}
+	}
+
+	opts.marketData = strings.ToLower(*marketData)
+	if opts.marketData != "ws" && opts.marketData != "rest" {
+		return nil, fmt.Errorf("invalid market data source: %s. Use ws or rest", *marketData)
+	}
+	opts.orderTransport = strings.ToLower(*orderTransport)
+	if opts.orderTransport != OrderTransportREST && opts.orderTransport != OrderTransportWS {
+		return nil, fmt.Errorf("invalid order transport: %s. Use rest or ws", *orderTransport)
+	}
+	if *orphanAction != OrphanActionAdopt && *orphanAction != OrphanActionCancel {
+		return nil, fmt.Errorf("invalid orphan action: %s. Use adopt or cancel", *orphanAction)
+	}
+	if *startWhen != "" {
+		if *resume {
+			return nil, fmt.Errorf("start-when only applies to new runs, not with -resume")
+		}
+		opts.startWhen = true
+		if opts.trigger, err = parsePriceTrigger(*startWhen); err != nil {
+			return nil, err
+		}
+		if *startExpiry != "" {
+			if opts.triggerExpiry, err = parseDuration(*startExpiry); err != nil {
+				return nil, fmt.Errorf("error parsing start expiry: %v", err)
+			}
+		}
+	} else if *startExpiry != "" {
+		return nil, fmt.Errorf("start-expiry requires -start-when")
+	}
+	opts.catchUp = strings.ToLower(*catchUp)
+	if opts.catchUp != CatchUpExtend && opts.catchUp != CatchUpSkip && opts.catchUp != CatchUpCompress {
+		return nil, fmt.Errorf("invalid catch-up policy: %s. Use extend, skip or compress", *catchUp)
+	}
+
+	cfg := &opts.run
+	cfg.Exchange = strings.ToLower(common.exchange)
+	cfg.Testnet = common.testnet
+	cfg.Market = common.market
+	cfg.Symbol = *symbol
+	if cfg.Fiat, err = parseFiatConfig(*reportCurrency, *fxSource); err != nil {
+		return nil, err
+	}
+	if cfg.Window, err = parseTradingWindow(*tradingHours, *tradingDays); err != nil {
+		return nil, err
+	}
+	if *blackoutCalendar != "" {
+		if cfg.Blackouts, err = loadBlackouts(*blackoutCalendar); err != nil {
+			return nil, err
+		}
+	}
+	cfg.Futures = FuturesConfig{
+		Leverage:   *leverage,
+		MarginType: strings.ToUpper(*marginType),
+		ReduceOnly: *reduceOnly,
+	}
+	if common.market != MarketFutures && cfg.Futures != (FuturesConfig{}) {
+		return nil, fmt.Errorf("leverage, margin type and reduce-only are only available with -market futures")
+	}
+	if cfg.SideEffect, err = parseSideEffect(*sideEffect); err != nil {
+		return nil, err
+	}
+	if cfg.SideEffect != "" && common.market != MarketMargin && common.market != MarketIsolatedMargin {
+		return nil, fmt.Errorf("side effect is only available with -market margin or isolated-margin")
+	}
+	if *autoTransfer < 0 {
+		return nil, fmt.Errorf("futures auto transfer must not be negative")
+	}
+	if *autoTransfer > 0 {
+		if common.market != MarketFutures || *reduceOnly {
+			return nil, fmt.Errorf("futures auto transfer is only available with -market futures without -reduce-only")
+		}
+		cfg.Futures.AutoTransferMax = NewDecimalFromFloat(*autoTransfer)
+	}
+	if cfg.Futures.Leverage < 0 {
+		return nil, fmt.Errorf("leverage must be positive")
+	}
+	if cfg.Futures.MarginType != "" && cfg.Futures.MarginType != "ISOLATED" && cfg.Futures.MarginType != "CROSSED" {
+		return nil, fmt.Errorf("invalid margin type: %s. Use isolated or crossed", *marginType)
+	}
+
+	// A resumed run keeps the settings persisted with its state
+	if opts.resume {
+		return opts, nil
+	}
+
+	if cfg.Duration, err = parseDuration(*totalRunTime); err != nil {
+		return nil, fmt.Errorf("error parsing total run time: %v", err)
+	}
+	if *accountsSpec != "" {
+		if cfg.Accounts, err = parseAccounts(*accountsSpec); err != nil {
+			return nil, err
+		}
+		if common.market == MarketFutures {
+			return nil, fmt.Errorf("multi-account runs are not available for futures")
+		}
+	}
+
+	cfg.Side = strings.ToUpper(*side)
+	if cfg.Side != "BUY" && cfg.Side != "SELL" {
+		return nil, fmt.Errorf("invalid side: %s. Use BUY or SELL", *side)
+	}
+
+	// A base quantity replaces the quote amount as the run's budget
+	if *quantity != "" {
+		opts.quantity, err = ParseDecimal(*quantity)
+		if err != nil || opts.quantity.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid quantity %q: a positive base quantity is required", *quantity)
+		}
+		if cfg.Side != "SELL" && common.market != MarketFutures {
+			return nil, fmt.Errorf("quantity is only available for SELL runs. Use -total-amount for BUY runs")
+		}
+		if *totalAmount > 0 {
+			return nil, fmt.Errorf("quantity and total amount cannot be combined")
+		}
+		cfg.BaseAmount = true
+	}
+
+	// Validate limit order settings
+	cfg.OrderType = strings.ToUpper(*orderType)
+	if cfg.OrderType != OrderTypeMarket && cfg.OrderType != OrderTypeLimit && cfg.OrderType != OrderTypeLimitMaker {
+		return nil, fmt.Errorf("invalid order type: %s. Use MARKET, LIMIT or LIMIT_MAKER", *orderType)
+	}
+	cfg.Limit = LimitOrderConfig{
+		OffsetBps:   *limitOffsetBps,
+		TimeInForce: strings.ToUpper(*timeInForce),
+		Reprice:     *limitTimeoutAction == "reprice",
+	}
+	if tif := cfg.Limit.TimeInForce; tif != TimeInForceGTC && tif != TimeInForceIOC && tif != TimeInForceFOK {
+		return nil, fmt.Errorf("invalid time in force: %s. Use GTC, IOC or FOK", *timeInForce)
+	}
+	if *limitTimeoutAction != "reprice" && *limitTimeoutAction != "cancel" {
+		return nil, fmt.Errorf("invalid limit timeout action: %s. Use reprice or cancel", *limitTimeoutAction)
+	}
+	if cfg.Limit.Timeout, err = parseDuration(*limitTimeout); err != nil {
+		return nil, fmt.Errorf("error parsing limit timeout: %v", err)
+	}
+	if cfg.Limit.MakerPatience, err = parseDuration(*makerPatience); err != nil {
+		return nil, fmt.Errorf("error parsing maker patience: %v", err)
+	}
+
+	cfg.Algo = strings.ToLower(*algo)
+	if cfg.Algo != AlgoTWAP && cfg.Algo != AlgoVWAP && cfg.Algo != AlgoPOV {
+		return nil, fmt.Errorf("invalid algo: %s. Use twap, vwap or pov", *algo)
+	}
+	if cfg.Algo == AlgoPOV {
+		if opts.marketData != "ws" {
+			return nil, fmt.Errorf("the pov algo needs the live trade stream. Use -market-data ws")
+		}
+		if cfg.Participation, err = parseParticipation(*participation); err != nil {
+			return nil, err
+		}
+	}
+
+	if *sizeJitter < 0 || *sizeJitter >= 1 || *timeJitter < 0 || *timeJitter >= 1 {
+		return nil, fmt.Errorf("jitter fractions must be between 0 and 1")
+	}
+	cfg.SizeJitter, cfg.TimeJitter = *sizeJitter, *timeJitter
+
+	if *bandAction != "skip" && *bandAction != "pause" {
+		return nil, fmt.Errorf("invalid band action: %s. Use skip or pause", *bandAction)
+	}
+	if *spreadAction != "skip" && *spreadAction != "delay" {
+		return nil, fmt.Errorf("invalid spread action: %s. Use skip or delay", *spreadAction)
+	}
+	if *maxSpreadBps < 0 {
+		return nil, fmt.Errorf("max spread cannot be negative")
+	}
+	if *opportunisticWindow < 0 || *opportunisticSensitivity < 0 {
+		return nil, fmt.Errorf("opportunistic window and sensitivity cannot be negative")
+	}
+	if *opportunisticMin < 0 || *opportunisticMin > 1 || *opportunisticMax < 1 {
+		return nil, fmt.Errorf("opportunistic multipliers must satisfy 0 <= min <= 1 <= max")
+	}
+	if *volMaxFactor < 0 {
+		return nil, fmt.Errorf("volatility max factor cannot be negative")
+	}
+	volWindowDuration, err := parseDuration(*volWindow)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing volatility window: %v", err)
+	}
+	volLookbackDuration, err := parseDuration(*volLookback)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing volatility lookback: %v", err)
+	}
+	if volWindowDuration < 3*time.Minute || volLookbackDuration < volWindowDuration {
+		return nil, fmt.Errorf("volatility window must be at least 3m and no longer than the lookback")
+	}
+	cfg.Replan = strings.ToLower(*replan)
+	switch cfg.Replan {
+	case "off":
+		cfg.Replan = ""
+	case ReplanSpread, ReplanAppend:
+	default:
+		return nil, fmt.Errorf("invalid replan mode: %s. Use off, spread or append", *replan)
+	}
+	if *depthMaxPct < 0 || *depthMaxPct > 100 {
+		return nil, fmt.Errorf("depth max pct must be between 0 and 100")
+	}
+	if *maxSpendRun < 0 || *maxSpendDay < 0 || *maxSpendSymbolDay < 0 {
+		return nil, fmt.Errorf("spend caps must not be negative")
+	}
+	if *maxPositionBase < 0 || *maxPositionQuote < 0 {
+		return nil, fmt.Errorf("position limits must not be negative")
+	}
+	if *depthLevels < 1 || *depthLevels > maxDepthLevels {
+		return nil, fmt.Errorf("depth levels must be between 1 and %d", maxDepthLevels)
+	}
+	if *slippageAction != "abort" && *slippageAction != "pause" {
+		return nil, fmt.Errorf("invalid slippage action: %s. Use abort or pause", *slippageAction)
+	}
+	if *controlAddr != "" && *controlToken == "" && !strings.HasPrefix(*controlAddr, "unix:") {
+		return nil, fmt.Errorf("a token is required to serve the control server over TCP: set -control-token or CONTROL_TOKEN")
+	}
+	if *slippageAction == "pause" && *controlAddr == "" {
+		return nil, fmt.Errorf("slippage action pause requires -control-addr to resume the run")
+	}
+	if *minPrice > 0 && *maxPrice > 0 && *minPrice > *maxPrice {
+		return nil, fmt.Errorf("min price (%.8f) is greater than max price (%.8f)", *minPrice, *maxPrice)
+	}
+	cfg.Band = PriceBand{Min: *minPrice, Max: *maxPrice, Pause: *bandAction == "pause"}
+	cfg.Depth = DepthLimit{MaxPct: *depthMaxPct, Levels: *depthLevels}
+	cfg.Slippage = SlippageLimit{MaxBps: *maxSlippageBps, Pause: *slippageAction == "pause"}
+	cfg.Spread = SpreadLimit{MaxBps: *maxSpreadBps, Delay: *spreadAction == "delay"}
+	cfg.Opportunistic = OpportunisticSizing{
+		Window:      *opportunisticWindow,
+		Sensitivity: *opportunisticSensitivity,
+		Min:         *opportunisticMin,
+		Max:         *opportunisticMax,
+	}
+	cfg.Volatility = VolatilityScaling{
+		MaxFactor: *volMaxFactor,
+		Window:    int(volWindowDuration.Minutes()),
+		Lookback:  int(volLookbackDuration.Minutes()),
+	}
+	cfg.Kill = KillSwitch{
+		MaxConsecutiveErrors: *killMaxErrors,
+		MaxDropPct:           *killMaxDropPct,
+		SentinelFile:         *killFile,
+		SentinelURL:          *killURL,
+	}
+	cfg.Spend = SpendCaps{
+		PerRun:       NewDecimalFromFloat(*maxSpendRun),
+		PerDay:       NewDecimalFromFloat(*maxSpendDay),
+		PerSymbolDay: NewDecimalFromFloat(*maxSpendSymbolDay),
+		Ledger:       *spendLedger,
+	}
+	cfg.Position = PositionLimit{
+		MaxBase:  NewDecimalFromFloat(*maxPositionBase),
+		MaxQuote: NewDecimalFromFloat(*maxPositionQuote),
+	}
+	cfg.ConvertFees = *convertFees
+
+	exitPoll, err := parseDuration(*exitPollInterval)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing exit poll interval: %v", err)
+	}
+	cfg.Exit = ExitConfig{
+		StopLossPct:           *stopLossPct,
+		TakeProfitPct:         *takeProfitPct,
+		TrailingStopPct:       *trailingStopPct,
+		TrailingTakeProfitPct: *trailingTakeProfitPct,
+		PollInterval:          exitPoll,
+		OCO:                   *exitOCO,
+	}
+	if cfg.Exit.Enabled() && cfg.Side != "BUY" {
+		return nil, fmt.Errorf("stop-loss and take-profit management is only available for BUY runs")
+	}
+	if cfg.Exit.Enabled() && len(cfg.Accounts) > 1 {
+		return nil, fmt.Errorf("stop-loss and take-profit management is not available for multi-account runs")
+	}
+	if cfg.Exit.OCO && (cfg.Exit.StopLossPct <= 0 || cfg.Exit.TakeProfitPct <= 0 || cfg.Exit.TrailingStopPct > 0 || cfg.Exit.TrailingTakeProfitPct > 0) {
+		return nil, fmt.Errorf("OCO exits need -stop-loss-pct and -take-profit-pct and cannot trail")
+	}
+	if cfg.Exit.Enabled() && common.market == MarketFutures {
+		return nil, fmt.Errorf("stop-loss and take-profit management is not available for futures runs")
+	}
+	cfg.Withdraw = WithdrawConfig{Address: *withdrawTo, Network: strings.ToUpper(*withdrawNetwork), Tag: *withdrawTag}
+	if cfg.Withdraw.Enabled() {
+		if !*enableWithdrawals {
+			return nil, fmt.Errorf("withdrawals are disabled. Pass -enable-withdrawals to allow -withdraw-to")
+		}
+		if cfg.Side != "BUY" || common.market != MarketSpot || len(cfg.Accounts) > 1 || cfg.Exit.Enabled() {
+			return nil, fmt.Errorf("-withdraw-to is only available for spot BUY runs on one account without exit management")
+		}
+	}
+
+	// Detect quote asset (supporting USDT quotes)
+	cfg.QuoteAsset = "USDT"
+	if !strings.HasSuffix(cfg.Symbol, cfg.QuoteAsset) {
+		return nil, fmt.Errorf("unsupported quote asset. Only %s quote pairs supported, got: %s", cfg.QuoteAsset, cfg.Symbol)
+	}
+	return opts, nil
+}
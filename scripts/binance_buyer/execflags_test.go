@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExecFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		err  string
+	}{
+		{name: "defaults"},
+		{name: "limit SELL run", args: []string{"-side", "sell", "-order-type", "limit", "-time-in-force", "ioc", "-quantity", "0.5"}},
+		{name: "futures BUY of a base quantity", args: []string{"-market", "futures", "-quantity", "0.5", "-leverage", "3", "-margin-type", "isolated"}},
+		{name: "resume skips the settings of a new run", args: []string{"-resume", "-total-run-time", "soon", "-side", "hold"}},
+		{name: "invalid side", args: []string{"-side", "hold"}, err: "invalid side"},
+		{name: "invalid run time", args: []string{"-total-run-time", "soon"}, err: "total run time"},
+		{name: "invalid order type", args: []string{"-order-type", "stop"}, err: "invalid order type"},
+		{name: "invalid market data source", args: []string{"-market-data", "fix"}, err: "market data source"},
+		{name: "start-when with resume", args: []string{"-resume", "-start-when", "price <= 60000"}, err: "start-when"},
+		{name: "start-expiry without start-when", args: []string{"-start-expiry", "1D"}, err: "requires -start-when"},
+		{name: "quantity on a spot BUY run", args: []string{"-quantity", "0.5"}, err: "only available for SELL runs"},
+		{name: "quantity with a total amount", args: []string{"-side", "SELL", "-quantity", "0.5", "-total-amount", "100"}, err: "cannot be combined"},
+		{name: "leverage outside futures", args: []string{"-leverage", "3"}, err: "only available with -market futures"},
+		{name: "side effect outside margin", args: []string{"-side-effect", "margin-buy"}, err: "side effect"},
+		{name: "pov without the trade stream", args: []string{"-algo", "pov", "-market-data", "rest"}, err: "live trade stream"},
+		{name: "jitter of a whole interval", args: []string{"-time-jitter", "1"}, err: "jitter"},
+		{name: "inverted price band", args: []string{"-min-price", "200", "-max-price", "100"}, err: "greater than max price"},
+		{name: "control server on a unix socket", args: []string{"-control-addr", "unix:/tmp/binance_buyer.sock"}},
+		{name: "control server over TCP without a token", args: []string{"-control-addr", "localhost:8080"}, err: "token is required"},
+		{name: "slippage pause without control server", args: []string{"-max-slippage-bps", "50", "-slippage-action", "pause"}, err: "requires -control-addr"},
+		{name: "exit management of a SELL run", args: []string{"-side", "SELL", "-stop-loss-pct", "5"}, err: "only available for BUY runs"},
+		{name: "trailing OCO exit", args: []string{"-stop-loss-pct", "5", "-take-profit-pct", "10", "-trailing-stop-pct", "2", "-exit-oco"}, err: "cannot trail"},
+		{name: "withdrawal not enabled", args: []string{"-withdraw-to", "bc1qaddress"}, err: "withdrawals are disabled"},
+		{name: "non-USDT quote", args: []string{"-symbol", "BTCEUR"}, err: "unsupported quote asset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseExecFlags(tt.args)
+			if tt.err == "" {
+				if err != nil {
+					t.Errorf("error %v, want the flags accepted", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("error %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestParseExecFlagsBuildsRunConfig(t *testing.T) {
+	opts, err := parseExecFlags([]string{"-symbol", "ETHUSDT", "-side", "sell", "-total-run-time", "2H", "-order-type", "limit_maker",
+		"-replan", "off", "-vol-window", "15m", "-vol-lookback", "12H", "-max-spend-run", "500", "-start-when", "price >= 3000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := opts.run
+	if cfg.Symbol != "ETHUSDT" || cfg.Side != "SELL" || cfg.QuoteAsset != "USDT" || cfg.Duration != 2*time.Hour || cfg.OrderType != OrderTypeLimitMaker {
+		t.Errorf("got %s %s in %s over %s with %s orders", cfg.Side, cfg.Symbol, cfg.QuoteAsset, cfg.Duration, cfg.OrderType)
+	}
+	if cfg.Replan != "" || cfg.Volatility.Window != 15 || cfg.Volatility.Lookback != 720 || cfg.Spend.PerRun.Cmp(decimalOrZero("500")) != 0 {
+		t.Errorf("got replan %q, volatility %+v and run spend cap %s", cfg.Replan, cfg.Volatility, cfg.Spend.PerRun)
+	}
+	if !opts.startWhen || opts.totalAmount > 0 {
+		t.Errorf("got start-when %t and total amount %v, want a trigger and the whole balance", opts.startWhen, opts.totalAmount)
+	}
+}
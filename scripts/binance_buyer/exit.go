@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// ExitConfig configures the stop-loss and take-profit management of the position accumulated by a BUY run
+type ExitConfig struct {
+	StopLossPct     float64 `json:"stop_loss_pct"`
+	TakeProfitPct   float64 `json:"take_profit_pct"`
+	TrailingStopPct float64 `json:"trailing_stop_pct"`
+	// TrailingTakeProfitPct sells once the price retraces this percentage from its high, after it has risen
+	// to the take-profit level, or above the entry price without one
+	TrailingTakeProfitPct float64       `json:"trailing_take_profit_pct,omitempty"`
+	PollInterval          time.Duration `json:"poll_interval"`
+	// OCO places the stop-loss and take-profit as one OCO order on the exchange instead of watching the price
+	OCO bool `json:"oco,omitempty"`
+}
+
+// Enabled reports whether any exit level is configured
+func (e ExitConfig) Enabled() bool {
+	return e.StopLossPct > 0 || e.TakeProfitPct > 0 || e.TrailingStopPct > 0 || e.TrailingTakeProfitPct > 0
+}
+
+// manageExit monitors the position accumulated by the run and market-sells it once the price reaches the
+// stop-loss or take-profit level. With a trailing stop, the stop is raised as the price makes new highs.
+// With a trailing take-profit, reaching the take-profit level arms a sell that triggers once the price
+// retraces from its high. Streamed prices are checked on every trade. When ctx is cancelled the exit levels
+// are saved and monitoring can be resumed later.
+func manageExit(ctx context.Context, client ExchangeClient, state *RunState, statePath string) {
+	cfg := state.Config
+	exit := cfg.Exit
+
+	entry := state.averageFillPrice()
+	if entry == 0 {
+		log.Printf("No filled position to manage. Skipping exit management.")
+		state.ExitCompleted = true
+		state.save(statePath)
+		return
+	}
+
+	// The free balance can be lower than the filled quantity when fees are charged in the base asset
+	quantity := state.FilledBase
+	if balance, err := client.GetBalance(cfg.baseAsset()); err == nil {
+		quantity = minDecimal(quantity, balance)
+	}
+	quantity = cfg.Filters.RoundQuantity(quantity)
+
+	if exit.OCO {
+		manageOCOExit(ctx, client, state, statePath, entry, quantity)
+		state.save(statePath)
+		return
+	}
+
+	if state.ExitStop == 0 {
+		if exit.StopLossPct > 0 {
+			state.ExitStop = entry * (1 - exit.StopLossPct/100)
+		}
+		if exit.TrailingStopPct > 0 {
+			state.ExitStop = math.Max(state.ExitStop, entry*(1-exit.TrailingStopPct/100))
+		}
+		state.ExitHighWater = entry
+	}
+	var takeProfit float64
+	if exit.TakeProfitPct > 0 {
+		takeProfit = entry * (1 + exit.TakeProfitPct/100)
+	}
+	// A trailing take-profit arms at the take-profit level instead of selling there
+	armAt := math.Max(takeProfit, entry)
+
+	log.Printf("Managing exit for %s %s: entry=%.8f stop=%.8f takeProfit=%.8f trailing=%.2f%% trailingTakeProfit=%.2f%%",
+		quantity, cfg.baseAsset(), entry, state.ExitStop, takeProfit, exit.TrailingStopPct, exit.TrailingTakeProfitPct)
+
+	for {
+		price, err := client.GetPrice(cfg.Symbol)
+		if err != nil {
+			log.Printf("Error getting price for exit management: %v", err)
+			if !sleepContext(ctx, exit.PollInterval) {
+				break
+			}
+			continue
+		}
+
+		if price > state.ExitHighWater {
+			state.ExitHighWater = price
+			if trailed := price * (1 - exit.TrailingStopPct/100); exit.TrailingStopPct > 0 && trailed > state.ExitStop {
+				state.ExitStop = trailed
+				log.Printf("New high %.8f, trailing stop raised to %.8f", price, state.ExitStop)
+				state.save(statePath)
+			}
+		}
+		if exit.TrailingTakeProfitPct > 0 && !state.ExitTakeProfitArmed && price >= armAt {
+			state.ExitTakeProfitArmed = true
+			log.Printf("Price %.8f reached %.8f, trailing take-profit armed %.2f%% below the high", price, armAt, exit.TrailingTakeProfitPct)
+			state.save(statePath)
+		}
+
+		var reason string
+		switch {
+		case state.ExitStop > 0 && price <= state.ExitStop:
+			reason = "stop-loss"
+		case state.ExitTakeProfitArmed && price <= state.ExitHighWater*(1-exit.TrailingTakeProfitPct/100):
+			reason = "trailing-take-profit"
+		case exit.TrailingTakeProfitPct == 0 && takeProfit > 0 && price >= takeProfit:
+			reason = "take-profit"
+		}
+		if reason == "" {
+			if !waitForTrade(ctx, client, cfg.Symbol, exit.PollInterval) {
+				break
+			}
+			continue
+		}
+
+		slog.Info("Exit level hit", "symbol", cfg.Symbol, "reason", reason, "price", price, "qty", quantity)
+		if err := cfg.Filters.ValidateOrder(quantity, NewDecimalFromFloat(price)); err != nil {
+			log.Printf("Cannot place exit order: %v", err)
+			break
+		}
+		order, err := client.PlaceOrder(OrderRequest{
+			Symbol:   cfg.Symbol,
+			Side:     "SELL",
+			Type:     OrderTypeMarket,
+			Quantity: quantity,
+		})
+		if isFatalError(err) {
+			log.Printf("Error placing exit order: %v. Fix the cause and resume exit management with -resume.", err)
+			state.save(statePath)
+			return
+		}
+		if err != nil {
+			log.Printf("Error placing exit order: %v. Retrying.", err)
+			if !sleepContext(ctx, exit.PollInterval) {
+				break
+			}
+			continue
+		}
+		slog.Info("Exit order placed", "symbol", cfg.Symbol, "side", "SELL", "order_id", order.OrderID, "status", order.Status,
+			"qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty)
+		state.journalOrder(order)
+		state.audit(AuditExit, "reason", reason, "order_id", order.OrderID, "status", order.Status,
+			"qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty, "price", price)
+		break
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("Exit management interrupted with stop=%.8f. Resume it with -resume.", state.ExitStop)
+		state.save(statePath)
+		return
+	}
+	state.ExitCompleted = true
+	state.save(statePath)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"log"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// feeRateMaxAge is how long the price of a fee asset is reused before it is fetched again
+const feeRateMaxAge = time.Minute
+
+// commissionClient fills in the commissions of executed orders reported without them, as Binance order queries
+// and orders placed without a full response are, from the trades of the order
+type commissionClient struct {
+	ExchangeClient
+	trades OrderTrades
+}
+
+// withCommissions wraps the client so executed orders carry their commissions, when the exchange client can list
+// the trades of an order
+func withCommissions(client ExchangeClient) ExchangeClient {
+	trades, ok := baseClient(client).(OrderTrades)
+	if !ok {
+		return client
+	}
+	return &commissionClient{ExchangeClient: client, trades: trades}
+}
+
+// PlaceOrder places an order and adds its commission when it completed on placement
+func (c *commissionClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	order, err := c.ExchangeClient.PlaceOrder(req)
+	if err == nil {
+		c.addCommission(order)
+	}
+	return order, err
+}
+
+// GetOrder queries an order and adds its commission once it is complete
+func (c *commissionClient) GetOrder(symbol, orderID string) (*Order, error) {
+	order, err := c.ExchangeClient.GetOrder(symbol, orderID)
+	if err == nil {
+		c.addCommission(order)
+	}
+	return order, err
+}
+
+// addCommission sums the commissions of the trades of a completed order that executed without reporting one.
+// Open orders are left alone, as their trades are still coming in.
+func (c *commissionClient) addCommission(order *Order) {
+	if !isTerminalStatus(order.Status) || order.Commission.Sign() > 0 || decimalOrZero(order.ExecutedQty).IsZero() {
+		return
+	}
+	trades, err := c.trades.GetOrderTrades(order.Symbol, order.OrderID)
+	if err != nil {
+		log.Printf("Error listing the trades of order %s for its commission: %v", order.OrderID, err)
+		return
+	}
+	for _, trade := range trades {
+		order.Commission = order.Commission.Add(trade.Commission)
+		order.CommissionAsset = trade.CommissionAsset
+	}
+}
+
+// Unwrap returns the wrapped client
+func (c *commissionClient) Unwrap() ExchangeClient {
+	return c.ExchangeClient
+}
+
+// feeRate is the price of a fee asset in the quote asset and when it was fetched
+type feeRate struct {
+	price float64
+	at    time.Time
+}
+
+// feeConverter values commissions paid in assets other than the quote asset, such as the base asset or BNB,
+// in the quote asset at the prices prevailing when they are paid
+type feeConverter struct {
+	client     MarketReader
+	quoteAsset string
+	mu         sync.Mutex
+	rates      map[string]feeRate
+}
+
+// newFeeConverter returns a fee converter pricing fee assets against quoteAsset through client
+func newFeeConverter(client MarketReader, quoteAsset string) *feeConverter {
+	return &feeConverter{client: client, quoteAsset: quoteAsset, rates: map[string]feeRate{}}
+}
+
+// rate returns the price of asset in the quote asset, fetching the asset's quote pair when the last price
+// fetched is older than feeRateMaxAge
+func (c *feeConverter) rate(asset string) (float64, error) {
+	if asset == c.quoteAsset {
+		return 1, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if rate, ok := c.rates[asset]; ok && time.Since(rate.at) < feeRateMaxAge {
+		return rate.price, nil
+	}
+	price, err := c.client.GetPrice(asset + c.quoteAsset)
+	if err != nil {
+		return 0, err
+	}
+	c.rates[asset] = feeRate{price: price, at: time.Now()}
+	return price, nil
+}
+
+// feeValue returns the commission of an order in the quote asset, or zero when fees are not converted or
+// the commission's asset has no price
+func (s *RunState) feeValue(order *Order) Decimal {
+	if s.fees == nil || order.Commission.Sign() <= 0 {
+		return Decimal{}
+	}
+	price, err := s.fees.rate(order.CommissionAsset)
+	if err != nil {
+		slog.Warn("Fee not converted, the price of its asset is not available", "order_id", order.OrderID,
+			"commission", order.Commission, "commission_asset", order.CommissionAsset, "quote_asset", s.fees.quoteAsset, "error", err)
+		return Decimal{}
+	}
+	return order.Commission.MulFloat(price)
+}
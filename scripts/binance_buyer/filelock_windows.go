@@ -0,0 +1,39 @@
+//go:build windows
+
+package main
+
+import (
+	"math"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// lockfileExclusiveLock is the LockFileEx flag taking an exclusive rather than a shared lock
+const lockfileExclusiveLock = 0x2
+
+var (
+	kernel32         = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx   = kernel32.NewProc("LockFileEx")
+	procUnlockFileEx = kernel32.NewProc("UnlockFileEx")
+)
+
+// lockExclusive takes an exclusive LockFileEx lock on the whole of file, waiting while another process holds it
+func lockExclusive(file *os.File) error {
+	var overlapped syscall.Overlapped
+	r, _, err := procLockFileEx.Call(file.Fd(), lockfileExclusiveLock, 0, math.MaxUint32, math.MaxUint32, uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// unlockFile releases the lock lockExclusive took
+func unlockFile(file *os.File) error {
+	var overlapped syscall.Overlapped
+	r, _, err := procUnlockFileEx.Call(file.Fd(), 0, math.MaxUint32, math.MaxUint32, uintptr(unsafe.Pointer(&overlapped)))
+	if r == 0 {
+		return err
+	}
+	return nil
+}
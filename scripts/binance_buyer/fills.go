@@ -0,0 +1,172 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+// Backtest order types
+const (
+	// FillMarket fills entries and exits at the next candle's open, paying the taker fee and slippage
+	FillMarket = "market"
+	// FillLimit rests entries and exits as limit orders, filled when a candle trades through their price
+	FillLimit = "limit"
+)
+
+// FeeTier is a fee level reached at a trailing 30-day traded volume
+type FeeTier struct {
+	// MinVolume is the quote volume traded over the last 30 days the tier starts at
+	MinVolume   float64 `json:"min_volume"`
+	TakerFeeBps float64 `json:"taker_fee_bps"`
+	MakerFeeBps float64 `json:"maker_fee_bps"`
+}
+
+// FillModel is how a backtest fills the orders of an exchange
+type FillModel struct {
+	// OrderType is market (default) or limit
+	OrderType string `json:"order_type,omitempty"`
+	// TakerFeeBps and MakerFeeBps are the fees below the first tier, paid by market and limit fills
+	TakerFeeBps float64 `json:"taker_fee_bps"`
+	MakerFeeBps float64 `json:"maker_fee_bps"`
+	// Tiers lower the fees as the backtest's trailing 30-day volume grows
+	Tiers []FeeTier `json:"tiers,omitempty"`
+	// SlippageBps is the fixed slippage of market fills from the open
+	SlippageBps float64 `json:"slippage_bps,omitempty"`
+	// ImpactBps adds slippage per percent of the candle's quote volume a market fill takes
+	ImpactBps float64 `json:"impact_bps,omitempty"`
+	// LimitOffsetBps places limit orders this far from the signal's close, below it when buying and above when
+	// selling
+	LimitOffsetBps float64 `json:"limit_offset_bps,omitempty"`
+	// PartialFillProbability is the chance a candle trading through a limit order fills only a random part of it
+	PartialFillProbability float64 `json:"partial_fill_probability,omitempty"`
+	// LimitExpiry cancels what is left of a limit order after this many candles (default 1)
+	LimitExpiry int `json:"limit_expiry,omitempty"`
+	// Seed makes partial fills reproducible (default 1)
+	Seed uint64 `json:"seed,omitempty"`
+}
+
+// validate checks the model's settings
+func (m FillModel) validate() error {
+	if m.OrderType != "" && m.OrderType != FillMarket && m.OrderType != FillLimit {
+		return fmt.Errorf("invalid fill order_type: %s. Use market or limit", m.OrderType)
+	}
+	if m.TakerFeeBps < 0 || m.MakerFeeBps < 0 || m.SlippageBps < 0 || m.ImpactBps < 0 || m.LimitOffsetBps < 0 {
+		return fmt.Errorf("fill fees, slippage and offsets must not be negative")
+	}
+	if m.PartialFillProbability < 0 || m.PartialFillProbability > 1 {
+		return fmt.Errorf("fill partial_fill_probability must be between 0 and 1")
+	}
+	if m.LimitExpiry < 0 {
+		return fmt.Errorf("fill limit_expiry must not be negative")
+	}
+	return nil
+}
+
+// fillModel returns the fill model of the backtest's exchange, or market fills paying fee_bps when the config
+// has none for it
+func (bt BacktestConfig) fillModel() (FillModel, error) {
+	model, ok := bt.Fills[bt.Exchange]
+	if !ok {
+		return FillModel{TakerFeeBps: bt.FeeBps, MakerFeeBps: bt.FeeBps}, nil
+	}
+	if err := model.validate(); err != nil {
+		return model, fmt.Errorf("%s: %v", bt.Exchange, err)
+	}
+	return model, nil
+}
+
+// filledVolume is a fill counted towards the fee tiers
+type filledVolume struct {
+	Time     time.Time
+	Notional float64
+}
+
+// fillSimulator fills a backtest's orders by its model, tracking the volume the fee tiers depend on
+type fillSimulator struct {
+	model  FillModel
+	rng    *rand.Rand
+	volume []filledVolume
+	tiers  []FeeTier
+}
+
+// newFillSimulator creates the simulator of a model
+func newFillSimulator(model FillModel) *fillSimulator {
+	seed := model.Seed
+	if seed == 0 {
+		seed = 1
+	}
+	tiers := slices.Clone(model.Tiers)
+	slices.SortFunc(tiers, func(a, b FeeTier) int { return cmp.Compare(a.MinVolume, b.MinVolume) })
+	return &fillSimulator{model: model, rng: rand.New(rand.NewPCG(seed, seed)), tiers: tiers}
+}
+
+// limit reports whether orders rest as limit orders
+func (f *fillSimulator) limit() bool {
+	return f.model.OrderType == FillLimit
+}
+
+// expiry returns how many candles a limit order rests
+func (f *fillSimulator) expiry() int {
+	return max(f.model.LimitExpiry, 1)
+}
+
+// feeRate returns the fee of a fill at t as a fraction, by the tier of the volume traded in the 30 days before
+func (f *fillSimulator) feeRate(t time.Time, maker bool) float64 {
+	var volume float64
+	for _, v := range f.volume {
+		if t.Sub(v.Time) <= 30*24*time.Hour {
+			volume += v.Notional
+		}
+	}
+	taker, makerFee := f.model.TakerFeeBps, f.model.MakerFeeBps
+	for _, tier := range f.tiers {
+		if volume >= tier.MinVolume {
+			taker, makerFee = tier.TakerFeeBps, tier.MakerFeeBps
+		}
+	}
+	if maker {
+		return makerFee / 10000
+	}
+	return taker / 10000
+}
+
+// record counts a fill towards the fee tiers
+func (f *fillSimulator) record(t time.Time, notional float64) {
+	f.volume = append(f.volume, filledVolume{Time: t, Notional: notional})
+}
+
+// marketPrice returns the price a market order of notional fills at on c: the open moved against the order by
+// the fixed slippage and the impact of its share of the candle's volume
+func (f *fillSimulator) marketPrice(side string, c Candle, notional float64) float64 {
+	bps := f.model.SlippageBps
+	if c.QuoteVolume > 0 {
+		bps += f.model.ImpactBps * notional / c.QuoteVolume * 100
+	}
+	if side == "BUY" {
+		return c.Open * (1 + bps/10000)
+	}
+	return c.Open * (1 - bps/10000)
+}
+
+// limitPrice returns the price of a limit order placed after a candle closing at close
+func (f *fillSimulator) limitPrice(side string, close float64) float64 {
+	if side == "BUY" {
+		return close * (1 - f.model.LimitOffsetBps/10000)
+	}
+	return close * (1 + f.model.LimitOffsetBps/10000)
+}
+
+// limitFill returns the share of a limit order at price that fills on c: none unless the candle trades
+// through the price, a random part with the partial fill probability, and otherwise all of it
+func (f *fillSimulator) limitFill(side string, price float64, c Candle) float64 {
+	if side == "BUY" && c.Low > price || side == "SELL" && c.High < price {
+		return 0
+	}
+	if f.model.PartialFillProbability > 0 && f.rng.Float64() < f.model.PartialFillProbability {
+		return f.rng.Float64()
+	}
+	return 1
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+)
+
+// SymbolFilters holds the trading rules an order must satisfy for a symbol
+type SymbolFilters struct {
+	Symbol         string  `json:"symbol"`
+	TickSize       Decimal `json:"tick_size"`
+	StepSize       Decimal `json:"step_size"`
+	MinQty         Decimal `json:"min_qty"`
+	MaxQty         Decimal `json:"max_qty"`
+	MinNotional    Decimal `json:"min_notional"`
+	QuotePrecision int     `json:"quote_precision"`
+}
+
+// RoundQuantity rounds a base quantity down to the symbol's step size
+func (f *SymbolFilters) RoundQuantity(qty Decimal) Decimal {
+	return qty.FloorToStep(f.StepSize)
+}
+
+// RoundPrice rounds a price to the symbol's tick size, towards the passive side for the given order side
+func (f *SymbolFilters) RoundPrice(price Decimal, side string) Decimal {
+	if side == "BUY" {
+		return price.FloorToStep(f.TickSize)
+	}
+	return price.CeilToStep(f.TickSize)
+}
+
+// RoundQuote rounds a quote amount down to the symbol's quote precision
+func (f *SymbolFilters) RoundQuote(quote Decimal) Decimal {
+	if f.QuotePrecision <= 0 {
+		return quote
+	}
+	return quote.Truncate(f.QuotePrecision)
+}
+
+// ValidateOrder checks a base quantity and price against the lot size and notional filters
+func (f *SymbolFilters) ValidateOrder(qty, price Decimal) error {
+	if qty.LessThan(f.MinQty) {
+		return fmt.Errorf("quantity %s is below the minimum quantity %s for %s", qty, f.MinQty, f.Symbol)
+	}
+	if f.MaxQty.Sign() > 0 && qty.GreaterThan(f.MaxQty) {
+		return fmt.Errorf("quantity %s is above the maximum quantity %s for %s", qty, f.MaxQty, f.Symbol)
+	}
+	return f.ValidateNotional(qty.Mul(price))
+}
+
+// ValidateNotional checks a quote amount against the minimum notional filter
+func (f *SymbolFilters) ValidateNotional(quote Decimal) error {
+	if quote.LessThan(f.MinNotional) {
+		return fmt.Errorf("notional %s is below the minimum notional %s for %s", quote, f.MinNotional, f.Symbol)
+	}
+	return nil
+}
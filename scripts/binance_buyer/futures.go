@@ -0,0 +1,526 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Binance USDT-M futures REST and WebSocket endpoints for the live exchange and the futures testnet
+const (
+	binanceFuturesBaseURL          = "https://fapi.binance.com"
+	binanceFuturesWSBaseURL        = "wss://fstream.binance.com"
+	binanceFuturesTestnetBaseURL   = "https://testnet.binancefuture.com"
+	binanceFuturesTestnetWSBaseURL = "wss://fstream.binancefuture.com"
+)
+
+// binanceFuturesPaths are the endpoints of the USDT-M futures API
+var binanceFuturesPaths = binancePaths{
+	time:         "/fapi/v1/time",
+	price:        "/fapi/v1/ticker/price",
+	bookTicker:   "/fapi/v1/ticker/bookTicker",
+	klines:       "/fapi/v1/klines",
+	depth:        "/fapi/v1/depth",
+	exchangeInfo: "/fapi/v1/exchangeInfo",
+	order:        "/fapi/v1/order",
+	openOrders:   "/fapi/v1/openOrders",
+}
+
+// binanceFuturesStopTypes maps the stop-limit order types to their futures names
+var binanceFuturesStopTypes = map[string]string{
+	OrderTypeStopLossLimit:   "STOP",
+	OrderTypeTakeProfitLimit: "TAKE_PROFIT",
+}
+
+// binanceFuturesTradeWindow is the longest period a single userTrades request may cover
+const binanceFuturesTradeWindow = 7 * 24 * time.Hour
+
+// FuturesConfig holds the futures settings of a run
+type FuturesConfig struct {
+	// Leverage is applied to the symbol before the run starts, where zero keeps the account's setting
+	Leverage int `json:"leverage,omitempty"`
+	// MarginType is ISOLATED or CROSSED, where empty keeps the account's setting
+	MarginType string `json:"margin_type,omitempty"`
+	// ReduceOnly restricts the run's orders to reducing the open position, so a run unwinds a position
+	// without flipping it
+	ReduceOnly bool `json:"reduce_only,omitempty"`
+	// AutoTransferMax is the most quote the run may move from the spot wallet when its margin falls short of
+	// an order, where zero never transfers
+	AutoTransferMax Decimal `json:"auto_transfer_max,omitzero"`
+}
+
+// FuturesPosition is the open position of a futures symbol
+type FuturesPosition struct {
+	Symbol string
+	// Amount is the position size in the base asset, negative for a short position
+	Amount           Decimal
+	EntryPrice       float64
+	MarkPrice        float64
+	LiquidationPrice float64
+	UnrealizedPnL    Decimal
+	Leverage         int
+	MarginType       string
+}
+
+// BinanceFuturesClient trades Binance USDT-M perpetual futures. Requests share the spot client's signing,
+// retries, clock synchronization and failover, with the futures endpoints and weight budget.
+type BinanceFuturesClient struct {
+	rest *BinanceClient
+	// spot reaches the wallet endpoints of the spot API, which transfers between the spot and futures wallets
+	// go through. It is nil on the testnet, which has none.
+	spot *BinanceClient
+
+	mu      sync.Mutex
+	filters map[string]*SymbolFilters
+}
+
+// NewBinanceFuturesClient creates a Binance USDT-M futures client
+func NewBinanceFuturesClient(apiKey string, signer Signer) *BinanceFuturesClient {
+	rest := NewBinanceClient(apiKey, signer)
+	rest.baseURLs = []string{binanceFuturesBaseURL}
+	rest.wsBaseURL = binanceFuturesWSBaseURL
+	rest.wsAPIURL = ""
+	rest.limiter = binanceFuturesLimiter
+	rest.paths = binanceFuturesPaths
+	return &BinanceFuturesClient{rest: rest, spot: NewBinanceClient(apiKey, signer), filters: map[string]*SymbolFilters{}}
+}
+
+// NewBinanceFuturesTestnetClient creates a client for the futures testnet at testnet.binancefuture.com
+func NewBinanceFuturesTestnetClient(apiKey string, signer Signer) *BinanceFuturesClient {
+	client := NewBinanceFuturesClient(apiKey, signer)
+	client.rest.baseURLs = []string{binanceFuturesTestnetBaseURL}
+	client.rest.wsBaseURL = binanceFuturesTestnetWSBaseURL
+	client.rest.limiter = binanceFuturesTestnetLimiter
+	client.spot = nil
+	return client
+}
+
+// SetEndpoints replaces the REST hosts and the WebSocket base URL
+func (c *BinanceFuturesClient) SetEndpoints(restURLs []string, wsURL string) error {
+	return c.rest.SetEndpoints(restURLs, wsURL)
+}
+
+// SyncTime measures the offset between the futures server clock and the local clock
+func (c *BinanceFuturesClient) SyncTime() (time.Duration, error) {
+	return c.rest.SyncTime()
+}
+
+// GetPrice gets the last price of a symbol
+func (c *BinanceFuturesClient) GetPrice(symbol string) (float64, error) {
+	return c.rest.GetPrice(symbol)
+}
+
+// GetBookTicker gets the best bid and ask of a symbol
+func (c *BinanceFuturesClient) GetBookTicker(symbol string) (*BookTicker, error) {
+	return c.rest.GetBookTicker(symbol)
+}
+
+// GetKlines fetches the candles of a symbol for the given interval opened between start and end
+func (c *BinanceFuturesClient) GetKlines(symbol, interval string, start, end time.Time) ([]Candle, error) {
+	return c.rest.GetKlines(symbol, interval, start, end)
+}
+
+// GetOrderBook fetches the best levels of a symbol's order book
+func (c *BinanceFuturesClient) GetOrderBook(symbol string, levels int) (*OrderBook, error) {
+	return c.rest.GetOrderBook(symbol, levels)
+}
+
+// GetOrder queries the status of an order
+func (c *BinanceFuturesClient) GetOrder(symbol, orderID string) (*Order, error) {
+	return c.rest.GetOrder(symbol, orderID)
+}
+
+// CancelOrder cancels an open order
+func (c *BinanceFuturesClient) CancelOrder(symbol, orderID string) error {
+	return c.rest.CancelOrder(symbol, orderID)
+}
+
+// GetOpenOrders lists the open orders of a symbol
+func (c *BinanceFuturesClient) GetOpenOrders(symbol string) ([]*Order, error) {
+	return c.rest.GetOpenOrders(symbol)
+}
+
+// GetSymbolFilters fetches the PRICE_FILTER, LOT_SIZE and MIN_NOTIONAL filters of a symbol. The futures
+// exchangeInfo lists every symbol, so the filters are cached.
+func (c *BinanceFuturesClient) GetSymbolFilters(symbol string) (*SymbolFilters, error) {
+	c.mu.Lock()
+	cached, ok := c.filters[symbol]
+	c.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	body, err := c.rest.sendPublic(c.rest.paths.exchangeInfo, url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var info struct {
+		Symbols []struct {
+			Symbol         string               `json:"symbol"`
+			ContractType   string               `json:"contractType"`
+			QuotePrecision int                  `json:"quotePrecision"`
+			Filters        []exchangeInfoFilter `json:"filters"`
+		} `json:"symbols"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	for _, s := range info.Symbols {
+		if s.Symbol != symbol {
+			continue
+		}
+		if s.ContractType != "PERPETUAL" {
+			return nil, fmt.Errorf("%s is a %s contract. Only perpetual contracts are supported", symbol, strings.ToLower(s.ContractType))
+		}
+		filters := &SymbolFilters{Symbol: s.Symbol, QuotePrecision: s.QuotePrecision}
+		filters.apply(s.Filters)
+		c.mu.Lock()
+		c.filters[symbol] = filters
+		c.mu.Unlock()
+		return filters, nil
+	}
+	return nil, fmt.Errorf("symbol %s not found in futures exchange info", symbol)
+}
+
+// GetBalance gets the margin balance of an asset available for new positions
+func (c *BinanceFuturesClient) GetBalance(asset string) (Decimal, error) {
+	body, err := c.rest.sendSigned("GET", "/fapi/v2/balance", url.Values{})
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	var balances []struct {
+		Asset            string `json:"asset"`
+		AvailableBalance string `json:"availableBalance"`
+	}
+	if err := json.Unmarshal(body, &balances); err != nil {
+		return Decimal{}, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	for _, balance := range balances {
+		if balance.Asset == asset {
+			available, err := ParseDecimal(balance.AvailableBalance)
+			if err != nil {
+				return Decimal{}, fmt.Errorf("error parsing %s balance: %v", asset, err)
+			}
+			return available, nil
+		}
+	}
+	return Decimal{}, fmt.Errorf("%s futures balance not found", asset)
+}
+
+// PlaceOrder places a limit or market order. Futures orders are sized in contracts of the base asset, so
+// market orders by quote quantity are converted at the touch price and rounded down to the step size.
+// Orders with a client order ID are placed idempotently.
+func (c *BinanceFuturesClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	if isStopLimit(req.Type) {
+		if err := req.validateStopLimit(); err != nil {
+			return nil, err
+		}
+	}
+	if req.Type == OrderTypeMarket && req.Quantity.Sign() <= 0 {
+		qty, err := c.quoteToQuantity(req.Symbol, req.Side, req.QuoteQuantity)
+		if err != nil {
+			return nil, err
+		}
+		req.Quantity = qty
+	}
+
+	params := url.Values{}
+	params.Set("symbol", req.Symbol)
+	params.Set("side", strings.ToUpper(req.Side))
+	params.Set("quantity", req.Quantity.String())
+	params.Set("newOrderRespType", "RESULT")
+	if req.ClientOrderID != "" {
+		params.Set("newClientOrderId", req.ClientOrderID)
+	}
+	if req.ReduceOnly {
+		params.Set("reduceOnly", "true")
+	}
+
+	switch req.Type {
+	case OrderTypeLimit:
+		params.Set("type", OrderTypeLimit)
+		params.Set("timeInForce", req.TimeInForce)
+		params.Set("price", req.Price.String())
+	case OrderTypeLimitMaker:
+		// Good-till-crossing orders expire instead of taking liquidity
+		params.Set("type", OrderTypeLimit)
+		params.Set("timeInForce", "GTX")
+		params.Set("price", req.Price.String())
+	case OrderTypeStopLossLimit, OrderTypeTakeProfitLimit:
+		params.Set("type", binanceFuturesStopTypes[req.Type])
+		params.Set("timeInForce", cmp.Or(req.TimeInForce, TimeInForceGTC))
+		params.Set("price", req.Price.String())
+		params.Set("stopPrice", req.StopPrice.String())
+	default:
+		params.Set("type", OrderTypeMarket)
+	}
+
+	return c.rest.submitOrder(req.Symbol, req.ClientOrderID, params)
+}
+
+// quoteToQuantity converts a quote amount into a contract quantity at the price a market order of side
+// would fill at
+func (c *BinanceFuturesClient) quoteToQuantity(symbol, side string, quote Decimal) (Decimal, error) {
+	filters, err := c.GetSymbolFilters(symbol)
+	if err != nil {
+		return Decimal{}, err
+	}
+	ticker, err := c.GetBookTicker(symbol)
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	price := ticker.AskPrice
+	if strings.ToUpper(side) == "SELL" {
+		price = ticker.BidPrice
+	}
+	if price <= 0 {
+		return Decimal{}, fmt.Errorf("no %s price to size the order at", symbol)
+	}
+	qty := filters.RoundQuantity(quote.Div(NewDecimalFromFloat(price)))
+	if qty.Sign() <= 0 {
+		return Decimal{}, fmt.Errorf("quote quantity %s is less than one step of %s at price %.8f", quote, symbol, price)
+	}
+	return qty, nil
+}
+
+// GetTrades lists the account's trades of a symbol executed between since and until, where a zero until means
+// up to now. The futures API returns at most seven days of trades per request, so longer periods are requested
+// a week at a time. A full page continues from the time of its last trade, skipping the trades already listed.
+func (c *BinanceFuturesClient) GetTrades(symbol string, since, until time.Time) ([]Trade, error) {
+	if until.IsZero() {
+		until = time.Now()
+	}
+	var trades []Trade
+	lastID := int64(-1)
+	for start := since; start.Before(until); {
+		end := start.Add(binanceFuturesTradeWindow)
+		if end.After(until) {
+			end = until
+		}
+		page, err := c.getTradePage(symbol, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, trade := range page {
+			if id, err := strconv.ParseInt(trade.ID, 10, 64); err == nil && id > lastID {
+				trades = append(trades, trade)
+				lastID = id
+			}
+		}
+		if len(page) < binanceTradePageSize {
+			start = end
+			continue
+		}
+		// A page filled by trades of a single millisecond moves on to the next one
+		next := page[len(page)-1].Time
+		if !next.After(start) {
+			next = start.Add(time.Millisecond)
+		}
+		start = next
+	}
+	return trades, nil
+}
+
+// getTradePage lists a page of the account's trades of a symbol executed between start and end
+func (c *BinanceFuturesClient) getTradePage(symbol string, start, end time.Time) ([]Trade, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("startTime", strconv.FormatInt(start.UnixMilli(), 10))
+	params.Set("endTime", strconv.FormatInt(end.UnixMilli(), 10))
+	params.Set("limit", strconv.Itoa(binanceTradePageSize))
+
+	return c.listTrades(params)
+}
+
+// GetOrderTrades lists the account's trades of an order
+func (c *BinanceFuturesClient) GetOrderTrades(symbol, orderID string) ([]Trade, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", orderID)
+
+	return c.listTrades(params)
+}
+
+// listTrades sends a signed request to the trade list endpoint and converts the trades in the response
+func (c *BinanceFuturesClient) listTrades(params url.Values) ([]Trade, error) {
+	body, err := c.rest.sendSigned("GET", "/fapi/v1/userTrades", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var rawTrades []struct {
+		Symbol          string `json:"symbol"`
+		ID              int64  `json:"id"`
+		OrderID         int64  `json:"orderId"`
+		Side            string `json:"side"`
+		Price           string `json:"price"`
+		Qty             string `json:"qty"`
+		QuoteQty        string `json:"quoteQty"`
+		Commission      string `json:"commission"`
+		CommissionAsset string `json:"commissionAsset"`
+		Time            int64  `json:"time"`
+	}
+	if err := json.Unmarshal(body, &rawTrades); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	trades := make([]Trade, 0, len(rawTrades))
+	for _, raw := range rawTrades {
+		trades = append(trades, Trade{
+			ID:              strconv.FormatInt(raw.ID, 10),
+			Symbol:          raw.Symbol,
+			OrderID:         strconv.FormatInt(raw.OrderID, 10),
+			Side:            raw.Side,
+			Price:           decimalOrZero(raw.Price),
+			Qty:             decimalOrZero(raw.Qty),
+			QuoteQty:        decimalOrZero(raw.QuoteQty),
+			Commission:      decimalOrZero(raw.Commission),
+			CommissionAsset: raw.CommissionAsset,
+			Time:            time.UnixMilli(raw.Time),
+		})
+	}
+	return trades, nil
+}
+
+// SetLeverage sets the initial leverage of a symbol, between 1 and the symbol's maximum
+func (c *BinanceFuturesClient) SetLeverage(symbol string, leverage int) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("leverage", strconv.Itoa(leverage))
+
+	_, err := c.rest.sendSigned("POST", "/fapi/v1/leverage", params)
+	return err
+}
+
+// SetMarginType sets the margin type of a symbol to ISOLATED or CROSSED. Binance rejects a change to the
+// type the symbol already has, which is not an error here.
+func (c *BinanceFuturesClient) SetMarginType(symbol, marginType string) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("marginType", marginType)
+
+	_, err := c.rest.sendSigned("POST", "/fapi/v1/marginType", params)
+	var apiErr *BinanceAPIError
+	if errors.As(err, &apiErr) && apiErr.Code == binanceMarginTypeUnchangedErrorCode {
+		return nil
+	}
+	return err
+}
+
+// GetPosition fetches the position of a symbol in one-way position mode, with a zero amount when there is none
+func (c *BinanceFuturesClient) GetPosition(symbol string) (*FuturesPosition, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	body, err := c.rest.sendSigned("GET", "/fapi/v2/positionRisk", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var positions []struct {
+		Symbol           string `json:"symbol"`
+		PositionAmt      string `json:"positionAmt"`
+		EntryPrice       string `json:"entryPrice"`
+		MarkPrice        string `json:"markPrice"`
+		LiquidationPrice string `json:"liquidationPrice"`
+		UnRealizedProfit string `json:"unRealizedProfit"`
+		Leverage         string `json:"leverage"`
+		MarginType       string `json:"marginType"`
+		PositionSide     string `json:"positionSide"`
+	}
+	if err := json.Unmarshal(body, &positions); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	for _, p := range positions {
+		if p.PositionSide != "BOTH" {
+			continue
+		}
+		leverage, _ := strconv.Atoi(p.Leverage)
+		return &FuturesPosition{
+			Symbol:           p.Symbol,
+			Amount:           decimalOrZero(p.PositionAmt),
+			EntryPrice:       parseAnyFloat(p.EntryPrice),
+			MarkPrice:        parseAnyFloat(p.MarkPrice),
+			LiquidationPrice: parseAnyFloat(p.LiquidationPrice),
+			UnrealizedPnL:    decimalOrZero(p.UnRealizedProfit),
+			Leverage:         leverage,
+			MarginType:       strings.ToUpper(p.MarginType),
+		}, nil
+	}
+	return nil, fmt.Errorf("no one-way position for %s. Hedge mode is not supported", symbol)
+}
+
+// futuresClient returns the futures client underneath any decorators wrapping client
+func futuresClient(client ExchangeClient) (*BinanceFuturesClient, error) {
+	futures, ok := baseClient(client).(*BinanceFuturesClient)
+	if !ok {
+		return nil, fmt.Errorf("futures are only supported on binance")
+	}
+	return futures, nil
+}
+
+// configureFutures applies a run's leverage and margin type to its symbol
+func configureFutures(client *BinanceFuturesClient, symbol string, cfg FuturesConfig) error {
+	if cfg.MarginType != "" {
+		if err := client.SetMarginType(symbol, cfg.MarginType); err != nil {
+			return fmt.Errorf("error setting %s margin type to %s: %v", symbol, cfg.MarginType, err)
+		}
+	}
+	if cfg.Leverage > 0 {
+		if err := client.SetLeverage(symbol, cfg.Leverage); err != nil {
+			return fmt.Errorf("error setting %s leverage to %dx: %v", symbol, cfg.Leverage, err)
+		}
+	}
+	return nil
+}
+
+// futuresAvailable returns how much a futures run can trade, as a quote amount and as a base quantity at
+// price. A reduce-only run can trade the open position it unwinds, which must be on the other side of the
+// run; any other run can trade the available margin at the symbol's leverage, plus what it may transfer from
+// the spot wallet up to transferMax.
+func futuresAvailable(client *BinanceFuturesClient, symbol, side, quoteAsset string, reduceOnly bool, price float64, transferMax Decimal) (quote, base Decimal, err error) {
+	position, err := client.GetPosition(symbol)
+	if err != nil {
+		return Decimal{}, Decimal{}, fmt.Errorf("error getting %s position: %v", symbol, err)
+	}
+	decimalPrice := NewDecimalFromFloat(price)
+	log.Printf("%s position: %s at entry price %.8f, %dx %s", symbol, position.Amount, position.EntryPrice, position.Leverage, strings.ToLower(position.MarginType))
+
+	if reduceOnly {
+		size := position.Amount
+		if side == "BUY" {
+			size = size.Neg()
+		}
+		if size.Sign() <= 0 {
+			return Decimal{}, Decimal{}, fmt.Errorf("reduce-only %s run has no %s position to reduce (position %s)", strings.ToLower(side), symbol, position.Amount)
+		}
+		return size.Mul(decimalPrice), size, nil
+	}
+
+	margin, err := client.GetBalance(quoteAsset)
+	if err != nil {
+		return Decimal{}, Decimal{}, fmt.Errorf("error getting %s margin balance: %v", quoteAsset, err)
+	}
+	if transferMax.Sign() > 0 {
+		spot, err := client.spotBalance(quoteAsset)
+		if err != nil {
+			return Decimal{}, Decimal{}, fmt.Errorf("error getting %s spot balance: %v", quoteAsset, err)
+		}
+		margin = margin.Add(minDecimal(transferMax, spot))
+	}
+	quote = margin.Mul(NewDecimalFromInt(int64(max(position.Leverage, 1))))
+	return quote, quote.Div(decimalPrice), nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FXSourceBinance prices the quote asset in the reporting currency with their pair on the exchange, e.g. EURUSDT
+const FXSourceBinance = "binance"
+
+// fxRateMaxAge is how long an FX rate is reused before it is fetched again
+const fxRateMaxAge = 10 * time.Minute
+
+// stablecoinCurrencies maps stablecoin quote assets to the fiat currency they track, for FX sources that only
+// know fiat currencies
+var stablecoinCurrencies = map[string]string{"USDT": "USD", "USDC": "USD", "FDUSD": "USD", "TUSD": "USD", "BUSD": "USD", "EURI": "EUR"}
+
+// FiatConfig expresses a run's fills in a fiat Currency for accounting, at rates from Source: FXSourceBinance,
+// a fixed rate such as "fixed:0.92", or a URL with {from} and {to} placeholders answering JSON with the rate
+// under "rate" or "rates", e.g. https://api.frankfurter.app/latest?from={from}&to={to}
+type FiatConfig struct {
+	Currency string `json:"currency,omitempty"`
+	Source   string `json:"source,omitempty"`
+}
+
+// Enabled reports whether fills are expressed in a fiat currency
+func (c FiatConfig) Enabled() bool {
+	return c.Currency != ""
+}
+
+// parseFiatConfig parses the -report-currency and -fx-source flags
+func parseFiatConfig(currency, source string) (FiatConfig, error) {
+	cfg := FiatConfig{Currency: strings.ToUpper(strings.TrimSpace(currency)), Source: strings.TrimSpace(source)}
+	if cfg.Currency == "" {
+		return cfg, nil
+	}
+	if len(cfg.Currency) != 3 || strings.Trim(cfg.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return cfg, fmt.Errorf("invalid report currency: %s. Use a 3-letter currency code, e.g. EUR, INR or GBP", currency)
+	}
+	switch {
+	case cfg.Source == FXSourceBinance:
+	case strings.HasPrefix(cfg.Source, "fixed:"):
+		if rate, err := strconv.ParseFloat(strings.TrimPrefix(cfg.Source, "fixed:"), 64); err != nil || rate <= 0 {
+			return cfg, fmt.Errorf("invalid fixed FX rate: %s. Use fixed:<currency per quote asset unit>, e.g. fixed:0.92", cfg.Source)
+		}
+	case strings.HasPrefix(cfg.Source, "http://") || strings.HasPrefix(cfg.Source, "https://"):
+	default:
+		return cfg, fmt.Errorf("invalid FX source: %s. Use binance, fixed:<rate> or an http(s) URL", source)
+	}
+	return cfg, nil
+}
+
+// FXConverter converts amounts of a quote asset into a fiat currency at rates from a FiatConfig's source
+type FXConverter struct {
+	cfg    FiatConfig
+	from   string
+	client MarketReader
+	mu     sync.Mutex
+	rate   float64
+	at     time.Time
+}
+
+// newFXConverter returns a converter from quoteAsset into the configured currency, pricing it through client
+// for the binance source
+func newFXConverter(cfg FiatConfig, quoteAsset string, client MarketReader) *FXConverter {
+	return &FXConverter{cfg: cfg, from: quoteAsset, client: client}
+}
+
+// Rate returns the units of the currency per unit of the quote asset, fetched again once older than
+// fxRateMaxAge. A stablecoin is worth one unit of the currency it tracks.
+func (c *FXConverter) Rate() (float64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rate > 0 && time.Since(c.at) < fxRateMaxAge {
+		return c.rate, nil
+	}
+	var rate float64
+	var err error
+	switch {
+	case cmp.Or(stablecoinCurrencies[c.from], c.from) == c.cfg.Currency:
+		rate = 1
+	case strings.HasPrefix(c.cfg.Source, "fixed:"):
+		rate, err = strconv.ParseFloat(strings.TrimPrefix(c.cfg.Source, "fixed:"), 64)
+	case c.cfg.Source == FXSourceBinance:
+		rate, err = c.exchangeRate()
+	default:
+		rate, err = c.urlRate()
+	}
+	if err != nil {
+		return 0, err
+	}
+	c.rate, c.at = rate, time.Now()
+	return rate, nil
+}
+
+// exchangeRate prices the quote asset with its pair against the currency, inverting the price when the currency
+// is the pair's base asset, as in EURUSDT
+func (c *FXConverter) exchangeRate() (float64, error) {
+	if price, err := c.client.GetPrice(c.from + c.cfg.Currency); err == nil && price > 0 {
+		return price, nil
+	}
+	price, err := c.client.GetPrice(c.cfg.Currency + c.from)
+	if err != nil {
+		return 0, fmt.Errorf("error getting the %s%s or %s%s price: %v", c.from, c.cfg.Currency, c.cfg.Currency, c.from, err)
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("invalid %s%s price: %g", c.cfg.Currency, c.from, price)
+	}
+	return 1 / price, nil
+}
+
+// urlRate fetches the rate from the source URL, asking for a stablecoin quote asset as the currency it tracks
+func (c *FXConverter) urlRate() (float64, error) {
+	from := cmp.Or(stablecoinCurrencies[c.from], c.from)
+	url := strings.NewReplacer("{from}", from, "{to}", c.cfg.Currency).Replace(c.cfg.Source)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("error creating request: %v", err)
+	}
+	res, err := readResult(newHTTPClient(), req)
+	if err != nil {
+		return 0, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("FX source returned status %d", res.StatusCode)
+	}
+	var answer struct {
+		Rate  float64            `json:"rate"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.Unmarshal(res.Body, &answer); err != nil {
+		return 0, fmt.Errorf("error parsing FX rate: %v", err)
+	}
+	rate := cmp.Or(answer.Rate, answer.Rates[c.cfg.Currency])
+	if rate <= 0 {
+		return 0, fmt.Errorf("FX source returned no %s rate for %s", c.cfg.Currency, from)
+	}
+	return rate, nil
+}
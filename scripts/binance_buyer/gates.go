@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Reasons a slice is held back by its gates, as recorded in the audit log
+const (
+	HoldBelowMinimum = "below minimum slice"
+	HoldOutsideBand  = "outside price band"
+	HoldWideSpread   = "spread too wide"
+	HoldThinBook     = "order book too thin"
+)
+
+// awaitSlice holds the run back while it is paused, outside its trading window or in a blackout period. It
+// reports whether it waited for the window or a blackout, after which the schedule restarts from now, and
+// false once ctx is cancelled.
+func (s *RunState) awaitSlice(ctx context.Context) (rescheduled, ok bool) {
+	if !s.control.waitWhilePaused(ctx) {
+		return false, false
+	}
+	return s.waitUntilTradable(ctx)
+}
+
+// positionGate caps a BUY slice to the headroom the position limit leaves, checked against the account before
+// every slice since other runs may have bought in the meantime. It reports reached once the headroom is below
+// the minimum slice, and returns the error that kept the position from being read, which defers the slice
+// rather than risk exceeding the limit.
+func (s *RunState) positionGate(client ExchangeClient, tracker *limitOrderTracker, amount Decimal) (capped Decimal, reached bool, err error) {
+	if s.Config.Side != "BUY" || !s.Config.Position.Enabled() {
+		return amount, false, nil
+	}
+	headroom, err := s.positionHeadroom(client, tracker)
+	if err != nil {
+		return amount, false, err
+	}
+	if headroom.LessThan(s.MinSlice) {
+		return amount, true, nil
+	}
+	return minDecimal(amount, headroom), false, nil
+}
+
+// holdReason checks a slice against the market before it is placed, returning why it is held back: below the
+// minimum slice, outside the price band, while the spread is too wide, or with an order book too thin for the
+// minimum slice. A slice that may be placed gets an empty reason and its amount capped to the order book's
+// depth and rounded to an orderable size.
+func (s *RunState) holdReason(ctx context.Context, client ExchangeClient, amount Decimal) (string, Decimal) {
+	cfg := s.Config
+	if amount.LessThan(s.MinSlice) {
+		return HoldBelowMinimum, amount
+	}
+	if !checkPriceBand(ctx, client, cfg.Symbol, cfg.Band) {
+		return HoldOutsideBand, amount
+	}
+	if !checkSpread(ctx, client, cfg.Symbol, cfg.Spread, s.Interval) {
+		return HoldWideSpread, amount
+	}
+	capped := cfg.roundSlice(capToDepth(client, cfg, amount))
+	if capped.LessThan(s.MinSlice) {
+		slog.Info("Order book too thin for the minimum slice. Deferring the slice.", "symbol", cfg.Symbol, "slice", s.NextSlice+1, "min_slice", s.MinSlice)
+		return HoldThinBook, amount
+	}
+	return "", capped
+}
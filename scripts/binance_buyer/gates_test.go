@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// marketClient answers the price, book ticker, order book and balance requests of the slice gates
+type marketClient struct {
+	ExchangeClient
+	price      float64
+	ticker     BookTicker
+	book       *OrderBook
+	balance    Decimal
+	balanceErr error
+}
+
+func (c *marketClient) GetPrice(symbol string) (float64, error) {
+	return c.price, nil
+}
+
+func (c *marketClient) GetBookTicker(symbol string) (*BookTicker, error) {
+	return &c.ticker, nil
+}
+
+func (c *marketClient) GetOrderBook(symbol string, levels int) (*OrderBook, error) {
+	return c.book, nil
+}
+
+func (c *marketClient) GetBalance(asset string) (Decimal, error) {
+	return c.balance, c.balanceErr
+}
+
+func TestAwaitSlice(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Weekday()
+	paused := NewRunControl(cancel, "")
+	paused.paused = true
+	tests := []struct {
+		name        string
+		state       *RunState
+		rescheduled bool
+		ok          bool
+	}{
+		{name: "tradable", state: &RunState{}, ok: true},
+		{name: "paused", state: &RunState{control: paused}},
+		{name: "outside the trading window", state: &RunState{Config: TWAPConfig{Window: TradingWindow{Days: []time.Weekday{tomorrow}}}}, rescheduled: true},
+		{
+			name:        "in a blackout",
+			state:       &RunState{Config: TWAPConfig{Blackouts: []Blackout{{From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)}}}},
+			rescheduled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rescheduled, ok := tt.state.awaitSlice(cancelled)
+			if rescheduled != tt.rescheduled || ok != tt.ok {
+				t.Errorf("got rescheduled=%t ok=%t, want rescheduled=%t ok=%t", rescheduled, ok, tt.rescheduled, tt.ok)
+			}
+		})
+	}
+}
+
+func TestKillReason(t *testing.T) {
+	sentinel := filepath.Join(t.TempDir(), "STOP")
+	if err := os.WriteFile(sentinel, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name  string
+		kill  KillSwitch
+		first float64
+		last  float64
+		halt  bool
+	}{
+		{name: "disabled", first: 100, last: 50},
+		{name: "drop within the limit", kill: KillSwitch{MaxDropPct: 10}, first: 100, last: 91},
+		{name: "drop at the limit", kill: KillSwitch{MaxDropPct: 10}, first: 100, last: 90, halt: true},
+		{name: "no price sampled", kill: KillSwitch{MaxDropPct: 10}},
+		{name: "sentinel file", kill: KillSwitch{SentinelFile: sentinel}, halt: true},
+		{name: "missing sentinel file", kill: KillSwitch{SentinelFile: sentinel + ".missing"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &RunState{Config: TWAPConfig{Kill: tt.kill}, FirstPrice: tt.first, LastPrice: tt.last}
+			if reason := state.killReason(); (reason != "") != tt.halt {
+				t.Errorf("kill reason %q, want halt=%t", reason, tt.halt)
+			}
+		})
+	}
+}
+
+func TestPositionGate(t *testing.T) {
+	tests := []struct {
+		name       string
+		side       string
+		limit      PositionLimit
+		balance    string
+		balanceErr error
+		want       string
+		reached    bool
+		err        bool
+	}{
+		{name: "no limit", side: "BUY", balance: "10", want: "80"},
+		{name: "SELL run", side: "SELL", limit: PositionLimit{MaxBase: decimalOrZero("1")}, balance: "10", want: "80"},
+		{name: "within the limit", side: "BUY", limit: PositionLimit{MaxBase: decimalOrZero("1")}, balance: "0.1", want: "80"},
+		{name: "capped to the headroom", side: "BUY", limit: PositionLimit{MaxBase: decimalOrZero("1")}, balance: "0.5", want: "50"},
+		{name: "capped by the quote limit", side: "BUY", limit: PositionLimit{MaxQuote: decimalOrZero("100")}, balance: "0.7", want: "30"},
+		{name: "limit reached", side: "BUY", limit: PositionLimit{MaxBase: decimalOrZero("1")}, balance: "0.98", reached: true},
+		{name: "position unknown", side: "BUY", limit: PositionLimit{MaxBase: decimalOrZero("1")}, balanceErr: errors.New("timeout"), err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &marketClient{balance: decimalOrZero(tt.balance), balanceErr: tt.balanceErr}
+			state := &RunState{Config: TWAPConfig{Symbol: "BTCUSDT", QuoteAsset: "USDT", Side: tt.side, Position: tt.limit},
+				MinSlice: decimalOrZero("5"), LastPrice: 100}
+			tracker := newLimitOrderTracker(client, state.Config, nil, state.recordFill)
+			amount, reached, err := state.positionGate(client, tracker, decimalOrZero("80"))
+			if reached != tt.reached || (err != nil) != tt.err {
+				t.Fatalf("reached=%t error=%v, want reached=%t error=%t", reached, err, tt.reached, tt.err)
+			}
+			if tt.want != "" && amount.Cmp(decimalOrZero(tt.want)) != 0 {
+				t.Errorf("amount %s, want %s", amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestHoldReason(t *testing.T) {
+	book := &OrderBook{Asks: []BookLevel{{Price: 100, Qty: 1}, {Price: 101, Qty: 1}}}
+	tests := []struct {
+		name   string
+		cfg    TWAPConfig
+		ticker BookTicker
+		amount string
+		reason string
+		want   string
+	}{
+		{name: "placed", amount: "80", want: "80"},
+		{name: "below the minimum slice", amount: "4", reason: HoldBelowMinimum},
+		{name: "outside the price band", cfg: TWAPConfig{Band: PriceBand{Max: 90}}, amount: "80", reason: HoldOutsideBand},
+		{name: "inside the price band", cfg: TWAPConfig{Band: PriceBand{Min: 90, Max: 110}}, amount: "80", want: "80"},
+		{name: "spread too wide", cfg: TWAPConfig{Spread: SpreadLimit{MaxBps: 10}}, ticker: BookTicker{BidPrice: 99, AskPrice: 101}, amount: "80", reason: HoldWideSpread},
+		{name: "spread within the limit", cfg: TWAPConfig{Spread: SpreadLimit{MaxBps: 10}}, amount: "80", want: "80"},
+		{name: "capped to the order book", cfg: TWAPConfig{Depth: DepthLimit{MaxPct: 10, Levels: 2}}, amount: "80", want: "20.1"},
+		{name: "order book too thin", cfg: TWAPConfig{Depth: DepthLimit{MaxPct: 1, Levels: 2}}, amount: "80", reason: HoldThinBook},
+		{name: "rounded to the step size", cfg: TWAPConfig{BaseAmount: true}, amount: "0.123456789", want: "0.12345"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Symbol, cfg.Side, cfg.OrderType, cfg.Filters = "BTCUSDT", "BUY", OrderTypeMarket, btcFilters
+			minSlice := decimalOrZero("5")
+			if cfg.BaseAmount {
+				minSlice = decimalOrZero("0.0001")
+			}
+			ticker := tt.ticker
+			if ticker == (BookTicker{}) {
+				ticker = BookTicker{BidPrice: 99.99, AskPrice: 100.01}
+			}
+			client := &marketClient{price: 100, ticker: ticker, book: book}
+			state := &RunState{Config: cfg, MinSlice: minSlice, Interval: time.Minute}
+			reason, amount := state.holdReason(context.Background(), client, decimalOrZero(tt.amount))
+			if reason != tt.reason {
+				t.Fatalf("reason %q, want %q", reason, tt.reason)
+			}
+			if tt.want != "" && amount.Cmp(decimalOrZero(tt.want)) != 0 {
+				t.Errorf("amount %s, want %s", amount, tt.want)
+			}
+		})
+	}
+}
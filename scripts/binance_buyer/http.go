@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// requestTimeout bounds a single HTTP attempt, from dialing to reading the last byte of the response
+	requestTimeout = 10 * time.Second
+	// maxResponseBytes bounds the size of a response body read into memory
+	maxResponseBytes = 16 << 20
+)
+
+// sharedTransport is the connection pool shared by the exchange clients, so keep-alive connections are reused
+// across requests instead of paying a TCP and TLS handshake for every order
+var sharedTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   10,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   5 * time.Second,
+	ResponseHeaderTimeout: requestTimeout,
+	ExpectContinueTimeout: time.Second,
+}
+
+// newHTTPClient creates an HTTP client on the shared transport. Deadlines are set per request by readResult.
+func newHTTPClient() *http.Client {
+	return &http.Client{Transport: sharedTransport}
+}
+
+// readResult sends the request under a requestTimeout deadline and reads the full response, failing
+// responses larger than maxResponseBytes rather than buffering them
+func readResult(httpClient *http.Client, req *http.Request) (*httpResult, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), requestTimeout)
+	defer cancel()
+
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %v", err)
+	}
+	if len(body) > maxResponseBytes {
+		return nil, fmt.Errorf("error reading response: body exceeds %d bytes", maxResponseBytes)
+	}
+
+	return &httpResult{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
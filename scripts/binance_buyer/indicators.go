@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Indicator is a technical indicator computed from a candle series. Candles are fed one at a time with
+// Update, so the same indicator works on historical klines and on live candles as they close.
+type Indicator interface {
+	// Update adds the next closed candle to the series
+	Update(c Candle)
+	// Value returns the indicator's current value, or NaN until Ready
+	Value() float64
+	// Ready reports whether enough candles have been seen for Value to be meaningful
+	Ready() bool
+}
+
+// IndicatorSeries feeds candles to ind and returns its value after each one, NaN where it was not yet ready
+func IndicatorSeries(ind Indicator, candles []Candle) []float64 {
+	values := make([]float64, len(candles))
+	for i, c := range candles {
+		ind.Update(c)
+		values[i] = ind.Value()
+	}
+	return values
+}
+
+// SMA is the simple moving average of the close price over a fixed number of candles
+type SMA struct {
+	period int
+	window []float64
+	next   int
+	count  int
+	sum    float64
+}
+
+// NewSMA creates a simple moving average over period candles
+func NewSMA(period int) *SMA {
+	return &SMA{period: period, window: make([]float64, period)}
+}
+
+// Update adds the close of c to the average
+func (s *SMA) Update(c Candle) {
+	s.add(c.Close)
+}
+
+// add adds a value to the average, dropping the oldest one once the window is full
+func (s *SMA) add(value float64) {
+	s.sum += value - s.window[s.next]
+	s.window[s.next] = value
+	s.next = (s.next + 1) % s.period
+	s.count = min(s.count+1, s.period)
+}
+
+// Value returns the average of the last period closes
+func (s *SMA) Value() float64 {
+	if !s.Ready() {
+		return math.NaN()
+	}
+	return s.sum / float64(s.period)
+}
+
+// Ready reports whether period candles have been seen
+func (s *SMA) Ready() bool {
+	return s.count == s.period
+}
+
+// stdDev returns the population standard deviation of the values in the window
+func (s *SMA) stdDev() float64 {
+	mean := s.sum / float64(s.period)
+	var variance float64
+	for _, v := range s.window {
+		variance += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(variance / float64(s.period))
+}
+
+// EMA is the exponential moving average of the close price, seeded with the SMA of the first period candles
+type EMA struct {
+	alpha float64
+	seed  *SMA
+	value float64
+}
+
+// NewEMA creates an exponential moving average with smoothing 2/(period+1)
+func NewEMA(period int) *EMA {
+	return newEMA(period, 2/float64(period+1))
+}
+
+// newEMA creates an exponential moving average with the given smoothing factor
+func newEMA(period int, alpha float64) *EMA {
+	return &EMA{alpha: alpha, seed: NewSMA(period)}
+}
+
+// Update adds the close of c to the average
+func (e *EMA) Update(c Candle) {
+	e.add(c.Close)
+}
+
+// add adds a value to the average
+func (e *EMA) add(value float64) {
+	if !e.seed.Ready() {
+		e.seed.add(value)
+		e.value = e.seed.Value()
+		return
+	}
+	e.value += e.alpha * (value - e.value)
+}
+
+// Value returns the current average
+func (e *EMA) Value() float64 {
+	if !e.Ready() {
+		return math.NaN()
+	}
+	return e.value
+}
+
+// Ready reports whether period candles have been seen
+func (e *EMA) Ready() bool {
+	return e.seed.Ready()
+}
+
+// RSI is Wilder's relative strength index of the close price, between 0 and 100
+type RSI struct {
+	gains, losses *EMA
+	prevClose     float64
+	started       bool
+}
+
+// NewRSI creates a relative strength index over period candles (14 is customary)
+func NewRSI(period int) *RSI {
+	return &RSI{gains: newEMA(period, 1/float64(period)), losses: newEMA(period, 1/float64(period))}
+}
+
+// Update adds c to the index
+func (r *RSI) Update(c Candle) {
+	if r.started {
+		change := c.Close - r.prevClose
+		r.gains.add(math.Max(change, 0))
+		r.losses.add(math.Max(-change, 0))
+	}
+	r.prevClose = c.Close
+	r.started = true
+}
+
+// Value returns the current index
+func (r *RSI) Value() float64 {
+	if !r.Ready() {
+		return math.NaN()
+	}
+	if r.losses.value == 0 {
+		return 100
+	}
+	return 100 - 100/(1+r.gains.value/r.losses.value)
+}
+
+// Ready reports whether period price changes have been seen
+func (r *RSI) Ready() bool {
+	return r.gains.Ready()
+}
+
+// MACD is the moving average convergence divergence of the close price: the difference between a fast and
+// a slow EMA, with an EMA of that difference as the signal line
+type MACD struct {
+	fast, slow, signal *EMA
+}
+
+// NewMACD creates a MACD with the given EMA periods (12, 26 and 9 are customary)
+func NewMACD(fast, slow, signal int) *MACD {
+	return &MACD{fast: NewEMA(fast), slow: NewEMA(slow), signal: NewEMA(signal)}
+}
+
+// Update adds c to the MACD
+func (m *MACD) Update(c Candle) {
+	m.fast.Update(c)
+	m.slow.Update(c)
+	if m.fast.Ready() && m.slow.Ready() {
+		m.signal.add(m.fast.value - m.slow.value)
+	}
+}
+
+// Value returns the MACD line
+func (m *MACD) Value() float64 {
+	if !m.slow.Ready() || !m.fast.Ready() {
+		return math.NaN()
+	}
+	return m.fast.value - m.slow.value
+}
+
+// Signal returns the signal line
+func (m *MACD) Signal() float64 {
+	return m.signal.Value()
+}
+
+// Histogram returns the MACD line minus the signal line
+func (m *MACD) Histogram() float64 {
+	return m.Value() - m.Signal()
+}
+
+// Ready reports whether the signal line is available
+func (m *MACD) Ready() bool {
+	return m.signal.Ready()
+}
+
+// BollingerBands are bands placed a number of standard deviations above and below the SMA of the close price
+type BollingerBands struct {
+	sma *SMA
+	k   float64
+}
+
+// NewBollingerBands creates bands k standard deviations around the SMA over period candles (20 and 2 are customary)
+func NewBollingerBands(period int, k float64) *BollingerBands {
+	return &BollingerBands{sma: NewSMA(period), k: k}
+}
+
+// Update adds c to the bands
+func (b *BollingerBands) Update(c Candle) {
+	b.sma.Update(c)
+}
+
+// Value returns the middle band
+func (b *BollingerBands) Value() float64 {
+	return b.sma.Value()
+}
+
+// Upper returns the upper band
+func (b *BollingerBands) Upper() float64 {
+	return b.Value() + b.k*b.width()
+}
+
+// Lower returns the lower band
+func (b *BollingerBands) Lower() float64 {
+	return b.Value() - b.k*b.width()
+}
+
+// width returns the standard deviation of the closes, or NaN until the bands are ready
+func (b *BollingerBands) width() float64 {
+	if !b.Ready() {
+		return math.NaN()
+	}
+	return b.sma.stdDev()
+}
+
+// Ready reports whether period candles have been seen
+func (b *BollingerBands) Ready() bool {
+	return b.sma.Ready()
+}
+
+// ATR is Wilder's average true range, a measure of volatility in price units
+type ATR struct {
+	ranges    *EMA
+	prevClose float64
+	started   bool
+}
+
+// NewATR creates an average true range over period candles (14 is customary)
+func NewATR(period int) *ATR {
+	return &ATR{ranges: newEMA(period, 1/float64(period))}
+}
+
+// Update adds c to the average
+func (a *ATR) Update(c Candle) {
+	trueRange := c.High - c.Low
+	if a.started {
+		trueRange = math.Max(trueRange, math.Max(math.Abs(c.High-a.prevClose), math.Abs(c.Low-a.prevClose)))
+	}
+	a.ranges.add(trueRange)
+	a.prevClose = c.Close
+	a.started = true
+}
+
+// Value returns the current average true range
+func (a *ATR) Value() float64 {
+	return a.ranges.Value()
+}
+
+// Ready reports whether period candles have been seen
+func (a *ATR) Ready() bool {
+	return a.ranges.Ready()
+}
+
+// VWAP is the volume-weighted average of the typical price (high+low+close)/3 since the series started
+// or was last Reset, e.g. at the start of each trading day
+type VWAP struct {
+	priceVolume float64
+	volume      float64
+}
+
+// NewVWAP creates a volume-weighted average price
+func NewVWAP() *VWAP {
+	return &VWAP{}
+}
+
+// Update adds c to the average
+func (v *VWAP) Update(c Candle) {
+	v.priceVolume += (c.High + c.Low + c.Close) / 3 * c.Volume
+	v.volume += c.Volume
+}
+
+// Value returns the current average price
+func (v *VWAP) Value() float64 {
+	if !v.Ready() {
+		return math.NaN()
+	}
+	return v.priceVolume / v.volume
+}
+
+// Ready reports whether any volume has been seen
+func (v *VWAP) Ready() bool {
+	return v.volume > 0
+}
+
+// Reset starts a new averaging session
+func (v *VWAP) Reset() {
+	v.priceVolume, v.volume = 0, 0
+}
+
+// ParseIndicator creates an indicator from a spec such as sma:20, ema:50, rsi:14, atr:14, macd:12:26:9,
+// bb:20:2 or vwap
+func ParseIndicator(spec string) (Indicator, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(spec)), ":")
+	params := make([]float64, len(parts)-1)
+	for i, part := range parts[1:] {
+		value, err := strconv.ParseFloat(part, 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid indicator %q: parameters must be positive numbers", spec)
+		}
+		params[i] = value
+	}
+	counts := map[string]int{"sma": 1, "ema": 1, "rsi": 1, "atr": 1, "macd": 3, "bb": 2, "vwap": 0}
+	count, ok := counts[parts[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown indicator %q. Use sma, ema, rsi, atr, macd, bb or vwap", spec)
+	}
+	if len(params) != count {
+		return nil, fmt.Errorf("invalid indicator %q: %s takes %d parameter(s)", spec, parts[0], count)
+	}
+	switch parts[0] {
+	case "sma":
+		return NewSMA(int(params[0])), nil
+	case "ema":
+		return NewEMA(int(params[0])), nil
+	case "rsi":
+		return NewRSI(int(params[0])), nil
+	case "atr":
+		return NewATR(int(params[0])), nil
+	case "macd":
+		return NewMACD(int(params[0]), int(params[1]), int(params[2])), nil
+	case "bb":
+		return NewBollingerBands(int(params[0]), params[1]), nil
+	default:
+		return NewVWAP(), nil
+	}
+}
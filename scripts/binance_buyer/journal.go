@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journalHeader is the column layout of the trade journal
+var journalHeader = []string{"timestamp", "symbol", "side", "order_id", "status", "executed_qty", "cum_quote_qty", "avg_price", "fee", "fee_asset", "run_id",
+	"fiat_currency", "fx_rate", "cum_quote_fiat", "avg_price_fiat"}
+
+// Journal appends every executed order to a CSV file for tax reporting and performance analysis
+type Journal struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *csv.Writer
+	// columns is the number of columns of the journal's header, which is fewer for journals created before
+	// run IDs or fiat values were recorded
+	columns int
+}
+
+// OpenJournal opens the journal at path for appending, writing the header when the file is new
+func OpenJournal(path string) (*Journal, error) {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".csv" {
+		return nil, fmt.Errorf("unsupported journal format %q: only .csv files are supported", ext)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening journal: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error reading journal: %v", err)
+	}
+
+	journal := &Journal{file: file, writer: csv.NewWriter(file), columns: len(journalHeader)}
+	if info.Size() == 0 {
+		if err := journal.write(journalHeader); err != nil {
+			file.Close()
+			return nil, err
+		}
+		return journal, nil
+	}
+	if journal.columns, err = journalColumns(path); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return journal, nil
+}
+
+// journalColumns returns the number of columns in the header of an existing journal
+func journalColumns(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("error reading journal: %v", err)
+	}
+	defer file.Close()
+	header, err := csv.NewReader(file).Read()
+	if err != nil {
+		return 0, fmt.Errorf("error reading journal header: %v", err)
+	}
+	return min(len(header), len(journalHeader)), nil
+}
+
+// Record appends an order of a run to the journal if any of it was executed, with its value in currency at
+// rate units per quote asset unit when rate is set. A nil journal records nothing.
+func (j *Journal) Record(order *Order, runID, currency string, rate float64) error {
+	if j == nil {
+		return nil
+	}
+	executed := decimalOrZero(order.ExecutedQty)
+	if executed.IsZero() {
+		return nil
+	}
+
+	quote := filledQuote(order)
+	avgPrice := quote.Div(executed)
+	var quoteFiat, priceFiat, fxRate string
+	if rate > 0 {
+		fxRate = strconv.FormatFloat(rate, 'f', -1, 64)
+		quoteFiat = quote.MulFloat(rate).String()
+		priceFiat = avgPrice.MulFloat(rate).String()
+	} else {
+		currency = ""
+	}
+	row := []string{
+		time.Now().UTC().Format(time.RFC3339),
+		order.Symbol,
+		order.Side,
+		order.OrderID,
+		order.Status,
+		order.ExecutedQty,
+		order.CumQuoteQty,
+		avgPrice.String(),
+		order.Commission.String(),
+		order.CommissionAsset,
+		runID,
+		currency,
+		fxRate,
+		quoteFiat,
+		priceFiat,
+	}
+	return j.write(row[:j.columns])
+}
+
+// write appends a row and flushes it so the journal survives a crash
+func (j *Journal) write(row []string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.writer.Write(row); err != nil {
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	j.writer.Flush()
+	if err := j.writer.Error(); err != nil {
+		return fmt.Errorf("error writing journal: %v", err)
+	}
+	return nil
+}
+
+// Close closes the journal file. A nil journal is a no-op.
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
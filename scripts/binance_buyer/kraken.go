@@ -0,0 +1,631 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// krakenAssetNames maps common asset codes to Kraken's legacy X/Z-prefixed balance names
+var krakenAssetNames = map[string]string{
+	"BTC":  "XXBT",
+	"ETH":  "XETH",
+	"LTC":  "XLTC",
+	"XRP":  "XXRP",
+	"XLM":  "XXLM",
+	"ETC":  "XETC",
+	"XMR":  "XXMR",
+	"ZEC":  "XZEC",
+	"DOGE": "XXDG",
+	"USD":  "ZUSD",
+	"EUR":  "ZEUR",
+	"GBP":  "ZGBP",
+	"JPY":  "ZJPY",
+	"CAD":  "ZCAD",
+}
+
+// krakenPairAssets maps common asset codes to the codes Kraken uses in pair names
+var krakenPairAssets = map[string]string{
+	"BTC":  "XBT",
+	"DOGE": "XDG",
+}
+
+// krakenOrderStatuses maps Kraken order statuses to normalized order statuses
+var krakenOrderStatuses = map[string]string{
+	"pending":  OrderStatusNew,
+	"open":     OrderStatusNew,
+	"closed":   OrderStatusFilled,
+	"canceled": OrderStatusCanceled,
+	"expired":  OrderStatusExpired,
+}
+
+// krakenStopTypes maps the stop-limit order types to Kraken order types
+var krakenStopTypes = map[string]string{
+	OrderTypeStopLossLimit:   "stop-loss-limit",
+	OrderTypeTakeProfitLimit: "take-profit-limit",
+}
+
+// krakenUnsafePaths are the private endpoints whose requests may take effect without a definitive response
+// reaching us. The client does not look AddOrder up by a user reference before resending it, so only a rate
+// limit rejection, which Kraken returns before processing the request, is retried.
+var krakenUnsafePaths = map[string]bool{"/0/private/AddOrder": true}
+
+// krakenQuoteAssets lists the quote assets recognised when splitting a symbol, longest first
+var krakenQuoteAssets = []string{"USDT", "USDC", "USD", "EUR", "GBP", "BTC", "ETH"}
+
+// KrakenClient represents the Kraken API client
+type KrakenClient struct {
+	apiKey      string
+	secretKey   string
+	baseURL     string
+	httpClient  *http.Client
+	retryPolicy RetryPolicy
+}
+
+// krakenResponse is the envelope wrapping every Kraken API response
+type krakenResponse struct {
+	Error  []string        `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+// krakenOrderInfo represents a single order returned by QueryOrders
+type krakenOrderInfo struct {
+	Status  string `json:"status"`
+	Vol     string `json:"vol"`
+	VolExec string `json:"vol_exec"`
+	Cost    string `json:"cost"`
+	Price   string `json:"price"`
+	UserRef int64  `json:"userref"`
+	Descr   struct {
+		Pair      string `json:"pair"`
+		Type      string `json:"type"`
+		OrderType string `json:"ordertype"`
+	} `json:"descr"`
+}
+
+// NewKrakenClient creates a new Kraken API client
+func NewKrakenClient(apiKey, secretKey string) *KrakenClient {
+	return &KrakenClient{
+		apiKey:      apiKey,
+		secretKey:   secretKey,
+		baseURL:     "https://api.kraken.com",
+		httpClient:  newHTTPClient(),
+		retryPolicy: defaultRetryPolicy,
+	}
+}
+
+// SetEndpoints replaces the REST host. Kraken has a single host, so only one REST URL can be given, and
+// the client does not use WebSockets.
+func (c *KrakenClient) SetEndpoints(restURLs []string, wsURL string) error {
+	if len(restURLs) > 1 {
+		return fmt.Errorf("kraken supports a single REST URL, got %d", len(restURLs))
+	}
+	if wsURL != "" {
+		return fmt.Errorf("a WebSocket URL is not supported for kraken")
+	}
+	if len(restURLs) == 1 {
+		if err := validateEndpoint(restURLs[0], "http", "https"); err != nil {
+			return err
+		}
+		c.baseURL = restURLs[0]
+	}
+	return nil
+}
+
+// krakenPair translates a symbol such as BTCUSDT into Kraken's pair name (XBTUSDT)
+func krakenPair(symbol string) (string, error) {
+	for _, quote := range krakenQuoteAssets {
+		if base, ok := strings.CutSuffix(symbol, quote); ok && base != "" {
+			return krakenPairAsset(base) + krakenPairAsset(quote), nil
+		}
+	}
+	return "", fmt.Errorf("unable to determine quote asset for symbol %s", symbol)
+}
+
+// krakenPairAsset returns the code Kraken uses for an asset inside a pair name
+func krakenPairAsset(asset string) string {
+	if name, ok := krakenPairAssets[asset]; ok {
+		return name
+	}
+	return asset
+}
+
+// generateSignature generates the API-Sign header for a private Kraken request
+func (c *KrakenClient) generateSignature(path, nonce, postData string) (string, error) {
+	secret, err := base64.StdEncoding.DecodeString(c.secretKey)
+	if err != nil {
+		return "", fmt.Errorf("error decoding secret key: %v", err)
+	}
+
+	sha := sha256.Sum256([]byte(nonce + postData))
+	h := hmac.New(sha512.New, secret)
+	h.Write([]byte(path))
+	h.Write(sha[:])
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// sendPublic sends a public GET request and returns the decoded result payload
+func (c *KrakenClient) sendPublic(path string, params url.Values) (json.RawMessage, error) {
+	return c.send(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", c.baseURL+path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %v", err)
+		}
+		req.URL.RawQuery = params.Encode()
+		return req, nil
+	}, false)
+}
+
+// sendPrivate signs the given parameters with a fresh nonce and sends a private POST request
+func (c *KrakenClient) sendPrivate(path string, params url.Values) (json.RawMessage, error) {
+	return c.send(func() (*http.Request, error) {
+		nonce := strconv.FormatInt(time.Now().UnixNano()/int64(time.Microsecond), 10)
+		params.Set("nonce", nonce)
+		postData := params.Encode()
+
+		signature, err := c.generateSignature(path, nonce, postData)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequest("POST", c.baseURL+path, strings.NewReader(postData))
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %v", err)
+		}
+
+		req.Header.Set("API-Key", c.apiKey)
+		req.Header.Set("API-Sign", signature)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	}, krakenUnsafePaths[path])
+}
+
+// send builds and sends a request with retries, then unwraps the Kraken response envelope.
+// The request is rebuilt on every attempt so private requests get a fresh nonce. An unsafe request may have
+// reached Kraken when it fails without a definitive response, so only its rate limit rejections are retried.
+func (c *KrakenClient) send(newRequest func() (*http.Request, error), unsafe bool) (json.RawMessage, error) {
+	retryable := isKrakenRetryable
+	if unsafe {
+		retryable = isKrakenRateLimited
+	}
+	res, err := doWithRetry(c.retryPolicy, func() (*httpResult, error) {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		res, err := readResult(c.httpClient, req)
+		if err != nil && unsafe {
+			return nil, permanentError{fmt.Errorf("request may have taken effect, not sending it again: %v", err)}
+		}
+		return res, err
+	}, retryable)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API error: %s", string(res.Body))
+	}
+
+	var krakenResp krakenResponse
+	if err := json.Unmarshal(res.Body, &krakenResp); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	if len(krakenResp.Error) > 0 {
+		return nil, fmt.Errorf("API error: %s", strings.Join(krakenResp.Error, ", "))
+	}
+
+	return krakenResp.Result, nil
+}
+
+// isKrakenRetryable reports whether a Kraken response is a transient failure worth retrying
+func isKrakenRetryable(res *httpResult) bool {
+	if isRetryableStatus(res.StatusCode) {
+		return true
+	}
+
+	var krakenResp krakenResponse
+	if err := json.Unmarshal(res.Body, &krakenResp); err != nil {
+		return false
+	}
+	for _, apiErr := range krakenResp.Error {
+		if strings.HasPrefix(apiErr, "EService:") || strings.HasPrefix(apiErr, "EAPI:Rate limit") {
+			return true
+		}
+	}
+	return false
+}
+
+// isKrakenRateLimited reports whether Kraken rejected a request for exceeding the rate limit, which it does
+// before processing the request
+func isKrakenRateLimited(res *httpResult) bool {
+	if res.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	var krakenResp krakenResponse
+	if err := json.Unmarshal(res.Body, &krakenResp); err != nil {
+		return false
+	}
+	for _, apiErr := range krakenResp.Error {
+		if strings.HasPrefix(apiErr, "EAPI:Rate limit") {
+			return true
+		}
+	}
+	return false
+}
+
+// krakenTicker holds the fields of a Kraken ticker entry used by the client
+type krakenTicker struct {
+	Ask       []string `json:"a"`
+	Bid       []string `json:"b"`
+	LastTrade []string `json:"c"`
+}
+
+// getTicker fetches the ticker entry for a symbol
+func (c *KrakenClient) getTicker(symbol string) (*krakenTicker, error) {
+	pair, err := krakenPair(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("pair", pair)
+	result, err := c.sendPublic("/0/public/Ticker", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var tickers map[string]krakenTicker
+	if err := json.Unmarshal(result, &tickers); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	for _, ticker := range tickers {
+		if len(ticker.Ask) == 0 || len(ticker.Bid) == 0 || len(ticker.LastTrade) == 0 {
+			break
+		}
+		return &ticker, nil
+	}
+
+	return nil, fmt.Errorf("no ticker returned for %s", pair)
+}
+
+// GetPrice gets the last traded price of a symbol
+func (c *KrakenClient) GetPrice(symbol string) (float64, error) {
+	ticker, err := c.getTicker(symbol)
+	if err != nil {
+		return 0, err
+	}
+
+	price, err := strconv.ParseFloat(ticker.LastTrade[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing price: %v", err)
+	}
+	return price, nil
+}
+
+// GetBookTicker gets the best bid and ask of a symbol
+func (c *KrakenClient) GetBookTicker(symbol string) (*BookTicker, error) {
+	ticker, err := c.getTicker(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	bid, err := strconv.ParseFloat(ticker.Bid[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing bid price: %v", err)
+	}
+	ask, err := strconv.ParseFloat(ticker.Ask[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ask price: %v", err)
+	}
+
+	return &BookTicker{Symbol: symbol, BidPrice: bid, AskPrice: ask}, nil
+}
+
+// krakenIntervals maps kline intervals to the interval in minutes used by Kraken's OHLC endpoint
+var krakenIntervals = map[string]int{
+	"1m":  1,
+	"5m":  5,
+	"15m": 15,
+	"30m": 30,
+	"1h":  60,
+	"4h":  240,
+	"1d":  1440,
+	"1w":  10080,
+}
+
+// GetKlines fetches the candles of a symbol for the given interval opened between start and end. Kraken only
+// serves the most recent 720 candles of an interval, so older history is not available.
+func (c *KrakenClient) GetKlines(symbol, interval string, start, end time.Time) ([]Candle, error) {
+	pair, err := krakenPair(symbol)
+	if err != nil {
+		return nil, err
+	}
+	minutes, ok := krakenIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("unsupported kline interval for Kraken: %s", interval)
+	}
+
+	params := url.Values{}
+	params.Set("pair", pair)
+	params.Set("interval", strconv.Itoa(minutes))
+	params.Set("since", strconv.FormatInt(start.Unix(), 10))
+	result, err := c.sendPublic("/0/public/OHLC", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var series map[string]json.RawMessage
+	if err := json.Unmarshal(result, &series); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	var candles []Candle
+	for name, raw := range series {
+		if name == "last" {
+			continue
+		}
+		var rows [][]any
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			return nil, fmt.Errorf("error parsing response: %v", err)
+		}
+		for _, row := range rows {
+			if len(row) < 8 {
+				return nil, fmt.Errorf("unexpected OHLC format: %v", row)
+			}
+			openSeconds, _ := row[0].(float64)
+			openTime := time.Unix(int64(openSeconds), 0)
+			if openTime.Before(start) {
+				continue
+			}
+			if openTime.After(end) {
+				break
+			}
+			volume := parseAnyFloat(row[6])
+			trades, _ := row[7].(float64)
+			candles = append(candles, Candle{
+				OpenTime:    openTime,
+				CloseTime:   openTime.Add(time.Duration(minutes)*time.Minute - time.Millisecond),
+				Open:        parseAnyFloat(row[1]),
+				High:        parseAnyFloat(row[2]),
+				Low:         parseAnyFloat(row[3]),
+				Close:       parseAnyFloat(row[4]),
+				Volume:      volume,
+				QuoteVolume: volume * parseAnyFloat(row[5]),
+				Trades:      int(trades),
+			})
+		}
+	}
+
+	return candles, nil
+}
+
+// GetOrderBook fetches the best levels of a symbol's order book
+func (c *KrakenClient) GetOrderBook(symbol string, levels int) (*OrderBook, error) {
+	pair, err := krakenPair(symbol)
+	if err != nil {
+		return nil, err
+	}
+	params := url.Values{}
+	params.Set("pair", pair)
+	params.Set("count", strconv.Itoa(levels))
+	result, err := c.sendPublic("/0/public/Depth", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var books map[string]struct {
+		Bids [][]any `json:"bids"`
+		Asks [][]any `json:"asks"`
+	}
+	if err := json.Unmarshal(result, &books); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	for _, book := range books {
+		return &OrderBook{Bids: parseBookLevels(book.Bids), Asks: parseBookLevels(book.Asks)}, nil
+	}
+	return nil, fmt.Errorf("order book for %s not found", symbol)
+}
+
+// GetSymbolFilters fetches the price and volume precision and order minimums of a symbol from AssetPairs
+func (c *KrakenClient) GetSymbolFilters(symbol string) (*SymbolFilters, error) {
+	pair, err := krakenPair(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("pair", pair)
+	result, err := c.sendPublic("/0/public/AssetPairs", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var pairs map[string]struct {
+		PairDecimals int    `json:"pair_decimals"`
+		CostDecimals int    `json:"cost_decimals"`
+		LotDecimals  int    `json:"lot_decimals"`
+		OrderMin     string `json:"ordermin"`
+		CostMin      string `json:"costmin"`
+		TickSize     string `json:"tick_size"`
+	}
+	if err := json.Unmarshal(result, &pairs); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	for _, info := range pairs {
+		filters := &SymbolFilters{
+			Symbol:         symbol,
+			TickSize:       decimalOrZero("1e-" + strconv.Itoa(info.PairDecimals)),
+			StepSize:       decimalOrZero("1e-" + strconv.Itoa(info.LotDecimals)),
+			MinQty:         decimalOrZero(info.OrderMin),
+			MinNotional:    decimalOrZero(info.CostMin),
+			QuotePrecision: info.CostDecimals,
+		}
+		if tickSize, err := ParseDecimal(info.TickSize); err == nil {
+			filters.TickSize = tickSize
+		}
+		return filters, nil
+	}
+
+	return nil, fmt.Errorf("no asset pair returned for %s", pair)
+}
+
+// GetBalance gets the balance for a given asset, translating it to Kraken's naming
+func (c *KrakenClient) GetBalance(asset string) (Decimal, error) {
+	result, err := c.sendPrivate("/0/private/Balance", url.Values{})
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	var balances map[string]string
+	if err := json.Unmarshal(result, &balances); err != nil {
+		return Decimal{}, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	balance, ok := balances[krakenAssetNames[asset]]
+	if !ok {
+		balance, ok = balances[asset]
+	}
+	if !ok {
+		return Decimal{}, fmt.Errorf("%s balance not found", asset)
+	}
+
+	free, err := ParseDecimal(balance)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error parsing %s balance: %v", asset, err)
+	}
+	return free, nil
+}
+
+// PlaceOrder places a limit order, or a market order by base quantity or sized from the quote quantity at the current price
+func (c *KrakenClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	pair, err := krakenPair(req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Set("pair", pair)
+	params.Set("type", strings.ToLower(req.Side))
+
+	if req.Type == OrderTypeLimit {
+		if req.TimeInForce == TimeInForceFOK {
+			return nil, fmt.Errorf("time in force %s is not supported by Kraken", req.TimeInForce)
+		}
+		params.Set("ordertype", "limit")
+		params.Set("timeinforce", req.TimeInForce)
+		params.Set("volume", req.Quantity.String())
+		params.Set("price", req.Price.String())
+	} else if req.Type == OrderTypeLimitMaker {
+		params.Set("ordertype", "limit")
+		params.Set("oflags", "post")
+		params.Set("volume", req.Quantity.String())
+		params.Set("price", req.Price.String())
+	} else if isStopLimit(req.Type) {
+		if err := req.validateStopLimit(); err != nil {
+			return nil, err
+		}
+		// Kraken triggers at price and places the limit order at price2
+		params.Set("ordertype", krakenStopTypes[req.Type])
+		params.Set("volume", req.Quantity.String())
+		params.Set("price", req.StopPrice.String())
+		params.Set("price2", req.Price.String())
+	} else if req.Quantity.Sign() > 0 {
+		params.Set("ordertype", "market")
+		params.Set("volume", req.Quantity.String())
+	} else {
+		price, err := c.GetPrice(req.Symbol)
+		if err != nil {
+			return nil, err
+		}
+		params.Set("ordertype", "market")
+		params.Set("volume", req.QuoteQuantity.Div(NewDecimalFromFloat(price)).Truncate(8).String())
+	}
+
+	result, err := c.sendPrivate("/0/private/AddOrder", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var added struct {
+		TxID []string `json:"txid"`
+	}
+	if err := json.Unmarshal(result, &added); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	if len(added.TxID) == 0 {
+		return nil, fmt.Errorf("no transaction id returned for order")
+	}
+
+	order, err := c.GetOrder(req.Symbol, added.TxID[0])
+	if err != nil {
+		return &Order{
+			Symbol:  req.Symbol,
+			OrderID: added.TxID[0],
+			Status:  OrderStatusNew,
+			Type:    req.Type,
+			Side:    strings.ToUpper(req.Side),
+		}, nil
+	}
+	return order, nil
+}
+
+// GetOrder queries the status of an order by transaction id
+func (c *KrakenClient) GetOrder(symbol, orderID string) (*Order, error) {
+	params := url.Values{}
+	params.Set("txid", orderID)
+
+	result, err := c.sendPrivate("/0/private/QueryOrders", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders map[string]krakenOrderInfo
+	if err := json.Unmarshal(result, &orders); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+
+	info, ok := orders[orderID]
+	if !ok {
+		return nil, fmt.Errorf("order %s not found", orderID)
+	}
+
+	status := krakenOrderStatuses[info.Status]
+	if status == OrderStatusNew && decimalOrZero(info.VolExec).Sign() > 0 {
+		status = OrderStatusPartiallyFilled
+	}
+
+	return &Order{
+		Symbol:        symbol,
+		OrderID:       orderID,
+		ClientOrderID: strconv.FormatInt(info.UserRef, 10),
+		Price:         info.Price,
+		OrigQty:       info.Vol,
+		ExecutedQty:   info.VolExec,
+		CumQuoteQty:   info.Cost,
+		Status:        status,
+		Type:          strings.ToUpper(info.Descr.OrderType),
+		Side:          strings.ToUpper(info.Descr.Type),
+	}, nil
+}
+
+// CancelOrder cancels an open order by transaction id
+func (c *KrakenClient) CancelOrder(symbol, orderID string) error {
+	params := url.Values{}
+	params.Set("txid", orderID)
+
+	_, err := c.sendPrivate("/0/private/CancelOrder", params)
+	return err
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestKrakenClient returns a Kraken client sending to url with fast retries
+func newTestKrakenClient(t *testing.T, url string) *KrakenClient {
+	t.Helper()
+	client := NewKrakenClient("key", "c2VjcmV0")
+	client.retryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	if err := client.SetEndpoints([]string{url}, ""); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// TestKrakenAddOrderRetries checks that AddOrder is only resent after a rate limit rejection, as any other
+// failure may have placed the order
+func TestKrakenAddOrderRetries(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		sends  int
+	}{
+		{name: "server error", status: http.StatusServiceUnavailable, body: `{"error":[]}`, sends: 1},
+		{name: "service unavailable", status: http.StatusOK, body: `{"error":["EService:Unavailable"]}`, sends: 1},
+		{name: "rate limit", status: http.StatusOK, body: `{"error":["EAPI:Rate limit exceeded"]}`, sends: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			sends := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				if r.URL.Path == "/0/private/AddOrder" {
+					sends++
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			client := newTestKrakenClient(t, server.URL)
+			_, err := client.PlaceOrder(OrderRequest{Symbol: "BTCUSD", Side: "BUY", Type: OrderTypeLimit,
+				Quantity: decimalOrZero("0.01"), Price: decimalOrZero("50000")})
+			if err == nil {
+				t.Fatal("placed the order, want an error")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if sends != tt.sends {
+				t.Errorf("sent AddOrder %d times, want %d", sends, tt.sends)
+			}
+		})
+	}
+}
+
+func TestKrakenSignature(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		path     string
+		nonce    string
+		postData string
+		want     string
+		err      bool
+	}{
+		{
+			// The example from Kraken's REST API authentication guide
+			name:     "documented example",
+			secret:   "kQH5HW/8p1uGOVjbgWA7FunAmGO8lsSUXNsu3eow76sz84Q18fWxnyRzBHCd3pd5nE9qa99HAZtuZuj6F1huXg==",
+			path:     "/0/private/AddOrder",
+			nonce:    "1616492376594",
+			postData: "nonce=1616492376594&ordertype=limit&pair=XBTUSD&price=37500&type=buy&volume=1.25",
+			want:     "4/dpxb3iT4tp/ZCVEwSnEsLxx0bqyhLpdfOpc6fn7OR8+UClSV5n9E6aSS8MPtnRfp32bAb0nmbRn6H8ndwLUQ==",
+		},
+		{name: "secret not base64", secret: "not base64!", path: "/0/private/Balance", nonce: "1", postData: "nonce=1", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewKrakenClient("key", tt.secret)
+			got, err := client.generateSignature(tt.path, tt.nonce, tt.postData)
+			if tt.err {
+				if err == nil {
+					t.Errorf("got signature %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestKrakenPlaceOrder places a limit order on a server answering like Kraken and checks the AddOrder request
+// and the order parsed from QueryOrders
+func TestKrakenPlaceOrder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		if r.Header.Get("API-Key") != "key" || r.Header.Get("API-Sign") == "" || r.PostForm.Get("nonce") == "" {
+			t.Errorf("%s sent without its API key, signature and nonce", r.URL.Path)
+		}
+		switch r.URL.Path {
+		case "/0/private/AddOrder":
+			for key, want := range map[string]string{"pair": "XBTUSD", "type": "buy", "ordertype": "limit", "volume": "0.01", "price": "50000"} {
+				if got := r.PostForm.Get(key); got != want {
+					t.Errorf("AddOrder %s %q, want %q", key, got, want)
+				}
+			}
+			fmt.Fprint(w, `{"error":[],"result":{"descr":{"order":"buy 0.01 XBTUSD @ limit 50000"},"txid":["OUF4EM-FRGI2-MQMWZD"]}}`)
+		case "/0/private/QueryOrders":
+			if txid := r.PostForm.Get("txid"); txid != "OUF4EM-FRGI2-MQMWZD" {
+				t.Errorf("QueryOrders txid %q, want the placed order", txid)
+			}
+			fmt.Fprint(w, `{"error":[],"result":{"OUF4EM-FRGI2-MQMWZD":{"status":"open","userref":42,"vol":"0.01000000",`+
+				`"vol_exec":"0.00400000","cost":"200.00000","price":"50000.0","descr":{"pair":"XBTUSD","type":"buy","ordertype":"limit"}}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := newTestKrakenClient(t, server.URL)
+	order, err := client.PlaceOrder(OrderRequest{Symbol: "BTCUSD", Side: "BUY", Type: OrderTypeLimit,
+		Quantity: decimalOrZero("0.01"), Price: decimalOrZero("50000")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Order{Symbol: "BTCUSD", OrderID: "OUF4EM-FRGI2-MQMWZD", ClientOrderID: "42", Price: "50000.0", OrigQty: "0.01000000",
+		ExecutedQty: "0.00400000", CumQuoteQty: "200.00000", Status: OrderStatusPartiallyFilled, Type: "LIMIT", Side: "BUY"}
+	if *order != want {
+		t.Errorf("got %+v, want %+v", *order, want)
+	}
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"math"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// LadderConfig holds the settings of a laddered limit buy
+type LadderConfig struct {
+	Symbol string `json:"symbol"`
+	// Amount is the quote budget spread over the rungs
+	Amount Decimal `json:"amount"`
+	// Steps are the percentages below the reference price the rungs are placed at, and Weights their shares
+	// of the budget
+	Steps   []float64 `json:"steps"`
+	Weights []float64 `json:"weights"`
+	// ReladderPct is how far the price must move from the reference, in percent, before the unfilled rungs are
+	// placed again around the new price, where zero keeps the ladder where it was placed
+	ReladderPct float64    `json:"reladder_pct"`
+	Kill        KillSwitch `json:"kill,omitzero"`
+}
+
+// ladderRung is one limit buy of a ladder and the part of its budget already spent
+type ladderRung struct {
+	Step   float64
+	Budget Decimal
+	Spent  Decimal
+	Base   Decimal
+	// Order is the rung's resting order, nil while it has none, and Executed and ExecutedQuote the part of it
+	// already counted in Spent and Base
+	Order         *Order
+	Price         Decimal
+	Executed      Decimal
+	ExecutedQuote Decimal
+	// Done is set once the rest of the budget is too small for an order
+	Done bool
+}
+
+// Ladder places a ladder of limit buys at percentage steps below the price, tracks their fills and optionally
+// moves the unfilled rungs along with the price
+type Ladder struct {
+	cfg       LadderConfig
+	client    ExchangeClient
+	filters   *SymbolFilters
+	runID     string
+	rungs     []*ladderRung
+	reference float64
+	placed    int
+	// FilledBase and FilledQuote are the base bought and quote spent over every rung
+	FilledBase  Decimal
+	FilledQuote Decimal
+	Fills       int
+	// consecutiveErrors counts the order placements that failed in a row, for the kill switch
+	consecutiveErrors int
+}
+
+// NewLadder creates a ladder for cfg's symbol, splitting its budget over the rungs by weight
+func NewLadder(client ExchangeClient, cfg LadderConfig) (*Ladder, error) {
+	filters, err := client.GetSymbolFilters(cfg.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("error getting symbol filters: %v", err)
+	}
+	var weightSum float64
+	for _, weight := range cfg.Weights {
+		weightSum += weight
+	}
+	l := &Ladder{cfg: cfg, client: client, filters: filters, runID: newRunID()}
+	for i, step := range cfg.Steps {
+		budget := filters.RoundQuote(cfg.Amount.MulFloat(cfg.Weights[i] / weightSum))
+		if err := filters.ValidateNotional(budget); err != nil {
+			return nil, fmt.Errorf("rung at -%g%% gets %s of the budget: %v", step, budget, err)
+		}
+		l.rungs = append(l.rungs, &ladderRung{Step: step, Budget: budget})
+	}
+	return l, nil
+}
+
+// account counts the part of a rung's order that executed since it was last seen
+func (l *Ladder) account(rung *ladderRung, order *Order) {
+	executed, executedQuote := decimalOrZero(order.ExecutedQty), filledQuote(order)
+	qty, value := executed.Sub(rung.Executed), executedQuote.Sub(rung.ExecutedQuote)
+	rung.Order, rung.Executed, rung.ExecutedQuote = order, executed, executedQuote
+	if isTerminalStatus(order.Status) {
+		rung.Order = nil
+	}
+	if qty.Sign() <= 0 {
+		return
+	}
+	rung.Spent, rung.Base = rung.Spent.Add(value), rung.Base.Add(qty)
+	l.FilledBase, l.FilledQuote = l.FilledBase.Add(qty), l.FilledQuote.Add(value)
+	l.Fills++
+	slog.Info("Rung filled", "symbol", l.cfg.Symbol, "step_pct", -rung.Step, "order_id", order.OrderID, "qty", qty,
+		"quote_qty", value, "rung_spent", rung.Spent, "rung_budget", rung.Budget, "filled_quote", l.FilledQuote)
+}
+
+// sync refreshes the open rungs, counting their fills
+func (l *Ladder) sync() {
+	for _, rung := range l.rungs {
+		if rung.Order == nil {
+			continue
+		}
+		order, err := l.client.GetOrder(l.cfg.Symbol, rung.Order.OrderID)
+		if err != nil {
+			slog.Error("Error querying rung", "symbol", l.cfg.Symbol, "order_id", rung.Order.OrderID, "error", err)
+			continue
+		}
+		l.account(rung, order)
+	}
+}
+
+// cancel cancels a rung's order, counting what it executed before the cancel, and reports whether it is gone
+func (l *Ladder) cancel(rung *ladderRung) bool {
+	orderID := rung.Order.OrderID
+	if err := l.client.CancelOrder(l.cfg.Symbol, orderID); err != nil {
+		slog.Error("Error cancelling rung", "symbol", l.cfg.Symbol, "order_id", orderID, "error", err)
+	}
+	order, err := l.client.GetOrder(l.cfg.Symbol, orderID)
+	if err != nil {
+		slog.Error("Error querying rung", "symbol", l.cfg.Symbol, "order_id", orderID, "error", err)
+		return false
+	}
+	l.account(rung, order)
+	return rung.Order == nil
+}
+
+// place places a rung's limit buy for the rest of its budget at its step below the reference price, marking the
+// rung done when the rest is too small for an order. It returns the error that prevented the order.
+func (l *Ladder) place(rung *ladderRung) error {
+	price := l.filters.RoundPrice(NewDecimalFromFloat(l.reference*(1-rung.Step/100)), "BUY")
+	quantity := l.filters.RoundQuantity(rung.Budget.Sub(rung.Spent).Div(price))
+	if err := l.filters.ValidateOrder(quantity, price); err != nil {
+		rung.Done = true
+		return nil
+	}
+	l.placed++
+	order, err := l.client.PlaceOrder(OrderRequest{
+		Symbol:        l.cfg.Symbol,
+		Side:          "BUY",
+		Type:          OrderTypeLimit,
+		Quantity:      quantity,
+		Price:         price,
+		TimeInForce:   TimeInForceGTC,
+		ClientOrderID: fmt.Sprintf("%s-ld%d", l.runID, l.placed),
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Rung placed", "symbol", l.cfg.Symbol, "step_pct", -rung.Step, "order_id", order.OrderID, "price", price, "qty", quantity)
+	rung.Price, rung.Executed, rung.ExecutedQuote = price, Decimal{}, Decimal{}
+	l.account(rung, order)
+	return nil
+}
+
+// fill places the rungs without an order that have budget left. It returns a reason once the kill switch's
+// error limit is reached.
+func (l *Ladder) fill() string {
+	for _, rung := range l.rungs {
+		if rung.Order != nil || rung.Done {
+			continue
+		}
+		err := l.place(rung)
+		if err == nil {
+			l.consecutiveErrors = 0
+			continue
+		}
+		l.consecutiveErrors++
+		slog.Error("Error placing rung", "symbol", l.cfg.Symbol, "step_pct", -rung.Step, "error", err)
+		if limit := l.cfg.Kill.MaxConsecutiveErrors; limit > 0 && l.consecutiveErrors >= limit {
+			return fmt.Sprintf("%d rungs failed in a row, last: %v", l.consecutiveErrors, err)
+		}
+	}
+	return ""
+}
+
+// done reports whether every rung has spent its budget
+func (l *Ladder) done() bool {
+	for _, rung := range l.rungs {
+		if !rung.Done || rung.Order != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// reladder cancels the open rungs once the price has moved ReladderPct from the reference, so they are placed
+// again around price
+func (l *Ladder) reladder(price float64) {
+	moved := math.Abs(price/l.reference-1) * 100
+	if l.cfg.ReladderPct <= 0 || moved < l.cfg.ReladderPct || !slices.ContainsFunc(l.rungs, func(rung *ladderRung) bool { return rung.Order != nil }) {
+		return
+	}
+	slog.Info("Price moved away from the ladder. Re-laddering the unfilled rungs.", "symbol", l.cfg.Symbol,
+		"reference", l.reference, "price", price, "moved_pct", moved)
+	for _, rung := range l.rungs {
+		if rung.Order != nil && !l.cancel(rung) {
+			// A rung that cannot be cancelled keeps the old reference until the next check
+			return
+		}
+	}
+	l.reference = price
+}
+
+// Run places the ladder and tracks it until every rung is filled, ctx is cancelled or the kill switch trips,
+// checking the fills and the price on every streamed trade and at least once per poll, then cancels the
+// unfilled rungs
+func (l *Ladder) Run(ctx context.Context, poll time.Duration) error {
+	price, err := l.client.GetPrice(l.cfg.Symbol)
+	if err != nil {
+		return fmt.Errorf("error getting the price of %s: %v", l.cfg.Symbol, err)
+	}
+	l.reference = price
+	log.Printf("Laddering %s of quote into %s: %d rungs at %s%% below %.8f", l.cfg.Amount, l.cfg.Symbol, len(l.rungs), formatSteps(l.cfg.Steps), price)
+	var reason string
+	var sentinelCheckedAt time.Time
+	for reason == "" {
+		if time.Since(sentinelCheckedAt) >= poll {
+			sentinelCheckedAt = time.Now()
+			if reason = l.cfg.Kill.sentinelReason(); reason != "" {
+				break
+			}
+		}
+		l.sync()
+		if price, err := l.client.GetPrice(l.cfg.Symbol); err != nil {
+			slog.Error("Error getting price, ladder left unchanged", "symbol", l.cfg.Symbol, "error", err)
+		} else {
+			l.reladder(price)
+		}
+		if reason = l.fill(); reason != "" || l.done() {
+			break
+		}
+		if !waitForTrade(ctx, l.client, l.cfg.Symbol, poll) {
+			break
+		}
+	}
+
+	open := 0
+	for _, rung := range l.rungs {
+		if rung.Order != nil && !l.cancel(rung) {
+			open++
+		}
+	}
+	average := 0.0
+	if l.FilledBase.Sign() > 0 {
+		average = l.FilledQuote.Div(l.FilledBase).Float64()
+	}
+	log.Printf("Ladder stopped after %d fill(s): bought %s for %s of %s at an average price of %.8f", l.Fills, l.FilledBase, l.FilledQuote, l.cfg.Amount, average)
+	if open > 0 {
+		log.Printf("WARNING: %d rung(s) could not be cancelled. Cancel them with trade cancel-all.", open)
+	}
+	if reason != "" {
+		return fmt.Errorf("kill switch: %s", reason)
+	}
+	return nil
+}
+
+// formatSteps formats ladder steps as a comma-separated list
+func formatSteps(steps []float64) string {
+	var parts []string
+	for _, step := range steps {
+		parts = append(parts, strconv.FormatFloat(step, 'f', -1, 64))
+	}
+	return strings.Join(parts, ",")
+}
+
+// parsePositiveFloats parses a comma-separated list of positive numbers
+func parsePositiveFloats(list string) ([]float64, error) {
+	var values []float64
+	for _, part := range strings.Split(list, ",") {
+		value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || value <= 0 {
+			return nil, fmt.Errorf("invalid value %q: a positive number is required", part)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// runLadder places a ladder of limit buys below the price and tracks it until it is filled or interrupted
+func runLadder(args []string) error {
+	fs, common := newFlagSet("ladder")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair to buy")
+	totalAmount := fs.Float64("total-amount", 0, "Quote amount spread over the rungs (e.g., 1000)")
+	levels := fs.Int("levels", 5, "Number of rungs, placed -step-pct apart below the price")
+	stepPct := fs.Float64("step-pct", 1, "Distance between rungs in percent of the price, the first rung being this far below it")
+	steps := fs.String("steps", "", "Comma-separated percentages below the price to place the rungs at, replacing -levels and -step-pct (e.g., 1,2,3.5,5)")
+	weights := fs.String("weights", "", "Comma-separated shares of the budget of each rung, in order (e.g., 1,1,2,2,3; default equal)")
+	reladderPct := fs.Float64("reladder-pct", 0, "Place the unfilled rungs again around the price once it moves this percentage from where they were placed (0 to disable)")
+	poll := fs.String("poll", "5s", "Longest time between fill and price checks when the price is not streamed or quiet")
+	marketData := fs.String("market-data", "ws", "Price source: ws (WebSocket stream with REST fallback) or rest")
+	userStream := fs.Bool("user-stream", true, "Track fills from the exchange's user data stream")
+	orderTransport := fs.String("order-transport", OrderTransportREST, "Transport rungs are placed and cancelled over: rest, or ws (Binance spot WebSocket API with REST fallback)")
+	killMaxErrors := fs.Int("kill-max-errors", 5, "Stop and cancel the rungs after this many rungs fail to be placed in a row (0 to disable)")
+	killFile := fs.String("kill-file", "", "Stop and cancel the rungs once this file exists (e.g., /tmp/STOP_TRADING)")
+	killURL := fs.String("kill-url", "", "Stop and cancel the rungs once this URL answers \"stop\" or {\"stop\": true}, checked every -poll")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+
+	if *totalAmount <= 0 {
+		return fmt.Errorf("a positive -total-amount is required")
+	}
+	var stepList []float64
+	if *steps != "" {
+		var err error
+		if stepList, err = parsePositiveFloats(*steps); err != nil {
+			return fmt.Errorf("steps: %v", err)
+		}
+	} else {
+		if *levels <= 0 || *stepPct <= 0 {
+			return fmt.Errorf("-levels and -step-pct must be positive")
+		}
+		for i := 1; i <= *levels; i++ {
+			stepList = append(stepList, float64(i)**stepPct)
+		}
+	}
+	for _, step := range stepList {
+		if step >= 100 {
+			return fmt.Errorf("steps must be below 100%%")
+		}
+	}
+	weightList := make([]float64, len(stepList))
+	for i := range weightList {
+		weightList[i] = 1
+	}
+	if *weights != "" {
+		var err error
+		if weightList, err = parsePositiveFloats(*weights); err != nil {
+			return fmt.Errorf("weights: %v", err)
+		}
+		if len(weightList) != len(stepList) {
+			return fmt.Errorf("%d weights given for %d rungs", len(weightList), len(stepList))
+		}
+	}
+	if *reladderPct < 0 {
+		return fmt.Errorf("-reladder-pct cannot be negative")
+	}
+	if *marketData != "ws" && *marketData != "rest" {
+		return fmt.Errorf("invalid market data source: %s. Use ws or rest", *marketData)
+	}
+	if *orderTransport != OrderTransportREST && *orderTransport != OrderTransportWS {
+		return fmt.Errorf("invalid order transport: %s. Use rest or ws", *orderTransport)
+	}
+	pollEvery, err := parseDuration(*poll)
+	if err != nil {
+		return fmt.Errorf("error parsing poll interval: %v", err)
+	}
+	cfg := LadderConfig{
+		Symbol:      *symbol,
+		Amount:      NewDecimalFromFloat(*totalAmount),
+		Steps:       stepList,
+		Weights:     weightList,
+		ReladderPct: *reladderPct,
+		Kill:        KillSwitch{MaxConsecutiveErrors: *killMaxErrors, SentinelFile: *killFile, SentinelURL: *killURL},
+	}
+
+	client, stopStreams, err := connectRun(common, common.exchange, common.market, common.testnet, cfg.Symbol, nil, *userStream, *marketData, *orderTransport)
+	if err != nil {
+		return err
+	}
+	defer stopStreams()
+	ladder, err := NewLadder(client, cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return ladder.Run(ctx, pollEvery)
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Cost basis methods of a position ledger
+const (
+	// CostBasisFIFO matches sells against the oldest lots first
+	CostBasisFIFO = "fifo"
+	// CostBasisAverage matches sells against the average cost of the whole position
+	CostBasisAverage = "average"
+	// CostBasisLIFO matches sells against the newest lots first
+	CostBasisLIFO = "lifo"
+	// CostBasisHIFO matches sells against the lots with the highest unit cost first
+	CostBasisHIFO = "hifo"
+)
+
+// maxTradePages bounds the trade history pages fetched for a symbol in one sync
+const maxTradePages = 100
+
+// Lot is a quantity of an asset bought at once and its total cost in the quote asset, fees included
+type Lot struct {
+	Time time.Time `json:"time"`
+	Qty  Decimal   `json:"qty"`
+	Cost Decimal   `json:"cost"`
+}
+
+// Realization is a sell matched against the cost of the lots it disposed of
+type Realization struct {
+	Time time.Time `json:"time"`
+	Qty  Decimal   `json:"qty"`
+	// Proceeds is what the sell brought in, net of fees paid in the quote asset
+	Proceeds Decimal `json:"proceeds"`
+	Cost     Decimal `json:"cost"`
+}
+
+// PnL returns the realized profit or loss of the sell
+func (r Realization) PnL() Decimal {
+	return r.Proceeds.Sub(r.Cost)
+}
+
+// Position is the open lots and realized sells of one symbol
+type Position struct {
+	Symbol       string        `json:"symbol"`
+	Lots         []Lot         `json:"lots"`
+	Realizations []Realization `json:"realizations"`
+	// SyncedAt is the time of the last trade applied to the position
+	SyncedAt time.Time `json:"synced_at,omitzero"`
+	// SyncedKeys identifies the trades executed at SyncedAt, which the next sync fetches again
+	SyncedKeys []string `json:"synced_keys,omitempty"`
+}
+
+// Ledger tracks the cost basis of positions from the account's fills, persisted so later syncs only fetch
+// new trades
+type Ledger struct {
+	Method     string               `json:"method"`
+	QuoteAsset string               `json:"quote_asset"`
+	Positions  map[string]*Position `json:"positions"`
+	UpdatedAt  time.Time            `json:"updated_at"`
+}
+
+// loadLedger reads the ledger at path, returning an empty ledger when the file does not exist yet
+func loadLedger(path, method, quoteAsset string) (*Ledger, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Ledger{Method: method, QuoteAsset: quoteAsset, Positions: map[string]*Position{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading ledger: %v", err)
+	}
+
+	var ledger Ledger
+	if err := json.Unmarshal(data, &ledger); err != nil {
+		return nil, fmt.Errorf("error parsing ledger: %v", err)
+	}
+	if ledger.Method != method || ledger.QuoteAsset != quoteAsset {
+		return nil, fmt.Errorf("ledger %s uses %s cost basis in %s. Use another -ledger for %s cost basis in %s",
+			path, ledger.Method, ledger.QuoteAsset, method, quoteAsset)
+	}
+	if ledger.Positions == nil {
+		ledger.Positions = map[string]*Position{}
+	}
+	return &ledger, nil
+}
+
+// save writes the ledger to path
+func (l *Ledger) save(path string) error {
+	l.UpdatedAt = time.Now()
+	return writeJSONFile(path, l)
+}
+
+// position returns the position of symbol, creating it when the ledger has none
+func (l *Ledger) position(symbol string) *Position {
+	position, ok := l.Positions[symbol]
+	if !ok {
+		position = &Position{Symbol: symbol}
+		l.Positions[symbol] = position
+	}
+	return position
+}
+
+// tradeKey identifies a trade, which the exchange clients return without a trade ID
+func tradeKey(trade Trade) string {
+	return strings.Join([]string{trade.OrderID, trade.Side, trade.Price.String(), trade.Qty.String()}, "/")
+}
+
+// sync applies the trades of symbol executed since the position was last synced, or since start for a new
+// position. Trades are fetched a page at a time, each page starting at the last trade of the previous one.
+func (l *Ledger) sync(history OrderHistory, symbol string, start time.Time) (int, error) {
+	position := l.position(symbol)
+	since := position.SyncedAt
+	if since.IsZero() {
+		since = start
+	}
+
+	applied := 0
+	for range maxTradePages {
+		trades, err := history.GetTrades(symbol, since, time.Time{})
+		if err != nil {
+			return applied, fmt.Errorf("error getting trades for %s: %v", symbol, err)
+		}
+		slices.SortStableFunc(trades, func(a, b Trade) int { return a.Time.Compare(b.Time) })
+
+		fresh := 0
+		for _, trade := range trades {
+			key := tradeKey(trade)
+			if trade.Time.Before(position.SyncedAt) || (trade.Time.Equal(position.SyncedAt) && slices.Contains(position.SyncedKeys, key)) {
+				continue
+			}
+			position.apply(trade, strings.TrimSuffix(symbol, l.QuoteAsset), l.QuoteAsset, l.Method)
+			if !trade.Time.Equal(position.SyncedAt) {
+				position.SyncedAt = trade.Time
+				position.SyncedKeys = nil
+			}
+			position.SyncedKeys = append(position.SyncedKeys, key)
+			fresh++
+		}
+		applied += fresh
+		if fresh == 0 || !position.SyncedAt.After(since) {
+			return applied, nil
+		}
+		since = position.SyncedAt
+	}
+	log.Printf("Stopped syncing %s after %d pages of trades. Run again to continue.", symbol, maxTradePages)
+	return applied, nil
+}
+
+// apply updates the position with a fill. Fees paid in the base asset reduce the quantity bought or add to
+// the quantity sold, and fees paid in the quote asset add to the cost or reduce the proceeds. Fees paid in
+// other assets are not counted.
+func (p *Position) apply(trade Trade, baseAsset, quoteAsset, method string) {
+	qty, quote := trade.Qty, trade.QuoteQty
+	switch trade.CommissionAsset {
+	case baseAsset:
+		if trade.Side == "BUY" {
+			qty = qty.Sub(trade.Commission)
+		} else {
+			qty = qty.Add(trade.Commission)
+		}
+	case quoteAsset:
+		if trade.Side == "BUY" {
+			quote = quote.Add(trade.Commission)
+		} else {
+			quote = quote.Sub(trade.Commission)
+		}
+	}
+
+	if trade.Side == "BUY" {
+		if method == CostBasisAverage && len(p.Lots) > 0 {
+			p.Lots[0].Qty = p.Lots[0].Qty.Add(qty)
+			p.Lots[0].Cost = p.Lots[0].Cost.Add(quote)
+			return
+		}
+		p.Lots = append(p.Lots, Lot{Time: trade.Time, Qty: qty, Cost: quote})
+		return
+	}
+
+	cost, uncovered := p.consume(qty)
+	if uncovered.Sign() > 0 {
+		// Without a known cost basis, the uncovered part is counted at its sale price so it realizes nothing
+		log.Printf("%s sell at %s exceeds the ledger's position by %s. Counting it without profit or loss.",
+			p.Symbol, trade.Time.Format(time.RFC3339), uncovered)
+		cost = cost.Add(quote.Mul(uncovered).Div(qty))
+	}
+	p.Realizations = append(p.Realizations, Realization{Time: trade.Time, Qty: qty, Proceeds: quote, Cost: cost})
+}
+
+// consume removes qty from the lots, oldest first, returning the cost of what it removed and the quantity
+// the lots could not cover
+func (p *Position) consume(qty Decimal) (Decimal, Decimal) {
+	var cost Decimal
+	for qty.Sign() > 0 && len(p.Lots) > 0 {
+		lot := &p.Lots[0]
+		take := minDecimal(qty, lot.Qty)
+		portion := lot.Cost.Mul(take).Div(lot.Qty)
+		cost = cost.Add(portion)
+		lot.Qty = lot.Qty.Sub(take)
+		lot.Cost = lot.Cost.Sub(portion)
+		qty = qty.Sub(take)
+		if lot.Qty.Sign() <= 0 {
+			p.Lots = p.Lots[1:]
+		}
+	}
+	return cost, qty
+}
+
+// Holding returns the open quantity of the position and its cost basis
+func (p *Position) Holding() (Decimal, Decimal) {
+	var qty, cost Decimal
+	for _, lot := range p.Lots {
+		qty = qty.Add(lot.Qty)
+		cost = cost.Add(lot.Cost)
+	}
+	return qty, cost
+}
+
+// RealizedSince returns the profit or loss realized by the sells executed at or after since
+func (p *Position) RealizedSince(since time.Time) Decimal {
+	var pnl Decimal
+	for _, realization := range p.Realizations {
+		if !realization.Time.Before(since) {
+			pnl = pnl.Add(realization.PnL())
+		}
+	}
+	return pnl
+}
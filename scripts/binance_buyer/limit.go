@@ -0,0 +1,395 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxClientOrderIDLength is the longest client order ID Binance accepts
+const maxClientOrderIDLength = 36
+
+// maxOrderLookupFailures is how many polls in a row may fail to query a tracked order before it is dropped,
+// e.g. when the exchange no longer knows the order
+const maxOrderLookupFailures = 5
+
+// LimitOrderConfig holds the settings used when slices are executed as limit orders
+type LimitOrderConfig struct {
+	OffsetBps   float64       `json:"offset_bps"`
+	TimeInForce string        `json:"time_in_force"`
+	Timeout     time.Duration `json:"timeout"`
+	Reprice     bool          `json:"reprice"`
+	// MakerPatience is how long a LIMIT_MAKER slice keeps re-posting before its remainder is sent at market
+	MakerPatience time.Duration `json:"maker_patience,omitempty"`
+}
+
+// trackedOrder is an open limit order awaiting a fill
+type trackedOrder struct {
+	Order    *Order    `json:"order"`
+	Price    Decimal   `json:"price"`
+	PlacedAt time.Time `json:"placed_at"`
+	// Since is when the slice was first posted, carried over to the orders re-posting it
+	Since time.Time `json:"since,omitzero"`
+	// lookupFailures counts the polls in a row that failed to query the order
+	lookupFailures int
+}
+
+// limitOrderTracker keeps track of unfilled limit orders and reprices or cancels them after a timeout
+type limitOrderTracker struct {
+	client     ExchangeClient
+	cfg        TWAPConfig
+	open       []*trackedOrder
+	recordFill func(*Order)
+	// audit, when set, records the tracker's decisions in the run's audit log
+	audit func(event string, args ...any)
+}
+
+// newLimitOrderTracker creates a tracker for the limit orders of a run, resuming any still open orders.
+// recordFill is called with every order once it reaches a terminal status.
+func newLimitOrderTracker(client ExchangeClient, cfg TWAPConfig, open []*trackedOrder, recordFill func(*Order)) *limitOrderTracker {
+	return &limitOrderTracker{client: client, cfg: cfg, open: open, recordFill: recordFill}
+}
+
+// limitPrice computes the limit price offset from the mid-price towards the passive side of the book
+func limitPrice(ticker *BookTicker, side string, offsetBps float64) float64 {
+	offset := ticker.Mid() * offsetBps / 10000
+	if side == "BUY" {
+		return ticker.Mid() - offset
+	}
+	return ticker.Mid() + offset
+}
+
+// makerPrice returns the most aggressive price that still rests on the book: one tick inside the spread when
+// it is wider than a tick, otherwise the best bid for a BUY or the best ask for a SELL
+func makerPrice(ticker *BookTicker, side string, tick Decimal) Decimal {
+	bid, ask := NewDecimalFromFloat(ticker.BidPrice), NewDecimalFromFloat(ticker.AskPrice)
+	if side == "BUY" {
+		if inside := bid.Add(tick); inside.LessThan(ask) {
+			return inside
+		}
+		return bid
+	}
+	if inside := ask.Sub(tick); inside.GreaterThan(bid) {
+		return inside
+	}
+	return ask
+}
+
+// bookMovedAway reports whether the best price on an order's side of the book has moved past the order's
+// price, leaving it behind the queue
+func bookMovedAway(ticker *BookTicker, side string, price Decimal) bool {
+	if side == "BUY" {
+		return NewDecimalFromFloat(ticker.BidPrice).GreaterThan(price)
+	}
+	return NewDecimalFromFloat(ticker.AskPrice).LessThan(price)
+}
+
+// Place submits a limit order for the given amount of the run's budget under clientOrderID and returns the
+// amount committed to it, along with the error that prevented the order from being placed
+func (t *limitOrderTracker) Place(amount Decimal, clientOrderID string) (Decimal, error) {
+	return t.place(amount, clientOrderID, time.Now())
+}
+
+// place submits a limit order for a slice first posted at since
+func (t *limitOrderTracker) place(amount Decimal, clientOrderID string, since time.Time) (Decimal, error) {
+	ticker, err := t.client.GetBookTicker(t.cfg.Symbol)
+	if err != nil {
+		log.Printf("Error getting book ticker: %v", err)
+		return Decimal{}, err
+	}
+
+	price := t.cfg.Filters.RoundPrice(NewDecimalFromFloat(limitPrice(ticker, t.cfg.Side, t.cfg.Limit.OffsetBps)), t.cfg.Side)
+	orderType := OrderTypeLimit
+	if t.cfg.OrderType == OrderTypeLimitMaker {
+		orderType = OrderTypeLimitMaker
+		price = makerPrice(ticker, t.cfg.Side, t.cfg.Filters.TickSize)
+	}
+	qty := amount
+	if !t.cfg.BaseAmount {
+		qty = amount.Div(price)
+	}
+	qty = t.cfg.Filters.RoundQuantity(qty)
+	if err := t.cfg.Filters.ValidateOrder(qty, price); err != nil {
+		slog.Warn("Skipping limit order", "symbol", t.cfg.Symbol, "side", t.cfg.Side, "qty", qty, "price", price, "error", err)
+		t.record(AuditSkipped, "client_order_id", clientOrderID, "qty", qty, "price", price, "reason", err.Error())
+		return Decimal{}, nil
+	}
+
+	order, err := t.client.PlaceOrder(OrderRequest{
+		Symbol:        t.cfg.Symbol,
+		Side:          t.cfg.Side,
+		Type:          orderType,
+		Quantity:      qty,
+		Price:         price,
+		TimeInForce:   t.cfg.Limit.TimeInForce,
+		ClientOrderID: clientOrderID,
+		ReduceOnly:    t.cfg.Futures.ReduceOnly,
+		SideEffect:    t.cfg.SideEffect,
+	})
+	if err != nil {
+		slog.Error("Error placing limit order", "symbol", t.cfg.Symbol, "side", t.cfg.Side, "qty", qty, "price", price, "error", err)
+		t.record(AuditError, "client_order_id", clientOrderID, "qty", qty, "price", price, "error", err.Error())
+		return Decimal{}, err
+	}
+	slog.Info("Limit order placed", "symbol", t.cfg.Symbol, "side", t.cfg.Side, "order_id", order.OrderID, "status", order.Status,
+		"price", price, "qty", order.OrigQty, "executed_qty", order.ExecutedQty)
+	t.record(AuditPlaced, "order_id", order.OrderID, "client_order_id", order.ClientOrderID, "type", orderType,
+		"side", t.cfg.Side, "qty", qty, "price", price)
+
+	committed := qty
+	if !t.cfg.BaseAmount {
+		committed = qty.Mul(price)
+	}
+	switch order.Status {
+	case OrderStatusNew, OrderStatusPartiallyFilled:
+		t.open = append(t.open, &trackedOrder{Order: order, Price: price, PlacedAt: time.Now(), Since: since})
+		return committed, nil
+	default:
+		t.recordFill(order)
+		return t.cfg.executedAmount(order), nil
+	}
+}
+
+// record records an event in the run's audit log, if the tracker has one
+func (t *limitOrderTracker) record(event string, args ...any) {
+	if t.audit != nil {
+		t.audit(event, args...)
+	}
+}
+
+// Poll refreshes the tracked orders and handles the ones that timed out, returning the
+// quote amount released back to the budget. When final is set, timed out orders are
+// cancelled rather than repriced.
+func (t *limitOrderTracker) Poll(final bool) Decimal {
+	var released Decimal
+	var stillOpen []*trackedOrder
+	polled := len(t.open)
+
+	for _, tracked := range t.open[:polled] {
+		order, err := t.client.GetOrder(t.cfg.Symbol, tracked.Order.OrderID)
+		if err != nil {
+			tracked.lookupFailures++
+			if tracked.lookupFailures >= maxOrderLookupFailures {
+				slog.Error("Dropping limit order that cannot be queried; check it on the exchange", "symbol", t.cfg.Symbol,
+					"order_id", tracked.Order.OrderID, "client_order_id", tracked.Order.ClientOrderID, "price", tracked.Price,
+					"failures", tracked.lookupFailures, "error", err)
+				t.record(AuditError, "order_id", tracked.Order.OrderID, "client_order_id", tracked.Order.ClientOrderID,
+					"error", fmt.Sprintf("dropped after %d failed queries: %v", tracked.lookupFailures, err))
+				continue
+			}
+			slog.Error("Error querying order", "symbol", t.cfg.Symbol, "order_id", tracked.Order.OrderID, "error", err)
+			stillOpen = append(stillOpen, tracked)
+			continue
+		}
+		tracked.lookupFailures = 0
+
+		if isTerminalStatus(order.Status) {
+			switch order.Status {
+			case OrderStatusFilled:
+				slog.Info("Limit order filled", "symbol", t.cfg.Symbol, "side", t.cfg.Side, "order_id", order.OrderID, "orig_qty", order.OrigQty, "executed_qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty)
+			case OrderStatusRejected, "EXPIRED_IN_MATCH":
+				slog.Warn("Limit order ended", "symbol", t.cfg.Symbol, "side", t.cfg.Side, "order_id", order.OrderID, "status", order.Status, "executed_qty", order.ExecutedQty)
+			}
+			t.recordFill(order)
+			released = released.Add(t.cfg.unspentAmount(order, tracked.Price))
+			continue
+		}
+
+		if t.cfg.OrderType == OrderTypeLimitMaker {
+			release, open := t.pollMaker(tracked, order)
+			released = released.Add(release)
+			if open {
+				stillOpen = append(stillOpen, tracked)
+			}
+			continue
+		}
+
+		if time.Since(tracked.PlacedAt) < t.cfg.Limit.Timeout {
+			tracked.Order = order
+			stillOpen = append(stillOpen, tracked)
+			continue
+		}
+
+		if err := t.client.CancelOrder(t.cfg.Symbol, order.OrderID); err != nil {
+			slog.Error("Error cancelling timed out order", "symbol", t.cfg.Symbol, "order_id", order.OrderID, "error", err)
+			stillOpen = append(stillOpen, tracked)
+			continue
+		}
+		if cancelled, err := t.client.GetOrder(t.cfg.Symbol, order.OrderID); err == nil {
+			order = cancelled
+		}
+		t.recordFill(order)
+
+		remaining := t.cfg.unspentAmount(order, tracked.Price)
+		slog.Info("Limit order timed out", "symbol", t.cfg.Symbol, "order_id", order.OrderID, "timeout", t.cfg.Limit.Timeout, "unfilled", remaining, "budget_asset", t.cfg.budgetAsset())
+		if !t.cfg.Limit.Reprice || final {
+			released = released.Add(remaining)
+			continue
+		}
+
+		slog.Info("Repricing limit order", "symbol", t.cfg.Symbol, "order_id", order.OrderID, "amount", remaining, "budget_asset", t.cfg.budgetAsset())
+		placed, _ := t.Place(remaining, repriceClientOrderID(order.ClientOrderID))
+		released = released.Add(remaining.Sub(placed))
+	}
+
+	t.open = append(stillOpen, t.open[polled:]...)
+	return released
+}
+
+// pollMaker re-posts an open LIMIT_MAKER order at the new best price when the book has moved away from it, or
+// sends its remainder as a market order once the slice has run out of patience. It returns the amount
+// released back to the budget and whether the order is still open.
+func (t *limitOrderTracker) pollMaker(tracked *trackedOrder, order *Order) (Decimal, bool) {
+	since := cmp.Or(tracked.Since, tracked.PlacedAt)
+	outOfPatience := time.Since(since) >= t.cfg.Limit.MakerPatience
+	if !outOfPatience {
+		ticker, err := t.client.GetBookTicker(t.cfg.Symbol)
+		if err != nil || !bookMovedAway(ticker, t.cfg.Side, tracked.Price) {
+			tracked.Order = order
+			return Decimal{}, true
+		}
+	}
+
+	if err := t.client.CancelOrder(t.cfg.Symbol, order.OrderID); err != nil {
+		slog.Error("Error cancelling maker order", "symbol", t.cfg.Symbol, "order_id", order.OrderID, "error", err)
+		tracked.Order = order
+		return Decimal{}, true
+	}
+	if cancelled, err := t.client.GetOrder(t.cfg.Symbol, order.OrderID); err == nil {
+		order = cancelled
+	}
+	t.recordFill(order)
+
+	remaining := t.cfg.unspentAmount(order, tracked.Price)
+	if remaining.IsZero() {
+		return Decimal{}, false
+	}
+	clientOrderID := repriceClientOrderID(order.ClientOrderID)
+	var placed Decimal
+	if outOfPatience {
+		slog.Info("Maker order out of patience, sending the rest at market", "symbol", t.cfg.Symbol, "order_id", order.OrderID,
+			"patience", t.cfg.Limit.MakerPatience, "amount", remaining, "budget_asset", t.cfg.budgetAsset())
+		placed, _ = t.placeMarket(remaining, clientOrderID)
+	} else {
+		slog.Info("Book moved, re-posting maker order", "symbol", t.cfg.Symbol, "order_id", order.OrderID, "price", tracked.Price,
+			"amount", remaining, "budget_asset", t.cfg.budgetAsset())
+		placed, _ = t.place(remaining, clientOrderID, since)
+	}
+	return remaining.Sub(placed), false
+}
+
+// placeMarket sends the given amount of the run's budget as a market order and returns the amount it executed
+func (t *limitOrderTracker) placeMarket(amount Decimal, clientOrderID string) (Decimal, error) {
+	request := OrderRequest{Symbol: t.cfg.Symbol, Side: t.cfg.Side, Type: OrderTypeMarket, ClientOrderID: clientOrderID, ReduceOnly: t.cfg.Futures.ReduceOnly, SideEffect: t.cfg.SideEffect}
+	var err error
+	if t.cfg.BaseAmount {
+		request.Quantity = t.cfg.Filters.RoundQuantity(amount)
+		amount = request.Quantity
+		if price, priceErr := t.client.GetPrice(t.cfg.Symbol); priceErr == nil {
+			err = t.cfg.Filters.ValidateOrder(request.Quantity, NewDecimalFromFloat(price))
+		}
+	} else {
+		request.QuoteQuantity = t.cfg.Filters.RoundQuote(amount)
+		amount = request.QuoteQuantity
+		err = t.cfg.Filters.ValidateNotional(request.QuoteQuantity)
+	}
+	if err != nil {
+		slog.Warn("Skipping market order", "symbol", t.cfg.Symbol, "side", t.cfg.Side, "qty", request.Quantity, "quote_qty", request.QuoteQuantity, "error", err)
+		t.record(AuditSkipped, "client_order_id", clientOrderID, "qty", request.Quantity, "quote_qty", request.QuoteQuantity, "reason", err.Error())
+		return Decimal{}, nil
+	}
+
+	order, err := t.client.PlaceOrder(request)
+	if err != nil {
+		slog.Error("Error placing market order", "symbol", t.cfg.Symbol, "side", t.cfg.Side, "qty", request.Quantity, "quote_qty", request.QuoteQuantity, "error", err)
+		t.record(AuditError, "client_order_id", clientOrderID, "qty", request.Quantity, "quote_qty", request.QuoteQuantity, "error", err.Error())
+		return Decimal{}, err
+	}
+	order = settleOrder(t.client, t.cfg.Symbol, order)
+	slog.Info("Order placed", "symbol", t.cfg.Symbol, "side", t.cfg.Side, "order_id", order.OrderID, "status", order.Status,
+		"qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty, "price", order.Price)
+	t.record(AuditPlaced, "order_id", order.OrderID, "client_order_id", order.ClientOrderID, "type", OrderTypeMarket,
+		"side", t.cfg.Side, "qty", request.Quantity, "quote_qty", request.QuoteQuantity)
+	t.recordFill(order)
+	if !isTerminalStatus(order.Status) {
+		return amount, nil
+	}
+	return t.cfg.executedAmount(order), nil
+}
+
+// Drain waits for the outstanding limit orders to reach a terminal status, time out or be dropped after failing
+// to be queried, and returns the released quote amount. Orders still open when ctx is cancelled are left open
+// and tracked.
+func (t *limitOrderTracker) Drain(ctx context.Context) Decimal {
+	var released Decimal
+	for len(t.open) > 0 {
+		log.Printf("Waiting for %d open limit order(s)", len(t.open))
+		if !sleepContext(ctx, time.Second) {
+			break
+		}
+		released = released.Add(t.Poll(true))
+	}
+	return released
+}
+
+// CancelAll cancels every tracked order and returns the released amount. Orders that cannot be cancelled
+// stay tracked.
+func (t *limitOrderTracker) CancelAll() Decimal {
+	var released Decimal
+	var stillOpen []*trackedOrder
+	for _, tracked := range t.open {
+		if err := t.client.CancelOrder(t.cfg.Symbol, tracked.Order.OrderID); err != nil {
+			slog.Error("Error cancelling order", "symbol", t.cfg.Symbol, "order_id", tracked.Order.OrderID, "error", err)
+			stillOpen = append(stillOpen, tracked)
+			continue
+		}
+		order := tracked.Order
+		if cancelled, err := t.client.GetOrder(t.cfg.Symbol, order.OrderID); err == nil {
+			order = cancelled
+		}
+		t.recordFill(order)
+		released = released.Add(t.cfg.unspentAmount(order, tracked.Price))
+	}
+	t.open = stillOpen
+	return released
+}
+
+// repriceClientOrderID derives the client order ID of a repriced order from the ID of the order it replaces,
+// numbering the reprices "<id>-r1", "<id>-r2" and so on. IDs that would grow too long are left to the exchange.
+func repriceClientOrderID(id string) string {
+	base, reprices := splitRepriceSuffix(id)
+	repriced := fmt.Sprintf("%s-r%d", base, reprices+1)
+	if id == "" || len(repriced) > maxClientOrderIDLength {
+		return ""
+	}
+	return repriced
+}
+
+// splitRepriceSuffix splits a client order ID into the ID of the original order and the number of reprices
+func splitRepriceSuffix(id string) (string, int) {
+	if i := strings.LastIndex(id, "-r"); i >= 0 {
+		if n, err := strconv.Atoi(id[i+2:]); err == nil {
+			return id[:i], n
+		}
+	}
+	return id, 0
+}
+
+// committedAmount returns the part of the budget a limit order for its original quantity at price commits
+func (c TWAPConfig) committedAmount(order *Order, price Decimal) Decimal {
+	if c.BaseAmount {
+		return decimalOrZero(order.OrigQty)
+	}
+	return decimalOrZero(order.OrigQty).Mul(price)
+}
+
+// unspentAmount returns the part of the budget committed to a limit order at price that its fills did not
+// spend: its unfilled quantity, and for quote budgets also what it saved by filling better than price
+func (c TWAPConfig) unspentAmount(order *Order, price Decimal) Decimal {
+	return c.committedAmount(order, price).Sub(c.executedAmount(order))
+}
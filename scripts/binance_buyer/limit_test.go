@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// orderLookupClient answers order queries from a fixed map, failing for orders it does not know
+type orderLookupClient struct {
+	ExchangeClient
+	orders map[string]*Order
+}
+
+func (c *orderLookupClient) GetOrder(symbol, orderID string) (*Order, error) {
+	if order, ok := c.orders[orderID]; ok {
+		return order, nil
+	}
+	return nil, errors.New("code=-2013, msg=Order does not exist.")
+}
+
+func TestLimitOrderTrackerPollSettlesTerminalOrders(t *testing.T) {
+	tests := []struct {
+		status      string
+		executedQty string
+		cumQuoteQty string
+		released    string
+	}{
+		{status: OrderStatusFilled, executedQty: "0.01", cumQuoteQty: "500", released: "0"},
+		{status: OrderStatusCanceled, executedQty: "0.004", cumQuoteQty: "200", released: "300"},
+		{status: OrderStatusExpired, executedQty: "0", cumQuoteQty: "0", released: "500"},
+		{status: OrderStatusRejected, executedQty: "0", cumQuoteQty: "0", released: "500"},
+		{status: "EXPIRED_IN_MATCH", executedQty: "0.002", cumQuoteQty: "100", released: "400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			order := &Order{OrderID: "1", Status: tt.status, OrigQty: "0.01", ExecutedQty: tt.executedQty, CumQuoteQty: tt.cumQuoteQty}
+			client := &orderLookupClient{orders: map[string]*Order{"1": order}}
+			var recorded []*Order
+			tracker := newLimitOrderTracker(client, TWAPConfig{Symbol: "BTCUSDT", Side: "BUY"},
+				[]*trackedOrder{{Order: &Order{OrderID: "1", Status: OrderStatusNew, OrigQty: "0.01"}, Price: decimalOrZero("50000"), PlacedAt: time.Now()}},
+				func(o *Order) { recorded = append(recorded, o) })
+
+			released := tracker.Poll(false)
+			if released.Cmp(decimalOrZero(tt.released)) != 0 {
+				t.Errorf("released %s, want %s", released, tt.released)
+			}
+			if len(tracker.open) != 0 {
+				t.Errorf("%d orders still tracked, want none", len(tracker.open))
+			}
+			if len(recorded) != 1 || recorded[0] != order {
+				t.Errorf("recorded %v, want the settled order", recorded)
+			}
+		})
+	}
+}
+
+func TestLimitOrderTrackerPollDropsOrdersThatCannotBeQueried(t *testing.T) {
+	client := &orderLookupClient{}
+	tracker := newLimitOrderTracker(client, TWAPConfig{Symbol: "BTCUSDT", Side: "BUY"},
+		[]*trackedOrder{{Order: &Order{OrderID: "1", Status: OrderStatusNew, OrigQty: "0.01"}, Price: decimalOrZero("50000"), PlacedAt: time.Now()}},
+		func(*Order) { t.Error("recorded a fill for an order that could not be queried") })
+
+	for i := 1; i < maxOrderLookupFailures; i++ {
+		tracker.Poll(false)
+		if len(tracker.open) != 1 {
+			t.Fatalf("order dropped after %d failed queries, want %d", i, maxOrderLookupFailures)
+		}
+	}
+	if released := tracker.Poll(false); !released.IsZero() {
+		t.Errorf("released %s for a dropped order, want nothing", released)
+	}
+	if len(tracker.open) != 0 {
+		t.Errorf("order still tracked after %d failed queries", maxOrderLookupFailures)
+	}
+}
+
+func TestLimitOrderTrackerLookupFailuresResetOnSuccess(t *testing.T) {
+	client := &orderLookupClient{orders: map[string]*Order{}}
+	tracked := &trackedOrder{Order: &Order{OrderID: "1", Status: OrderStatusNew, OrigQty: "0.01"}, Price: decimalOrZero("50000"), PlacedAt: time.Now()}
+	tracker := newLimitOrderTracker(client, TWAPConfig{Symbol: "BTCUSDT", Side: "BUY", Limit: LimitOrderConfig{Timeout: time.Hour}},
+		[]*trackedOrder{tracked}, func(*Order) {})
+
+	for i := 1; i < maxOrderLookupFailures; i++ {
+		tracker.Poll(false)
+	}
+	client.orders["1"] = &Order{OrderID: "1", Status: OrderStatusNew, OrigQty: "0.01"}
+	tracker.Poll(false)
+	delete(client.orders, "1")
+	tracker.Poll(false)
+	if len(tracker.open) != 1 || tracked.lookupFailures != 1 {
+		t.Errorf("tracked %d orders with %d failures, want the order kept with 1", len(tracker.open), tracked.lookupFailures)
+	}
+}
+
+func TestLimitOrderTrackerDrainEndsOnRejectedOrder(t *testing.T) {
+	client := &orderLookupClient{orders: map[string]*Order{"1": {OrderID: "1", Status: OrderStatusRejected, OrigQty: "0.01", ExecutedQty: "0", CumQuoteQty: "0"}}}
+	tracker := newLimitOrderTracker(client, TWAPConfig{Symbol: "BTCUSDT", Side: "BUY"},
+		[]*trackedOrder{{Order: &Order{OrderID: "1", Status: OrderStatusNew, OrigQty: "0.01"}, Price: decimalOrZero("50000"), PlacedAt: time.Now()}},
+		func(*Order) {})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	released := tracker.Drain(ctx)
+	if ctx.Err() != nil {
+		t.Fatal("Drain did not return before the context expired")
+	}
+	if released.Cmp(decimalOrZero("500")) != 0 {
+		t.Errorf("released %s, want 500", released)
+	}
+}
+
+func TestRepriceClientOrderID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{id: "0123456789abcdef-12", want: "0123456789abcdef-12-r1"},
+		{id: "0123456789abcdef-12-r1", want: "0123456789abcdef-12-r2"},
+		{id: "0123456789abcdef-12-r9", want: "0123456789abcdef-12-r10"},
+		{id: "order-rx", want: "order-rx-r1"},
+		{id: strings.Repeat("x", maxClientOrderIDLength-3), want: strings.Repeat("x", maxClientOrderIDLength-3) + "-r1"},
+		{id: strings.Repeat("x", maxClientOrderIDLength-2)},
+		{id: strings.Repeat("x", maxClientOrderIDLength-3) + "-r9"},
+		{id: ""},
+	}
+	for _, tt := range tests {
+		if got := repriceClientOrderID(tt.id); got != tt.want {
+			t.Errorf("repriceClientOrderID(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestSplitRepriceSuffix(t *testing.T) {
+	tests := []struct {
+		id       string
+		base     string
+		reprices int
+	}{
+		{id: "run-3", base: "run-3"},
+		{id: "run-3-r2", base: "run-3", reprices: 2},
+		{id: "run-3-r", base: "run-3-r"},
+		{id: "", base: ""},
+	}
+	for _, tt := range tests {
+		if base, reprices := splitRepriceSuffix(tt.id); base != tt.base || reprices != tt.reprices {
+			t.Errorf("splitRepriceSuffix(%q) = %q, %d, want %q, %d", tt.id, base, reprices, tt.base, tt.reprices)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// maxAlertBytes bounds the body of an alert webhook
+const maxAlertBytes = 64 << 10
+
+// alertRequest is the JSON message of a TradingView alert, which cannot set headers, so the shared secret
+// travels in the body
+type alertRequest struct {
+	Secret string `json:"secret"`
+	Plan   string `json:"plan"`
+}
+
+// alertListener runs the pre-configured execution plan named by each authenticated alert, one run at a time
+type alertListener struct {
+	// plans maps each plan name to its trade exec arguments
+	plans  map[string][]string
+	secret string
+	queue  chan string
+}
+
+// loadPlans reads the execution plans from a JSON file mapping names to trade exec arguments, e.g.
+// {"buy_btc": ["-symbol", "BTCUSDT", "-side", "BUY", "-total-amount", "100", "-total-run-time", "30m"]}.
+// Plans without -state-file get a state file of their own, so runs of different plans never share one.
+func loadPlans(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plans: %v", err)
+	}
+	var plans map[string][]string
+	if err := json.Unmarshal(data, &plans); err != nil {
+		return nil, fmt.Errorf("error parsing plans: %v", err)
+	}
+	if len(plans) == 0 {
+		return nil, fmt.Errorf("no plans in %s", path)
+	}
+	for name, args := range plans {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("plan %s has no arguments", name)
+		}
+		if hasFlag(args, "resume") {
+			return nil, fmt.Errorf("plan %s uses -resume. Alerts always start a new run", name)
+		}
+		if !hasFlag(args, "state-file") {
+			plans[name] = append(slices.Clip(args), "-state-file", fmt.Sprintf("binance_buyer_state_%s.json", name))
+		}
+	}
+	return plans, nil
+}
+
+// hasFlag reports whether args set the named flag
+func hasFlag(args []string, name string) bool {
+	return slices.ContainsFunc(args, func(arg string) bool {
+		arg = strings.TrimLeft(arg, "-")
+		return arg == name || strings.HasPrefix(arg, name+"=")
+	})
+}
+
+// handleAlert authenticates an alert and queues its plan, answering at once since TradingView gives up on
+// slow webhooks
+func (l *alertListener) handleAlert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var alert alertRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxAlertBytes)).Decode(&alert); err != nil {
+		http.Error(w, "alert message must be JSON with secret and plan", http.StatusBadRequest)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(alert.Secret), []byte(l.secret)) != 1 {
+		log.Printf("Rejected alert from %s with a wrong secret", r.RemoteAddr)
+		http.Error(w, "invalid secret", http.StatusUnauthorized)
+		return
+	}
+	if alert.Plan == "" && len(l.plans) == 1 {
+		for name := range l.plans {
+			alert.Plan = name
+		}
+	}
+	if _, ok := l.plans[alert.Plan]; !ok {
+		log.Printf("Rejected alert for unknown plan %q", alert.Plan)
+		http.Error(w, "unknown plan", http.StatusNotFound)
+		return
+	}
+
+	select {
+	case l.queue <- alert.Plan:
+		log.Printf("Alert accepted for plan %s (%d queued)", alert.Plan, len(l.queue))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"status": "queued", "plan": alert.Plan, "queued": len(l.queue)})
+	default:
+		log.Printf("Rejected alert for plan %s: too many runs queued", alert.Plan)
+		http.Error(w, "too many runs queued", http.StatusServiceUnavailable)
+	}
+}
+
+// runPlans executes queued plans one after another until ctx is cancelled. Each plan runs as a trade exec
+// child process, so a failing plan cannot take the listener down. The child shares the listener's process
+// group, so Ctrl-C interrupts it too and it saves its state before the listener exits.
+func (l *alertListener) runPlans(ctx context.Context, executable string) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case name := <-l.queue:
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Starting plan %s", name)
+			started := time.Now()
+			cmd := exec.Command(executable, append([]string{"trade", "exec"}, l.plans[name]...)...)
+			cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+			if err := cmd.Run(); err != nil {
+				log.Printf("Plan %s failed after %s: %v", name, time.Since(started).Round(time.Second), err)
+				continue
+			}
+			log.Printf("Plan %s finished after %s", name, time.Since(started).Round(time.Second))
+		}
+	}
+}
+
+// runListen serves the alert webhook and runs the plans its alerts trigger until interrupted
+func runListen(args []string) error {
+	fs := flag.NewFlagSet("listen", flag.ExitOnError)
+	addr := fs.String("addr", ":443", "Address to serve the alert webhook on. TradingView only sends alerts to ports 80 and 443")
+	plansPath := fs.String("plans", "", "JSON file mapping plan names to trade exec arguments (e.g., {\"buy_btc\": [\"-symbol\", \"BTCUSDT\", \"-side\", \"BUY\", \"-total-amount\", \"100\"]})")
+	secret := fs.String("secret", os.Getenv("ALERT_SECRET"), "Shared secret alerts must carry in their \"secret\" field (default from ALERT_SECRET)")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file to serve HTTPS (empty serves plain HTTP, e.g., behind a TLS-terminating proxy)")
+	tlsKey := fs.String("tls-key", "", "TLS private key file of -tls-cert")
+	maxQueued := fs.Int("max-queued", 1, "Alerts queued while a plan is running before further alerts are rejected")
+	logFormat := fs.String("log-format", "text", "Log output format: text or json")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if err := setupLogging(*logFormat); err != nil {
+		return err
+	}
+	if *secret == "" {
+		return fmt.Errorf("a shared secret is required: set -secret or ALERT_SECRET")
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		return fmt.Errorf("-tls-cert and -tls-key must be set together")
+	}
+	if *maxQueued < 0 {
+		return fmt.Errorf("max queued must not be negative")
+	}
+	plans, err := loadPlans(*plansPath)
+	if err != nil {
+		return err
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error locating executable: %v", err)
+	}
+
+	listener := &alertListener{plans: plans, secret: *secret, queue: make(chan string, *maxQueued)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/webhook", listener.handleAlert)
+	server := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	serveErr := make(chan error, 1)
+	go func() {
+		var err error
+		if *tlsCert != "" {
+			err = server.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErr <- fmt.Errorf("error serving alerts: %v", err)
+			stop()
+		}
+	}()
+	log.Printf("Listening for alerts on %s/webhook with plans %s", *addr, strings.Join(slices.Sorted(maps.Keys(plans)), ", "))
+
+	listener.runPlans(ctx, executable)
+	select {
+	case err := <-serveErr:
+		return err
+	default:
+	}
+	log.Printf("Shutting down alert listener")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func parseDuration(durationStr string) (time.Duration, error) {
+	// Regular expression to match the duration pattern
+	re := regexp.MustCompile(`^(\d+)([smhHdDwWM])$`)
+	matches := re.FindStringSubmatch(durationStr)
+
+	if len(matches) != 3 {
+		return 0, fmt.Errorf("invalid duration format. Use format like '30s', '30m', '2H', '1D', '1W', or '1M'")
+	}
+
+	value, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid number in duration: %v", err)
+	}
+
+	unit := matches[2]
+	var duration time.Duration
+
+	switch unit {
+	case "s": // Second
+		duration = time.Duration(value) * time.Second
+	case "m": // Minute
+		duration = time.Duration(value) * time.Minute
+	case "H": // Hour
+		duration = time.Duration(value) * time.Hour
+	case "D": // Day
+		duration = time.Duration(value) * 24 * time.Hour
+	case "W": // Week
+		duration = time.Duration(value) * 7 * 24 * time.Hour
+	case "M": // Month (approximated to 30 days)
+		duration = time.Duration(value) * 30 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("invalid time unit. Use s, m, H, D, W, or M")
+	}
+
+	return duration, nil
+}
+
+// newExchangeClient creates the client for a market of the named exchange, or for its testnet when testnet is set
+func newExchangeClient(exchange, market string, creds Credentials, testnet bool) (ExchangeClient, error) {
+	if market != MarketSpot && strings.ToLower(exchange) != "binance" {
+		return nil, fmt.Errorf("%s trading is not available for %s", market, exchange)
+	}
+	switch strings.ToLower(exchange) {
+	case "binance":
+		signer, err := NewSigner(creds.KeyType, creds.SecretKey)
+		if err != nil {
+			return nil, err
+		}
+		if market == MarketMargin || market == MarketIsolatedMargin {
+			if testnet {
+				return nil, fmt.Errorf("margin trading is not available on the binance testnet")
+			}
+			return NewBinanceMarginClient(creds.APIKey, signer, market == MarketIsolatedMargin), nil
+		}
+		if market == MarketFutures && testnet {
+			return NewBinanceFuturesTestnetClient(creds.APIKey, signer), nil
+		}
+		if market == MarketFutures {
+			return NewBinanceFuturesClient(creds.APIKey, signer), nil
+		}
+		if testnet {
+			return NewBinanceTestnetClient(creds.APIKey, signer), nil
+		}
+		return NewBinanceClient(creds.APIKey, signer), nil
+	case "kraken":
+		if testnet {
+			return nil, fmt.Errorf("testnet is not available for kraken")
+		}
+		if creds.KeyType != "" && creds.KeyType != KeyTypeHMAC {
+			return nil, fmt.Errorf("%s keys are not supported on kraken", creds.KeyType)
+		}
+		return NewKrakenClient(creds.APIKey, creds.SecretKey), nil
+	default:
+		return nil, fmt.Errorf("unsupported exchange: %s. Use binance or kraken", exchange)
+	}
+}
+
+// syncServerTime aligns signed request timestamps with the exchange clock when the client requires it
+func syncServerTime(client ExchangeClient) {
+	syncer, ok := client.(TimeSyncer)
+	if !ok {
+		return
+	}
+	offset, err := syncer.SyncTime()
+	if err != nil {
+		log.Printf("Error synchronizing server time, using local clock: %v", err)
+		return
+	}
+	log.Printf("Server time offset: %s", offset)
+}
+
+// attachStreams wraps the client with the enabled WebSocket streams and order transport and returns a function
+// that stops them
+func attachStreams(client ExchangeClient, symbol string, userStream bool, marketData, orderTransport string) (ExchangeClient, func()) {
+	stopUserData, stopMarketData := func() {}, func() {}
+	client, stopOrders := withWSOrders(client, orderTransport)
+	client = withCommissions(client)
+	if userStream {
+		client, stopUserData = withUserData(client)
+	}
+	if marketData == "ws" {
+		client, stopMarketData = withMarketData(client, symbol)
+	}
+	return client, func() {
+		stopMarketData()
+		stopUserData()
+		stopOrders()
+	}
+}
+
+// connectRun creates the client a run trades through with the enabled streams attached, spreading its orders
+// over the given accounts when there are any, and returns a function that stops the streams
+func connectRun(common *commonFlags, exchange, market string, testnet bool, symbol string, accounts []AccountWeight, userStream bool, marketData, orderTransport string) (ExchangeClient, func(), error) {
+	if len(accounts) == 0 {
+		client, err := common.clientFor(exchange, market, testnet)
+		if err != nil {
+			return nil, nil, err
+		}
+		bindIsolatedSymbol(client, symbol)
+		client, stop := attachStreams(client, symbol, userStream, marketData, orderTransport)
+		return client, stop, nil
+	}
+
+	var clients []ExchangeClient
+	var stops []func()
+	stopAll := func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+	for i, account := range accounts {
+		client, err := common.accountClientFor(exchange, market, testnet, account.Name)
+		if err != nil {
+			stopAll()
+			return nil, nil, fmt.Errorf("account %s: %v", account.Name, err)
+		}
+		bindIsolatedSymbol(client, symbol)
+		// Market data is the same for every account, so only the first one streams it
+		accountMarketData := marketData
+		if i > 0 {
+			accountMarketData = "rest"
+		}
+		client, stop := attachStreams(client, symbol, userStream, accountMarketData, orderTransport)
+		clients = append(clients, client)
+		stops = append(stops, stop)
+	}
+	log.Printf("Trading through %d account(s)", len(accounts))
+	return newMultiAccountClient(accounts, clients), stopAll, nil
+}
+
+// runPrices returns the klines of the run's symbol over the run, in the shortest interval keeping them to one
+// page, or none when the client is nil or the request fails
+func runPrices(client MarketReader, state *RunState) []Candle {
+	if client == nil {
+		return nil
+	}
+	window := state.UpdatedAt.Sub(state.StartedAt)
+	interval := "1d"
+	for _, candidate := range []string{"1m", "5m", "15m", "1h", "4h"} {
+		if window/klineDurations[candidate] <= binanceKlineLimit {
+			interval = candidate
+			break
+		}
+	}
+	candles, err := client.GetKlines(state.Config.Symbol, interval, state.StartedAt, state.UpdatedAt)
+	if err != nil {
+		log.Printf("Error getting market prices for the report: %v", err)
+		return nil
+	}
+	return candles
+}
+
+// reportRun logs the execution report of a run and writes it to path when set: as a standalone HTML page with
+// charts of the run over the market prices from client, which may be nil, when it ends in .html and as JSON
+// otherwise
+func reportRun(state *RunState, path string, client MarketReader) {
+	report := newRunReport(state)
+	report.Log()
+	status := "completed"
+	if state.Killed != "" {
+		status = "halted by the kill switch: " + state.Killed
+	} else if !state.Completed {
+		status = fmt.Sprintf("ended at slice %d/%d", state.NextSlice, state.TotalSlices)
+	}
+	state.emailer.Send(report, state.RunID, status)
+	if path == "" {
+		return
+	}
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".html") {
+		err = report.WriteHTML(path, runPrices(client, state), state.StartedAt.Add(time.Duration(state.TotalSlices)*state.Interval))
+	} else {
+		err = report.WriteJSON(path)
+	}
+	if err != nil {
+		log.Printf("Error saving execution report: %v", err)
+	}
+}
+
+// executeRun runs the remaining slices of a run, reports on them and then manages the exit of the position or
+// withdraws it once the run has completed
+func executeRun(ctx context.Context, client ExchangeClient, state *RunState, statePath, reportPath string) {
+	client = withMarginTopUp(withSpendGuard(client, state), state)
+	if state.Config.ConvertFees {
+		state.fees = newFeeConverter(client, state.Config.QuoteAsset)
+	}
+	if state.Config.Fiat.Enabled() {
+		state.fx = newFXConverter(state.Config.Fiat, state.Config.QuoteAsset, client)
+	}
+	runTWAP(ctx, client, state, statePath)
+	reportRun(state, reportPath, client)
+	if ctx.Err() == nil && state.Completed && state.Config.Exit.Enabled() {
+		manageExit(ctx, client, state, statePath)
+	}
+	if ctx.Err() == nil && state.Completed && state.Config.Withdraw.Enabled() && state.WithdrawalID == "" {
+		withdrawRun(client, state, statePath)
+	}
+}
+
+// runExec plans and executes a new run, or resumes the run persisted in the state file
+func runExec(args []string) error {
+	opts, err := parseExecFlags(args)
+	if err != nil {
+		return err
+	}
+	common := opts.common
+
+	// Stop scheduling new slices on SIGINT/SIGTERM and let the run save its state. A second signal
+	// terminates immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	defer context.AfterFunc(ctx, func() {
+		log.Printf("Shutdown requested. Finishing the current order and saving state. Press Ctrl-C again to force quit.")
+		stop()
+	})()
+
+	var control *RunControl
+	if opts.controlAddr != "" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		control = NewRunControl(cancel, opts.controlToken)
+		shutdown, err := control.Serve(opts.controlAddr)
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+	}
+
+	var journal *Journal
+	if opts.journalPath != "" {
+		var err error
+		journal, err = OpenJournal(opts.journalPath)
+		if err != nil {
+			return err
+		}
+		defer journal.Close()
+	}
+	var auditLog *AuditLog
+	if opts.auditPath != "" {
+		var err error
+		auditLog, err = OpenAuditLog(opts.auditPath)
+		if err != nil {
+			return err
+		}
+		defer auditLog.Close()
+	}
+	notifier := NewNotifier()
+	if opts.slackWebhook != "" {
+		if err := notifier.Add(NewSlackBackend(opts.slackWebhook), opts.slackVerbosity); err != nil {
+			return err
+		}
+	}
+	if opts.discordWebhook != "" {
+		if err := notifier.Add(NewDiscordBackend(opts.discordWebhook), opts.discordVerbosity); err != nil {
+			return err
+		}
+	}
+	if opts.webhookURL != "" {
+		if err := notifier.Add(NewEventWebhookBackend(opts.webhookURL, opts.webhookSecret), opts.webhookVerbosity); err != nil {
+			return err
+		}
+	}
+	notifier = notifier.Start()
+	defer notifier.Close()
+	var emailer *EmailReporter
+	if len(opts.email.To) > 0 {
+		var err error
+		emailer, err = NewEmailReporter(opts.email, opts.emailSchedule)
+		if err != nil {
+			return err
+		}
+		defer emailer.Close()
+	}
+
+	// Continue a previous run from its persisted state
+	if opts.resume {
+		state, err := loadRunState(opts.stateFile)
+		if err != nil {
+			return fmt.Errorf("error loading run state: %v", err)
+		}
+		state.journal = journal
+		state.auditLog = auditLog
+		state.notifier = notifier
+		state.emailer = emailer
+		state.control = control
+		if state.RunID == "" {
+			state.RunID = newRunID()
+		}
+		tagLogs(state.RunID)
+		if state.Completed && (state.ExitCompleted || !state.Config.Exit.Enabled()) &&
+			(state.WithdrawalID != "" || !state.Config.Withdraw.Enabled()) {
+			log.Printf("Run persisted in %s already completed. Nothing to resume.", opts.stateFile)
+			return nil
+		}
+		client, stopStreams, err := connectRun(common, state.Config.Exchange, cmp.Or(state.Config.Market, MarketSpot), state.Config.Testnet, state.Config.Symbol, state.Config.Accounts, opts.userStream, opts.marketData, opts.orderTransport)
+		if err != nil {
+			return err
+		}
+		defer stopStreams()
+		if opts.checkPermissions {
+			if err := checkKeyPermissions(client, state.Config); err != nil {
+				return err
+			}
+		}
+		log.Printf("Resuming %s of %s on %s at slice %d/%d with %s %s remaining",
+			strings.ToLower(state.Config.Side), state.Config.Symbol, state.Config.Exchange,
+			state.NextSlice+1, state.TotalSlices, state.Remaining, state.Config.budgetAsset())
+		state.audit(AuditResumed, "next_slice", state.NextSlice+1, "total_slices", state.TotalSlices, "remaining_budget", state.Remaining)
+		if !state.Completed {
+			state.catchUp(opts.catchUp)
+			reconcileRun(client, state, opts.orphanAction)
+			state.save(opts.stateFile)
+		}
+		executeRun(ctx, client, state, opts.stateFile, opts.reportPath)
+		return nil
+	}
+
+	// Create exchange client
+	cfg := opts.run
+	client, stopStreams, err := connectRun(common, common.exchange, common.market, common.testnet, cfg.Symbol, cfg.Accounts, opts.userStream, opts.marketData, opts.orderTransport)
+	if err != nil {
+		return err
+	}
+	defer stopStreams()
+	if common.testnet {
+		log.Printf("Trading on the %s testnet", strings.ToLower(common.exchange))
+	}
+	var futures *BinanceFuturesClient
+	if common.market == MarketFutures {
+		if futures, err = futuresClient(client); err != nil {
+			return err
+		}
+		if err := configureFutures(futures, cfg.Symbol, cfg.Futures); err != nil {
+			return err
+		}
+	}
+	if _, ok := baseClient(client).(OCOPlacer); cfg.Exit.OCO && !ok {
+		return fmt.Errorf("OCO exits are not supported for this market")
+	}
+	if cfg.Withdraw.Enabled() {
+		if _, err := walletOf(client, common.exchange); err != nil {
+			return err
+		}
+	}
+
+	// Fetch the symbol's trading rules so every order can be rounded and validated
+	cfg.Filters, err = client.GetSymbolFilters(cfg.Symbol)
+	if err != nil {
+		return fmt.Errorf("error getting symbol filters for %s: %v", cfg.Symbol, err)
+	}
+	log.Printf("Symbol filters for %s: tickSize=%s stepSize=%s minQty=%s minNotional=%s",
+		cfg.Symbol, cfg.Filters.TickSize, cfg.Filters.StepSize, cfg.Filters.MinQty, cfg.Filters.MinNotional)
+
+	// Check the key before arming a -start-when trigger, which may wait for hours before the run would otherwise
+	// find out that its orders are refused
+	if opts.checkPermissions {
+		if err := checkKeyPermissions(client, cfg); err != nil {
+			return err
+		}
+	}
+
+	if opts.startWhen && !waitForTrigger(ctx, client, cfg.Symbol, opts.trigger, opts.triggerExpiry) {
+		return nil
+	}
+
+	// Fetch current price once for SELL calculations and logging
+	currentPrice, err := client.GetPrice(cfg.Symbol)
+	if err != nil {
+		return fmt.Errorf("error getting current price for %s: %v", cfg.Symbol, err)
+	}
+
+	// Determine available quote amount based on side
+	// Margin orders that borrow can spend more than the free balance
+	balanceOf := client.GetBalance
+	if margin, ok := baseClient(client).(*BinanceMarginClient); ok && borrowsFunds(cfg.SideEffect) {
+		balanceOf = margin.availableWithBorrowing
+	}
+	var availableQuote, baseBalance Decimal
+	if futures != nil {
+		availableQuote, baseBalance, err = futuresAvailable(futures, cfg.Symbol, cfg.Side, cfg.QuoteAsset, cfg.Futures.ReduceOnly, currentPrice, cfg.Futures.AutoTransferMax)
+		if err != nil {
+			return err
+		}
+	} else if cfg.Side == "BUY" {
+		availableQuote, err = balanceOf(cfg.QuoteAsset)
+		if err != nil {
+			return fmt.Errorf("error getting %s balance: %v", cfg.QuoteAsset, err)
+		}
+	} else {
+		baseAsset := strings.TrimSuffix(cfg.Symbol, cfg.QuoteAsset)
+		baseBalance, err = balanceOf(baseAsset)
+		if err != nil {
+			return fmt.Errorf("error getting %s balance: %v", baseAsset, err)
+		}
+		availableQuote = baseBalance.Mul(NewDecimalFromFloat(currentPrice))
+	}
+
+	// Determine the total quote amount to use
+	cfg.Amount = availableQuote
+	if opts.totalAmount > 0 {
+		cfg.Amount = NewDecimalFromFloat(opts.totalAmount)
+		if cfg.Amount.GreaterThan(availableQuote) {
+			return fmt.Errorf("specified total amount (%s) is greater than available %s amount (%s)", cfg.Amount, cfg.QuoteAsset, availableQuote)
+		}
+	}
+	if cfg.BaseAmount {
+		if opts.quantity.GreaterThan(baseBalance) {
+			return fmt.Errorf("specified quantity (%s) is greater than available %s balance (%s)", opts.quantity, strings.TrimSuffix(cfg.Symbol, cfg.QuoteAsset), baseBalance)
+		}
+		cfg.Amount = opts.quantity
+	}
+
+	log.Printf("Initial available %s (quote) amount: %s", cfg.QuoteAsset, availableQuote.StringFixed(2))
+	log.Printf("Starting automated %s for %s at price %.8f", strings.ToLower(cfg.Side), cfg.Symbol, currentPrice)
+
+	if multi, ok := client.(*multiAccountClient); ok {
+		if err := multi.checkAccountBalances(cfg, currentPrice); err != nil {
+			return err
+		}
+	}
+
+	var state *RunState
+	if cfg.Algo == AlgoVWAP {
+		state, err = planVWAP(client, cfg, currentPrice, opts.vwapLookbackDays)
+		if err != nil {
+			return fmt.Errorf("error planning VWAP execution: %v", err)
+		}
+	} else {
+		state = planTWAP(cfg, currentPrice)
+	}
+	state.journal = journal
+	state.auditLog = auditLog
+	state.notifier = notifier
+	state.emailer = emailer
+	state.control = control
+	tagLogs(state.RunID)
+	state.audit(AuditPlanned, "symbol", cfg.Symbol, "side", cfg.Side, "algo", cfg.Algo, "amount", cfg.Amount,
+		"budget_asset", cfg.budgetAsset(), "duration", cfg.Duration.String(), "total_slices", state.TotalSlices,
+		"slice_amount", state.SliceAmount, "interval", state.Interval.String())
+	if previous, err := loadRunState(opts.stateFile); err == nil {
+		reconcilePrevious(client, state, previous, opts.orphanAction)
+	}
+	state.save(opts.stateFile)
+	executeRun(ctx, client, state, opts.stateFile, opts.reportPath)
+	return nil
+}
+
+func main() {
+	root := &command{
+		name: "binance_buyer",
+		subcommands: []*command{
+			{
+				name:    "trade",
+				summary: "Execute runs and inspect the trading account",
+				subcommands: []*command{
+					{name: "exec", summary: "Execute a TWAP/VWAP run or resume a persisted one", run: runExec},
+					{name: "balance", summary: "Show free asset balances", run: runBalance},
+					{name: "price", summary: "Show the last price and best bid/ask of a symbol", run: runPrice},
+					{name: "position", summary: "Show the open futures position of a symbol", run: runPosition},
+					{name: "history", summary: "List recent trades of a symbol", run: runHistory},
+					{name: "pnl", summary: "Report realized and unrealized profit or loss from the position ledger", run: runPnL},
+					{name: "cancel-all", summary: "Cancel every open order of a symbol", run: runCancelAll},
+					{
+						name:    "orders",
+						summary: "List, inspect and cancel orders",
+						subcommands: []*command{
+							{name: "list", summary: "List the open orders of a symbol", run: runOrdersList},
+							{name: "status", summary: "Show the state of an order", run: runOrderStatus},
+							{name: "cancel", summary: "Cancel an order", run: runOrderCancel},
+						},
+					},
+					{name: "arb", summary: "Scan triangles of symbols for arbitrage and optionally trade them", run: runArb},
+					{name: "spread-monitor", summary: "Compare a pair's price across exchanges and alert on wide spreads", run: runSpreadMonitor},
+					{name: "ladder", summary: "Buy with a ladder of limit orders below the price, optionally following it", run: runLadder},
+					{name: "market-make", summary: "Quote both sides of a symbol's book with inventory limits", run: runMarketMake},
+					{name: "strategy", summary: "Trade or backtest a candle strategy from a config file", run: runStrategy},
+					{name: "simulate", summary: "Simulate TWAP or VWAP schedules on price paths resampled from history", run: runSimulate},
+					{
+						name:    "data",
+						summary: "Download and store historical candles",
+						subcommands: []*command{
+							{name: "download", summary: "Download a symbol's klines into the local candle database", run: runDataDownload},
+							{name: "gaps", summary: "List and fill the ranges missing from stored klines", run: runDataGaps},
+						},
+					},
+					{
+						name:    "wallet",
+						summary: "Look up deposit addresses, withdraw crypto and transfer between wallets",
+						subcommands: []*command{
+							{name: "deposit-address", summary: "Show the address an asset is deposited to", run: runDepositAddress},
+							{name: "withdraw", summary: "Withdraw an asset to an address (requires -enable-withdrawals)", run: runWithdraw},
+							{name: "withdrawals", summary: "List recent withdrawals and their status", run: runWithdrawals},
+							{name: "transfer", summary: "Move funds between the spot, futures, margin and funding wallets", run: runTransfer},
+						},
+					},
+					{
+						name:    "subaccounts",
+						summary: "List sub-accounts, read their balances and fund them from the master account",
+						subcommands: []*command{
+							{name: "list", summary: "List the sub-accounts of the master account", run: runSubAccountsList},
+							{name: "assets", summary: "Show the balances of a sub-account", run: runSubAccountsAssets},
+							{name: "transfer", summary: "Move funds between the master account and sub-accounts", run: runSubAccountsTransfer},
+							{name: "fund", summary: "Split a budget over sub-accounts by weight and transfer each its share", run: runSubAccountsFund},
+						},
+					},
+					{
+						name:    "credentials",
+						summary: "Manage the key pairs of an encrypted credentials file",
+						subcommands: []*command{
+							{name: "add", summary: "Encrypt a key pair into the credentials file", run: runCredentialsAdd},
+							{name: "list", summary: "List the stored key pairs with masked API keys", run: runCredentialsList},
+							{name: "remove", summary: "Delete a key pair from the credentials file", run: runCredentialsRemove},
+						},
+					},
+					{name: "permissions", summary: "Show the permissions of the API key and those runs need", run: runKeyPermissions},
+					{
+						name:    "daemon",
+						summary: "Run queued and scheduled execution jobs concurrently in a long-running daemon",
+						subcommands: []*command{
+							{name: "serve", summary: "Run the daemon and serve its job API", run: runDaemonServe},
+							{name: "submit", summary: "Submit a job of trade exec arguments to the daemon", run: runDaemonSubmit},
+							{name: "list", summary: "List the daemon's jobs and their status", run: runDaemonList},
+							{name: "cancel", summary: "Cancel a queued, scheduled or running job", run: runDaemonCancel},
+							{name: "logs", summary: "Print the output of a job's runs", run: runDaemonLogs},
+						},
+					},
+					{name: "presets", summary: "List the exec presets of a presets file", run: runPresets},
+					{name: "metrics", summary: "Compute performance metrics from a trade journal or account snapshots", run: runMetrics},
+					{name: "tax-export", summary: "Export a trade journal as Koinly, CoinTracking or Form 8949 CSV with FIFO, LIFO or HIFO lots", run: runTaxExport},
+					{name: "snapshot", summary: "Record account balances marked to market on a schedule for equity curves", run: runSnapshot},
+					{name: "panic", summary: "Cancel all open orders of symbols and optionally liquidate their positions", run: runPanic},
+					{name: "report", summary: "Print the execution report of a persisted run", run: runReport},
+					{name: "dca", summary: "Buy a fixed amount on a cron schedule as a long-lived process", run: runDCACommand},
+					{name: "rebalance", summary: "Trade a portfolio back to its target allocations", run: runRebalance},
+					{name: "listen", summary: "Run pre-configured plans on TradingView alert webhooks", run: runListen},
+				},
+			},
+			{name: "mock-server", summary: "Serve a scriptable mock Binance spot exchange for integration testing", run: runMockServer},
+		},
+	}
+
+	// Invocations from before subcommands existed pass the run flags directly
+	args := os.Args[1:]
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") && args[0] != "-h" && args[0] != "-help" {
+		args = append([]string{"trade", "exec"}, args...)
+	}
+	if err := root.execute(root.name, args); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Side effects of a margin order, which can borrow what the order lacks and repay debt with what it brings in
+const (
+	MarginSideEffectNone           = "NO_SIDE_EFFECT"
+	MarginSideEffectBorrow         = "MARGIN_BUY"
+	MarginSideEffectRepay          = "AUTO_REPAY"
+	MarginSideEffectBorrowAndRepay = "AUTO_BORROW_REPAY"
+)
+
+// parseSideEffect converts a side effect flag value such as auto-borrow-repay into its MarginSideEffect value
+func parseSideEffect(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return "", nil
+	case "none":
+		return MarginSideEffectNone, nil
+	case "margin-buy":
+		return MarginSideEffectBorrow, nil
+	case "auto-repay":
+		return MarginSideEffectRepay, nil
+	case "auto-borrow-repay":
+		return MarginSideEffectBorrowAndRepay, nil
+	}
+	return "", fmt.Errorf("invalid side effect: %s. Use none, margin-buy, auto-repay or auto-borrow-repay", value)
+}
+
+// borrowsFunds reports whether orders with a side effect borrow the funds the account lacks
+func borrowsFunds(sideEffect string) bool {
+	return sideEffect == MarginSideEffectBorrow || sideEffect == MarginSideEffectBorrowAndRepay
+}
+
+// BinanceMarginClient trades on a Binance cross margin account, or on the isolated margin account of one
+// symbol. Margin orders trade the spot order books, so market data and symbol rules come from the spot API.
+type BinanceMarginClient struct {
+	rest     *BinanceClient
+	isolated bool
+	// symbol is the pair whose isolated account balances are read from
+	symbol string
+}
+
+// NewBinanceMarginClient creates a client for the cross margin account, or for isolated margin accounts
+// when isolated is set
+func NewBinanceMarginClient(apiKey string, signer Signer, isolated bool) *BinanceMarginClient {
+	rest := NewBinanceClient(apiKey, signer)
+	rest.paths.order = "/sapi/v1/margin/order"
+	rest.paths.openOrders = "/sapi/v1/margin/openOrders"
+	rest.wsAPIURL = ""
+	return &BinanceMarginClient{rest: rest, isolated: isolated}
+}
+
+// bindIsolatedSymbol selects the isolated margin account of symbol for the balances of a client. Other
+// clients are left unchanged.
+func bindIsolatedSymbol(client ExchangeClient, symbol string) {
+	if margin, ok := baseClient(client).(*BinanceMarginClient); ok && margin.isolated {
+		margin.symbol = symbol
+	}
+}
+
+// accountParams returns the parameters that select the margin account an order of symbol is on
+func (c *BinanceMarginClient) accountParams(symbol string) url.Values {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	if c.isolated {
+		params.Set("isIsolated", "TRUE")
+	}
+	return params
+}
+
+// SetEndpoints replaces the REST hosts and the WebSocket base URL
+func (c *BinanceMarginClient) SetEndpoints(restURLs []string, wsURL string) error {
+	return c.rest.SetEndpoints(restURLs, wsURL)
+}
+
+// SyncTime measures the offset between the Binance server clock and the local clock
+func (c *BinanceMarginClient) SyncTime() (time.Duration, error) {
+	return c.rest.SyncTime()
+}
+
+// GetPrice gets the current price of a symbol
+func (c *BinanceMarginClient) GetPrice(symbol string) (float64, error) {
+	return c.rest.GetPrice(symbol)
+}
+
+// GetBookTicker gets the best bid and ask of a symbol
+func (c *BinanceMarginClient) GetBookTicker(symbol string) (*BookTicker, error) {
+	return c.rest.GetBookTicker(symbol)
+}
+
+// GetSymbolFilters fetches the trading rules of a symbol
+func (c *BinanceMarginClient) GetSymbolFilters(symbol string) (*SymbolFilters, error) {
+	return c.rest.GetSymbolFilters(symbol)
+}
+
+// GetKlines fetches the candles of a symbol for the given interval opened between start and end
+func (c *BinanceMarginClient) GetKlines(symbol, interval string, start, end time.Time) ([]Candle, error) {
+	return c.rest.GetKlines(symbol, interval, start, end)
+}
+
+// GetOrderBook fetches the best levels of a symbol's order book
+func (c *BinanceMarginClient) GetOrderBook(symbol string, levels int) (*OrderBook, error) {
+	return c.rest.GetOrderBook(symbol, levels)
+}
+
+// GetBalance gets the free balance of an asset on the margin account, not counting what can be borrowed
+func (c *BinanceMarginClient) GetBalance(asset string) (Decimal, error) {
+	if c.isolated {
+		return c.isolatedBalance(asset)
+	}
+
+	body, err := c.rest.sendSigned("GET", "/sapi/v1/margin/account", url.Values{})
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	var account struct {
+		UserAssets []Balance `json:"userAssets"`
+	}
+	if err := json.Unmarshal(body, &account); err != nil {
+		return Decimal{}, fmt.Errorf("error parsing response: %v", err)
+	}
+	return freeBalance(account.UserAssets, asset)
+}
+
+// isolatedBalance gets the free balance of an asset on the isolated margin account of the bound symbol
+func (c *BinanceMarginClient) isolatedBalance(asset string) (Decimal, error) {
+	if c.symbol == "" {
+		return Decimal{}, fmt.Errorf("isolated margin balances need a symbol")
+	}
+	params := url.Values{}
+	params.Set("symbols", c.symbol)
+
+	body, err := c.rest.sendSigned("GET", "/sapi/v1/margin/isolated/account", params)
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	var account struct {
+		Assets []struct {
+			Symbol     string  `json:"symbol"`
+			BaseAsset  Balance `json:"baseAsset"`
+			QuoteAsset Balance `json:"quoteAsset"`
+		} `json:"assets"`
+	}
+	if err := json.Unmarshal(body, &account); err != nil {
+		return Decimal{}, fmt.Errorf("error parsing response: %v", err)
+	}
+	for _, pair := range account.Assets {
+		if pair.Symbol == c.symbol {
+			return freeBalance([]Balance{pair.BaseAsset, pair.QuoteAsset}, asset)
+		}
+	}
+	return Decimal{}, fmt.Errorf("no isolated margin account for %s", c.symbol)
+}
+
+// freeBalance returns the free amount of an asset in a balance list
+func freeBalance(balances []Balance, asset string) (Decimal, error) {
+	for _, balance := range balances {
+		if balance.Asset == asset {
+			free, err := ParseDecimal(balance.Free)
+			if err != nil {
+				return Decimal{}, fmt.Errorf("error parsing %s balance: %v", asset, err)
+			}
+			return free, nil
+		}
+	}
+	return Decimal{}, fmt.Errorf("%s margin balance not found", asset)
+}
+
+// MaxBorrowable gets how much more of an asset the margin account can borrow
+func (c *BinanceMarginClient) MaxBorrowable(asset string) (Decimal, error) {
+	params := url.Values{}
+	params.Set("asset", asset)
+	if c.isolated {
+		if c.symbol == "" {
+			return Decimal{}, fmt.Errorf("isolated margin balances need a symbol")
+		}
+		params.Set("isolatedSymbol", c.symbol)
+	}
+
+	body, err := c.rest.sendSigned("GET", "/sapi/v1/margin/maxBorrowable", params)
+	if err != nil {
+		return Decimal{}, err
+	}
+
+	var borrowable struct {
+		Amount string `json:"amount"`
+	}
+	if err := json.Unmarshal(body, &borrowable); err != nil {
+		return Decimal{}, fmt.Errorf("error parsing response: %v", err)
+	}
+	return ParseDecimal(borrowable.Amount)
+}
+
+// availableWithBorrowing returns the free balance of an asset plus what can still be borrowed
+func (c *BinanceMarginClient) availableWithBorrowing(asset string) (Decimal, error) {
+	free, err := c.GetBalance(asset)
+	if err != nil {
+		return Decimal{}, err
+	}
+	borrowable, err := c.MaxBorrowable(asset)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error getting borrowable %s: %v", asset, err)
+	}
+	return free.Add(borrowable), nil
+}
+
+// PlaceOrder places a margin order with the request's side effect. Orders with a client order ID are
+// placed idempotently.
+func (c *BinanceMarginClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	if isStopLimit(req.Type) {
+		if err := req.validateStopLimit(); err != nil {
+			return nil, err
+		}
+	}
+	params := spotOrderParams(req)
+	if c.isolated {
+		params.Set("isIsolated", "TRUE")
+	}
+	if req.SideEffect != "" {
+		params.Set("sideEffectType", req.SideEffect)
+	}
+	return c.rest.submitOrder(req.Symbol, req.ClientOrderID, params)
+}
+
+// GetOrder queries the status of a margin order
+func (c *BinanceMarginClient) GetOrder(symbol, orderID string) (*Order, error) {
+	params := c.accountParams(symbol)
+	params.Set("orderId", orderID)
+
+	return c.rest.sendOrderRequest("GET", params)
+}
+
+// CancelOrder cancels an open margin order
+func (c *BinanceMarginClient) CancelOrder(symbol, orderID string) error {
+	params := c.accountParams(symbol)
+	params.Set("orderId", orderID)
+
+	_, err := c.rest.sendOrderRequest("DELETE", params)
+	return err
+}
+
+// GetOpenOrders lists the open margin orders of a symbol
+func (c *BinanceMarginClient) GetOpenOrders(symbol string) ([]*Order, error) {
+	return c.rest.listOpenOrders(c.accountParams(symbol))
+}
+
+// GetTrades lists the account's margin trades of a symbol executed between since and until, where a zero
+// until means up to now
+func (c *BinanceMarginClient) GetTrades(symbol string, since, until time.Time) ([]Trade, error) {
+	return c.rest.paginateTrades("/sapi/v1/margin/myTrades", c.accountParams(symbol), since, until)
+}
+
+// GetOrderTrades lists the account's margin trades of an order
+func (c *BinanceMarginClient) GetOrderTrades(symbol, orderID string) ([]Trade, error) {
+	params := c.accountParams(symbol)
+	params.Set("orderId", orderID)
+	return c.rest.listTrades("/sapi/v1/margin/myTrades", params)
+}
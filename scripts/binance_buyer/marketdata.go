@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// marketDataStaleAfter is how old streamed data may be before callers fall back to REST
+	marketDataStaleAfter = 5 * time.Second
+	// marketDataReadTimeout is how long the stream may stay silent before it is considered dead
+	marketDataReadTimeout = time.Minute
+	// marketDataMaxConnAge reconnects ahead of Binance's 24 hour connection limit
+	marketDataMaxConnAge = 23 * time.Hour
+)
+
+// MarketDataFeed maintains the live best bid/ask and last trade price of a symbol from a WebSocket stream,
+// reconnecting automatically when the stream drops or goes silent
+type MarketDataFeed struct {
+	symbol    string
+	streamURL string
+
+	mu        sync.RWMutex
+	ticker    BookTicker
+	tickerAt  time.Time
+	lastPrice float64
+	priceAt   time.Time
+	// benchmark accumulates the streamed trades, time-weighting each price until the trade at benchmarkAt
+	benchmark   MarketBenchmark
+	benchmarkAt time.Time
+
+	// trades is signalled after each trade, coalescing trades that arrive before the signal is received
+	trades chan struct{}
+	// books is signalled after each best bid/ask update, coalesced the same way
+	books chan struct{}
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBinanceMarketDataFeed creates a feed subscribed to the bookTicker and trade streams of a symbol
+func NewBinanceMarketDataFeed(wsBaseURL, symbol string) *MarketDataFeed {
+	stream := strings.ToLower(symbol)
+	return &MarketDataFeed{
+		symbol:    symbol,
+		streamURL: fmt.Sprintf("%s/stream?streams=%s@bookTicker/%s@trade", wsBaseURL, stream, stream),
+		trades:    make(chan struct{}, 1),
+		books:     make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start connects to the stream in the background
+func (f *MarketDataFeed) Start() {
+	go f.run()
+}
+
+// Stop closes the stream and waits for the background goroutine to exit
+func (f *MarketDataFeed) Stop() {
+	close(f.stop)
+	<-f.done
+}
+
+// BookTicker returns the latest streamed best bid/ask, and whether it is fresh
+func (f *MarketDataFeed) BookTicker() (*BookTicker, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	ticker := f.ticker
+	return &ticker, !f.tickerAt.IsZero() && time.Since(f.tickerAt) < marketDataStaleAfter
+}
+
+// LastPrice returns the latest streamed trade price, and whether it is fresh
+func (f *MarketDataFeed) LastPrice() (float64, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.lastPrice, !f.priceAt.IsZero() && time.Since(f.priceAt) < marketDataStaleAfter
+}
+
+// Benchmark returns the average price accumulators of the trades streamed since the feed started, with the
+// last price weighted up to now
+func (f *MarketDataFeed) Benchmark() MarketBenchmark {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	benchmark := f.benchmark
+	if !f.benchmarkAt.IsZero() {
+		seconds := time.Since(f.benchmarkAt).Seconds()
+		benchmark.PriceTime += f.lastPrice * seconds
+		benchmark.Seconds += seconds
+	}
+	return benchmark
+}
+
+// Trades returns a channel that is signalled when a new trade price is available
+func (f *MarketDataFeed) Trades() <-chan struct{} {
+	return f.trades
+}
+
+// Books returns a channel that is signalled when a new best bid/ask is available
+func (f *MarketDataFeed) Books() <-chan struct{} {
+	return f.books
+}
+
+// run keeps the stream connected until the feed is stopped
+func (f *MarketDataFeed) run() {
+	defer close(f.done)
+	keepConnected("Market data stream for "+f.symbol, f.stop, f.consume)
+}
+
+// consume reads messages from a single connection until it fails, reporting whether it connected at all
+func (f *MarketDataFeed) consume() (bool, error) {
+	conn, err := dialWebSocket(f.streamURL, 10*time.Second)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	defer closeOnStop(conn, f.stop)()
+
+	log.Printf("Market data stream connected for %s", f.symbol)
+	conn.SetReadTimeout(marketDataReadTimeout)
+	connectedAt := time.Now()
+	for time.Since(connectedAt) < marketDataMaxConnAge {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		f.handle(message)
+	}
+	return true, fmt.Errorf("connection reached its maximum age")
+}
+
+// handle applies a combined stream message to the feed's state
+func (f *MarketDataFeed) handle(message []byte) {
+	var envelope struct {
+		Stream string          `json:"stream"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(message, &envelope); err != nil {
+		log.Printf("Error parsing market data message: %v", err)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(envelope.Stream, "@bookTicker"):
+		var update struct {
+			Bid string `json:"b"`
+			Ask string `json:"a"`
+		}
+		if err := json.Unmarshal(envelope.Data, &update); err != nil {
+			return
+		}
+		bid, bidErr := strconv.ParseFloat(update.Bid, 64)
+		ask, askErr := strconv.ParseFloat(update.Ask, 64)
+		if bidErr != nil || askErr != nil {
+			return
+		}
+		f.mu.Lock()
+		f.ticker = BookTicker{Symbol: f.symbol, BidPrice: bid, AskPrice: ask}
+		f.tickerAt = time.Now()
+		f.mu.Unlock()
+		select {
+		case f.books <- struct{}{}:
+		default:
+		}
+	case strings.HasSuffix(envelope.Stream, "@trade"):
+		var trade struct {
+			Price string `json:"p"`
+			Qty   string `json:"q"`
+		}
+		if err := json.Unmarshal(envelope.Data, &trade); err != nil {
+			return
+		}
+		price, err := strconv.ParseFloat(trade.Price, 64)
+		if err != nil {
+			return
+		}
+		qty, _ := strconv.ParseFloat(trade.Qty, 64)
+		now := time.Now()
+		f.mu.Lock()
+		if !f.benchmarkAt.IsZero() {
+			seconds := now.Sub(f.benchmarkAt).Seconds()
+			f.benchmark.PriceTime += f.lastPrice * seconds
+			f.benchmark.Seconds += seconds
+		}
+		f.benchmark.Trades++
+		f.benchmark.Volume += qty
+		f.benchmark.Notional += price * qty
+		f.benchmarkAt = now
+		f.lastPrice = price
+		f.priceAt = now
+		f.mu.Unlock()
+		select {
+		case f.trades <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// streamingClient serves prices for the streamed symbol from a market data feed and falls back to the
+// wrapped client's REST endpoints when the feed is stale or for other symbols
+type streamingClient struct {
+	ExchangeClient
+	feed *MarketDataFeed
+}
+
+// GetPrice returns the streamed last trade price when fresh
+func (c *streamingClient) GetPrice(symbol string) (float64, error) {
+	if symbol == c.feed.symbol {
+		if price, ok := c.feed.LastPrice(); ok {
+			return price, nil
+		}
+	}
+	return c.ExchangeClient.GetPrice(symbol)
+}
+
+// GetBookTicker returns the streamed best bid/ask when fresh
+func (c *streamingClient) GetBookTicker(symbol string) (*BookTicker, error) {
+	if symbol == c.feed.symbol {
+		if ticker, ok := c.feed.BookTicker(); ok {
+			return ticker, nil
+		}
+	}
+	return c.ExchangeClient.GetBookTicker(symbol)
+}
+
+// Unwrap returns the wrapped client
+func (c *streamingClient) Unwrap() ExchangeClient {
+	return c.ExchangeClient
+}
+
+// marketFeed returns the feed streaming symbol's market data to the client, or nil when the client does not
+// stream it
+func marketFeed(client ExchangeClient, symbol string) *MarketDataFeed {
+	for {
+		if streaming, ok := client.(*streamingClient); ok && streaming.feed.symbol == symbol {
+			return streaming.feed
+		}
+		wrapper, ok := client.(interface{ Unwrap() ExchangeClient })
+		if !ok {
+			return nil
+		}
+		client = wrapper.Unwrap()
+	}
+}
+
+// tradeUpdates returns the channel signalled on each streamed trade of symbol, or nil when the client does
+// not stream its price
+func tradeUpdates(client ExchangeClient, symbol string) <-chan struct{} {
+	if feed := marketFeed(client, symbol); feed != nil {
+		return feed.Trades()
+	}
+	return nil
+}
+
+// waitForTrade waits for the next streamed trade of symbol, or for poll when its price is not streamed or
+// the stream is quiet, and reports whether ctx is still live
+func waitForTrade(ctx context.Context, client ExchangeClient, symbol string, poll time.Duration) bool {
+	timer := time.NewTimer(poll)
+	defer timer.Stop()
+	select {
+	case <-tradeUpdates(client, symbol):
+		return true
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// waitForBook waits for the next streamed best bid/ask of symbol, or for poll when its book is not streamed or
+// quiet, and reports whether ctx is still live
+func waitForBook(ctx context.Context, client ExchangeClient, symbol string, poll time.Duration) bool {
+	var books <-chan struct{}
+	if feed := marketFeed(client, symbol); feed != nil {
+		books = feed.Books()
+	}
+	timer := time.NewTimer(poll)
+	defer timer.Stop()
+	select {
+	case <-books:
+		return true
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// spotStreamURL returns the WebSocket base URL of the Binance spot streams a client's symbols trade on.
+// Margin accounts trade the spot order books.
+func spotStreamURL(client ExchangeClient) (string, bool) {
+	switch c := client.(type) {
+	case *BinanceClient:
+		return c.wsBaseURL, true
+	case *BinanceMarginClient:
+		return c.rest.wsBaseURL, true
+	}
+	return "", false
+}
+
+// withMarketData wraps the client so price checks for symbol are served from a WebSocket feed.
+// It returns the client unchanged when the exchange has no streaming support, along with a
+// function that stops the feed.
+func withMarketData(client ExchangeClient, symbol string) (ExchangeClient, func()) {
+	wsBaseURL, ok := spotStreamURL(baseClient(client))
+	if !ok {
+		log.Printf("WebSocket market data is not supported for this exchange. Using REST.")
+		return client, func() {}
+	}
+
+	feed := NewBinanceMarketDataFeed(wsBaseURL, symbol)
+	feed.Start()
+	return &streamingClient{ExchangeClient: client, feed: feed}, feed.Stop
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"math"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// MarketMakerConfig holds the settings of a market-making run
+type MarketMakerConfig struct {
+	Symbol string `json:"symbol"`
+	// QuoteSize is the base quantity of each bid and ask
+	QuoteSize Decimal `json:"quote_size"`
+	// SpreadBps is the distance between the bid and ask, centred on the mid-price
+	SpreadBps float64 `json:"spread_bps"`
+	// SkewBps is how far both quotes shift away from the mid at the inventory limit: down while long, so the
+	// ask fills first, and up while short
+	SkewBps float64 `json:"skew_bps"`
+	// MaxInventory is the largest base position the maker builds in either direction from its starting holding
+	MaxInventory Decimal `json:"max_inventory"`
+	// RefreshBps is how far a quote's target price must move before the quote is replaced
+	RefreshBps float64    `json:"refresh_bps"`
+	Kill       KillSwitch `json:"kill,omitzero"`
+}
+
+// makerQuote is a resting bid or ask of a market maker
+type makerQuote struct {
+	Order *Order
+	Price Decimal
+	// Executed and ExecutedQuote are the part of the order already counted in the maker's inventory
+	Executed      Decimal
+	ExecutedQuote Decimal
+}
+
+// MarketMaker quotes both sides of a symbol's book with post-only orders, skewing the quotes to work its
+// inventory back towards zero and withdrawing the side that would take it past the limit
+type MarketMaker struct {
+	cfg     MarketMakerConfig
+	client  ExchangeClient
+	filters *SymbolFilters
+	runID   string
+	quotes  map[string]*makerQuote
+	placed  int
+	// Inventory is the base bought minus the base sold, and Cash the quote received minus the quote spent
+	Inventory Decimal
+	Cash      Decimal
+	Fills     int
+	// consecutiveErrors counts the order placements that failed in a row, for the kill switch
+	consecutiveErrors int
+}
+
+// NewMarketMaker creates a maker for cfg's symbol
+func NewMarketMaker(client ExchangeClient, cfg MarketMakerConfig) (*MarketMaker, error) {
+	filters, err := client.GetSymbolFilters(cfg.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("error getting symbol filters: %v", err)
+	}
+	if quantity := filters.RoundQuantity(cfg.QuoteSize); quantity.LessThan(filters.MinQty) || quantity.IsZero() {
+		return nil, fmt.Errorf("quote size %s is below the minimum quantity %s for %s", cfg.QuoteSize, filters.MinQty, cfg.Symbol)
+	}
+	return &MarketMaker{cfg: cfg, client: client, filters: filters, runID: newRunID(), quotes: map[string]*makerQuote{}}, nil
+}
+
+// quotePrices returns the bid and ask around the book's mid-price, skewed by the inventory and kept one tick
+// inside the spread at most so both rest on the book
+func (m *MarketMaker) quotePrices(ticker *BookTicker) (Decimal, Decimal) {
+	ratio := 0.0
+	if !m.cfg.MaxInventory.IsZero() {
+		ratio = math.Max(-1, math.Min(1, m.Inventory.Float64()/m.cfg.MaxInventory.Float64()))
+	}
+	center := ticker.Mid() * (1 - ratio*m.cfg.SkewBps/10000)
+	half := m.cfg.SpreadBps / 2 / 10000
+	bid := m.filters.RoundPrice(NewDecimalFromFloat(center*(1-half)), "BUY")
+	ask := m.filters.RoundPrice(NewDecimalFromFloat(center*(1+half)), "SELL")
+	if inside := makerPrice(ticker, "BUY", m.filters.TickSize); bid.GreaterThan(inside) {
+		bid = inside
+	}
+	if inside := makerPrice(ticker, "SELL", m.filters.TickSize); ask.LessThan(inside) {
+		ask = inside
+	}
+	return bid, ask
+}
+
+// canQuote reports whether a fill of a full quote on side keeps the inventory within its limit
+func (m *MarketMaker) canQuote(side string) bool {
+	size := m.filters.RoundQuantity(m.cfg.QuoteSize)
+	if side == "BUY" {
+		return !m.Inventory.Add(size).GreaterThan(m.cfg.MaxInventory)
+	}
+	return !m.Inventory.Sub(size).LessThan(m.cfg.MaxInventory.Neg())
+}
+
+// account counts the part of a quote's order that executed since it was last seen in the inventory and cash
+func (m *MarketMaker) account(side string, quote *makerQuote, order *Order) {
+	executed, executedQuote := decimalOrZero(order.ExecutedQty), filledQuote(order)
+	qty, value := executed.Sub(quote.Executed), executedQuote.Sub(quote.ExecutedQuote)
+	quote.Order, quote.Executed, quote.ExecutedQuote = order, executed, executedQuote
+	if qty.Sign() <= 0 {
+		return
+	}
+	if side == "BUY" {
+		m.Inventory, m.Cash = m.Inventory.Add(qty), m.Cash.Sub(value)
+	} else {
+		m.Inventory, m.Cash = m.Inventory.Sub(qty), m.Cash.Add(value)
+	}
+	m.Fills++
+	slog.Info("Quote filled", "symbol", m.cfg.Symbol, "side", side, "order_id", order.OrderID, "qty", qty, "quote_qty", value, "inventory", m.Inventory)
+}
+
+// sync refreshes the open quotes, counting their fills and dropping the ones that can no longer fill
+func (m *MarketMaker) sync() {
+	for side, quote := range m.quotes {
+		order, err := m.client.GetOrder(m.cfg.Symbol, quote.Order.OrderID)
+		if err != nil {
+			slog.Error("Error querying quote", "symbol", m.cfg.Symbol, "order_id", quote.Order.OrderID, "error", err)
+			continue
+		}
+		m.account(side, quote, order)
+		if isTerminalStatus(order.Status) {
+			delete(m.quotes, side)
+		}
+	}
+}
+
+// withdraw cancels the quote on side, counting what it executed before the cancel, and reports whether it is
+// gone
+func (m *MarketMaker) withdraw(side string) bool {
+	quote := m.quotes[side]
+	if err := m.client.CancelOrder(m.cfg.Symbol, quote.Order.OrderID); err != nil {
+		slog.Error("Error cancelling quote", "symbol", m.cfg.Symbol, "order_id", quote.Order.OrderID, "error", err)
+	}
+	order, err := m.client.GetOrder(m.cfg.Symbol, quote.Order.OrderID)
+	if err != nil {
+		slog.Error("Error querying quote", "symbol", m.cfg.Symbol, "order_id", quote.Order.OrderID, "error", err)
+		return false
+	}
+	m.account(side, quote, order)
+	if !isTerminalStatus(order.Status) {
+		return false
+	}
+	delete(m.quotes, side)
+	return true
+}
+
+// post places a post-only quote on side at price and returns the error that prevented it
+func (m *MarketMaker) post(side string, price Decimal) error {
+	quantity := m.filters.RoundQuantity(m.cfg.QuoteSize)
+	if err := m.filters.ValidateOrder(quantity, price); err != nil {
+		return err
+	}
+	m.placed++
+	order, err := m.client.PlaceOrder(OrderRequest{
+		Symbol:        m.cfg.Symbol,
+		Side:          side,
+		Type:          OrderTypeLimitMaker,
+		Quantity:      quantity,
+		Price:         price,
+		ClientOrderID: fmt.Sprintf("%s-mm%d", m.runID, m.placed),
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Quote posted", "symbol", m.cfg.Symbol, "side", side, "order_id", order.OrderID, "price", price, "qty", quantity)
+	quote := &makerQuote{Order: order, Price: price}
+	m.quotes[side] = quote
+	m.account(side, quote, order)
+	if isTerminalStatus(order.Status) {
+		delete(m.quotes, side)
+	}
+	return nil
+}
+
+// refresh replaces the quotes whose target price moved by RefreshBps or more, withdraws the side the inventory
+// limit forbids and posts the missing ones. It returns a reason once the kill switch's error limit is reached.
+func (m *MarketMaker) refresh(ticker *BookTicker) string {
+	bid, ask := m.quotePrices(ticker)
+	for _, side := range []string{"BUY", "SELL"} {
+		price := bid
+		if side == "SELL" {
+			price = ask
+		}
+		allowed := m.canQuote(side)
+		if quote := m.quotes[side]; quote != nil {
+			moved := math.Abs(price.Float64()/quote.Price.Float64()-1) * 10000
+			if allowed && moved < m.cfg.RefreshBps {
+				continue
+			}
+			if !m.withdraw(side) {
+				continue
+			}
+		}
+		if !m.canQuote(side) {
+			continue
+		}
+		err := m.post(side, price)
+		if err == nil {
+			m.consecutiveErrors = 0
+			continue
+		}
+		m.consecutiveErrors++
+		slog.Error("Error posting quote", "symbol", m.cfg.Symbol, "side", side, "price", price, "error", err)
+		if limit := m.cfg.Kill.MaxConsecutiveErrors; limit > 0 && m.consecutiveErrors >= limit {
+			return fmt.Sprintf("%d quotes failed in a row, last: %v", m.consecutiveErrors, err)
+		}
+	}
+	return ""
+}
+
+// Run quotes until ctx is cancelled or the kill switch trips, re-quoting on every book update but at most once
+// per minInterval and at least once per poll, then withdraws both quotes
+func (m *MarketMaker) Run(ctx context.Context, minInterval, poll time.Duration) error {
+	log.Printf("Making a market in %s: %s per side, %.2f bps spread, inventory limit %s, skew %.2f bps",
+		m.cfg.Symbol, m.cfg.QuoteSize, m.cfg.SpreadBps, m.cfg.MaxInventory, m.cfg.SkewBps)
+	var reason string
+	var sentinelCheckedAt time.Time
+	for reason == "" {
+		if time.Since(sentinelCheckedAt) >= poll {
+			sentinelCheckedAt = time.Now()
+			if reason = m.cfg.Kill.sentinelReason(); reason != "" {
+				break
+			}
+		}
+		m.sync()
+		ticker, err := m.client.GetBookTicker(m.cfg.Symbol)
+		if err != nil || ticker.BidPrice <= 0 || ticker.AskPrice <= 0 {
+			slog.Error("Error getting book ticker, quotes left unchanged", "symbol", m.cfg.Symbol, "error", err)
+		} else {
+			reason = m.refresh(ticker)
+		}
+		if reason != "" || !sleepContext(ctx, minInterval) || !waitForBook(ctx, m.client, m.cfg.Symbol, poll) {
+			break
+		}
+	}
+
+	for side := range m.quotes {
+		m.withdraw(side)
+	}
+	mark := m.Cash
+	if price, err := m.client.GetPrice(m.cfg.Symbol); err == nil {
+		mark = mark.Add(m.Inventory.Mul(NewDecimalFromFloat(price)))
+	}
+	log.Printf("Market making stopped after %d fill(s): inventory %s, cash %s, marked-to-market PnL %s", m.Fills, m.Inventory, m.Cash, mark)
+	if len(m.quotes) > 0 {
+		log.Printf("WARNING: %d quote(s) could not be cancelled. Cancel them with trade cancel-all.", len(m.quotes))
+	}
+	if reason != "" {
+		return fmt.Errorf("kill switch: %s", reason)
+	}
+	return nil
+}
+
+// runMarketMake quotes both sides of a symbol's book around the mid-price until interrupted
+func runMarketMake(args []string) error {
+	fs, common := newFlagSet("market-make")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair to quote")
+	quoteSize := fs.String("quote-size", "", "Base quantity of each bid and ask (e.g., 0.001)")
+	spreadBps := fs.Float64("spread-bps", 20, "Distance between the bid and ask in basis points, centred on the mid-price")
+	skewBps := fs.Float64("skew-bps", 10, "How far both quotes shift away from the mid at the inventory limit, in basis points, to work the inventory back")
+	maxInventory := fs.String("max-inventory", "", "Largest base position built in either direction from the starting holding; the side that would exceed it is withdrawn")
+	refreshBps := fs.Float64("refresh-bps", 5, "How far a quote's target price must move, in basis points, before the quote is replaced")
+	minInterval := fs.String("min-refresh-interval", "1s", "Shortest time between re-quotes, bounding the order rate on busy books")
+	poll := fs.String("poll", "5s", "Longest time between re-quotes and fill checks when the book is quiet or not streamed")
+	marketData := fs.String("market-data", "ws", "Book source: ws (WebSocket stream with REST fallback) or rest")
+	userStream := fs.Bool("user-stream", true, "Track fills from the exchange's user data stream")
+	orderTransport := fs.String("order-transport", OrderTransportREST, "Transport quotes are placed and cancelled over: rest, or ws (Binance spot WebSocket API with REST fallback)")
+	killMaxErrors := fs.Int("kill-max-errors", 5, "Stop and withdraw the quotes after this many quotes fail in a row (0 to disable)")
+	killFile := fs.String("kill-file", "", "Stop and withdraw the quotes once this file exists (e.g., /tmp/STOP_TRADING)")
+	killURL := fs.String("kill-url", "", "Stop and withdraw the quotes once this URL answers \"stop\" or {\"stop\": true}, checked every -poll")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+
+	size, err := ParseDecimal(*quoteSize)
+	if err != nil || size.Sign() <= 0 {
+		return fmt.Errorf("a positive -quote-size is required")
+	}
+	inventory, err := ParseDecimal(*maxInventory)
+	if err != nil || inventory.LessThan(size) {
+		return fmt.Errorf("-max-inventory of at least -quote-size is required")
+	}
+	if *spreadBps <= 0 || *skewBps < 0 || *refreshBps < 0 {
+		return fmt.Errorf("-spread-bps must be positive and -skew-bps and -refresh-bps not negative")
+	}
+	if *marketData != "ws" && *marketData != "rest" {
+		return fmt.Errorf("invalid market data source: %s. Use ws or rest", *marketData)
+	}
+	if *orderTransport != OrderTransportREST && *orderTransport != OrderTransportWS {
+		return fmt.Errorf("invalid order transport: %s. Use rest or ws", *orderTransport)
+	}
+	refreshEvery, err := parseDuration(*minInterval)
+	if err != nil {
+		return fmt.Errorf("error parsing min refresh interval: %v", err)
+	}
+	pollEvery, err := parseDuration(*poll)
+	if err != nil {
+		return fmt.Errorf("error parsing poll interval: %v", err)
+	}
+	cfg := MarketMakerConfig{
+		Symbol:       *symbol,
+		QuoteSize:    size,
+		SpreadBps:    *spreadBps,
+		SkewBps:      *skewBps,
+		MaxInventory: inventory,
+		RefreshBps:   *refreshBps,
+		Kill:         KillSwitch{MaxConsecutiveErrors: *killMaxErrors, SentinelFile: *killFile, SentinelURL: *killURL},
+	}
+
+	client, stopStreams, err := connectRun(common, common.exchange, common.market, common.testnet, cfg.Symbol, nil, *userStream, *marketData, *orderTransport)
+	if err != nil {
+		return err
+	}
+	defer stopStreams()
+	maker, err := NewMarketMaker(client, cfg)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return maker.Run(ctx, refreshEvery, pollEvery)
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// EquityPoint is the marked-to-market equity of an account at a point in time
+type EquityPoint struct {
+	Time       time.Time `json:"time"`
+	Equity     float64   `json:"equity"`
+	InPosition bool      `json:"in_position"`
+	// Price and Position are the price the equity is marked at and the base quantity held
+	Price    float64 `json:"price,omitempty"`
+	Position float64 `json:"position,omitempty"`
+}
+
+// PerformanceMetrics summarizes the performance of a backtest or of live trading. Sharpe and Sortino ratios
+// are annualized from daily returns over 365 days, with no risk-free rate.
+type PerformanceMetrics struct {
+	Source         string    `json:"source"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+	InitialEquity  float64   `json:"initial_equity"`
+	FinalEquity    float64   `json:"final_equity"`
+	ReturnPct      float64   `json:"return_pct"`
+	SharpeRatio    float64   `json:"sharpe_ratio"`
+	SortinoRatio   float64   `json:"sortino_ratio"`
+	MaxDrawdownPct float64   `json:"max_drawdown_pct"`
+	// ExposurePct is the share of the time a position was open
+	ExposurePct float64 `json:"exposure_pct"`
+	Trades      int     `json:"trades"`
+	WinRatePct  float64 `json:"win_rate_pct"`
+	AvgTradePnL float64 `json:"avg_trade_pnl"`
+	AvgWin      float64 `json:"avg_win"`
+	AvgLoss     float64 `json:"avg_loss"`
+	// ProfitFactor is the gross profit over the gross loss, 0 without losing trades
+	ProfitFactor float64 `json:"profit_factor"`
+	BestTrade    float64 `json:"best_trade"`
+	WorstTrade   float64 `json:"worst_trade"`
+	Fees         float64 `json:"fees"`
+}
+
+// computeMetrics computes the metrics of an equity curve and the PnLs of its closed trades
+func computeMetrics(source string, curve []EquityPoint, pnls []float64, fees float64) PerformanceMetrics {
+	m := PerformanceMetrics{Source: source, Trades: len(pnls), Fees: fees}
+	if len(curve) == 0 {
+		return m
+	}
+	first, last := curve[0], curve[len(curve)-1]
+	m.Start, m.End = first.Time, last.Time
+	m.InitialEquity, m.FinalEquity = first.Equity, last.Equity
+	if first.Equity > 0 {
+		m.ReturnPct = (last.Equity/first.Equity - 1) * 100
+	}
+
+	peak := first.Equity
+	var exposed time.Duration
+	for i, point := range curve {
+		peak = math.Max(peak, point.Equity)
+		if peak > 0 {
+			m.MaxDrawdownPct = math.Max(m.MaxDrawdownPct, (peak-point.Equity)/peak*100)
+		}
+		if i > 0 && curve[i-1].InPosition {
+			exposed += point.Time.Sub(curve[i-1].Time)
+		}
+	}
+	if span := last.Time.Sub(first.Time); span > 0 {
+		m.ExposurePct = float64(exposed) / float64(span) * 100
+	}
+
+	returns := dailyReturns(curve)
+	if len(returns) > 1 {
+		mean, stdDev := meanStdDev(returns)
+		var downside float64
+		for _, r := range returns {
+			downside += math.Pow(math.Min(r, 0), 2)
+		}
+		downside = math.Sqrt(downside / float64(len(returns)))
+		if stdDev > 0 {
+			m.SharpeRatio = mean / stdDev * math.Sqrt(365)
+		}
+		if downside > 0 {
+			m.SortinoRatio = mean / downside * math.Sqrt(365)
+		}
+	}
+
+	if len(pnls) > 0 {
+		var total, grossProfit, grossLoss float64
+		wins, losses := 0, 0
+		m.BestTrade, m.WorstTrade = pnls[0], pnls[0]
+		for _, pnl := range pnls {
+			total += pnl
+			m.BestTrade, m.WorstTrade = math.Max(m.BestTrade, pnl), math.Min(m.WorstTrade, pnl)
+			if pnl > 0 {
+				wins++
+				grossProfit += pnl
+			} else if pnl < 0 {
+				losses++
+				grossLoss -= pnl
+			}
+		}
+		m.WinRatePct = float64(wins) / float64(len(pnls)) * 100
+		m.AvgTradePnL = total / float64(len(pnls))
+		if wins > 0 {
+			m.AvgWin = grossProfit / float64(wins)
+		}
+		if losses > 0 {
+			m.AvgLoss = -grossLoss / float64(losses)
+			m.ProfitFactor = grossProfit / grossLoss
+		}
+	}
+	return m
+}
+
+// dailyReturns returns the returns between the equity at the end of consecutive UTC days, carrying the equity
+// over days without points
+func dailyReturns(curve []EquityPoint) []float64 {
+	var closes []float64
+	day := curve[0].Time.UTC().Truncate(24 * time.Hour)
+	equity := curve[0].Equity
+	for _, point := range curve {
+		for point.Time.UTC().Truncate(24 * time.Hour).After(day) {
+			closes = append(closes, equity)
+			day = day.Add(24 * time.Hour)
+		}
+		equity = point.Equity
+	}
+	closes = append(closes, equity)
+	var returns []float64
+	for i := 1; i < len(closes); i++ {
+		if closes[i-1] > 0 {
+			returns = append(returns, closes[i]/closes[i-1]-1)
+		}
+	}
+	return returns
+}
+
+// rows returns the metrics as labelled, formatted values, in the order they are reported
+func (m PerformanceMetrics) rows() [][2]string {
+	return [][2]string{
+		{"Period", fmt.Sprintf("%s to %s", m.Start.UTC().Format(time.DateTime), m.End.UTC().Format(time.DateTime))},
+		{"Equity", fmt.Sprintf("%.2f -> %.2f", m.InitialEquity, m.FinalEquity)},
+		{"Return", fmt.Sprintf("%+.2f%%", m.ReturnPct)},
+		{"Sharpe ratio", fmt.Sprintf("%.2f", m.SharpeRatio)},
+		{"Sortino ratio", fmt.Sprintf("%.2f", m.SortinoRatio)},
+		{"Max drawdown", fmt.Sprintf("%.2f%%", m.MaxDrawdownPct)},
+		{"Exposure", fmt.Sprintf("%.1f%%", m.ExposurePct)},
+		{"Trades", strconv.Itoa(m.Trades)},
+		{"Win rate", fmt.Sprintf("%.1f%%", m.WinRatePct)},
+		{"Average trade", fmt.Sprintf("%+.2f", m.AvgTradePnL)},
+		{"Average win", fmt.Sprintf("%+.2f", m.AvgWin)},
+		{"Average loss", fmt.Sprintf("%+.2f", m.AvgLoss)},
+		{"Profit factor", fmt.Sprintf("%.2f", m.ProfitFactor)},
+		{"Best trade", fmt.Sprintf("%+.2f", m.BestTrade)},
+		{"Worst trade", fmt.Sprintf("%+.2f", m.WorstTrade)},
+		{"Fees", fmt.Sprintf("%.2f", m.Fees)},
+	}
+}
+
+// Print writes the metrics to w as a table
+func (m PerformanceMetrics) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range m.rows() {
+		fmt.Fprintf(tw, "%s\t%s\n", row[0], row[1])
+	}
+	return tw.Flush()
+}
+
+// metricsTemplate is the HTML metrics report
+var metricsTemplate = template.Must(template.New("metrics").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Metrics.Source}}</title></head>
+<body style="font-family: sans-serif; font-size: 14px;">
+<h2>{{.Metrics.Source}}</h2>
+<table cellpadding="4" border="1" style="border-collapse: collapse;">
+{{range .Rows}}<tr><td>{{index . 0}}</td><td>{{index . 1}}</td></tr>
+{{end}}</table>
+<p style="color: #666;">Sharpe and Sortino ratios are annualized from daily returns over 365 days, with no risk-free rate.</p>
+{{.Chart}}
+</body></html>
+`))
+
+// equityPanels returns the charts of an equity curve: the price with the trades marked on it, the equity, the
+// position and the share of the equity deployed in it
+func equityPanels(curve []EquityPoint, markers []chartMarker) []chartPanel {
+	price := chartSeries{Name: "price", Color: "#1f77b4"}
+	equity := chartSeries{Name: "equity", Color: "#2ca02c"}
+	position := chartSeries{Name: "base held", Color: "#9467bd", Step: true}
+	deployed := chartSeries{Name: "% of equity in position", Color: "#ff7f0e", Step: true}
+	for _, p := range curve {
+		if p.Price > 0 {
+			price.Points = append(price.Points, chartPoint{p.Time, p.Price})
+		}
+		equity.Points = append(equity.Points, chartPoint{p.Time, p.Equity})
+		position.Points = append(position.Points, chartPoint{p.Time, p.Position})
+		if p.Equity > 0 {
+			deployed.Points = append(deployed.Points, chartPoint{p.Time, p.Position * p.Price / p.Equity * 100})
+		}
+	}
+	if len(price.Points) == 0 {
+		// Curves of whole accounts, such as snapshots, have no single price or position to chart
+		return []chartPanel{{Title: "Equity", Series: []chartSeries{equity}}}
+	}
+	return []chartPanel{
+		{Title: "Price", Series: []chartSeries{price}, Markers: markers},
+		{Title: "Equity", Series: []chartSeries{equity}},
+		{Title: "Position", Series: []chartSeries{position}},
+		{Title: "Capital deployed", Series: []chartSeries{deployed}},
+	}
+}
+
+// positionMarkers marks the points of a curve where the position grew as buys and where it shrank as sells
+func positionMarkers(curve []EquityPoint) []chartMarker {
+	var markers []chartMarker
+	for i := 1; i < len(curve); i++ {
+		change := curve[i].Position - curve[i-1].Position
+		if change == 0 {
+			continue
+		}
+		marker := chartMarker{Time: curve[i].Time, Value: curve[i].Price, Color: "#2ca02c"}
+		side := "BUY"
+		if change < 0 {
+			marker.Color, side = "#d62728", "SELL"
+		}
+		marker.Label = fmt.Sprintf("%s %s %.8g at %.8g", curve[i].Time.UTC().Format(time.DateTime), side, math.Abs(change), curve[i].Price)
+		markers = append(markers, marker)
+	}
+	return markers
+}
+
+// writeMetrics writes the metrics to each comma-separated path, as JSON with the equity curve, CSV or an HTML
+// report charting the curve with markers on its price, by the file's extension
+func writeMetrics(paths string, m PerformanceMetrics, curve []EquityPoint, markers []chartMarker) error {
+	for _, path := range strings.Split(paths, ",") {
+		if path = strings.TrimSpace(path); path == "" {
+			continue
+		}
+		var err error
+		switch ext := strings.ToLower(filepath.Ext(path)); ext {
+		case ".json":
+			err = writeJSONFile(path, struct {
+				PerformanceMetrics
+				Equity []EquityPoint `json:"equity"`
+			}{m, curve})
+		case ".csv":
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			w.Write([]string{"metric", "value"})
+			for _, row := range m.rows() {
+				w.Write(row[:])
+			}
+			w.Flush()
+			if err = w.Error(); err == nil {
+				err = os.WriteFile(path, buf.Bytes(), 0o644)
+			}
+		case ".html":
+			var buf bytes.Buffer
+			if err = metricsTemplate.Execute(&buf, map[string]any{"Metrics": m, "Rows": m.rows(),
+				"Chart": renderChart(equityPanels(curve, markers))}); err == nil {
+				err = os.WriteFile(path, buf.Bytes(), 0o644)
+			}
+		default:
+			return fmt.Errorf("unsupported metrics format %q for %s. Use .json, .csv or .html", ext, path)
+		}
+		if err != nil {
+			return fmt.Errorf("error writing metrics to %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// journalMetrics replays the journaled fills of symbol from initialQuote and returns the equity curve, marked at
+// each fill's price, and the PnL of each round trip from flat back to flat. Fees paid in the quote or base
+// asset are counted; fees in other assets are not and are reported by count. Sells of more than the journal
+// bought are capped at the position. An initialQuote of 0 starts from the largest cost the position reached.
+func journalMetrics(path, symbol, quoteAsset string, initialQuote float64) (PerformanceMetrics, []EquityPoint, int, error) {
+	var m PerformanceMetrics
+	file, err := os.Open(path)
+	if err != nil {
+		return m, nil, 0, fmt.Errorf("error reading journal: %v", err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return m, nil, 0, fmt.Errorf("error reading journal: %v", err)
+	}
+
+	type fill struct {
+		Time                   time.Time
+		Buy                    bool
+		Qty, Quote, Price, Fee float64
+		FeeInQuote, FeeInOther bool
+	}
+	base := strings.TrimSuffix(symbol, quoteAsset)
+	var fills []fill
+	for i, row := range rows {
+		if i == 0 || len(row) < 10 || row[1] != symbol {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, row[0])
+		if err != nil {
+			return m, nil, 0, fmt.Errorf("journal line %d: invalid timestamp %q", i+1, row[0])
+		}
+		f := fill{Time: t, Buy: row[2] == "BUY"}
+		f.Qty, _ = strconv.ParseFloat(row[5], 64)
+		f.Quote, _ = strconv.ParseFloat(row[6], 64)
+		f.Price, _ = strconv.ParseFloat(row[7], 64)
+		f.Fee, _ = strconv.ParseFloat(row[8], 64)
+		f.FeeInQuote = row[9] == quoteAsset
+		f.FeeInOther = f.Fee > 0 && row[9] != quoteAsset && row[9] != base
+		if f.Qty > 0 && f.Price > 0 {
+			fills = append(fills, f)
+		}
+	}
+	if len(fills) == 0 {
+		return m, nil, 0, fmt.Errorf("no %s fills in %s", symbol, path)
+	}
+
+	// replay walks the fills, calling point after each one with the cash and the position
+	replay := func(cash float64, point func(f fill, cash, qty, cost float64)) {
+		qty, cost := 0.0, 0.0
+		for _, f := range fills {
+			feeQuote, feeBase := 0.0, 0.0
+			switch {
+			case f.FeeInQuote:
+				feeQuote = f.Fee
+			case !f.FeeInOther:
+				feeBase = f.Fee
+			}
+			if f.Buy {
+				cash -= f.Quote + feeQuote
+				cost += f.Quote + feeQuote
+				qty += f.Qty - feeBase
+			} else {
+				sold := math.Min(f.Qty, qty)
+				if sold <= 0 {
+					continue
+				}
+				share := sold / f.Qty
+				cash += (f.Quote - feeQuote) * share
+				cost *= 1 - sold/qty
+				qty -= sold
+				if qty*f.Price < cost*0.01 || qty*f.Price < 1e-8 {
+					// Lot rounding dust does not keep a position open
+					qty, cost = 0, 0
+				}
+			}
+			point(f, cash, qty, cost)
+		}
+	}
+
+	if initialQuote <= 0 {
+		replay(0, func(f fill, cash, qty, cost float64) { initialQuote = math.Max(initialQuote, cost) })
+	}
+	curve := []EquityPoint{{Time: fills[0].Time, Equity: initialQuote}}
+	var pnls []float64
+	var fees float64
+	otherFees := 0
+	tradeStart := initialQuote
+	replay(initialQuote, func(f fill, cash, qty, cost float64) {
+		previous := curve[len(curve)-1]
+		if !previous.InPosition && qty > 0 {
+			tradeStart = previous.Equity
+		}
+		equity := cash + qty*f.Price
+		if previous.InPosition && qty == 0 {
+			pnls = append(pnls, equity-tradeStart)
+		}
+		curve = append(curve, EquityPoint{Time: f.Time, Equity: equity, InPosition: qty > 0, Price: f.Price, Position: qty})
+		switch {
+		case f.FeeInOther:
+			otherFees++
+		case f.FeeInQuote:
+			fees += f.Fee
+		default:
+			fees += f.Fee * f.Price
+		}
+	})
+	return computeMetrics(fmt.Sprintf("Journal %s %s", filepath.Base(path), symbol), curve, pnls, fees), curve, otherFees, nil
+}
+
+// runMetrics computes performance metrics from a trade journal or account snapshots and writes them as JSON,
+// CSV or HTML
+func runMetrics(args []string) error {
+	fs, common := newFlagSet("metrics")
+	journalPath := fs.String("journal", "", "Trade journal (CSV) written with -journal")
+	snapshotPath := fs.String("snapshots", "", "Snapshot store written by trade snapshot, to report the whole account instead of a journal")
+	symbol := fs.String("symbol", "BTCUSDT", "Symbol of the journal to report")
+	quoteAsset := fs.String("quote", "USDT", "Quote asset of the symbol, which metrics are reported in")
+	initialQuote := fs.Float64("initial-quote", 0, "Starting equity the returns are measured against (0 for the largest cost the position reached)")
+	out := fs.String("out", "", "Comma-separated files to write the metrics to, as .json (with the equity curve), .csv or .html")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if (*journalPath == "") == (*snapshotPath == "") {
+		return fmt.Errorf("one of -journal or -snapshots is required")
+	}
+	if *initialQuote < 0 {
+		return fmt.Errorf("-initial-quote must not be negative")
+	}
+
+	if *snapshotPath != "" {
+		m, curve, err := snapshotMetrics(*snapshotPath)
+		if err != nil {
+			return err
+		}
+		if err := m.Print(os.Stdout); err != nil {
+			return err
+		}
+		return writeMetrics(*out, m, curve, nil)
+	}
+
+	m, curve, otherFees, err := journalMetrics(*journalPath, strings.ToUpper(*symbol), strings.ToUpper(*quoteAsset), *initialQuote)
+	if err != nil {
+		return err
+	}
+	if err := m.Print(os.Stdout); err != nil {
+		return err
+	}
+	if otherFees > 0 {
+		fmt.Printf("\n%d fee(s) paid in other assets are not included\n", otherFees)
+	}
+	return writeMetrics(*out, m, curve, positionMarkers(curve))
+}
@@ -0,0 +1,833 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MockScript scripts how the mock exchange misbehaves: slow responses, failed requests and orders that
+// fill only in part
+type MockScript struct {
+	// Latency delays every response, in nanoseconds in JSON
+	Latency time.Duration `json:"latency"`
+	// FailRate is the probability of a request failing with an unknown error, drawn from the seeded source
+	FailRate float64 `json:"fail_rate"`
+	// FillRatio is the share of an order's remaining quantity filled at each match, where 1 fills it at once
+	FillRatio float64 `json:"fill_ratio"`
+	// FeeBps is the commission charged on every trade, in the asset received or in FeeAsset when it is set
+	// and has a mock symbol pricing it
+	FeeBps   float64 `json:"fee_bps"`
+	FeeAsset string  `json:"fee_asset"`
+	// Faults fail the next requests of an endpoint
+	Faults []MockFault `json:"faults"`
+}
+
+// MockFault fails the next Count requests to a path, and method when set, with a Binance error
+type MockFault struct {
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path"`
+	Count  int    `json:"count"`
+	Status int    `json:"status"`
+	Code   int    `json:"code"`
+	Msg    string `json:"msg"`
+	// Drop handles the request and then closes the connection without a response instead of returning the
+	// error, like a request that timed out after taking effect
+	Drop bool `json:"drop,omitempty"`
+}
+
+// mockOrder is an order on the mock exchange
+type mockOrder struct {
+	Symbol        string
+	OrderID       int64
+	ClientOrderID string
+	Side          string
+	Type          string
+	TimeInForce   string
+	Price         Decimal
+	StopPrice     Decimal
+	Triggered     bool
+	OrigQty       Decimal
+	ExecutedQty   Decimal
+	CumQuoteQty   Decimal
+	Status        string
+	Time          time.Time
+	UpdateTime    time.Time
+}
+
+// mockTrade is an execution of a mock order
+type mockTrade struct {
+	ID              int64
+	OrderID         int64
+	Symbol          string
+	Price           Decimal
+	Qty             Decimal
+	Commission      Decimal
+	CommissionAsset string
+	Time            time.Time
+	IsBuyer         bool
+}
+
+// mockSymbol is a pair traded on the mock exchange
+type mockSymbol struct {
+	base, quote string
+	price       Decimal
+}
+
+// Trading rules of every mock symbol
+var (
+	mockTickSize    = decimalOrZero("0.01")
+	mockStepSize    = decimalOrZero("0.00001")
+	mockMinNotional = decimalOrZero("5")
+)
+
+// MockBinance is an in-memory Binance spot exchange serving the endpoints the client uses for account,
+// ticker, order and exchangeInfo requests over an httptest server. Signatures are not checked. Prices only
+// change when set, so a run against it is deterministic for a given script and seed.
+type MockBinance struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	script  MockScript
+	rand    *rand.Rand
+	symbols map[string]*mockSymbol
+	free    map[string]Decimal
+	locked  map[string]Decimal
+	orders  []*mockOrder
+	trades  []mockTrade
+	nextID  int64
+
+	withdrawals []Withdrawal
+}
+
+// NewMockBinance creates an unstarted mock exchange trading the given symbols in quoteAsset, with the
+// random failures of its script drawn from seed
+func NewMockBinance(quoteAsset string, prices map[string]Decimal, balances map[string]Decimal, seed uint64) *MockBinance {
+	m := &MockBinance{
+		script:  MockScript{FillRatio: 1},
+		rand:    rand.New(rand.NewPCG(seed, seed)),
+		symbols: map[string]*mockSymbol{},
+		free:    map[string]Decimal{},
+		locked:  map[string]Decimal{},
+		nextID:  1,
+	}
+	for symbol, price := range prices {
+		m.symbols[symbol] = &mockSymbol{base: strings.TrimSuffix(symbol, quoteAsset), quote: quoteAsset, price: price}
+		m.free[strings.TrimSuffix(symbol, quoteAsset)] = Decimal{}
+	}
+	m.free[quoteAsset] = Decimal{}
+	for asset, balance := range balances {
+		m.free[asset] = balance
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/time", m.handleTime)
+	mux.HandleFunc("GET /api/v3/ticker/price", m.handleTickerPrice)
+	mux.HandleFunc("GET /api/v3/ticker/bookTicker", m.handleBookTicker)
+	mux.HandleFunc("GET /api/v3/exchangeInfo", m.handleExchangeInfo)
+	mux.HandleFunc("GET /api/v3/account", m.handleAccount)
+	mux.HandleFunc("POST /api/v3/order", m.handleNewOrder)
+	mux.HandleFunc("GET /api/v3/order", m.handleGetOrder)
+	mux.HandleFunc("DELETE /api/v3/order", m.handleCancelOrder)
+	mux.HandleFunc("GET /api/v3/openOrders", m.handleOpenOrders)
+	mux.HandleFunc("GET /api/v3/myTrades", m.handleMyTrades)
+	mux.HandleFunc("POST /sapi/v1/capital/withdraw/apply", m.handleWithdraw)
+	mux.HandleFunc("GET /sapi/v1/capital/withdraw/history", m.handleWithdrawHistory)
+	mux.HandleFunc("PUT /mock/script", m.handleScript)
+	mux.HandleFunc("PUT /mock/price", m.handleSetPrice)
+	m.server = httptest.NewUnstartedServer(m.middleware(mux))
+	return m
+}
+
+// Start serves the mock exchange on listener, or on a random local port when listener is nil
+func (m *MockBinance) Start(listener net.Listener) {
+	if listener != nil {
+		m.server.Listener.Close()
+		m.server.Listener = listener
+	}
+	m.server.Start()
+}
+
+// URL returns the base URL to pass as -rest-url
+func (m *MockBinance) URL() string {
+	return m.server.URL
+}
+
+// Close stops the server
+func (m *MockBinance) Close() {
+	m.server.Close()
+}
+
+// SetScript replaces the mock's script
+func (m *MockBinance) SetScript(script MockScript) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if script.FillRatio <= 0 || script.FillRatio > 1 {
+		script.FillRatio = 1
+	}
+	m.script = script
+}
+
+// SetPrice moves the price of a symbol and matches the resting orders it crosses
+func (m *MockBinance) SetPrice(symbol string, price Decimal) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.symbols[symbol]
+	if !ok {
+		return fmt.Errorf("unknown symbol %s", symbol)
+	}
+	s.price = price
+	m.matchResting()
+	return nil
+}
+
+// middleware delays every response by the script's latency and fails requests the script's faults match or
+// its fail rate selects
+func (m *MockBinance) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		latency := m.script.Latency
+		var fault *MockFault
+		if !strings.HasPrefix(r.URL.Path, "/mock/") {
+			for i := range m.script.Faults {
+				f := &m.script.Faults[i]
+				if f.Count > 0 && f.Path == r.URL.Path && (f.Method == "" || strings.EqualFold(f.Method, r.Method)) {
+					f.Count--
+					fault = f
+					break
+				}
+			}
+			if fault == nil && m.script.FailRate > 0 && m.rand.Float64() < m.script.FailRate {
+				fault = &MockFault{Status: http.StatusInternalServerError, Code: binanceUnknownErrorCode, Msg: "An unknown error occurred while processing the request."}
+			}
+		}
+		m.mu.Unlock()
+
+		if latency > 0 {
+			select {
+			case <-time.After(latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if fault != nil && fault.Drop {
+			next.ServeHTTP(httptest.NewRecorder(), r)
+			if conn, _, err := http.NewResponseController(w).Hijack(); err == nil {
+				conn.Close()
+			}
+			return
+		}
+		if fault != nil {
+			writeMockError(w, fault.Status, fault.Code, fault.Msg)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeMockJSON writes v as a successful JSON response
+func writeMockJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeMockError writes a Binance error response
+func writeMockError(w http.ResponseWriter, status, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"code": code, "msg": msg})
+}
+
+// symbol returns the symbol a request names, writing an error when the mock does not trade it
+func (m *MockBinance) symbol(w http.ResponseWriter, r *http.Request) (*mockSymbol, string, bool) {
+	name := r.URL.Query().Get("symbol")
+	s, ok := m.symbols[name]
+	if !ok {
+		writeMockError(w, http.StatusBadRequest, binanceInvalidSymbolErrorCode, "Invalid symbol.")
+	}
+	return s, name, ok
+}
+
+// handleTime returns the local clock as the server time
+func (m *MockBinance) handleTime(w http.ResponseWriter, r *http.Request) {
+	writeMockJSON(w, map[string]int64{"serverTime": time.Now().UnixMilli()})
+}
+
+// handleTickerPrice returns the price of a symbol
+func (m *MockBinance) handleTickerPrice(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, name, ok := m.symbol(w, r)
+	if !ok {
+		return
+	}
+	writeMockJSON(w, map[string]string{"symbol": name, "price": s.price.String()})
+}
+
+// handleBookTicker quotes the price one tick either side
+func (m *MockBinance) handleBookTicker(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, name, ok := m.symbol(w, r)
+	if !ok {
+		return
+	}
+	writeMockJSON(w, map[string]string{
+		"symbol":   name,
+		"bidPrice": s.price.Sub(mockTickSize).String(),
+		"askPrice": s.price.Add(mockTickSize).String(),
+	})
+}
+
+// handleExchangeInfo returns the trading rules of a symbol, which are the same for every mock symbol
+func (m *MockBinance) handleExchangeInfo(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, name, ok := m.symbol(w, r)
+	if !ok {
+		return
+	}
+	filters := []map[string]string{
+		{"filterType": "PRICE_FILTER", "tickSize": mockTickSize.String()},
+		{"filterType": "LOT_SIZE", "stepSize": mockStepSize.String(), "minQty": mockStepSize.String(), "maxQty": "9000"},
+		{"filterType": "NOTIONAL", "minNotional": mockMinNotional.String()},
+	}
+	writeMockJSON(w, map[string]any{"symbols": []map[string]any{{"symbol": name, "quoteAssetPrecision": 8, "filters": filters}}})
+}
+
+// handleAccount returns the free and locked balance of every asset
+func (m *MockBinance) handleAccount(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var balances []Balance
+	for asset, free := range m.free {
+		balances = append(balances, Balance{Asset: asset, Free: free.String(), Locked: m.locked[asset].String()})
+	}
+	slices.SortFunc(balances, func(a, b Balance) int { return strings.Compare(a.Asset, b.Asset) })
+	writeMockJSON(w, map[string]any{"balances": balances})
+}
+
+// handleNewOrder places a market order, which fills the script's fill ratio at once and expires the rest,
+// a limit order, which fills as far as it is marketable and rests or expires depending on its time in force,
+// a post-only order, which rests unless it would cross the book, or a stop-limit order, which rests
+// untriggered until the price reaches its stop price
+func (m *MockBinance) handleNewOrder(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, name, ok := m.symbol(w, r)
+	if !ok {
+		return
+	}
+	params := r.URL.Query()
+	clientOrderID := params.Get("newClientOrderId")
+	if clientOrderID != "" && slices.ContainsFunc(m.orders, func(o *mockOrder) bool {
+		return o.ClientOrderID == clientOrderID && !isTerminalStatus(o.Status)
+	}) {
+		writeMockError(w, http.StatusBadRequest, binanceOrderRejectedErrorCode, "Duplicate order sent.")
+		return
+	}
+
+	now := time.Now()
+	order := &mockOrder{
+		Symbol:        name,
+		OrderID:       m.nextID,
+		ClientOrderID: cmp.Or(clientOrderID, "mock-"+strconv.FormatInt(m.nextID, 10)),
+		Side:          params.Get("side"),
+		Type:          params.Get("type"),
+		TimeInForce:   params.Get("timeInForce"),
+		Price:         decimalOrZero(params.Get("price")),
+		StopPrice:     decimalOrZero(params.Get("stopPrice")),
+		OrigQty:       decimalOrZero(params.Get("quantity")),
+		Status:        OrderStatusNew,
+		Time:          now,
+		UpdateTime:    now,
+	}
+	if order.Type == OrderTypeMarket && order.OrigQty.IsZero() {
+		order.OrigQty = decimalOrZero(params.Get("quoteOrderQty")).Div(s.price).FloorToStep(mockStepSize)
+	}
+	if order.OrigQty.Sign() <= 0 || (order.Type != OrderTypeMarket && order.Price.Sign() <= 0) ||
+		(isStopLimit(order.Type) && order.StopPrice.Sign() <= 0) {
+		writeMockError(w, http.StatusBadRequest, -1013, "Invalid quantity or price.")
+		return
+	}
+	if order.Type == OrderTypeLimitMaker {
+		// Post-only orders are rejected when they would cross the book, whose touch is a tick from the price
+		if (order.Side == "BUY" && order.Price.Cmp(s.price.Add(mockTickSize)) >= 0) ||
+			(order.Side == "SELL" && order.Price.Cmp(s.price.Sub(mockTickSize)) <= 0) {
+			writeMockError(w, http.StatusBadRequest, binanceOrderRejectedErrorCode, "Order would immediately match and take.")
+			return
+		}
+		order.TimeInForce = TimeInForceGTC
+	}
+	if isStopLimit(order.Type) && order.stopTriggered(s.price) {
+		writeMockError(w, http.StatusBadRequest, binanceOrderRejectedErrorCode, "Order would immediately trigger.")
+		return
+	}
+	notionalPrice := s.price
+	if order.Type != OrderTypeMarket {
+		notionalPrice = order.Price
+	}
+	if order.OrigQty.Mul(notionalPrice).LessThan(mockMinNotional) {
+		writeMockError(w, http.StatusBadRequest, -1013, "Filter failure: NOTIONAL")
+		return
+	}
+
+	// Buys lock the quote asset at the order's price and sells lock the base asset
+	lockAsset, lockAmount := s.base, order.OrigQty
+	if order.Side == "BUY" {
+		lockAsset, lockAmount = s.quote, order.OrigQty.Mul(notionalPrice)
+	}
+	if m.free[lockAsset].LessThan(lockAmount) {
+		writeMockError(w, http.StatusBadRequest, binanceOrderRejectedErrorCode, "Account has insufficient balance for requested action.")
+		return
+	}
+	m.free[lockAsset] = m.free[lockAsset].Sub(lockAmount)
+	m.locked[lockAsset] = m.locked[lockAsset].Add(lockAmount)
+	m.nextID++
+	m.orders = append(m.orders, order)
+
+	traded := len(m.trades)
+	if order.Type == OrderTypeMarket {
+		m.fill(order, s, s.price)
+		m.close(order, s, OrderStatusExpired)
+	} else if order.Type == OrderTypeLimit {
+		m.match(order, s)
+		if order.TimeInForce != TimeInForceGTC {
+			m.close(order, s, OrderStatusExpired)
+		}
+	}
+	// Like a full Binance response, the response lists the trades the order executed on placement
+	response := order.response()
+	fills := []map[string]any{}
+	for _, trade := range m.trades[traded:] {
+		fills = append(fills, map[string]any{
+			"price":           trade.Price.String(),
+			"qty":             trade.Qty.String(),
+			"commission":      trade.Commission.String(),
+			"commissionAsset": trade.CommissionAsset,
+			"tradeId":         trade.ID,
+		})
+	}
+	response["fills"] = fills
+	writeMockJSON(w, response)
+}
+
+// handleGetOrder returns an order after matching resting orders against the current prices
+func (m *MockBinance) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.matchResting()
+	order, ok := m.lookup(w, r)
+	if !ok {
+		return
+	}
+	writeMockJSON(w, order.response())
+}
+
+// handleCancelOrder cancels an open order and releases the balance it locks
+func (m *MockBinance) handleCancelOrder(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	order, ok := m.lookup(w, r)
+	if !ok {
+		return
+	}
+	if isTerminalStatus(order.Status) {
+		writeMockError(w, http.StatusBadRequest, -2011, "Unknown order sent.")
+		return
+	}
+	m.close(order, m.symbols[order.Symbol], OrderStatusCanceled)
+	writeMockJSON(w, order.response())
+}
+
+// lookup returns the order a request names by orderId or origClientOrderId, writing an error when there is none
+func (m *MockBinance) lookup(w http.ResponseWriter, r *http.Request) (*mockOrder, bool) {
+	params := r.URL.Query()
+	for _, order := range slices.Backward(m.orders) {
+		if order.Symbol != params.Get("symbol") {
+			continue
+		}
+		if strconv.FormatInt(order.OrderID, 10) == params.Get("orderId") ||
+			(params.Get("origClientOrderId") != "" && order.ClientOrderID == params.Get("origClientOrderId")) {
+			return order, true
+		}
+	}
+	writeMockError(w, http.StatusBadRequest, binanceNoSuchOrderErrorCode, "Order does not exist.")
+	return nil, false
+}
+
+// handleOpenOrders lists the open orders of a symbol
+func (m *MockBinance) handleOpenOrders(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.matchResting()
+	open := []map[string]any{}
+	for _, order := range m.orders {
+		if order.Symbol == r.URL.Query().Get("symbol") && !isTerminalStatus(order.Status) {
+			open = append(open, order.response())
+		}
+	}
+	writeMockJSON(w, open)
+}
+
+// handleMyTrades lists the trades of a symbol from fromId, or executed between startTime and endTime, up to
+// limit
+func (m *MockBinance) handleMyTrades(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	params := r.URL.Query()
+	if params.Has("fromId") && params.Has("startTime") {
+		writeMockError(w, http.StatusBadRequest, -1127, "fromId cannot be combined with startTime or endTime.")
+		return
+	}
+	fromID, _ := strconv.ParseInt(params.Get("fromId"), 10, 64)
+	orderID, _ := strconv.ParseInt(params.Get("orderId"), 10, 64)
+	startTime, _ := strconv.ParseInt(params.Get("startTime"), 10, 64)
+	endTime, err := strconv.ParseInt(params.Get("endTime"), 10, 64)
+	if err != nil {
+		endTime = math.MaxInt64
+	}
+	limit, err := strconv.Atoi(params.Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = 500
+	}
+	trades := []map[string]any{}
+	for _, trade := range m.trades {
+		if trade.Symbol != params.Get("symbol") || trade.ID < fromID || trade.Time.UnixMilli() < startTime ||
+			trade.Time.UnixMilli() > endTime || (orderID != 0 && trade.OrderID != orderID) || len(trades) == limit {
+			continue
+		}
+		trades = append(trades, map[string]any{
+			"symbol":          trade.Symbol,
+			"id":              trade.ID,
+			"orderId":         trade.OrderID,
+			"price":           trade.Price.String(),
+			"qty":             trade.Qty.String(),
+			"quoteQty":        trade.Price.Mul(trade.Qty).String(),
+			"commission":      trade.Commission.String(),
+			"commissionAsset": trade.CommissionAsset,
+			"time":            trade.Time.UnixMilli(),
+			"isBuyer":         trade.IsBuyer,
+		})
+	}
+	writeMockJSON(w, trades)
+}
+
+// handleWithdraw withdraws a free balance at once, completing the withdrawal without a network fee
+func (m *MockBinance) handleWithdraw(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	params := r.URL.Query()
+	asset, amount := params.Get("coin"), decimalOrZero(params.Get("amount"))
+	if amount.Sign() <= 0 || params.Get("address") == "" {
+		writeMockError(w, http.StatusBadRequest, -1102, "Mandatory parameter was not sent, was empty/null, or malformed.")
+		return
+	}
+	if m.free[asset].LessThan(amount) {
+		writeMockError(w, http.StatusBadRequest, -4026, "User has insufficient balance")
+		return
+	}
+	m.free[asset] = m.free[asset].Sub(amount)
+	withdrawal := Withdrawal{
+		ID:        "mock-withdrawal-" + strconv.Itoa(len(m.withdrawals)+1),
+		ClientID:  params.Get("withdrawOrderId"),
+		Asset:     asset,
+		Network:   params.Get("network"),
+		Address:   params.Get("address"),
+		Amount:    amount,
+		AppliedAt: time.Now().UTC(),
+	}
+	m.withdrawals = append(m.withdrawals, withdrawal)
+	writeMockJSON(w, map[string]string{"id": withdrawal.ID})
+}
+
+// handleWithdrawHistory lists the withdrawals of an asset, or of every asset, filtered by client ID when it is set
+func (m *MockBinance) handleWithdrawHistory(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	params := r.URL.Query()
+	history := []map[string]any{}
+	for _, withdrawal := range slices.Backward(m.withdrawals) {
+		if (params.Has("coin") && withdrawal.Asset != params.Get("coin")) ||
+			(params.Has("withdrawOrderId") && withdrawal.ClientID != params.Get("withdrawOrderId")) {
+			continue
+		}
+		history = append(history, map[string]any{
+			"id":              withdrawal.ID,
+			"withdrawOrderId": withdrawal.ClientID,
+			"coin":            withdrawal.Asset,
+			"network":         withdrawal.Network,
+			"address":         withdrawal.Address,
+			"amount":          withdrawal.Amount.String(),
+			"transactionFee":  "0",
+			"status":          6,
+			"applyTime":       withdrawal.AppliedAt.Format(time.DateTime),
+		})
+	}
+	writeMockJSON(w, history)
+}
+
+// handleScript replaces the script with the JSON request body
+func (m *MockBinance) handleScript(w http.ResponseWriter, r *http.Request) {
+	var script MockScript
+	if err := json.NewDecoder(r.Body).Decode(&script); err != nil {
+		http.Error(w, fmt.Sprintf("invalid script: %v", err), http.StatusBadRequest)
+		return
+	}
+	m.SetScript(script)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetPrice moves the price of the symbol parameter to the price parameter
+func (m *MockBinance) handleSetPrice(w http.ResponseWriter, r *http.Request) {
+	price, err := ParseDecimal(r.URL.Query().Get("price"))
+	if err != nil || price.Sign() <= 0 {
+		http.Error(w, "invalid price", http.StatusBadRequest)
+		return
+	}
+	if err := m.SetPrice(r.URL.Query().Get("symbol"), price); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// matchResting triggers resting stop-limit orders whose stop price has been reached and matches every
+// resting limit order against the current prices
+func (m *MockBinance) matchResting() {
+	for _, order := range m.orders {
+		if order.Type == OrderTypeMarket || isTerminalStatus(order.Status) {
+			continue
+		}
+		s := m.symbols[order.Symbol]
+		if isStopLimit(order.Type) && !order.Triggered {
+			if !order.stopTriggered(s.price) {
+				continue
+			}
+			order.Triggered = true
+			m.match(order, s)
+			if order.TimeInForce != TimeInForceGTC {
+				m.close(order, s, OrderStatusExpired)
+			}
+			continue
+		}
+		m.match(order, s)
+	}
+}
+
+// stopTriggered reports whether price has reached a stop-limit order's stop price: a stop-loss triggers
+// when the price moves against the order's side and a take-profit when it moves in its favor
+func (o *mockOrder) stopTriggered(price Decimal) bool {
+	rising := price.Cmp(o.StopPrice) >= 0
+	falling := price.Cmp(o.StopPrice) <= 0
+	if (o.Type == OrderTypeStopLossLimit) == (o.Side == "BUY") {
+		return rising
+	}
+	return falling
+}
+
+// match fills a limit order at its price when the market price has reached it
+func (m *MockBinance) match(order *mockOrder, s *mockSymbol) {
+	if (order.Side == "BUY" && s.price.GreaterThan(order.Price)) || (order.Side == "SELL" && s.price.LessThan(order.Price)) {
+		return
+	}
+	m.fill(order, s, order.Price)
+}
+
+// fill executes the script's fill ratio of an order's remaining quantity at price, all of it when the rest
+// would be below a lot step, and moves the balances
+func (m *MockBinance) fill(order *mockOrder, s *mockSymbol, price Decimal) {
+	remaining := order.OrigQty.Sub(order.ExecutedQty)
+	qty := remaining.MulFloat(m.script.FillRatio).FloorToStep(mockStepSize)
+	if remaining.Sub(qty).LessThan(mockStepSize) || qty.IsZero() {
+		qty = remaining
+	}
+	quote := qty.Mul(price)
+
+	if order.Side == "BUY" {
+		// The quote asset was locked at the order's limit price, or the market price for market orders
+		lockPrice := order.Price
+		if order.Type == OrderTypeMarket {
+			lockPrice = price
+		}
+		m.locked[s.quote] = m.locked[s.quote].Sub(qty.Mul(lockPrice))
+		m.free[s.quote] = m.free[s.quote].Add(qty.Mul(lockPrice)).Sub(quote)
+		m.free[s.base] = m.free[s.base].Add(qty)
+	} else {
+		m.locked[s.base] = m.locked[s.base].Sub(qty)
+		m.free[s.quote] = m.free[s.quote].Add(quote)
+	}
+
+	commission, commissionAsset := m.commission(s, order.Side, qty, quote)
+	m.free[commissionAsset] = m.free[commissionAsset].Sub(commission)
+
+	order.ExecutedQty = order.ExecutedQty.Add(qty)
+	order.CumQuoteQty = order.CumQuoteQty.Add(quote)
+	order.UpdateTime = time.Now()
+	order.Status = OrderStatusPartiallyFilled
+	if order.ExecutedQty.Cmp(order.OrigQty) >= 0 {
+		order.Status = OrderStatusFilled
+	}
+	m.trades = append(m.trades, mockTrade{
+		ID:              int64(len(m.trades) + 1),
+		OrderID:         order.OrderID,
+		Symbol:          order.Symbol,
+		Price:           price,
+		Qty:             qty,
+		Commission:      commission,
+		CommissionAsset: commissionAsset,
+		Time:            order.UpdateTime,
+		IsBuyer:         order.Side == "BUY",
+	})
+}
+
+// commission returns the script's commission on a trade of qty for quote and its asset: the fee asset when it
+// is priced by a mock symbol, otherwise the asset the trade received
+func (m *MockBinance) commission(s *mockSymbol, side string, qty, quote Decimal) (Decimal, string) {
+	rate := m.script.FeeBps / 10000
+	if feeSymbol, ok := m.symbols[m.script.FeeAsset+s.quote]; ok && m.script.FeeAsset != "" {
+		return quote.MulFloat(rate).Div(feeSymbol.price), m.script.FeeAsset
+	}
+	if side == "BUY" {
+		return qty.MulFloat(rate), s.base
+	}
+	return quote.MulFloat(rate), s.quote
+}
+
+// close ends an order that has not filled completely with status, releasing the balance it still locks
+func (m *MockBinance) close(order *mockOrder, s *mockSymbol, status string) {
+	if order.Status == OrderStatusFilled {
+		return
+	}
+	remaining := order.OrigQty.Sub(order.ExecutedQty)
+	asset, amount := s.base, remaining
+	if order.Side == "BUY" {
+		lockPrice := order.Price
+		if order.Type == OrderTypeMarket {
+			lockPrice = s.price
+		}
+		asset, amount = s.quote, remaining.Mul(lockPrice)
+	}
+	m.locked[asset] = m.locked[asset].Sub(amount)
+	m.free[asset] = m.free[asset].Add(amount)
+	order.Status = status
+	order.UpdateTime = time.Now()
+}
+
+// response returns the order in the format of the Binance order endpoints
+func (o *mockOrder) response() map[string]any {
+	return map[string]any{
+		"symbol":              o.Symbol,
+		"orderId":             o.OrderID,
+		"clientOrderId":       o.ClientOrderID,
+		"transactTime":        o.Time.UnixMilli(),
+		"time":                o.Time.UnixMilli(),
+		"updateTime":          o.UpdateTime.UnixMilli(),
+		"price":               o.Price.String(),
+		"stopPrice":           o.StopPrice.String(),
+		"origQty":             o.OrigQty.String(),
+		"executedQty":         o.ExecutedQty.String(),
+		"cummulativeQuoteQty": o.CumQuoteQty.String(),
+		"status":              o.Status,
+		"type":                o.Type,
+		"side":                o.Side,
+		"timeInForce":         o.TimeInForce,
+	}
+}
+
+// parseAssetAmounts parses a comma-separated list such as "BTCUSDT=50000,ETHUSDT=3000" into a map
+func parseAssetAmounts(spec string) (map[string]Decimal, error) {
+	amounts := map[string]Decimal{}
+	if spec == "" {
+		return amounts, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		name, amountSpec, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		amount, err := ParseDecimal(amountSpec)
+		if !ok || err != nil || amount.Sign() < 0 {
+			return nil, fmt.Errorf("invalid entry %q: use NAME=AMOUNT", entry)
+		}
+		amounts[strings.ToUpper(name)] = amount
+	}
+	return amounts, nil
+}
+
+// runMockServer serves a mock Binance spot exchange until interrupted, for running the trade commands
+// against with -rest-url
+func runMockServer(args []string) error {
+	// The mock is a local fake, so it takes none of the exchange, credential or secrets flags
+	fs := flag.NewFlagSet("mock-server", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:9090", "Address to listen on")
+	quoteAsset := fs.String("quote", "USDT", "Quote asset of the mock symbols")
+	symbols := fs.String("symbols", "BTCUSDT=50000", "Comma-separated symbols and their starting prices (e.g., BTCUSDT=50000,ETHUSDT=3000)")
+	balances := fs.String("balances", "USDT=10000", "Comma-separated starting free balances (e.g., USDT=10000,BTC=0.5)")
+	scriptPath := fs.String("script", "", "JSON file with the latency, fail_rate, fill_ratio, fee_bps, fee_asset and faults to apply (replaceable at runtime with PUT /mock/script)")
+	latency := fs.Duration("latency", 0, "Delay every response by this duration")
+	failRate := fs.Float64("fail-rate", 0, "Probability of a request failing with an unknown error (0-1)")
+	fillRatio := fs.Float64("fill-ratio", 1, "Share of an order's remaining quantity filled at each match (0-1]")
+	feeBps := fs.Float64("fee-bps", 0, "Commission charged on every trade in basis points, in the asset received or in -fee-asset")
+	feeAsset := fs.String("fee-asset", "", "Asset commissions are paid in instead of the asset received, priced by its mock symbol (e.g., BNB with -symbols BTCUSDT=50000,BNBUSDT=600)")
+	seed := fs.Uint64("seed", 1, "Seed of the random failures, so a scripted run can be repeated exactly")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	if err := setupLogging("text"); err != nil {
+		return err
+	}
+
+	prices, err := parseAssetAmounts(*symbols)
+	if err != nil {
+		return fmt.Errorf("error parsing symbols: %v", err)
+	}
+	for symbol, price := range prices {
+		if !strings.HasSuffix(symbol, strings.ToUpper(*quoteAsset)) || price.Sign() <= 0 {
+			return fmt.Errorf("symbol %s must be quoted in %s and have a positive price", symbol, *quoteAsset)
+		}
+	}
+	startBalances, err := parseAssetAmounts(*balances)
+	if err != nil {
+		return fmt.Errorf("error parsing balances: %v", err)
+	}
+	script := MockScript{Latency: *latency, FailRate: *failRate, FillRatio: *fillRatio, FeeBps: *feeBps, FeeAsset: strings.ToUpper(*feeAsset)}
+	if *scriptPath != "" {
+		data, err := os.ReadFile(*scriptPath)
+		if err != nil {
+			return fmt.Errorf("error reading script: %v", err)
+		}
+		if err := json.Unmarshal(data, &script); err != nil {
+			return fmt.Errorf("error parsing script: %v", err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %v", *addr, err)
+	}
+	mock := NewMockBinance(strings.ToUpper(*quoteAsset), prices, startBalances, *seed)
+	mock.SetScript(script)
+	mock.Start(listener)
+	defer mock.Close()
+	log.Printf("Mock Binance listening on %s. Trade against it with -rest-url %s -api-key mock -secret-key mock", mock.URL(), mock.URL())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestTWAPAgainstMock runs a TWAP buy against the mock exchange and checks that every slice is placed and the
+// whole budget spent, including when order requests fail and are retried
+func TestTWAPAgainstMock(t *testing.T) {
+	tests := []struct {
+		name   string
+		faults []MockFault
+	}{
+		{name: "no faults"},
+		{name: "retried order failures", faults: []MockFault{{Method: "POST", Path: "/api/v3/order", Count: 2, Status: 503, Code: -1001, Msg: "Internal error; unable to process your request. Please try again."}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockBinance("USDT", map[string]Decimal{"BTCUSDT": decimalOrZero("50000")}, map[string]Decimal{"USDT": decimalOrZero("1000")}, 1)
+			mock.SetScript(MockScript{FillRatio: 1, Faults: tt.faults})
+			mock.Start(nil)
+			defer mock.Close()
+
+			signer, err := NewSigner("hmac", "mock")
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := NewBinanceClient("mock", signer)
+			client.retryPolicy = RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+			if err := client.SetEndpoints([]string{mock.URL()}, ""); err != nil {
+				t.Fatal(err)
+			}
+			filters, err := client.GetSymbolFilters("BTCUSDT")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := TWAPConfig{
+				Symbol:     "BTCUSDT",
+				Side:       "BUY",
+				QuoteAsset: "USDT",
+				Algo:       AlgoTWAP,
+				Amount:     decimalOrZero("30"),
+				Duration:   3 * time.Second,
+				OrderType:  OrderTypeMarket,
+				Filters:    filters,
+			}
+			state := planTWAP(cfg, 50000)
+			if state.TotalSlices != 3 {
+				t.Fatalf("planned %d slices, want 3", state.TotalSlices)
+			}
+			runTWAP(context.Background(), client, state, "")
+
+			if state.PlacedSlices != 3 || state.FailedSlices != 0 {
+				t.Errorf("placed %d and failed %d slices, want 3 placed and none failed", state.PlacedSlices, state.FailedSlices)
+			}
+			if !state.Remaining.IsZero() {
+				t.Errorf("remaining budget %s, want 0", state.Remaining)
+			}
+			if state.FilledQuote.Cmp(decimalOrZero("30")) != 0 {
+				t.Errorf("filled %s USDT, want 30", state.FilledQuote)
+			}
+			balance, err := client.GetBalance("USDT")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if balance.Cmp(decimalOrZero("970")) != 0 {
+				t.Errorf("USDT balance %s after the run, want 970", balance)
+			}
+		})
+	}
+}
+
+// newMockClient starts a mock exchange holding 1000 USDT and returns it with a client sending to it with fast
+// retries
+func newMockClient(t *testing.T) (*MockBinance, *BinanceClient) {
+	t.Helper()
+	mock := NewMockBinance("USDT", map[string]Decimal{"BTCUSDT": decimalOrZero("50000")}, map[string]Decimal{"USDT": decimalOrZero("1000")}, 1)
+	mock.Start(nil)
+	t.Cleanup(mock.Close)
+
+	signer, err := NewSigner("hmac", "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewBinanceClient("mock", signer)
+	client.retryPolicy = RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	if err := client.SetEndpoints([]string{mock.URL()}, ""); err != nil {
+		t.Fatal(err)
+	}
+	return mock, client
+}
+
+// placedOrders returns the number of orders the mock exchange has accepted
+func (m *MockBinance) placedOrders() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.orders)
+}
+
+// TestPlaceOrderAfterTimeout places an order whose first request times out after the exchange accepted it, and
+// checks that the resend finds the order by its client order ID instead of placing a second one
+func TestPlaceOrderAfterTimeout(t *testing.T) {
+	mock, client := newMockClient(t)
+	mock.SetScript(MockScript{FillRatio: 1, Faults: []MockFault{{Method: "POST", Path: "/api/v3/order", Count: 1, Drop: true}}})
+
+	order, err := client.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: OrderTypeMarket, QuoteQuantity: decimalOrZero("10")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ClientOrderID == "" || order.Status != OrderStatusFilled {
+		t.Errorf("got order %q with status %s, want a filled order with a client order ID", order.ClientOrderID, order.Status)
+	}
+	if placed := mock.placedOrders(); placed != 1 {
+		t.Errorf("placed %d orders, want 1", placed)
+	}
+}
+
+// TestWSOrderAfterFailedWrite places an order over a WebSocket API connection that fails while the request is
+// written, after the exchange has already accepted the order, and checks that the REST fallback finds the order
+// instead of placing a second one
+func TestWSOrderAfterFailedWrite(t *testing.T) {
+	mock, client := newMockClient(t)
+	conn, server := net.Pipe()
+	defer conn.Close()
+	api := NewBinanceWSAPI(client)
+	api.conn = &wsConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	const clientOrderID = "bb-ws-test"
+	go func() {
+		// Read the start of the request frame, let the exchange accept the order and drop the connection before
+		// the rest of the frame is written
+		server.Read(make([]byte, 8))
+		res, err := http.Post(mock.URL()+"/api/v3/order?symbol=BTCUSDT&side=BUY&type=MARKET&quoteOrderQty=10&newClientOrderId="+clientOrderID, "", nil)
+		if err == nil {
+			res.Body.Close()
+		}
+		server.Close()
+	}()
+
+	orders := &wsOrderClient{ExchangeClient: client, rest: client, api: api}
+	order, err := orders.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: OrderTypeMarket,
+		QuoteQuantity: decimalOrZero("10"), ClientOrderID: clientOrderID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.ClientOrderID != clientOrderID {
+		t.Errorf("got order %q, want %q", order.ClientOrderID, clientOrderID)
+	}
+	if placed := mock.placedOrders(); placed != 1 {
+		t.Errorf("placed %d orders, want 1", placed)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// MomentumConfig holds the parameters of the momentum breakout strategy
+type MomentumConfig struct {
+	// Lookback is the number of candles whose high the close must break
+	Lookback int `json:"lookback"`
+	// VolumePeriod is the number of candles the breakout's volume is compared against
+	VolumePeriod int `json:"volume_period"`
+	// VolumeMultiplier is how many times the average volume the breakout candle must trade
+	VolumeMultiplier float64 `json:"volume_multiplier"`
+	// TrailingStopPct is how far the close may fall below the highest high since entry before exiting
+	TrailingStopPct float64 `json:"trailing_stop_pct"`
+}
+
+// MomentumBreakout enters when a candle closes above the high of the Lookback candles before it on volume
+// confirming the move, and exits when the close falls TrailingStopPct below the highest high since entry
+type MomentumBreakout struct {
+	cfg MomentumConfig
+	// highs are the highs of the last Lookback candles
+	highs  []float64
+	volume *SMA
+	// peak is the highest high since entry, zero while flat
+	peak float64
+}
+
+// NewMomentumBreakout creates the strategy, validating its parameters
+func NewMomentumBreakout(cfg MomentumConfig) (*MomentumBreakout, error) {
+	if cfg.Lookback < 1 || cfg.VolumePeriod < 1 {
+		return nil, fmt.Errorf("momentum lookback and volume_period must be at least 1")
+	}
+	if cfg.TrailingStopPct <= 0 || cfg.TrailingStopPct >= 100 {
+		return nil, fmt.Errorf("momentum trailing_stop_pct must be between 0 and 100")
+	}
+	if cfg.VolumeMultiplier < 0 {
+		return nil, fmt.Errorf("momentum volume_multiplier must not be negative")
+	}
+	return &MomentumBreakout{cfg: cfg, volume: NewSMA(cfg.VolumePeriod)}, nil
+}
+
+// Name returns the strategy's config name
+func (m *MomentumBreakout) Name() string {
+	return "momentum"
+}
+
+// Warmup returns the candles needed to fill the high and volume windows
+func (m *MomentumBreakout) Warmup() int {
+	return max(m.cfg.Lookback, m.cfg.VolumePeriod)
+}
+
+// OnCandle signals an entry on a confirmed breakout and an exit when the trailing stop is hit
+func (m *MomentumBreakout) OnCandle(c Candle, inPosition bool) Signal {
+	var signal Signal
+	if inPosition {
+		m.peak = max(m.peak, c.High)
+		if stop := m.peak * (1 - m.cfg.TrailingStopPct/100); c.Close <= stop {
+			signal = Signal{Action: ActionExit, Reason: fmt.Sprintf("close %.8f hit the trailing stop %.8f", c.Close, stop)}
+		}
+	} else {
+		m.peak = 0
+		if len(m.highs) == m.cfg.Lookback && m.volume.Ready() {
+			high := slices.Max(m.highs)
+			required := m.volume.Value() * m.cfg.VolumeMultiplier
+			if c.Close > high && c.Volume >= required {
+				m.peak = c.High
+				signal = Signal{Action: ActionEnter, Reason: fmt.Sprintf("close %.8f broke the %d-candle high %.8f on volume %.2f (required %.2f)",
+					c.Close, m.cfg.Lookback, high, c.Volume, required)}
+			}
+		}
+	}
+	m.highs = append(m.highs, c.High)
+	if len(m.highs) > m.cfg.Lookback {
+		m.highs = m.highs[1:]
+	}
+	m.volume.add(c.Volume)
+	return signal
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Notification verbosity levels, each including the events of the levels before it
+const (
+	// NotifyErrors sends errors, stopped runs and kill switch triggers
+	NotifyErrors = "errors"
+	// NotifySummary adds the start, re-plans, completion, exit, withdrawal and margin transfers of a run
+	NotifySummary = "summary"
+	// NotifyAll adds every placed, filled and skipped slice
+	NotifyAll = "all"
+)
+
+// notifyLevels ranks the verbosity levels
+var notifyLevels = map[string]int{NotifyErrors: 0, NotifySummary: 1, NotifyAll: 2}
+
+// notifyEventLevels is the lowest verbosity level that sends each audit event
+var notifyEventLevels = map[string]int{
+	AuditError:         0,
+	eventSpreadAlert:   0,
+	AuditStopped:       0,
+	AuditKilled:        0,
+	AuditPlanned:       1,
+	AuditResumed:       1,
+	AuditReplanned:     1,
+	AuditInterrupted:   1,
+	AuditCompleted:     1,
+	AuditExit:          1,
+	AuditWithdrawal:    1,
+	AuditTransferred:   1,
+	AuditBudgetChanged: 1,
+	AuditCaughtUp:      1,
+	AuditWindowClosed:  1,
+	AuditBlackout:      1,
+	AuditPlaced:        2,
+	AuditFilled:        2,
+	AuditUnfilled:      2,
+	AuditSkipped:       2,
+}
+
+// notifyQueueSize bounds the messages waiting to be sent before new ones are dropped
+const notifyQueueSize = 100
+
+// notifyFlushTimeout bounds how long Close waits for queued messages to be sent
+const notifyFlushTimeout = 10 * time.Second
+
+// notifyRetryPolicy retries webhooks briefly so a slow chat service cannot hold up the queue
+var notifyRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+// notifyEvent is a run event queued for delivery
+type notifyEvent struct {
+	Time   time.Time
+	RunID  string
+	Symbol string
+	Side   string
+	Event  string
+	// Args are the event's key-value fields as in slog
+	Args []any
+}
+
+// notifyBackend delivers run events to an external service
+type notifyBackend interface {
+	Name() string
+	Send(event notifyEvent) error
+}
+
+// notifyChannel is a backend and the verbosity of the events sent to it
+type notifyChannel struct {
+	backend notifyBackend
+	level   int
+}
+
+// notification is an event queued for a channel
+type notification struct {
+	channel notifyChannel
+	event   notifyEvent
+}
+
+// Notifier sends the events of a run to chat services and webhooks in the background, so a slow or failing
+// webhook never delays trading
+type Notifier struct {
+	channels []notifyChannel
+	queue    chan notification
+	done     chan struct{}
+}
+
+// NewNotifier returns a notifier that is disabled until backends are added and started
+func NewNotifier() *Notifier {
+	return &Notifier{}
+}
+
+// Add registers a backend receiving the events of verbosity and below
+func (n *Notifier) Add(backend notifyBackend, verbosity string) error {
+	level, ok := notifyLevels[strings.ToLower(verbosity)]
+	if !ok {
+		return fmt.Errorf("invalid %s verbosity: %s. Use errors, summary or all", backend.Name(), verbosity)
+	}
+	n.channels = append(n.channels, notifyChannel{backend: backend, level: level})
+	return nil
+}
+
+// Start starts delivering notifications. It returns nil when no backends are configured, which leaves
+// notifications disabled.
+func (n *Notifier) Start() *Notifier {
+	if len(n.channels) == 0 {
+		return nil
+	}
+	n.queue = make(chan notification, notifyQueueSize)
+	n.done = make(chan struct{})
+	go n.deliver()
+	return n
+}
+
+// deliver sends queued notifications until the queue is closed
+func (n *Notifier) deliver() {
+	defer close(n.done)
+	for msg := range n.queue {
+		if err := msg.channel.backend.Send(msg.event); err != nil {
+			slog.Warn("Error sending notification", "backend", msg.channel.backend.Name(), "error", err)
+		}
+	}
+}
+
+// Notify queues an event for every channel whose verbosity includes it. Events are dropped when the queue is
+// full. A nil notifier sends nothing.
+func (n *Notifier) Notify(event notifyEvent) {
+	if n == nil {
+		return
+	}
+	level, ok := notifyEventLevels[event.Event]
+	if !ok {
+		return
+	}
+	for _, channel := range n.channels {
+		if level > channel.level {
+			continue
+		}
+		select {
+		case n.queue <- notification{channel: channel, event: event}:
+		default:
+			slog.Warn("Notification queue full, dropping message", "backend", channel.backend.Name(), "event", event.Event)
+		}
+	}
+}
+
+// Close sends the queued notifications, waiting at most notifyFlushTimeout. A nil notifier is a no-op.
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+	close(n.queue)
+	select {
+	case <-n.done:
+	case <-time.After(notifyFlushTimeout):
+		slog.Warn("Timed out sending notifications", "pending", len(n.queue))
+	}
+}
+
+// text renders the event as a chat message, e.g.
+// "[BTCUSDT BUY run 1a2b] completed: filled_base=0.01 filled_quote=650"
+func (e notifyEvent) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s %s run %s] %s", e.Symbol, e.Side, e.RunID, e.Event)
+	for i := 0; i+1 < len(e.Args); i += 2 {
+		sep := " "
+		if i == 0 {
+			sep = ": "
+		}
+		fmt.Fprintf(&b, "%s%v=%v", sep, e.Args[i], e.Args[i+1])
+	}
+	return b.String()
+}
+
+// ChatBackend posts events as messages to a chat service's incoming webhook
+type ChatBackend struct {
+	name string
+	url  string
+	// field is the JSON field carrying the message text
+	field string
+	// maxLen is the longest message the service accepts, in characters
+	maxLen     int
+	httpClient *http.Client
+}
+
+// NewSlackBackend returns a backend posting to a Slack incoming webhook
+func NewSlackBackend(url string) *ChatBackend {
+	return &ChatBackend{name: "slack", url: url, field: "text", maxLen: 40000, httpClient: newHTTPClient()}
+}
+
+// NewDiscordBackend returns a backend posting to a Discord webhook
+func NewDiscordBackend(url string) *ChatBackend {
+	return &ChatBackend{name: "discord", url: url, field: "content", maxLen: 2000, httpClient: newHTTPClient()}
+}
+
+// Name returns the name of the chat service
+func (w *ChatBackend) Name() string {
+	return w.name
+}
+
+// Send posts an event to the webhook as a message, truncated to the service's length limit and retried on
+// transient failures
+func (w *ChatBackend) Send(event notifyEvent) error {
+	text := event.text()
+	if runes := []rune(text); len(runes) > w.maxLen {
+		text = string(runes[:w.maxLen-1]) + "…"
+	}
+	body, err := json.Marshal(map[string]string{w.field: text})
+	if err != nil {
+		return fmt.Errorf("error encoding message: %v", err)
+	}
+
+	return postJSON(w.httpClient, notifyRetryPolicy, w.url, body, nil)
+}
+
+// postJSON posts a JSON body, retrying transient failures under policy. sign, when set, adds headers to each
+// attempt's request.
+func postJSON(httpClient *http.Client, policy RetryPolicy, url string, body []byte, sign func(*http.Request)) error {
+	res, err := doWithRetry(policy, func() (*httpResult, error) {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %v", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if sign != nil {
+			sign(req)
+		}
+		return readResult(httpClient, req)
+	}, func(res *httpResult) bool { return isRetryableStatus(res.StatusCode) })
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned status %d: %s", res.StatusCode, string(res.Body))
+	}
+	return nil
+}
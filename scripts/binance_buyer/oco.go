@@ -0,0 +1,256 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// OCORequest describes a one-cancels-the-other order: a limit order at Price and a stop order triggered at
+// StopPrice. When one leg fills or triggers, the exchange cancels the other.
+type OCORequest struct {
+	Symbol   string
+	Side     string
+	Quantity Decimal
+	// Price is the limit price of the take-profit leg
+	Price Decimal
+	// StopPrice triggers the stop-loss leg
+	StopPrice Decimal
+	// StopLimitPrice makes the stop-loss leg a limit order at this price once triggered, where zero sends it
+	// as a market order
+	StopLimitPrice Decimal
+	// ListClientOrderID identifies the order list, where empty generates one so a resend can look the list up
+	ListClientOrderID string
+}
+
+// OrderList is a group of orders that are managed together by the exchange, such as the legs of an OCO order
+type OrderList struct {
+	ListID            string   `json:"list_id"`
+	ListClientOrderID string   `json:"list_client_order_id"`
+	Symbol            string   `json:"symbol"`
+	Status            string   `json:"status"`
+	Orders            []*Order `json:"orders"`
+}
+
+// Binance order list statuses
+const (
+	OrderListStatusExecuting = "EXECUTING"
+	OrderListStatusAllDone   = "ALL_DONE"
+	OrderListStatusReject    = "REJECT"
+)
+
+// Done reports whether none of the list's orders can fill any more
+func (l *OrderList) Done() bool {
+	return l.Status == OrderListStatusAllDone || l.Status == OrderListStatusReject
+}
+
+// OCOPlacer is implemented by clients that can place OCO orders and track their order lists
+type OCOPlacer interface {
+	PlaceOCOOrder(req OCORequest) (*OrderList, error)
+	GetOrderList(symbol, listID string) (*OrderList, error)
+	CancelOrderList(symbol, listID string) error
+}
+
+// orderListResponse represents an order list returned by the Binance order list endpoints
+type orderListResponse struct {
+	OrderListID       int64  `json:"orderListId"`
+	ListClientOrderID string `json:"listClientOrderId"`
+	Symbol            string `json:"symbol"`
+	ListOrderStatus   string `json:"listOrderStatus"`
+	Orders            []struct {
+		OrderID       int64  `json:"orderId"`
+		ClientOrderID string `json:"clientOrderId"`
+	} `json:"orders"`
+	OrderReports []OrderResponse `json:"orderReports"`
+}
+
+// toOrderList converts an order list response, taking the orders from its order reports when it has them
+func (r *orderListResponse) toOrderList() *OrderList {
+	list := &OrderList{
+		ListID:            strconv.FormatInt(r.OrderListID, 10),
+		ListClientOrderID: r.ListClientOrderID,
+		Symbol:            r.Symbol,
+		Status:            r.ListOrderStatus,
+	}
+	if len(r.OrderReports) > 0 {
+		for _, report := range r.OrderReports {
+			list.Orders = append(list.Orders, report.toOrder())
+		}
+		return list
+	}
+	for _, order := range r.Orders {
+		list.Orders = append(list.Orders, &Order{
+			Symbol:        r.Symbol,
+			OrderID:       strconv.FormatInt(order.OrderID, 10),
+			ClientOrderID: order.ClientOrderID,
+		})
+	}
+	return list
+}
+
+// PlaceOCOOrder places an OCO order with a limit maker take-profit leg and a stop-loss leg
+func (c *BinanceClient) PlaceOCOOrder(req OCORequest) (*OrderList, error) {
+	params := url.Values{}
+	params.Set("symbol", req.Symbol)
+	params.Set("side", strings.ToUpper(req.Side))
+	params.Set("quantity", req.Quantity.String())
+	params.Set("price", req.Price.String())
+	params.Set("stopPrice", req.StopPrice.String())
+	if req.StopLimitPrice.Sign() > 0 {
+		params.Set("stopLimitPrice", req.StopLimitPrice.String())
+		params.Set("stopLimitTimeInForce", TimeInForceGTC)
+	}
+	listClientOrderID := cmp.Or(req.ListClientOrderID, newClientOrderID())
+	params.Set("listClientOrderId", listClientOrderID)
+	params.Set("newOrderRespType", "RESULT")
+
+	list := &idempotentOrderList{client: c, listClientOrderID: listClientOrderID, params: params}
+	body, err := c.resendOnTimestampError(func() (*httpResult, error) {
+		return doWithRetry(c.retryPolicy, list.attempt, isBinanceRetryable)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseOrderListResponse(body)
+}
+
+// idempotentOrderList is a new order list sent under a list client order ID. Like an idempotentOrder, once it
+// has been sent it is looked up by that ID before every resend, so a timed out attempt that placed the list
+// is never followed by a second list.
+type idempotentOrderList struct {
+	client            *BinanceClient
+	listClientOrderID string
+	params            url.Values
+	sent              bool
+}
+
+// attempt sends the order list, or returns it when an earlier attempt already placed it
+func (l *idempotentOrderList) attempt() (*httpResult, error) {
+	if l.sent {
+		lookup := url.Values{}
+		lookup.Set("origClientOrderId", l.listClientOrderID)
+		res, err := l.client.sendOnce("GET", "/api/v3/orderList", lookup, binanceAuthSigned)
+		switch {
+		case err != nil:
+			return nil, err
+		case res.StatusCode == http.StatusOK:
+			log.Printf("Order list %s was placed by an earlier attempt. Not sending it again.", l.listClientOrderID)
+			return res, nil
+		case newBinanceAPIError(res).Code != binanceNoSuchOrderErrorCode:
+			return res, nil
+		}
+	}
+	l.sent = true
+	return l.client.sendOnce("POST", "/api/v3/order/oco", l.params, binanceAuthSigned)
+}
+
+// GetOrderList queries an order list and the status of each of its orders
+func (c *BinanceClient) GetOrderList(symbol, listID string) (*OrderList, error) {
+	params := url.Values{}
+	params.Set("orderListId", listID)
+
+	body, err := c.sendSigned("GET", "/api/v3/orderList", params)
+	if err != nil {
+		return nil, err
+	}
+	list, err := parseOrderListResponse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, order := range list.Orders {
+		status, err := c.GetOrder(symbol, order.OrderID)
+		if err != nil {
+			return nil, fmt.Errorf("error getting order %s of list %s: %v", order.OrderID, listID, err)
+		}
+		list.Orders[i] = status
+	}
+	return list, nil
+}
+
+// CancelOrderList cancels every open order of an order list
+func (c *BinanceClient) CancelOrderList(symbol, listID string) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderListId", listID)
+
+	_, err := c.sendSigned("DELETE", "/api/v3/orderList", params)
+	return err
+}
+
+// parseOrderListResponse converts the body of an order list endpoint response
+func parseOrderListResponse(body []byte) (*OrderList, error) {
+	var listResp orderListResponse
+	if err := json.Unmarshal(body, &listResp); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	return listResp.toOrderList(), nil
+}
+
+// manageOCOExit places the run's stop-loss and take-profit as one OCO sell order resting on the exchange and
+// tracks its order list until a leg executes. The order keeps protecting the position while the process is
+// down, and a resumed run continues tracking it.
+func manageOCOExit(ctx context.Context, client ExchangeClient, state *RunState, statePath string, entry float64, quantity Decimal) {
+	cfg := state.Config
+	placer, ok := baseClient(client).(OCOPlacer)
+	if !ok {
+		log.Printf("OCO orders are not supported for this exchange. Skipping exit management.")
+		state.ExitCompleted = true
+		return
+	}
+
+	if state.ExitOrderListID == "" {
+		req := OCORequest{
+			Symbol:            cfg.Symbol,
+			Side:              "SELL",
+			Quantity:          quantity,
+			Price:             cfg.Filters.RoundPrice(NewDecimalFromFloat(entry*(1+cfg.Exit.TakeProfitPct/100)), "SELL"),
+			StopPrice:         NewDecimalFromFloat(entry * (1 - cfg.Exit.StopLossPct/100)).FloorToStep(cfg.Filters.TickSize),
+			ListClientOrderID: state.RunID + "-exit",
+		}
+		if err := cfg.Filters.ValidateOrder(quantity, req.StopPrice); err != nil {
+			log.Printf("Cannot place OCO exit order: %v", err)
+			state.ExitCompleted = true
+			return
+		}
+		list, err := placer.PlaceOCOOrder(req)
+		if err != nil {
+			log.Printf("Error placing OCO exit order: %v. Fix the cause and resume exit management with -resume.", err)
+			return
+		}
+		state.ExitOrderListID = list.ListID
+		state.save(statePath)
+		slog.Info("OCO exit order placed", "symbol", cfg.Symbol, "list_id", list.ListID, "qty", quantity,
+			"take_profit", req.Price, "stop", req.StopPrice, "entry", entry)
+	}
+
+	for {
+		list, err := placer.GetOrderList(cfg.Symbol, state.ExitOrderListID)
+		if err != nil {
+			log.Printf("Error getting OCO exit order %s: %v", state.ExitOrderListID, err)
+		} else if list.Done() {
+			for _, order := range list.Orders {
+				if decimalOrZero(order.ExecutedQty).Sign() > 0 {
+					slog.Info("OCO exit order executed", "symbol", cfg.Symbol, "order_id", order.OrderID, "type", order.Type,
+						"status", order.Status, "qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty)
+					state.journalOrder(order)
+					state.audit(AuditExit, "reason", "oco", "order_id", order.OrderID, "type", order.Type, "status", order.Status,
+						"qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty)
+				}
+			}
+			break
+		}
+		if !sleepContext(ctx, cfg.Exit.PollInterval) {
+			log.Printf("Exit management interrupted. OCO order %s stays open on the exchange. Resume tracking it with -resume.", state.ExitOrderListID)
+			return
+		}
+	}
+	state.ExitCompleted = true
+}
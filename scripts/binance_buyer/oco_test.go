@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPlaceOCOOrderResend places an OCO order on a server that accepts the first attempt but fails its response,
+// and checks that the resend finds the list by its list client order ID instead of placing a second one
+func TestPlaceOCOOrderResend(t *testing.T) {
+	var mu sync.Mutex
+	placed := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		listClientOrderID := r.URL.Query().Get("listClientOrderId")
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/api/v3/order/oco":
+			placed[listClientOrderID]++
+			if placed[listClientOrderID] == 1 {
+				writeMockError(w, http.StatusServiceUnavailable, binanceDisconnectedErrorCode, "Internal error; unable to process your request. Please try again.")
+				return
+			}
+			writeMockError(w, http.StatusBadRequest, binanceOrderRejectedErrorCode, "Duplicate order sent.")
+		case r.Method == "GET" && r.URL.Path == "/api/v3/orderList":
+			id := r.URL.Query().Get("origClientOrderId")
+			if placed[id] == 0 {
+				writeMockError(w, http.StatusBadRequest, binanceNoSuchOrderErrorCode, "Order list does not exist.")
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]any{"orderListId": 7, "listClientOrderId": id, "symbol": "BTCUSDT", "listOrderStatus": OrderListStatusExecuting})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	signer, err := NewSigner("hmac", "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewBinanceClient("mock", signer)
+	client.retryPolicy = RetryPolicy{MaxAttempts: 5, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}
+	if err := client.SetEndpoints([]string{server.URL}, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := client.PlaceOCOOrder(OCORequest{
+		Symbol:    "BTCUSDT",
+		Side:      "SELL",
+		Quantity:  decimalOrZero("0.001"),
+		Price:     decimalOrZero("55000"),
+		StopPrice: decimalOrZero("45000"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.ListID != "7" || list.ListClientOrderID == "" {
+		t.Errorf("got list %s with client order ID %q, want list 7 with a generated ID", list.ListID, list.ListClientOrderID)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(placed) != 1 || placed[list.ListClientOrderID] != 1 {
+		t.Errorf("placed %v, want the list placed once", placed)
+	}
+}
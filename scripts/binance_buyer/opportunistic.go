@@ -0,0 +1,44 @@
+package main
+
+import (
+	"log"
+	"math"
+)
+
+// OpportunisticSizing scales slices by how far the price is from the rolling average of the prices sampled at
+// the run's last Window slices: a BUY run trades more while the price is below the average and less while it
+// is above, and a SELL run the other way round. Sensitivity is the multiplier change per unit of relative
+// deviation, so 100 doubles a slice at a 1% dip, and the multiplier is bounded to [Min, Max]. A zero Window
+// is disabled.
+type OpportunisticSizing struct {
+	Window      int     `json:"window"`
+	Sensitivity float64 `json:"sensitivity"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+}
+
+// Enabled reports whether slices are scaled
+func (o OpportunisticSizing) Enabled() bool {
+	return o.Window > 0
+}
+
+// multiplier returns the slice multiplier of side at price against the rolling average
+func (o OpportunisticSizing) multiplier(side string, price, average float64) float64 {
+	deviation := (average - price) / average
+	if side == "SELL" {
+		deviation = -deviation
+	}
+	return math.Max(o.Min, math.Min(o.Max, 1+o.Sensitivity*deviation))
+}
+
+// opportunisticMultiplier returns the multiplier of the next slice at the last sampled price, which is 1
+// until Window prices have been sampled since the run was started or resumed
+func (s *RunState) opportunisticMultiplier() float64 {
+	if s.priceAverage == nil || !s.priceAverage.Ready() || s.LastPrice <= 0 {
+		return 1
+	}
+	average := s.priceAverage.Value()
+	m := s.Config.Opportunistic.multiplier(s.Config.Side, s.LastPrice, average)
+	log.Printf("Price %.8f vs rolling average %.8f. Slice scaled by %.2f.", s.LastPrice, average, m)
+	return m
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Backtest objectives the best parameters are chosen by
+const (
+	// ObjectiveReturn ranks parameters by their return
+	ObjectiveReturn = "return"
+	// ObjectiveReturnDrawdown ranks parameters by their return divided by their maximum drawdown (at least 1%)
+	ObjectiveReturnDrawdown = "return_drawdown"
+)
+
+// WalkForwardConfig splits a backtest period into rolling windows. Each window optimizes the parameters on its
+// training period and tests the best ones on the test period that follows, then the windows move on by the
+// test period.
+type WalkForwardConfig struct {
+	// Train and Test are the lengths of the periods (e.g., 90D and 30D)
+	Train string `json:"train"`
+	Test  string `json:"test"`
+}
+
+// SweepParams are the values of the swept parameters of one backtest, by name
+type SweepParams map[string]float64
+
+// String returns the parameters as name=value pairs sorted by name
+func (p SweepParams) String() string {
+	var pairs []string
+	for _, name := range slices.Sorted(maps.Keys(p)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%g", name, p[name]))
+	}
+	return strings.Join(pairs, " ")
+}
+
+// sweepCombinations returns every combination of the swept values
+func sweepCombinations(sweep map[string][]float64) []SweepParams {
+	combinations := []SweepParams{{}}
+	for _, name := range slices.Sorted(maps.Keys(sweep)) {
+		var next []SweepParams
+		for _, params := range combinations {
+			for _, value := range sweep[name] {
+				combination := maps.Clone(params)
+				combination[name] = value
+				next = append(next, combination)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// withParams returns a copy of the config with the parameters set in its strategy's section, e.g. lookback in
+// the momentum section
+func (c *StrategyConfig) withParams(params SweepParams) (*StrategyConfig, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	section := map[string]any{}
+	if raw, ok := fields[c.Strategy]; ok {
+		if err := json.Unmarshal(raw, &section); err != nil {
+			return nil, err
+		}
+	}
+	for name, value := range params {
+		section[name] = value
+	}
+	if fields[c.Strategy], err = json.Marshal(section); err != nil {
+		return nil, err
+	}
+	if data, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var swept StrategyConfig
+	if err := decoder.Decode(&swept); err != nil {
+		return nil, fmt.Errorf("invalid sweep of %s: %v", params, err)
+	}
+	return &swept, nil
+}
+
+// objectiveScore scores a result by the objective, higher being better
+func objectiveScore(result BacktestResult, objective string) float64 {
+	if objective == ObjectiveReturnDrawdown {
+		return result.ReturnPct / math.Max(result.MaxDrawdownPct, 1)
+	}
+	return result.ReturnPct
+}
+
+// sweepWindow backtests every config on the candles opened between start and end, spread over workers
+// goroutines, and returns the results in the order of the configs
+func sweepWindow(configs []*StrategyConfig, candles []Candle, start, end time.Time, workers int) ([]BacktestResult, error) {
+	results := make([]BacktestResult, len(configs))
+	errs := make([]error, len(configs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(configs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				strategy, err := configs[i].newStrategy()
+				if err != nil {
+					errs[i] = err
+					continue
+				}
+				results[i], _ = backtestWindow(strategy, candles, start, end, configs[i].Backtest, configs[i].Position)
+				if stopper, ok := strategy.(interface{ Stop() }); ok {
+					stopper.Stop()
+				}
+			}
+		}()
+	}
+	for i := range configs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// walkForwardWindow is the outcome of one walk-forward window
+type walkForwardWindow struct {
+	TrainStart, TestStart, TestEnd time.Time
+	Best                           SweepParams
+	InSample, OutOfSample          BacktestResult
+	// Rank is the share of parameter sets that did worse out of sample than the best in-sample set, so 0.5 and
+	// below means the optimization picked no better than chance
+	Rank float64
+}
+
+// runOptimize sweeps the config's parameters over its backtest period, walking forward through rolling windows
+// when the config sets them, and prints the results
+func runOptimize(client ExchangeClient, cfg *StrategyConfig, workers int) error {
+	bt := cfg.Backtest
+	if len(bt.Sweep) == 0 {
+		return fmt.Errorf("optimizing needs a sweep in the backtest section, e.g. {\"lookback\": [10, 20, 30]}")
+	}
+	for name, values := range bt.Sweep {
+		if len(values) == 0 {
+			return fmt.Errorf("sweep of %s has no values", name)
+		}
+	}
+	objective := bt.Objective
+	if objective == "" {
+		objective = ObjectiveReturn
+	}
+	if objective != ObjectiveReturn && objective != ObjectiveReturnDrawdown {
+		return fmt.Errorf("invalid objective: %s. Use return or return_drawdown", objective)
+	}
+	if workers < 1 {
+		return fmt.Errorf("-workers must be at least 1")
+	}
+	start, end, err := backtestPeriod(bt)
+	if err != nil {
+		return err
+	}
+
+	combinations := sweepCombinations(bt.Sweep)
+	configs := make([]*StrategyConfig, len(combinations))
+	warmup := 0
+	for i, params := range combinations {
+		if configs[i], err = cfg.withParams(params); err != nil {
+			return err
+		}
+		strategy, err := configs[i].newStrategy()
+		if err != nil {
+			return fmt.Errorf("sweep %s: %v", params, err)
+		}
+		warmup = max(warmup, strategy.Warmup())
+	}
+	candles, err := backtestCandles(client, cfg, start, end, warmup)
+	if err != nil {
+		return err
+	}
+
+	if bt.WalkForward == nil {
+		results, err := sweepWindow(configs, candles, start, end, workers)
+		if err != nil {
+			return err
+		}
+		order := make([]int, len(results))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return objectiveScore(results[order[a]], objective) > objectiveScore(results[order[b]], objective)
+		})
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PARAMETERS\tRETURN\tMAX DRAWDOWN\tTRADES\tWIN RATE")
+		for _, i := range order {
+			r := results[i]
+			fmt.Fprintf(w, "%s\t%+.2f%%\t%.2f%%\t%d\t%.1f%%\n", combinations[i], r.ReturnPct, r.MaxDrawdownPct, len(r.Trades), r.WinRate())
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("\n%d parameter set(s) of %s on %s %s, %s to %s, ranked by %s\n", len(configs), cfg.Strategy, cfg.Symbol,
+			cfg.Interval, start.Format(time.DateOnly), end.Format(time.DateOnly), objective)
+		return nil
+	}
+
+	train, err := parseDuration(bt.WalkForward.Train)
+	if err != nil {
+		return fmt.Errorf("invalid walk_forward train: %v", err)
+	}
+	test, err := parseDuration(bt.WalkForward.Test)
+	if err != nil {
+		return fmt.Errorf("invalid walk_forward test: %v", err)
+	}
+	var windows []walkForwardWindow
+	for t := start; !t.Add(train + test).After(end); t = t.Add(test) {
+		window := walkForwardWindow{TrainStart: t, TestStart: t.Add(train), TestEnd: t.Add(train + test)}
+		inSample, err := sweepWindow(configs, candles, window.TrainStart, window.TestStart, workers)
+		if err != nil {
+			return err
+		}
+		outOfSample, err := sweepWindow(configs, candles, window.TestStart, window.TestEnd, workers)
+		if err != nil {
+			return err
+		}
+		best := 0
+		for i := range inSample {
+			if objectiveScore(inSample[i], objective) > objectiveScore(inSample[best], objective) {
+				best = i
+			}
+		}
+		window.Best, window.InSample, window.OutOfSample = combinations[best], inSample[best], outOfSample[best]
+		bestScore, worse := objectiveScore(outOfSample[best], objective), 0
+		for _, r := range outOfSample {
+			if objectiveScore(r, objective) < bestScore {
+				worse++
+			}
+		}
+		if len(outOfSample) > 1 {
+			window.Rank = float64(worse) / float64(len(outOfSample)-1)
+		} else {
+			window.Rank = 1
+		}
+		windows = append(windows, window)
+	}
+	if len(windows) == 0 {
+		return fmt.Errorf("the backtest period is shorter than one walk-forward window of %s + %s", bt.WalkForward.Train, bt.WalkForward.Test)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TRAIN\tTEST\tBEST PARAMETERS\tIN-SAMPLE\tOUT-OF-SAMPLE\tOOS DRAWDOWN\tOOS TRADES\tOOS RANK")
+	compounded, inSampleSum, outOfSampleSum := 1.0, 0.0, 0.0
+	profitable, overfit := 0, 0
+	distinct := map[string]bool{}
+	for _, window := range windows {
+		fmt.Fprintf(w, "%s..%s\t%s..%s\t%s\t%+.2f%%\t%+.2f%%\t%.2f%%\t%d\t%.0f%%\n",
+			window.TrainStart.Format(time.DateOnly), window.TestStart.Format(time.DateOnly),
+			window.TestStart.Format(time.DateOnly), window.TestEnd.Format(time.DateOnly), window.Best,
+			window.InSample.ReturnPct, window.OutOfSample.ReturnPct, window.OutOfSample.MaxDrawdownPct,
+			len(window.OutOfSample.Trades), window.Rank*100)
+		compounded *= 1 + window.OutOfSample.ReturnPct/100
+		inSampleSum += window.InSample.ReturnPct
+		outOfSampleSum += window.OutOfSample.ReturnPct
+		if window.OutOfSample.ReturnPct > 0 {
+			profitable++
+		}
+		if window.Rank <= 0.5 {
+			overfit++
+		}
+		distinct[window.Best.String()] = true
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	n := float64(len(windows))
+	fmt.Printf("\nWalk-forward of %s on %s %s: %d window(s) of %s train + %s test, %d parameter set(s) ranked by %s\n",
+		cfg.Strategy, cfg.Symbol, cfg.Interval, len(windows), bt.WalkForward.Train, bt.WalkForward.Test, len(configs), objective)
+	fmt.Printf("Out-of-sample return: %+.2f%% compounded, %+.2f%% per window (in-sample %+.2f%%)\n",
+		(compounded-1)*100, outOfSampleSum/n, inSampleSum/n)
+	if inSampleSum > 0 {
+		// Returns per day make windows of different lengths comparable
+		efficiency := (outOfSampleSum / test.Hours()) / (inSampleSum / train.Hours())
+		fmt.Printf("Walk-forward efficiency: %.2f (out-of-sample over in-sample return per day; below 0.5 suggests overfitting)\n", efficiency)
+	} else {
+		fmt.Printf("Walk-forward efficiency: n/a (no in-sample profit)\n")
+	}
+	fmt.Printf("Profitable test windows: %d/%d\n", profitable, len(windows))
+	fmt.Printf("Probability of overfitting: %.0f%% (windows whose best in-sample parameters ranked in the lower half out of sample)\n", float64(overfit)/n*100)
+	fmt.Printf("Parameter stability: %d distinct best parameter set(s) over %d window(s)\n", len(distinct), len(windows))
+	return nil
+}
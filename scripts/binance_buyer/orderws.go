@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Transports orders are placed and cancelled over
+const (
+	OrderTransportREST = "rest"
+	OrderTransportWS   = "ws"
+)
+
+const (
+	// wsAPIResponseTimeout is how long a WebSocket API response is awaited before the request's outcome is
+	// considered unknown
+	wsAPIResponseTimeout = 10 * time.Second
+	// wsAPIReadTimeout is how long the connection may stay silent, including the server's pings every 20 seconds,
+	// before it is considered dead
+	wsAPIReadTimeout = time.Minute
+	// wsAPIConnectWeight is the request weight of opening a WebSocket API connection
+	wsAPIConnectWeight = 2
+)
+
+var (
+	// errWSAPINotSent is returned when a request could not be sent, so it can be sent over REST instead
+	errWSAPINotSent = errors.New("websocket API request not sent")
+	// errWSAPINoResponse is returned when a request that may have been sent got no response, so it may or may not
+	// have been executed
+	errWSAPINoResponse = errors.New("no websocket API response")
+)
+
+// wsAPIResponse is the response to a WebSocket API request, correlated with it by ID
+type wsAPIResponse struct {
+	ID     string           `json:"id"`
+	Status int              `json:"status"`
+	Result json.RawMessage  `json:"result"`
+	Error  *BinanceAPIError `json:"error"`
+}
+
+// BinanceWSAPI sends signed requests over a persistent connection to the Binance WebSocket API, saving the
+// connection setup a REST request pays on every order
+type BinanceWSAPI struct {
+	client *BinanceClient
+
+	mu      sync.Mutex
+	conn    *wsConn
+	pending map[string]chan wsAPIResponse
+	nextID  int64
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewBinanceWSAPI creates a WebSocket API connection for the client's account
+func NewBinanceWSAPI(client *BinanceClient) *BinanceWSAPI {
+	return &BinanceWSAPI{
+		client:  client,
+		pending: map[string]chan wsAPIResponse{},
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start connects in the background
+func (a *BinanceWSAPI) Start() {
+	go a.run()
+}
+
+// Stop closes the connection and waits for the background goroutine to exit
+func (a *BinanceWSAPI) Stop() {
+	close(a.stop)
+	<-a.done
+}
+
+// run keeps the connection open until it is stopped
+func (a *BinanceWSAPI) run() {
+	defer close(a.done)
+	keepConnected("WebSocket API", a.stop, a.consume)
+}
+
+// consume connects and delivers responses to the requests awaiting them until the connection fails, reporting
+// whether it connected at all. Requests still awaiting a response when it fails are told none will come.
+func (a *BinanceWSAPI) consume() (bool, error) {
+	a.client.limiter.Acquire(wsAPIConnectWeight)
+	conn, err := dialWebSocket(a.client.wsAPIURL, 10*time.Second)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	defer closeOnStop(conn, a.stop)()
+
+	a.mu.Lock()
+	a.conn = conn
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.conn = nil
+		for id, ch := range a.pending {
+			close(ch)
+			delete(a.pending, id)
+		}
+	}()
+
+	log.Printf("WebSocket API connected")
+	conn.SetReadTimeout(wsAPIReadTimeout)
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		var res wsAPIResponse
+		if err := json.Unmarshal(message, &res); err != nil {
+			log.Printf("Error parsing WebSocket API response: %v", err)
+			continue
+		}
+		a.mu.Lock()
+		if ch, ok := a.pending[res.ID]; ok {
+			ch <- res
+			delete(a.pending, res.ID)
+		}
+		a.mu.Unlock()
+	}
+}
+
+// request signs and sends a request and waits for its result. It fails with errWSAPINotSent when there is no
+// connection to send it over, and with errWSAPINoResponse when sending it failed or no response came back.
+func (a *BinanceWSAPI) request(method string, params url.Values) (json.RawMessage, error) {
+	a.mu.Lock()
+	conn := a.conn
+	a.nextID++
+	id := strconv.FormatInt(a.nextID, 10)
+	ch := make(chan wsAPIResponse, 1)
+	if conn != nil {
+		a.pending[id] = ch
+	}
+	a.mu.Unlock()
+	if conn == nil {
+		return nil, errWSAPINotSent
+	}
+	abandon := func() {
+		a.mu.Lock()
+		delete(a.pending, id)
+		a.mu.Unlock()
+	}
+
+	signed, err := a.sign(params)
+	if err != nil {
+		abandon()
+		return nil, err
+	}
+	message, err := json.Marshal(map[string]any{"id": id, "method": method, "params": signed})
+	if err != nil {
+		abandon()
+		return nil, fmt.Errorf("error encoding request: %v", err)
+	}
+	a.client.limiter.Acquire(1)
+	// A failed write may still have delivered the request, so its outcome is as unknown as a missing response
+	if err := conn.WriteText(message); err != nil {
+		abandon()
+		return nil, fmt.Errorf("%w: error sending request: %v", errWSAPINoResponse, err)
+	}
+
+	select {
+	case res, ok := <-ch:
+		if !ok {
+			return nil, fmt.Errorf("%w: connection closed", errWSAPINoResponse)
+		}
+		if res.Status != 200 {
+			if res.Error == nil {
+				res.Error = &BinanceAPIError{}
+			}
+			res.Error.StatusCode = res.Status
+			return nil, res.Error
+		}
+		return res.Result, nil
+	case <-time.After(wsAPIResponseTimeout):
+		abandon()
+		return nil, fmt.Errorf("%w after %s", errWSAPINoResponse, wsAPIResponseTimeout)
+	}
+}
+
+// sign adds the API key, timestamp and signature to a request's parameters. Unlike REST, the signed payload
+// is the parameters sorted by name with their values as they are, not URL encoded.
+func (a *BinanceWSAPI) sign(params url.Values) (map[string]string, error) {
+	apiKey := a.client.key.Load()
+	params.Set("apiKey", apiKey.apiKey)
+	params.Set("timestamp", a.client.serverTimestamp())
+	params.Set("recvWindow", "5000")
+	keys := slices.Sorted(func(yield func(string) bool) {
+		for key := range params {
+			if !yield(key) {
+				return
+			}
+		}
+	})
+	signed := map[string]string{}
+	var payload []string
+	for _, key := range keys {
+		signed[key] = params.Get(key)
+		payload = append(payload, key+"="+params.Get(key))
+	}
+	signature, err := apiKey.signer.Sign(strings.Join(payload, "&"))
+	if err != nil {
+		return nil, err
+	}
+	signed["signature"] = signature
+	return signed, nil
+}
+
+// wsOrderClient places and cancels spot orders over the WebSocket API, falling back to REST when the connection
+// is down or the exchange asks for the request to be retried
+type wsOrderClient struct {
+	ExchangeClient
+	rest *BinanceClient
+	api  *BinanceWSAPI
+}
+
+// PlaceOrder places an order over the WebSocket API. An order that was sent without a response is looked up by
+// its client order ID over REST before being sent again, so it cannot be placed twice.
+func (c *wsOrderClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	if isStopLimit(req.Type) {
+		if err := req.validateStopLimit(); err != nil {
+			return nil, err
+		}
+	}
+	// An order the WebSocket API may have placed is only recovered over REST by its client order ID
+	if req.ClientOrderID == "" {
+		req.ClientOrderID = newClientOrderID()
+	}
+	params := spotOrderParams(req)
+	result, err := c.api.request("order.place", params)
+	if err == nil {
+		return parseOrderResponse(result)
+	}
+
+	switch fallback := wsAPIFallback(err); {
+	case fallback == wsAPIFallbackNone:
+		return nil, err
+	case fallback == wsAPIFallbackUnknown:
+		log.Printf("WebSocket API order %s failed (%v). Checking for it over REST.", req.ClientOrderID, err)
+		return c.rest.recoverOrder(req.Symbol, req.ClientOrderID, spotOrderParams(req))
+	default:
+		log.Printf("WebSocket API order failed (%v). Placing it over REST.", err)
+		return c.ExchangeClient.PlaceOrder(req)
+	}
+}
+
+// CancelOrder cancels an order over the WebSocket API, falling back to REST when it could not be cancelled there
+func (c *wsOrderClient) CancelOrder(symbol, orderID string) error {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("orderId", orderID)
+	_, err := c.api.request("order.cancel", params)
+	if err == nil || wsAPIFallback(err) == wsAPIFallbackNone {
+		return err
+	}
+	log.Printf("WebSocket API cancel of order %s failed (%v). Cancelling it over REST.", orderID, err)
+	return c.ExchangeClient.CancelOrder(symbol, orderID)
+}
+
+// Unwrap returns the wrapped client
+func (c *wsOrderClient) Unwrap() ExchangeClient {
+	return c.ExchangeClient
+}
+
+// Ways a failed WebSocket API request can fall back to REST
+const (
+	// wsAPIFallbackNone returns the error, as the exchange rejected the request
+	wsAPIFallbackNone = iota
+	// wsAPIFallbackResend sends the request over REST, as it was not executed
+	wsAPIFallbackResend
+	// wsAPIFallbackUnknown checks over REST whether the request was executed before sending it again
+	wsAPIFallbackUnknown
+)
+
+// wsAPIFallback classifies a failed WebSocket API request by how it can be retried over REST
+func wsAPIFallback(err error) int {
+	var apiErr *BinanceAPIError
+	switch {
+	case errors.Is(err, errWSAPINotSent):
+		return wsAPIFallbackResend
+	case errors.As(err, &apiErr) && apiErr.Code == binanceTimestampErrorCode:
+		return wsAPIFallbackResend
+	case errors.As(err, &apiErr) && apiErr.Retryable():
+		return wsAPIFallbackUnknown
+	case errors.As(err, &apiErr):
+		return wsAPIFallbackNone
+	default:
+		return wsAPIFallbackUnknown
+	}
+}
+
+// recoverOrder sends an order over REST after an attempt elsewhere may have placed it, looking it up by its
+// client order ID before every send
+func (c *BinanceClient) recoverOrder(symbol, clientOrderID string, params url.Values) (*Order, error) {
+	order := &idempotentOrder{client: c, symbol: symbol, clientOrderID: clientOrderID, params: params, sent: true}
+	body, err := c.resendOnTimestampError(func() (*httpResult, error) {
+		return doWithRetry(c.retryPolicy, order.attempt, isBinanceRetryable)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseOrderResponse(body)
+}
+
+// withWSOrders wraps the client so orders go over the WebSocket API when transport is ws. It returns the client
+// unchanged otherwise, or when the exchange has no WebSocket API support, along with a function that closes
+// the connection.
+func withWSOrders(client ExchangeClient, transport string) (ExchangeClient, func()) {
+	if transport != OrderTransportWS {
+		return client, func() {}
+	}
+	binance, ok := baseClient(client).(*BinanceClient)
+	if !ok || binance.wsAPIURL == "" {
+		log.Printf("WebSocket API orders are not supported for this exchange or market. Placing orders over REST.")
+		return client, func() {}
+	}
+
+	api := NewBinanceWSAPI(binance)
+	api.Start()
+	return &wsOrderClient{ExchangeClient: client, rest: binance, api: api}, api.Stop
+}
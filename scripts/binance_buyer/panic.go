@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// confirm asks a yes/no question on the terminal. Anything but yes, including a closed stdin, is no.
+func confirm(in io.Reader, question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// liquidate market-sells the free base balance of a spot or margin symbol, repaying margin debt with the
+// proceeds, or closes the futures position of a symbol with reduce-only orders. Quantities above the symbol's
+// maximum are sold in several orders.
+func liquidate(client ExchangeClient, market, symbol, quoteAsset string) error {
+	filters, err := client.GetSymbolFilters(symbol)
+	if err != nil {
+		return fmt.Errorf("error getting symbol filters for %s: %v", symbol, err)
+	}
+	side := "SELL"
+	var quantity Decimal
+	if market == MarketFutures {
+		futures, err := futuresClient(client)
+		if err != nil {
+			return err
+		}
+		position, err := futures.GetPosition(symbol)
+		if err != nil {
+			return fmt.Errorf("error getting %s position: %v", symbol, err)
+		}
+		quantity = position.Amount
+		if quantity.Sign() < 0 {
+			side, quantity = "BUY", quantity.Neg()
+		}
+	} else {
+		baseAsset := strings.TrimSuffix(symbol, quoteAsset)
+		if quantity, err = client.GetBalance(baseAsset); err != nil {
+			return fmt.Errorf("error getting %s balance: %v", baseAsset, err)
+		}
+	}
+	quantity = filters.RoundQuantity(quantity)
+	if quantity.IsZero() {
+		log.Printf("No %s position to liquidate", symbol)
+		return nil
+	}
+	price, err := client.GetPrice(symbol)
+	if err != nil {
+		return fmt.Errorf("error getting price for %s: %v", symbol, err)
+	}
+	if err := filters.ValidateOrder(quantity, NewDecimalFromFloat(price)); err != nil {
+		log.Printf("Cannot liquidate %s %s: %v", quantity, symbol, err)
+		return nil
+	}
+
+	for quantity.Sign() > 0 {
+		qty := quantity
+		if filters.MaxQty.Sign() > 0 {
+			qty = minDecimal(qty, filters.MaxQty)
+		}
+		order, err := client.PlaceOrder(OrderRequest{
+			Symbol:     symbol,
+			Side:       side,
+			Type:       OrderTypeMarket,
+			Quantity:   qty,
+			ReduceOnly: market == MarketFutures,
+			SideEffect: MarginSideEffectRepay,
+		})
+		if err != nil {
+			return fmt.Errorf("error liquidating %s %s: %v", qty, symbol, err)
+		}
+		log.Printf("Liquidated %s: %s order %s executed %s for %s %s", symbol, side, order.OrderID, order.ExecutedQty, order.CumQuoteQty, quoteAsset)
+		quantity = quantity.Sub(qty)
+	}
+	return nil
+}
+
+// runPanic cancels every open order of the given symbols and optionally liquidates their positions, for
+// emergency unwinds. Failures on one symbol do not stop the others.
+func runPanic(args []string) error {
+	fs, common := newFlagSet("panic")
+	symbols := fs.String("symbols", "BTCUSDT", "Comma-separated symbols to unwind (e.g., BTCUSDT,ETHUSDT)")
+	quoteAsset := fs.String("quote-asset", "USDT", "Quote asset the symbols trade against, which liquidation sells back to")
+	liquidatePositions := fs.Bool("liquidate", false, "Also market-sell the free base balance of each symbol, or close its position with -market futures")
+	yes := fs.Bool("yes", false, "Skip the confirmation prompt")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+	history, err := orderHistory(client, common.exchange)
+	if err != nil {
+		return err
+	}
+
+	var list []string
+	for _, symbol := range strings.Split(*symbols, ",") {
+		if symbol = strings.ToUpper(strings.TrimSpace(symbol)); symbol != "" {
+			list = append(list, symbol)
+		}
+	}
+	if len(list) == 0 {
+		return fmt.Errorf("no symbols given")
+	}
+	question := fmt.Sprintf("Cancel every open order of %s on %s", strings.Join(list, ", "), common.exchange)
+	if *liquidatePositions {
+		question += fmt.Sprintf(" and market-sell their positions back to %s", strings.ToUpper(*quoteAsset))
+	}
+	question += "?"
+	if !*yes && !confirm(os.Stdin, question) {
+		return fmt.Errorf("aborted")
+	}
+
+	var failed []string
+	for _, symbol := range list {
+		bindIsolatedSymbol(client, symbol)
+		// Orders are cancelled first, which releases the balance they lock for the liquidation. The free
+		// balance is still liquidated when some orders cannot be cancelled.
+		errs := []error{cancelOpenOrders(client, history, symbol)}
+		if *liquidatePositions {
+			errs = append(errs, liquidate(client, common.market, symbol, strings.ToUpper(*quoteAsset)))
+		}
+		if err := errors.Join(errs...); err != nil {
+			log.Printf("Error unwinding %s: %v", symbol, err)
+			failed = append(failed, symbol)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("could not fully unwind %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
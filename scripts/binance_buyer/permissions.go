@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// keyTradingExpiryWarning is how far ahead the expiry of an API key's trading permission is warned about
+const keyTradingExpiryWarning = 7 * 24 * time.Hour
+
+// APIRestrictions are the permissions Binance grants an API key
+type APIRestrictions struct {
+	IPRestrict                   bool `json:"ipRestrict"`
+	EnableReading                bool `json:"enableReading"`
+	EnableSpotAndMarginTrading   bool `json:"enableSpotAndMarginTrading"`
+	EnableMargin                 bool `json:"enableMargin"`
+	EnableFutures                bool `json:"enableFutures"`
+	EnableWithdrawals            bool `json:"enableWithdrawals"`
+	EnableInternalTransfer       bool `json:"enableInternalTransfer"`
+	PermitsUniversalTransfer     bool `json:"permitsUniversalTransfer"`
+	EnableVanillaOptions         bool `json:"enableVanillaOptions"`
+	EnablePortfolioMarginTrading bool `json:"enablePortfolioMarginTrading"`
+	// TradingAuthorityExpirationTime is when the trading permission of a key without IP restriction lapses, in
+	// milliseconds, or 0 when it does not
+	TradingAuthorityExpirationTime int64 `json:"tradingAuthorityExpirationTime"`
+}
+
+// Permissions of a Binance API key, named as on the API management page
+const (
+	PermissionReading           = "reading"
+	PermissionSpotMarginTrading = "spot & margin trading"
+	PermissionMarginLoans       = "margin loan, repay & transfer"
+	PermissionFutures           = "futures"
+	PermissionWithdrawals       = "withdrawals"
+	PermissionUniversalTransfer = "universal transfer"
+	PermissionInternalTransfer  = "internal transfer"
+	PermissionOptions           = "european options"
+	PermissionPortfolioMargin   = "portfolio margin trading"
+)
+
+// keyPermission is a permission of an API key and whether it is granted
+type keyPermission struct {
+	name    string
+	granted bool
+}
+
+// granted lists the permissions of a key in the order of the API management page, with whether each is granted
+func (r *APIRestrictions) granted() []keyPermission {
+	return []keyPermission{
+		{PermissionReading, r.EnableReading},
+		{PermissionSpotMarginTrading, r.EnableSpotAndMarginTrading},
+		{PermissionMarginLoans, r.EnableMargin},
+		{PermissionFutures, r.EnableFutures},
+		{PermissionWithdrawals, r.EnableWithdrawals},
+		{PermissionUniversalTransfer, r.PermitsUniversalTransfer},
+		{PermissionInternalTransfer, r.EnableInternalTransfer},
+		{PermissionOptions, r.EnableVanillaOptions},
+		{PermissionPortfolioMargin, r.EnablePortfolioMarginTrading},
+	}
+}
+
+// KeyPermissionChecker is implemented by clients that can read the permissions of their API key
+type KeyPermissionChecker interface {
+	GetAPIRestrictions() (*APIRestrictions, error)
+}
+
+// GetAPIRestrictions gets the permissions of the client's API key
+func (c *BinanceClient) GetAPIRestrictions() (*APIRestrictions, error) {
+	body, err := c.sendSigned("GET", "/sapi/v1/account/apiRestrictions", url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	var restrictions APIRestrictions
+	if err := json.Unmarshal(body, &restrictions); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	return &restrictions, nil
+}
+
+// GetAPIRestrictions gets the permissions of the margin account's API key
+func (c *BinanceMarginClient) GetAPIRestrictions() (*APIRestrictions, error) {
+	return c.rest.GetAPIRestrictions()
+}
+
+// GetAPIRestrictions gets the permissions of the futures account's API key through the spot API, which the futures
+// testnet does not have
+func (c *BinanceFuturesClient) GetAPIRestrictions() (*APIRestrictions, error) {
+	if c.spot == nil {
+		return nil, fmt.Errorf("API key permissions are not available on the futures testnet")
+	}
+	return c.spot.GetAPIRestrictions()
+}
+
+// requiredPermissions returns the permissions a run's API key needs: reading, trading on its market, and the
+// borrowing, transfers and withdrawals it is configured to make
+func requiredPermissions(cfg TWAPConfig) map[string]bool {
+	required := map[string]bool{PermissionReading: true}
+	switch cfg.Market {
+	case MarketFutures:
+		required[PermissionFutures] = true
+		if cfg.Futures.AutoTransferMax.Sign() > 0 {
+			required[PermissionUniversalTransfer] = true
+		}
+	case MarketMargin, MarketIsolatedMargin:
+		required[PermissionSpotMarginTrading] = true
+		if cfg.SideEffect != "" && cfg.SideEffect != MarginSideEffectNone {
+			required[PermissionMarginLoans] = true
+		}
+	default:
+		required[PermissionSpotMarginTrading] = true
+	}
+	if cfg.Withdraw.Enabled() {
+		required[PermissionWithdrawals] = true
+	}
+	return required
+}
+
+// checkKeyPermissions verifies before a run starts that the API key of each of its accounts has the permissions
+// the run needs, failing fast instead of on the first order. Permissions beyond those are warned about, as is a key
+// usable from any IP address. Exchanges, and testnets, without permission details are not checked.
+func checkKeyPermissions(client ExchangeClient, cfg TWAPConfig) error {
+	if cfg.Testnet {
+		return nil
+	}
+	accounts := []*tradingAccount{{client: client}}
+	if multi, ok := client.(*multiAccountClient); ok {
+		accounts = multi.accounts
+	}
+
+	required := requiredPermissions(cfg)
+	for _, account := range accounts {
+		label := "API key"
+		if account.Name != "" {
+			label = "API key of account " + account.Name
+		}
+		checker, ok := baseClient(account.client).(KeyPermissionChecker)
+		if !ok {
+			log.Printf("Checking API key permissions is not supported on %s", cfg.Exchange)
+			return nil
+		}
+		restrictions, err := checker.GetAPIRestrictions()
+		var apiErr *BinanceAPIError
+		if errors.As(err, &apiErr) {
+			return fmt.Errorf("error checking %s permissions: %v", label, err)
+		}
+		if err != nil {
+			log.Printf("Error checking %s permissions, continuing without the check: %v", label, err)
+			continue
+		}
+
+		var missing, extra []string
+		for _, permission := range restrictions.granted() {
+			switch {
+			case required[permission.name] && !permission.granted:
+				missing = append(missing, permission.name)
+			case !required[permission.name] && permission.granted:
+				extra = append(extra, permission.name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%s lacks the %s permission(s) this run needs. Enable them in the API management page", label,
+				strings.Join(missing, ", "))
+		}
+		if len(extra) > 0 {
+			log.Printf("Warning: %s has permissions this run does not need: %s. Consider a key limited to %s", label,
+				strings.Join(extra, ", "), strings.Join(neededNames(restrictions, required), ", "))
+		}
+		if !restrictions.IPRestrict {
+			log.Printf("Warning: %s is not restricted to trusted IP addresses", label)
+		}
+		if expiry := restrictions.TradingAuthorityExpirationTime; expiry > 0 && time.Until(time.UnixMilli(expiry)) < keyTradingExpiryWarning {
+			log.Printf("Warning: the trading permission of the %s expires at %s. Restrict the key to trusted IP addresses to keep it",
+				label, time.UnixMilli(expiry).UTC().Format(time.RFC3339))
+		}
+		log.Printf("%s has the permissions this run needs", label)
+	}
+	return nil
+}
+
+// neededNames returns the names of the required permissions in the order of the API management page
+func neededNames(restrictions *APIRestrictions, required map[string]bool) []string {
+	var names []string
+	for _, permission := range restrictions.granted() {
+		if required[permission.name] {
+			names = append(names, permission.name)
+		}
+	}
+	return names
+}
+
+// runKeyPermissions shows the permissions of the API key, marking those an exec run of the given market needs, or
+// only reading in read-only mode
+func runKeyPermissions(args []string) error {
+	fs, common := newFlagSet("permissions")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	client, err := common.readOnlyClient()
+	if err != nil {
+		return err
+	}
+	restrictions, err := client.GetAPIRestrictions()
+	if err != nil {
+		return fmt.Errorf("error getting API key permissions: %v", err)
+	}
+
+	required := requiredPermissions(TWAPConfig{Market: common.market})
+	if common.readOnly {
+		required = map[string]bool{PermissionReading: true}
+	}
+	fmt.Printf("%-32s %-8s %s\n", "PERMISSION", "GRANTED", "NEEDED")
+	for _, permission := range restrictions.granted() {
+		granted, needed := "no", ""
+		if permission.granted {
+			granted = "yes"
+		}
+		if required[permission.name] {
+			needed = "yes"
+		}
+		fmt.Printf("%-32s %-8s %s\n", permission.name, granted, needed)
+	}
+	fmt.Printf("\nIP restricted: %t\n", restrictions.IPRestrict)
+	if restrictions.TradingAuthorityExpirationTime > 0 {
+		fmt.Printf("Trading permission expires: %s\n", time.UnixMilli(restrictions.TradingAuthorityExpirationTime).UTC().Format(time.RFC3339))
+	}
+	return nil
+}
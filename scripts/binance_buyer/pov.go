@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// parseParticipation parses a participation rate such as "5%" or "5" into a percentage
+func parseParticipation(spec string) (float64, error) {
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(spec), "%"), 64)
+	if err != nil || pct <= 0 || pct > 100 {
+		return 0, fmt.Errorf("invalid participation %q: use a percentage above 0 and up to 100, e.g. 5%%", spec)
+	}
+	return pct, nil
+}
+
+// participationCap returns how much of the run's budget the next order may use under a POV run's
+// participation rate: that share of the volume the market traded since the run's previous order, in the
+// budget asset
+func (s *RunState) participationCap() Decimal {
+	traded := s.benchmarkFeed.Benchmark()
+	volume := traded.Notional - s.participationMark.Notional
+	if s.Config.BaseAmount {
+		volume = traded.Volume - s.participationMark.Volume
+	}
+	return NewDecimalFromFloat(volume * s.Config.Participation / 100)
+}
+
+// markParticipation starts measuring the volume the next order may participate in from now
+func (s *RunState) markParticipation() {
+	s.participationMark = s.benchmarkFeed.Benchmark()
+}
+
+// extendPOV appends a slice to a POV run that has reached the end of its planned run time with budget left,
+// because the market traded too little for it to keep pace
+func (s *RunState) extendPOV() {
+	if s.NextSlice < s.TotalSlices || s.Remaining.LessThan(s.MinSlice) {
+		return
+	}
+	if s.TotalSlices == s.plannedSlices() {
+		log.Printf("Market volume too low to finish in the planned run time. Extending the run until %s %s is traded.", s.Remaining, s.Config.budgetAsset())
+	}
+	s.TotalSlices++
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// defaultPresetsFile is the presets file read when neither -presets nor PRESETS_FILE is set
+const defaultPresetsFile = "binance_buyer_presets.json"
+
+// Preset is a named set of trade exec flag values, keyed by flag name without the dash
+type Preset map[string]any
+
+// loadPresets reads a presets file mapping preset names to flag values, e.g.
+// {"weekly-btc-dca": {"symbol": "BTCUSDT", "total-amount": 100, "total-run-time": "2H", "algo": "twap", "max-price": 70000}}
+func loadPresets(path string) (map[string]Preset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading presets: %v", err)
+	}
+	var presets map[string]Preset
+	if err := json.Unmarshal(data, &presets); err != nil {
+		return nil, fmt.Errorf("error parsing presets: %v", err)
+	}
+	return presets, nil
+}
+
+// args returns the preset's flag values as arguments in flag name order, failing on a value that is not a string,
+// number or boolean or on a flag fs does not define
+func (p Preset) args(fs *flag.FlagSet) ([]string, error) {
+	var args []string
+	for _, name := range slices.Sorted(maps.Keys(p)) {
+		if name == "preset" || name == "presets" || name == "resume" {
+			return nil, fmt.Errorf("presets cannot set -%s", name)
+		}
+		if fs.Lookup(name) == nil {
+			return nil, fmt.Errorf("unknown flag -%s", name)
+		}
+		var value string
+		switch v := p[name].(type) {
+		case string:
+			value = v
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			value = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("-%s must be a string, number or boolean", name)
+		}
+		args = append(args, "-"+name+"="+value)
+	}
+	return args, nil
+}
+
+// flagValue returns the value args give the named flag, the last one when it is given more than once
+func flagValue(args []string, name string) string {
+	var value string
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		arg = strings.TrimLeft(arg, "-")
+		if v, ok := strings.CutPrefix(arg, name+"="); ok {
+			value = v
+		} else if arg == name && i+1 < len(args) {
+			value = args[i+1]
+		}
+	}
+	return value
+}
+
+// applyPreset puts the flag values of the preset named by -preset in front of args, so flags given on the command
+// line override them. The preset is read from the -presets file, or PRESETS_FILE.
+func applyPreset(fs *flag.FlagSet, args []string) ([]string, error) {
+	name := flagValue(args, "preset")
+	if name == "" {
+		return args, nil
+	}
+	path := flagValue(args, "presets")
+	if path == "" {
+		path = fs.Lookup("presets").DefValue
+	}
+	presets, err := loadPresets(path)
+	if err != nil {
+		return nil, err
+	}
+	preset, ok := presets[name]
+	if !ok {
+		return nil, fmt.Errorf("no preset named %s in %s", name, path)
+	}
+	presetArgs, err := preset.args(fs)
+	if err != nil {
+		return nil, fmt.Errorf("preset %s: %v", name, err)
+	}
+	return append(presetArgs, args...), nil
+}
+
+// runPresets lists the presets of a presets file with their flag values
+func runPresets(args []string) error {
+	fs := flag.NewFlagSet("presets", flag.ExitOnError)
+	path := fs.String("presets", cmp.Or(os.Getenv("PRESETS_FILE"), defaultPresetsFile), "Presets file mapping preset names to trade exec flag values (default from PRESETS_FILE)")
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+	presets, err := loadPresets(*path)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PRESET\tFLAGS")
+	for _, name := range slices.Sorted(maps.Keys(presets)) {
+		var flags []string
+		for _, flagName := range slices.Sorted(maps.Keys(presets[name])) {
+			flags = append(flags, fmt.Sprintf("-%s=%v", flagName, presets[name][flagName]))
+		}
+		fmt.Fprintf(w, "%s\t%s\n", name, strings.Join(flags, " "))
+	}
+	return w.Flush()
+}
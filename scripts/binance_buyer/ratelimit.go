@@ -0,0 +1,148 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// binanceWeightLimit is the request weight Binance allows per IP per minute
+const binanceWeightLimit = 6000
+
+// binanceEndpointWeights holds the published request weight of the endpoints used by the client
+var binanceEndpointWeights = map[string]int{
+	"GET /api/v3/account":                  20,
+	"GET /api/v3/exchangeInfo":             20,
+	"GET /api/v3/ticker/price":             2,
+	"GET /api/v3/ticker/bookTicker":        2,
+	"GET /api/v3/time":                     1,
+	"GET /api/v3/klines":                   2,
+	"GET /api/v3/depth":                    5,
+	"POST /api/v3/order":                   1,
+	"GET /api/v3/order":                    4,
+	"DELETE /api/v3/order":                 1,
+	"GET /api/v3/openOrders":               6,
+	"GET /api/v3/myTrades":                 20,
+	"POST /api/v3/order/oco":               1,
+	"GET /api/v3/orderList":                4,
+	"DELETE /api/v3/orderList":             1,
+	"POST /api/v3/userDataStream":          2,
+	"PUT /api/v3/userDataStream":           2,
+	"DELETE /api/v3/userDataStream":        2,
+	"GET /fapi/v1/exchangeInfo":            1,
+	"GET /fapi/v1/ticker/price":            1,
+	"GET /fapi/v1/ticker/bookTicker":       2,
+	"GET /fapi/v1/time":                    1,
+	"GET /fapi/v1/klines":                  5,
+	"GET /fapi/v1/depth":                   5,
+	"POST /fapi/v1/order":                  1,
+	"GET /fapi/v1/order":                   1,
+	"DELETE /fapi/v1/order":                1,
+	"GET /fapi/v1/openOrders":              1,
+	"GET /fapi/v1/userTrades":              5,
+	"GET /fapi/v2/balance":                 5,
+	"GET /fapi/v2/positionRisk":            5,
+	"POST /fapi/v1/leverage":               1,
+	"POST /fapi/v1/marginType":             1,
+	"POST /sapi/v1/margin/order":           6,
+	"GET /sapi/v1/margin/order":            10,
+	"DELETE /sapi/v1/margin/order":         10,
+	"GET /sapi/v1/margin/openOrders":       10,
+	"GET /sapi/v1/margin/myTrades":         10,
+	"GET /sapi/v1/margin/account":          10,
+	"GET /sapi/v1/margin/isolated/account": 10,
+	"GET /sapi/v1/margin/maxBorrowable":    50,
+	"GET /sapi/v1/capital/deposit/address": 10,
+}
+
+// binanceFuturesWeightLimit is the request weight the futures API allows per IP per minute, counted apart
+// from the spot API's
+const binanceFuturesWeightLimit = 2400
+
+// binanceLimiter is shared by all Binance clients since the weight limit applies per IP
+var binanceLimiter = NewWeightLimiter(binanceWeightLimit * 9 / 10)
+
+// binanceTestnetLimiter tracks the testnet's weight budget, which is separate from the live exchange's
+var binanceTestnetLimiter = NewWeightLimiter(binanceWeightLimit * 9 / 10)
+
+// binanceFuturesLimiter is shared by all Binance futures clients
+var binanceFuturesLimiter = NewWeightLimiter(binanceFuturesWeightLimit * 9 / 10)
+
+// binanceFuturesTestnetLimiter tracks the futures testnet's weight budget
+var binanceFuturesTestnetLimiter = NewWeightLimiter(binanceFuturesWeightLimit * 9 / 10)
+
+// endpointWeight returns the request weight of an endpoint, defaulting to 1 for unlisted endpoints
+func endpointWeight(method, endpoint string) int {
+	if weight, ok := binanceEndpointWeights[method+" "+endpoint]; ok {
+		return weight
+	}
+	return 1
+}
+
+// WeightLimiter throttles requests so the used request weight stays under a per-minute budget
+type WeightLimiter struct {
+	mu           sync.Mutex
+	limit        int
+	used         int
+	windowEnd    time.Time
+	blockedUntil time.Time
+}
+
+// NewWeightLimiter creates a limiter allowing limit request weight per minute
+func NewWeightLimiter(limit int) *WeightLimiter {
+	return &WeightLimiter{limit: limit}
+}
+
+// Acquire blocks until a request of the given weight fits into the current minute's budget and reserves it
+func (l *WeightLimiter) Acquire(weight int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for {
+		now := time.Now()
+		if now.Before(l.blockedUntil) {
+			l.wait(l.blockedUntil.Sub(now))
+			continue
+		}
+		if !now.Before(l.windowEnd) {
+			l.used = 0
+			l.windowEnd = now.Truncate(time.Minute).Add(time.Minute)
+		}
+		if l.used+weight <= l.limit {
+			l.used += weight
+			return
+		}
+		log.Printf("Request weight budget exhausted (%d/%d used), waiting for the next window", l.used, l.limit)
+		l.wait(l.windowEnd.Sub(now))
+	}
+}
+
+// wait sleeps for d without holding the lock
+func (l *WeightLimiter) wait(d time.Duration) {
+	l.mu.Unlock()
+	time.Sleep(d)
+	l.mu.Lock()
+}
+
+// Update synchronizes the used weight with the exchange's X-MBX-USED-WEIGHT-1M header and blocks
+// all requests for the Retry-After period when the exchange reports a rate limit or ban
+func (l *WeightLimiter) Update(res *httpResult) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if used, err := strconv.Atoi(res.Header.Get("X-MBX-USED-WEIGHT-1M")); err == nil && used > l.used {
+		l.used = used
+	}
+
+	if res.StatusCode != http.StatusTooManyRequests && res.StatusCode != http.StatusTeapot {
+		return
+	}
+	retryAfter := time.Minute
+	if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		retryAfter = time.Duration(seconds) * time.Second
+	}
+	l.blockedUntil = time.Now().Add(retryAfter)
+	log.Printf("Rate limited by exchange (status %d), pausing requests for %s", res.StatusCode, retryAfter)
+}
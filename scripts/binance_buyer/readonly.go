@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errReadOnly is returned when a command that can place orders or move funds is run in read-only mode
+var errReadOnly = errors.New("this command can place orders or move funds, which read-only mode does not allow. Unset -read-only and READ_ONLY to use it")
+
+// PositionReader is implemented by clients that can read the open futures position of a symbol
+type PositionReader interface {
+	GetPosition(symbol string) (*FuturesPosition, error)
+}
+
+// ReadOnlyClient reads an account's balances, prices, orders and trades. The client underneath is held only through
+// its read interfaces, so no code handed a ReadOnlyClient can place or cancel an order, and an API key with trading
+// disabled is enough for it.
+type ReadOnlyClient struct {
+	reader   MarketReader
+	history  OrderHistory
+	position PositionReader
+	checker  KeyPermissionChecker
+	exchange string
+	// bindSymbol selects the isolated margin account of a symbol, as bindIsolatedSymbol does
+	bindSymbol func(symbol string)
+}
+
+// NewReadOnlyClient wraps a client, keeping only what it can read
+func NewReadOnlyClient(client ExchangeClient, exchange string) *ReadOnlyClient {
+	base := baseClient(client)
+	c := &ReadOnlyClient{
+		reader:     client,
+		exchange:   exchange,
+		bindSymbol: func(symbol string) { bindIsolatedSymbol(client, symbol) },
+	}
+	c.history, _ = base.(OrderHistory)
+	c.position, _ = base.(PositionReader)
+	c.checker, _ = base.(KeyPermissionChecker)
+	return c
+}
+
+// GetPrice gets the last price of a symbol
+func (c *ReadOnlyClient) GetPrice(symbol string) (float64, error) {
+	return c.reader.GetPrice(symbol)
+}
+
+// GetBookTicker gets the best bid and ask of a symbol
+func (c *ReadOnlyClient) GetBookTicker(symbol string) (*BookTicker, error) {
+	return c.reader.GetBookTicker(symbol)
+}
+
+// GetSymbolFilters gets the trading rules of a symbol
+func (c *ReadOnlyClient) GetSymbolFilters(symbol string) (*SymbolFilters, error) {
+	return c.reader.GetSymbolFilters(symbol)
+}
+
+// GetKlines gets the candles of a symbol between start and end
+func (c *ReadOnlyClient) GetKlines(symbol, interval string, start, end time.Time) ([]Candle, error) {
+	return c.reader.GetKlines(symbol, interval, start, end)
+}
+
+// GetBalance gets the free balance of an asset
+func (c *ReadOnlyClient) GetBalance(asset string) (Decimal, error) {
+	return c.reader.GetBalance(asset)
+}
+
+// GetOrder gets the current state of an order
+func (c *ReadOnlyClient) GetOrder(symbol, orderID string) (*Order, error) {
+	return c.reader.GetOrder(symbol, orderID)
+}
+
+// GetOpenOrders lists the open orders of a symbol
+func (c *ReadOnlyClient) GetOpenOrders(symbol string) ([]*Order, error) {
+	if c.history == nil {
+		return nil, fmt.Errorf("order history is not supported on %s", c.exchange)
+	}
+	return c.history.GetOpenOrders(symbol)
+}
+
+// GetTrades lists the account's trades of a symbol between since and until
+func (c *ReadOnlyClient) GetTrades(symbol string, since, until time.Time) ([]Trade, error) {
+	if c.history == nil {
+		return nil, fmt.Errorf("order history is not supported on %s", c.exchange)
+	}
+	return c.history.GetTrades(symbol, since, until)
+}
+
+// GetPosition gets the open futures position of a symbol
+func (c *ReadOnlyClient) GetPosition(symbol string) (*FuturesPosition, error) {
+	if c.position == nil {
+		return nil, fmt.Errorf("futures are only supported on binance")
+	}
+	return c.position.GetPosition(symbol)
+}
+
+// GetAPIRestrictions gets the permissions of the client's API key
+func (c *ReadOnlyClient) GetAPIRestrictions() (*APIRestrictions, error) {
+	if c.checker == nil {
+		return nil, fmt.Errorf("API key permissions are not supported on %s", c.exchange)
+	}
+	return c.checker.GetAPIRestrictions()
+}
+
+// BindIsolatedSymbol selects the isolated margin account of symbol for the reads that follow, when the client is
+// on the isolated margin market
+func (c *ReadOnlyClient) BindIsolatedSymbol(symbol string) {
+	c.bindSymbol(symbol)
+}
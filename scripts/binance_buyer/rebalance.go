@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AllocationTarget is the share of the portfolio's value an asset should make up
+type AllocationTarget struct {
+	Asset string
+	Pct   float64
+}
+
+// parseTargets parses a comma-separated allocation such as "BTC:50,ETH:30,USDT:20", which must add up to 100%
+func parseTargets(spec string) ([]AllocationTarget, error) {
+	var targets []AllocationTarget
+	var total float64
+	for _, entry := range strings.Split(spec, ",") {
+		asset, pctSpec, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		asset = strings.ToUpper(strings.TrimSpace(asset))
+		if !ok || asset == "" {
+			return nil, fmt.Errorf("invalid target %q: use ASSET:PERCENT", entry)
+		}
+		pct, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(pctSpec), "%"), 64)
+		if err != nil || pct < 0 {
+			return nil, fmt.Errorf("invalid percentage %q for %s", pctSpec, asset)
+		}
+		if slices.ContainsFunc(targets, func(t AllocationTarget) bool { return t.Asset == asset }) {
+			return nil, fmt.Errorf("asset %s is listed twice", asset)
+		}
+		targets = append(targets, AllocationTarget{Asset: asset, Pct: pct})
+		total += pct
+	}
+	if math.Abs(total-100) > 0.01 {
+		return nil, fmt.Errorf("target allocations add up to %.2f%%, not 100%%", total)
+	}
+	return targets, nil
+}
+
+// holding is an asset's position in the portfolio being rebalanced
+type holding struct {
+	AllocationTarget
+	Balance Decimal
+	// Price is the asset's price in the quote asset, 1 for the quote asset itself
+	Price float64
+	Value float64
+	// CurrentPct is the share of the portfolio's value the asset makes up
+	CurrentPct float64
+}
+
+// drift returns how many percentage points the asset is above its target, negative when below
+func (h holding) drift() float64 {
+	return h.CurrentPct - h.Pct
+}
+
+// rebalanceTrade is an order that moves an asset back to its target allocation, selling a base quantity or
+// buying for a quote amount
+type rebalanceTrade struct {
+	Symbol   string
+	Side     string
+	Quantity Decimal
+	Quote    Decimal
+	Value    float64
+	filters  *SymbolFilters
+}
+
+// loadHoldings fetches the balance and price of every target asset and values the portfolio in quoteAsset
+func loadHoldings(client MarketReader, targets []AllocationTarget, quoteAsset string) ([]holding, float64, error) {
+	var holdings []holding
+	var total float64
+	for _, target := range targets {
+		balance, err := client.GetBalance(target.Asset)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error getting %s balance: %v", target.Asset, err)
+		}
+		price := 1.0
+		if target.Asset != quoteAsset {
+			if price, err = client.GetPrice(target.Asset + quoteAsset); err != nil {
+				return nil, 0, fmt.Errorf("error getting %s price: %v", target.Asset+quoteAsset, err)
+			}
+		}
+		h := holding{AllocationTarget: target, Balance: balance, Price: price, Value: balance.Float64() * price}
+		holdings = append(holdings, h)
+		total += h.Value
+	}
+	if total <= 0 {
+		return nil, 0, fmt.Errorf("portfolio has no value to rebalance")
+	}
+	for i := range holdings {
+		holdings[i].CurrentPct = holdings[i].Value / total * 100
+	}
+	return holdings, total, nil
+}
+
+// planRebalance returns the trades that bring the assets drifting more than tolerance percentage points from
+// their targets back to target, sells first so their proceeds fund the buys. Every trade goes through the
+// quote asset, so when only the quote asset drifts, all other assets are traded back to target.
+func planRebalance(client MarketReader, holdings []holding, total float64, quoteAsset string, tolerance float64) ([]rebalanceTrade, error) {
+	quoteDrifts := false
+	anyDrifts := false
+	for _, h := range holdings {
+		if math.Abs(h.drift()) > tolerance {
+			anyDrifts = true
+			quoteDrifts = quoteDrifts || h.Asset == quoteAsset
+		}
+	}
+	if !anyDrifts {
+		return nil, nil
+	}
+
+	var sells, buys []rebalanceTrade
+	for _, h := range holdings {
+		if h.Asset == quoteAsset || (math.Abs(h.drift()) <= tolerance && !quoteDrifts) {
+			continue
+		}
+		symbol := h.Asset + quoteAsset
+		filters, err := client.GetSymbolFilters(symbol)
+		if err != nil {
+			return nil, fmt.Errorf("error getting symbol filters for %s: %v", symbol, err)
+		}
+
+		delta := total*h.Pct/100 - h.Value
+		trade := rebalanceTrade{Symbol: symbol, Value: math.Abs(delta), filters: filters}
+		if delta < 0 {
+			trade.Side = "SELL"
+			trade.Quantity = filters.RoundQuantity(NewDecimalFromFloat(-delta / h.Price))
+			err = filters.ValidateOrder(trade.Quantity, NewDecimalFromFloat(h.Price))
+		} else {
+			trade.Side = "BUY"
+			trade.Quote = filters.RoundQuote(NewDecimalFromFloat(delta))
+			err = filters.ValidateNotional(trade.Quote)
+		}
+		if err != nil {
+			log.Printf("Skipping %s %s: %v", strings.ToLower(trade.Side), symbol, err)
+			continue
+		}
+		if trade.Side == "SELL" {
+			sells = append(sells, trade)
+		} else {
+			buys = append(buys, trade)
+		}
+	}
+	return append(sells, buys...), nil
+}
+
+// executeRebalance places the trades one after another, each as a single market order or, when duration is
+// set, as a TWAP run over duration. Buys are scaled down when the quote balance cannot fund them in full,
+// e.g. after fees or slippage on the sells.
+func executeRebalance(ctx context.Context, client ExchangeClient, trades []rebalanceTrade, quoteAsset string, duration time.Duration, journal *Journal) error {
+	var buyTotal Decimal
+	for _, trade := range trades {
+		buyTotal = buyTotal.Add(trade.Quote)
+	}
+
+	scaled := false
+	for i, trade := range trades {
+		if ctx.Err() != nil {
+			return fmt.Errorf("rebalance interrupted before %s %s", strings.ToLower(trade.Side), trade.Symbol)
+		}
+		if trade.Side == "BUY" && !scaled {
+			scaled = true
+			available, err := client.GetBalance(quoteAsset)
+			if err != nil {
+				return fmt.Errorf("error getting %s balance: %v", quoteAsset, err)
+			}
+			if available.LessThan(buyTotal) {
+				log.Printf("Only %s of the %s %s planned for buys is available. Scaling buys down.", available, buyTotal, quoteAsset)
+				for j := i; j < len(trades); j++ {
+					trades[j].Quote = trades[j].filters.RoundQuote(trades[j].Quote.Mul(available).Div(buyTotal))
+				}
+				trade = trades[i]
+			}
+		}
+
+		if duration > 0 {
+			if err := twapRebalanceTrade(ctx, client, trade, quoteAsset, duration, journal); err != nil {
+				return err
+			}
+			continue
+		}
+
+		request := OrderRequest{Symbol: trade.Symbol, Side: trade.Side, Type: OrderTypeMarket, Quantity: trade.Quantity, QuoteQuantity: trade.Quote}
+		order, err := client.PlaceOrder(request)
+		if err != nil {
+			return fmt.Errorf("error placing %s %s order: %v", strings.ToLower(trade.Side), trade.Symbol, err)
+		}
+		slog.Info("Rebalance order placed", "symbol", trade.Symbol, "side", trade.Side, "order_id", order.OrderID,
+			"status", order.Status, "qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty)
+		if err := journal.Record(order, "", "", 0); err != nil {
+			log.Printf("Error recording order %s in journal: %v", order.OrderID, err)
+		}
+	}
+	return nil
+}
+
+// twapRebalanceTrade executes a rebalance trade as a TWAP run over duration, selling a base quantity or
+// buying for a quote amount
+func twapRebalanceTrade(ctx context.Context, client ExchangeClient, trade rebalanceTrade, quoteAsset string, duration time.Duration, journal *Journal) error {
+	price, err := client.GetPrice(trade.Symbol)
+	if err != nil {
+		return fmt.Errorf("error getting price for %s: %v", trade.Symbol, err)
+	}
+
+	cfg := TWAPConfig{
+		Symbol:     trade.Symbol,
+		Side:       trade.Side,
+		QuoteAsset: quoteAsset,
+		Algo:       AlgoTWAP,
+		Amount:     trade.Quote,
+		Duration:   duration,
+		OrderType:  OrderTypeMarket,
+		Filters:    trade.filters,
+	}
+	if trade.Side == "SELL" {
+		cfg.Amount = trade.Quantity
+		cfg.BaseAmount = true
+	}
+
+	log.Printf("Rebalancing %s: %s %s %s over %s", trade.Symbol, strings.ToLower(trade.Side), cfg.Amount, cfg.budgetAsset(), duration)
+	state := planTWAP(cfg, price)
+	state.journal = journal
+	runTWAP(ctx, client, state, "")
+	log.Printf("Rebalance of %s %s: %s %s executed for %s %s", trade.Symbol, strings.ToLower(trade.Side),
+		state.FilledBase, cfg.baseAsset(), state.FilledQuote, quoteAsset)
+	if ctx.Err() != nil {
+		return fmt.Errorf("rebalance interrupted during %s %s", strings.ToLower(trade.Side), trade.Symbol)
+	}
+	return nil
+}
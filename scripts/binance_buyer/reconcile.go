@@ -0,0 +1,137 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Actions for open orders found on the exchange that the run state does not track, selectable with -orphan-action
+const (
+	OrphanActionAdopt  = "adopt"
+	OrphanActionCancel = "cancel"
+)
+
+// reconcileRun brings a resumed run's state in line with the exchange. Fills of orders placed after the state was
+// last saved, e.g. by a run that crashed between placing an order and persisting it, are recorded against the
+// budget, and open orders placed by the run but missing from its state are adopted or cancelled.
+func reconcileRun(client ExchangeClient, state *RunState, orphanAction string) {
+	history, ok := baseClient(client).(OrderHistory)
+	if !ok {
+		log.Printf("Order reconciliation is not supported for this exchange. Trusting the persisted state.")
+		return
+	}
+	cfg := state.Config
+
+	tracked := map[string]bool{}
+	for _, order := range state.OpenOrders {
+		tracked[order.Order.OrderID] = true
+	}
+
+	openOrders, err := history.GetOpenOrders(cfg.Symbol)
+	if err != nil {
+		log.Printf("Error listing open orders for reconciliation: %v", err)
+		return
+	}
+	open := map[string]bool{}
+	var orphans []*Order
+	for _, order := range openOrders {
+		open[order.OrderID] = true
+		if !tracked[order.OrderID] && order.Side == cfg.Side && !order.CreatedAt.Before(state.StartedAt) {
+			orphans = append(orphans, order)
+		}
+	}
+
+	trades, err := history.GetTrades(cfg.Symbol, state.UpdatedAt, time.Time{})
+	if err != nil {
+		log.Printf("Error listing trades for reconciliation: %v", err)
+		return
+	}
+	untracked := map[string]*Order{}
+	for _, trade := range trades {
+		if tracked[trade.OrderID] || open[trade.OrderID] || trade.Side != cfg.Side {
+			continue
+		}
+		order, ok := untracked[trade.OrderID]
+		if !ok {
+			order = &Order{Symbol: trade.Symbol, OrderID: trade.OrderID, Side: trade.Side, Status: OrderStatusFilled, Account: trade.Account}
+			untracked[trade.OrderID] = order
+		}
+		addTrade(order, trade)
+	}
+	for _, order := range untracked {
+		log.Printf("Recording fill of untracked order %s: ExecutedQty=%s, %s=%s", order.OrderID, order.ExecutedQty, cfg.QuoteAsset, order.CumQuoteQty)
+		state.recordFill(order)
+		state.Remaining = state.Remaining.Sub(cfg.executedAmount(order))
+	}
+
+	for _, order := range state.OpenOrders {
+		if !open[order.Order.OrderID] {
+			log.Printf("Tracked order %s is no longer open. Its final state will be collected.", order.Order.OrderID)
+		}
+	}
+
+	handleOrphans(client, state, orphans, orphanAction)
+	log.Printf("Reconciliation complete: %d untracked fill(s), %d orphaned open order(s)", len(untracked), len(orphans))
+}
+
+// reconcilePrevious handles the open orders left by an incomplete previous run that a new run is replacing,
+// since the new run's state file no longer tracks them
+func reconcilePrevious(client ExchangeClient, state, previous *RunState, orphanAction string) {
+	if previous.Completed || previous.Config.Exchange != state.Config.Exchange || previous.Config.Symbol != state.Config.Symbol {
+		return
+	}
+	history, ok := baseClient(client).(OrderHistory)
+	if !ok {
+		return
+	}
+
+	openOrders, err := history.GetOpenOrders(state.Config.Symbol)
+	if err != nil {
+		log.Printf("Error listing open orders for reconciliation: %v", err)
+		return
+	}
+	var orphans []*Order
+	for _, order := range openOrders {
+		if order.Side == state.Config.Side && !order.CreatedAt.Before(previous.StartedAt) {
+			orphans = append(orphans, order)
+		}
+	}
+	if len(orphans) > 0 {
+		log.Printf("Found %d open order(s) left by the incomplete run started at %s", len(orphans), previous.StartedAt.Format(time.RFC3339))
+	}
+	handleOrphans(client, state, orphans, orphanAction)
+}
+
+// handleOrphans adopts open orders into the run's limit order tracking, committing them against the budget, or cancels them
+func handleOrphans(client ExchangeClient, state *RunState, orphans []*Order, orphanAction string) {
+	cfg := state.Config
+	for _, order := range orphans {
+		if orphanAction == OrphanActionAdopt {
+			price := decimalOrZero(order.Price)
+			committed := cfg.committedAmount(order, price)
+			log.Printf("Adopting open order %s: Price=%s, Qty=%s, ExecutedQty=%s", order.OrderID, order.Price, order.OrigQty, order.ExecutedQty)
+			state.OpenOrders = append(state.OpenOrders, &trackedOrder{Order: order, Price: price, PlacedAt: time.Now()})
+			state.Remaining = state.Remaining.Sub(committed)
+			continue
+		}
+
+		if err := client.CancelOrder(cfg.Symbol, order.OrderID); err != nil {
+			log.Printf("Error cancelling open order %s: %v", order.OrderID, err)
+			continue
+		}
+		if cancelled, err := client.GetOrder(cfg.Symbol, order.OrderID); err == nil {
+			order = cancelled
+		}
+		log.Printf("Cancelled open order %s: ExecutedQty=%s", order.OrderID, order.ExecutedQty)
+		state.recordFill(order)
+		state.Remaining = state.Remaining.Sub(cfg.executedAmount(order))
+	}
+}
+
+// addTrade accumulates a trade into an order's executed quantity, quote amount and commission
+func addTrade(order *Order, trade Trade) {
+	order.ExecutedQty = decimalOrZero(order.ExecutedQty).Add(trade.Qty).String()
+	order.CumQuoteQty = decimalOrZero(order.CumQuoteQty).Add(trade.QuoteQty).String()
+	order.Commission = order.Commission.Add(trade.Commission)
+	order.CommissionAsset = trade.CommissionAsset
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log/slog"
+)
+
+// Re-planning modes for the budget of slices that place no order
+const (
+	// ReplanSpread spreads a missed slice's budget evenly over the remaining slices
+	ReplanSpread = "spread"
+	// ReplanAppend appends a catch-up slice at the end of the run for every missed slice
+	ReplanAppend = "append"
+)
+
+// deferSlice handles a slice skipped before placing an order, carrying its budget into the next slice unless
+// the run re-plans missed slices
+func (s *RunState) deferSlice(due Decimal) {
+	if s.Config.Replan == "" {
+		s.Carry = due
+		return
+	}
+	s.Carry = Decimal{}
+	s.replan(due)
+}
+
+// replan moves the budget of a missed slice into the backlog. A catch-up slice is appended in append mode,
+// and in spread mode when no slice is left to spread it over.
+func (s *RunState) replan(amount Decimal) {
+	if s.Config.Replan == "" || amount.IsZero() {
+		return
+	}
+	if s.Config.Replan == ReplanAppend || s.NextSlice >= s.TotalSlices-1 {
+		s.TotalSlices++
+	}
+	s.Backlog = s.Backlog.Add(amount)
+	s.audit(AuditReplanned, "slice", s.NextSlice+1, "amount", amount, "replan", s.Config.Replan, "backlog", s.Backlog, "total_slices", s.TotalSlices)
+	slog.Info("Missed slice re-planned", "symbol", s.Config.Symbol, "slice", s.NextSlice+1, "amount", amount,
+		"replan", s.Config.Replan, "backlog", s.Backlog, "total_slices", s.TotalSlices, "budget_asset", s.Config.budgetAsset())
+}
+
+// backlogShare returns the part of the backlog due with the next slice. Spread mode shares it evenly between
+// the remaining slices, and append mode between the catch-up slices only.
+func (s *RunState) backlogShare() Decimal {
+	first := s.NextSlice
+	if s.Config.Replan == ReplanAppend {
+		first = max(first, s.plannedSlices())
+	}
+	if s.Backlog.IsZero() || s.NextSlice < first || first >= s.TotalSlices {
+		return Decimal{}
+	}
+	return s.Backlog.Div(NewDecimalFromInt(int64(s.TotalSlices - first)))
+}
+
+// plannedSlices returns the number of slices the run was planned with, which is the total for runs persisted
+// before catch-up slices existed
+func (s *RunState) plannedSlices() int {
+	if s.PlannedSlices == 0 {
+		return s.TotalSlices
+	}
+	return s.PlannedSlices
+}
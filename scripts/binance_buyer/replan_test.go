@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestRunStateReplan(t *testing.T) {
+	tests := []struct {
+		name      string
+		replan    string
+		nextSlice int
+		amount    string
+		total     int
+		backlog   string
+	}{
+		{name: "carried without re-planning", nextSlice: 3, amount: "10", total: 10, backlog: "5"},
+		{name: "spread over the remaining slices", replan: ReplanSpread, nextSlice: 3, amount: "10", total: 10, backlog: "15"},
+		{name: "spread after the last slice", replan: ReplanSpread, nextSlice: 9, amount: "10", total: 11, backlog: "15"},
+		{name: "appended as a catch-up slice", replan: ReplanAppend, nextSlice: 3, amount: "10", total: 11, backlog: "15"},
+		{name: "nothing missed", replan: ReplanAppend, nextSlice: 3, amount: "0", total: 10, backlog: "5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &RunState{Config: TWAPConfig{Symbol: "BTCUSDT", Replan: tt.replan}, NextSlice: tt.nextSlice,
+				TotalSlices: 10, PlannedSlices: 10, Backlog: decimalOrZero("5")}
+			state.replan(decimalOrZero(tt.amount))
+			if state.TotalSlices != tt.total {
+				t.Errorf("total slices %d, want %d", state.TotalSlices, tt.total)
+			}
+			if state.Backlog.Cmp(decimalOrZero(tt.backlog)) != 0 {
+				t.Errorf("backlog %s, want %s", state.Backlog, tt.backlog)
+			}
+		})
+	}
+}
+
+func TestRunStateDeferSlice(t *testing.T) {
+	state := &RunState{TotalSlices: 10, PlannedSlices: 10}
+	state.deferSlice(decimalOrZero("10"))
+	if state.Carry.Cmp(decimalOrZero("10")) != 0 || !state.Backlog.IsZero() {
+		t.Errorf("carry %s and backlog %s, want the slice carried into the next one", state.Carry, state.Backlog)
+	}
+
+	state.Config.Replan = ReplanSpread
+	state.deferSlice(decimalOrZero("20"))
+	if !state.Carry.IsZero() || state.Backlog.Cmp(decimalOrZero("20")) != 0 {
+		t.Errorf("carry %s and backlog %s, want the slice re-planned", state.Carry, state.Backlog)
+	}
+}
+
+func TestRunStateBacklogShare(t *testing.T) {
+	tests := []struct {
+		name      string
+		replan    string
+		nextSlice int
+		total     int
+		want      string
+	}{
+		{name: "spread over the remaining slices", replan: ReplanSpread, nextSlice: 6, total: 10, want: "7.5"},
+		{name: "spread onto the last slice", replan: ReplanSpread, nextSlice: 9, total: 10, want: "30"},
+		{name: "held for the catch-up slices", replan: ReplanAppend, nextSlice: 6, total: 12, want: "0"},
+		{name: "shared by the catch-up slices", replan: ReplanAppend, nextSlice: 10, total: 12, want: "15"},
+		{name: "no slice left", replan: ReplanSpread, nextSlice: 10, total: 10, want: "0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &RunState{Config: TWAPConfig{Replan: tt.replan}, NextSlice: tt.nextSlice, TotalSlices: tt.total,
+				PlannedSlices: 10, Backlog: decimalOrZero("30")}
+			if got := state.backlogShare(); got.Cmp(decimalOrZero(tt.want)) != 0 {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"time"
+)
+
+// RunReport summarizes the execution quality of a completed run
+type RunReport struct {
+	Symbol           string             `json:"symbol"`
+	Side             string             `json:"side"`
+	QuoteAsset       string             `json:"quote_asset"`
+	StartedAt        time.Time          `json:"started_at"`
+	FinishedAt       time.Time          `json:"finished_at"`
+	TargetQuote      Decimal            `json:"target_quote,omitzero"`
+	TargetBase       Decimal            `json:"target_base,omitzero"`
+	FilledQuote      Decimal            `json:"filled_quote"`
+	FilledBase       Decimal            `json:"filled_base"`
+	AverageFillPrice float64            `json:"average_fill_price"`
+	MarketTWAP       float64            `json:"market_twap"`
+	MarketVWAP       float64            `json:"market_vwap,omitempty"`
+	BenchmarkTrades  int                `json:"benchmark_trades,omitempty"`
+	FirstPrice       float64            `json:"first_price"`
+	LastPrice        float64            `json:"last_price"`
+	VsTWAPBps        float64            `json:"vs_twap_bps"`
+	VsVWAPBps        float64            `json:"vs_vwap_bps,omitempty"`
+	VsFirstBps       float64            `json:"vs_first_bps"`
+	VsLastBps        float64            `json:"vs_last_bps"`
+	SlippageBps      float64            `json:"slippage_bps"`
+	Fees             map[string]Decimal `json:"fees"`
+	Accounts         map[string]*Fills  `json:"accounts,omitempty"`
+	PlannedSlices    int                `json:"planned_slices"`
+	PlacedSlices     int                `json:"placed_slices"`
+	FailedSlices     int                `json:"failed_slices"`
+	SkippedSlices    int                `json:"skipped_slices,omitempty"`
+	DowntimeSeconds  float64            `json:"downtime_seconds,omitempty"`
+	Fills            []FillRecord       `json:"fills,omitempty"`
+	// FeesQuote is the fees in every asset in the quote asset, and PriceWithFees the average fill price with
+	// them added to the cost of a BUY or taken from the proceeds of a SELL, set when fees are converted
+	FeesQuote     Decimal `json:"fees_quote,omitzero"`
+	PriceWithFees float64 `json:"average_price_with_fees,omitempty"`
+	// Currency is the fiat currency the run reports in, with the quote filled, average price and fees in it
+	// at the FX rates of the fills. FiatMissed counts the fills left out for want of a rate.
+	Currency         string  `json:"currency,omitempty"`
+	FilledFiat       Decimal `json:"filled_fiat,omitzero"`
+	AveragePriceFiat float64 `json:"average_price_fiat,omitempty"`
+	FeesFiat         Decimal `json:"fees_fiat,omitzero"`
+	FiatMissed       int     `json:"fiat_missed,omitempty"`
+}
+
+// newRunReport builds the report of a run from its state
+func newRunReport(state *RunState) *RunReport {
+	cfg := state.Config
+	report := &RunReport{
+		Symbol:           cfg.Symbol,
+		Side:             cfg.Side,
+		QuoteAsset:       cfg.QuoteAsset,
+		StartedAt:        state.StartedAt,
+		FinishedAt:       state.UpdatedAt,
+		FilledQuote:      state.FilledQuote,
+		FilledBase:       state.FilledBase,
+		AverageFillPrice: state.averageFillPrice(),
+		FirstPrice:       state.FirstPrice,
+		LastPrice:        state.LastPrice,
+		SlippageBps:      state.cumulativeSlippageBps(),
+		Fees:             state.Commissions,
+		Accounts:         state.AccountFills,
+		PlannedSlices:    state.TotalSlices,
+		PlacedSlices:     state.PlacedSlices,
+		FailedSlices:     state.FailedSlices,
+		SkippedSlices:    state.SkippedSlices,
+		DowntimeSeconds:  state.Downtime.Seconds(),
+		Fills:            state.FillLog,
+	}
+	if state.FeesQuote.Sign() > 0 && state.FilledBase.Sign() > 0 {
+		report.FeesQuote = state.FeesQuote
+		cost := state.FilledQuote.Add(state.FeesQuote)
+		if cfg.Side == "SELL" {
+			cost = state.FilledQuote.Sub(state.FeesQuote)
+		}
+		report.PriceWithFees = cost.Div(state.FilledBase).Float64()
+	}
+	if cfg.Fiat.Enabled() {
+		report.Currency = cfg.Fiat.Currency
+		report.FilledFiat = state.FilledFiat
+		report.FeesFiat = state.FeesFiat
+		report.FiatMissed = state.FiatMissed
+		if state.FilledBase.Sign() > 0 {
+			report.AveragePriceFiat = state.FilledFiat.Div(state.FilledBase).Float64()
+		}
+	}
+	if cfg.BaseAmount {
+		report.TargetBase = cfg.Amount
+	} else {
+		report.TargetQuote = cfg.Amount
+	}
+	report.MarketTWAP = state.marketTWAP()
+	report.MarketVWAP = state.Benchmark.VWAP()
+	report.BenchmarkTrades = state.Benchmark.Trades
+	report.VsTWAPBps = priceImprovementBps(cfg.Side, report.AverageFillPrice, report.MarketTWAP)
+	report.VsVWAPBps = priceImprovementBps(cfg.Side, report.AverageFillPrice, report.MarketVWAP)
+	report.VsFirstBps = priceImprovementBps(cfg.Side, report.AverageFillPrice, report.FirstPrice)
+	report.VsLastBps = priceImprovementBps(cfg.Side, report.AverageFillPrice, report.LastPrice)
+	return report
+}
+
+// priceImprovementBps returns how much better the fill price is than a benchmark in basis points,
+// positive when buying below or selling above it
+func priceImprovementBps(side string, fill, benchmark float64) float64 {
+	if fill == 0 || benchmark == 0 {
+		return 0
+	}
+	improvement := (benchmark - fill) / benchmark * 10000
+	if side == "SELL" {
+		return -improvement
+	}
+	return improvement
+}
+
+// Log prints the report
+func (r *RunReport) Log() {
+	log.Printf("===== Execution report: %s %s =====", r.Side, r.Symbol)
+	log.Printf("Duration:            %s", r.FinishedAt.Sub(r.StartedAt).Round(time.Second))
+	if r.TargetBase.IsZero() {
+		log.Printf("Quote filled:        %s / %s %s", r.FilledQuote, r.TargetQuote, r.QuoteAsset)
+		log.Printf("Base filled:         %s", r.FilledBase)
+	} else {
+		log.Printf("Base filled:         %s / %s", r.FilledBase, r.TargetBase)
+		log.Printf("Quote filled:        %s %s", r.FilledQuote, r.QuoteAsset)
+	}
+	log.Printf("Average fill price:  %.8f", r.AverageFillPrice)
+	log.Printf("vs market TWAP:      %.8f (%+.2f bps)", r.MarketTWAP, r.VsTWAPBps)
+	if r.MarketVWAP > 0 {
+		log.Printf("vs market VWAP:      %.8f (%+.2f bps, %d market trades)", r.MarketVWAP, r.VsVWAPBps, r.BenchmarkTrades)
+	}
+	log.Printf("vs first price:      %.8f (%+.2f bps)", r.FirstPrice, r.VsFirstBps)
+	log.Printf("vs last price:       %.8f (%+.2f bps)", r.LastPrice, r.VsLastBps)
+	log.Printf("Slippage vs mid:     %+.2f bps", r.SlippageBps)
+	for asset, fee := range r.Fees {
+		log.Printf("Fees paid:           %s %s", fee, asset)
+	}
+	if r.FeesQuote.Sign() > 0 {
+		log.Printf("Fees in %-12s %s (%.2f bps of quote filled)", r.QuoteAsset+":", r.FeesQuote, r.FeesQuote.Div(r.FilledQuote).Float64()*10000)
+		log.Printf("Price with fees:     %.8f", r.PriceWithFees)
+	}
+	if r.Currency != "" {
+		log.Printf("Filled in %-10s %s at %.8f average", r.Currency+":", r.FilledFiat.StringFixed(2), r.AveragePriceFiat)
+		if r.FeesFiat.Sign() > 0 {
+			log.Printf("Fees in %-12s %s", r.Currency+":", r.FeesFiat.StringFixed(2))
+		}
+		if r.FiatMissed > 0 {
+			log.Printf("Not in %-13s %d fill(s) without an FX rate", r.Currency+":", r.FiatMissed)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(r.Accounts)) {
+		fills := r.Accounts[name]
+		log.Printf("Account %-12s %s base, %s %s at %.8f", name+":", fills.FilledBase, fills.FilledQuote, r.QuoteAsset, fills.averageFillPrice())
+	}
+	log.Printf("Slices:              %d planned, %d placed, %d failed", r.PlannedSlices, r.PlacedSlices, r.FailedSlices)
+	if r.DowntimeSeconds > 0 {
+		log.Printf("Downtime:            %s (%d missed slices skipped)", time.Duration(r.DowntimeSeconds*float64(time.Second)).Round(time.Second), r.SkippedSlices)
+	}
+}
+
+// WriteJSON writes the report to path as JSON
+func (r *RunReport) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding report: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing report: %v", err)
+	}
+	return nil
+}
+
+// runReportTemplate is the standalone HTML execution report
+var runReportTemplate = template.Must(template.New("run").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Report.Side}} {{.Report.Symbol}}</title></head>
+<body style="font-family: sans-serif; font-size: 14px;">
+<h2>Execution report: {{.Report.Side}} {{.Report.Symbol}}</h2>
+<table cellpadding="4" border="1" style="border-collapse: collapse;">
+<tr><td>Period</td><td>{{.Report.StartedAt.Format "2006-01-02 15:04:05 MST"}} to {{.Report.FinishedAt.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+{{if .Report.TargetBase.IsZero}}<tr><td>Quote filled</td><td>{{.Report.FilledQuote}} / {{.Report.TargetQuote}} {{.Report.QuoteAsset}}</td></tr>
+<tr><td>Base filled</td><td>{{.Report.FilledBase}}</td></tr>
+{{else}}<tr><td>Base filled</td><td>{{.Report.FilledBase}} / {{.Report.TargetBase}}</td></tr>
+<tr><td>Quote filled</td><td>{{.Report.FilledQuote}} {{.Report.QuoteAsset}}</td></tr>
+{{end}}<tr><td>Average fill price</td><td>{{printf "%.8f" .Report.AverageFillPrice}}</td></tr>
+<tr><td>vs market TWAP</td><td>{{printf "%.8f" .Report.MarketTWAP}} ({{printf "%+.2f" .Report.VsTWAPBps}} bps)</td></tr>
+{{if .Report.MarketVWAP}}<tr><td>vs market VWAP</td><td>{{printf "%.8f" .Report.MarketVWAP}} ({{printf "%+.2f" .Report.VsVWAPBps}} bps)</td></tr>
+{{end}}<tr><td>Slippage vs mid</td><td>{{printf "%+.2f" .Report.SlippageBps}} bps</td></tr>
+{{range $asset, $fee := .Report.Fees}}<tr><td>Fees paid</td><td>{{$fee}} {{$asset}}</td></tr>
+{{end}}{{if .Report.PriceWithFees}}<tr><td>Fees in {{.Report.QuoteAsset}}</td><td>{{.Report.FeesQuote}}</td></tr>
+<tr><td>Price with fees</td><td>{{printf "%.8f" .Report.PriceWithFees}}</td></tr>
+{{end}}{{if .Report.Currency}}<tr><td>Filled in {{.Report.Currency}}</td><td>{{.Report.FilledFiat.StringFixed 2}} at {{printf "%.8f" .Report.AveragePriceFiat}} average{{if .Report.FiatMissed}} ({{.Report.FiatMissed}} fills without an FX rate){{end}}</td></tr>
+{{if .Report.FeesFiat.Sign}}<tr><td>Fees in {{.Report.Currency}}</td><td>{{.Report.FeesFiat.StringFixed 2}}</td></tr>
+{{end}}{{end}}<tr><td>Slices</td><td>{{.Report.PlannedSlices}} planned, {{.Report.PlacedSlices}} placed, {{.Report.FailedSlices}} failed</td></tr>
+{{if .Report.DowntimeSeconds}}<tr><td>Downtime</td><td>{{printf "%.0f" .Report.DowntimeSeconds}} s ({{.Report.SkippedSlices}} missed slices skipped)</td></tr>
+{{end}}</table>
+<p style="color: #666;">Hover over a fill for its details.{{if not .Prices}} The market price was not available, so the price chart shows the fills only.{{end}}</p>
+{{.Chart}}
+</body></html>
+`))
+
+// panels returns the charts of the run: the market price with the fills marked on it, the position built up
+// and the budget consumed against the even schedule ending at plannedEnd
+func (r *RunReport) panels(prices []Candle, plannedEnd time.Time) []chartPanel {
+	market := chartSeries{Name: "market close", Color: "#1f77b4"}
+	for _, c := range prices {
+		market.Points = append(market.Points, chartPoint{c.CloseTime, c.Close})
+	}
+	average := chartSeries{Name: "average fill", Color: "#7f7f7f", Dashed: true}
+	position := chartSeries{Name: "base filled", Color: "#9467bd", Step: true, Points: []chartPoint{{r.StartedAt, 0}}}
+	budget := chartSeries{Name: "% of target filled", Color: "#ff7f0e", Step: true, Points: []chartPoint{{r.StartedAt, 0}}}
+	planned := chartSeries{Name: "even schedule", Color: "#7f7f7f", Dashed: true,
+		Points: []chartPoint{{r.StartedAt, 0}, {plannedEnd, 100}}}
+	target, baseTarget := r.TargetQuote.Float64(), !r.TargetBase.IsZero()
+	if baseTarget {
+		target = r.TargetBase.Float64()
+	}
+	var markers []chartMarker
+	var base, filled float64
+	for _, f := range r.Fills {
+		color := "#2ca02c"
+		if r.Side == "SELL" {
+			color = "#d62728"
+		}
+		label := fmt.Sprintf("%s %s %s for %s %s at %.8f", f.Time.Format(time.DateTime), r.Side, f.Qty, f.Quote, r.QuoteAsset, f.Price)
+		if f.Account != "" {
+			label += " (" + f.Account + ")"
+		}
+		markers = append(markers, chartMarker{Time: f.Time, Value: f.Price, Color: color, Label: label})
+		base += f.Qty.Float64()
+		position.Points = append(position.Points, chartPoint{f.Time, base})
+		if baseTarget {
+			filled += f.Qty.Float64()
+		} else {
+			filled += f.Quote.Float64()
+		}
+		if target > 0 {
+			budget.Points = append(budget.Points, chartPoint{f.Time, filled / target * 100})
+		}
+	}
+	if len(r.Fills) > 0 {
+		average.Points = []chartPoint{{r.StartedAt, r.AverageFillPrice}, {r.FinishedAt, r.AverageFillPrice}}
+	}
+	position.Points = append(position.Points, chartPoint{r.FinishedAt, base})
+	budget.Points = append(budget.Points, chartPoint{r.FinishedAt, budget.Points[len(budget.Points)-1].Value})
+	return []chartPanel{
+		{Title: "Price", Series: []chartSeries{market, average}, Markers: markers},
+		{Title: "Position", Series: []chartSeries{position}},
+		{Title: "Budget consumed", Series: []chartSeries{budget, planned}},
+	}
+}
+
+// WriteHTML writes the report to path as a standalone HTML page charting the run over the market prices, which
+// may be empty, and against its schedule ending at plannedEnd
+func (r *RunReport) WriteHTML(path string, prices []Candle, plannedEnd time.Time) error {
+	var buf bytes.Buffer
+	err := runReportTemplate.Execute(&buf, map[string]any{"Report": r, "Prices": len(prices) > 0,
+		"Chart": renderChart(r.panels(prices, plannedEnd))})
+	if err != nil {
+		return fmt.Errorf("error rendering report: %v", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("error writing report: %v", err)
+	}
+	return nil
+}
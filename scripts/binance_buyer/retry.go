@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures the exponential backoff applied to transient HTTP failures
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// defaultRetryPolicy is used by the exchange clients unless overridden
+var defaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// httpResult is a response whose body has been read in full
+type httpResult struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// permanentError is an attempt's error that doWithRetry returns without retrying, such as a transport error on
+// a request that may have taken effect
+type permanentError struct {
+	err error
+}
+
+// Error returns the message of the wrapped error
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// doWithRetry runs attempt until it returns a response that retryable rejects, or the attempts are exhausted.
+// attempt must build and send a fresh request each time so signatures and nonces are regenerated.
+// Transport errors are retried unless attempt wraps them in a permanentError.
+func doWithRetry(policy RetryPolicy, attempt func() (*httpResult, error), retryable func(*httpResult) bool) (*httpResult, error) {
+	for n := 1; ; n++ {
+		res, err := attempt()
+		if err == nil && !retryable(res) {
+			return res, nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			return nil, permanent.err
+		}
+		if n >= policy.MaxAttempts {
+			return res, err
+		}
+
+		delay := policy.backoff(n, res)
+		if err != nil {
+			slog.Warn("Request failed, retrying", "attempt", n, "max_attempts", policy.MaxAttempts, "error", err, "retry_in", delay)
+		} else {
+			slog.Warn("Request failed, retrying", "attempt", n, "max_attempts", policy.MaxAttempts, "status", res.StatusCode, "body", string(res.Body), "retry_in", delay)
+		}
+		time.Sleep(delay)
+	}
+}
+
+// backoff returns the delay before the next attempt, honoring a Retry-After header when present
+// and otherwise using exponential backoff with full jitter
+func (p RetryPolicy) backoff(attempt int, res *httpResult) time.Duration {
+	if res != nil {
+		if seconds, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second
+		}
+	}
+
+	ceiling := min(p.MaxDelay, p.BaseDelay<<(attempt-1))
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+// isRetryableStatus reports whether an HTTP status indicates a transient server or rate limit failure
+func isRetryableStatus(statusCode int) bool {
+	return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KillSwitch halts a run and cancels its open orders when any of its rules trips. Zero values disable a rule.
+type KillSwitch struct {
+	// MaxConsecutiveErrors is the number of order placements failing in a row that halts the run
+	MaxConsecutiveErrors int `json:"max_consecutive_errors,omitempty"`
+	// MaxDropPct is how far the price may fall below the run's first sampled price, in percent
+	MaxDropPct float64 `json:"max_drop_pct,omitempty"`
+	// SentinelFile halts the run once it exists, so one file can stop every run sharing it
+	SentinelFile string `json:"sentinel_file,omitempty"`
+	// SentinelURL halts the run once it answers "stop" or {"stop": true}
+	SentinelURL string `json:"sentinel_url,omitempty"`
+}
+
+// Enabled reports whether any rule is set
+func (k KillSwitch) Enabled() bool {
+	return k != KillSwitch{}
+}
+
+// sentinelResponse is the JSON form of a sentinel endpoint's answer
+type sentinelResponse struct {
+	Stop bool `json:"stop"`
+}
+
+// killReason checks the price and sentinel rules of the run's kill switch before a slice, returning why the
+// run must halt or an empty string
+func (s *RunState) killReason() string {
+	k := s.Config.Kill
+	if k.MaxDropPct > 0 && s.FirstPrice > 0 && s.LastPrice > 0 {
+		if drop := (s.FirstPrice - s.LastPrice) / s.FirstPrice * 100; drop >= k.MaxDropPct {
+			return fmt.Sprintf("price fell %.2f%% since the run started, from %.8f to %.8f", drop, s.FirstPrice, s.LastPrice)
+		}
+	}
+	return k.sentinelReason()
+}
+
+// sentinelReason checks the kill switch's sentinel file and URL, returning why trading must halt or an empty
+// string
+func (k KillSwitch) sentinelReason() string {
+	if k.SentinelFile != "" {
+		if _, err := os.Stat(k.SentinelFile); err == nil {
+			return fmt.Sprintf("sentinel file %s exists", k.SentinelFile)
+		}
+	}
+	if k.SentinelURL != "" {
+		stop, err := checkSentinelURL(k.SentinelURL)
+		if err != nil {
+			// An unreachable sentinel does not halt the run, so an outage of the endpoint cannot stop trading
+			log.Printf("Error checking kill switch sentinel: %v", err)
+		} else if stop {
+			return fmt.Sprintf("sentinel %s says stop", k.SentinelURL)
+		}
+	}
+	return ""
+}
+
+// checkSentinelURL asks a sentinel endpoint whether trading must stop
+func checkSentinelURL(url string) (bool, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("error creating request: %v", err)
+	}
+	res, err := readResult(newHTTPClient(), req)
+	if err != nil {
+		return false, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("sentinel returned status %d", res.StatusCode)
+	}
+	body := strings.TrimSpace(string(res.Body))
+	if strings.EqualFold(body, "stop") {
+		return true, nil
+	}
+	var answer sentinelResponse
+	if json.Unmarshal(res.Body, &answer) == nil {
+		return answer.Stop, nil
+	}
+	return false, nil
+}
+
+// recordPlacement counts consecutive failed order placements, returning a reason once the kill switch's error
+// limit is reached
+func (s *RunState) recordPlacement(err error) string {
+	if err == nil {
+		s.consecutiveErrors = 0
+		return ""
+	}
+	s.consecutiveErrors++
+	if limit := s.Config.Kill.MaxConsecutiveErrors; limit > 0 && s.consecutiveErrors >= limit {
+		return fmt.Sprintf("%d order placements failed in a row, last: %v", s.consecutiveErrors, err)
+	}
+	return ""
+}
+
+// kill halts the run for reason, cancelling its open limit orders and alerting through the audit log,
+// notifiers and event stream
+func (s *RunState) kill(tracker *limitOrderTracker, reason string) {
+	s.Killed = reason
+	s.Remaining = s.Remaining.Add(tracker.CancelAll())
+	s.OpenOrders = tracker.open
+	s.audit(AuditKilled, "reason", reason, "next_slice", s.NextSlice+1, "remaining_budget", s.Remaining, "open_orders", len(s.OpenOrders))
+	log.Printf("KILL SWITCH: %s. Trading halted with %s %s remaining. Resume with -resume once it is safe.", reason, s.Remaining, s.Config.budgetAsset())
+}
+
+// spendLedgerRetention is how many days of spend a ledger keeps
+const spendLedgerRetention = 31
+
+// SpendCaps are hard limits on the quote a run's BUY orders may spend, checked against every order before it is
+// placed whatever the slicing computed. Zero values disable a cap.
+type SpendCaps struct {
+	// PerRun caps the quote spent by the run
+	PerRun Decimal `json:"per_run,omitzero"`
+	// PerDay caps the quote spent per UTC day by every run sharing the ledger, over symbols of the same quote asset
+	PerDay Decimal `json:"per_day,omitzero"`
+	// PerSymbolDay caps the quote spent per UTC day on the run's symbol by every run sharing the ledger
+	PerSymbolDay Decimal `json:"per_symbol_day,omitzero"`
+	// Ledger is the file recording what runs have spent, shared by runs so the daily caps span them
+	Ledger string `json:"ledger,omitempty"`
+}
+
+// Enabled reports whether any cap is set
+func (c SpendCaps) Enabled() bool {
+	return !c.PerRun.IsZero() || !c.PerDay.IsZero() || !c.PerSymbolDay.IsZero()
+}
+
+// SpendLedger records the quote spent by BUY orders per day and symbol, and per run
+type SpendLedger struct {
+	// Days maps each UTC date to the quote spent per symbol
+	Days map[string]map[string]Decimal `json:"days"`
+	Runs map[string]RunSpend           `json:"runs"`
+}
+
+// RunSpend is the quote spent by a run and the last day it spent any
+type RunSpend struct {
+	Spent Decimal `json:"spent"`
+	Day   string  `json:"day"`
+}
+
+// SpendCapError rejects an order that would take spending past a cap. It is fatal, so the run stops with its
+// state saved.
+type SpendCapError struct {
+	Cap      string
+	Limit    Decimal
+	Spent    Decimal
+	Notional Decimal
+}
+
+// Error describes the breached cap
+func (e *SpendCapError) Error() string {
+	return fmt.Sprintf("order of %s would exceed the %s spend cap of %s with %s already spent", e.Notional, e.Cap, e.Limit, e.Spent)
+}
+
+// errSpendLedgerNotSaved is returned when spending cannot be saved to the ledger. It is fatal, as the caps
+// cannot be enforced without it.
+var errSpendLedgerNotSaved = errors.New("error saving spend ledger")
+
+// spendReservation is the notional counted for an order until it reaches a terminal status
+type spendReservation struct {
+	amount Decimal
+	day    string
+}
+
+// spendGuardClient checks every BUY order against the spend caps and records what it spends in the ledger.
+// An order counts at its full notional once placed and is settled to its filled quote when it ends, so
+// orders open across a restart stay counted in full.
+type spendGuardClient struct {
+	ExchangeClient
+	caps       SpendCaps
+	runID      string
+	quoteAsset string
+	mu         sync.Mutex
+	reserved   map[string]spendReservation
+}
+
+// withSpendGuard wraps the client of a run so its BUY orders are held to the run's spend caps. Runs without caps
+// get the client back unchanged.
+func withSpendGuard(client ExchangeClient, state *RunState) ExchangeClient {
+	if !state.Config.Spend.Enabled() {
+		return client
+	}
+	return &spendGuardClient{
+		ExchangeClient: client,
+		caps:           state.Config.Spend,
+		runID:          state.RunID,
+		quoteAsset:     state.Config.QuoteAsset,
+		reserved:       make(map[string]spendReservation),
+	}
+}
+
+// Unwrap returns the wrapped client
+func (c *spendGuardClient) Unwrap() ExchangeClient {
+	return c.ExchangeClient
+}
+
+// PlaceOrder places a BUY order only when its notional fits within every cap, recording it in the ledger
+func (c *spendGuardClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	if req.Side != "BUY" {
+		return c.ExchangeClient.PlaceOrder(req)
+	}
+	notional, err := c.notional(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ledger is shared with runs in other processes, so it stays locked from the check until the order's
+	// spend is saved
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	unlock, err := lockFile(c.caps.Ledger)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	ledger, err := loadSpendLedger(c.caps.Ledger)
+	if err != nil {
+		return nil, err
+	}
+	day := time.Now().UTC().Format(time.DateOnly)
+	if err := c.check(ledger, req.Symbol, day, notional); err != nil {
+		return nil, err
+	}
+	// The notional is saved before the order is sent, so no order is placed without being counted
+	if err := c.record(ledger, req.Symbol, day, notional); err != nil {
+		return nil, err
+	}
+	order, err := c.ExchangeClient.PlaceOrder(req)
+	if err != nil {
+		if recordErr := c.record(ledger, req.Symbol, day, notional.Neg()); recordErr != nil {
+			log.Printf("Error releasing spend of a failed order: %v", recordErr)
+		}
+		return order, err
+	}
+
+	if !isTerminalStatus(order.Status) {
+		c.reserved[order.OrderID] = spendReservation{amount: notional, day: day}
+	} else if unspent := decimalOrZero(order.CumQuoteQty).Sub(notional); !unspent.IsZero() {
+		if err := c.record(ledger, req.Symbol, day, unspent); err != nil {
+			log.Printf("Error settling spend of order %s: %v", order.OrderID, err)
+		}
+	}
+	return order, nil
+}
+
+// GetOrder settles the spend of an order counted at its notional once it reaches a terminal status
+func (c *spendGuardClient) GetOrder(symbol, orderID string) (*Order, error) {
+	order, err := c.ExchangeClient.GetOrder(symbol, orderID)
+	if err != nil || !isTerminalStatus(order.Status) {
+		return order, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reservation, ok := c.reserved[orderID]
+	if !ok {
+		return order, nil
+	}
+	delete(c.reserved, orderID)
+	unlock, err := lockFile(c.caps.Ledger)
+	if err != nil {
+		log.Printf("Error settling spend of order %s: %v", orderID, err)
+		return order, nil
+	}
+	defer unlock()
+	ledger, err := loadSpendLedger(c.caps.Ledger)
+	if err != nil {
+		log.Printf("Error settling spend of order %s: %v", orderID, err)
+		return order, nil
+	}
+	if err := c.record(ledger, symbol, reservation.day, decimalOrZero(order.CumQuoteQty).Sub(reservation.amount)); err != nil {
+		log.Printf("Error settling spend of order %s: %v", orderID, err)
+	}
+	return order, nil
+}
+
+// notional returns the most an order may spend: its quote quantity, or its quantity at its limit price or
+// else the current price
+func (c *spendGuardClient) notional(req OrderRequest) (Decimal, error) {
+	if !req.QuoteQuantity.IsZero() {
+		return req.QuoteQuantity, nil
+	}
+	if !req.Price.IsZero() {
+		return req.Quantity.Mul(req.Price), nil
+	}
+	price, err := c.ExchangeClient.GetPrice(req.Symbol)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error pricing order against spend caps: %v", err)
+	}
+	return req.Quantity.MulFloat(price), nil
+}
+
+// check returns a SpendCapError when spending notional on symbol would exceed a cap
+func (c *spendGuardClient) check(ledger *SpendLedger, symbol, day string, notional Decimal) error {
+	var daySpent Decimal
+	for s, spent := range ledger.Days[day] {
+		if strings.HasSuffix(s, c.quoteAsset) {
+			daySpent = daySpent.Add(spent)
+		}
+	}
+	caps := []struct {
+		name  string
+		limit Decimal
+		spent Decimal
+	}{
+		{"per-run", c.caps.PerRun, ledger.Runs[c.runID].Spent},
+		{"daily", c.caps.PerDay, daySpent},
+		{symbol + " daily", c.caps.PerSymbolDay, ledger.Days[day][symbol]},
+	}
+	for _, cp := range caps {
+		if !cp.limit.IsZero() && cp.spent.Add(notional).GreaterThan(cp.limit) {
+			return &SpendCapError{Cap: cp.name, Limit: cp.limit, Spent: cp.spent, Notional: notional}
+		}
+	}
+	return nil
+}
+
+// record adds spent to the run's and the day's totals, dropping days past the retention, and saves the ledger
+func (c *spendGuardClient) record(ledger *SpendLedger, symbol, day string, spent Decimal) error {
+	if ledger.Days[day] == nil {
+		ledger.Days[day] = make(map[string]Decimal)
+	}
+	ledger.Days[day][symbol] = ledger.Days[day][symbol].Add(spent)
+	run := ledger.Runs[c.runID]
+	ledger.Runs[c.runID] = RunSpend{Spent: run.Spent.Add(spent), Day: day}
+
+	oldest := time.Now().UTC().AddDate(0, 0, -spendLedgerRetention).Format(time.DateOnly)
+	for d := range ledger.Days {
+		if d < oldest {
+			delete(ledger.Days, d)
+		}
+	}
+	for id, run := range ledger.Runs {
+		if run.Day < oldest {
+			delete(ledger.Runs, id)
+		}
+	}
+	if err := writeJSONFile(c.caps.Ledger, ledger); err != nil {
+		return fmt.Errorf("%w: %v", errSpendLedgerNotSaved, err)
+	}
+	return nil
+}
+
+// loadSpendLedger reads the spend ledger, which is empty when the file does not exist yet. It is read before
+// every order, so runs sharing it see each other's spending.
+func loadSpendLedger(path string) (*SpendLedger, error) {
+	ledger := &SpendLedger{Days: make(map[string]map[string]Decimal), Runs: make(map[string]RunSpend)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading spend ledger: %v", err)
+	}
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return nil, fmt.Errorf("error parsing spend ledger: %v", err)
+	}
+	if ledger.Days == nil {
+		ledger.Days = make(map[string]map[string]Decimal)
+	}
+	if ledger.Runs == nil {
+		ledger.Runs = make(map[string]RunSpend)
+	}
+	return ledger, nil
+}
+
+// PositionLimit caps the position a BUY run builds up, counting what the account already holds so overlapping
+// runs cannot over-accumulate together. Zero values disable a limit.
+type PositionLimit struct {
+	// MaxBase is the largest position in the base asset
+	MaxBase Decimal `json:"max_base,omitzero"`
+	// MaxQuote is the largest position valued in the quote asset at the current price
+	MaxQuote Decimal `json:"max_quote,omitzero"`
+}
+
+// Enabled reports whether any limit is set
+func (p PositionLimit) Enabled() bool {
+	return !p.MaxBase.IsZero() || !p.MaxQuote.IsZero()
+}
+
+// maxBase returns the largest position in the base asset the limits allow at price, or zero when no limit is
+// set
+func (p PositionLimit) maxBase(price Decimal) Decimal {
+	maxBase := p.MaxBase
+	if byQuote := p.MaxQuote.Div(price); !byQuote.IsZero() && (maxBase.IsZero() || byQuote.LessThan(maxBase)) {
+		maxBase = byQuote
+	}
+	return maxBase
+}
+
+// holding returns the base asset the account holds in the run's symbol: the free balance on spot, or the
+// position amount on futures
+func (s *RunState) holding(client ExchangeClient) (Decimal, error) {
+	if s.Config.Market == MarketFutures {
+		futures, err := futuresClient(client)
+		if err != nil {
+			return Decimal{}, err
+		}
+		position, err := futures.GetPosition(s.Config.Symbol)
+		if err != nil {
+			return Decimal{}, fmt.Errorf("error getting %s position: %v", s.Config.Symbol, err)
+		}
+		return position.Amount, nil
+	}
+	balance, err := client.GetBalance(s.Config.baseAsset())
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error getting %s balance: %v", s.Config.baseAsset(), err)
+	}
+	return balance, nil
+}
+
+// positionHeadroom returns how much of the budget asset the run can still buy before the projected position,
+// the holding plus the unfilled part of the run's open orders, reaches the position limit
+func (s *RunState) positionHeadroom(client ExchangeClient, tracker *limitOrderTracker) (Decimal, error) {
+	if s.LastPrice <= 0 {
+		return Decimal{}, fmt.Errorf("no price sampled")
+	}
+	projected, err := s.holding(client)
+	if err != nil {
+		return Decimal{}, err
+	}
+	for _, tracked := range tracker.open {
+		projected = projected.Add(decimalOrZero(tracked.Order.OrigQty).Sub(decimalOrZero(tracked.Order.ExecutedQty)))
+	}
+
+	price := NewDecimalFromFloat(s.LastPrice)
+	headroom := s.Config.Position.maxBase(price).Sub(projected)
+	if headroom.Sign() < 0 {
+		headroom = Decimal{}
+	}
+	if s.Config.BaseAmount {
+		return headroom, nil
+	}
+	return headroom.Mul(price), nil
+}
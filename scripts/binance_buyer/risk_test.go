@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fillingClient fills market orders at their quote quantity and leaves limit orders open until settled
+type fillingClient struct {
+	ExchangeClient
+	orders map[string]*Order
+	placed int
+}
+
+func (c *fillingClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	c.placed++
+	order := &Order{OrderID: strconv.Itoa(c.placed), Symbol: req.Symbol, Side: req.Side, Status: OrderStatusFilled,
+		CumQuoteQty: req.QuoteQuantity.String()}
+	if req.Type == OrderTypeLimit {
+		order.Status, order.CumQuoteQty = OrderStatusNew, "0"
+	}
+	c.orders[order.OrderID] = order
+	return order, nil
+}
+
+func (c *fillingClient) GetOrder(symbol, orderID string) (*Order, error) {
+	return c.orders[orderID], nil
+}
+
+func TestSpendGuardCheck(t *testing.T) {
+	day := "2024-01-01"
+	ledger := &SpendLedger{
+		Days: map[string]map[string]Decimal{day: {
+			"BTCUSDT": decimalOrZero("300"),
+			"ETHUSDT": decimalOrZero("200"),
+			"BTCEUR":  decimalOrZero("1000"),
+		}},
+		Runs: map[string]RunSpend{"run": {Spent: decimalOrZero("400"), Day: day}},
+	}
+	tests := []struct {
+		name     string
+		caps     SpendCaps
+		notional string
+		want     string
+	}{
+		{name: "no caps", notional: "100000"},
+		{name: "up to the run cap", caps: SpendCaps{PerRun: decimalOrZero("500")}, notional: "100"},
+		{name: "past the run cap", caps: SpendCaps{PerRun: decimalOrZero("500")}, notional: "100.01", want: "per-run"},
+		{name: "daily cap of the quote asset", caps: SpendCaps{PerDay: decimalOrZero("600")}, notional: "100"},
+		{name: "past the daily cap", caps: SpendCaps{PerDay: decimalOrZero("600")}, notional: "150", want: "daily"},
+		{name: "past the symbol's daily cap", caps: SpendCaps{PerSymbolDay: decimalOrZero("350")}, notional: "60", want: "BTCUSDT daily"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := &spendGuardClient{caps: tt.caps, runID: "run", quoteAsset: "USDT"}
+			err := guard.check(ledger, "BTCUSDT", day, decimalOrZero(tt.notional))
+			var capErr *SpendCapError
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("error %v, want the order allowed", err)
+				}
+			} else if !errors.As(err, &capErr) || capErr.Cap != tt.want {
+				t.Errorf("error %v, want the %s cap", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpendGuardRecordsSpendAcrossRuns(t *testing.T) {
+	ledgerPath := filepath.Join(t.TempDir(), "spend.json")
+	client := &fillingClient{orders: make(map[string]*Order)}
+	newRun := func(id string, caps SpendCaps) ExchangeClient {
+		caps.Ledger = ledgerPath
+		return withSpendGuard(client, &RunState{RunID: id, Config: TWAPConfig{QuoteAsset: "USDT", Spend: caps}})
+	}
+	spent := func(runID string) (Decimal, Decimal) {
+		t.Helper()
+		ledger, err := loadSpendLedger(ledgerPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ledger.Runs[runID].Spent, ledger.Days[time.Now().UTC().Format(time.DateOnly)]["BTCUSDT"]
+	}
+	buy := OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: OrderTypeMarket, QuoteQuantity: decimalOrZero("600")}
+
+	first := newRun("first", SpendCaps{PerRun: decimalOrZero("1000")})
+	if _, err := first.PlaceOrder(buy); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.PlaceOrder(buy); !errors.As(err, new(*SpendCapError)) {
+		t.Fatalf("second order returned %v, want it rejected by the run cap", err)
+	}
+	if _, err := first.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "SELL", Type: OrderTypeMarket, Quantity: decimalOrZero("1")}); err != nil {
+		t.Errorf("SELL order returned %v, want it not capped", err)
+	}
+
+	// A limit order counts at its full notional until it ends, and then at what it filled
+	limit := OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: OrderTypeLimit, Quantity: decimalOrZero("0.004"), Price: decimalOrZero("50000")}
+	order, err := first.PlaceOrder(limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run, day := spent("first"); run.Cmp(decimalOrZero("800")) != 0 || day.Cmp(decimalOrZero("800")) != 0 {
+		t.Errorf("spent %s by the run and %s on the day with the limit order open, want 800", run, day)
+	}
+	client.orders[order.OrderID].Status, client.orders[order.OrderID].CumQuoteQty = OrderStatusCanceled, "150"
+	if _, err := first.GetOrder("BTCUSDT", order.OrderID); err != nil {
+		t.Fatal(err)
+	}
+	if run, _ := spent("first"); run.Cmp(decimalOrZero("750")) != 0 {
+		t.Errorf("spent %s by the run once the order ended, want 750", run)
+	}
+
+	// The daily cap counts what other runs sharing the ledger spent
+	second := newRun("second", SpendCaps{PerDay: decimalOrZero("800")})
+	var capErr *SpendCapError
+	if _, err := second.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: OrderTypeMarket, QuoteQuantity: decimalOrZero("100")}); !errors.As(err, &capErr) || capErr.Cap != "daily" {
+		t.Errorf("order of another run returned %v, want it rejected by the daily cap", err)
+	}
+	if _, err := second.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: OrderTypeMarket, QuoteQuantity: decimalOrZero("50")}); err != nil {
+		t.Errorf("order within the daily cap returned %v", err)
+	}
+}
+
+func TestSpendGuardStopsWhenLedgerNotSaved(t *testing.T) {
+	// The lock file's name still fits, but the temporary file the ledger is written through does not
+	ledgerPath := filepath.Join(t.TempDir(), strings.Repeat("l", 250))
+	client := &fillingClient{orders: make(map[string]*Order)}
+	guard := withSpendGuard(client, &RunState{RunID: "run", Config: TWAPConfig{QuoteAsset: "USDT",
+		Spend: SpendCaps{PerRun: decimalOrZero("1000"), Ledger: ledgerPath}}})
+
+	_, err := guard.PlaceOrder(OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Type: OrderTypeMarket, QuoteQuantity: decimalOrZero("100")})
+	if !errors.Is(err, errSpendLedgerNotSaved) || !isFatalError(err) {
+		t.Errorf("error %v, want a fatal ledger error", err)
+	}
+	if client.placed != 0 {
+		t.Errorf("placed %d orders, want none", client.placed)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"os"
+	"os/exec"
+	"sort"
+	"time"
+)
+
+// scriptReplyTimeout is how long a script strategy may take to answer a candle before it is restarted
+const scriptReplyTimeout = 5 * time.Second
+
+// ScriptConfig holds the settings of a strategy whose logic runs in an unsandboxed subprocess
+type ScriptConfig struct {
+	// Command is the script's command line, e.g. ["python3", "strategy.py"] or ["lua", "strategy.lua"]
+	Command []string `json:"command"`
+	// Indicators are the indicators computed for the script on every candle, by name, e.g. {"fast": "ema:12",
+	// "bands": "bb:20:2"}
+	Indicators map[string]string `json:"indicators,omitempty"`
+	// Warmup is how many candles the script needs before it can signal
+	Warmup int `json:"warmup"`
+	// Reload restarts the script when one of the files on its command line changes, so a strategy can be
+	// swapped while the engine keeps running
+	Reload bool `json:"reload,omitempty"`
+	// Wasm is a WASI module speaking the script protocol on stdin and stdout, run in-process and sandboxed in
+	// place of Command
+	Wasm string `json:"wasm,omitempty"`
+	// WasmMemoryMB bounds the memory of the WASI module, 64 MiB by default
+	WasmMemoryMB int `json:"wasm_memory_mb,omitempty"`
+}
+
+// scriptCandle is a candle as sent to scripts
+type scriptCandle struct {
+	OpenTime time.Time `json:"open_time"`
+	Open     float64   `json:"open"`
+	High     float64   `json:"high"`
+	Low      float64   `json:"low"`
+	Close    float64   `json:"close"`
+	Volume   float64   `json:"volume"`
+}
+
+// scriptMessage is the line sent to a script for every closed candle
+type scriptMessage struct {
+	Candle     scriptCandle       `json:"candle"`
+	Indicators map[string]float64 `json:"indicators"`
+	InPosition bool               `json:"in_position"`
+	// Replay marks candles resent to a restarted script to rebuild its state, whose signals are ignored
+	Replay bool `json:"replay,omitempty"`
+}
+
+// scriptReply is the line a script answers every candle with
+type scriptReply struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+	// Amount optionally sizes an entry in the quote asset, up to the config's amount
+	Amount Decimal `json:"amount,omitzero"`
+}
+
+// ScriptStrategy runs strategy logic in an external process, so it can be written in any scripting language
+// and changed without recompiling. The script reads one JSON message per closed candle on stdin, with the
+// candle, the configured indicators and whether a position is open, and answers each with one JSON line
+// holding its action. The process is not sandboxed: it runs with the user's permissions and can reach files and
+// the network. Only its environment is emptied apart from PATH, which keeps credentials out of it. Strategies
+// that need a sandbox use StarlarkStrategy.
+type ScriptStrategy struct {
+	cfg        ScriptConfig
+	indicators *scriptIndicators
+	// history holds the last Warmup messages, replayed to the script whenever it is restarted
+	history []scriptMessage
+
+	process scriptProcess
+	stdin   io.WriteCloser
+	stdout  *os.File
+	replies chan []byte
+	// modTimes are the modification times of the script's files when it was started
+	modTimes map[string]time.Time
+}
+
+// scriptProcess is a running script: an external process or a WebAssembly module
+type scriptProcess interface {
+	// Kill stops the script at once
+	Kill()
+	// Wait waits for the script to exit
+	Wait() error
+}
+
+// commandProcess is a script running as an external process
+type commandProcess struct {
+	cmd *exec.Cmd
+}
+
+// Kill kills the process
+func (p commandProcess) Kill() {
+	p.cmd.Process.Kill()
+}
+
+// Wait waits for the process to exit
+func (p commandProcess) Wait() error {
+	return p.cmd.Wait()
+}
+
+// NewScriptStrategy creates the strategy, validating its indicators. The script is started with the first
+// candle.
+func NewScriptStrategy(cfg ScriptConfig) (*ScriptStrategy, error) {
+	switch {
+	case cfg.Wasm != "" && len(cfg.Command) > 0:
+		return nil, fmt.Errorf("the script strategy takes either a command or a wasm module, not both")
+	case cfg.Wasm != "":
+		if err := checkWasmModule(cfg.Wasm, cfg.WasmMemoryMB); err != nil {
+			return nil, err
+		}
+	case len(cfg.Command) == 0:
+		return nil, fmt.Errorf("the script strategy needs a command or a wasm module")
+	}
+	if cfg.Warmup < 0 {
+		return nil, fmt.Errorf("script warmup must not be negative")
+	}
+	indicators, err := newScriptIndicators(cfg.Indicators)
+	if err != nil {
+		return nil, err
+	}
+	return &ScriptStrategy{cfg: cfg, indicators: indicators}, nil
+}
+
+// scriptIndicators computes the indicators configured for a scripted strategy on every candle
+type scriptIndicators struct {
+	names      []string
+	indicators map[string]Indicator
+}
+
+// newScriptIndicators parses indicators by name, e.g. {"fast": "ema:12", "bands": "bb:20:2"}
+func newScriptIndicators(specs map[string]string) (*scriptIndicators, error) {
+	s := &scriptIndicators{indicators: map[string]Indicator{}}
+	for name, spec := range specs {
+		ind, err := ParseIndicator(spec)
+		if err != nil {
+			return nil, fmt.Errorf("script indicator %s: %v", name, err)
+		}
+		s.indicators[name] = ind
+		s.names = append(s.names, name)
+	}
+	sort.Strings(s.names)
+	return s, nil
+}
+
+// update updates the indicators with c and returns their values. Indicators that are not ready yet are left
+// out, and bands and MACD add their other lines under suffixed names.
+func (s *scriptIndicators) update(c Candle) map[string]float64 {
+	values := map[string]float64{}
+	for _, name := range s.names {
+		ind := s.indicators[name]
+		ind.Update(c)
+		if !ind.Ready() {
+			continue
+		}
+		values[name] = ind.Value()
+		switch ind := ind.(type) {
+		case *BollingerBands:
+			values[name+"_upper"], values[name+"_lower"] = ind.Upper(), ind.Lower()
+		case *MACD:
+			values[name+"_signal"], values[name+"_histogram"] = ind.Signal(), ind.Histogram()
+		}
+	}
+	return values
+}
+
+// Name returns the strategy's config name
+func (s *ScriptStrategy) Name() string {
+	return "script"
+}
+
+// Warmup returns the candles the script needs
+func (s *ScriptStrategy) Warmup() int {
+	return s.cfg.Warmup
+}
+
+// fileModTimes returns the modification times of the script's files: the WebAssembly module, or the arguments
+// of the command line that are files, such as the script itself
+func (s *ScriptStrategy) fileModTimes() map[string]time.Time {
+	files := s.cfg.Command
+	if s.cfg.Wasm != "" {
+		files = []string{s.cfg.Wasm}
+	}
+	modTimes := map[string]time.Time{}
+	for _, arg := range files {
+		if info, err := os.Stat(arg); err == nil && info.Mode().IsRegular() {
+			modTimes[arg] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// changed reports whether a file of the script was modified, added or removed since the script
+// was started. A script whose file is missing, e.g. while an editor replaces it, fails to start and holds until
+// the file is back.
+func (s *ScriptStrategy) changed() bool {
+	return !maps.EqualFunc(s.fileModTimes(), s.modTimes, time.Time.Equal)
+}
+
+// startCommand starts the script's command line with an empty environment apart from PATH, returning the process
+// with its stdin and stdout
+func (s *ScriptStrategy) startCommand() (scriptProcess, io.WriteCloser, *os.File, error) {
+	cmd := exec.Command(s.cfg.Command[0], s.cfg.Command[1:]...)
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH")}
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// The strategy owns the stdout pipe rather than using StdoutPipe, so Stop can end the reader before Wait
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		stdin.Close()
+		return nil, nil, nil, err
+	}
+	cmd.Stdout = stdoutWriter
+	err = cmd.Start()
+	stdoutWriter.Close()
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		return nil, nil, nil, fmt.Errorf("error starting script: %v", err)
+	}
+	log.Printf("Script strategy started: %v (pid %d)", s.cfg.Command, cmd.Process.Pid)
+	return commandProcess{cmd}, stdin, stdout, nil
+}
+
+// start launches the script and replays the candle history to it
+func (s *ScriptStrategy) start() error {
+	s.modTimes = s.fileModTimes()
+	var process scriptProcess
+	var stdin io.WriteCloser
+	var stdout *os.File
+	var err error
+	if s.cfg.Wasm != "" {
+		process, stdin, stdout, err = startWasmModule(s.cfg.Wasm, s.cfg.WasmMemoryMB)
+	} else {
+		process, stdin, stdout, err = s.startCommand()
+	}
+	if err != nil {
+		return err
+	}
+	replies := make(chan []byte, 1)
+	go func() {
+		defer close(replies)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			replies <- append([]byte(nil), scanner.Bytes()...)
+		}
+	}()
+	s.process, s.stdin, s.stdout, s.replies = process, stdin, stdout, replies
+
+	for _, message := range s.history {
+		message.Replay = true
+		if _, err := s.send(message); err != nil {
+			return fmt.Errorf("error replaying candles to script: %v", err)
+		}
+	}
+	return nil
+}
+
+// Stop terminates the script. Closing stdin asks it to exit; a script that has not closed its stdout within a
+// second is killed. The reader of its replies is ended before the process is waited for.
+func (s *ScriptStrategy) Stop() {
+	if s.process == nil {
+		return
+	}
+	s.stdin.Close()
+	timeout := time.After(time.Second)
+	for open := true; open; {
+		select {
+		case _, open = <-s.replies:
+		case <-timeout:
+			s.process.Kill()
+			// A child the script started may still hold its stdout, so the pipe is closed here too
+			s.stdout.Close()
+		}
+	}
+	s.stdout.Close()
+	s.process.Wait()
+	s.process = nil
+}
+
+// send writes a message to the script and reads its reply
+func (s *ScriptStrategy) send(message scriptMessage) (scriptReply, error) {
+	var reply scriptReply
+	line, err := json.Marshal(message)
+	if err != nil {
+		return reply, err
+	}
+	if _, err := s.stdin.Write(append(line, '\n')); err != nil {
+		return reply, fmt.Errorf("error writing to script: %v", err)
+	}
+	select {
+	case answer, ok := <-s.replies:
+		if !ok {
+			return reply, fmt.Errorf("script exited")
+		}
+		if err := json.Unmarshal(answer, &reply); err != nil {
+			return reply, fmt.Errorf("invalid script reply %q: %v", answer, err)
+		}
+	case <-time.After(scriptReplyTimeout):
+		return reply, fmt.Errorf("script did not answer within %s", scriptReplyTimeout)
+	}
+	if reply.Action != ActionHold && reply.Action != ActionEnter && reply.Action != ActionExit {
+		return reply, fmt.Errorf("invalid script action %q. Use enter, exit or an empty action", reply.Action)
+	}
+	return reply, nil
+}
+
+// message updates the indicators with c and builds the candle's message
+func (s *ScriptStrategy) message(c Candle, inPosition bool) scriptMessage {
+	return scriptMessage{
+		Candle:     scriptCandle{OpenTime: c.OpenTime, Open: c.Open, High: c.High, Low: c.Low, Close: c.Close, Volume: c.Volume},
+		Indicators: s.indicators.update(c),
+		InPosition: inPosition,
+	}
+}
+
+// OnCandle sends the candle to the script and returns its signal. A script that fails to answer is
+// restarted on the next candle, and holds until then.
+func (s *ScriptStrategy) OnCandle(c Candle, inPosition bool) Signal {
+	message := s.message(c, inPosition)
+	defer func() {
+		s.history = append(s.history, message)
+		if len(s.history) > s.cfg.Warmup {
+			s.history = s.history[1:]
+		}
+	}()
+	if s.process != nil && s.cfg.Reload && s.changed() {
+		log.Printf("Script strategy changed, reloading %v", s.cfg.Command)
+		s.Stop()
+	}
+	if s.process == nil {
+		if err := s.start(); err != nil {
+			log.Printf("Script strategy failed, holding: %v", err)
+			s.Stop()
+			return Signal{}
+		}
+	}
+	reply, err := s.send(message)
+	if err != nil {
+		log.Printf("Script strategy failed, holding until it is restarted: %v", err)
+		s.Stop()
+		return Signal{}
+	}
+	return Signal{Action: reply.Action, Reason: reply.Reason, Amount: reply.Amount}
+}
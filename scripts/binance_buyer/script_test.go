@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestScriptStrategySignals(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell")
+	}
+	strategy, err := NewScriptStrategy(ScriptConfig{Command: []string{"sh", "-c", `while read line; do echo '{"action": "enter", "reason": "always", "amount": "10"}'; done`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer strategy.Stop()
+	for i := range 3 {
+		signal := strategy.OnCandle(hourlyTestCandle(i, 100), false)
+		if signal.Action != ActionEnter || signal.Reason != "always" || signal.Amount.Cmp(decimalOrZero("10")) != 0 {
+			t.Errorf("candle %d: signal %+v, want an entry of 10", i, signal)
+		}
+	}
+}
+
+func TestScriptStrategyStop(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no shell")
+	}
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "exits on end of input", script: `while read line; do echo '{}'; done`},
+		{name: "ignores end of input", script: `read line; echo '{}'; exec sleep 5`},
+		{name: "child holds stdout", script: `read line; echo '{}'; sleep 5 & wait`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := NewScriptStrategy(ScriptConfig{Command: []string{"sh", "-c", tt.script}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signal := strategy.OnCandle(hourlyTestCandle(0, 100), false); signal.Action != ActionHold {
+				t.Fatalf("signal %+v, want hold", signal)
+			}
+			if strategy.process == nil {
+				t.Fatal("script is not running")
+			}
+			stopped := make(chan struct{})
+			go func() {
+				strategy.Stop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-time.After(10 * time.Second):
+				t.Fatal("Stop did not return")
+			}
+			if strategy.process != nil {
+				t.Error("script still tracked after Stop")
+			}
+		})
+	}
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Secrets managers credentials can be fetched from
+const (
+	SecretsVault = "vault"
+	SecretsAWS   = "aws"
+)
+
+// Default prefixes of the secrets holding key pairs. Vault's starts with the mount of its KV version 2 engine.
+const (
+	defaultVaultSecretsPrefix = "secret/algo-trading/"
+	defaultAWSSecretsPrefix   = "algo-trading/"
+)
+
+// secretsBackend reads a key pair from a secrets manager, reporting false when there is no secret by that ID
+type secretsBackend interface {
+	getSecret(id string) (Credentials, bool, error)
+}
+
+// KeyRotator is implemented by clients whose API key can be replaced while they are in use
+type KeyRotator interface {
+	RotateKey(creds Credentials) error
+}
+
+// SecretsProvider fetches key pairs from a secrets manager at startup, each stored as a JSON object with api_key,
+// secret_key and optionally key_type fields under the prefix followed by the name the environment variables would
+// use, e.g. algo-trading/binance. Once clients use a key pair, it is polled so a rotated key replaces the old one
+// without a restart.
+type SecretsProvider struct {
+	backend secretsBackend
+	prefix  string
+	refresh time.Duration
+
+	mu       sync.Mutex
+	cached   map[string]Credentials
+	watchers map[string][]KeyRotator
+	watching sync.Once
+}
+
+// NewSecretsProvider creates the provider of a secrets manager, configured from its usual environment variables:
+// VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE for Vault, and the AWS region and credentials variables, the container
+// credentials endpoint or the instance metadata service for AWS. refresh is how often used key pairs are checked for
+// rotation, or 0 to never check.
+func NewSecretsProvider(provider, prefix string, refresh time.Duration) (*SecretsProvider, error) {
+	var backend secretsBackend
+	switch strings.ToLower(provider) {
+	case SecretsVault:
+		addr, token := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"), os.Getenv("VAULT_TOKEN")
+		if addr == "" || token == "" {
+			return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN are required to read credentials from vault")
+		}
+		backend = &vaultBackend{addr: addr, token: token, namespace: os.Getenv("VAULT_NAMESPACE"), httpClient: newHTTPClient()}
+		prefix = firstNonEmpty(prefix, defaultVaultSecretsPrefix)
+	case SecretsAWS:
+		region := firstNonEmpty(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"))
+		if region == "" {
+			return nil, fmt.Errorf("AWS_REGION is required to read credentials from AWS Secrets Manager")
+		}
+		endpoint := firstNonEmpty(os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER"), os.Getenv("AWS_ENDPOINT_URL"),
+			"https://secretsmanager."+region+".amazonaws.com")
+		backend = &awsSecretsBackend{region: region, endpoint: strings.TrimRight(endpoint, "/"), httpClient: newHTTPClient()}
+		prefix = firstNonEmpty(prefix, defaultAWSSecretsPrefix)
+	default:
+		return nil, fmt.Errorf("invalid secrets provider: %s. Use vault or aws", provider)
+	}
+	return &SecretsProvider{
+		backend:  backend,
+		prefix:   prefix,
+		refresh:  refresh,
+		cached:   map[string]Credentials{},
+		watchers: map[string][]KeyRotator{},
+	}, nil
+}
+
+// lookup fetches the key pair stored under a name
+func (p *SecretsProvider) lookup(name string) (Credentials, bool, error) {
+	creds, ok, err := p.backend.getSecret(p.prefix + name)
+	if err != nil {
+		return creds, false, fmt.Errorf("error fetching secret %s%s: %v", p.prefix, name, err)
+	}
+	if ok {
+		p.mu.Lock()
+		p.cached[name] = creds
+		p.mu.Unlock()
+	}
+	return creds, ok, nil
+}
+
+// watch rotates the key of a client using the key pair stored under a name whenever the secret changes. Clients
+// whose credentials did not come from the provider are left alone.
+func (p *SecretsProvider) watch(name string, creds Credentials, client ExchangeClient) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if cached, ok := p.cached[name]; !ok || cached.APIKey != creds.APIKey || p.refresh <= 0 {
+		return
+	}
+	rotator, ok := client.(KeyRotator)
+	if !ok {
+		log.Printf("Key rotation is not supported for this exchange. Restart to use a rotated %s key.", name)
+		return
+	}
+	p.watchers[name] = append(p.watchers[name], rotator)
+	p.watching.Do(func() { go p.poll() })
+}
+
+// poll checks the watched key pairs for rotation every refresh interval for the life of the process
+func (p *SecretsProvider) poll() {
+	ticker := time.NewTicker(p.refresh)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.mu.Lock()
+		names := make([]string, 0, len(p.watchers))
+		for name := range p.watchers {
+			names = append(names, name)
+		}
+		p.mu.Unlock()
+
+		for _, name := range names {
+			p.mu.Lock()
+			previous := p.cached[name]
+			p.mu.Unlock()
+			creds, ok, err := p.lookup(name)
+			if err != nil {
+				log.Printf("Error checking %s credentials for rotation: %v", name, err)
+				continue
+			}
+			if !ok {
+				log.Printf("Secret %s%s no longer exists. Keeping the current key.", p.prefix, name)
+				continue
+			}
+			if creds == previous {
+				continue
+			}
+
+			p.mu.Lock()
+			rotators := p.watchers[name]
+			p.mu.Unlock()
+			for _, rotator := range rotators {
+				if err := rotator.RotateKey(creds); err != nil {
+					log.Printf("Error rotating %s API key: %v", name, err)
+				}
+			}
+			log.Printf("Rotated the %s API key to %s", name, maskAPIKey(creds.APIKey))
+		}
+	}
+}
+
+// RotateKey replaces the API key the client authenticates with. Requests in flight finish with the old key.
+func (c *BinanceClient) RotateKey(creds Credentials) error {
+	signer, err := NewSigner(creds.KeyType, creds.SecretKey)
+	if err != nil {
+		return err
+	}
+	c.key.Store(&binanceKey{apiKey: creds.APIKey, signer: signer})
+	return nil
+}
+
+// RotateKey replaces the API key of the margin account
+func (c *BinanceMarginClient) RotateKey(creds Credentials) error {
+	return c.rest.RotateKey(creds)
+}
+
+// RotateKey replaces the API key of both the futures and the spot API
+func (c *BinanceFuturesClient) RotateKey(creds Credentials) error {
+	if err := c.rest.RotateKey(creds); err != nil {
+		return err
+	}
+	if c.spot != nil {
+		return c.spot.RotateKey(creds)
+	}
+	return nil
+}
+
+// vaultBackend reads secrets from the KV version 2 secrets engine of a HashiCorp Vault server. Secret IDs start with
+// the engine's mount, e.g. secret/algo-trading/binance.
+type vaultBackend struct {
+	addr       string
+	token      string
+	namespace  string
+	httpClient *http.Client
+}
+
+// getSecret reads the latest version of a secret
+func (b *vaultBackend) getSecret(id string) (Credentials, bool, error) {
+	mount, path, ok := strings.Cut(strings.Trim(id, "/"), "/")
+	if !ok {
+		return Credentials{}, false, fmt.Errorf("vault secret %s has no mount", id)
+	}
+	req, err := http.NewRequest("GET", b.addr+"/v1/"+mount+"/data/"+path, nil)
+	if err != nil {
+		return Credentials{}, false, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("X-Vault-Token", b.token)
+	if b.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", b.namespace)
+	}
+	res, err := readResult(b.httpClient, req)
+	if err != nil {
+		return Credentials{}, false, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return Credentials{}, false, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		var raw struct {
+			Errors []string `json:"errors"`
+		}
+		json.Unmarshal(res.Body, &raw)
+		return Credentials{}, false, fmt.Errorf("vault returned HTTP %d: %s", res.StatusCode, strings.Join(raw.Errors, "; "))
+	}
+
+	var raw struct {
+		Data struct {
+			Data Credentials `json:"data"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(res.Body, &raw); err != nil {
+		return Credentials{}, false, fmt.Errorf("error parsing response: %v", err)
+	}
+	return raw.Data.Data, true, nil
+}
+
+// awsSecretsBackend reads secrets from AWS Secrets Manager, signing requests with Signature Version 4
+type awsSecretsBackend struct {
+	region     string
+	endpoint   string
+	httpClient *http.Client
+}
+
+// getSecret reads the current version of a secret, whose string is the key pair's JSON object
+func (b *awsSecretsBackend) getSecret(id string) (Credentials, bool, error) {
+	awsCreds, err := awsCredentials(b.httpClient)
+	if err != nil {
+		return Credentials{}, false, err
+	}
+	body, _ := json.Marshal(map[string]string{"SecretId": id})
+	req, err := http.NewRequest("POST", b.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return Credentials{}, false, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, body, awsCreds, b.region, "secretsmanager", time.Now())
+	res, err := readResult(b.httpClient, req)
+	if err != nil {
+		return Credentials{}, false, err
+	}
+	if res.StatusCode != http.StatusOK {
+		var raw struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(res.Body, &raw)
+		if strings.HasSuffix(raw.Type, "ResourceNotFoundException") {
+			return Credentials{}, false, nil
+		}
+		return Credentials{}, false, fmt.Errorf("AWS Secrets Manager returned HTTP %d: %s %s", res.StatusCode, raw.Type, raw.Message)
+	}
+
+	var raw struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(res.Body, &raw); err != nil {
+		return Credentials{}, false, fmt.Errorf("error parsing response: %v", err)
+	}
+	var creds Credentials
+	if err := json.Unmarshal([]byte(raw.SecretString), &creds); err != nil {
+		return Credentials{}, false, fmt.Errorf("secret %s is not a JSON object with api_key and secret_key: %v", id, err)
+	}
+	return creds, true, nil
+}
+
+// awsCredential is an AWS access key, with the session token of temporary credentials
+type awsCredential struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	Token           string `json:"Token"`
+}
+
+// awsCredentials resolves the AWS credentials of the process from, in order, the AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY environment variables, the ECS or EKS container credentials endpoint and the EC2 instance
+// metadata service. Temporary credentials are resolved anew on every call, so they are never used expired.
+func awsCredentials(httpClient *http.Client) (awsCredential, error) {
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		return awsCredential{AccessKeyID: id, SecretAccessKey: secret, Token: os.Getenv("AWS_SESSION_TOKEN")}, nil
+	}
+
+	containerURL := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		containerURL = "http://169.254.170.2" + relative
+	}
+	if containerURL != "" {
+		headers := map[string]string{}
+		token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+		if tokenFile := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); tokenFile != "" {
+			data, err := os.ReadFile(tokenFile)
+			if err != nil {
+				return awsCredential{}, fmt.Errorf("error reading container authorization token: %v", err)
+			}
+			token = strings.TrimSpace(string(data))
+		}
+		if token != "" {
+			headers["Authorization"] = token
+		}
+		body, err := awsMetadataRequest(httpClient, "GET", containerURL, headers)
+		if err != nil {
+			return awsCredential{}, fmt.Errorf("error getting container credentials: %v", err)
+		}
+		return parseAWSCredential(body)
+	}
+
+	const imds = "http://169.254.169.254/latest"
+	token, err := awsMetadataRequest(httpClient, "PUT", imds+"/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "300"})
+	if err != nil {
+		return awsCredential{}, fmt.Errorf("no AWS credentials found in the environment, container or instance metadata: %v", err)
+	}
+	headers := map[string]string{"X-aws-ec2-metadata-token": string(token)}
+	role, err := awsMetadataRequest(httpClient, "GET", imds+"/meta-data/iam/security-credentials/", headers)
+	if err != nil {
+		return awsCredential{}, fmt.Errorf("error getting instance role: %v", err)
+	}
+	body, err := awsMetadataRequest(httpClient, "GET", imds+"/meta-data/iam/security-credentials/"+strings.TrimSpace(strings.SplitN(string(role), "\n", 2)[0]), headers)
+	if err != nil {
+		return awsCredential{}, fmt.Errorf("error getting instance credentials: %v", err)
+	}
+	return parseAWSCredential(body)
+}
+
+// awsMetadataRequest sends a request to a credentials endpoint and returns the body of its successful response
+func awsMetadataRequest(httpClient *http.Client, method, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	res, err := readResult(httpClient, req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d from %s", res.StatusCode, url)
+	}
+	return res.Body, nil
+}
+
+// parseAWSCredential parses the temporary credentials returned by a credentials endpoint
+func parseAWSCredential(body []byte) (awsCredential, error) {
+	var creds awsCredential
+	if err := json.Unmarshal(body, &creds); err != nil {
+		return creds, fmt.Errorf("error parsing AWS credentials: %v", err)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, fmt.Errorf("AWS credentials endpoint returned no access key")
+	}
+	return creds, nil
+}
+
+// signAWSRequest adds the AWS Signature Version 4 headers authenticating a request with a JSON body to a service
+func signAWSRequest(req *http.Request, body []byte, creds awsCredential, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.Token != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.Token)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{req.Method, cmp.Or(req.URL.EscapedPath(), "/"), req.URL.RawQuery, canonicalHeaders.String(),
+		signedHeaders, hex.EncodeToString(payloadHash[:])}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
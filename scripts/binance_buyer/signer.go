@@ -0,0 +1,124 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Types of Binance API keys, which differ in how requests are signed
+const (
+	KeyTypeHMAC    = "hmac"
+	KeyTypeEd25519 = "ed25519"
+	KeyTypeRSA     = "rsa"
+)
+
+// Signer signs the query of a Binance request with the secret of an API key
+type Signer interface {
+	Sign(payload string) (string, error)
+}
+
+// NewSigner creates the signer of a key type. HMAC keys are signed with the secret key itself. Ed25519 and RSA
+// keys are signed with the private key matching the public key registered with Binance, given as an
+// unencrypted PEM block or the path of a PEM file.
+func NewSigner(keyType, secret string) (Signer, error) {
+	switch strings.ToLower(keyType) {
+	case "", KeyTypeHMAC:
+		return hmacSigner{secret: []byte(secret)}, nil
+	case KeyTypeEd25519:
+		key, err := parsePrivateKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		ed, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is not an ed25519 key")
+		}
+		return ed25519Signer{key: ed}, nil
+	case KeyTypeRSA:
+		key, err := parsePrivateKey(secret)
+		if err != nil {
+			return nil, err
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is not an RSA key")
+		}
+		return rsaSigner{key: rsaKey}, nil
+	default:
+		return nil, fmt.Errorf("invalid key type: %s. Use hmac, ed25519 or rsa", keyType)
+	}
+}
+
+// parsePrivateKey parses a PKCS#8 private key, or a PKCS#1 RSA private key, from PEM text or a PEM file
+func parsePrivateKey(secret string) (crypto.PrivateKey, error) {
+	data := []byte(secret)
+	if !strings.Contains(secret, "-----BEGIN") {
+		var err error
+		if data, err = os.ReadFile(secret); err != nil {
+			return nil, fmt.Errorf("error reading private key: %v", err)
+		}
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("private key is not PEM encoded")
+	}
+	if strings.Contains(block.Type, "ENCRYPTED") {
+		return nil, fmt.Errorf("encrypted private keys are not supported")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %v", err)
+	}
+	return key, nil
+}
+
+// hmacSigner signs with the HMAC SHA256 of the secret key, hex encoded
+type hmacSigner struct {
+	secret []byte
+}
+
+// Sign returns the hex encoded HMAC SHA256 of the payload
+func (s hmacSigner) Sign(payload string) (string, error) {
+	h := hmac.New(sha256.New, s.secret)
+	h.Write([]byte(payload))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ed25519Signer signs with an Ed25519 private key, base64 encoded
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// Sign returns the base64 encoded Ed25519 signature of the payload
+func (s ed25519Signer) Sign(payload string) (string, error) {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, []byte(payload))), nil
+}
+
+// rsaSigner signs with an RSA private key using PKCS#1 v1.5 over SHA256, base64 encoded
+type rsaSigner struct {
+	key *rsa.PrivateKey
+}
+
+// Sign returns the base64 encoded RSA signature of the payload
+func (s rsaSigner) Sign(payload string) (string, error) {
+	digest := sha256.Sum256([]byte(payload))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing request: %v", err)
+	}
+	return base64.StdEncoding.EncodeToString(signature), nil
+}
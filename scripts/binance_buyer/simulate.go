@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// candleStep is a historical candle relative to the close before it, the unit price paths are resampled in
+type candleStep struct {
+	High, Low, Close float64
+	Volume           float64
+}
+
+// candleSteps returns the steps of consecutive candles
+func candleSteps(candles []Candle) []candleStep {
+	var steps []candleStep
+	for i := 1; i < len(candles); i++ {
+		prev := candles[i-1].Close
+		if prev <= 0 {
+			continue
+		}
+		c := candles[i]
+		steps = append(steps, candleStep{High: c.High / prev, Low: c.Low / prev, Close: c.Close / prev, Volume: c.QuoteVolume})
+	}
+	return steps
+}
+
+// bootstrapPath resamples n steps as blocks of consecutive historical steps, so the path keeps the volatility
+// clustering of the history within each block
+func bootstrapPath(rng *rand.Rand, steps []candleStep, n, block int) []candleStep {
+	block = min(block, len(steps))
+	path := make([]candleStep, 0, n)
+	for len(path) < n {
+		start := rng.IntN(len(steps) - block + 1)
+		path = append(path, steps[start:start+min(block, n-len(path))]...)
+	}
+	return path
+}
+
+// scheduleOutcome is the result of executing a schedule on one price path, relative to the arrival price of 1
+type scheduleOutcome struct {
+	Average, TWAP, VWAP float64
+}
+
+// executeSchedule runs a schedule on a path starting at price 1. Each candle executes its weight at the
+// candle's typical price: BUY runs spend the same quote per unit of weight and SELL runs sell the same base.
+func executeSchedule(path []candleStep, weights []float64, side string) scheduleOutcome {
+	price := 1.0
+	var weightSum, quote, base, priceSum, volume, notional float64
+	for i, step := range path {
+		typical := price * (step.High + step.Low + step.Close) / 3
+		price *= step.Close
+		priceSum += typical
+		volume += step.Volume
+		notional += step.Volume * typical
+		weightSum += weights[i]
+		if side == "BUY" {
+			quote += weights[i]
+			base += weights[i] / typical
+		} else {
+			base += weights[i]
+			quote += weights[i] * typical
+		}
+	}
+	outcome := scheduleOutcome{Average: quote / base, TWAP: priceSum / float64(len(path))}
+	outcome.VWAP = outcome.TWAP
+	if volume > 0 {
+		outcome.VWAP = notional / volume
+	}
+	return outcome
+}
+
+// scheduleWeights returns the weight of each candle of a run starting at start: even for TWAP, and the
+// historical share of volume of the candle's hour of day for VWAP
+func scheduleWeights(algo string, profile []float64, start time.Time, interval time.Duration, n int) []float64 {
+	weights := make([]float64, n)
+	for i := range weights {
+		weights[i] = 1
+		if algo == AlgoVWAP {
+			weights[i] = profile[start.Add(time.Duration(i)*interval).UTC().Hour()]
+		}
+	}
+	return weights
+}
+
+// costBps returns how much worse than benchmark a run's average price is, in basis points: paid above it when
+// buying, received below it when selling
+func costBps(average, benchmark float64, side string) float64 {
+	cost := (average/benchmark - 1) * 10000
+	if side == "SELL" {
+		return -cost
+	}
+	return cost
+}
+
+// percentile returns the p-th percentile of sorted values by linear interpolation
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := min(lower+1, len(sorted)-1)
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
+
+// meanStdDev returns the mean and standard deviation of values
+func meanStdDev(values []float64) (float64, float64) {
+	var sum, squares float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)))
+}
+
+// runSimulate runs TWAP or VWAP schedules of several durations on price paths block-bootstrapped from the
+// symbol's history and prints the distribution of their execution cost against the arrival price and the
+// market's TWAP and VWAP over each path
+func runSimulate(args []string) error {
+	fs, common := newFlagSet("simulate")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair symbol")
+	side := fs.String("side", "BUY", "Order side: BUY (the same quote per slice) or SELL (the same base per slice)")
+	algo := fs.String("algo", AlgoTWAP, "Schedule to simulate: twap or vwap")
+	durations := fs.String("durations", "1H,4H,12H,1D", "Comma-separated run durations to compare (e.g., 30m,2H,1D)")
+	interval := fs.String("interval", "5m", "Kline interval the history is resampled in, the resolution of the simulated schedule")
+	lookback := fs.Int("lookback-days", 30, "Days of history the paths are resampled from")
+	block := fs.String("block", "4H", "Length of the blocks of consecutive history resampled together")
+	paths := fs.Int("paths", 2000, "Number of simulated price paths per duration")
+	seed := fs.Uint64("seed", 0, "Random seed for reproducible simulations (0 for a random one)")
+	dataDir := fs.String("data-dir", "", "Read the history from a directory filled by trade data download instead of the exchange")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+
+	*side = strings.ToUpper(*side)
+	if *side != "BUY" && *side != "SELL" {
+		return fmt.Errorf("invalid side: %s. Use BUY or SELL", *side)
+	}
+	*algo = strings.ToLower(*algo)
+	if *algo != AlgoTWAP && *algo != AlgoVWAP {
+		return fmt.Errorf("invalid algo: %s. Use twap or vwap", *algo)
+	}
+	period, ok := klineDurations[*interval]
+	if !ok {
+		return fmt.Errorf("invalid interval %q. Use a kline interval such as 1m, 5m or 15m", *interval)
+	}
+	var runDurations []time.Duration
+	for _, spec := range strings.Split(*durations, ",") {
+		d, err := parseDuration(strings.TrimSpace(spec))
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %v", spec, err)
+		}
+		if d < period {
+			return fmt.Errorf("duration %s is shorter than the %s interval", spec, *interval)
+		}
+		runDurations = append(runDurations, d)
+	}
+	blockLength, err := parseDuration(*block)
+	if err != nil {
+		return fmt.Errorf("invalid block: %v", err)
+	}
+	blockCandles := int(blockLength / period)
+	if blockCandles < 1 {
+		return fmt.Errorf("block must be at least one %s candle", *interval)
+	}
+	if *lookback < 1 || *paths < 1 {
+		return fmt.Errorf("-lookback-days and -paths must be at least 1")
+	}
+
+	end := time.Now()
+	start := end.AddDate(0, 0, -*lookback)
+	var candles []Candle
+	if *dataDir != "" {
+		candles, err = loadStoredCandles(*dataDir, strings.ToUpper(*symbol), *interval, start, end)
+	} else {
+		var client *ReadOnlyClient
+		if client, err = common.readOnlyClient(); err != nil {
+			return err
+		}
+		candles, err = client.GetKlines(strings.ToUpper(*symbol), *interval, start, end)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting %s klines: %v", *symbol, err)
+	}
+	steps := candleSteps(candles)
+	if len(steps) < blockCandles {
+		return fmt.Errorf("%d %s candles of history are fewer than one block of %s", len(candles), *interval, *block)
+	}
+	profile := buildVolumeProfile(candles)
+
+	rng := rand.New(rand.NewPCG(*seed, *seed))
+	if *seed == 0 {
+		rng = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DURATION\tVS ARRIVAL MEAN\tSTD DEV\tP5\tMEDIAN\tP95\tVS TWAP MEAN\tVS VWAP MEAN")
+	for _, duration := range runDurations {
+		n := int(duration / period)
+		weights := scheduleWeights(*algo, profile, end, period, n)
+		var vsArrival, vsTWAP, vsVWAP []float64
+		for range *paths {
+			outcome := executeSchedule(bootstrapPath(rng, steps, n, blockCandles), weights, *side)
+			vsArrival = append(vsArrival, costBps(outcome.Average, 1, *side))
+			vsTWAP = append(vsTWAP, costBps(outcome.Average, outcome.TWAP, *side))
+			vsVWAP = append(vsVWAP, costBps(outcome.Average, outcome.VWAP, *side))
+		}
+		slices.Sort(vsArrival)
+		mean, stdDev := meanStdDev(vsArrival)
+		twapMean, _ := meanStdDev(vsTWAP)
+		vwapMean, _ := meanStdDev(vsVWAP)
+		fmt.Fprintf(w, "%s\t%+.1f bps\t%.1f bps\t%+.1f\t%+.1f\t%+.1f\t%+.1f bps\t%+.1f bps\n", duration, mean, stdDev,
+			percentile(vsArrival, 5), percentile(vsArrival, 50), percentile(vsArrival, 95), twapMean, vwapMean)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%s %s of %s on %d paths per duration, resampled in %s blocks from %d %s candles over %d days\n",
+		strings.ToUpper(*algo), *side, strings.ToUpper(*symbol), *paths, *block, len(candles), *interval, *lookback)
+	fmt.Println("Costs are in basis points of the benchmark, positive when the run did worse. Market impact and fees are not modeled.")
+	return nil
+}
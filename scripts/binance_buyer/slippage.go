@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log"
+	"log/slog"
+)
+
+// SlippageLimit stops or pauses a run once its cumulative slippage exceeds MaxBps, where zero is disabled
+type SlippageLimit struct {
+	MaxBps float64 `json:"max_bps"`
+	Pause  bool    `json:"pause"`
+}
+
+// Enabled reports whether a threshold is set
+func (l SlippageLimit) Enabled() bool {
+	return l.MaxBps > 0
+}
+
+// crossed reports whether cumulative slippage moving from before to after crossed the threshold
+func (l SlippageLimit) crossed(before, after float64) bool {
+	return l.Enabled() && before <= l.MaxBps && after > l.MaxBps
+}
+
+// slippageBps returns how much worse the fill price is than the pre-order mid-price in basis points,
+// positive when buying above or selling below it
+func slippageBps(side string, fill, mid float64) float64 {
+	return -priceImprovementBps(side, fill, mid)
+}
+
+// recordSlippage records the slippage of a market order's fill against the mid-price quoted before it was placed
+func (s *RunState) recordSlippage(ticker *BookTicker, order *Order) {
+	executed := decimalOrZero(order.ExecutedQty)
+	if ticker == nil || executed.IsZero() {
+		return
+	}
+	quote := filledQuote(order)
+	bps := slippageBps(s.Config.Side, quote.Div(executed).Float64(), ticker.Mid())
+
+	s.SlippageBpsQuote += bps * quote.Float64()
+	s.SlippageQuote += quote.Float64()
+	slog.Info("Slice slippage", "symbol", s.Config.Symbol, "order_id", order.OrderID, "mid", ticker.Mid(), "slippage_bps", bps, "cumulative_slippage_bps", s.cumulativeSlippageBps())
+}
+
+// cumulativeSlippageBps returns the quote-weighted average slippage of the run's market orders
+func (s *RunState) cumulativeSlippageBps() float64 {
+	if s.SlippageQuote == 0 {
+		return 0
+	}
+	return s.SlippageBpsQuote / s.SlippageQuote
+}
+
+// handleSlippageLimit pauses the run through its control, or reports that the run must be aborted
+func (s *RunState) handleSlippageLimit() bool {
+	limit := s.Config.Slippage
+	if limit.Pause && s.control != nil {
+		log.Printf("Cumulative slippage %.2f bps exceeds %.2f bps. Pausing the run until it is resumed.", s.cumulativeSlippageBps(), limit.MaxBps)
+		s.control.pause()
+		return false
+	}
+	log.Printf("Cumulative slippage %.2f bps exceeds %.2f bps. Aborting the run.", s.cumulativeSlippageBps(), limit.MaxBps)
+	return true
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// AssetValue is the balance of one asset in an account snapshot and its value in the quote asset
+type AssetValue struct {
+	Asset  string  `json:"asset"`
+	Free   Decimal `json:"free"`
+	Locked Decimal `json:"locked"`
+	// Price is the asset's price in the quote asset, 1 for the quote asset itself
+	Price float64 `json:"price"`
+	Value float64 `json:"value"`
+}
+
+// Snapshot is the balances of an account at a point in time, marked to market in a quote asset
+type Snapshot struct {
+	Time       time.Time    `json:"time"`
+	QuoteAsset string       `json:"quote_asset"`
+	Value      float64      `json:"value"`
+	Assets     []AssetValue `json:"assets"`
+	// Unpriced lists the assets held without a market against the quote asset, which Value leaves out
+	Unpriced []string `json:"unpriced,omitempty"`
+}
+
+// takeSnapshot reads the balances of the account, every non-empty one or those of assets when set, and values
+// them in quoteAsset. Assets without a pair against the quote asset in either direction are listed as unpriced.
+func takeSnapshot(client ExchangeClient, assets []string, quoteAsset string) (*Snapshot, error) {
+	var balances []Balance
+	if len(assets) == 0 {
+		lister, ok := baseClient(client).(AccountBalances)
+		if !ok {
+			return nil, fmt.Errorf("the exchange client cannot list every balance. Name the assets with -assets")
+		}
+		var err error
+		if balances, err = lister.GetBalances(); err != nil {
+			return nil, fmt.Errorf("error getting balances: %v", err)
+		}
+	}
+	for _, asset := range assets {
+		free, err := client.GetBalance(asset)
+		if err != nil {
+			return nil, fmt.Errorf("error getting %s balance: %v", asset, err)
+		}
+		balances = append(balances, Balance{Asset: asset, Free: free.String(), Locked: "0"})
+	}
+
+	snapshot := &Snapshot{Time: time.Now().UTC(), QuoteAsset: quoteAsset}
+	for _, balance := range balances {
+		value := AssetValue{Asset: balance.Asset, Free: decimalOrZero(balance.Free), Locked: decimalOrZero(balance.Locked)}
+		price, err := assetPrice(client, balance.Asset, quoteAsset)
+		if err != nil {
+			log.Printf("Not valuing %s: %v", balance.Asset, err)
+			snapshot.Unpriced = append(snapshot.Unpriced, balance.Asset)
+		} else {
+			value.Price = price
+			value.Value = value.Free.Add(value.Locked).Float64() * price
+			snapshot.Value += value.Value
+		}
+		snapshot.Assets = append(snapshot.Assets, value)
+	}
+	return snapshot, nil
+}
+
+// assetPrice returns the price of asset in quoteAsset from their pair, inverting the price when the quote asset
+// is the pair's base asset
+func assetPrice(client MarketReader, asset, quoteAsset string) (float64, error) {
+	if asset == quoteAsset {
+		return 1, nil
+	}
+	if price, err := client.GetPrice(asset + quoteAsset); err == nil && price > 0 {
+		return price, nil
+	}
+	price, err := client.GetPrice(quoteAsset + asset)
+	if err != nil {
+		return 0, fmt.Errorf("error getting the %s%s or %s%s price: %v", asset, quoteAsset, quoteAsset, asset, err)
+	}
+	if price <= 0 {
+		return 0, fmt.Errorf("invalid %s%s price: %g", quoteAsset, asset, price)
+	}
+	return 1 / price, nil
+}
+
+// appendSnapshot appends a snapshot to the store at path as a JSON line
+func appendSnapshot(path string, snapshot *Snapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening snapshot store: %v", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing snapshot: %v", err)
+	}
+	return nil
+}
+
+// loadSnapshots reads every snapshot of the store at path, in the order they were taken
+func loadSnapshots(path string) ([]Snapshot, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot store: %v", err)
+	}
+	defer file.Close()
+
+	var snapshots []Snapshot
+	decoder := json.NewDecoder(file)
+	for {
+		var snapshot Snapshot
+		err := decoder.Decode(&snapshot)
+		if errors.Is(err, io.EOF) {
+			return snapshots, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing snapshot %d: %v", len(snapshots)+1, err)
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+}
+
+// snapshotMetrics returns the equity curve of the snapshots in the store at path. The account counts as in a
+// position whenever it holds value in assets other than the quote asset.
+func snapshotMetrics(path string) (PerformanceMetrics, []EquityPoint, error) {
+	snapshots, err := loadSnapshots(path)
+	if err != nil {
+		return PerformanceMetrics{}, nil, err
+	}
+	if len(snapshots) == 0 {
+		return PerformanceMetrics{}, nil, fmt.Errorf("no snapshots in %s", path)
+	}
+	var curve []EquityPoint
+	for _, snapshot := range snapshots {
+		if snapshot.QuoteAsset != snapshots[0].QuoteAsset {
+			return PerformanceMetrics{}, nil, fmt.Errorf("snapshots in %s are valued in both %s and %s", path, snapshots[0].QuoteAsset, snapshot.QuoteAsset)
+		}
+		point := EquityPoint{Time: snapshot.Time, Equity: snapshot.Value}
+		for _, asset := range snapshot.Assets {
+			point.InPosition = point.InPosition || (asset.Asset != snapshot.QuoteAsset && asset.Value > 0)
+		}
+		curve = append(curve, point)
+	}
+	source := fmt.Sprintf("Snapshots %s in %s", path, snapshots[0].QuoteAsset)
+	return computeMetrics(source, curve, nil, 0), curve, nil
+}
+
+// runSnapshot records account snapshots on a cron schedule until interrupted, or once with -once
+func runSnapshot(args []string) error {
+	fs, common := newFlagSet("snapshot")
+	store := fs.String("store", "snapshots.jsonl", "File the snapshots are appended to as JSON lines")
+	quoteAsset := fs.String("quote", "USDT", "Asset the balances are valued in")
+	assetList := fs.String("assets", "", "Comma-separated assets to snapshot (empty for every non-empty balance of the account)")
+	schedule := fs.String("schedule", "@hourly", "Cron schedule of the snapshots: minute hour day-of-month month day-of-week or @hourly/@daily")
+	timezone := fs.String("timezone", "Local", "Time zone the schedule is evaluated in (e.g., UTC, Europe/London)")
+	once := fs.Bool("once", false, "Take a single snapshot and exit, e.g. when run from the system's cron")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if *store == "" {
+		return fmt.Errorf("-store is required")
+	}
+	cron, err := ParseCron(*schedule)
+	if err != nil {
+		return err
+	}
+	location, err := time.LoadLocation(*timezone)
+	if err != nil {
+		return fmt.Errorf("error loading timezone: %v", err)
+	}
+	quote := strings.ToUpper(*quoteAsset)
+	var assets []string
+	for _, asset := range strings.Split(*assetList, ",") {
+		if asset = strings.ToUpper(strings.TrimSpace(asset)); asset != "" {
+			assets = append(assets, asset)
+		}
+	}
+
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+	snap := func() error {
+		snapshot, err := takeSnapshot(client, assets, quote)
+		if err != nil {
+			return err
+		}
+		if err := appendSnapshot(*store, snapshot); err != nil {
+			return err
+		}
+		log.Printf("Snapshot of %d asset(s) worth %.2f %s written to %s", len(snapshot.Assets), snapshot.Value, quote, *store)
+		return nil
+	}
+	if *once {
+		return snap()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := snap(); err != nil {
+		return err
+	}
+	for {
+		next := cron.Next(time.Now().In(location))
+		if next.IsZero() {
+			return fmt.Errorf("schedule %q has no upcoming activations", cron)
+		}
+		log.Printf("Next snapshot at %s", next.Format(time.RFC3339))
+		if !sleepContext(ctx, time.Until(next)) {
+			return nil
+		}
+		// A failed snapshot leaves a gap in the curve rather than stopping the scheduler
+		if err := snap(); err != nil {
+			log.Printf("Error taking snapshot: %v", err)
+		}
+	}
+}
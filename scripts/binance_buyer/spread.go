@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// spreadPollInterval is how often the book ticker is rechecked while a slice is delayed by a wide spread
+const spreadPollInterval = 5 * time.Second
+
+// SpreadLimit holds back slices while the bid-ask spread is wider than MaxBps basis points of the mid-price,
+// where a zero MaxBps is disabled. With Delay set a slice waits for the spread to narrow, for up to one slice
+// interval, instead of being skipped at once.
+type SpreadLimit struct {
+	MaxBps float64 `json:"max_bps"`
+	Delay  bool    `json:"delay"`
+}
+
+// Enabled reports whether the limit is set
+func (s SpreadLimit) Enabled() bool {
+	return s.MaxBps > 0
+}
+
+// SpreadBps returns the bid-ask spread in basis points of the mid-price
+func (t *BookTicker) SpreadBps() float64 {
+	if t.Mid() <= 0 {
+		return 0
+	}
+	return (t.AskPrice - t.BidPrice) / t.Mid() * 10000
+}
+
+// checkSpread rechecks the book ticker and reports whether a slice may be executed. When the limit is
+// configured to delay, it waits up to maxWait for the spread to narrow, returning false once maxWait has
+// passed or ctx is cancelled.
+func checkSpread(ctx context.Context, client ExchangeClient, symbol string, limit SpreadLimit, maxWait time.Duration) bool {
+	if !limit.Enabled() {
+		return true
+	}
+
+	deadline := time.Now().Add(maxWait)
+	delayed := false
+	for {
+		ticker, err := client.GetBookTicker(symbol)
+		if err != nil {
+			log.Printf("Error checking spread: %v", err)
+			return false
+		}
+		spread := ticker.SpreadBps()
+		if spread <= limit.MaxBps {
+			if delayed {
+				log.Printf("Spread %.2f bps is back within %g bps. Resuming.", spread, limit.MaxBps)
+			}
+			return true
+		}
+		wait := min(spreadPollInterval, time.Until(deadline))
+		if !limit.Delay || wait <= 0 {
+			log.Printf("Spread %.2f bps is wider than %g bps. Skipping slice.", spread, limit.MaxBps)
+			return false
+		}
+		if !delayed {
+			log.Printf("Spread %.2f bps is wider than %g bps. Delaying slice until it narrows.", spread, limit.MaxBps)
+			delayed = true
+		}
+		if !sleepContext(ctx, wait) {
+			return false
+		}
+	}
+}
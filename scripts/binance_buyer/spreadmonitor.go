@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// eventSpreadAlert is the notification sent when a pair's price diverges across exchanges
+const eventSpreadAlert = "spread_alert"
+
+// venue is an exchange the spread monitor compares, with its best bid and ask from the latest scan
+type venue struct {
+	Name   string
+	Client ExchangeClient
+	Ticker *BookTicker
+}
+
+// crossSpread is the opportunity of buying a pair at the ask of one exchange and selling it at the bid of
+// another
+type crossSpread struct {
+	Buy, Sell *venue
+	// Bps is the gain after paying the fee on both trades, in basis points
+	Bps float64
+}
+
+// widestSpread returns the widest spread after feeBps per trade between the venues with a bid and ask, and
+// false when fewer than two have one
+func widestSpread(venues []*venue, feeBps float64) (crossSpread, bool) {
+	var best crossSpread
+	found := false
+	for _, buy := range venues {
+		for _, sell := range venues {
+			if buy == sell || buy.Ticker == nil || sell.Ticker == nil || buy.Ticker.AskPrice <= 0 || sell.Ticker.BidPrice <= 0 {
+				continue
+			}
+			bps := (sell.Ticker.BidPrice*(1-feeBps/10000)/(buy.Ticker.AskPrice*(1+feeBps/10000)) - 1) * 10000
+			if !found || bps > best.Bps {
+				best, found = crossSpread{Buy: buy, Sell: sell, Bps: bps}, true
+			}
+		}
+	}
+	return best, found
+}
+
+// execute buys notional of the quote asset's worth of symbol at market on the cheaper exchange and sells the
+// same base quantity at market on the richer one, after checking both accounts can cover their side. It
+// returns the quote asset gained. A failed sell leaves the bought base asset on the cheaper exchange.
+func (s crossSpread) execute(symbol, quoteAsset string, notional Decimal) (Decimal, error) {
+	baseAsset := strings.TrimSuffix(symbol, quoteAsset)
+	filters, err := s.Buy.Client.GetSymbolFilters(symbol)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error getting symbol filters for %s on %s: %v", symbol, s.Buy.Name, err)
+	}
+	sellFilters, err := s.Sell.Client.GetSymbolFilters(symbol)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error getting symbol filters for %s on %s: %v", symbol, s.Sell.Name, err)
+	}
+	quantity := sellFilters.RoundQuantity(filters.RoundQuantity(notional.Div(NewDecimalFromFloat(s.Buy.Ticker.AskPrice))))
+	if err := sellFilters.ValidateOrder(quantity, NewDecimalFromFloat(s.Sell.Ticker.BidPrice)); err != nil {
+		return Decimal{}, fmt.Errorf("cannot sell %s %s on %s: %v", quantity, baseAsset, s.Sell.Name, err)
+	}
+	quoteFree, err := s.Buy.Client.GetBalance(quoteAsset)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error getting %s balance on %s: %v", quoteAsset, s.Buy.Name, err)
+	}
+	baseFree, err := s.Sell.Client.GetBalance(baseAsset)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error getting %s balance on %s: %v", baseAsset, s.Sell.Name, err)
+	}
+	if quoteFree.LessThan(notional) || baseFree.LessThan(quantity) {
+		return Decimal{}, fmt.Errorf("insufficient balance: %s needs %s %s (free %s) and %s needs %s %s (free %s)",
+			s.Buy.Name, notional, quoteAsset, quoteFree, s.Sell.Name, quantity, baseAsset, baseFree)
+	}
+
+	bought, err := s.Buy.Client.PlaceOrder(OrderRequest{Symbol: symbol, Side: "BUY", Type: OrderTypeMarket, Quantity: quantity})
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error placing BUY order on %s: %v", s.Buy.Name, err)
+	}
+	log.Printf("Bought %s %s on %s for %s %s (order %s)", bought.ExecutedQty, baseAsset, s.Buy.Name, filledQuote(bought), quoteAsset, bought.OrderID)
+	sold, err := s.Sell.Client.PlaceOrder(OrderRequest{Symbol: symbol, Side: "SELL", Type: OrderTypeMarket, Quantity: quantity})
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error placing SELL order on %s, holding %s %s on %s: %v", s.Sell.Name, bought.ExecutedQty, baseAsset, s.Buy.Name, err)
+	}
+	log.Printf("Sold %s %s on %s for %s %s (order %s)", sold.ExecutedQty, baseAsset, s.Sell.Name, filledQuote(sold), quoteAsset, sold.OrderID)
+	return filledQuote(sold).Sub(filledQuote(bought)), nil
+}
+
+// runSpreadMonitor compares a pair's best bid and ask across exchanges, logging the widest spread after fees
+// and alerting when it reaches the threshold, and with -execute trades the spread within strict notional
+// limits
+func runSpreadMonitor(args []string) error {
+	fs, common := newFlagSet("spread-monitor")
+	symbol := fs.String("symbol", "BTCUSDT", "Trading pair to compare across exchanges")
+	exchanges := fs.String("exchanges", "binance,kraken", "Comma-separated exchanges to compare, each using its own <EXCHANGE>_API_KEY and <EXCHANGE>_SECRET_KEY credentials")
+	quoteAsset := fs.String("quote-asset", "USDT", "Quote asset of the pair, spent on the cheaper exchange")
+	thresholdBps := fs.Float64("threshold-bps", 50, "Spread after fees, in basis points, that raises an alert")
+	feeBps := fs.Float64("fee-bps", 10, "Trading fee per trade in basis points")
+	interval := fs.String("interval", "10s", "Time between scans (e.g., 10s, 1m)")
+	cooldown := fs.String("alert-cooldown", "5m", "Minimum time between alerts for the same pair of exchanges")
+	once := fs.Bool("once", false, "Scan once and exit")
+	execute := fs.Bool("execute", false, "Buy on the cheaper exchange and sell on the richer one when the spread reaches -threshold-bps")
+	maxNotional := fs.Float64("max-notional", 0, "Amount of the quote asset bought per trade, required with -execute")
+	maxTotalNotional := fs.Float64("max-total-notional", 0, "Total amount of the quote asset bought before the monitor stops executing, required with -execute")
+	slackWebhook := fs.String("slack-webhook", os.Getenv("SLACK_WEBHOOK_URL"), "Send alerts to this Slack incoming webhook URL (default from SLACK_WEBHOOK_URL)")
+	discordWebhook := fs.String("discord-webhook", os.Getenv("DISCORD_WEBHOOK_URL"), "Send alerts to this Discord webhook URL (default from DISCORD_WEBHOOK_URL)")
+	webhookURL := fs.String("webhook-url", "", "Post alerts as spread_alert JSON events to this URL")
+	webhookSecret := fs.String("webhook-secret", os.Getenv("WEBHOOK_SECRET"), "Sign webhook events with HMAC-SHA256 under this secret (default from WEBHOOK_SECRET)")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+
+	pair := strings.ToUpper(*symbol)
+	var names []string
+	for _, name := range strings.Split(*exchanges, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) < 2 {
+		return fmt.Errorf("at least two exchanges are required")
+	}
+	scanInterval, err := parseDuration(*interval)
+	if err != nil {
+		return fmt.Errorf("error parsing interval: %v", err)
+	}
+	alertCooldown, err := parseDuration(*cooldown)
+	if err != nil {
+		return fmt.Errorf("error parsing alert cooldown: %v", err)
+	}
+	if !strings.HasSuffix(pair, strings.ToUpper(*quoteAsset)) {
+		return fmt.Errorf("%s does not trade against %s", pair, strings.ToUpper(*quoteAsset))
+	}
+	if *execute && (*maxNotional <= 0 || *maxTotalNotional < *maxNotional) {
+		return fmt.Errorf("-execute requires a positive -max-notional and a -max-total-notional of at least as much")
+	}
+	var venues []*venue
+	for _, name := range names {
+		client, err := common.clientFor(name, common.market, common.testnet)
+		if err != nil {
+			return fmt.Errorf("error creating %s client: %v", name, err)
+		}
+		venues = append(venues, &venue{Name: name, Client: client})
+	}
+
+	notifier := NewNotifier()
+	if *slackWebhook != "" {
+		notifier.Add(NewSlackBackend(*slackWebhook), NotifyErrors)
+	}
+	if *discordWebhook != "" {
+		notifier.Add(NewDiscordBackend(*discordWebhook), NotifyErrors)
+	}
+	if *webhookURL != "" {
+		notifier.Add(NewEventWebhookBackend(*webhookURL, *webhookSecret), NotifyErrors)
+	}
+	notifier = notifier.Start()
+	defer notifier.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	monitorID := newRunID()
+	notional := NewDecimalFromFloat(*maxNotional)
+	lastAlert := map[string]time.Time{}
+	var traded Decimal
+	for {
+		for _, v := range venues {
+			if v.Ticker, err = v.Client.GetBookTicker(pair); err != nil {
+				log.Printf("Error getting book ticker for %s on %s: %v", pair, v.Name, err)
+			}
+		}
+
+		if spread, ok := widestSpread(venues, *feeBps); ok {
+			log.Printf("%s: buy on %s at %.8f, sell on %s at %.8f: %+.2f bps after fees", pair,
+				spread.Buy.Name, spread.Buy.Ticker.AskPrice, spread.Sell.Name, spread.Sell.Ticker.BidPrice, spread.Bps)
+			route := spread.Buy.Name + ">" + spread.Sell.Name
+			if spread.Bps >= *thresholdBps && time.Since(lastAlert[route]) >= alertCooldown {
+				lastAlert[route] = time.Now()
+				log.Printf("Spread alert: %s is %.2f bps richer on %s than on %s after fees", pair, spread.Bps, spread.Sell.Name, spread.Buy.Name)
+				notifier.Notify(notifyEvent{Time: time.Now(), RunID: monitorID, Symbol: pair, Event: eventSpreadAlert, Args: []any{
+					"buy_exchange", spread.Buy.Name, "ask", spread.Buy.Ticker.AskPrice,
+					"sell_exchange", spread.Sell.Name, "bid", spread.Sell.Ticker.BidPrice,
+					"spread_bps", fmt.Sprintf("%.2f", spread.Bps),
+				}})
+			}
+			switch {
+			case !*execute || spread.Bps < *thresholdBps:
+			case traded.Add(notional).GreaterThan(NewDecimalFromFloat(*maxTotalNotional)):
+				log.Printf("Total notional limit of %g reached. Not trading the spread.", *maxTotalNotional)
+			default:
+				traded = traded.Add(notional)
+				gained, err := spread.execute(pair, strings.ToUpper(*quoteAsset), notional)
+				if err != nil {
+					notifier.Notify(notifyEvent{Time: time.Now(), RunID: monitorID, Symbol: pair, Event: AuditError, Args: []any{"error", err.Error()}})
+					return fmt.Errorf("trading the %s spread from %s to %s failed: %v", pair, spread.Buy.Name, spread.Sell.Name, err)
+				}
+				log.Printf("Traded the %s spread from %s to %s for a gain of %s", pair, spread.Buy.Name, spread.Sell.Name, gained)
+			}
+		}
+
+		if *once || !sleepContext(ctx, scanInterval) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// defaultStarlarkMaxSteps bounds the computation steps of each call into a Starlark strategy unless configured
+const defaultStarlarkMaxSteps = 1_000_000
+
+// starlarkFileOptions is the Starlark dialect strategies are written in: while loops and top-level statements
+// are allowed, recursion is not
+var starlarkFileOptions = &syntax.FileOptions{Set: true, While: true, TopLevelControl: true}
+
+// starlarkPredeclared are the modules available to Starlark strategies besides the language's built-ins
+var starlarkPredeclared = starlark.StringDict{"math": math.Module}
+
+// StarlarkConfig holds the settings of a strategy written in Starlark
+type StarlarkConfig struct {
+	// File is the script defining the strategy's on_candle function
+	File string `json:"file"`
+	// Indicators are the indicators computed for the script on every candle, by name, e.g. {"fast": "ema:12"}
+	Indicators map[string]string `json:"indicators,omitempty"`
+	// Warmup is how many candles the script needs before it can signal, and how many it sees in ctx.candles
+	Warmup int `json:"warmup"`
+	// Reload reloads the script when its file changes, so a strategy can be swapped while the engine keeps running
+	Reload bool `json:"reload,omitempty"`
+	// MaxSteps bounds the computation steps of each call, so a runaway loop cannot stall the engine
+	MaxSteps uint64 `json:"max_steps,omitempty"`
+}
+
+// starlarkCandle is a closed candle as the script was given it
+type starlarkCandle struct {
+	candle     Candle
+	indicators map[string]float64
+	inPosition bool
+}
+
+// StarlarkStrategy runs strategy logic written in Starlark, a small dialect of Python, in an interpreter
+// embedded in the engine, so a strategy can be changed without recompiling. The script is sandboxed: it has no
+// access to files, the network, the environment or the clock, cannot load other files, and each call is
+// bounded in computation steps. Its only way to act is to enter or exit a position through the ctx it is
+// called with, and the engine places the orders.
+type StarlarkStrategy struct {
+	cfg        StarlarkConfig
+	indicators *scriptIndicators
+	// history holds the last Warmup candles, replayed to the script whenever it is reloaded
+	history []starlarkCandle
+
+	onCandle starlark.Callable
+	// state is the script's ctx.state, kept across candles until the script is reloaded
+	state *starlark.Dict
+	// modTime is the modification time of the script when it was last loaded
+	modTime time.Time
+}
+
+// NewStarlarkStrategy creates the strategy, loading its script and validating its indicators
+func NewStarlarkStrategy(cfg StarlarkConfig) (*StarlarkStrategy, error) {
+	if cfg.File == "" {
+		return nil, fmt.Errorf("the starlark strategy needs a file")
+	}
+	if cfg.Warmup < 0 {
+		return nil, fmt.Errorf("starlark warmup must not be negative")
+	}
+	indicators, err := newScriptIndicators(cfg.Indicators)
+	if err != nil {
+		return nil, err
+	}
+	s := &StarlarkStrategy{cfg: cfg, indicators: indicators}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Name returns the strategy's config name
+func (s *StarlarkStrategy) Name() string {
+	return "starlark"
+}
+
+// Warmup returns the candles the script needs
+func (s *StarlarkStrategy) Warmup() int {
+	return s.cfg.Warmup
+}
+
+// thread returns a Starlark thread for one call into the script, printing to the log
+func (s *StarlarkStrategy) thread() *starlark.Thread {
+	thread := &starlark.Thread{
+		Name:  "strategy",
+		Print: func(_ *starlark.Thread, msg string) { log.Printf("Starlark strategy: %s", msg) },
+	}
+	thread.SetMaxExecutionSteps(cmp.Or(s.cfg.MaxSteps, defaultStarlarkMaxSteps))
+	return thread
+}
+
+// load executes the script and looks up its on_candle function, starting it with empty state
+func (s *StarlarkStrategy) load() error {
+	info, err := os.Stat(s.cfg.File)
+	if err != nil {
+		return fmt.Errorf("error reading starlark strategy: %v", err)
+	}
+	s.modTime = info.ModTime()
+	globals, err := starlark.ExecFileOptions(starlarkFileOptions, s.thread(), s.cfg.File, nil, starlarkPredeclared)
+	if err != nil {
+		return fmt.Errorf("error loading %s: %v", s.cfg.File, starlarkError(err))
+	}
+	onCandle, ok := globals["on_candle"].(starlark.Callable)
+	if !ok {
+		return fmt.Errorf("%s does not define an on_candle function", s.cfg.File)
+	}
+	s.onCandle, s.state = onCandle, starlark.NewDict(0)
+	return nil
+}
+
+// starlarkError adds the Starlark call stack to an error raised by the script
+func starlarkError(err error) error {
+	var evalErr *starlark.EvalError
+	if errors.As(err, &evalErr) {
+		return errors.New(evalErr.Backtrace())
+	}
+	return err
+}
+
+// changed reports whether the script's file was modified since it was last loaded. A missing file, e.g. while
+// an editor replaces it, counts as changed once.
+func (s *StarlarkStrategy) changed() bool {
+	info, err := os.Stat(s.cfg.File)
+	if err != nil {
+		return !s.modTime.IsZero()
+	}
+	return !info.ModTime().Equal(s.modTime)
+}
+
+// reload loads the changed script and replays the candles before the current one to it, so it rebuilds its
+// state. A script that fails to load holds until its file changes again.
+func (s *StarlarkStrategy) reload() {
+	log.Printf("Starlark strategy changed, reloading %s", s.cfg.File)
+	s.onCandle, s.modTime = nil, time.Time{}
+	if err := s.load(); err != nil {
+		log.Printf("Starlark strategy failed to load, holding: %v", err)
+		return
+	}
+	for i := 1; i < len(s.history); i++ {
+		if _, err := s.call(s.history[:i], true); err != nil {
+			log.Printf("Error replaying candles to the Starlark strategy: %v", err)
+			return
+		}
+	}
+}
+
+// call runs on_candle for the last candle of history and returns the signal the script placed. On replayed
+// candles the script rebuilds its state, and its signals are ignored.
+func (s *StarlarkStrategy) call(history []starlarkCandle, replay bool) (Signal, error) {
+	current := history[len(history)-1]
+	var signal *Signal
+	place := func(action string, reason string, amount starlark.Value) (starlark.Value, error) {
+		if signal != nil {
+			return nil, fmt.Errorf("the script already signalled %s on this candle", signal.Action)
+		}
+		placed := Signal{Action: action, Reason: reason}
+		if amount != starlark.None {
+			var err error
+			if placed.Amount, err = starlarkDecimal(amount); err != nil {
+				return nil, err
+			}
+		}
+		signal = &placed
+		return starlark.None, nil
+	}
+	enter := starlark.NewBuiltin("enter", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var reason string
+		var amount starlark.Value = starlark.None
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "reason?", &reason, "amount?", &amount); err != nil {
+			return nil, err
+		}
+		return place(ActionEnter, reason, amount)
+	})
+	exit := starlark.NewBuiltin("exit", func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var reason string
+		if err := starlark.UnpackArgs(b.Name(), args, kwargs, "reason?", &reason); err != nil {
+			return nil, err
+		}
+		return place(ActionExit, reason, starlark.None)
+	})
+
+	candles := make([]starlark.Value, len(history))
+	for i, h := range history {
+		candles[i] = starlarkCandleValue(h.candle)
+	}
+	indicators := starlark.NewDict(len(current.indicators))
+	for _, name := range slices.Sorted(maps.Keys(current.indicators)) {
+		indicators.SetKey(starlark.String(name), starlark.Float(current.indicators[name]))
+	}
+	list := starlark.NewList(candles)
+	list.Freeze()
+	indicators.Freeze()
+	// ctx itself has no settable fields; only its state is left mutable
+	ctx := starlarkstruct.FromStringDict(starlark.String("ctx"), starlark.StringDict{
+		"candle":      candles[len(candles)-1],
+		"candles":     list,
+		"indicators":  indicators,
+		"in_position": starlark.Bool(current.inPosition),
+		"replay":      starlark.Bool(replay),
+		"state":       s.state,
+		"enter":       enter,
+		"exit":        exit,
+	})
+
+	if _, err := starlark.Call(s.thread(), s.onCandle, starlark.Tuple{ctx}, nil); err != nil {
+		return Signal{}, starlarkError(err)
+	}
+	if signal == nil || replay {
+		return Signal{}, nil
+	}
+	return *signal, nil
+}
+
+// starlarkCandleValue converts a candle to the struct scripts see, with its open time in Unix seconds
+func starlarkCandleValue(c Candle) starlark.Value {
+	return starlarkstruct.FromStringDict(starlark.String("candle"), starlark.StringDict{
+		"open_time": starlark.MakeInt64(c.OpenTime.Unix()),
+		"open":      starlark.Float(c.Open),
+		"high":      starlark.Float(c.High),
+		"low":       starlark.Float(c.Low),
+		"close":     starlark.Float(c.Close),
+		"volume":    starlark.Float(c.Volume),
+	})
+}
+
+// starlarkDecimal converts an amount given by a script as an int, float or decimal string
+func starlarkDecimal(v starlark.Value) (Decimal, error) {
+	var amount Decimal
+	var err error
+	switch v := v.(type) {
+	case starlark.Int:
+		amount, err = ParseDecimal(v.String())
+	case starlark.Float:
+		amount = NewDecimalFromFloat(float64(v))
+	case starlark.String:
+		amount, err = ParseDecimal(string(v))
+	default:
+		return Decimal{}, fmt.Errorf("amount must be a number or a decimal string, not %s", v.Type())
+	}
+	if err != nil {
+		return Decimal{}, fmt.Errorf("invalid amount %s: %v", v, err)
+	}
+	if amount.Sign() <= 0 {
+		return Decimal{}, fmt.Errorf("amount must be positive, not %s", v)
+	}
+	return amount, nil
+}
+
+// OnCandle updates the indicators with the candle and calls the script's on_candle with it. A script that
+// fails holds its position.
+func (s *StarlarkStrategy) OnCandle(c Candle, inPosition bool) Signal {
+	s.history = append(s.history, starlarkCandle{candle: c, indicators: s.indicators.update(c), inPosition: inPosition})
+	if len(s.history) > max(s.cfg.Warmup, 1) {
+		s.history = s.history[1:]
+	}
+	if s.cfg.Reload && s.changed() {
+		s.reload()
+	}
+	if s.onCandle == nil {
+		return Signal{}
+	}
+	signal, err := s.call(s.history, false)
+	if err != nil {
+		log.Printf("Starlark strategy failed, holding: %v", err)
+		return Signal{}
+	}
+	return signal
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeStarlarkStrategy writes a script to a temporary file and returns its path
+func writeStarlarkStrategy(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "strategy.star")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// hourlyTestCandle returns the i-th hourly candle closing at price
+func hourlyTestCandle(i int, price float64) Candle {
+	openTime := time.Date(2024, 1, 1, i, 0, 0, 0, time.UTC)
+	return Candle{OpenTime: openTime, CloseTime: openTime.Add(time.Hour), Open: price, High: price, Low: price, Close: price, Volume: 1}
+}
+
+func TestStarlarkStrategySignals(t *testing.T) {
+	path := writeStarlarkStrategy(t, `
+def on_candle(ctx):
+    closes = [c.close for c in ctx.candles]
+    if len(closes) < 3:
+        return
+    if not ctx.in_position and ctx.candle.close > max(closes[:-1]):
+        ctx.enter("breakout", amount="25.5")
+    elif ctx.in_position and ctx.candle.close < ctx.indicators["fast"]:
+        ctx.exit(reason="below ema")
+`)
+	strategy, err := NewStarlarkStrategy(StarlarkConfig{File: path, Indicators: map[string]string{"fast": "ema:2"}, Warmup: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		price      float64
+		inPosition bool
+		action     string
+		reason     string
+		amount     string
+	}{
+		{price: 100},
+		{price: 101},
+		{price: 100},
+		{price: 105, action: ActionEnter, reason: "breakout", amount: "25.5"},
+		{price: 106, inPosition: true},
+		{price: 90, inPosition: true, action: ActionExit, reason: "below ema"},
+	}
+	for i, tt := range tests {
+		signal := strategy.OnCandle(hourlyTestCandle(i, tt.price), tt.inPosition)
+		if signal.Action != tt.action || signal.Reason != tt.reason || signal.Amount.Cmp(decimalOrZero(tt.amount)) != 0 {
+			t.Errorf("candle %d: signal %+v, want %s %q amount %q", i, signal, tt.action, tt.reason, tt.amount)
+		}
+	}
+}
+
+func TestStarlarkStrategyState(t *testing.T) {
+	path := writeStarlarkStrategy(t, `
+def on_candle(ctx):
+    ctx.state["seen"] = ctx.state.get("seen", 0) + 1
+    if ctx.state["seen"] == 3:
+        ctx.enter()
+`)
+	strategy, err := NewStarlarkStrategy(StarlarkConfig{File: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		signal := strategy.OnCandle(hourlyTestCandle(i, 100), false)
+		if want := map[bool]string{true: ActionEnter}[i == 2]; signal.Action != want {
+			t.Errorf("candle %d: action %q, want %q", i, signal.Action, want)
+		}
+	}
+}
+
+func TestStarlarkStrategyHoldsOnErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{name: "runtime error", script: "def on_candle(ctx):\n    fail('broken')\n"},
+		{name: "two signals", script: "def on_candle(ctx):\n    ctx.enter()\n    ctx.exit()\n"},
+		{name: "negative amount", script: "def on_candle(ctx):\n    ctx.enter(amount=-1)\n"},
+		{name: "invalid amount", script: "def on_candle(ctx):\n    ctx.enter(amount='lots')\n"},
+		{name: "candles are frozen", script: "def on_candle(ctx):\n    ctx.candles.append(1)\n    ctx.enter()\n"},
+		{name: "runaway loop", script: "def on_candle(ctx):\n    while True:\n        pass\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := NewStarlarkStrategy(StarlarkConfig{File: writeStarlarkStrategy(t, tt.script), MaxSteps: 10_000})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if signal := strategy.OnCandle(hourlyTestCandle(0, 100), false); signal.Action != ActionHold {
+				t.Errorf("signal %+v, want hold", signal)
+			}
+		})
+	}
+}
+
+func TestStarlarkStrategyLoadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   string
+	}{
+		{name: "no on_candle", script: "x = 1\n", want: "does not define an on_candle function"},
+		{name: "load", script: "load('other.star', 'f')\ndef on_candle(ctx):\n    pass\n", want: "load not implemented"},
+		{name: "syntax error", script: "def on_candle(ctx)\n", want: "error loading"},
+		{name: "recursion", script: "def f(n):\n    return f(n - 1)\nf(1)\ndef on_candle(ctx):\n    pass\n", want: "called recursively"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewStarlarkStrategy(StarlarkConfig{File: writeStarlarkStrategy(t, tt.script)})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("loading returned %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStarlarkStrategyReloadReplaysHistory(t *testing.T) {
+	path := writeStarlarkStrategy(t, "def on_candle(ctx):\n    pass\n")
+	strategy, err := NewStarlarkStrategy(StarlarkConfig{File: path, Warmup: 3, Reload: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3 {
+		strategy.OnCandle(hourlyTestCandle(i, 100), false)
+	}
+
+	script := `
+def on_candle(ctx):
+    if ctx.replay:
+        ctx.state["replayed"] = ctx.state.get("replayed", 0) + 1
+        ctx.enter("ignored")
+    elif ctx.state.get("replayed") == 2:
+        ctx.enter("rebuilt")
+`
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := strategy.modTime.Add(time.Second)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if signal := strategy.OnCandle(hourlyTestCandle(3, 100), false); signal.Reason != "rebuilt" {
+		t.Errorf("signal %+v after reloading, want the rebuilt entry", signal)
+	}
+}
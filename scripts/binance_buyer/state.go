@@ -0,0 +1,329 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Fills accumulates the executed quantities and fees of a run's orders
+type Fills struct {
+	FilledBase  Decimal            `json:"filled_base"`
+	FilledQuote Decimal            `json:"filled_quote"`
+	Commissions map[string]Decimal `json:"commissions,omitempty"`
+}
+
+// add adds the executed part of an order to the totals
+func (f *Fills) add(order *Order) {
+	f.FilledBase = f.FilledBase.Add(decimalOrZero(order.ExecutedQty))
+	f.FilledQuote = f.FilledQuote.Add(filledQuote(order))
+	if order.Commission.Sign() > 0 {
+		if f.Commissions == nil {
+			f.Commissions = map[string]Decimal{}
+		}
+		f.Commissions[order.CommissionAsset] = f.Commissions[order.CommissionAsset].Add(order.Commission)
+	}
+}
+
+// averageFillPrice returns the volume-weighted average price of the fills
+func (f *Fills) averageFillPrice() float64 {
+	return f.FilledQuote.Div(f.FilledBase).Float64()
+}
+
+// FillRecord is the executed part of one of a run's orders
+type FillRecord struct {
+	Time            time.Time `json:"time"`
+	OrderID         string    `json:"order_id"`
+	Account         string    `json:"account,omitempty"`
+	Qty             Decimal   `json:"qty"`
+	Quote           Decimal   `json:"quote"`
+	Price           float64   `json:"price"`
+	Commission      Decimal   `json:"commission,omitzero"`
+	CommissionAsset string    `json:"commission_asset,omitempty"`
+	// CommissionQuote is the commission in the quote asset when fees are converted
+	CommissionQuote Decimal `json:"commission_quote,omitzero"`
+	// QuoteFiat is Quote in the run's reporting currency at FXRate units per quote asset unit
+	QuoteFiat Decimal `json:"quote_fiat,omitzero"`
+	FXRate    float64 `json:"fx_rate,omitempty"`
+}
+
+// RunState is the persisted execution plan and progress of a run
+type RunState struct {
+	RunID         string        `json:"run_id"`
+	Config        TWAPConfig    `json:"config"`
+	SliceAmount   Decimal       `json:"slice_amount"`
+	MinSlice      Decimal       `json:"min_slice"`
+	Interval      time.Duration `json:"interval"`
+	TotalSlices   int           `json:"total_slices"`
+	VolumeProfile []float64     `json:"volume_profile,omitempty"`
+	WeightSum     float64       `json:"weight_sum,omitempty"`
+	NextSlice     int           `json:"next_slice"`
+	Carry         Decimal       `json:"carry"`
+	// PlannedSlices is the number of slices the run was planned with, before catch-up slices were appended
+	PlannedSlices int `json:"planned_slices,omitempty"`
+	// Backlog is the budget of missed slices re-planned onto later slices
+	Backlog Decimal `json:"backlog,omitzero"`
+	// Adjustment is the part of budget changes made through the control server not yet spread onto slices
+	Adjustment Decimal `json:"adjustment,omitzero"`
+	// BudgetChanged is the net change of the budget made through the control server
+	BudgetChanged Decimal `json:"budget_changed,omitzero"`
+	Remaining     Decimal `json:"remaining"`
+	Fills
+	AccountFills     map[string]*Fills `json:"account_fills,omitempty"`
+	FillLog          []FillRecord      `json:"fill_log,omitempty"`
+	PlacedSlices     int               `json:"placed_slices"`
+	FailedSlices     int               `json:"failed_slices"`
+	FirstPrice       float64           `json:"first_price,omitempty"`
+	LastPrice        float64           `json:"last_price,omitempty"`
+	PriceSum         float64           `json:"price_sum,omitempty"`
+	PriceSamples     int               `json:"price_samples,omitempty"`
+	Benchmark        MarketBenchmark   `json:"benchmark,omitzero"`
+	SlippageBpsQuote float64           `json:"slippage_bps_quote,omitempty"`
+	SlippageQuote    float64           `json:"slippage_quote,omitempty"`
+	OpenOrders       []*trackedOrder   `json:"open_orders"`
+	Completed        bool              `json:"completed"`
+	ExitStop         float64           `json:"exit_stop,omitempty"`
+	ExitHighWater    float64           `json:"exit_high_water,omitempty"`
+	ExitOrderListID  string            `json:"exit_order_list_id,omitempty"`
+	// ExitTakeProfitArmed is set once the price has reached the level that arms a trailing take-profit
+	ExitTakeProfitArmed bool `json:"exit_take_profit_armed,omitempty"`
+	ExitCompleted       bool `json:"exit_completed"`
+	// MarginTransferred is the quote moved from spot to futures margin by the run, counted against its allowance
+	MarginTransferred Decimal `json:"margin_transferred,omitzero"`
+	// WithdrawalID is the withdrawal the completed run applied for, set so a resumed run does not withdraw again
+	WithdrawalID string    `json:"withdrawal_id,omitempty"`
+	StartedAt    time.Time `json:"started_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// Killed is why the kill switch last halted the run, cleared when it is resumed
+	Killed string `json:"killed,omitempty"`
+	// Downtime is how long the run was not running past a due slice, over every resume
+	Downtime time.Duration `json:"downtime,omitempty"`
+	// SkippedSlices counts the slices dropped by the skip catch-up policy
+	SkippedSlices int `json:"skipped_slices,omitempty"`
+	// VolatilityReference is the realized volatility of 1m returns slices are scaled against
+	VolatilityReference float64 `json:"volatility_reference,omitempty"`
+	// FeesQuote is the commissions paid in every asset, valued in the quote asset when they were paid
+	FeesQuote Decimal `json:"fees_quote,omitzero"`
+	// FilledFiat and FeesFiat are FilledQuote and FeesQuote in the reporting currency, at the FX rates of the
+	// fills, and FiatMissed counts the fills left out of them for want of a rate
+	FilledFiat Decimal `json:"filled_fiat,omitzero"`
+	FeesFiat   Decimal `json:"fees_fiat,omitzero"`
+	FiatMissed int     `json:"fiat_missed,omitempty"`
+
+	journal  *Journal
+	auditLog *AuditLog
+	notifier *Notifier
+	emailer  *EmailReporter
+	control  *RunControl
+	// fees values the run's commissions in the quote asset, when fee conversion is enabled
+	fees *feeConverter
+	// fx converts the run's fills into its reporting currency, when it has one
+	fx *FXConverter
+	// consecutiveErrors counts the order placements failing in a row since the run was started or resumed
+	consecutiveErrors int
+	// benchmarkFeed streams the market trades added to Benchmark, which held benchmarkBase when it started
+	benchmarkFeed *MarketDataFeed
+	benchmarkBase MarketBenchmark
+	// volatilityScale is the slice size factor last measured at volatilityCheckedAt
+	volatilityScale     float64
+	volatilityCheckedAt time.Time
+	// participationMark is the streamed market volume when a POV run placed its previous order
+	participationMark MarketBenchmark
+	// priceAverage is the rolling average of the prices sampled at the last slices of an opportunistic run
+	priceAverage *SMA
+}
+
+// newRunID returns a random identifier for a new run
+func newRunID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// sliceClientOrderID returns the client order ID of slice i's order, derived from the run ID so a retried
+// request for the slice is recognized by the exchange
+func (s *RunState) sliceClientOrderID(i int) string {
+	return fmt.Sprintf("%s-%d", s.RunID, i+1)
+}
+
+// sliceAmount returns the planned quote amount of slice i, weighted by the volume profile when one is set.
+// Catch-up slices have no planned amount of their own.
+func (s *RunState) sliceAmount(i int) Decimal {
+	if i >= s.plannedSlices() {
+		return Decimal{}
+	}
+	if len(s.VolumeProfile) == 0 || s.WeightSum == 0 {
+		return s.SliceAmount
+	}
+	return s.Config.Amount.MulFloat(s.sliceWeight(i) / s.WeightSum)
+}
+
+// sliceWeight returns the volume profile weight of the hour slice i is scheduled in
+func (s *RunState) sliceWeight(i int) float64 {
+	return s.VolumeProfile[s.StartedAt.Add(time.Duration(i)*s.Interval).UTC().Hour()]
+}
+
+// recordFill adds the executed part of an order to the run's fill totals
+func (s *RunState) recordFill(order *Order) {
+	s.Fills.add(order)
+	if order.Account != "" {
+		if s.AccountFills == nil {
+			s.AccountFills = map[string]*Fills{}
+		}
+		if s.AccountFills[order.Account] == nil {
+			s.AccountFills[order.Account] = &Fills{}
+		}
+		s.AccountFills[order.Account].add(order)
+	}
+	feeQuote := s.feeValue(order)
+	s.FeesQuote = s.FeesQuote.Add(feeQuote)
+	if qty := decimalOrZero(order.ExecutedQty); qty.Sign() > 0 {
+		quote := filledQuote(order)
+		record := FillRecord{Time: time.Now(), OrderID: order.OrderID, Account: order.Account, Qty: qty,
+			Quote: quote, Price: quote.Div(qty).Float64(), Commission: order.Commission, CommissionAsset: order.CommissionAsset,
+			CommissionQuote: feeQuote}
+		if _, rate := s.fiatRate(); rate > 0 {
+			record.QuoteFiat, record.FXRate = quote.MulFloat(rate), rate
+			s.FilledFiat = s.FilledFiat.Add(record.QuoteFiat)
+			s.FeesFiat = s.FeesFiat.Add(feeQuote.MulFloat(rate))
+		} else if s.fx != nil {
+			s.FiatMissed++
+		}
+		s.FillLog = append(s.FillLog, record)
+	}
+	s.journalOrder(order)
+	event := AuditFilled
+	if decimalOrZero(order.ExecutedQty).IsZero() {
+		event = AuditUnfilled
+	}
+	s.audit(event, "order_id", order.OrderID, "client_order_id", order.ClientOrderID, "status", order.Status,
+		"qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty, "commission", order.Commission, "commission_asset", order.CommissionAsset,
+		"account", order.Account)
+}
+
+// journalOrder appends an executed order to the run's trade journal, if one is configured
+func (s *RunState) journalOrder(order *Order) {
+	if s.journal == nil {
+		return
+	}
+	currency, rate := s.fiatRate()
+	if err := s.journal.Record(order, s.RunID, currency, rate); err != nil {
+		log.Printf("Error recording order %s in journal: %v", order.OrderID, err)
+	}
+}
+
+// fiatRate returns the run's reporting currency and its units per quote asset unit, or a zero rate when the run
+// has no reporting currency or the rate is not available
+func (s *RunState) fiatRate() (string, float64) {
+	if s.fx == nil {
+		return "", 0
+	}
+	rate, err := s.fx.Rate()
+	if err != nil {
+		log.Printf("Error getting the %s rate, the fill is not converted: %v", s.Config.Fiat.Currency, err)
+		return "", 0
+	}
+	return s.Config.Fiat.Currency, rate
+}
+
+// samplePrice records the market price at a slice, for comparing the run's fills against the market
+func (s *RunState) samplePrice(client ExchangeClient) {
+	price, err := client.GetPrice(s.Config.Symbol)
+	if err != nil {
+		log.Printf("Error sampling market price: %v", err)
+		return
+	}
+	if s.FirstPrice == 0 {
+		s.FirstPrice = price
+	}
+	s.LastPrice = price
+	s.PriceSum += price
+	s.PriceSamples++
+	if s.priceAverage != nil {
+		s.priceAverage.add(price)
+	}
+}
+
+// filledQuote returns the quote amount executed by an order, derived from its price when the exchange
+// does not report the cumulative quote quantity
+func filledQuote(order *Order) Decimal {
+	if quote := decimalOrZero(order.CumQuoteQty); !quote.IsZero() {
+		return quote
+	}
+	return decimalOrZero(order.ExecutedQty).Mul(decimalOrZero(order.Price))
+}
+
+// loadRunState reads a previously persisted run state
+func loadRunState(path string) (*RunState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file: %v", err)
+	}
+
+	var state RunState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("error parsing state file: %v", err)
+	}
+
+	return &state, nil
+}
+
+// save atomically writes the run state to path, logging rather than failing the run on error
+func (s *RunState) save(path string) {
+	s.UpdatedAt = time.Now()
+	s.refreshBenchmark()
+	if path == "" {
+		return
+	}
+	if err := writeJSONFile(path, s); err != nil {
+		log.Print(err)
+	}
+}
+
+// writeJSONFile atomically replaces path with the JSON encoding of v, through a temporary file of its own so
+// concurrent writers do not clobber each other's
+func writeJSONFile(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding state: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error writing state file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing state file: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error replacing state file: %v", err)
+	}
+	return nil
+}
+
+// lockFile takes an exclusive lock on path's ".lock" file, waiting for other processes holding it, and
+// returns the function releasing it
+func lockFile(path string) (func(), error) {
+	file, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening lock file: %v", err)
+	}
+	if err := lockExclusive(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("error locking %s: %v", path, err)
+	}
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
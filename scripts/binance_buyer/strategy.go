@@ -0,0 +1,387 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Strategy signal actions
+const (
+	// ActionHold keeps the current position
+	ActionHold = ""
+	// ActionEnter opens a long position
+	ActionEnter = "enter"
+	// ActionExit closes the open position
+	ActionExit = "exit"
+)
+
+// klineDurations is the length of each Binance kline interval
+var klineDurations = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"6h":  6 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  72 * time.Hour,
+	"1w":  7 * 24 * time.Hour,
+}
+
+// Signal is a strategy's decision on a closed candle
+type Signal struct {
+	Action string
+	Reason string
+	// Amount optionally sizes an entry in the quote asset, below the config's amount
+	Amount Decimal
+}
+
+// Strategy decides on every closed candle whether to open or close a long position. The same strategy drives
+// live trading and backtests.
+type Strategy interface {
+	// Name returns the strategy's name as used in config files
+	Name() string
+	// Warmup returns how many candles the strategy needs before it can signal
+	Warmup() int
+	// OnCandle updates the strategy with a closed candle and returns its signal, given whether a position is
+	// open
+	OnCandle(c Candle, inPosition bool) Signal
+}
+
+// StrategyConfig is the config file of a strategy run, holding everything needed to trade it live or
+// backtest it
+type StrategyConfig struct {
+	Strategy string `json:"strategy"`
+	Symbol   string `json:"symbol"`
+	// QuoteAsset is the asset the symbol is quoted in, USDT by default
+	QuoteAsset string `json:"quote_asset,omitempty"`
+	// Interval is the kline interval the strategy runs on (e.g., 1h)
+	Interval string `json:"interval"`
+	// Amount is the quote amount of each entry
+	Amount Decimal `json:"amount"`
+	// Position caps each entry so the position stays within the limits, live and in backtests
+	Position  PositionLimit `json:"position,omitzero"`
+	StateFile string        `json:"state_file,omitempty"`
+	// CandleStore caches the klines of warm-ups, live runs and backtests in this data directory, so only
+	// candles not seen before are requested
+	CandleStore string           `json:"candle_store,omitempty"`
+	Momentum    *MomentumConfig  `json:"momentum,omitempty"`
+	Bollinger   *BollingerConfig `json:"bollinger,omitempty"`
+	Script      *ScriptConfig    `json:"script,omitempty"`
+	Starlark    *StarlarkConfig  `json:"starlark,omitempty"`
+	Backtest    BacktestConfig   `json:"backtest"`
+}
+
+// loadStrategyConfig reads and validates a strategy config file
+func loadStrategyConfig(path string) (*StrategyConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config: %v", err)
+	}
+	var cfg StrategyConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("error parsing config: %v", err)
+	}
+	if cfg.Symbol == "" {
+		return nil, fmt.Errorf("config %s has no symbol", path)
+	}
+	if _, ok := klineDurations[cfg.Interval]; !ok {
+		return nil, fmt.Errorf("invalid interval %q in %s. Use a kline interval such as 15m, 1h, 4h or 1d", cfg.Interval, path)
+	}
+	if cfg.Amount.Sign() <= 0 {
+		return nil, fmt.Errorf("config %s needs a positive amount", path)
+	}
+	return &cfg, nil
+}
+
+// baseAsset returns the asset the strategy's positions are held in
+func (c *StrategyConfig) baseAsset() string {
+	return strings.TrimSuffix(c.Symbol, cmp.Or(c.QuoteAsset, "USDT"))
+}
+
+// newStrategy creates the strategy the config selects
+func (c *StrategyConfig) newStrategy() (Strategy, error) {
+	switch c.Strategy {
+	case "momentum":
+		if c.Momentum == nil {
+			return nil, fmt.Errorf("the momentum strategy needs a momentum section")
+		}
+		return NewMomentumBreakout(*c.Momentum)
+	case "bollinger":
+		if c.Bollinger == nil {
+			return nil, fmt.Errorf("the bollinger strategy needs a bollinger section")
+		}
+		return NewMeanReversion(*c.Bollinger)
+	case "script":
+		if c.Script == nil {
+			return nil, fmt.Errorf("the script strategy needs a script section")
+		}
+		return NewScriptStrategy(*c.Script)
+	case "starlark":
+		if c.Starlark == nil {
+			return nil, fmt.Errorf("the starlark strategy needs a starlark section")
+		}
+		return NewStarlarkStrategy(*c.Starlark)
+	default:
+		return nil, fmt.Errorf("unknown strategy: %q. Use momentum, bollinger, script or starlark", c.Strategy)
+	}
+}
+
+// StrategyState is the persisted position of a live strategy
+type StrategyState struct {
+	Strategy string `json:"strategy"`
+	Symbol   string `json:"symbol"`
+	// Quantity is the base asset held by the open position, zero when flat
+	Quantity   Decimal   `json:"quantity"`
+	EntryCost  Decimal   `json:"entry_cost"`
+	EntryTime  time.Time `json:"entry_time,omitzero"`
+	LastCandle time.Time `json:"last_candle"`
+	Trades     int       `json:"trades"`
+	// RealizedPnL is the quote received from exits minus the quote spent on their entries
+	RealizedPnL Decimal   `json:"realized_pnl"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// inPosition reports whether a position is open
+func (s *StrategyState) inPosition() bool {
+	return s.Quantity.Sign() > 0
+}
+
+// save writes the state to path, logging rather than failing on error
+func (s *StrategyState) save(path string) {
+	if path == "" {
+		return
+	}
+	s.UpdatedAt = time.Now()
+	if err := writeJSONFile(path, s); err != nil {
+		log.Print(err)
+	}
+}
+
+// loadStrategyState reads the persisted state of a live strategy, or returns a fresh one when path does not
+// exist
+func loadStrategyState(path string, cfg *StrategyConfig) (*StrategyState, error) {
+	state := &StrategyState{Strategy: cfg.Strategy, Symbol: cfg.Symbol}
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading state file: %v", err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error parsing state file: %v", err)
+	}
+	if state.Strategy != cfg.Strategy || state.Symbol != cfg.Symbol {
+		return nil, fmt.Errorf("state file %s belongs to a %s strategy on %s. Use another state_file", path, state.Strategy, state.Symbol)
+	}
+	return state, nil
+}
+
+// closedCandles returns the candles of symbol opened after since that have closed by now
+func closedCandles(client ExchangeClient, symbol, interval string, since time.Time) ([]Candle, error) {
+	now := time.Now()
+	candles, err := client.GetKlines(symbol, interval, since, now)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s klines: %v", symbol, err)
+	}
+	for len(candles) > 0 && candles[len(candles)-1].CloseTime.After(now) {
+		candles = candles[:len(candles)-1]
+	}
+	for len(candles) > 0 && !candles[0].OpenTime.After(since) {
+		candles = candles[1:]
+	}
+	return candles, nil
+}
+
+// entryAmount returns the quote amount of an entry: the signal's amount when it sets a smaller one than the
+// config's, reduced so the position the account already holds plus the entry stays within the position limits
+func entryAmount(client ExchangeClient, cfg *StrategyConfig, signal Signal) (Decimal, error) {
+	amount := cfg.Amount
+	if signal.Amount.Sign() > 0 && signal.Amount.LessThan(amount) {
+		amount = signal.Amount
+	}
+	if !cfg.Position.Enabled() {
+		return amount, nil
+	}
+	price, err := client.GetPrice(cfg.Symbol)
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error getting price: %v", err)
+	}
+	held, err := client.GetBalance(cfg.baseAsset())
+	if err != nil {
+		return Decimal{}, fmt.Errorf("error getting %s balance: %v", cfg.baseAsset(), err)
+	}
+	headroom := cfg.Position.maxBase(NewDecimalFromFloat(price)).Sub(held).Mul(NewDecimalFromFloat(price))
+	if headroom.Sign() <= 0 {
+		return Decimal{}, fmt.Errorf("position limit reached, holding %s %s", held, cfg.baseAsset())
+	}
+	if headroom.LessThan(amount) {
+		log.Printf("Entry reduced from %s to %s by the position limit, holding %s", amount, headroom, held)
+		return headroom, nil
+	}
+	return amount, nil
+}
+
+// enterStrategy buys the config's quote amount at market, within the position limits, opening the position
+func enterStrategy(client ExchangeClient, cfg *StrategyConfig, state *StrategyState, filters *SymbolFilters, signal Signal) error {
+	amount, err := entryAmount(client, cfg, signal)
+	if err != nil {
+		return err
+	}
+	amount = filters.RoundQuote(amount)
+	if err := filters.ValidateNotional(amount); err != nil {
+		return err
+	}
+	order, err := client.PlaceOrder(OrderRequest{Symbol: cfg.Symbol, Side: "BUY", Type: OrderTypeMarket, QuoteQuantity: amount})
+	if err != nil {
+		return fmt.Errorf("error placing entry order: %v", err)
+	}
+	quantity := decimalOrZero(order.ExecutedQty)
+	if order.CommissionAsset == cfg.baseAsset() {
+		quantity = quantity.Sub(order.Commission)
+	}
+	state.Quantity, state.EntryCost, state.EntryTime = filters.RoundQuantity(quantity), filledQuote(order), time.Now()
+	log.Printf("Entered %s: bought %s for %s (order %s)", cfg.Symbol, order.ExecutedQty, state.EntryCost, order.OrderID)
+	return nil
+}
+
+// exitStrategy sells the position at market, closing it
+func exitStrategy(client ExchangeClient, cfg *StrategyConfig, state *StrategyState) error {
+	order, err := client.PlaceOrder(OrderRequest{Symbol: cfg.Symbol, Side: "SELL", Type: OrderTypeMarket, Quantity: state.Quantity})
+	if err != nil {
+		return fmt.Errorf("error placing exit order: %v", err)
+	}
+	pnl := filledQuote(order).Sub(state.EntryCost)
+	state.RealizedPnL = state.RealizedPnL.Add(pnl)
+	state.Trades++
+	log.Printf("Exited %s: sold %s for %s (order %s), trade PnL %s, total %s", cfg.Symbol, order.ExecutedQty, filledQuote(order), order.OrderID, pnl, state.RealizedPnL)
+	state.Quantity, state.EntryCost, state.EntryTime = Decimal{}, Decimal{}, time.Time{}
+	return nil
+}
+
+// runStrategyLive feeds the strategy every closed candle and trades its signals at market until ctx is
+// cancelled. It warms the strategy up on the candles before the start, and the position survives restarts
+// through the state file.
+func runStrategyLive(ctx context.Context, client ExchangeClient, cfg *StrategyConfig, strategy Strategy) error {
+	state, err := loadStrategyState(cfg.StateFile, cfg)
+	if err != nil {
+		return err
+	}
+	filters, err := client.GetSymbolFilters(cfg.Symbol)
+	if err != nil {
+		return fmt.Errorf("error getting symbol filters for %s: %v", cfg.Symbol, err)
+	}
+	period := klineDurations[cfg.Interval]
+	warmup, err := closedCandles(client, cfg.Symbol, cfg.Interval, time.Now().Add(-time.Duration(strategy.Warmup()+2)*period))
+	if err != nil {
+		return err
+	}
+	for _, c := range warmup {
+		strategy.OnCandle(c, state.inPosition())
+		state.LastCandle = c.OpenTime
+	}
+	log.Printf("Running %s on %s %s candles, %s per entry. Warmed up on %d candle(s), position %s.",
+		cfg.Strategy, cfg.Symbol, cfg.Interval, cfg.Amount, len(warmup), state.Quantity)
+
+	for {
+		// Wait until the candle after the last one processed has closed, plus a moment for the exchange
+		next := state.LastCandle.Add(2 * period).Add(2 * time.Second)
+		if !sleepContext(ctx, max(time.Until(next), time.Second)) {
+			return nil
+		}
+		candles, err := closedCandles(client, cfg.Symbol, cfg.Interval, state.LastCandle)
+		if err != nil {
+			log.Print(err)
+			continue
+		}
+		for _, c := range candles {
+			signal := strategy.OnCandle(c, state.inPosition())
+			state.LastCandle = c.OpenTime
+			switch {
+			case signal.Action == ActionEnter && !state.inPosition():
+				log.Printf("Entry signal on the %s candle: %s", c.OpenTime.Format(time.RFC3339), signal.Reason)
+				err = enterStrategy(client, cfg, state, filters, signal)
+			case signal.Action == ActionExit && state.inPosition():
+				log.Printf("Exit signal on the %s candle: %s", c.OpenTime.Format(time.RFC3339), signal.Reason)
+				err = exitStrategy(client, cfg, state)
+			}
+			if err != nil {
+				log.Printf("Error trading %s signal: %v", signal.Action, err)
+				err = nil
+			}
+		}
+		state.save(cfg.StateFile)
+	}
+}
+
+// runStrategy trades the strategy of a config file live, or with -backtest replays it over the config's
+// backtest period and with -optimize sweeps its parameters
+func runStrategy(args []string) error {
+	fs, common := newFlagSet("strategy")
+	configPath := fs.String("config", "", "Strategy config file (JSON) holding the symbol, interval, amount and strategy parameters")
+	backtestOnly := fs.Bool("backtest", false, "Backtest the strategy over the config's backtest period instead of trading it")
+	optimize := fs.Bool("optimize", false, "Backtest every combination of the config's sweep, walking forward when it sets walk_forward")
+	metricsOut := fs.String("metrics-out", "", "Comma-separated files to write the backtest's metrics to, as .json (with the equity curve), .csv or .html")
+	workers := fs.Int("workers", runtime.NumCPU(), "Backtests run in parallel by -optimize")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if *configPath == "" {
+		return fmt.Errorf("-config is required")
+	}
+	cfg, err := loadStrategyConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if cfg.Backtest.Exchange == "" {
+		cfg.Backtest.Exchange = strings.ToLower(common.exchange)
+	}
+	strategy, err := cfg.newStrategy()
+	if err != nil {
+		return err
+	}
+	if stopper, ok := strategy.(interface{ Stop() }); ok {
+		defer stopper.Stop()
+	}
+	if *optimize && cfg.Backtest.DataDir != "" {
+		return runOptimize(nil, cfg, *workers)
+	}
+	if *backtestOnly && cfg.Backtest.DataDir != "" {
+		// Stored candles need no exchange
+		return runBacktest(nil, cfg, strategy, *metricsOut)
+	}
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+	if cfg.CandleStore != "" {
+		client = withCandleStore(client, cfg.CandleStore)
+	}
+	if *optimize {
+		return runOptimize(client, cfg, *workers)
+	}
+	if *backtestOnly {
+		return runBacktest(client, cfg, strategy, *metricsOut)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return runStrategyLive(ctx, client, cfg, strategy)
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// binanceSubAccountWalletTypes are the names of the wallets in sub-account universal transfers
+var binanceSubAccountWalletTypes = map[string]string{
+	WalletSpot:    "SPOT",
+	WalletFutures: "USDT_FUTURE",
+	WalletMargin:  "MARGIN",
+}
+
+// binanceSubAccountPageSize is the most sub-accounts a single list request returns
+const binanceSubAccountPageSize = 200
+
+// SubAccount is a sub-account of the master account
+type SubAccount struct {
+	Email     string
+	Frozen    bool
+	CreatedAt time.Time
+}
+
+// SubAccountTransfer moves an asset between the master account and its sub-accounts, or between two
+// sub-accounts. An empty email is the master account.
+type SubAccountTransfer struct {
+	FromEmail  string
+	ToEmail    string
+	FromWallet string
+	ToWallet   string
+	Asset      string
+	Amount     Decimal
+	// ClientID must be unique, so the exchange rejects a retried transfer instead of repeating it
+	ClientID string
+}
+
+// SubAccountManager is implemented by clients of a master account that can list its sub-accounts, read their
+// balances and move funds to and from them
+type SubAccountManager interface {
+	GetSubAccounts() ([]SubAccount, error)
+	GetSubAccountBalances(email string) ([]Balance, error)
+	TransferSubAccount(transfer SubAccountTransfer) (string, error)
+}
+
+// subAccountManager returns the sub-account management of a client, or an error when the exchange does not
+// provide it
+func subAccountManager(client ExchangeClient, exchange string) (SubAccountManager, error) {
+	manager, ok := baseClient(client).(SubAccountManager)
+	if !ok {
+		return nil, fmt.Errorf("sub-accounts are not supported on %s", exchange)
+	}
+	return manager, nil
+}
+
+// GetSubAccounts lists the sub-accounts of the master account, a page at a time
+func (c *BinanceClient) GetSubAccounts() ([]SubAccount, error) {
+	var accounts []SubAccount
+	for page := 1; ; page++ {
+		params := url.Values{}
+		params.Set("page", strconv.Itoa(page))
+		params.Set("limit", strconv.Itoa(binanceSubAccountPageSize))
+		body, err := c.sendSigned("GET", "/sapi/v1/sub-account/list", params)
+		if err != nil {
+			return nil, err
+		}
+
+		var raw struct {
+			SubAccounts []struct {
+				Email      string `json:"email"`
+				IsFreeze   bool   `json:"isFreeze"`
+				CreateTime int64  `json:"createTime"`
+			} `json:"subAccounts"`
+		}
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, fmt.Errorf("error parsing response: %v", err)
+		}
+		for _, account := range raw.SubAccounts {
+			accounts = append(accounts, SubAccount{Email: account.Email, Frozen: account.IsFreeze, CreatedAt: time.UnixMilli(account.CreateTime)})
+		}
+		if len(raw.SubAccounts) < binanceSubAccountPageSize {
+			return accounts, nil
+		}
+	}
+}
+
+// GetSubAccountBalances gets the spot balances of a sub-account
+func (c *BinanceClient) GetSubAccountBalances(email string) ([]Balance, error) {
+	params := url.Values{}
+	params.Set("email", email)
+	body, err := c.sendSigned("GET", "/sapi/v4/sub-account/assets", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Balances []Balance `json:"balances"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	return raw.Balances, nil
+}
+
+// TransferSubAccount moves funds between the master account and its sub-accounts and returns the transfer's
+// ID. The request is sent once without retries; a transfer with a client ID can be sent again safely.
+func (c *BinanceClient) TransferSubAccount(transfer SubAccountTransfer) (string, error) {
+	fromType, ok := binanceSubAccountWalletTypes[strings.ToLower(transfer.FromWallet)]
+	if !ok {
+		return "", fmt.Errorf("invalid wallet: %s. Use spot, futures or margin", transfer.FromWallet)
+	}
+	toType, ok := binanceSubAccountWalletTypes[strings.ToLower(transfer.ToWallet)]
+	if !ok {
+		return "", fmt.Errorf("invalid wallet: %s. Use spot, futures or margin", transfer.ToWallet)
+	}
+	params := url.Values{}
+	if transfer.FromEmail != "" {
+		params.Set("fromEmail", transfer.FromEmail)
+	}
+	if transfer.ToEmail != "" {
+		params.Set("toEmail", transfer.ToEmail)
+	}
+	params.Set("fromAccountType", fromType)
+	params.Set("toAccountType", toType)
+	params.Set("asset", transfer.Asset)
+	params.Set("amount", transfer.Amount.String())
+	if transfer.ClientID != "" {
+		params.Set("clientTranId", transfer.ClientID)
+	}
+	body, err := c.resendOnTimestampError(func() (*httpResult, error) {
+		return c.sendOnce("POST", "/sapi/v1/sub-account/universalTransfer", params, binanceAuthSigned)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var raw struct {
+		TranID int64 `json:"tranId"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return "", fmt.Errorf("error parsing response: %v", err)
+	}
+	return strconv.FormatInt(raw.TranID, 10), nil
+}
+
+// subAccountClient creates the client of the master account selected by the flags and returns its sub-account
+// management
+func subAccountClient(common *commonFlags) (SubAccountManager, error) {
+	client, err := common.client()
+	if err != nil {
+		return nil, err
+	}
+	return subAccountManager(client, common.exchange)
+}
+
+// runSubAccountsList lists the sub-accounts of the master account
+func runSubAccountsList(args []string) error {
+	fs, common := newFlagSet("list")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	manager, err := subAccountClient(common)
+	if err != nil {
+		return err
+	}
+
+	accounts, err := manager.GetSubAccounts()
+	if err != nil {
+		return fmt.Errorf("error listing sub-accounts: %v", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "EMAIL\tCREATED\tFROZEN")
+	for _, account := range accounts {
+		fmt.Fprintf(w, "%s\t%s\t%t\n", account.Email, account.CreatedAt.Format(time.DateOnly), account.Frozen)
+	}
+	return w.Flush()
+}
+
+// runSubAccountsAssets prints the non-zero spot balances of a sub-account
+func runSubAccountsAssets(args []string) error {
+	fs, common := newFlagSet("assets")
+	email := fs.String("email", "", "Email of the sub-account")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if *email == "" {
+		return fmt.Errorf("-email is required")
+	}
+	manager, err := subAccountClient(common)
+	if err != nil {
+		return err
+	}
+
+	balances, err := manager.GetSubAccountBalances(*email)
+	if err != nil {
+		return fmt.Errorf("error getting balances of %s: %v", *email, err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ASSET\tFREE\tLOCKED")
+	for _, balance := range balances {
+		if decimalOrZero(balance.Free).IsZero() && decimalOrZero(balance.Locked).IsZero() {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", balance.Asset, balance.Free, balance.Locked)
+	}
+	return w.Flush()
+}
+
+// runSubAccountsTransfer moves an asset between the master account and a sub-account or between two
+// sub-accounts
+func runSubAccountsTransfer(args []string) error {
+	fs, common := newFlagSet("transfer")
+	fromEmail := fs.String("from-email", "", "Sub-account to transfer from (empty for the master account)")
+	toEmail := fs.String("to-email", "", "Sub-account to transfer to (empty for the master account)")
+	fromWallet := fs.String("from-wallet", WalletSpot, "Wallet to transfer from: spot, futures (USDT-M) or margin (cross)")
+	toWallet := fs.String("to-wallet", WalletSpot, "Wallet to transfer to: spot, futures (USDT-M) or margin (cross)")
+	asset := fs.String("asset", "USDT", "Asset to transfer")
+	amount := fs.String("amount", "", "Amount to transfer (e.g., 1000)")
+	clientID := fs.String("client-id", "", "Unique ID of the transfer, so sending it again cannot transfer twice")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if *fromEmail == "" && *toEmail == "" {
+		return fmt.Errorf("-from-email or -to-email is required")
+	}
+	quantity, err := ParseDecimal(*amount)
+	if err != nil || quantity.Sign() <= 0 {
+		return fmt.Errorf("invalid amount %q: a positive amount is required", *amount)
+	}
+	manager, err := subAccountClient(common)
+	if err != nil {
+		return err
+	}
+
+	transfer := SubAccountTransfer{FromEmail: *fromEmail, ToEmail: *toEmail, FromWallet: *fromWallet, ToWallet: *toWallet,
+		Asset: strings.ToUpper(*asset), Amount: quantity, ClientID: *clientID}
+	id, err := manager.TransferSubAccount(transfer)
+	if err != nil {
+		return fmt.Errorf("error transferring %s %s: %v", transfer.Amount, transfer.Asset, err)
+	}
+	fmt.Printf("Transferred %s %s from %s to %s (transfer %s)\n", transfer.Amount, transfer.Asset, accountLabel(*fromEmail), accountLabel(*toEmail), id)
+	return nil
+}
+
+// accountLabel names an account of a transfer, the master account for an empty email
+func accountLabel(email string) string {
+	if email == "" {
+		return "master"
+	}
+	return email
+}
+
+// runSubAccountsFund splits a budget over sub-accounts by weight and transfers each its share from the master
+// account's spot wallet, e.g. before running a multi-account execution on them
+func runSubAccountsFund(args []string) error {
+	fs, common := newFlagSet("fund")
+	to := fs.String("to", "", "Comma-separated sub-account emails with weights (e.g., desk1@example.com:2,desk2@example.com:1)")
+	asset := fs.String("asset", "USDT", "Asset of the budget")
+	amount := fs.String("amount", "", "Budget to split over the sub-accounts (e.g., 3000)")
+	toWallet := fs.String("to-wallet", WalletSpot, "Wallet of the sub-accounts to fund: spot, futures (USDT-M) or margin (cross)")
+	clientID := fs.String("client-id", "", "Prefix of the unique transfer IDs, so funding again with it cannot transfer twice")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	budget, err := ParseDecimal(*amount)
+	if err != nil || budget.Sign() <= 0 {
+		return fmt.Errorf("invalid amount %q: a positive amount is required", *amount)
+	}
+	if _, ok := binanceSubAccountWalletTypes[strings.ToLower(*toWallet)]; !ok {
+		return fmt.Errorf("invalid wallet: %s. Use spot, futures or margin", *toWallet)
+	}
+	type share struct {
+		email  string
+		weight int
+	}
+	var shares []share
+	totalWeight := 0
+	for _, entry := range strings.Split(*to, ",") {
+		email, weightSpec, hasWeight := strings.Cut(strings.TrimSpace(entry), ":")
+		if !strings.Contains(email, "@") {
+			return fmt.Errorf("invalid sub-account email %q", email)
+		}
+		weight := 1
+		if hasWeight {
+			if weight, err = strconv.Atoi(weightSpec); err != nil || weight <= 0 {
+				return fmt.Errorf("invalid weight %q for sub-account %s", weightSpec, email)
+			}
+		}
+		shares = append(shares, share{email: email, weight: weight})
+		totalWeight += weight
+	}
+	manager, err := subAccountClient(common)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SUB-ACCOUNT\tWEIGHT\tAMOUNT\tTRANSFER")
+	var failed []string
+	for i, s := range shares {
+		transfer := SubAccountTransfer{ToEmail: s.email, FromWallet: WalletSpot, ToWallet: *toWallet, Asset: strings.ToUpper(*asset),
+			Amount: budget.Mul(NewDecimalFromInt(int64(s.weight))).Div(NewDecimalFromInt(int64(totalWeight))).Truncate(8)}
+		if *clientID != "" {
+			transfer.ClientID = fmt.Sprintf("%s-%d", *clientID, i+1)
+		}
+		id, err := manager.TransferSubAccount(transfer)
+		if err != nil {
+			id = "error: " + err.Error()
+			failed = append(failed, s.email)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s %s\t%s\n", s.email, s.weight, transfer.Amount, transfer.Asset, id)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("funding failed for %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Formats of a tax export
+const (
+	// TaxFormatKoinly is Koinly's universal CSV import, one row per trade
+	TaxFormatKoinly = "koinly"
+	// TaxFormatCoinTracking is CoinTracking's CSV import, one row per trade
+	TaxFormatCoinTracking = "cointracking"
+	// TaxFormat8949 is a Form 8949-style list of disposals with their cost basis, one row per lot sold
+	TaxFormat8949 = "8949"
+)
+
+// longTermHolding is how long a lot is held before its disposal is long-term
+const longTermHolding = 365 * 24 * time.Hour
+
+// TaxLot is a quantity of an asset bought at once, with its total cost in the quote asset, fees included
+type TaxLot struct {
+	Time    time.Time
+	OrderID string
+	Qty     Decimal
+	Cost    Decimal
+}
+
+// Disposal is the part of a sell matched against one lot, or against none when the journal did not record
+// the buy (Acquired is zero and the cost is the proceeds, so it gains nothing)
+type Disposal struct {
+	Symbol   string
+	Asset    string
+	OrderID  string
+	Qty      Decimal
+	Acquired time.Time
+	Sold     time.Time
+	Proceeds Decimal
+	Cost     Decimal
+}
+
+// Gain returns the gain or loss of the disposal
+func (d Disposal) Gain() Decimal {
+	return d.Proceeds.Sub(d.Cost)
+}
+
+// LongTerm reports whether the lot was held for more than a year
+func (d Disposal) LongTerm() bool {
+	return !d.Acquired.IsZero() && d.Sold.Sub(d.Acquired) > longTermHolding
+}
+
+// readJournalTrades reads the executed orders of a trade journal whose symbol is quoted in quoteAsset, in the
+// order they were recorded, returning them with the number of rows of other quote assets it skipped
+func readJournalTrades(path, quoteAsset string) ([]Trade, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading journal: %v", err)
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, 0, fmt.Errorf("error reading journal: %v", err)
+	}
+
+	var trades []Trade
+	skipped := 0
+	for i, row := range rows {
+		if i == 0 || len(row) < 10 {
+			continue
+		}
+		if !strings.HasSuffix(row[1], quoteAsset) || row[1] == quoteAsset {
+			skipped++
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, row[0])
+		if err != nil {
+			return nil, 0, fmt.Errorf("journal line %d: invalid timestamp %q", i+1, row[0])
+		}
+		trade := Trade{Symbol: row[1], Side: row[2], OrderID: row[3], Qty: decimalOrZero(row[5]), QuoteQty: decimalOrZero(row[6]),
+			Price: decimalOrZero(row[7]), Commission: decimalOrZero(row[8]), CommissionAsset: row[9], Time: t}
+		if trade.Qty.Sign() > 0 && (trade.Side == "BUY" || trade.Side == "SELL") {
+			trades = append(trades, trade)
+		}
+	}
+	return trades, skipped, nil
+}
+
+// matchLots matches the sells of trades against the lots their buys opened, picking lots by method, and
+// returns the disposals. Fees paid in the base asset reduce the quantity bought or add to the quantity sold,
+// and fees paid in the quote asset add to the cost or reduce the proceeds, as in the ledger; fees paid in
+// other assets are not counted.
+func matchLots(trades []Trade, quoteAsset, method string) []Disposal {
+	lots := map[string][]TaxLot{}
+	var disposals []Disposal
+	for _, trade := range trades {
+		base := strings.TrimSuffix(trade.Symbol, quoteAsset)
+		qty, quote := trade.Qty, trade.QuoteQty
+		switch trade.CommissionAsset {
+		case base:
+			if trade.Side == "BUY" {
+				qty = qty.Sub(trade.Commission)
+			} else {
+				qty = qty.Add(trade.Commission)
+			}
+		case quoteAsset:
+			if trade.Side == "BUY" {
+				quote = quote.Add(trade.Commission)
+			} else {
+				quote = quote.Sub(trade.Commission)
+			}
+		}
+		if trade.Side == "BUY" {
+			lots[trade.Symbol] = append(lots[trade.Symbol], TaxLot{Time: trade.Time, OrderID: trade.OrderID, Qty: qty, Cost: quote})
+			continue
+		}
+
+		remaining := qty
+		open := lots[trade.Symbol]
+		for remaining.Sign() > 0 && len(open) > 0 {
+			i := nextLot(open, method)
+			lot := &open[i]
+			take := minDecimal(remaining, lot.Qty)
+			cost := lot.Cost.Mul(take).Div(lot.Qty)
+			disposals = append(disposals, Disposal{Symbol: trade.Symbol, Asset: base, OrderID: trade.OrderID, Qty: take,
+				Acquired: lot.Time, Sold: trade.Time, Proceeds: quote.Mul(take).Div(qty), Cost: cost})
+			lot.Qty = lot.Qty.Sub(take)
+			lot.Cost = lot.Cost.Sub(cost)
+			remaining = remaining.Sub(take)
+			if lot.Qty.Sign() <= 0 {
+				open = slices.Delete(open, i, i+1)
+			}
+		}
+		lots[trade.Symbol] = open
+		if remaining.Sign() > 0 {
+			// Without a known cost basis, the uncovered part is reported at its sale price so it gains nothing
+			log.Printf("%s sell at %s exceeds the journal's position by %s. Reporting it without gain or loss.",
+				trade.Symbol, trade.Time.Format(time.RFC3339), remaining)
+			proceeds := quote.Mul(remaining).Div(qty)
+			disposals = append(disposals, Disposal{Symbol: trade.Symbol, Asset: base, OrderID: trade.OrderID, Qty: remaining,
+				Sold: trade.Time, Proceeds: proceeds, Cost: proceeds})
+		}
+	}
+	return disposals
+}
+
+// nextLot returns the index of the lot a sell takes from next: the oldest for FIFO, the newest for LIFO and
+// the one with the highest unit cost for HIFO
+func nextLot(lots []TaxLot, method string) int {
+	switch method {
+	case CostBasisLIFO:
+		return len(lots) - 1
+	case CostBasisHIFO:
+		best := 0
+		for i, lot := range lots {
+			if lot.Cost.Mul(lots[best].Qty).GreaterThan(lots[best].Cost.Mul(lot.Qty)) {
+				best = i
+			}
+		}
+		return best
+	}
+	return 0
+}
+
+// orderDisposals groups the disposals of each sell, which the Koinly and CoinTracking formats describe in
+// their trade's comment
+func orderDisposals(disposals []Disposal) map[string][]Disposal {
+	byOrder := map[string][]Disposal{}
+	for _, d := range disposals {
+		byOrder[d.Symbol+"/"+d.OrderID] = append(byOrder[d.Symbol+"/"+d.OrderID], d)
+	}
+	return byOrder
+}
+
+// disposalNote describes the cost basis and gain of a sell's disposals in the quote asset
+func disposalNote(disposals []Disposal, quoteAsset, method string) string {
+	var cost, gain Decimal
+	for _, d := range disposals {
+		cost = cost.Add(d.Cost)
+		gain = gain.Add(d.Gain())
+	}
+	return fmt.Sprintf("%s cost basis %s %s, gain %s %s", strings.ToUpper(method), cost.StringFixed(2), quoteAsset, gain.StringFixed(2), quoteAsset)
+}
+
+// writeKoinly writes the trades in Koinly's universal CSV format, valued in the quote asset
+func writeKoinly(w *csv.Writer, trades []Trade, disposals []Disposal, quoteAsset, method string) error {
+	if err := w.Write([]string{"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency", "Fee Amount", "Fee Currency",
+		"Net Worth Amount", "Net Worth Currency", "Label", "Description", "TxHash"}); err != nil {
+		return err
+	}
+	byOrder := orderDisposals(disposals)
+	for _, trade := range trades {
+		base := strings.TrimSuffix(trade.Symbol, quoteAsset)
+		sent, sentAsset, received, receivedAsset := trade.QuoteQty, quoteAsset, trade.Qty, base
+		description := trade.Symbol + " buy"
+		if trade.Side == "SELL" {
+			sent, sentAsset, received, receivedAsset = trade.Qty, base, trade.QuoteQty, quoteAsset
+			description = trade.Symbol + " sell, " + disposalNote(byOrder[trade.Symbol+"/"+trade.OrderID], quoteAsset, method)
+		}
+		fee, feeAsset := "", ""
+		if trade.Commission.Sign() > 0 {
+			fee, feeAsset = trade.Commission.String(), trade.CommissionAsset
+		}
+		if err := w.Write([]string{trade.Time.UTC().Format("2006-01-02 15:04:05 UTC"), sent.String(), sentAsset, received.String(), receivedAsset,
+			fee, feeAsset, trade.QuoteQty.String(), quoteAsset, "", description, trade.OrderID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeCoinTracking writes the trades in CoinTracking's CSV import format
+func writeCoinTracking(w *csv.Writer, trades []Trade, disposals []Disposal, quoteAsset, method string) error {
+	if err := w.Write([]string{"Type", "Buy Amount", "Buy Currency", "Sell Amount", "Sell Currency", "Fee", "Fee Currency",
+		"Exchange", "Trade-Group", "Comment", "Date", "Tx-ID"}); err != nil {
+		return err
+	}
+	byOrder := orderDisposals(disposals)
+	for _, trade := range trades {
+		base := strings.TrimSuffix(trade.Symbol, quoteAsset)
+		bought, boughtAsset, sold, soldAsset := trade.Qty, base, trade.QuoteQty, quoteAsset
+		comment := ""
+		if trade.Side == "SELL" {
+			bought, boughtAsset, sold, soldAsset = trade.QuoteQty, quoteAsset, trade.Qty, base
+			comment = disposalNote(byOrder[trade.Symbol+"/"+trade.OrderID], quoteAsset, method)
+		}
+		fee, feeAsset := "", ""
+		if trade.Commission.Sign() > 0 {
+			fee, feeAsset = trade.Commission.String(), trade.CommissionAsset
+		}
+		if err := w.Write([]string{"Trade", bought.String(), boughtAsset, sold.String(), soldAsset, fee, feeAsset,
+			"Binance", trade.Symbol, comment, trade.Time.UTC().Format("02.01.2006 15:04:05"), trade.OrderID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write8949 writes the disposals as Form 8949-style rows in the quote asset, short-term first
+func write8949(w *csv.Writer, disposals []Disposal) error {
+	if err := w.Write([]string{"Description of property", "Date acquired", "Date sold", "Proceeds", "Cost basis", "Gain or loss", "Term"}); err != nil {
+		return err
+	}
+	sorted := slices.Clone(disposals)
+	slices.SortStableFunc(sorted, func(a, b Disposal) int {
+		if a.LongTerm() == b.LongTerm() {
+			return 0
+		}
+		if a.LongTerm() {
+			return 1
+		}
+		return -1
+	})
+	for _, d := range sorted {
+		acquired, term := "VARIOUS", "Short-term"
+		if !d.Acquired.IsZero() {
+			acquired = d.Acquired.UTC().Format("01/02/2006")
+		}
+		if d.LongTerm() {
+			term = "Long-term"
+		}
+		if err := w.Write([]string{d.Qty.String() + " " + d.Asset, acquired, d.Sold.UTC().Format("01/02/2006"),
+			d.Proceeds.StringFixed(2), d.Cost.StringFixed(2), d.Gain().StringFixed(2), term}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runTaxExport exports a trade journal for tax software, with the cost basis of sells matched by a lot method
+func runTaxExport(args []string) error {
+	fs, common := newFlagSet("tax-export")
+	journalPath := fs.String("journal", "", "Trade journal (CSV) written with -journal")
+	format := fs.String("format", TaxFormatKoinly, "Export format: koinly, cointracking or 8949")
+	method := fs.String("method", CostBasisFIFO, "Lot matching method: fifo, lifo or hifo (highest cost first)")
+	quoteAsset := fs.String("quote", "USDT", "Quote asset of the symbols to export, which cost basis and proceeds are in")
+	year := fs.Int("year", 0, "Tax year to export, by trade or sale date (0 for all). Lots bought in earlier years are still matched.")
+	out := fs.String("out", "", "File to write the export to (stdout when empty)")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if *journalPath == "" {
+		return fmt.Errorf("-journal is required")
+	}
+	if *format != TaxFormatKoinly && *format != TaxFormatCoinTracking && *format != TaxFormat8949 {
+		return fmt.Errorf("invalid format: %s. Use koinly, cointracking or 8949", *format)
+	}
+	if *method != CostBasisFIFO && *method != CostBasisLIFO && *method != CostBasisHIFO {
+		return fmt.Errorf("invalid method: %s. Use fifo, lifo or hifo", *method)
+	}
+	if *year < 0 {
+		return fmt.Errorf("-year must not be negative")
+	}
+	quote := strings.ToUpper(*quoteAsset)
+
+	trades, skipped, err := readJournalTrades(*journalPath, quote)
+	if err != nil {
+		return err
+	}
+	if len(trades) == 0 {
+		return fmt.Errorf("no %s fills in %s", quote, *journalPath)
+	}
+	if skipped > 0 {
+		log.Printf("Skipped %d journal row(s) of symbols not quoted in %s", skipped, quote)
+	}
+	disposals := matchLots(trades, quote, *method)
+	if *year > 0 {
+		trades = slices.DeleteFunc(trades, func(t Trade) bool { return t.Time.UTC().Year() != *year })
+		disposals = slices.DeleteFunc(disposals, func(d Disposal) bool { return d.Sold.UTC().Year() != *year })
+	}
+
+	var dest io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("error creating export: %v", err)
+		}
+		defer file.Close()
+		dest = file
+	}
+	w := csv.NewWriter(dest)
+	switch *format {
+	case TaxFormatKoinly:
+		err = writeKoinly(w, trades, disposals, quote, *method)
+	case TaxFormatCoinTracking:
+		err = writeCoinTracking(w, trades, disposals, quote, *method)
+	default:
+		err = write8949(w, disposals)
+	}
+	if err != nil {
+		return fmt.Errorf("error writing export: %v", err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing export: %v", err)
+	}
+	if *out != "" {
+		log.Printf("Exported %d trade(s) and %d disposal(s) to %s", len(trades), len(disposals), *out)
+	}
+	return nil
+}
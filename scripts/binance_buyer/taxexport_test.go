@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchLots(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n) }
+	trade := func(side, orderID string, n int, qty, quote string) Trade {
+		return Trade{Symbol: "BTCUSDT", Side: side, OrderID: orderID, Qty: decimalOrZero(qty), QuoteQty: decimalOrZero(quote), Time: day(n)}
+	}
+	trades := []Trade{
+		trade("BUY", "1", 0, "1", "100"),
+		trade("BUY", "2", 1, "1", "300"),
+		trade("BUY", "3", 2, "1", "200"),
+		// Sold a year and a day after the first buy, and exactly a year after the second
+		trade("SELL", "4", 366, "1.5", "600"),
+	}
+	type disposal struct {
+		acquired int
+		qty      string
+		cost     string
+		proceeds string
+		longTerm bool
+	}
+	tests := []struct {
+		method string
+		want   []disposal
+	}{
+		{method: CostBasisFIFO, want: []disposal{
+			{acquired: 0, qty: "1", cost: "100", proceeds: "400", longTerm: true},
+			{acquired: 1, qty: "0.5", cost: "150", proceeds: "200"},
+		}},
+		{method: CostBasisLIFO, want: []disposal{
+			{acquired: 2, qty: "1", cost: "200", proceeds: "400"},
+			{acquired: 1, qty: "0.5", cost: "150", proceeds: "200"},
+		}},
+		{method: CostBasisHIFO, want: []disposal{
+			{acquired: 1, qty: "1", cost: "300", proceeds: "400"},
+			{acquired: 2, qty: "0.5", cost: "100", proceeds: "200"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			got := matchLots(trades, "USDT", tt.method)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d disposals, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				d := got[i]
+				if !d.Acquired.Equal(day(want.acquired)) || d.Qty.Cmp(decimalOrZero(want.qty)) != 0 ||
+					d.Cost.Cmp(decimalOrZero(want.cost)) != 0 || d.Proceeds.Cmp(decimalOrZero(want.proceeds)) != 0 {
+					t.Errorf("disposal %d: %s acquired %s at cost %s for %s, want %s acquired %s at cost %s for %s", i,
+						d.Qty, d.Acquired.Format(time.DateOnly), d.Cost, d.Proceeds,
+						want.qty, day(want.acquired).Format(time.DateOnly), want.cost, want.proceeds)
+				}
+				if d.LongTerm() != want.longTerm {
+					t.Errorf("disposal %d: long-term %t, want %t", i, d.LongTerm(), want.longTerm)
+				}
+			}
+		})
+	}
+}
+
+func TestMatchLotsCountsFees(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	trades := []Trade{
+		// The base asset fee leaves 0.999 in the lot
+		{Symbol: "BTCUSDT", Side: "BUY", OrderID: "1", Qty: decimalOrZero("1"), QuoteQty: decimalOrZero("100"),
+			Commission: decimalOrZero("0.001"), CommissionAsset: "BTC", Time: at},
+		// The quote asset fee reduces the proceeds
+		{Symbol: "BTCUSDT", Side: "SELL", OrderID: "2", Qty: decimalOrZero("0.999"), QuoteQty: decimalOrZero("150"),
+			Commission: decimalOrZero("0.15"), CommissionAsset: "USDT", Time: at.Add(time.Hour)},
+	}
+	got := matchLots(trades, "USDT", CostBasisFIFO)
+	if len(got) != 1 {
+		t.Fatalf("got %d disposals, want 1", len(got))
+	}
+	if got[0].Qty.Cmp(decimalOrZero("0.999")) != 0 || got[0].Cost.Cmp(decimalOrZero("100")) != 0 ||
+		got[0].Proceeds.Cmp(decimalOrZero("149.85")) != 0 {
+		t.Errorf("disposal %s at cost %s for %s, want 0.999 at cost 100 for 149.85", got[0].Qty, got[0].Cost, got[0].Proceeds)
+	}
+}
+
+func TestMatchLotsReportsUncoveredSellsWithoutGain(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	trades := []Trade{
+		{Symbol: "BTCUSDT", Side: "BUY", OrderID: "1", Qty: decimalOrZero("1"), QuoteQty: decimalOrZero("100"), Time: at},
+		{Symbol: "ETHUSDT", Side: "BUY", OrderID: "2", Qty: decimalOrZero("5"), QuoteQty: decimalOrZero("50"), Time: at},
+		{Symbol: "BTCUSDT", Side: "SELL", OrderID: "3", Qty: decimalOrZero("2"), QuoteQty: decimalOrZero("400"), Time: at.Add(time.Hour)},
+	}
+	got := matchLots(trades, "USDT", CostBasisFIFO)
+	if len(got) != 2 {
+		t.Fatalf("got %d disposals, want the covered and the uncovered part", len(got))
+	}
+	if got[0].Gain().Cmp(decimalOrZero("100")) != 0 {
+		t.Errorf("covered part gains %s, want 100", got[0].Gain())
+	}
+	uncovered := got[1]
+	if !uncovered.Acquired.IsZero() || uncovered.Qty.Cmp(decimalOrZero("1")) != 0 || !uncovered.Gain().IsZero() {
+		t.Errorf("uncovered part %s acquired %s gains %s, want 1 without acquisition or gain", uncovered.Qty, uncovered.Acquired, uncovered.Gain())
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Wallets of an account funds can be transferred between
+const (
+	WalletSpot    = "spot"
+	WalletFutures = "futures"
+	WalletMargin  = "margin"
+	WalletFunding = "funding"
+)
+
+// binanceWalletTypes are the names of the wallets in Binance universal transfer types, e.g. MAIN_UMFUTURE
+var binanceWalletTypes = map[string]string{
+	WalletSpot:    "MAIN",
+	WalletFutures: "UMFUTURE",
+	WalletMargin:  "MARGIN",
+	WalletFunding: "FUNDING",
+}
+
+// marginTopUpBuffer is the share of an order's margin transferred on top of what it needs, covering fees and
+// the price moving before the order fills
+const marginTopUpBuffer = 0.02
+
+// WalletTransferer is implemented by clients that can move funds between the wallets of the account
+type WalletTransferer interface {
+	Transfer(from, to, asset string, amount Decimal) (string, error)
+}
+
+// transfererOf returns the wallet transfers of a client, or an error when the exchange does not provide them
+func transfererOf(client ExchangeClient, exchange string) (WalletTransferer, error) {
+	transferer, ok := baseClient(client).(WalletTransferer)
+	if !ok {
+		return nil, fmt.Errorf("wallet transfers are not supported on %s", exchange)
+	}
+	return transferer, nil
+}
+
+// binanceTransferType returns the universal transfer type moving funds from one wallet to another
+func binanceTransferType(from, to string) (string, error) {
+	fromType, ok := binanceWalletTypes[strings.ToLower(from)]
+	if !ok {
+		return "", fmt.Errorf("invalid wallet: %s. Use spot, futures, margin or funding", from)
+	}
+	toType, ok := binanceWalletTypes[strings.ToLower(to)]
+	if !ok {
+		return "", fmt.Errorf("invalid wallet: %s. Use spot, futures, margin or funding", to)
+	}
+	if fromType == toType {
+		return "", fmt.Errorf("cannot transfer from the %s wallet to itself", from)
+	}
+	return fromType + "_" + toType, nil
+}
+
+// Transfer moves an amount of an asset between two wallets of the account and returns the transfer's ID. Like a
+// withdrawal, the request is sent once without retries so a lost response cannot transfer twice.
+func (c *BinanceClient) Transfer(from, to, asset string, amount Decimal) (string, error) {
+	transferType, err := binanceTransferType(from, to)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	params.Set("type", transferType)
+	params.Set("asset", asset)
+	params.Set("amount", amount.String())
+	body, err := c.resendOnTimestampError(func() (*httpResult, error) {
+		return c.sendOnce("POST", "/sapi/v1/asset/transfer", params, binanceAuthSigned)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var raw struct {
+		TranID int64 `json:"tranId"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return "", fmt.Errorf("error parsing response: %v", err)
+	}
+	return strconv.FormatInt(raw.TranID, 10), nil
+}
+
+// Transfer moves an amount of an asset between two wallets of the account through the spot API
+func (c *BinanceMarginClient) Transfer(from, to, asset string, amount Decimal) (string, error) {
+	return c.rest.Transfer(from, to, asset, amount)
+}
+
+// Transfer moves an amount of an asset between two wallets of the account through the spot API, which the
+// futures testnet does not have
+func (c *BinanceFuturesClient) Transfer(from, to, asset string, amount Decimal) (string, error) {
+	if c.spot == nil {
+		return "", fmt.Errorf("wallet transfers are not supported on the futures testnet")
+	}
+	return c.spot.Transfer(from, to, asset, amount)
+}
+
+// spotBalance gets the free balance of an asset in the spot wallet, which margin is topped up from
+func (c *BinanceFuturesClient) spotBalance(asset string) (Decimal, error) {
+	if c.spot == nil {
+		return Decimal{}, fmt.Errorf("the futures testnet has no spot wallet")
+	}
+	return c.spot.GetBalance(asset)
+}
+
+// marginTopUpClient moves margin from the spot wallet to the futures wallet before an order that the available
+// margin cannot cover, up to the run's transfer allowance. Orders it cannot top up for are placed anyway and
+// rejected by the exchange as before.
+type marginTopUpClient struct {
+	ExchangeClient
+	futures *BinanceFuturesClient
+	state   *RunState
+	mu      sync.Mutex
+	// leverage is the symbol's leverage, read from its position on the first order
+	leverage int
+}
+
+// withMarginTopUp wraps the client of a futures run allowed to transfer margin from spot. Other runs get the
+// client back unchanged.
+func withMarginTopUp(client ExchangeClient, state *RunState) ExchangeClient {
+	futures, err := futuresClient(client)
+	if err != nil || state.Config.Futures.AutoTransferMax.Sign() <= 0 {
+		return client
+	}
+	return &marginTopUpClient{ExchangeClient: client, futures: futures, state: state}
+}
+
+// Unwrap returns the wrapped client
+func (c *marginTopUpClient) Unwrap() ExchangeClient {
+	return c.ExchangeClient
+}
+
+// PlaceOrder tops up the futures margin for an order that opens or adds to a position, then places it
+func (c *marginTopUpClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	if !req.ReduceOnly {
+		if err := c.topUp(req); err != nil {
+			log.Printf("Error topping up futures margin: %v", err)
+		}
+	}
+	return c.ExchangeClient.PlaceOrder(req)
+}
+
+// topUp transfers the margin an order lacks, plus a buffer, from the spot wallet, at most what is left of the
+// run's allowance and the spot balance
+func (c *marginTopUpClient) topUp(req OrderRequest) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cfg := c.state.Config
+	notional := req.QuoteQuantity
+	if notional.IsZero() {
+		price := req.Price
+		if price.IsZero() {
+			last, err := c.ExchangeClient.GetPrice(req.Symbol)
+			if err != nil {
+				return fmt.Errorf("error pricing order: %v", err)
+			}
+			price = NewDecimalFromFloat(last)
+		}
+		notional = req.Quantity.Mul(price)
+	}
+	if c.leverage == 0 {
+		position, err := c.futures.GetPosition(req.Symbol)
+		if err != nil {
+			return fmt.Errorf("error getting %s leverage: %v", req.Symbol, err)
+		}
+		c.leverage = max(position.Leverage, 1)
+	}
+	required := notional.Div(NewDecimalFromInt(int64(c.leverage))).MulFloat(1 + marginTopUpBuffer)
+	available, err := c.futures.GetBalance(cfg.QuoteAsset)
+	if err != nil {
+		return fmt.Errorf("error getting %s margin balance: %v", cfg.QuoteAsset, err)
+	}
+	if !available.LessThan(required) {
+		return nil
+	}
+
+	allowance := cfg.Futures.AutoTransferMax.Sub(c.state.MarginTransferred)
+	if allowance.Sign() <= 0 {
+		return fmt.Errorf("the run has used its transfer allowance of %s %s", cfg.Futures.AutoTransferMax, cfg.QuoteAsset)
+	}
+	spot, err := c.futures.spotBalance(cfg.QuoteAsset)
+	if err != nil {
+		return fmt.Errorf("error getting %s spot balance: %v", cfg.QuoteAsset, err)
+	}
+	amount := minDecimal(minDecimal(required.Sub(available).CeilToStep(NewDecimalFromFloat(0.01)), allowance), spot)
+	if amount.Sign() <= 0 {
+		return fmt.Errorf("no %s in the spot wallet to transfer", cfg.QuoteAsset)
+	}
+	id, err := c.futures.Transfer(WalletSpot, WalletFutures, cfg.QuoteAsset, amount)
+	if err != nil {
+		return fmt.Errorf("error transferring %s %s from spot: %v", amount, cfg.QuoteAsset, err)
+	}
+	c.state.MarginTransferred = c.state.MarginTransferred.Add(amount)
+	log.Printf("Transferred %s %s of margin from spot to futures (transfer %s, %s of %s allowed so far)", amount, cfg.QuoteAsset, id,
+		c.state.MarginTransferred, cfg.Futures.AutoTransferMax)
+	c.state.audit(AuditTransferred, "transfer_id", id, "asset", cfg.QuoteAsset, "amount", amount, "from", WalletSpot, "to", WalletFutures,
+		"transferred", c.state.MarginTransferred)
+	return nil
+}
+
+// runTransfer moves an asset between the spot, futures, margin and funding wallets of the account
+func runTransfer(args []string) error {
+	fs, common := newFlagSet("transfer")
+	from := fs.String("from", WalletSpot, "Wallet to transfer from: spot, futures (USDT-M), margin (cross) or funding")
+	to := fs.String("to", WalletFutures, "Wallet to transfer to: spot, futures (USDT-M), margin (cross) or funding")
+	asset := fs.String("asset", "USDT", "Asset to transfer")
+	amount := fs.String("amount", "", "Amount to transfer (e.g., 100)")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if _, err := binanceTransferType(*from, *to); err != nil {
+		return err
+	}
+	quantity, err := ParseDecimal(*amount)
+	if err != nil || quantity.Sign() <= 0 {
+		return fmt.Errorf("invalid amount %q: a positive amount is required", *amount)
+	}
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+	transferer, err := transfererOf(client, common.exchange)
+	if err != nil {
+		return err
+	}
+
+	id, err := transferer.Transfer(*from, *to, strings.ToUpper(*asset), quantity)
+	if err != nil {
+		return fmt.Errorf("error transferring %s %s: %v", quantity, strings.ToUpper(*asset), err)
+	}
+	fmt.Printf("Transferred %s %s from %s to %s (transfer %s)\n", quantity, strings.ToUpper(*asset), strings.ToLower(*from), strings.ToLower(*to), id)
+	return nil
+}
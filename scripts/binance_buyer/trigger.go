@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// triggerPollInterval is how often an armed trigger rechecks the price when no streamed trade arrives
+const triggerPollInterval = 5 * time.Second
+
+// triggerOperators are the comparisons a price trigger accepts, longest first so "<=" is not read as "<"
+var triggerOperators = []string{"<=", ">=", "<", ">"}
+
+// PriceTrigger is a condition on the last price that starts a run once it holds, e.g. price <= 60000
+type PriceTrigger struct {
+	Op    string
+	Price float64
+}
+
+// parsePriceTrigger parses a -start-when condition of the form "price <op> <value>"
+func parsePriceTrigger(condition string) (PriceTrigger, error) {
+	invalid := fmt.Errorf("invalid start condition: %s. Use price <=, >=, < or > a value, e.g. \"price <= 60000\"", condition)
+	rest, ok := strings.CutPrefix(strings.ToLower(strings.TrimSpace(condition)), "price")
+	if !ok {
+		return PriceTrigger{}, invalid
+	}
+	rest = strings.TrimSpace(rest)
+	for _, op := range triggerOperators {
+		value, ok := strings.CutPrefix(rest, op)
+		if !ok {
+			continue
+		}
+		price, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || price <= 0 {
+			return PriceTrigger{}, invalid
+		}
+		return PriceTrigger{Op: op, Price: price}, nil
+	}
+	return PriceTrigger{}, invalid
+}
+
+// Fired reports whether price satisfies the condition
+func (t PriceTrigger) Fired(price float64) bool {
+	switch t.Op {
+	case "<=":
+		return price <= t.Price
+	case ">=":
+		return price >= t.Price
+	case "<":
+		return price < t.Price
+	}
+	return price > t.Price
+}
+
+// String formats the condition as -start-when takes it
+func (t PriceTrigger) String() string {
+	return fmt.Sprintf("price %s %g", t.Op, t.Price)
+}
+
+// waitForTrigger arms a trigger, checking the price of symbol on every streamed trade until the condition
+// fires. It reports false when expiry passes first, with a zero expiry never passing, or ctx is cancelled.
+func waitForTrigger(ctx context.Context, client ExchangeClient, symbol string, trigger PriceTrigger, expiry time.Duration) bool {
+	var deadline time.Time
+	if expiry > 0 {
+		deadline = time.Now().Add(expiry)
+		log.Printf("Armed: the run starts once %s %s, or expires at %s", symbol, trigger, deadline.Format(time.RFC3339))
+	} else {
+		log.Printf("Armed: the run starts once %s %s", symbol, trigger)
+	}
+	for {
+		price, err := client.GetPrice(symbol)
+		if err != nil {
+			log.Printf("Error checking the start condition: %v", err)
+		} else if trigger.Fired(price) {
+			log.Printf("Start condition %s met at %.8f. Starting the run.", trigger, price)
+			return true
+		}
+		poll := triggerPollInterval
+		if !deadline.IsZero() {
+			if poll = min(poll, time.Until(deadline)); poll <= 0 {
+				log.Printf("Start condition %s not met before it expired. The run was not started.", trigger)
+				return false
+			}
+		}
+		if !waitForTrade(ctx, client, symbol, poll) {
+			log.Printf("Interrupted while armed. The run was not started.")
+			return false
+		}
+	}
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+)
+
+// Market orders acknowledged before they settle are queried every orderSettlePoll for up to orderSettleTimeout
+const (
+	orderSettlePoll    = 250 * time.Millisecond
+	orderSettleTimeout = 10 * time.Second
+)
+
+// TWAPConfig holds the parameters of a time-weighted execution run
+type TWAPConfig struct {
+	Exchange string `json:"exchange"`
+	Testnet  bool   `json:"testnet,omitempty"`
+	// Market is MarketSpot or MarketFutures, where empty is spot for runs persisted before futures existed
+	Market     string  `json:"market,omitempty"`
+	Symbol     string  `json:"symbol"`
+	Side       string  `json:"side"`
+	QuoteAsset string  `json:"quote_asset"`
+	Algo       string  `json:"algo"`
+	Amount     Decimal `json:"amount"`
+	// BaseAmount is set when Amount and the run's budget are a base asset quantity, executed as
+	// base quantity orders, rather than a quote amount
+	BaseAmount bool             `json:"base_amount,omitempty"`
+	Duration   time.Duration    `json:"duration"`
+	OrderType  string           `json:"order_type"`
+	Limit      LimitOrderConfig `json:"limit"`
+	Filters    *SymbolFilters   `json:"filters"`
+	SizeJitter float64          `json:"size_jitter"`
+	TimeJitter float64          `json:"time_jitter"`
+	Band       PriceBand        `json:"band"`
+	Slippage   SlippageLimit    `json:"slippage"`
+	Depth      DepthLimit       `json:"depth"`
+	Exit       ExitConfig       `json:"exit"`
+	Accounts   []AccountWeight  `json:"accounts,omitempty"`
+	Futures    FuturesConfig    `json:"futures,omitzero"`
+	// SideEffect is the borrowing and repaying of a margin run's orders
+	SideEffect string `json:"side_effect,omitempty"`
+	// Replan is how the budget of slices that place no order is re-planned, where empty carries it into the
+	// next slice
+	Replan string `json:"replan,omitempty"`
+	// Kill is the kill switch halting the run on repeated errors, a price drop or a sentinel
+	Kill KillSwitch `json:"kill,omitzero"`
+	// Spend caps what the run's BUY orders may spend per run, per day and per symbol per day
+	Spend SpendCaps `json:"spend,omitzero"`
+	// Position caps the position a BUY run builds up, including what the account already holds
+	Position PositionLimit `json:"position,omitzero"`
+	// Spread holds back slices while the bid-ask spread is too wide
+	Spread SpreadLimit `json:"spread,omitzero"`
+	// Volatility scales slices by the recent realized volatility
+	Volatility VolatilityScaling `json:"volatility,omitzero"`
+	// Participation is the percentage of the market's traded volume a POV run's orders may take
+	Participation float64 `json:"participation,omitempty"`
+	// Opportunistic scales slices by the price's deviation from its rolling average
+	Opportunistic OpportunisticSizing `json:"opportunistic,omitzero"`
+	// Window restricts the slices to trading hours and days
+	Window TradingWindow `json:"window,omitzero"`
+	// Blackouts are the periods of the run's blackout calendar, during which it places no slices
+	Blackouts []Blackout `json:"blackouts,omitempty"`
+	// Withdraw sends what a completed BUY run acquired to an address
+	Withdraw WithdrawConfig `json:"withdraw,omitzero"`
+	// ConvertFees values commissions paid in other assets than the quote asset in the quote asset
+	ConvertFees bool `json:"convert_fees,omitempty"`
+	// Fiat is the currency the run's fills are also expressed in for accounting
+	Fiat FiatConfig `json:"fiat,omitzero"`
+}
+
+// baseAsset returns the base asset of the traded symbol
+func (c TWAPConfig) baseAsset() string {
+	return strings.TrimSuffix(c.Symbol, c.QuoteAsset)
+}
+
+// budgetAsset returns the asset Amount and the run's budget are denominated in
+func (c TWAPConfig) budgetAsset() string {
+	if c.BaseAmount {
+		return c.baseAsset()
+	}
+	return c.QuoteAsset
+}
+
+// executedAmount returns the part of the budget an order executed
+func (c TWAPConfig) executedAmount(order *Order) Decimal {
+	if c.BaseAmount {
+		return decimalOrZero(order.ExecutedQty)
+	}
+	return decimalOrZero(order.CumQuoteQty)
+}
+
+// roundSlice rounds a slice down to an amount an order can be placed for. Base quantities are rounded to the
+// symbol's step size here so the remainder can be carried over, while quote amounts are rounded when placed.
+func (c TWAPConfig) roundSlice(amount Decimal) Decimal {
+	if c.BaseAmount {
+		return c.Filters.RoundQuantity(amount)
+	}
+	return amount
+}
+
+// minSliceAmount returns the smallest slice worth placing: for quote amount runs at least 1 unit of quote asset
+// buying a quantity that, once rounded to the step size, satisfies the symbol's minimum notional at the price
+// the order will use, or for base quantity runs the minimum quantity that satisfies it at price
+func minSliceAmount(cfg TWAPConfig, price float64) Decimal {
+	if cfg.BaseAmount {
+		minSlice := cfg.Filters.MinNotional.Div(NewDecimalFromFloat(price)).CeilToStep(cfg.Filters.StepSize)
+		if minSlice.LessThan(cfg.Filters.MinQty) {
+			minSlice = cfg.Filters.MinQty
+		}
+		if minSlice.LessThan(cfg.Filters.StepSize) {
+			minSlice = cfg.Filters.StepSize
+		}
+		return minSlice
+	}
+
+	if cfg.OrderType == OrderTypeLimit || cfg.OrderType == OrderTypeLimitMaker {
+		// Limit orders buy their quantity at the limit price, which is below the price for a BUY
+		price = limitPrice(&BookTicker{BidPrice: price, AskPrice: price}, cfg.Side, cfg.Limit.OffsetBps)
+	}
+	orderPrice := NewDecimalFromFloat(price)
+	minQty := cfg.Filters.MinNotional.Div(orderPrice).CeilToStep(cfg.Filters.StepSize)
+	if minQty.LessThan(cfg.Filters.MinQty) {
+		minQty = cfg.Filters.MinQty
+	}
+	one := NewDecimalFromInt(1)
+	minSlice := minQty.Mul(orderPrice).CeilToStep(one)
+	if minSlice.LessThan(one) {
+		minSlice = one
+	}
+	return minSlice
+}
+
+// planTWAP splits the configured amount into evenly sized slices spread over the run duration, sizing the
+// minimum slice at the current price
+func planTWAP(cfg TWAPConfig, price float64) *RunState {
+	state := &RunState{
+		RunID:     newRunID(),
+		Config:    cfg,
+		Remaining: cfg.Amount,
+		StartedAt: time.Now(),
+	}
+
+	// Calculate per-second amount, in cents for quote amounts
+	asset := cfg.budgetAsset()
+	totalSeconds := cfg.Duration.Seconds()
+	perSecond := cfg.Amount.Float64() / totalSeconds
+	if !cfg.BaseAmount {
+		perSecond = math.Round(perSecond*100) / 100
+	}
+
+	log.Printf("Total run time: %s (%.0f seconds)", cfg.Duration, totalSeconds)
+	log.Printf("%s amount per second: %.8f", asset, perSecond)
+	log.Printf("Total %s to %s: %.8f", asset, strings.ToLower(cfg.Side), perSecond*totalSeconds)
+
+	minSlice := minSliceAmount(cfg, price)
+	state.MinSlice = minSlice
+
+	if perSecond < minSlice.Float64() {
+		// Calculate number of intervals (each interval trades the minimum slice)
+		nIntervals := int(cfg.Amount.Div(minSlice).FloorToStep(NewDecimalFromInt(1)).Float64())
+		if nIntervals == 0 {
+			log.Printf("%s amount to use is less than %s. Nothing to do.", asset, minSlice)
+			return state
+		}
+		state.TotalSlices = nIntervals
+		state.PlannedSlices = nIntervals
+		state.SliceAmount = minSlice
+		if cfg.BaseAmount {
+			// Spread the whole quantity over the slices so the run trades exactly the requested amount
+			state.SliceAmount = cfg.Amount.Div(NewDecimalFromInt(int64(nIntervals)))
+		}
+		state.Interval = time.Duration(cfg.Duration.Seconds()/float64(nIntervals)) * time.Second
+		log.Printf("Per-second amount < %s %s. Will trade %s %s every %s, %d times.", minSlice, asset, state.SliceAmount, asset, state.Interval, nIntervals)
+	} else {
+		// Calculate number of trades to be made
+		numberOfTrades := int(totalSeconds)
+		if numberOfTrades == 0 {
+			log.Printf("Total run time is less than 1 second. Nothing to buy.")
+			return state
+		}
+		state.TotalSlices = numberOfTrades
+		state.PlannedSlices = numberOfTrades
+		state.SliceAmount = cfg.Amount.Div(NewDecimalFromInt(int64(numberOfTrades)))
+		state.Interval = time.Second
+		log.Printf("Will make %d trades, %s %s per trade", numberOfTrades, state.SliceAmount, asset)
+	}
+
+	return state
+}
+
+// runTWAP executes the remaining slices of a run, persisting its state after every slice. When ctx is
+// cancelled, or the exchange rejects an order with an error that would fail every later slice too, no
+// further slices are scheduled and the state is saved with open orders tracked, so the run can be resumed.
+func runTWAP(ctx context.Context, client ExchangeClient, state *RunState, statePath string) {
+	cfg := state.Config
+	tracker := newLimitOrderTracker(client, cfg, state.OpenOrders, state.recordFill)
+	tracker.audit = state.audit
+	schedule := newSliceSchedule(state.NextSlice, state.Interval)
+	var fatalErr error
+	var aborted bool
+	state.Killed = ""
+	state.trackBenchmark(client)
+	if cfg.Opportunistic.Enabled() {
+		state.priceAverage = NewSMA(cfg.Opportunistic.Window)
+	}
+	if cfg.Algo == AlgoPOV && state.benchmarkFeed == nil {
+		slog.Error("POV runs size their orders by the streamed trades of the symbol. Run with -market-data ws on a single account.", "symbol", cfg.Symbol)
+		return
+	}
+
+	state.control.acceptBudgetChanges(true)
+	defer state.control.acceptBudgetChanges(false)
+	for state.NextSlice < state.TotalSlices && ctx.Err() == nil {
+		state.control.update(state)
+		// Slices resume once the window opens or a blackout ends, rather than catching up with the ones due meanwhile
+		if rescheduled, ok := state.awaitSlice(ctx); !ok {
+			break
+		} else if rescheduled {
+			schedule = newSliceSchedule(state.NextSlice, state.Interval)
+		}
+		if change, ok := state.control.takeBudgetChange(); ok {
+			state.changeBudget(change)
+			state.save(statePath)
+		}
+		state.Remaining = state.Remaining.Add(tracker.Poll(false))
+		if state.Remaining.LessThan(state.MinSlice) {
+			slog.Info("Insufficient amount for next order. Stopping.", "symbol", cfg.Symbol, "remaining_budget", state.Remaining, "min_slice", state.MinSlice, "budget_asset", cfg.budgetAsset())
+			break
+		}
+
+		// Slices smaller than the minimum order size are carried over into the next slice, as is the
+		// difference introduced by size jitter so the run still adds up to the target amount. The part of
+		// a slice removed by throttling is not carried over.
+		share := state.backlogShare()
+		state.Backlog = state.Backlog.Sub(share)
+		adjustment := state.adjustmentShare()
+		state.Adjustment = state.Adjustment.Sub(adjustment)
+		due := state.sliceAmount(state.NextSlice).Add(state.Carry).Add(share).Add(adjustment)
+		scheduled := due
+		if state.NextSlice < state.TotalSlices-1 {
+			scheduled = jitter(due, cfg.SizeJitter)
+		}
+		scheduled = minDecimal(scheduled, state.Remaining)
+		amount := state.control.throttle(scheduled)
+		state.samplePrice(client)
+		if reason := state.killReason(); reason != "" {
+			state.kill(tracker, reason)
+			break
+		}
+		amount, reached, positionErr := state.positionGate(client, tracker, amount)
+		if reached {
+			state.audit(AuditStopped, "next_slice", state.NextSlice+1, "remaining_budget", state.Remaining, "reason", "position limit reached")
+			slog.Warn("Position limit reached. Stopping.", "symbol", cfg.Symbol, "next_slice", state.NextSlice+1, "remaining_budget", state.Remaining, "budget_asset", cfg.budgetAsset())
+			break
+		}
+		factor := state.volatilityFactor(client)
+		// POV orders are capped to the participation rate of the volume traded since the previous order, and
+		// opportunistic slices are scaled by the price's deviation from its rolling average. The part of the
+		// slice cut by either is carried over, and a slice scaled up takes from the budget of later slices.
+		var cut Decimal
+		if cfg.Opportunistic.Enabled() && state.NextSlice < state.TotalSlices-1 {
+			scaled := minDecimal(amount.MulFloat(state.opportunisticMultiplier()), state.Remaining)
+			if scaled.LessThan(amount) {
+				cut = amount.Sub(scaled)
+			}
+			amount = scaled
+		}
+		if cfg.Algo == AlgoPOV {
+			capped := minDecimal(amount, state.participationCap())
+			cut = cut.Add(amount.Sub(capped))
+			amount = capped
+		}
+		if positionErr != nil {
+			slog.Warn("Cannot check the position limit. Deferring the slice.", "symbol", cfg.Symbol, "slice", state.NextSlice+1, "error", positionErr)
+			state.audit(AuditSkipped, "slice", state.NextSlice+1, "amount", amount, "reason", "position unknown")
+			state.deferSlice(due)
+		} else if state.batchSlice(factor, amount) {
+			log.Printf("Calm market. Batching slice %d into the next one.", state.NextSlice+1)
+			state.Carry = due
+		} else if reason, capped := state.holdReason(ctx, client, amount); reason != "" {
+			state.audit(AuditSkipped, "slice", state.NextSlice+1, "amount", amount, "reason", reason)
+			state.deferSlice(due)
+		} else {
+			// The part of a slice the order book cannot absorb or that is rounded off is carried over, unlike
+			// the part removed by throttling
+			state.Carry = due.Sub(scheduled).Add(amount.Sub(capped)).Add(cut)
+			slippageBefore := state.cumulativeSlippageBps()
+			committed, unplaced, err := placeSplit(ctx, client, state, tracker, capped, state.sliceParts(factor, capped))
+			state.Carry = state.Carry.Add(unplaced)
+			// The parts of a split slice placed before an error stay committed
+			if isFatalError(err) {
+				state.Carry = due.Sub(committed)
+				state.Remaining = state.Remaining.Sub(committed)
+				fatalErr = err
+				break
+			}
+			if reason := state.recordPlacement(err); reason != "" {
+				state.Carry = due.Sub(committed)
+				state.Remaining = state.Remaining.Sub(committed)
+				state.FailedSlices++
+				state.kill(tracker, reason)
+				break
+			}
+			if committed.IsZero() {
+				state.FailedSlices++
+				if cfg.Replan == "" {
+					// Without re-planning, a slice whose orders were all skipped is carried into the next one
+					state.Carry = state.Carry.Add(capped.Sub(unplaced))
+				} else {
+					state.replan(capped.Sub(unplaced))
+				}
+			} else {
+				state.PlacedSlices++
+				state.Remaining = state.Remaining.Sub(committed)
+				if cfg.Algo == AlgoPOV {
+					state.markParticipation()
+				}
+				slog.Info("Slice placed", "symbol", cfg.Symbol, "slice", state.NextSlice+1, "total_slices", state.TotalSlices, "committed", committed, "remaining_budget", state.Remaining, "budget_asset", cfg.budgetAsset())
+			}
+			if cfg.Slippage.crossed(slippageBefore, state.cumulativeSlippageBps()) {
+				aborted = state.handleSlippageLimit()
+			}
+		}
+
+		state.NextSlice++
+		if cfg.Algo == AlgoPOV {
+			state.extendPOV()
+		}
+		state.OpenOrders = tracker.open
+		state.save(statePath)
+		state.emailDaily()
+		if aborted {
+			break
+		}
+
+		// Each slice is shifted randomly around its nominal time without changing the overall run time
+		if state.NextSlice < state.TotalSlices {
+			schedule.wait(ctx, state.NextSlice, jitterOffset(cfg.TimeJitter))
+		}
+	}
+
+	if fatalErr != nil {
+		state.OpenOrders = tracker.open
+		state.save(statePath)
+		state.audit(AuditStopped, "next_slice", state.NextSlice+1, "remaining_budget", state.Remaining, "error", fatalErr.Error())
+		slog.Error("Run stopped by a fatal exchange error. Fix the cause and resume it with -resume.", "symbol", cfg.Symbol, "next_slice", state.NextSlice+1, "total_slices", state.TotalSlices, "open_orders", len(state.OpenOrders), "remaining_budget", state.Remaining, "error", fatalErr)
+		return
+	}
+
+	if state.Killed != "" {
+		state.control.update(state)
+		state.save(statePath)
+		return
+	}
+
+	state.Remaining = state.Remaining.Add(tracker.Drain(ctx))
+	state.OpenOrders = tracker.open
+	state.control.update(state)
+	if ctx.Err() != nil {
+		state.save(statePath)
+		state.audit(AuditInterrupted, "next_slice", state.NextSlice+1, "remaining_budget", state.Remaining, "open_orders", len(state.OpenOrders))
+		slog.Info("Run interrupted. Resume it with -resume.", "symbol", cfg.Symbol, "next_slice", state.NextSlice+1, "total_slices", state.TotalSlices, "open_orders", len(state.OpenOrders), "remaining_budget", state.Remaining)
+		return
+	}
+	state.Completed = true
+	state.save(statePath)
+	state.audit(AuditCompleted, "remaining_budget", state.Remaining, "filled_base", state.FilledBase, "filled_quote", state.FilledQuote)
+	slog.Info("Trading completed", "symbol", cfg.Symbol, "side", cfg.Side, "remaining_budget", state.Remaining, "filled_base", state.FilledBase, "filled_quote", state.FilledQuote, "budget_asset", cfg.budgetAsset())
+}
+
+// sliceSchedule times the slices of a run against absolute target times, slice i being due i intervals after
+// the first slice, so the time spent placing orders and retrying requests is taken out of the following wait
+// rather than added to the run time
+type sliceSchedule struct {
+	anchor   time.Time
+	first    int
+	interval time.Duration
+}
+
+// newSliceSchedule starts a schedule whose slice first is due now
+func newSliceSchedule(first int, interval time.Duration) *sliceSchedule {
+	return &sliceSchedule{anchor: time.Now(), first: first, interval: interval}
+}
+
+// due returns the target time of slice i, shifted by offset intervals
+func (s *sliceSchedule) due(i int, offset float64) time.Time {
+	return s.anchor.Add(time.Duration(i-s.first)*s.interval + time.Duration(offset*float64(s.interval)))
+}
+
+// wait sleeps until slice i is due, reporting false if ctx is cancelled meanwhile. A slice more than an
+// interval overdue, after a pause or while the host slept, is placed at once and the rest of the schedule is
+// pushed back by the delay, rather than catching up with a burst of orders.
+func (s *sliceSchedule) wait(ctx context.Context, i int, offset float64) bool {
+	target := s.due(i, offset)
+	if lag := time.Since(target); lag > s.interval {
+		s.anchor = s.anchor.Add(lag)
+		log.Printf("Slice %d is %s behind schedule. Pushing the remaining slices back.", i+1, lag.Round(time.Millisecond))
+		return true
+	}
+	return sleepContext(ctx, time.Until(target))
+}
+
+// placeSlice places a single order for the given amount of the run's budget under clientOrderID and returns
+// the amount committed to it, the amount a market order left unfilled, and the error that prevented the
+// order from being placed. A market order commits only what it executed.
+func placeSlice(client ExchangeClient, state *RunState, tracker *limitOrderTracker, amount Decimal, clientOrderID string) (Decimal, Decimal, error) {
+	cfg := state.Config
+	if cfg.OrderType == OrderTypeLimit || cfg.OrderType == OrderTypeLimitMaker {
+		committed, err := tracker.Place(amount, clientOrderID)
+		return committed, Decimal{}, err
+	}
+
+	request := OrderRequest{Symbol: cfg.Symbol, Side: cfg.Side, Type: OrderTypeMarket, ClientOrderID: clientOrderID, ReduceOnly: cfg.Futures.ReduceOnly, SideEffect: cfg.SideEffect}
+	var committed Decimal
+	var err error
+	if cfg.BaseAmount {
+		request.Quantity = cfg.Filters.RoundQuantity(amount)
+		committed = request.Quantity
+		err = cfg.Filters.ValidateOrder(request.Quantity, NewDecimalFromFloat(state.LastPrice))
+	} else {
+		request.QuoteQuantity = cfg.Filters.RoundQuote(amount)
+		committed = request.QuoteQuantity
+		err = cfg.Filters.ValidateNotional(request.QuoteQuantity)
+	}
+	if err != nil {
+		slog.Warn("Skipping order", "symbol", cfg.Symbol, "side", cfg.Side, "qty", request.Quantity, "quote_qty", request.QuoteQuantity, "error", err)
+		state.audit(AuditSkipped, "slice", state.NextSlice+1, "amount", amount, "reason", err.Error())
+		return Decimal{}, Decimal{}, nil
+	}
+
+	// The mid-price right before the order is the benchmark its slippage is measured against
+	ticker, err := client.GetBookTicker(cfg.Symbol)
+	if err != nil {
+		log.Printf("Error getting book ticker, slippage of this slice is not measured: %v", err)
+	}
+
+	order, err := client.PlaceOrder(request)
+	if err != nil {
+		slog.Error("Error placing order", "symbol", cfg.Symbol, "side", cfg.Side, "qty", request.Quantity, "quote_qty", request.QuoteQuantity, "error", err)
+		state.audit(AuditError, "slice", state.NextSlice+1, "client_order_id", clientOrderID, "qty", request.Quantity, "quote_qty", request.QuoteQuantity, "error", err.Error())
+		return Decimal{}, Decimal{}, err
+	}
+	order = settleOrder(client, cfg.Symbol, order)
+	slog.Info("Order placed", "symbol", cfg.Symbol, "side", cfg.Side, "order_id", order.OrderID, "status", order.Status,
+		"qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty, "price", order.Price)
+	state.audit(AuditPlaced, "slice", state.NextSlice+1, "order_id", order.OrderID, "client_order_id", order.ClientOrderID,
+		"type", OrderTypeMarket, "side", cfg.Side, "qty", request.Quantity, "quote_qty", request.QuoteQuantity)
+	state.recordFill(order)
+	state.recordSlippage(ticker, order)
+	if !isTerminalStatus(order.Status) {
+		slog.Warn("Order not settled, counting its requested amount as spent", "symbol", cfg.Symbol, "order_id", order.OrderID,
+			"status", order.Status, "amount", committed, "budget_asset", cfg.budgetAsset())
+		return committed, Decimal{}, nil
+	}
+	// Only what the order executed is spent. The rest of a partly filled order is carried into the next slice.
+	executed := cfg.executedAmount(order)
+	if executed.LessThan(committed) {
+		slog.Warn("Order partially filled", "symbol", cfg.Symbol, "order_id", order.OrderID, "status", order.Status,
+			"orig_qty", order.OrigQty, "executed_qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty,
+			"unfilled", committed.Sub(executed), "budget_asset", cfg.budgetAsset())
+		return executed, committed.Sub(executed), nil
+	}
+	return executed, Decimal{}, nil
+}
+
+// settleOrder queries an order acknowledged before it reached a terminal status, as market orders can be,
+// until it does or orderSettleTimeout passes, and returns its latest state
+func settleOrder(client ExchangeClient, symbol string, order *Order) *Order {
+	deadline := time.Now().Add(orderSettleTimeout)
+	for !isTerminalStatus(order.Status) && time.Now().Before(deadline) {
+		time.Sleep(orderSettlePoll)
+		latest, err := client.GetOrder(symbol, order.OrderID)
+		if err != nil {
+			slog.Error("Error querying order", "symbol", symbol, "order_id", order.OrderID, "error", err)
+			continue
+		}
+		order = latest
+	}
+	return order
+}
+
+// sleepContext sleeps for d or until ctx is cancelled, reporting whether the full duration elapsed
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// jitter scales value by a random factor within [1-fraction, 1+fraction]
+func jitter(value Decimal, fraction float64) Decimal {
+	return value.MulFloat(1 + jitterOffset(fraction))
+}
+
+// jitterOffset returns a uniformly random offset within [-fraction, fraction]
+func jitterOffset(fraction float64) float64 {
+	if fraction <= 0 {
+		return 0
+	}
+	return (rand.Float64()*2 - 1) * fraction
+}
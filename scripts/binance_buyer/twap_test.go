@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// btcFilters are the spot BTCUSDT filters the tests size slices with
+var btcFilters = &SymbolFilters{
+	Symbol:      "BTCUSDT",
+	TickSize:    decimalOrZero("0.01"),
+	StepSize:    decimalOrZero("0.00001"),
+	MinQty:      decimalOrZero("0.00001"),
+	MinNotional: decimalOrZero("5"),
+}
+
+func TestPlanTWAP(t *testing.T) {
+	tests := []struct {
+		name       string
+		amount     string
+		baseAmount bool
+		duration   time.Duration
+		slices     int
+		slice      string
+		interval   time.Duration
+	}{
+		{name: "one slice per second", amount: "36000", duration: time.Hour, slices: 3600, slice: "10", interval: time.Second},
+		{name: "minimum slices", amount: "100", duration: time.Hour, slices: 20, slice: "5", interval: 3 * time.Minute},
+		{name: "remainder below the minimum", amount: "12", duration: time.Hour, slices: 2, slice: "5", interval: 30 * time.Minute},
+		{name: "less than the minimum slice", amount: "4", duration: time.Hour},
+		{name: "less than a second", amount: "1000", duration: 500 * time.Millisecond},
+		{name: "base quantity", amount: "0.01", baseAmount: true, duration: time.Hour, slices: 100, slice: "0.0001", interval: 36 * time.Second},
+		{name: "base quantity spread over the slices", amount: "0.00025", baseAmount: true, duration: time.Hour, slices: 2, slice: "0.000125", interval: 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := TWAPConfig{Symbol: "BTCUSDT", Side: "BUY", QuoteAsset: "USDT", Amount: decimalOrZero(tt.amount),
+				BaseAmount: tt.baseAmount, Duration: tt.duration, Filters: btcFilters}
+			state := planTWAP(cfg, 50000)
+			if state.TotalSlices != tt.slices || state.PlannedSlices != tt.slices {
+				t.Errorf("planned %d of %d slices, want %d", state.PlannedSlices, state.TotalSlices, tt.slices)
+			}
+			if tt.slices == 0 {
+				return
+			}
+			if state.SliceAmount.Cmp(decimalOrZero(tt.slice)) != 0 {
+				t.Errorf("slice amount %s, want %s", state.SliceAmount, tt.slice)
+			}
+			if state.Interval != tt.interval {
+				t.Errorf("interval %s, want %s", state.Interval, tt.interval)
+			}
+			if state.Remaining.Cmp(cfg.Amount) != 0 {
+				t.Errorf("remaining %s, want the whole amount %s", state.Remaining, cfg.Amount)
+			}
+		})
+	}
+}
+
+func TestMinSliceAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     TWAPConfig
+		filters SymbolFilters
+		price   float64
+		want    string
+	}{
+		{name: "quote at the minimum notional", price: 50000, want: "5"},
+		{name: "quote rounded up to the step size", price: 30000, want: "6"},
+		{name: "quote at the minimum quantity", filters: SymbolFilters{MinQty: decimalOrZero("0.001")}, price: 50000, want: "50"},
+		{
+			name:    "quote at least one unit",
+			filters: SymbolFilters{StepSize: decimalOrZero("1"), MinQty: decimalOrZero("1"), MinNotional: decimalOrZero("0.1")},
+			price:   0.2,
+			want:    "1",
+		},
+		{
+			name:  "quote at the limit price",
+			cfg:   TWAPConfig{OrderType: OrderTypeLimit, Limit: LimitOrderConfig{OffsetBps: 100}},
+			price: 50000,
+			want:  "6",
+		},
+		{name: "base at the minimum notional", cfg: TWAPConfig{BaseAmount: true}, price: 50000, want: "0.0001"},
+		{name: "base at the minimum quantity", cfg: TWAPConfig{BaseAmount: true}, filters: SymbolFilters{MinQty: decimalOrZero("0.001")}, price: 50000, want: "0.001"},
+		{
+			name:    "base at least one step",
+			cfg:     TWAPConfig{BaseAmount: true},
+			filters: SymbolFilters{StepSize: decimalOrZero("0.01"), MinNotional: decimalOrZero("0.0001")},
+			price:   50000,
+			want:    "0.01",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filters := *btcFilters
+			if !tt.filters.StepSize.IsZero() {
+				filters.StepSize = tt.filters.StepSize
+			}
+			if !tt.filters.MinQty.IsZero() {
+				filters.MinQty = tt.filters.MinQty
+			}
+			if !tt.filters.MinNotional.IsZero() {
+				filters.MinNotional = tt.filters.MinNotional
+			}
+			cfg := tt.cfg
+			cfg.Side, cfg.Filters = "BUY", &filters
+			if got := minSliceAmount(cfg, tt.price); got.Cmp(decimalOrZero(tt.want)) != 0 {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
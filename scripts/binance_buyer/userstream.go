@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// userStreamKeepAlive is how often the listen key is extended; Binance expires it after 60 minutes
+	userStreamKeepAlive = 30 * time.Minute
+	// userStreamReadTimeout is how long the stream may stay silent, including server pings, before it is considered dead
+	userStreamReadTimeout = 2 * time.Minute
+	// userStreamFillWait is how long an order's final execution report is awaited after it is placed
+	userStreamFillWait = 2 * time.Second
+)
+
+// executionReport is the subset of a Binance executionReport event used to track orders
+type executionReport struct {
+	EventType       string  `json:"e"`
+	Symbol          string  `json:"s"`
+	ClientOrderID   string  `json:"c"`
+	Side            string  `json:"S"`
+	Type            string  `json:"o"`
+	Price           string  `json:"p"`
+	OrigQty         string  `json:"q"`
+	ExecutionType   string  `json:"x"`
+	Status          string  `json:"X"`
+	OrderID         int64   `json:"i"`
+	ExecutedQty     string  `json:"z"`
+	CumQuoteQty     string  `json:"Z"`
+	Commission      string  `json:"n"`
+	CommissionAsset *string `json:"N"`
+}
+
+// UserDataStream tracks the account's orders from Binance executionReport events, so fills, partial
+// fills and commissions are known as the exchange reports them rather than from order responses alone
+type UserDataStream struct {
+	client *BinanceClient
+
+	mu     sync.Mutex
+	orders map[string]*Order
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewUserDataStream creates a user data stream for the client's account
+func NewUserDataStream(client *BinanceClient) *UserDataStream {
+	return &UserDataStream{
+		client: client,
+		orders: map[string]*Order{},
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start connects to the stream in the background
+func (s *UserDataStream) Start() {
+	go s.run()
+}
+
+// Stop closes the stream and waits for the background goroutine to exit
+func (s *UserDataStream) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// order returns a copy of the streamed state of an order, if any event has been received for it
+func (s *UserDataStream) order(orderID string) (*Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[orderID]
+	if !ok {
+		return nil, false
+	}
+	streamed := *order
+	return &streamed, true
+}
+
+// forget stops tracking an order that has reached a terminal status and been consumed
+func (s *UserDataStream) forget(orderID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.orders, orderID)
+}
+
+// run keeps the stream connected until it is stopped
+func (s *UserDataStream) run() {
+	defer close(s.done)
+	keepConnected("User data stream", s.stop, s.consume)
+}
+
+// consume opens a listen key and reads events from it until the connection fails, reporting whether it connected at all
+func (s *UserDataStream) consume() (bool, error) {
+	listenKey, err := s.client.CreateListenKey()
+	if err != nil {
+		return false, fmt.Errorf("error creating listen key: %v", err)
+	}
+	defer s.client.CloseListenKey(listenKey)
+
+	conn, err := dialWebSocket(s.client.wsBaseURL+"/ws/"+listenKey, 10*time.Second)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	defer closeOnStop(conn, s.stop)()
+
+	// Events missed while disconnected would leave tracked orders stale, so start from a clean slate
+	s.mu.Lock()
+	clear(s.orders)
+	s.mu.Unlock()
+
+	disconnected := make(chan struct{})
+	defer close(disconnected)
+	go s.keepAlive(listenKey, disconnected)
+
+	log.Printf("User data stream connected")
+	conn.SetReadTimeout(userStreamReadTimeout)
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		if err := s.handle(message); err != nil {
+			return true, err
+		}
+	}
+}
+
+// keepAlive extends the listen key periodically until the connection using it is closed
+func (s *UserDataStream) keepAlive(listenKey string, disconnected <-chan struct{}) {
+	ticker := time.NewTicker(userStreamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.client.KeepAliveListenKey(listenKey); err != nil {
+				log.Printf("Error extending listen key: %v", err)
+			}
+		case <-disconnected:
+			return
+		}
+	}
+}
+
+// handle applies a user data event to the tracked orders
+func (s *UserDataStream) handle(message []byte) error {
+	var report executionReport
+	if err := json.Unmarshal(message, &report); err != nil {
+		log.Printf("Error parsing user data event: %v", err)
+		return nil
+	}
+
+	switch report.EventType {
+	case "listenKeyExpired":
+		return fmt.Errorf("listen key expired")
+	case "executionReport":
+	default:
+		return nil
+	}
+
+	orderID := strconv.FormatInt(report.OrderID, 10)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	order, ok := s.orders[orderID]
+	if !ok {
+		order = &Order{OrderID: orderID}
+		s.orders[orderID] = order
+	}
+	order.Symbol = report.Symbol
+	order.ClientOrderID = report.ClientOrderID
+	order.Price = report.Price
+	order.OrigQty = report.OrigQty
+	order.ExecutedQty = report.ExecutedQty
+	order.CumQuoteQty = report.CumQuoteQty
+	order.Status = report.Status
+	order.Type = report.Type
+	order.Side = report.Side
+
+	if report.ExecutionType == "TRADE" {
+		order.Commission = order.Commission.Add(decimalOrZero(report.Commission))
+		if report.CommissionAsset != nil {
+			order.CommissionAsset = *report.CommissionAsset
+		}
+		slog.Info("Fill received", "symbol", order.Symbol, "side", order.Side, "order_id", orderID, "status", order.Status,
+			"executed_qty", order.ExecutedQty, "quote_qty", order.CumQuoteQty, "commission", report.Commission, "commission_asset", order.CommissionAsset)
+	}
+	return nil
+}
+
+// userDataClient reports order state from a user data stream, falling back to the wrapped
+// client's REST endpoints for orders the stream has not seen
+type userDataClient struct {
+	ExchangeClient
+	stream *UserDataStream
+}
+
+// PlaceOrder places an order and, once the response shows it completed, returns the final state
+// reported by the stream, including commissions
+func (c *userDataClient) PlaceOrder(req OrderRequest) (*Order, error) {
+	order, err := c.ExchangeClient.PlaceOrder(req)
+	if err != nil || !isTerminalStatus(order.Status) {
+		return order, err
+	}
+
+	deadline := time.Now().Add(userStreamFillWait)
+	for time.Now().Before(deadline) {
+		if streamed, ok := c.stream.order(order.OrderID); ok && isTerminalStatus(streamed.Status) {
+			c.stream.forget(order.OrderID)
+			return streamed, nil
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	log.Printf("No execution report received for order %s. Using the order response.", order.OrderID)
+	return order, nil
+}
+
+// GetOrder returns the streamed state of an order without a REST request when the stream has seen it
+func (c *userDataClient) GetOrder(symbol, orderID string) (*Order, error) {
+	if streamed, ok := c.stream.order(orderID); ok {
+		if isTerminalStatus(streamed.Status) {
+			c.stream.forget(orderID)
+		}
+		return streamed, nil
+	}
+	return c.ExchangeClient.GetOrder(symbol, orderID)
+}
+
+// Unwrap returns the wrapped client
+func (c *userDataClient) Unwrap() ExchangeClient {
+	return c.ExchangeClient
+}
+
+// withUserData wraps the client so order state comes from the user data stream. It returns the client
+// unchanged when the exchange has no user data stream support, along with a function that stops the stream.
+func withUserData(client ExchangeClient) (ExchangeClient, func()) {
+	binance, ok := baseClient(client).(*BinanceClient)
+	if !ok {
+		log.Printf("User data stream is not supported for this exchange. Tracking fills from order responses.")
+		return client, func() {}
+	}
+
+	stream := NewUserDataStream(binance)
+	stream.Start()
+	return &userDataClient{ExchangeClient: client, stream: stream}, stream.Stop
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// volatilityCheckInterval is how often the recent volatility is remeasured, once per 1m candle
+const volatilityCheckInterval = time.Minute
+
+// VolatilityScaling scales slices by the realized volatility of 1m returns over the last Window minutes,
+// relative to its level over the Lookback minutes before the run's first slice. Calm markets batch up to MaxFactor
+// slices into one larger order, and volatile markets split a slice into up to MaxFactor smaller orders spread
+// over its interval, so the budget stays on schedule either way. A MaxFactor of 1 or less is disabled.
+type VolatilityScaling struct {
+	MaxFactor float64 `json:"max_factor"`
+	Window    int     `json:"window"`
+	Lookback  int     `json:"lookback"`
+}
+
+// Enabled reports whether slices are scaled
+func (v VolatilityScaling) Enabled() bool {
+	return v.MaxFactor > 1
+}
+
+// realizedVolatility returns the standard deviation of the log returns between the closes of candles, or
+// zero when there are too few of them
+func realizedVolatility(candles []Candle) float64 {
+	var returns []float64
+	for i := 1; i < len(candles); i++ {
+		if candles[i-1].Close > 0 && candles[i].Close > 0 {
+			returns = append(returns, math.Log(candles[i].Close/candles[i-1].Close))
+		}
+	}
+	if len(returns) < 2 {
+		return 0
+	}
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	return math.Sqrt(variance / float64(len(returns)-1))
+}
+
+// minuteVolatility measures the realized volatility of the last minutes 1m candles of symbol
+func minuteVolatility(client ExchangeClient, symbol string, minutes int) (float64, error) {
+	end := time.Now()
+	candles, err := client.GetKlines(symbol, "1m", end.Add(-time.Duration(minutes+1)*time.Minute), end)
+	if err != nil {
+		return 0, err
+	}
+	vol := realizedVolatility(candles)
+	if vol == 0 {
+		return 0, fmt.Errorf("not enough 1m candles of %s to measure volatility", symbol)
+	}
+	return vol, nil
+}
+
+// sizeFactor returns how much larger than planned slices are made at the volatility ratio recent/reference:
+// the inverse of the ratio, bounded to [1/MaxFactor, MaxFactor]
+func (v VolatilityScaling) sizeFactor(recent, reference float64) float64 {
+	if recent <= 0 || reference <= 0 {
+		return 1
+	}
+	return math.Max(1/v.MaxFactor, math.Min(v.MaxFactor, reference/recent))
+}
+
+// volatilityFactor returns the slice size factor at the current volatility, remeasured at most once a
+// minute. The reference volatility is measured once, before the run's first slice, and saved with the state.
+// The factor is 1 while volatility cannot be measured.
+func (s *RunState) volatilityFactor(client ExchangeClient) float64 {
+	cfg := s.Config
+	if !cfg.Volatility.Enabled() {
+		return 1
+	}
+	if time.Since(s.volatilityCheckedAt) < volatilityCheckInterval {
+		return s.volatilityScale
+	}
+	s.volatilityCheckedAt = time.Now()
+	s.volatilityScale = 1
+	if s.VolatilityReference == 0 {
+		reference, err := minuteVolatility(client, cfg.Symbol, cfg.Volatility.Lookback)
+		if err != nil {
+			log.Printf("Error measuring reference volatility, slices are not scaled: %v", err)
+			return 1
+		}
+		s.VolatilityReference = reference
+	}
+	recent, err := minuteVolatility(client, cfg.Symbol, cfg.Volatility.Window)
+	if err != nil {
+		log.Printf("Error measuring volatility, slices are not scaled: %v", err)
+		return 1
+	}
+	s.volatilityScale = cfg.Volatility.sizeFactor(recent, s.VolatilityReference)
+	log.Printf("Volatility %.6f vs reference %.6f. Slices scaled by %.2f.", recent, s.VolatilityReference, s.volatilityScale)
+	return s.volatilityScale
+}
+
+// batchSlice reports whether a slice of amount is carried into the next one while the market is calm, until
+// it has grown to factor times its planned amount. The last slice is never batched.
+func (s *RunState) batchSlice(factor float64, amount Decimal) bool {
+	return factor > 1 && s.NextSlice < s.TotalSlices-1 && amount.LessThan(s.sliceAmount(s.NextSlice).MulFloat(factor))
+}
+
+// sliceParts returns how many orders a slice of amount is split into while the market is volatile: the
+// inverse of factor, rounded, but no more than leaves every order at the minimum slice
+func (s *RunState) sliceParts(factor float64, amount Decimal) int {
+	if factor >= 1 {
+		return 1
+	}
+	parts := int(math.Round(1 / factor))
+	return max(1, min(parts, int(amount.Div(s.MinSlice).Float64())))
+}
+
+// placeSplit places a slice as parts orders spread evenly over the slice interval, the first one at once. It
+// returns the amount committed and the amount left unplaced: the parts not placed when ctx was cancelled or
+// a part failed with an error, and what market orders left unfilled.
+func placeSplit(ctx context.Context, client ExchangeClient, state *RunState, tracker *limitOrderTracker, amount Decimal, parts int) (Decimal, Decimal, error) {
+	clientOrderID := state.sliceClientOrderID(state.NextSlice)
+	if parts <= 1 {
+		return placeSlice(client, state, tracker, amount, clientOrderID)
+	}
+
+	part := state.Config.roundSlice(amount.Div(NewDecimalFromInt(int64(parts))))
+	unplaced := amount
+	var committed, shortfall Decimal
+	for i := range parts {
+		size := part
+		if i == parts-1 {
+			size = unplaced
+		}
+		if i > 0 && !sleepContext(ctx, state.Interval/time.Duration(parts)) {
+			return committed, unplaced, nil
+		}
+		placed, unfilled, err := placeSlice(client, state, tracker, size, fmt.Sprintf("%s-p%d", clientOrderID, i+1))
+		committed = committed.Add(placed)
+		unplaced = unplaced.Sub(size)
+		shortfall = shortfall.Add(unfilled)
+		if err != nil {
+			return committed, unplaced.Add(shortfall), err
+		}
+	}
+	return committed, shortfall, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Execution algorithms selectable with -algo
+const (
+	AlgoTWAP = "twap"
+	AlgoVWAP = "vwap"
+	// AlgoPOV sizes each order to a share of the volume the market traded since the previous one
+	AlgoPOV = "pov"
+)
+
+// planVWAP plans a run whose slices are weighted by the symbol's historical volume per hour of day (UTC),
+// so more is traded in the hours that usually see more volume
+func planVWAP(client ExchangeClient, cfg TWAPConfig, price float64, lookbackDays int) (*RunState, error) {
+	end := time.Now()
+	candles, err := client.GetKlines(cfg.Symbol, "1h", end.AddDate(0, 0, -lookbackDays), end)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching klines for volume profile: %v", err)
+	}
+	if len(candles) == 0 {
+		return nil, fmt.Errorf("no klines returned for %s", cfg.Symbol)
+	}
+
+	state := planTWAP(cfg, price)
+	state.VolumeProfile = buildVolumeProfile(candles)
+	for i := 0; i < state.TotalSlices; i++ {
+		state.WeightSum += state.sliceWeight(i)
+	}
+	if state.WeightSum == 0 {
+		return nil, fmt.Errorf("historical volume over the run window is zero for %s", cfg.Symbol)
+	}
+
+	log.Printf("VWAP volume profile built from %d hourly candles over %d days", len(candles), lookbackDays)
+	for hour, weight := range state.VolumeProfile {
+		log.Printf("  %02d:00 UTC  %.4f", hour, weight)
+	}
+	return state, nil
+}
+
+// buildVolumeProfile returns the share of the average daily volume traded in each hour of the day (UTC)
+func buildVolumeProfile(candles []Candle) []float64 {
+	profile := make([]float64, 24)
+	var total float64
+	for _, candle := range candles {
+		profile[candle.OpenTime.UTC().Hour()] += candle.QuoteVolume
+		total += candle.QuoteVolume
+	}
+	if total == 0 {
+		return profile
+	}
+	for hour := range profile {
+		profile[hour] /= total
+	}
+	return profile
+}
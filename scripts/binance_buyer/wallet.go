@@ -0,0 +1,359 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DepositAddress is where an asset is deposited to the account on a network
+type DepositAddress struct {
+	Asset   string
+	Network string
+	Address string
+	// Tag is the memo some networks need to credit a deposit, empty when they do not
+	Tag string
+}
+
+// WithdrawalRequest describes a crypto withdrawal from the account
+type WithdrawalRequest struct {
+	Asset string
+	// Network is the network the asset is sent over, where empty uses the asset's default network
+	Network string
+	Address string
+	Tag     string
+	Amount  Decimal
+	// ClientID identifies the withdrawal in the withdrawal history, so a retried request can be recognized
+	ClientID string
+}
+
+// Withdrawal is a withdrawal from the withdrawal history
+type Withdrawal struct {
+	ID        string
+	ClientID  string
+	Asset     string
+	Network   string
+	Address   string
+	Amount    Decimal
+	Fee       Decimal
+	Status    string
+	TxID      string
+	AppliedAt time.Time
+}
+
+// Wallet is implemented by clients that can look up deposit addresses and withdraw crypto from the account
+type Wallet interface {
+	GetDepositAddress(asset, network string) (*DepositAddress, error)
+	Withdraw(req WithdrawalRequest) (string, error)
+	GetWithdrawals(asset, clientID string, since time.Time) ([]Withdrawal, error)
+}
+
+// walletOf returns the wallet of a client, or an error when the exchange does not provide one
+func walletOf(client ExchangeClient, exchange string) (Wallet, error) {
+	wallet, ok := baseClient(client).(Wallet)
+	if !ok {
+		return nil, fmt.Errorf("deposits and withdrawals are not supported on %s", exchange)
+	}
+	return wallet, nil
+}
+
+// binanceWithdrawalStatuses names the status codes of the Binance withdrawal history
+var binanceWithdrawalStatuses = map[int]string{
+	0: "EMAIL_SENT",
+	1: "CANCELLED",
+	2: "AWAITING_APPROVAL",
+	3: "REJECTED",
+	4: "PROCESSING",
+	5: "FAILURE",
+	6: "COMPLETED",
+}
+
+// binanceWithdrawalWindow is the longest period a withdrawal history request may span
+const binanceWithdrawalWindow = 90 * 24 * time.Hour
+
+// GetDepositAddress gets the address an asset is deposited to on a network, the asset's default network when
+// network is empty
+func (c *BinanceClient) GetDepositAddress(asset, network string) (*DepositAddress, error) {
+	params := url.Values{}
+	params.Set("coin", asset)
+	if network != "" {
+		params.Set("network", network)
+	}
+	body, err := c.sendSigned("GET", "/sapi/v1/capital/deposit/address", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Coin    string `json:"coin"`
+		Address string `json:"address"`
+		Tag     string `json:"tag"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	return &DepositAddress{Asset: raw.Coin, Network: network, Address: raw.Address, Tag: raw.Tag}, nil
+}
+
+// Withdraw applies for a withdrawal and returns its ID. The request is sent once without retries, so a lost
+// response cannot withdraw twice; look the withdrawal up by its client ID instead.
+func (c *BinanceClient) Withdraw(req WithdrawalRequest) (string, error) {
+	params := url.Values{}
+	params.Set("coin", req.Asset)
+	params.Set("address", req.Address)
+	params.Set("amount", req.Amount.String())
+	if req.Network != "" {
+		params.Set("network", req.Network)
+	}
+	if req.Tag != "" {
+		params.Set("addressTag", req.Tag)
+	}
+	if req.ClientID != "" {
+		params.Set("withdrawOrderId", req.ClientID)
+	}
+	body, err := c.resendOnTimestampError(func() (*httpResult, error) {
+		return c.sendOnce("POST", "/sapi/v1/capital/withdraw/apply", params, binanceAuthSigned)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var raw struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return "", fmt.Errorf("error parsing response: %v", err)
+	}
+	return raw.ID, nil
+}
+
+// GetWithdrawals lists the withdrawals of an asset applied for in the 90 days from since, or in the last 90 days
+// when since is zero, filtered by client ID when it is set. An empty asset lists every asset.
+func (c *BinanceClient) GetWithdrawals(asset, clientID string, since time.Time) ([]Withdrawal, error) {
+	params := url.Values{}
+	if asset != "" {
+		params.Set("coin", asset)
+	}
+	if clientID != "" {
+		params.Set("withdrawOrderId", clientID)
+	}
+	if !since.IsZero() {
+		until := since.Add(binanceWithdrawalWindow)
+		if now := time.Now(); until.After(now) {
+			until = now
+		}
+		params.Set("startTime", strconv.FormatInt(since.UnixMilli(), 10))
+		params.Set("endTime", strconv.FormatInt(until.UnixMilli(), 10))
+	}
+	body, err := c.sendSigned("GET", "/sapi/v1/capital/withdraw/history", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw []struct {
+		ID              string `json:"id"`
+		WithdrawOrderID string `json:"withdrawOrderId"`
+		Coin            string `json:"coin"`
+		Network         string `json:"network"`
+		Address         string `json:"address"`
+		Amount          string `json:"amount"`
+		TransactionFee  string `json:"transactionFee"`
+		Status          int    `json:"status"`
+		TxID            string `json:"txId"`
+		ApplyTime       string `json:"applyTime"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("error parsing response: %v", err)
+	}
+	withdrawals := make([]Withdrawal, 0, len(raw))
+	for _, w := range raw {
+		withdrawal := Withdrawal{
+			ID:       w.ID,
+			ClientID: w.WithdrawOrderID,
+			Asset:    w.Coin,
+			Network:  w.Network,
+			Address:  w.Address,
+			Amount:   decimalOrZero(w.Amount),
+			Fee:      decimalOrZero(w.TransactionFee),
+			Status:   binanceWithdrawalStatuses[w.Status],
+			TxID:     w.TxID,
+		}
+		if withdrawal.Status == "" {
+			withdrawal.Status = strconv.Itoa(w.Status)
+		}
+		withdrawal.AppliedAt, _ = time.ParseInLocation(time.DateTime, w.ApplyTime, time.UTC)
+		withdrawals = append(withdrawals, withdrawal)
+	}
+	return withdrawals, nil
+}
+
+// WithdrawConfig sends what a BUY run acquired to an address once the run has completed, e.g. to cold storage
+type WithdrawConfig struct {
+	Address string `json:"address"`
+	Network string `json:"network,omitempty"`
+	Tag     string `json:"tag,omitempty"`
+}
+
+// Enabled reports whether the run withdraws its fills
+func (w WithdrawConfig) Enabled() bool {
+	return w.Address != ""
+}
+
+// withdrawRun withdraws the base asset a completed BUY run acquired, net of the fees paid in it and at most
+// the free balance, and records the withdrawal in the state. A withdrawal already applied for by the run is
+// found by its client ID, the run ID, rather than applied for again.
+func withdrawRun(client ExchangeClient, state *RunState, statePath string) {
+	cfg := state.Config
+	asset := cfg.baseAsset()
+	wallet, err := walletOf(client, cfg.Exchange)
+	if err != nil {
+		log.Printf("Error withdrawing %s: %v", asset, err)
+		return
+	}
+	if previous, err := wallet.GetWithdrawals(asset, state.RunID, time.Time{}); err != nil {
+		log.Printf("Error checking for a previous withdrawal of %s: %v", asset, err)
+		return
+	} else if len(previous) > 0 {
+		state.WithdrawalID = previous[0].ID
+		state.save(statePath)
+		log.Printf("Withdrawal %s of %s %s was already applied for (%s)", previous[0].ID, previous[0].Amount, asset, previous[0].Status)
+		return
+	}
+
+	amount := state.FilledBase.Sub(state.Commissions[asset])
+	if balance, err := client.GetBalance(asset); err == nil {
+		amount = minDecimal(amount, balance)
+	}
+	if amount.Sign() <= 0 {
+		log.Printf("No %s to withdraw", asset)
+		return
+	}
+	req := WithdrawalRequest{Asset: asset, Network: cfg.Withdraw.Network, Address: cfg.Withdraw.Address, Tag: cfg.Withdraw.Tag,
+		Amount: amount, ClientID: state.RunID}
+	id, err := wallet.Withdraw(req)
+	if err != nil {
+		log.Printf("Error withdrawing %s %s to %s: %v", amount, asset, req.Address, err)
+		state.audit(AuditError, "withdrawal_amount", amount, "address", req.Address, "error", err.Error())
+		return
+	}
+	state.WithdrawalID = id
+	state.save(statePath)
+	log.Printf("Applied for withdrawal %s of %s %s to %s", id, amount, asset, req.Address)
+	state.audit(AuditWithdrawal, "withdrawal_id", id, "asset", asset, "amount", amount, "network", req.Network, "address", req.Address)
+}
+
+// runDepositAddress prints the address an asset is deposited to
+func runDepositAddress(args []string) error {
+	fs, common := newFlagSet("deposit-address")
+	asset := fs.String("asset", "", "Asset to deposit (e.g., BTC)")
+	network := fs.String("network", "", "Network to deposit over (e.g., BTC, ETH, BSC; empty for the asset's default network)")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if *asset == "" {
+		return fmt.Errorf("-asset is required")
+	}
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+	wallet, err := walletOf(client, common.exchange)
+	if err != nil {
+		return err
+	}
+
+	address, err := wallet.GetDepositAddress(strings.ToUpper(*asset), strings.ToUpper(*network))
+	if err != nil {
+		return fmt.Errorf("error getting %s deposit address: %v", *asset, err)
+	}
+	fmt.Printf("Asset:    %s\n", address.Asset)
+	if address.Network != "" {
+		fmt.Printf("Network:  %s\n", address.Network)
+	}
+	fmt.Printf("Address:  %s\n", address.Address)
+	if address.Tag != "" {
+		fmt.Printf("Tag:      %s\n", address.Tag)
+	}
+	return nil
+}
+
+// runWithdraw withdraws an asset to an address, refusing to unless withdrawals are explicitly enabled
+func runWithdraw(args []string) error {
+	fs, common := newFlagSet("withdraw")
+	asset := fs.String("asset", "", "Asset to withdraw (e.g., BTC)")
+	network := fs.String("network", "", "Network to withdraw over (e.g., BTC, ETH, BSC; empty for the asset's default network)")
+	address := fs.String("address", "", "Address to withdraw to")
+	tag := fs.String("tag", "", "Memo or tag the destination needs on networks that use one")
+	amount := fs.String("amount", "", "Amount to withdraw, before the network fee is deducted (e.g., 0.05)")
+	clientID := fs.String("client-id", "", "ID recorded with the withdrawal in the history, to find it again")
+	enable := fs.Bool("enable-withdrawals", false, "Allow withdrawals, which are refused without it")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if !*enable {
+		return fmt.Errorf("withdrawals are disabled. Pass -enable-withdrawals to allow them")
+	}
+	if *asset == "" || *address == "" {
+		return fmt.Errorf("-asset and -address are required")
+	}
+	quantity, err := ParseDecimal(*amount)
+	if err != nil || quantity.Sign() <= 0 {
+		return fmt.Errorf("invalid amount %q: a positive amount is required", *amount)
+	}
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+	wallet, err := walletOf(client, common.exchange)
+	if err != nil {
+		return err
+	}
+
+	req := WithdrawalRequest{Asset: strings.ToUpper(*asset), Network: strings.ToUpper(*network), Address: *address, Tag: *tag,
+		Amount: quantity, ClientID: *clientID}
+	id, err := wallet.Withdraw(req)
+	if err != nil {
+		return fmt.Errorf("error withdrawing %s %s: %v", req.Amount, req.Asset, err)
+	}
+	fmt.Printf("Applied for withdrawal %s of %s %s to %s\n", id, req.Amount, req.Asset, req.Address)
+	return nil
+}
+
+// runWithdrawals lists recent withdrawals
+func runWithdrawals(args []string) error {
+	fs, common := newFlagSet("withdrawals")
+	asset := fs.String("asset", "", "Asset to list the withdrawals of (empty for all)")
+	days := fs.Int("days", 30, "Days of history to list (at most 90)")
+	if err := common.parse(fs, args); err != nil {
+		return err
+	}
+	if *days < 1 || *days > 90 {
+		return fmt.Errorf("-days must be between 1 and 90")
+	}
+	client, err := common.client()
+	if err != nil {
+		return err
+	}
+	wallet, err := walletOf(client, common.exchange)
+	if err != nil {
+		return err
+	}
+
+	withdrawals, err := wallet.GetWithdrawals(strings.ToUpper(*asset), "", time.Now().AddDate(0, 0, -*days))
+	if err != nil {
+		return fmt.Errorf("error getting withdrawals: %v", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tID\tASSET\tNETWORK\tAMOUNT\tFEE\tSTATUS\tADDRESS\tTX ID")
+	for _, wd := range withdrawals {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", wd.AppliedAt.Format(time.DateTime), wd.ID, wd.Asset, wd.Network,
+			wd.Amount, wd.Fee, wd.Status, wd.Address, wd.TxID)
+	}
+	return w.Flush()
+}
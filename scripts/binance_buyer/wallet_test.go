@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestWithdrawRunOnce withdraws what a run bought from the mock exchange and checks that a rerun of the withdrawal
+// finds it by the run ID instead of withdrawing again
+func TestWithdrawRunOnce(t *testing.T) {
+	mock, client := newMockClient(t)
+	mock.mu.Lock()
+	mock.free["BTC"] = decimalOrZero("0.01")
+	mock.mu.Unlock()
+
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	newState := func() *RunState {
+		return &RunState{
+			RunID:  "run-1",
+			Config: TWAPConfig{Symbol: "BTCUSDT", Side: "BUY", QuoteAsset: "USDT", Withdraw: WithdrawConfig{Address: "bc1qcold"}},
+			Fills:  Fills{FilledBase: decimalOrZero("0.004"), Commissions: map[string]Decimal{"BTC": decimalOrZero("0.0001")}},
+		}
+	}
+	first := newState()
+	withdrawRun(client, first, statePath)
+	if first.WithdrawalID == "" {
+		t.Fatal("no withdrawal applied for")
+	}
+
+	// A rerun, e.g. after the process died before saving the withdrawal ID, finds the first withdrawal
+	rerun := newState()
+	withdrawRun(client, rerun, statePath)
+	if rerun.WithdrawalID != first.WithdrawalID {
+		t.Errorf("rerun recorded withdrawal %q, want %q", rerun.WithdrawalID, first.WithdrawalID)
+	}
+
+	mock.mu.Lock()
+	defer mock.mu.Unlock()
+	if len(mock.withdrawals) != 1 {
+		t.Fatalf("withdrew %d times, want once", len(mock.withdrawals))
+	}
+	if got := mock.withdrawals[0]; got.Amount.Cmp(decimalOrZero("0.0039")) != 0 || got.Address != "bc1qcold" || got.ClientID != "run-1" {
+		t.Errorf("withdrew %s to %s under %q, want 0.0039 net of fees to bc1qcold under the run ID", got.Amount, got.Address, got.ClientID)
+	}
+}
+
+func TestWithdrawRequiresEnabling(t *testing.T) {
+	err := runWithdraw([]string{"-asset", "BTC", "-address", "bc1qcold", "-amount", "0.01"})
+	if err == nil || !strings.Contains(err.Error(), "-enable-withdrawals") {
+		t.Errorf("error %v, want withdrawals refused without -enable-withdrawals", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const (
+	// defaultWasmMemoryMB bounds the memory of a WebAssembly strategy module unless configured
+	defaultWasmMemoryMB = 64
+	// maxWasmMemoryMB is the most memory a 32-bit WebAssembly module can address
+	maxWasmMemoryMB = 4096
+	// wasmPagesPerMB is the number of 64 KiB WebAssembly memory pages in a MiB
+	wasmPagesPerMB = 16
+)
+
+// wasmMagic starts every WebAssembly binary module
+var wasmMagic = []byte("\x00asm")
+
+// wasmCompilationCache keeps the compiled code of strategy modules, so restarting a module, or running the same
+// module in several strategies, does not compile it again
+var wasmCompilationCache = wazero.NewCompilationCache()
+
+// wasmProcess is a WebAssembly strategy module running in-process
+type wasmProcess struct {
+	runtime wazero.Runtime
+	cancel  context.CancelFunc
+	done    chan error
+}
+
+// Kill aborts the module
+func (p *wasmProcess) Kill() {
+	p.cancel()
+}
+
+// Wait waits for the module to exit and releases its runtime
+func (p *wasmProcess) Wait() error {
+	err := <-p.done
+	p.runtime.Close(context.Background())
+	return err
+}
+
+// checkWasmModule checks that a strategy module is a WebAssembly binary and that its memory limit is valid
+func checkWasmModule(module string, memoryMB int) error {
+	data, err := os.ReadFile(module)
+	if err != nil {
+		return fmt.Errorf("error reading wasm module: %v", err)
+	}
+	if !bytes.HasPrefix(data, wasmMagic) {
+		return fmt.Errorf("%s is not a WebAssembly binary module", module)
+	}
+	if memoryMB < 0 || memoryMB > maxWasmMemoryMB {
+		return fmt.Errorf("wasm_memory_mb must be between 1 and %d", maxWasmMemoryMB)
+	}
+	return nil
+}
+
+// startWasmModule runs a WASI command module in-process under wazero, returning it with its stdin and stdout.
+// The module may only import WASI. It sees no files, environment variables or network, only its name as its
+// single argument, and its memory is bounded by memoryMB, 64 MiB when zero. Its stderr is passed through.
+func startWasmModule(module string, memoryMB int) (scriptProcess, io.WriteCloser, *os.File, error) {
+	code, err := os.ReadFile(module)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error reading wasm module: %v", err)
+	}
+	if memoryMB == 0 {
+		memoryMB = defaultWasmMemoryMB
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCompilationCache(wasmCompilationCache).
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(uint32(memoryMB*wasmPagesPerMB)))
+	fail := func(err error) (scriptProcess, io.WriteCloser, *os.File, error) {
+		runtime.Close(ctx)
+		cancel()
+		return nil, nil, nil, err
+	}
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		return fail(fmt.Errorf("error instantiating WASI: %v", err))
+	}
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		return fail(fmt.Errorf("error compiling %s: %v", module, err))
+	}
+	for _, imported := range compiled.ImportedFunctions() {
+		if moduleName, name, _ := imported.Import(); moduleName != wasi_snapshot_preview1.ModuleName {
+			return fail(fmt.Errorf("%s imports %s.%s. Strategy modules may only import %s", module, moduleName, name,
+				wasi_snapshot_preview1.ModuleName))
+		}
+	}
+
+	stdinReader, stdin, err := os.Pipe()
+	if err != nil {
+		return fail(err)
+	}
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		stdinReader.Close()
+		stdin.Close()
+		return fail(err)
+	}
+	config := wazero.NewModuleConfig().
+		WithArgs(filepath.Base(module)).
+		WithStdin(stdinReader).
+		WithStdout(stdoutWriter).
+		WithStderr(os.Stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep()
+	done := make(chan error, 1)
+	go func() {
+		_, err := runtime.InstantiateModule(ctx, compiled, config)
+		stdinReader.Close()
+		stdoutWriter.Close()
+		done <- err
+	}()
+	log.Printf("WebAssembly strategy started: %s", module)
+	return &wasmProcess{runtime: runtime, cancel: cancel, done: done}, stdin, stdout, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wasmTestStrategy enters on every candle it is sent, and reports what the sandbox lets it see
+const wasmTestStrategy = `package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+)
+
+func main() {
+	_, err := os.ReadFile("/etc/hostname")
+	reason := fmt.Sprintf("args=%d env=%d fs=%t", len(os.Args), len(os.Environ()), err == nil)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fmt.Printf("{\"action\": \"enter\", \"reason\": %q}\n", reason)
+	}
+	if len(os.Args) > 0 && os.Args[0] == "hang.wasm" {
+		for {
+		}
+	}
+}
+`
+
+// buildWasmTestStrategy compiles the test strategy to a WASI module named name
+func buildWasmTestStrategy(t *testing.T, name string) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("building a WebAssembly module is slow")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("no Go toolchain to build the test module")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(wasmTestStrategy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	module := filepath.Join(dir, name)
+	cmd := exec.Command(goTool, "build", "-o", module, "main.go")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm", "GOFLAGS=", "GO111MODULE=off")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("error building the test module: %v\n%s", err, output)
+	}
+	return module
+}
+
+func TestScriptStrategyRunsWasmModuleInProcess(t *testing.T) {
+	module := buildWasmTestStrategy(t, "strategy.wasm")
+	strategy, err := NewScriptStrategy(ScriptConfig{Wasm: module})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer strategy.Stop()
+	for i := range 2 {
+		signal := strategy.OnCandle(hourlyTestCandle(i, 100), false)
+		if signal.Action != ActionEnter || signal.Reason != "args=1 env=0 fs=false" {
+			t.Errorf("candle %d: signal %+v, want an entry from a module without environment or files", i, signal)
+		}
+	}
+}
+
+func TestScriptStrategyStopsHungWasmModule(t *testing.T) {
+	strategy, err := NewScriptStrategy(ScriptConfig{Wasm: buildWasmTestStrategy(t, "hang.wasm")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signal := strategy.OnCandle(hourlyTestCandle(0, 100), false); signal.Action != ActionEnter {
+		t.Fatalf("signal %+v, want an entry", signal)
+	}
+	stopped := make(chan struct{})
+	go func() {
+		strategy.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stop did not abort a module that ignores the end of its input")
+	}
+}
+
+func TestScriptStrategyRejectsInvalidWasmModules(t *testing.T) {
+	dir := t.TempDir()
+	notWasm := filepath.Join(dir, "strategy.wasm")
+	if err := os.WriteFile(notWasm, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cfg  ScriptConfig
+		want string
+	}{
+		{cfg: ScriptConfig{Wasm: notWasm}, want: "is not a WebAssembly binary module"},
+		{cfg: ScriptConfig{Wasm: filepath.Join(dir, "missing.wasm")}, want: "error reading wasm module"},
+		{cfg: ScriptConfig{Wasm: notWasm, Command: []string{"python3"}}, want: "either a command or a wasm module"},
+	}
+	for _, tt := range tests {
+		if _, err := NewScriptStrategy(tt.cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: error %v, want %q", tt.cfg, err, tt.want)
+		}
+	}
+
+	// A module importing anything besides WASI is refused when it starts
+	importsHost := filepath.Join(dir, "imports.wasm")
+	module := []byte("\x00asm\x01\x00\x00\x00" +
+		"\x01\x04\x01\x60\x00\x00" + // type section: one func type () -> ()
+		"\x02\x0b\x01\x03env\x03now\x00\x00") // import section: env.now of type 0
+	if err := os.WriteFile(importsHost, module, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := startWasmModule(importsHost, 0); err == nil || !strings.Contains(err.Error(), "imports env.now") {
+		t.Errorf("starting a module importing env.now returned %v, want it refused", err)
+	}
+}
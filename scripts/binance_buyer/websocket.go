@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// WebSocket frame opcodes
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// wsAcceptGUID is appended to the handshake key to compute Sec-WebSocket-Accept
+const wsAcceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxMessageSize bounds the size of a single incoming message
+const wsMaxMessageSize = 16 << 20
+
+// errWebSocketClosed is returned when the server closes the connection
+var errWebSocketClosed = errors.New("websocket closed by server")
+
+// wsConn is a minimal RFC 6455 WebSocket client connection that answers pings automatically
+type wsConn struct {
+	conn        net.Conn
+	reader      *bufio.Reader
+	readTimeout time.Duration
+	writeMu     sync.Mutex
+}
+
+// dialWebSocket opens a WebSocket connection to a ws:// or wss:// URL
+func dialWebSocket(rawURL string, timeout time.Duration) (*wsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing websocket URL: %v", err)
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch u.Scheme {
+	case "wss":
+		conn, err = dialTLS(dialer, hostPort(u, "443"), u.Hostname())
+	case "ws":
+		conn, err = dialTCP(dialer, hostPort(u, "80"))
+	default:
+		return nil, fmt.Errorf("unsupported websocket scheme: %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %v", u.Host, err)
+	}
+
+	ws, err := handshake(conn, u, timeout)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ws, nil
+}
+
+// dialTLS opens a TLS connection to addr, through the configured proxy when there is one
+func dialTLS(dialer *net.Dialer, addr, serverName string) (net.Conn, error) {
+	conn, err := dialTCP(dialer, addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName})
+	tlsConn.SetDeadline(time.Now().Add(dialer.Timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+// hostPort returns the host of u with defaultPort added when it has none
+func hostPort(u *url.URL, defaultPort string) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	return net.JoinHostPort(u.Hostname(), defaultPort)
+}
+
+// handshake performs the HTTP upgrade on an established connection
+func handshake(conn net.Conn, u *url.URL, timeout time.Duration) (*wsConn, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating websocket key: %v", err)
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	if _, err := fmt.Fprintf(conn, "GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n",
+		u.RequestURI(), u.Host, key); err != nil {
+		return nil, fmt.Errorf("error sending websocket handshake: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error reading websocket handshake: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("websocket handshake failed with status %s", resp.Status)
+	}
+	accept := sha1.Sum([]byte(key + wsAcceptGUID))
+	if resp.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(accept[:]) {
+		return nil, fmt.Errorf("websocket handshake returned an invalid accept key")
+	}
+
+	return &wsConn{conn: conn, reader: reader}, nil
+}
+
+// ReadMessage returns the next text or binary message, answering pings and reassembling fragments
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+		case wsOpPong:
+		case wsOpClose:
+			c.writeFrame(wsOpClose, payload)
+			return nil, errWebSocketClosed
+		default:
+			if len(message)+len(payload) > wsMaxMessageSize {
+				return nil, fmt.Errorf("websocket message exceeds %d bytes", wsMaxMessageSize)
+			}
+			message = append(message, payload...)
+			if fin {
+				return message, nil
+			}
+		}
+	}
+}
+
+// readFrame reads a single frame from the connection
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	if c.readTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if length > wsMaxMessageSize {
+		return false, 0, nil, fmt.Errorf("websocket frame exceeds %d bytes", wsMaxMessageSize)
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// WriteText sends a text message
+func (c *wsConn) WriteText(payload []byte) error {
+	return c.writeFrame(wsOpText, payload)
+}
+
+// Ping sends a ping control frame
+func (c *wsConn) Ping() error {
+	return c.writeFrame(wsOpPing, nil)
+}
+
+// writeFrame sends a single masked frame, as required for client to server frames
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+	switch length := len(payload); {
+	case length < 126:
+		frame = append(frame, 0x80|byte(length))
+	case length <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return fmt.Errorf("error generating websocket mask: %v", err)
+	}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// SetReadTimeout sets how long the connection may stay silent, counting control frames, before reads fail
+func (c *wsConn) SetReadTimeout(timeout time.Duration) {
+	c.readTimeout = timeout
+}
+
+// Close sends a normal closure frame and closes the underlying connection
+func (c *wsConn) Close() error {
+	c.writeFrame(wsOpClose, []byte{0x03, 0xE8})
+	return c.conn.Close()
+}
+
+// wsMaxBackoff caps the delay between stream reconnection attempts
+const wsMaxBackoff = 30 * time.Second
+
+// keepConnected calls connect until stop is closed, backing off exponentially between attempts.
+// connect reports whether it established a connection, which resets the backoff.
+func keepConnected(name string, stop <-chan struct{}, connect func() (bool, error)) {
+	backoff := time.Second
+	for {
+		connected, err := connect()
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if connected {
+			backoff = time.Second
+		}
+		log.Printf("%s disconnected: %v. Reconnecting in %s", name, err, backoff)
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, wsMaxBackoff)
+	}
+}
+
+// closeOnStop closes conn when stop is closed, unblocking pending reads. The returned function
+// must be called once the connection is no longer used.
+func closeOnStop(conn *wsConn, stop <-chan struct{}) func() {
+	closed := make(chan struct{})
+	go func() {
+		select {
+		case <-stop:
+			conn.Close()
+		case <-closed:
+		}
+	}()
+	return func() { close(closed) }
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// weekdays maps the day names accepted by -trading-days to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// TradingWindow restricts slices to the hours from Start to End minutes after midnight UTC on Days. A window
+// ending before it starts runs past midnight and belongs to the day it starts on. Equal Start and End allow
+// the whole day, and no Days allow every day.
+type TradingWindow struct {
+	Start int            `json:"start_minute"`
+	End   int            `json:"end_minute"`
+	Days  []time.Weekday `json:"days,omitempty"`
+}
+
+// Enabled reports whether the window restricts trading
+func (w TradingWindow) Enabled() bool {
+	return w.Start != w.End || len(w.Days) > 0
+}
+
+// allows reports whether trading is allowed on day
+func (w TradingWindow) allows(day time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, day)
+}
+
+// Open reports whether t falls in the window
+func (w TradingWindow) Open(t time.Time) bool {
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return w.allows(t.Weekday()) && minute >= w.Start && minute < w.End
+	}
+	// The part after midnight of a window running past it belongs to the previous day
+	return (minute >= w.Start && w.allows(t.Weekday())) || (minute < w.End && w.allows(t.AddDate(0, 0, -1).Weekday()))
+}
+
+// NextOpen returns the time the window next opens at or after t
+func (w TradingWindow) NextOpen(t time.Time) time.Time {
+	if w.Open(t) {
+		return t
+	}
+	midnight := t.UTC().Truncate(24 * time.Hour)
+	for day := range 8 {
+		opens := midnight.AddDate(0, 0, day).Add(time.Duration(w.Start) * time.Minute)
+		if opens.After(t) && w.allows(opens.Weekday()) {
+			return opens
+		}
+	}
+	return t
+}
+
+// String formats the window as its flag values
+func (w TradingWindow) String() string {
+	var days []string
+	for _, day := range w.Days {
+		days = append(days, strings.ToLower(day.String()[:3]))
+	}
+	hours := "all day"
+	if w.Start != w.End {
+		hours = fmt.Sprintf("%02d:%02d-%02d:%02d UTC", w.Start/60, w.Start%60, w.End/60, w.End%60)
+	}
+	if len(days) == 0 {
+		return hours
+	}
+	return hours + " on " + strings.Join(days, ",")
+}
+
+// parseTradingWindow parses the -trading-hours and -trading-days flags, e.g. "08:00-22:00" and "mon-fri" or
+// "mon,wed,fri". Empty values allow the whole day and every day.
+func parseTradingWindow(hours, days string) (TradingWindow, error) {
+	var w TradingWindow
+	if hours != "" {
+		start, end, ok := strings.Cut(hours, "-")
+		if !ok {
+			return w, fmt.Errorf("invalid trading hours: %s. Use HH:MM-HH:MM in UTC, e.g. 08:00-22:00", hours)
+		}
+		var err error
+		if w.Start, err = parseMinuteOfDay(start); err != nil {
+			return w, err
+		}
+		if w.End, err = parseMinuteOfDay(end); err != nil {
+			return w, err
+		}
+		if w.Start == w.End {
+			return w, fmt.Errorf("trading hours %s are empty. Leave -trading-hours unset to trade all day", hours)
+		}
+	}
+	if days == "" {
+		return w, nil
+	}
+	for _, part := range strings.Split(strings.ToLower(days), ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		from, ok := weekdays[first]
+		if !ok {
+			return w, fmt.Errorf("invalid trading day: %s. Use sun, mon, tue, wed, thu, fri or sat", first)
+		}
+		to := from
+		if isRange {
+			if to, ok = weekdays[last]; !ok {
+				return w, fmt.Errorf("invalid trading day: %s. Use sun, mon, tue, wed, thu, fri or sat", last)
+			}
+		}
+		// Ranges may wrap around the end of the week, e.g. fri-mon
+		for day := from; ; day = (day + 1) % 7 {
+			if !slices.Contains(w.Days, day) {
+				w.Days = append(w.Days, day)
+			}
+			if day == to {
+				break
+			}
+		}
+	}
+	slices.Sort(w.Days)
+	return w, nil
+}
+
+// parseMinuteOfDay parses a HH:MM time of day into minutes after midnight
+func parseMinuteOfDay(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day: %s. Use HH:MM in UTC", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// waitForWindow holds the run back while its trading window is closed, reporting whether it waited and false
+// if ctx was cancelled meanwhile
+func (s *RunState) waitForWindow(ctx context.Context) (waited, ok bool) {
+	window := s.Config.Window
+	if !window.Enabled() || window.Open(time.Now()) {
+		return false, true
+	}
+	opens := window.NextOpen(time.Now())
+	s.audit(AuditWindowClosed, "next_slice", s.NextSlice+1, "window", window.String(), "opens_at", opens.Format(time.RFC3339))
+	slog.Info("Outside the trading window. Pausing until it opens.", "symbol", s.Config.Symbol, "window", window.String(),
+		"opens_at", opens.Format(time.RFC3339), "next_slice", s.NextSlice+1)
+	if !sleepContext(ctx, time.Until(opens)) {
+		return true, false
+	}
+	ends := time.Now().Add(time.Duration(s.TotalSlices-s.NextSlice) * s.Interval)
+	slog.Info("Trading window open. Re-planning the remaining slices from now.", "symbol", s.Config.Symbol,
+		"next_slice", s.NextSlice+1, "total_slices", s.TotalSlices, "expected_end", ends.Format(time.RFC3339))
+	return true, true
+}